@@ -314,7 +314,8 @@ func convertOpenAITools(toolsJSON string) []anthropicTool {
 // convertOpenAIToolChoice accepts the spec form
 // ({type:function, function:{name:X}}) and the flat legacy form
 // ({type:function, name:X}) some clients send. Unknown object shapes
-// are warned and dropped rather than silently treated as auto.
+// are warned and dropped rather than silently treated as auto. The
+// Gemini and Bedrock translators map the Anthropic shape onward.
 func convertOpenAIToolChoice(toolChoiceJSON string) *anthropicToolChoice {
 	if toolChoiceJSON == "" {
 		return nil
@@ -339,7 +340,7 @@ func convertOpenAIToolChoice(toolChoiceJSON string) *anthropicToolChoice {
 		} `json:"function"`
 	}
 	if err := json.Unmarshal([]byte(toolChoiceJSON), &asObj); err != nil {
-		xlog.Warn("cloud-proxy: anthropic translate: unparseable tool_choice, dropping", "error", err)
+		xlog.Warn("cloud-proxy: translate: unparseable tool_choice, dropping", "error", err)
 		return nil
 	}
 	if name := asObj.Function.Name; name != "" {
@@ -348,7 +349,7 @@ func convertOpenAIToolChoice(toolChoiceJSON string) *anthropicToolChoice {
 	if asObj.Name != "" {
 		return &anthropicToolChoice{Type: "tool", Name: asObj.Name}
 	}
-	xlog.Warn("cloud-proxy: anthropic translate: unrecognised tool_choice shape, dropping", "shape", toolChoiceJSON)
+	xlog.Warn("cloud-proxy: translate: unrecognised tool_choice shape, dropping", "shape", toolChoiceJSON)
 	return nil
 }
 
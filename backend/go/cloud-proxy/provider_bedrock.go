@@ -0,0 +1,509 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	pb "github.com/mudler/LocalAI/pkg/grpc/proto"
+	"github.com/mudler/xlog"
+)
+
+// AWS Bedrock Converse wire-format types. Narrowed to what translate
+// mode preserves through the Reply proto: text + toolUse blocks + usage
+// tokens. Image/document blocks, guardrails, reasoning content and
+// prompt-cache points are not modelled — passthrough mode covers those.
+//
+// Notable differences from OpenAI:
+//   - The model id and the operation live in the URL
+//     (/model/{modelId}/converse, /converse-stream), not in the body.
+//   - Roles are user/assistant only — system messages move to a
+//     top-level `system` block list, and tool results are toolResult
+//     blocks of a user turn.
+//   - The stream is not SSE but AWS event-stream: binary frames whose
+//     :event-type header names the event (contentBlockStart carries the
+//     toolUse id + name, contentBlockDelta carries text or a toolUse
+//     input fragment, metadata carries usage after messageStop).
+
+type bedrockRequest struct {
+	Messages        []bedrockMessage        `json:"messages"`
+	System          []bedrockContentBlock   `json:"system,omitempty"`
+	InferenceConfig *bedrockInferenceConfig `json:"inferenceConfig,omitempty"`
+	ToolConfig      *bedrockToolConfig      `json:"toolConfig,omitempty"`
+}
+
+type bedrockMessage struct {
+	Role    string                `json:"role"`
+	Content []bedrockContentBlock `json:"content"`
+}
+
+// bedrockContentBlock is a union: exactly one member is set. Response
+// blocks of kinds we don't model (reasoningContent, ...) decode to an
+// empty block and are skipped.
+type bedrockContentBlock struct {
+	Text       string             `json:"text,omitempty"`
+	ToolUse    *bedrockToolUse    `json:"toolUse,omitempty"`
+	ToolResult *bedrockToolResult `json:"toolResult,omitempty"`
+}
+
+type bedrockToolUse struct {
+	ToolUseID string          `json:"toolUseId"`
+	Name      string          `json:"name"`
+	Input     json.RawMessage `json:"input"`
+}
+
+type bedrockToolResult struct {
+	ToolUseID string                `json:"toolUseId"`
+	Content   []bedrockContentBlock `json:"content"`
+}
+
+type bedrockInferenceConfig struct {
+	MaxTokens     int32    `json:"maxTokens,omitempty"`
+	StopSequences []string `json:"stopSequences,omitempty"`
+}
+
+type bedrockToolConfig struct {
+	Tools      []bedrockTool      `json:"tools"`
+	ToolChoice *bedrockToolChoice `json:"toolChoice,omitempty"`
+}
+
+type bedrockTool struct {
+	ToolSpec bedrockToolSpec `json:"toolSpec"`
+}
+
+type bedrockToolSpec struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	InputSchema struct {
+		JSON json.RawMessage `json:"json"`
+	} `json:"inputSchema"`
+}
+
+// bedrockToolChoice is a union of {"auto":{}} | {"any":{}} |
+// {"tool":{"name":"X"}}. Converse has no "none" — the translator drops
+// the tools instead, like the Anthropic one.
+type bedrockToolChoice struct {
+	Auto *struct{}             `json:"auto,omitempty"`
+	Any  *struct{}             `json:"any,omitempty"`
+	Tool *bedrockToolChoiceFor `json:"tool,omitempty"`
+}
+
+type bedrockToolChoiceFor struct {
+	Name string `json:"name"`
+}
+
+type bedrockResponse struct {
+	Output struct {
+		Message *bedrockMessage `json:"message"`
+	} `json:"output"`
+	StopReason string        `json:"stopReason"`
+	Usage      *bedrockUsage `json:"usage,omitempty"`
+}
+
+type bedrockUsage struct {
+	InputTokens  int `json:"inputTokens"`
+	OutputTokens int `json:"outputTokens"`
+}
+
+// bedrockStreamEvent is the union of the event payloads we process;
+// the :event-type header discriminates. Message is set on exceptions.
+type bedrockStreamEvent struct {
+	ContentBlockIndex int `json:"contentBlockIndex"`
+	Start             *struct {
+		ToolUse *bedrockToolUse `json:"toolUse"`
+	} `json:"start,omitempty"`
+	Delta *struct {
+		Text    string `json:"text"`
+		ToolUse *struct {
+			Input string `json:"input"`
+		} `json:"toolUse"`
+	} `json:"delta,omitempty"`
+	Usage   *bedrockUsage `json:"usage,omitempty"`
+	Message string        `json:"message,omitempty"`
+}
+
+func buildBedrockRequest(opts *pb.PredictOptions) ([]byte, error) {
+	req := bedrockRequest{}
+	if n, stop := opts.GetTokens(), opts.GetStopPrompts(); n > 0 || len(stop) > 0 {
+		// Sampling parameters are not forwarded, as for the other
+		// providers: clients mostly send server-side defaults.
+		req.InferenceConfig = &bedrockInferenceConfig{MaxTokens: max(n, 0), StopSequences: stop}
+	}
+
+	if tools := convertOpenAIToolsToBedrock(opts.GetTools()); len(tools) > 0 {
+		req.ToolConfig = &bedrockToolConfig{Tools: tools}
+		if choice := convertOpenAIToolChoice(opts.GetToolChoice()); choice != nil {
+			switch choice.Type {
+			case anthropicToolChoiceNone:
+				req.ToolConfig = nil
+			case "auto":
+				req.ToolConfig.ToolChoice = &bedrockToolChoice{Auto: &struct{}{}}
+			case "any":
+				req.ToolConfig.ToolChoice = &bedrockToolChoice{Any: &struct{}{}}
+			case "tool":
+				req.ToolConfig.ToolChoice = &bedrockToolChoice{Tool: &bedrockToolChoiceFor{Name: choice.Name}}
+			}
+		}
+	}
+
+	for _, m := range opts.GetMessages() {
+		switch m.GetRole() {
+		case "system":
+			if c := m.GetContent(); c != "" {
+				req.System = append(req.System, bedrockContentBlock{Text: c})
+			}
+		case "user":
+			// Converse rejects blank text blocks.
+			if c := m.GetContent(); c != "" {
+				req.Messages = appendBedrockBlocks(req.Messages, "user", bedrockContentBlock{Text: c})
+			}
+		case "assistant":
+			var blocks []bedrockContentBlock
+			if text := m.GetContent(); text != "" {
+				blocks = append(blocks, bedrockContentBlock{Text: text})
+			}
+			for _, tc := range parseOpenAIToolCalls(m.GetToolCalls()) {
+				blocks = append(blocks, bedrockContentBlock{ToolUse: &bedrockToolUse{
+					ToolUseID: tc.ID,
+					Name:      tc.Function.Name,
+					Input:     jsonObjectOrEmpty(tc.Function.Arguments),
+				}})
+			}
+			if len(blocks) > 0 {
+				req.Messages = appendBedrockBlocks(req.Messages, "assistant", blocks...)
+			}
+		case "tool", "function":
+			req.Messages = appendBedrockBlocks(req.Messages, "user", bedrockContentBlock{ToolResult: &bedrockToolResult{
+				ToolUseID: m.GetToolCallId(),
+				Content:   []bedrockContentBlock{{Text: m.GetContent()}},
+			}})
+		}
+	}
+	if len(req.Messages) == 0 && opts.GetPrompt() != "" {
+		req.Messages = []bedrockMessage{{Role: "user", Content: []bedrockContentBlock{{Text: opts.GetPrompt()}}}}
+	}
+
+	return json.Marshal(req)
+}
+
+// appendBedrockBlocks appends blocks as a message of role, merging into
+// the previous message when it has the same role. Converse 400s unless
+// user and assistant messages alternate, and parallel tool results must
+// share one user message.
+func appendBedrockBlocks(msgs []bedrockMessage, role string, blocks ...bedrockContentBlock) []bedrockMessage {
+	if n := len(msgs); n > 0 && msgs[n-1].Role == role {
+		msgs[n-1].Content = append(msgs[n-1].Content, blocks...)
+		return msgs
+	}
+	return append(msgs, bedrockMessage{Role: role, Content: blocks})
+}
+
+func convertOpenAIToolsToBedrock(toolsJSON string) []bedrockTool {
+	if toolsJSON == "" {
+		return nil
+	}
+	var raw []openAITool
+	if err := json.Unmarshal([]byte(toolsJSON), &raw); err != nil {
+		xlog.Warn("cloud-proxy: bedrock translate: unparseable tools JSON, dropping", "error", err)
+		return nil
+	}
+	tools := make([]bedrockTool, 0, len(raw))
+	for _, t := range raw {
+		if t.Function.Name == "" {
+			continue
+		}
+		spec := bedrockToolSpec{Name: t.Function.Name, Description: t.Function.Description}
+		spec.InputSchema.JSON = t.Function.Parameters
+		if len(spec.InputSchema.JSON) == 0 {
+			spec.InputSchema.JSON = emptyObjectSchema
+		}
+		tools = append(tools, bedrockTool{ToolSpec: spec})
+	}
+	return tools
+}
+
+// bedrockEndpoint resolves the Converse URL. upstream_url is either the
+// runtime base (https://bedrock-runtime.us-east-1.amazonaws.com, the
+// model id is appended) or a full .../model/{modelId}/converse URL,
+// whose operation is swapped for the streaming one as needed. Model ids
+// may be ARNs, so they are path-escaped.
+func bedrockEndpoint(cfg *proxyConfig, stream bool) (string, error) {
+	u, err := url.Parse(cfg.upstreamURL)
+	if err != nil {
+		return "", fmt.Errorf("cloud-proxy: parse upstream_url %q: %w", cfg.upstreamURL, err)
+	}
+	op := "converse"
+	if stream {
+		op = "converse-stream"
+	}
+	path := strings.TrimSuffix(u.EscapedPath(), "/")
+	if base, ok := strings.CutSuffix(path, "/converse-stream"); ok {
+		path = base
+	} else if base, ok := strings.CutSuffix(path, "/converse"); ok {
+		path = base
+	} else {
+		path += "/model/" + url.PathEscape(modelName(cfg, nil))
+	}
+	u.RawPath = path + "/" + op
+	if u.Path, err = url.PathUnescape(u.RawPath); err != nil {
+		return "", fmt.Errorf("cloud-proxy: upstream_url %q: %w", cfg.upstreamURL, err)
+	}
+	return u.String(), nil
+}
+
+// doBedrockRequest is the Bedrock counterpart of doOpenAIRequest. The
+// API key goes out as a Bearer token (a Bedrock API key, or whatever a
+// Bedrock-compatible gateway expects).
+func (c *CloudProxy) doBedrockRequest(ctx context.Context, cfg *proxyConfig, body []byte, stream bool) (*http.Response, error) {
+	endpoint, err := bedrockEndpoint(cfg, stream)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("cloud-proxy: build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "*/*")
+	if cfg.apiKey != "" {
+		applyAuthHeader(req, cfg.provider, cfg.apiKey)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("cloud-proxy: upstream request: %w", err)
+	}
+	return resp, nil
+}
+
+// predictBedrockRich returns the full Reply: joined text blocks,
+// toolUse blocks mapped to ToolCallDelta, and usage tokens.
+func (c *CloudProxy) predictBedrockRich(ctx context.Context, cfg *proxyConfig, opts *pb.PredictOptions) (*pb.Reply, error) {
+	body, err := buildBedrockRequest(opts)
+	if err != nil {
+		return nil, fmt.Errorf("cloud-proxy: marshal request: %w", err)
+	}
+	resp, err := c.doBedrockRequest(ctx, cfg, body, false)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode >= 400 {
+		errBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+		return nil, fmt.Errorf("cloud-proxy: upstream %d: %s", resp.StatusCode, string(errBody))
+	}
+
+	var parsed bedrockResponse
+	if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
+		return nil, fmt.Errorf("cloud-proxy: decode response: %w", err)
+	}
+
+	reply := &pb.Reply{}
+	if parsed.Usage != nil {
+		reply.PromptTokens = int32(parsed.Usage.InputTokens)
+		reply.Tokens = int32(parsed.Usage.OutputTokens)
+	}
+	if parsed.Output.Message == nil {
+		return reply, nil
+	}
+
+	var content strings.Builder
+	var toolCalls []*pb.ToolCallDelta
+	for _, b := range parsed.Output.Message.Content {
+		switch {
+		case b.ToolUse != nil:
+			args := ""
+			if len(b.ToolUse.Input) > 0 {
+				args = string(b.ToolUse.Input)
+			}
+			toolCalls = append(toolCalls, newToolCallDelta(len(toolCalls), b.ToolUse.ToolUseID, b.ToolUse.Name, args))
+		default:
+			content.WriteString(b.Text)
+		}
+	}
+	reply.Message = []byte(content.String())
+	if len(toolCalls) > 0 {
+		reply.ChatDeltas = []*pb.ChatDelta{{ToolCalls: toolCalls}}
+	}
+	return reply, nil
+}
+
+// predictBedrockStreamRich streams Reply chunks from converse-stream.
+// contentBlockStart announces a toolUse (id + name), contentBlockDelta
+// carries text or a fragment of the toolUse input, and the trailing
+// metadata event carries usage. As for Anthropic, the content block
+// index feeds ToolCallDelta.Index so parallel calls can be reassembled.
+// Exceptions arrive in-stream and fail the call.
+func (c *CloudProxy) predictBedrockStreamRich(ctx context.Context, cfg *proxyConfig, opts *pb.PredictOptions, results chan<- *pb.Reply) error {
+	body, err := buildBedrockRequest(opts)
+	if err != nil {
+		return fmt.Errorf("cloud-proxy: marshal request: %w", err)
+	}
+	resp, err := c.doBedrockRequest(ctx, cfg, body, true)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode >= 400 {
+		errBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+		return fmt.Errorf("cloud-proxy: upstream %d: %s", resp.StatusCode, string(errBody))
+	}
+
+	r := bufio.NewReader(resp.Body)
+	for {
+		headers, payload, err := readEventStreamMessage(r)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var ev bedrockStreamEvent
+		if err := json.Unmarshal(payload, &ev); err != nil {
+			xlog.Debug("cloud-proxy: skip malformed event-stream message", "error", err)
+			continue
+		}
+		if headers[":message-type"] == "exception" {
+			return fmt.Errorf("cloud-proxy: upstream %s: %s", headers[":exception-type"], ev.Message)
+		}
+
+		var reply *pb.Reply
+		switch headers[":event-type"] {
+		case "contentBlockStart":
+			if ev.Start == nil || ev.Start.ToolUse == nil {
+				continue
+			}
+			reply = &pb.Reply{ChatDeltas: []*pb.ChatDelta{{ToolCalls: []*pb.ToolCallDelta{
+				newToolCallDelta(ev.ContentBlockIndex, ev.Start.ToolUse.ToolUseID, ev.Start.ToolUse.Name, ""),
+			}}}}
+		case "contentBlockDelta":
+			switch {
+			case ev.Delta == nil:
+				continue
+			case ev.Delta.ToolUse != nil:
+				if ev.Delta.ToolUse.Input == "" {
+					continue
+				}
+				reply = &pb.Reply{ChatDeltas: []*pb.ChatDelta{{ToolCalls: []*pb.ToolCallDelta{
+					newToolCallDelta(ev.ContentBlockIndex, "", "", ev.Delta.ToolUse.Input),
+				}}}}
+			case ev.Delta.Text != "":
+				reply = &pb.Reply{
+					Message:    []byte(ev.Delta.Text),
+					ChatDeltas: []*pb.ChatDelta{{Content: ev.Delta.Text}},
+				}
+			default:
+				continue
+			}
+		case "metadata":
+			if ev.Usage == nil {
+				continue
+			}
+			reply = &pb.Reply{
+				PromptTokens: int32(ev.Usage.InputTokens),
+				Tokens:       int32(ev.Usage.OutputTokens),
+			}
+		default:
+			// messageStart, contentBlockStop and messageStop carry
+			// nothing the Reply proto models; the stream ends at EOF
+			// after metadata.
+			continue
+		}
+		if !sendReply(ctx, results, reply) {
+			return ctx.Err()
+		}
+	}
+}
+
+// eventStreamMaxMessage bounds a single event-stream message, matching
+// the AWS SDK's limit, so a corrupt length prefix can't make us
+// allocate unbounded memory.
+const eventStreamMaxMessage = 16 << 20
+
+// readEventStreamMessage reads one AWS event-stream message: a 12-byte
+// prelude (total length, headers length, prelude CRC32), the headers,
+// the payload and a CRC32 of everything before it. Only string-valued
+// headers are returned — the :event-type / :message-type /
+// :exception-type ones we need are all strings. Returns io.EOF at a
+// clean end of stream.
+func readEventStreamMessage(r io.Reader) (map[string]string, []byte, error) {
+	prelude := make([]byte, 12)
+	if _, err := io.ReadFull(r, prelude); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, nil, io.EOF
+		}
+		return nil, nil, fmt.Errorf("cloud-proxy: read event-stream prelude: %w", err)
+	}
+	total := binary.BigEndian.Uint32(prelude[0:4])
+	headersLen := binary.BigEndian.Uint32(prelude[4:8])
+	if crc32.ChecksumIEEE(prelude[:8]) != binary.BigEndian.Uint32(prelude[8:12]) {
+		return nil, nil, errors.New("cloud-proxy: event-stream prelude checksum mismatch")
+	}
+	if total < 16 || total > eventStreamMaxMessage || headersLen > total-16 {
+		return nil, nil, fmt.Errorf("cloud-proxy: invalid event-stream message length %d", total)
+	}
+
+	msg := make([]byte, total)
+	copy(msg, prelude)
+	if _, err := io.ReadFull(r, msg[12:]); err != nil {
+		return nil, nil, fmt.Errorf("cloud-proxy: read event-stream message: %w", err)
+	}
+	if crc32.ChecksumIEEE(msg[:total-4]) != binary.BigEndian.Uint32(msg[total-4:]) {
+		return nil, nil, errors.New("cloud-proxy: event-stream message checksum mismatch")
+	}
+
+	headers, err := parseEventStreamHeaders(msg[12 : 12+headersLen])
+	if err != nil {
+		return nil, nil, err
+	}
+	return headers, msg[12+headersLen : total-4], nil
+}
+
+// eventStreamHeaderSizes is the fixed value size of each header type;
+// -1 marks the variable-length types (byte array, string), which carry
+// a 2-byte length prefix.
+var eventStreamHeaderSizes = [...]int{0, 0, 1, 2, 4, 8, -1, -1, 8, 16}
+
+const eventStreamHeaderString = 7
+
+func parseEventStreamHeaders(b []byte) (map[string]string, error) {
+	headers := map[string]string{}
+	for len(b) > 0 {
+		nameLen := int(b[0])
+		if len(b) < 1+nameLen+1 {
+			return nil, errors.New("cloud-proxy: truncated event-stream header")
+		}
+		name := string(b[1 : 1+nameLen])
+		typ := int(b[1+nameLen])
+		b = b[2+nameLen:]
+		if typ >= len(eventStreamHeaderSizes) {
+			return nil, fmt.Errorf("cloud-proxy: unknown event-stream header type %d", typ)
+		}
+		size := eventStreamHeaderSizes[typ]
+		if size < 0 {
+			if len(b) < 2 {
+				return nil, errors.New("cloud-proxy: truncated event-stream header")
+			}
+			size = int(binary.BigEndian.Uint16(b))
+			b = b[2:]
+		}
+		if len(b) < size {
+			return nil, errors.New("cloud-proxy: truncated event-stream header")
+		}
+		if typ == eventStreamHeaderString {
+			headers[name] = string(b[:size])
+		}
+		b = b[size:]
+	}
+	return headers, nil
+}
@@ -0,0 +1,352 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	pb "github.com/mudler/LocalAI/pkg/grpc/proto"
+	. "github.com/onsi/gomega"
+)
+
+// bedrockCapture records what the fake Bedrock upstream received: the
+// translated body plus the escaped path (model id + operation) and the
+// Authorization header.
+type bedrockCapture struct {
+	req  bedrockRequest
+	path string
+	auth string
+}
+
+func fakeBedrockUpstream(t *testing.T, handler func(req bedrockRequest) (status int, body []byte, contentType string)) (*httptest.Server, *bedrockCapture) {
+	t.Helper()
+	captured := &bedrockCapture{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(raw, &captured.req)
+		captured.path = r.URL.EscapedPath()
+		captured.auth = r.Header.Get("Authorization")
+		status, body, ct := handler(captured.req)
+		w.Header().Set("Content-Type", ct)
+		w.WriteHeader(status)
+		_, _ = w.Write(body)
+	}))
+	return srv, captured
+}
+
+func newBedrockTranslateCloudProxy(t *testing.T, upstreamURL, upstreamModel string) *CloudProxy {
+	t.Helper()
+	g := NewWithT(t)
+	t.Setenv("CLOUD_PROXY_BEDROCK_FAKE", "bedrock-fake-key")
+	cp := NewCloudProxy()
+	err := cp.Load(&pb.ModelOptions{
+		Model: "claude-bedrock",
+		Proxy: &pb.ProxyOptions{
+			UpstreamUrl:   upstreamURL,
+			Mode:          modeTranslate,
+			Provider:      providerBedrock,
+			ApiKeyEnv:     "CLOUD_PROXY_BEDROCK_FAKE",
+			UpstreamModel: upstreamModel,
+		},
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+	return cp
+}
+
+// eventStreamFrame encodes one AWS event-stream message with string
+// headers, the wire format of converse-stream.
+func eventStreamFrame(headers [][2]string, payload string) []byte {
+	var hb bytes.Buffer
+	for _, h := range headers {
+		hb.WriteByte(byte(len(h[0])))
+		hb.WriteString(h[0])
+		hb.WriteByte(eventStreamHeaderString)
+		_ = binary.Write(&hb, binary.BigEndian, uint16(len(h[1])))
+		hb.WriteString(h[1])
+	}
+	total := 12 + hb.Len() + len(payload) + 4
+	msg := make([]byte, 0, total)
+	msg = binary.BigEndian.AppendUint32(msg, uint32(total))
+	msg = binary.BigEndian.AppendUint32(msg, uint32(hb.Len()))
+	msg = binary.BigEndian.AppendUint32(msg, crc32.ChecksumIEEE(msg[:8]))
+	msg = append(msg, hb.Bytes()...)
+	msg = append(msg, payload...)
+	return binary.BigEndian.AppendUint32(msg, crc32.ChecksumIEEE(msg))
+}
+
+func bedrockEvent(eventType, payload string) []byte {
+	return eventStreamFrame([][2]string{
+		{":event-type", eventType},
+		{":content-type", "application/json"},
+		{":message-type", "event"},
+	}, payload)
+}
+
+func TestPredict_Bedrock_BasicMessages(t *testing.T) {
+	g := NewWithT(t)
+	srv, captured := fakeBedrockUpstream(t, func(_ bedrockRequest) (int, []byte, string) {
+		return 200, []byte(`{"output":{"message":{"role":"assistant","content":[{"text":"hi "},{"reasoningContent":{"reasoningText":{"text":"hmm"}}},{"text":"there"}]}},"stopReason":"end_turn","usage":{"inputTokens":5,"outputTokens":2,"totalTokens":7}}`), "application/json"
+	})
+	defer srv.Close()
+	cp := newBedrockTranslateCloudProxy(t, srv.URL, "anthropic.claude-3-5-sonnet-20241022-v2:0")
+
+	reply, err := cp.PredictRich(&pb.PredictOptions{
+		Messages: []*pb.Message{
+			{Role: "system", Content: "be brief"},
+			{Role: "user", Content: "hello"},
+		},
+		Temperature: 0.5,
+		Tokens:      32,
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(string(reply.GetMessage())).To(Equal("hi there"))
+	g.Expect(reply.GetPromptTokens()).To(Equal(int32(5)))
+	g.Expect(reply.GetTokens()).To(Equal(int32(2)))
+
+	g.Expect(captured.path).To(Equal("/model/anthropic.claude-3-5-sonnet-20241022-v2:0/converse"))
+	g.Expect(captured.auth).To(Equal("Bearer bedrock-fake-key"))
+	g.Expect(captured.req.System).To(Equal([]bedrockContentBlock{{Text: "be brief"}}))
+	g.Expect(captured.req.Messages).To(HaveLen(1))
+	g.Expect(captured.req.Messages[0].Role).To(Equal("user"))
+	g.Expect(captured.req.Messages[0].Content[0].Text).To(Equal("hello"))
+	g.Expect(captured.req.InferenceConfig).NotTo(BeNil())
+	g.Expect(captured.req.InferenceConfig.MaxTokens).To(Equal(int32(32)))
+	g.Expect(captured.req.ToolConfig).To(BeNil())
+}
+
+func TestPredict_Bedrock_UpstreamError(t *testing.T) {
+	g := NewWithT(t)
+	srv, _ := fakeBedrockUpstream(t, func(_ bedrockRequest) (int, []byte, string) {
+		return 403, []byte(`{"message":"The security token included in the request is invalid."}`), "application/json"
+	})
+	defer srv.Close()
+	cp := newBedrockTranslateCloudProxy(t, srv.URL, "amazon.nova-pro-v1:0")
+
+	_, err := cp.Predict(&pb.PredictOptions{Prompt: "x"})
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.Error()).To(ContainSubstring("403"))
+	g.Expect(err.Error()).To(ContainSubstring("security token"))
+}
+
+func TestPredictRich_Bedrock_ToolUse(t *testing.T) {
+	g := NewWithT(t)
+	srv, _ := fakeBedrockUpstream(t, func(_ bedrockRequest) (int, []byte, string) {
+		return 200, []byte(`{"output":{"message":{"role":"assistant","content":[
+			{"text":"Checking."},
+			{"toolUse":{"toolUseId":"tooluse_1","name":"get_weather","input":{"city":"Paris"}}}
+		]}},"stopReason":"tool_use","usage":{"inputTokens":20,"outputTokens":9}}`), "application/json"
+	})
+	defer srv.Close()
+	cp := newBedrockTranslateCloudProxy(t, srv.URL, "amazon.nova-pro-v1:0")
+
+	reply, err := cp.PredictRich(&pb.PredictOptions{Messages: []*pb.Message{{Role: "user", Content: "weather?"}}})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(string(reply.GetMessage())).To(Equal("Checking."))
+	calls := reply.GetChatDeltas()[0].GetToolCalls()
+	g.Expect(calls).To(HaveLen(1))
+	g.Expect(calls[0].GetIndex()).To(Equal(int32(0)))
+	g.Expect(calls[0].GetId()).To(Equal("tooluse_1"))
+	g.Expect(calls[0].GetName()).To(Equal("get_weather"))
+	g.Expect(calls[0].GetArguments()).To(MatchJSON(`{"city":"Paris"}`))
+	g.Expect(reply.GetPromptTokens()).To(Equal(int32(20)))
+	g.Expect(reply.GetTokens()).To(Equal(int32(9)))
+}
+
+func TestBuildBedrock_RoundTripsToolCalls(t *testing.T) {
+	g := NewWithT(t)
+	body, err := buildBedrockRequest(&pb.PredictOptions{
+		Tools:      `[{"type":"function","function":{"name":"get_weather","description":"Weather by city","parameters":{"type":"object","properties":{"city":{"type":"string"}}}}},{"type":"function","function":{"name":"get_time"}}]`,
+		ToolChoice: `"required"`,
+		Messages: []*pb.Message{
+			{Role: "user", Content: "weather and time in Paris?"},
+			{Role: "assistant", Content: "Let me check.", ToolCalls: `[{"id":"call_a","type":"function","function":{"name":"get_weather","arguments":"{\"city\":\"Paris\"}"}},{"id":"call_b","type":"function","function":{"name":"get_time","arguments":""}}]`},
+			{Role: "tool", ToolCallId: "call_a", Content: `{"temp":21}`},
+			{Role: "tool", ToolCallId: "call_b", Content: "noon"},
+		},
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	var req bedrockRequest
+	g.Expect(json.Unmarshal(body, &req)).To(Succeed())
+
+	g.Expect(req.ToolConfig).NotTo(BeNil())
+	g.Expect(req.ToolConfig.Tools).To(HaveLen(2))
+	g.Expect(req.ToolConfig.Tools[0].ToolSpec.Description).To(Equal("Weather by city"))
+	g.Expect(string(req.ToolConfig.Tools[0].ToolSpec.InputSchema.JSON)).To(MatchJSON(`{"type":"object","properties":{"city":{"type":"string"}}}`))
+	// Converse requires a schema; tools without one get an empty object schema.
+	g.Expect(string(req.ToolConfig.Tools[1].ToolSpec.InputSchema.JSON)).To(MatchJSON(emptyObjectSchema))
+	g.Expect(req.ToolConfig.ToolChoice).NotTo(BeNil())
+	g.Expect(req.ToolConfig.ToolChoice.Any).NotTo(BeNil())
+
+	g.Expect(req.Messages).To(HaveLen(3))
+	assistant := req.Messages[1]
+	g.Expect(assistant.Role).To(Equal("assistant"))
+	g.Expect(assistant.Content).To(HaveLen(3))
+	g.Expect(assistant.Content[0].Text).To(Equal("Let me check."))
+	g.Expect(assistant.Content[1].ToolUse.ToolUseID).To(Equal("call_a"))
+	g.Expect(string(assistant.Content[1].ToolUse.Input)).To(MatchJSON(`{"city":"Paris"}`))
+	g.Expect(string(assistant.Content[2].ToolUse.Input)).To(MatchJSON(`{}`))
+
+	// Parallel results share one user message so roles keep alternating.
+	results := req.Messages[2]
+	g.Expect(results.Role).To(Equal("user"))
+	g.Expect(results.Content).To(HaveLen(2))
+	g.Expect(results.Content[0].ToolResult.ToolUseID).To(Equal("call_a"))
+	g.Expect(results.Content[0].ToolResult.Content[0].Text).To(Equal(`{"temp":21}`))
+	g.Expect(results.Content[1].ToolResult.ToolUseID).To(Equal("call_b"))
+}
+
+func TestBuildBedrock_ToolChoice(t *testing.T) {
+	g := NewWithT(t)
+	tools := `[{"type":"function","function":{"name":"lookup"}}]`
+
+	body, err := buildBedrockRequest(&pb.PredictOptions{Tools: tools, ToolChoice: `{"type":"function","function":{"name":"lookup"}}`})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(string(body)).To(ContainSubstring(`"toolChoice":{"tool":{"name":"lookup"}}`))
+
+	body, err = buildBedrockRequest(&pb.PredictOptions{Tools: tools, ToolChoice: `"auto"`})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(string(body)).To(ContainSubstring(`"toolChoice":{"auto":{}}`))
+
+	// Converse has no "none": the tools are dropped instead.
+	body, err = buildBedrockRequest(&pb.PredictOptions{Tools: tools, ToolChoice: `"none"`})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(string(body)).NotTo(ContainSubstring("toolConfig"))
+}
+
+func TestPredictStreamRich_Bedrock_EventStream(t *testing.T) {
+	var stream []byte
+	for _, ev := range [][2]string{
+		{"messageStart", `{"role":"assistant"}`},
+		{"contentBlockDelta", `{"contentBlockIndex":0,"delta":{"text":"Let me "},"p":"abc"}`},
+		{"contentBlockDelta", `{"contentBlockIndex":0,"delta":{"text":"check."},"p":"abcdef"}`},
+		{"contentBlockStop", `{"contentBlockIndex":0}`},
+		{"contentBlockStart", `{"contentBlockIndex":1,"start":{"toolUse":{"toolUseId":"tooluse_42","name":"lookup"}}}`},
+		{"contentBlockDelta", `{"contentBlockIndex":1,"delta":{"toolUse":{"input":"{\"q\":"}}}`},
+		{"contentBlockDelta", `{"contentBlockIndex":1,"delta":{"toolUse":{"input":"\"rain\"}"}}}`},
+		{"contentBlockStop", `{"contentBlockIndex":1}`},
+		{"messageStop", `{"stopReason":"tool_use"}`},
+		{"metadata", `{"usage":{"inputTokens":11,"outputTokens":7,"totalTokens":18},"metrics":{"latencyMs":120}}`},
+	} {
+		stream = append(stream, bedrockEvent(ev[0], ev[1])...)
+	}
+	srv, captured := fakeBedrockUpstream(t, func(_ bedrockRequest) (int, []byte, string) {
+		return 200, stream, "application/vnd.amazon.eventstream"
+	})
+	defer srv.Close()
+	g := NewWithT(t)
+	// A full converse URL keeps its model id; only the operation is swapped.
+	cp := newBedrockTranslateCloudProxy(t, srv.URL+"/model/us.amazon.nova-pro-v1:0/converse", "")
+
+	results := make(chan *pb.Reply, 16)
+	done := make(chan error, 1)
+	go func() {
+		done <- cp.PredictStreamRich(&pb.PredictOptions{
+			Messages: []*pb.Message{{Role: "user", Content: "rain?"}},
+		}, results)
+		close(results)
+	}()
+
+	var (
+		content          strings.Builder
+		toolID, toolName string
+		toolIndex        int32
+		argsBuf          strings.Builder
+		last             *pb.Reply
+	)
+	for reply := range results {
+		content.Write(reply.GetMessage())
+		for _, cd := range reply.GetChatDeltas() {
+			for _, tc := range cd.GetToolCalls() {
+				if tc.GetId() != "" {
+					toolID = tc.GetId()
+				}
+				if tc.GetName() != "" {
+					toolName = tc.GetName()
+				}
+				toolIndex = tc.GetIndex()
+				argsBuf.WriteString(tc.GetArguments())
+			}
+		}
+		last = reply
+	}
+	g.Expect(<-done).NotTo(HaveOccurred())
+
+	g.Expect(captured.path).To(Equal("/model/us.amazon.nova-pro-v1:0/converse-stream"))
+	g.Expect(content.String()).To(Equal("Let me check."))
+	g.Expect(toolID).To(Equal("tooluse_42"))
+	g.Expect(toolName).To(Equal("lookup"))
+	g.Expect(toolIndex).To(Equal(int32(1)))
+	g.Expect(argsBuf.String()).To(Equal(`{"q":"rain"}`))
+	// Usage arrives in the trailing metadata event, as the last Reply.
+	g.Expect(last.GetPromptTokens()).To(Equal(int32(11)))
+	g.Expect(last.GetTokens()).To(Equal(int32(7)))
+}
+
+func TestPredictStreamRich_Bedrock_Exception(t *testing.T) {
+	stream := append(
+		bedrockEvent("contentBlockDelta", `{"contentBlockIndex":0,"delta":{"text":"par"}}`),
+		eventStreamFrame([][2]string{
+			{":exception-type", "throttlingException"},
+			{":content-type", "application/json"},
+			{":message-type", "exception"},
+		}, `{"message":"Too many requests, please wait before trying again."}`)...,
+	)
+	srv, _ := fakeBedrockUpstream(t, func(_ bedrockRequest) (int, []byte, string) {
+		return 200, stream, "application/vnd.amazon.eventstream"
+	})
+	defer srv.Close()
+	g := NewWithT(t)
+	cp := newBedrockTranslateCloudProxy(t, srv.URL, "amazon.nova-pro-v1:0")
+
+	results := make(chan *pb.Reply, 16)
+	err := cp.PredictStreamRich(&pb.PredictOptions{Prompt: "x"}, results)
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.Error()).To(ContainSubstring("throttlingException"))
+	g.Expect(err.Error()).To(ContainSubstring("Too many requests"))
+	g.Expect(results).To(HaveLen(1))
+}
+
+func TestReadEventStreamMessage_RejectsCorruptFrames(t *testing.T) {
+	g := NewWithT(t)
+	frame := bedrockEvent("contentBlockDelta", `{"contentBlockIndex":0,"delta":{"text":"hi"}}`)
+
+	headers, payload, err := readEventStreamMessage(bytes.NewReader(frame))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(headers).To(HaveKeyWithValue(":event-type", "contentBlockDelta"))
+	g.Expect(string(payload)).To(MatchJSON(`{"contentBlockIndex":0,"delta":{"text":"hi"}}`))
+
+	_, _, err = readEventStreamMessage(bytes.NewReader(nil))
+	g.Expect(err).To(MatchError(io.EOF))
+
+	corrupt := bytes.Clone(frame)
+	corrupt[len(corrupt)-6] ^= 0xff
+	_, _, err = readEventStreamMessage(bytes.NewReader(corrupt))
+	g.Expect(err).To(MatchError(ContainSubstring("checksum mismatch")))
+
+	_, _, err = readEventStreamMessage(bytes.NewReader(frame[:len(frame)-3]))
+	g.Expect(err).To(MatchError(ContainSubstring("read event-stream message")))
+}
+
+func TestBedrockEndpoint(t *testing.T) {
+	g := NewWithT(t)
+	cfg := &proxyConfig{
+		upstreamURL:   "https://bedrock-runtime.us-east-1.amazonaws.com/",
+		upstreamModel: "arn:aws:bedrock:us-east-1:123456789012:inference-profile/us.anthropic.claude-sonnet-4-20250514-v1:0",
+	}
+	u, err := bedrockEndpoint(cfg, true)
+	g.Expect(err).NotTo(HaveOccurred())
+	// ARNs contain '/', which must not split the path.
+	g.Expect(u).To(Equal("https://bedrock-runtime.us-east-1.amazonaws.com/model/arn:aws:bedrock:us-east-1:123456789012:inference-profile%2Fus.anthropic.claude-sonnet-4-20250514-v1:0/converse-stream"))
+
+	cfg.upstreamURL = "https://gateway.example.com/bedrock/model/amazon.nova-lite-v1:0/converse-stream"
+	u, err = bedrockEndpoint(cfg, false)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(u).To(Equal("https://gateway.example.com/bedrock/model/amazon.nova-lite-v1:0/converse"))
+}
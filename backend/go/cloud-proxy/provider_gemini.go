@@ -0,0 +1,439 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	pb "github.com/mudler/LocalAI/pkg/grpc/proto"
+	"github.com/mudler/xlog"
+)
+
+// Google Gemini generateContent wire-format types. Narrowed to what
+// translate mode preserves through the Reply proto: text parts,
+// functionCall/functionResponse parts and usage metadata. Inline data,
+// grounding, safety settings and cached contents are not modelled —
+// passthrough mode covers those.
+//
+// Notable differences from OpenAI:
+//   - The model and the method live in the URL
+//     (models/{model}:generateContent, :streamGenerateContent?alt=sse),
+//     not in the body.
+//   - Roles are user/model only. System messages move to a top-level
+//     systemInstruction; tool results are functionResponse parts of a
+//     user turn, matched to the call by function name.
+//   - functionCall.args and functionResponse.response are JSON objects,
+//     not JSON-encoded strings.
+//   - Streaming chunks are whole GenerateContentResponse objects: text
+//     arrives incrementally, function calls arrive complete in a single
+//     chunk, and usageMetadata carries running totals. The stream has
+//     no terminator — it ends at EOF.
+
+type geminiRequest struct {
+	Contents          []geminiContent         `json:"contents"`
+	SystemInstruction *geminiContent          `json:"systemInstruction,omitempty"`
+	Tools             []geminiTool            `json:"tools,omitempty"`
+	ToolConfig        *geminiToolConfig       `json:"toolConfig,omitempty"`
+	GenerationConfig  *geminiGenerationConfig `json:"generationConfig,omitempty"`
+}
+
+type geminiContent struct {
+	Role  string       `json:"role,omitempty"`
+	Parts []geminiPart `json:"parts"`
+}
+
+// geminiPart is the union of the part kinds we read and write. Thought
+// marks the model's reasoning summary (thinking models), which is not
+// part of the answer.
+type geminiPart struct {
+	Text             string                  `json:"text,omitempty"`
+	Thought          bool                    `json:"thought,omitempty"`
+	FunctionCall     *geminiFunctionCall     `json:"functionCall,omitempty"`
+	FunctionResponse *geminiFunctionResponse `json:"functionResponse,omitempty"`
+}
+
+type geminiFunctionCall struct {
+	ID   string          `json:"id,omitempty"`
+	Name string          `json:"name"`
+	Args json.RawMessage `json:"args,omitempty"`
+}
+
+type geminiFunctionResponse struct {
+	Name     string          `json:"name"`
+	Response json.RawMessage `json:"response"`
+}
+
+type geminiTool struct {
+	FunctionDeclarations []geminiFunctionDeclaration `json:"functionDeclarations"`
+}
+
+// geminiFunctionDeclaration sends the OpenAI parameters schema as
+// parametersJsonSchema, which takes full JSON Schema verbatim. The
+// older `parameters` field only accepts an OpenAPI subset and 400s on
+// common keywords such as additionalProperties.
+type geminiFunctionDeclaration struct {
+	Name                 string          `json:"name"`
+	Description          string          `json:"description,omitempty"`
+	ParametersJSONSchema json.RawMessage `json:"parametersJsonSchema,omitempty"`
+}
+
+type geminiToolConfig struct {
+	FunctionCallingConfig geminiFunctionCallingConfig `json:"functionCallingConfig"`
+}
+
+// geminiFunctionCallingConfig mirrors the modes Gemini accepts: AUTO,
+// ANY (optionally narrowed by allowedFunctionNames) and NONE.
+type geminiFunctionCallingConfig struct {
+	Mode                 string   `json:"mode"`
+	AllowedFunctionNames []string `json:"allowedFunctionNames,omitempty"`
+}
+
+type geminiGenerationConfig struct {
+	MaxOutputTokens int32    `json:"maxOutputTokens,omitempty"`
+	StopSequences   []string `json:"stopSequences,omitempty"`
+}
+
+type geminiResponse struct {
+	Candidates    []geminiCandidate    `json:"candidates"`
+	UsageMetadata *geminiUsageMetadata `json:"usageMetadata,omitempty"`
+}
+
+type geminiCandidate struct {
+	Content      geminiContent `json:"content"`
+	FinishReason string        `json:"finishReason,omitempty"`
+}
+
+// geminiUsageMetadata reports the totals so far. Thinking models bill
+// their reasoning tokens as output but report them separately from
+// candidatesTokenCount.
+type geminiUsageMetadata struct {
+	PromptTokenCount     int `json:"promptTokenCount"`
+	CandidatesTokenCount int `json:"candidatesTokenCount"`
+	ThoughtsTokenCount   int `json:"thoughtsTokenCount"`
+}
+
+func (u *geminiUsageMetadata) completionTokens() int32 {
+	return int32(u.CandidatesTokenCount + u.ThoughtsTokenCount)
+}
+
+func buildGeminiRequest(opts *pb.PredictOptions) ([]byte, error) {
+	req := geminiRequest{}
+	if n, stop := opts.GetTokens(), opts.GetStopPrompts(); n > 0 || len(stop) > 0 {
+		// Sampling parameters are not forwarded, as for the other
+		// providers: clients mostly send server-side defaults.
+		req.GenerationConfig = &geminiGenerationConfig{MaxOutputTokens: max(n, 0), StopSequences: stop}
+	}
+
+	req.Tools = convertOpenAIToolsToGemini(opts.GetTools())
+	if choice := convertOpenAIToolChoice(opts.GetToolChoice()); choice != nil && len(req.Tools) > 0 {
+		cfg := geminiFunctionCallingConfig{Mode: "AUTO"}
+		switch choice.Type {
+		case anthropicToolChoiceNone:
+			cfg.Mode = "NONE"
+		case "any":
+			cfg.Mode = "ANY"
+		case "tool":
+			cfg.Mode = "ANY"
+			cfg.AllowedFunctionNames = []string{choice.Name}
+		}
+		req.ToolConfig = &geminiToolConfig{FunctionCallingConfig: cfg}
+	}
+
+	// functionResponse parts are matched to their call by name, but
+	// OpenAI tool messages only carry the call id — remember the name
+	// of every call the conversation made.
+	callNames := map[string]string{}
+	var systemParts []string
+	for _, m := range opts.GetMessages() {
+		switch m.GetRole() {
+		case "system":
+			if c := m.GetContent(); c != "" {
+				systemParts = append(systemParts, c)
+			}
+		case "user":
+			// An empty text part marshals to {}, which Gemini rejects.
+			if c := m.GetContent(); c != "" {
+				req.Contents = appendGeminiParts(req.Contents, "user", geminiPart{Text: c})
+			}
+		case "assistant":
+			var parts []geminiPart
+			if text := m.GetContent(); text != "" {
+				parts = append(parts, geminiPart{Text: text})
+			}
+			for _, tc := range parseOpenAIToolCalls(m.GetToolCalls()) {
+				callNames[tc.ID] = tc.Function.Name
+				parts = append(parts, geminiPart{FunctionCall: &geminiFunctionCall{
+					Name: tc.Function.Name,
+					Args: jsonObjectOrEmpty(tc.Function.Arguments),
+				}})
+			}
+			if len(parts) == 0 {
+				continue
+			}
+			req.Contents = appendGeminiParts(req.Contents, "model", parts...)
+		case "tool", "function":
+			name := m.GetName()
+			if name == "" {
+				name = callNames[m.GetToolCallId()]
+			}
+			req.Contents = appendGeminiParts(req.Contents, "user", geminiPart{FunctionResponse: &geminiFunctionResponse{
+				Name:     name,
+				Response: toolResultObject(m.GetContent()),
+			}})
+		}
+	}
+	if len(systemParts) > 0 {
+		req.SystemInstruction = &geminiContent{Parts: []geminiPart{{Text: strings.Join(systemParts, "\n\n")}}}
+	}
+	if len(req.Contents) == 0 && opts.GetPrompt() != "" {
+		req.Contents = []geminiContent{{Role: "user", Parts: []geminiPart{{Text: opts.GetPrompt()}}}}
+	}
+
+	return json.Marshal(req)
+}
+
+// appendGeminiParts appends parts as a turn of role, merging into the
+// previous turn when it has the same role. Gemini expects user and model
+// turns to alternate, and parallel tool results must share one user turn.
+func appendGeminiParts(contents []geminiContent, role string, parts ...geminiPart) []geminiContent {
+	if n := len(contents); n > 0 && contents[n-1].Role == role {
+		contents[n-1].Parts = append(contents[n-1].Parts, parts...)
+		return contents
+	}
+	return append(contents, geminiContent{Role: role, Parts: parts})
+}
+
+func convertOpenAIToolsToGemini(toolsJSON string) []geminiTool {
+	if toolsJSON == "" {
+		return nil
+	}
+	var raw []openAITool
+	if err := json.Unmarshal([]byte(toolsJSON), &raw); err != nil {
+		xlog.Warn("cloud-proxy: gemini translate: unparseable tools JSON, dropping", "error", err)
+		return nil
+	}
+	decls := make([]geminiFunctionDeclaration, 0, len(raw))
+	for _, t := range raw {
+		if t.Function.Name == "" {
+			continue
+		}
+		decls = append(decls, geminiFunctionDeclaration{
+			Name:                 t.Function.Name,
+			Description:          t.Function.Description,
+			ParametersJSONSchema: t.Function.Parameters,
+		})
+	}
+	if len(decls) == 0 {
+		return nil
+	}
+	return []geminiTool{{FunctionDeclarations: decls}}
+}
+
+// geminiEndpoint resolves the generateContent URL. upstream_url is
+// either the API base (https://generativelanguage.googleapis.com/v1beta,
+// the model is appended) or a full method URL, whose method is swapped
+// for the streaming one as needed — the latter also covers Vertex AI's
+// projects/.../publishers/google/models/{model} paths.
+func geminiEndpoint(cfg *proxyConfig, stream bool) (string, error) {
+	u, err := url.Parse(cfg.upstreamURL)
+	if err != nil {
+		return "", fmt.Errorf("cloud-proxy: parse upstream_url %q: %w", cfg.upstreamURL, err)
+	}
+	method := "generateContent"
+	if stream {
+		method = "streamGenerateContent"
+	}
+	path := strings.TrimSuffix(u.Path, "/")
+	if base, ok := strings.CutSuffix(path, ":streamGenerateContent"); ok {
+		path = base
+	} else if base, ok := strings.CutSuffix(path, ":generateContent"); ok {
+		path = base
+	} else {
+		path += "/models/" + strings.TrimPrefix(modelName(cfg, nil), "models/")
+	}
+	u.Path, u.RawPath = path+":"+method, ""
+	// alt=sse switches the stream from a JSON array to SSE frames.
+	q := u.Query()
+	if stream {
+		q.Set("alt", "sse")
+	} else {
+		q.Del("alt")
+	}
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// doGeminiRequest is the Gemini counterpart of doOpenAIRequest.
+// applyAuthHeader sets x-goog-api-key when provider is gemini.
+func (c *CloudProxy) doGeminiRequest(ctx context.Context, cfg *proxyConfig, body []byte, stream bool) (*http.Response, error) {
+	endpoint, err := geminiEndpoint(cfg, stream)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("cloud-proxy: build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "*/*")
+	if cfg.apiKey != "" {
+		applyAuthHeader(req, cfg.provider, cfg.apiKey)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("cloud-proxy: upstream request: %w", err)
+	}
+	return resp, nil
+}
+
+// geminiToolCallID returns the upstream call id, or synthesises one:
+// Gemini only sets ids on some models, while OpenAI clients need one to
+// answer the call.
+func geminiToolCallID(fc *geminiFunctionCall, index int) string {
+	if fc.ID != "" {
+		return fc.ID
+	}
+	return fmt.Sprintf("call_%d", index)
+}
+
+// predictGeminiRich returns the full Reply: joined text of the first
+// candidate, functionCall parts mapped to ToolCallDelta, and usage
+// tokens.
+func (c *CloudProxy) predictGeminiRich(ctx context.Context, cfg *proxyConfig, opts *pb.PredictOptions) (*pb.Reply, error) {
+	body, err := buildGeminiRequest(opts)
+	if err != nil {
+		return nil, fmt.Errorf("cloud-proxy: marshal request: %w", err)
+	}
+	resp, err := c.doGeminiRequest(ctx, cfg, body, false)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode >= 400 {
+		errBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+		return nil, fmt.Errorf("cloud-proxy: upstream %d: %s", resp.StatusCode, string(errBody))
+	}
+
+	var parsed geminiResponse
+	if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
+		return nil, fmt.Errorf("cloud-proxy: decode response: %w", err)
+	}
+
+	reply := &pb.Reply{}
+	if parsed.UsageMetadata != nil {
+		reply.PromptTokens = int32(parsed.UsageMetadata.PromptTokenCount)
+		reply.Tokens = parsed.UsageMetadata.completionTokens()
+	}
+	// A prompt blocked by safety filters comes back without candidates;
+	// like Anthropic's empty content, that is an empty answer rather
+	// than an error.
+	if len(parsed.Candidates) == 0 {
+		return reply, nil
+	}
+
+	var content strings.Builder
+	var toolCalls []*pb.ToolCallDelta
+	for _, p := range parsed.Candidates[0].Content.Parts {
+		switch {
+		case p.FunctionCall != nil:
+			idx := len(toolCalls)
+			toolCalls = append(toolCalls, newToolCallDelta(idx, geminiToolCallID(p.FunctionCall, idx), p.FunctionCall.Name, string(p.FunctionCall.Args)))
+		case !p.Thought:
+			content.WriteString(p.Text)
+		}
+	}
+	reply.Message = []byte(content.String())
+	if len(toolCalls) > 0 {
+		reply.ChatDeltas = []*pb.ChatDelta{{ToolCalls: toolCalls}}
+	}
+	return reply, nil
+}
+
+// predictGeminiStreamRich streams Reply chunks from streamGenerateContent
+// (alt=sse). Text parts become content deltas; each functionCall part
+// arrives complete and becomes a single tool-call delta with id, name
+// and arguments. usageMetadata holds running totals, so only the last
+// one is emitted, as a final usage Reply.
+func (c *CloudProxy) predictGeminiStreamRich(ctx context.Context, cfg *proxyConfig, opts *pb.PredictOptions, results chan<- *pb.Reply) error {
+	body, err := buildGeminiRequest(opts)
+	if err != nil {
+		return fmt.Errorf("cloud-proxy: marshal request: %w", err)
+	}
+	resp, err := c.doGeminiRequest(ctx, cfg, body, true)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode >= 400 {
+		errBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+		return fmt.Errorf("cloud-proxy: upstream %d: %s", resp.StatusCode, string(errBody))
+	}
+
+	var (
+		usage   *geminiUsageMetadata
+		toolIdx int
+		scanner = bufio.NewScanner(resp.Body)
+	)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		payload := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if payload == "" {
+			continue
+		}
+		var chunk geminiResponse
+		if err := json.Unmarshal([]byte(payload), &chunk); err != nil {
+			xlog.Debug("cloud-proxy: skip malformed SSE chunk", "error", err)
+			continue
+		}
+		if chunk.UsageMetadata != nil {
+			usage = chunk.UsageMetadata
+		}
+		if len(chunk.Candidates) == 0 {
+			continue
+		}
+		for _, p := range chunk.Candidates[0].Content.Parts {
+			var reply *pb.Reply
+			switch {
+			case p.FunctionCall != nil:
+				reply = &pb.Reply{ChatDeltas: []*pb.ChatDelta{{ToolCalls: []*pb.ToolCallDelta{
+					newToolCallDelta(toolIdx, geminiToolCallID(p.FunctionCall, toolIdx), p.FunctionCall.Name, string(p.FunctionCall.Args)),
+				}}}}
+				toolIdx++
+			case p.Thought || p.Text == "":
+				continue
+			default:
+				reply = &pb.Reply{
+					Message:    []byte(p.Text),
+					ChatDeltas: []*pb.ChatDelta{{Content: p.Text}},
+				}
+			}
+			if !sendReply(ctx, results, reply) {
+				return ctx.Err()
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if usage != nil {
+		if !sendReply(ctx, results, &pb.Reply{
+			PromptTokens: int32(usage.PromptTokenCount),
+			Tokens:       usage.completionTokens(),
+		}) {
+			return ctx.Err()
+		}
+	}
+	return nil
+}
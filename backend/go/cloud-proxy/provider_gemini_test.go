@@ -0,0 +1,281 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	pb "github.com/mudler/LocalAI/pkg/grpc/proto"
+	. "github.com/onsi/gomega"
+)
+
+// geminiCapture records what the fake Gemini upstream received: the
+// translated body plus the URL and auth header, which carry the model,
+// the method and the key.
+type geminiCapture struct {
+	req    geminiRequest
+	path   string
+	query  string
+	apiKey string
+}
+
+func fakeGeminiUpstream(t *testing.T, handler func(req geminiRequest) (status int, body string, contentType string)) (*httptest.Server, *geminiCapture) {
+	t.Helper()
+	captured := &geminiCapture{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(raw, &captured.req)
+		captured.path, captured.query = r.URL.Path, r.URL.RawQuery
+		captured.apiKey = r.Header.Get("x-goog-api-key")
+		status, body, ct := handler(captured.req)
+		w.Header().Set("Content-Type", ct)
+		w.WriteHeader(status)
+		_, _ = io.WriteString(w, body)
+	}))
+	return srv, captured
+}
+
+func newGeminiTranslateCloudProxy(t *testing.T, upstreamURL string) *CloudProxy {
+	t.Helper()
+	g := NewWithT(t)
+	t.Setenv("CLOUD_PROXY_GEMINI_FAKE", "gemini-fake-key")
+	cp := NewCloudProxy()
+	err := cp.Load(&pb.ModelOptions{
+		Model: "gemini-local",
+		Proxy: &pb.ProxyOptions{
+			UpstreamUrl:   upstreamURL,
+			Mode:          modeTranslate,
+			Provider:      providerGemini,
+			ApiKeyEnv:     "CLOUD_PROXY_GEMINI_FAKE",
+			UpstreamModel: "gemini-2.5-flash",
+		},
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+	return cp
+}
+
+func TestPredict_Gemini_BasicMessages(t *testing.T) {
+	g := NewWithT(t)
+	srv, captured := fakeGeminiUpstream(t, func(_ geminiRequest) (int, string, string) {
+		return 200, `{"candidates":[{"content":{"role":"model","parts":[{"text":"thinking...","thought":true},{"text":"hi "},{"text":"there"}]},"finishReason":"STOP"}],"usageMetadata":{"promptTokenCount":5,"candidatesTokenCount":2,"thoughtsTokenCount":3,"totalTokenCount":10}}`, "application/json"
+	})
+	defer srv.Close()
+	cp := newGeminiTranslateCloudProxy(t, srv.URL)
+
+	reply, err := cp.PredictRich(&pb.PredictOptions{
+		Messages: []*pb.Message{
+			{Role: "system", Content: "be brief"},
+			{Role: "user", Content: "hello"},
+		},
+		Temperature: 0.5,
+		Tokens:      32,
+		StopPrompts: []string{"END"},
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+	// Thought parts are the model's reasoning, not the answer.
+	g.Expect(string(reply.GetMessage())).To(Equal("hi there"))
+	// Reasoning tokens are billed as output.
+	g.Expect(reply.GetPromptTokens()).To(Equal(int32(5)))
+	g.Expect(reply.GetTokens()).To(Equal(int32(5)))
+
+	g.Expect(captured.path).To(Equal("/models/gemini-2.5-flash:generateContent"))
+	g.Expect(captured.query).To(BeEmpty())
+	g.Expect(captured.apiKey).To(Equal("gemini-fake-key"))
+	g.Expect(captured.req.SystemInstruction).NotTo(BeNil())
+	g.Expect(captured.req.SystemInstruction.Parts[0].Text).To(Equal("be brief"))
+	g.Expect(captured.req.Contents).To(HaveLen(1))
+	g.Expect(captured.req.Contents[0].Role).To(Equal("user"))
+	g.Expect(captured.req.Contents[0].Parts[0].Text).To(Equal("hello"))
+	g.Expect(captured.req.GenerationConfig).NotTo(BeNil())
+	g.Expect(captured.req.GenerationConfig.MaxOutputTokens).To(Equal(int32(32)))
+	g.Expect(captured.req.GenerationConfig.StopSequences).To(Equal([]string{"END"}))
+}
+
+func TestPredict_Gemini_PromptFallback(t *testing.T) {
+	g := NewWithT(t)
+	srv, captured := fakeGeminiUpstream(t, func(_ geminiRequest) (int, string, string) {
+		return 200, `{"candidates":[{"content":{"parts":[{"text":"ok"}]}}]}`, "application/json"
+	})
+	defer srv.Close()
+	cp := newGeminiTranslateCloudProxy(t, srv.URL)
+
+	got, err := cp.Predict(&pb.PredictOptions{Prompt: "raw prompt"})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(got).To(Equal("ok"))
+	g.Expect(captured.req.Contents).To(HaveLen(1))
+	g.Expect(captured.req.Contents[0].Parts[0].Text).To(Equal("raw prompt"))
+	g.Expect(captured.req.GenerationConfig).To(BeNil())
+}
+
+func TestPredict_Gemini_UpstreamError(t *testing.T) {
+	g := NewWithT(t)
+	srv, _ := fakeGeminiUpstream(t, func(_ geminiRequest) (int, string, string) {
+		return 400, `{"error":{"code":400,"message":"API key not valid","status":"INVALID_ARGUMENT"}}`, "application/json"
+	})
+	defer srv.Close()
+	cp := newGeminiTranslateCloudProxy(t, srv.URL)
+
+	_, err := cp.Predict(&pb.PredictOptions{Messages: []*pb.Message{{Role: "user", Content: "x"}}})
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.Error()).To(ContainSubstring("400"))
+	g.Expect(err.Error()).To(ContainSubstring("API key not valid"))
+}
+
+func TestPredictRich_Gemini_FunctionCall(t *testing.T) {
+	g := NewWithT(t)
+	srv, _ := fakeGeminiUpstream(t, func(_ geminiRequest) (int, string, string) {
+		return 200, `{"candidates":[{"content":{"role":"model","parts":[
+			{"functionCall":{"name":"get_weather","args":{"city":"Paris"}}},
+			{"functionCall":{"id":"fc_2","name":"get_time","args":{}}}
+		]}}],"usageMetadata":{"promptTokenCount":12,"candidatesTokenCount":6}}`, "application/json"
+	})
+	defer srv.Close()
+	cp := newGeminiTranslateCloudProxy(t, srv.URL)
+
+	reply, err := cp.PredictRich(&pb.PredictOptions{Messages: []*pb.Message{{Role: "user", Content: "weather?"}}})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(reply.GetMessage()).To(BeEmpty())
+	g.Expect(reply.GetChatDeltas()).To(HaveLen(1))
+	calls := reply.GetChatDeltas()[0].GetToolCalls()
+	g.Expect(calls).To(HaveLen(2))
+	// Gemini leaves ids out on most models; OpenAI clients need one.
+	g.Expect(calls[0].GetId()).To(Equal("call_0"))
+	g.Expect(calls[0].GetName()).To(Equal("get_weather"))
+	g.Expect(calls[0].GetArguments()).To(MatchJSON(`{"city":"Paris"}`))
+	g.Expect(calls[1].GetIndex()).To(Equal(int32(1)))
+	g.Expect(calls[1].GetId()).To(Equal("fc_2"))
+	g.Expect(reply.GetPromptTokens()).To(Equal(int32(12)))
+	g.Expect(reply.GetTokens()).To(Equal(int32(6)))
+}
+
+func TestBuildGemini_RoundTripsToolCalls(t *testing.T) {
+	g := NewWithT(t)
+	body, err := buildGeminiRequest(&pb.PredictOptions{
+		Tools:      `[{"type":"function","function":{"name":"get_weather","description":"Weather by city","parameters":{"type":"object","properties":{"city":{"type":"string"}},"additionalProperties":false}}},{"type":"function","function":{"name":"get_time"}}]`,
+		ToolChoice: `{"type":"function","function":{"name":"get_weather"}}`,
+		Messages: []*pb.Message{
+			{Role: "user", Content: "weather and time in Paris?"},
+			{Role: "assistant", ToolCalls: `[{"id":"call_a","type":"function","function":{"name":"get_weather","arguments":"{\"city\":\"Paris\"}"}},{"id":"call_b","type":"function","function":{"name":"get_time","arguments":"not json"}}]`},
+			{Role: "tool", ToolCallId: "call_a", Content: `{"temp":21}`},
+			{Role: "tool", ToolCallId: "call_b", Content: "noon"},
+		},
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	var req geminiRequest
+	g.Expect(json.Unmarshal(body, &req)).To(Succeed())
+
+	g.Expect(req.Tools).To(HaveLen(1))
+	decls := req.Tools[0].FunctionDeclarations
+	g.Expect(decls).To(HaveLen(2))
+	g.Expect(decls[0].Description).To(Equal("Weather by city"))
+	// Full JSON Schema goes through verbatim (parametersJsonSchema).
+	g.Expect(string(decls[0].ParametersJSONSchema)).To(ContainSubstring(`"additionalProperties":false`))
+	g.Expect(decls[1].ParametersJSONSchema).To(BeNil())
+	g.Expect(req.ToolConfig).NotTo(BeNil())
+	g.Expect(req.ToolConfig.FunctionCallingConfig.Mode).To(Equal("ANY"))
+	g.Expect(req.ToolConfig.FunctionCallingConfig.AllowedFunctionNames).To(Equal([]string{"get_weather"}))
+
+	g.Expect(req.Contents).To(HaveLen(3))
+	model := req.Contents[1]
+	g.Expect(model.Role).To(Equal("model"))
+	g.Expect(model.Parts).To(HaveLen(2))
+	g.Expect(model.Parts[0].FunctionCall.Name).To(Equal("get_weather"))
+	g.Expect(string(model.Parts[0].FunctionCall.Args)).To(MatchJSON(`{"city":"Paris"}`))
+	// Malformed arguments must not 400 the whole request.
+	g.Expect(string(model.Parts[1].FunctionCall.Args)).To(MatchJSON(`{}`))
+
+	// Parallel results share one user turn and are matched by name,
+	// resolved from the call id.
+	results := req.Contents[2]
+	g.Expect(results.Role).To(Equal("user"))
+	g.Expect(results.Parts).To(HaveLen(2))
+	g.Expect(results.Parts[0].FunctionResponse.Name).To(Equal("get_weather"))
+	g.Expect(string(results.Parts[0].FunctionResponse.Response)).To(MatchJSON(`{"temp":21}`))
+	g.Expect(results.Parts[1].FunctionResponse.Name).To(Equal("get_time"))
+	g.Expect(string(results.Parts[1].FunctionResponse.Response)).To(MatchJSON(`{"output":"noon"}`))
+}
+
+func TestBuildGemini_ToolChoice(t *testing.T) {
+	g := NewWithT(t)
+	tools := `[{"type":"function","function":{"name":"lookup"}}]`
+	for choice, mode := range map[string]string{`"auto"`: "AUTO", `"required"`: "ANY", `"none"`: "NONE"} {
+		body, err := buildGeminiRequest(&pb.PredictOptions{Tools: tools, ToolChoice: choice})
+		g.Expect(err).NotTo(HaveOccurred())
+		var req geminiRequest
+		g.Expect(json.Unmarshal(body, &req)).To(Succeed())
+		g.Expect(req.ToolConfig.FunctionCallingConfig.Mode).To(Equal(mode), choice)
+	}
+
+	// tool_choice without tools is dropped rather than sent dangling.
+	body, err := buildGeminiRequest(&pb.PredictOptions{ToolChoice: `"required"`})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(string(body)).NotTo(ContainSubstring("toolConfig"))
+}
+
+func TestPredictStreamRich_Gemini_StreamsTextAndFunctionCalls(t *testing.T) {
+	frames := []string{
+		`data: {"candidates":[{"content":{"role":"model","parts":[{"text":"Let me "}]}}],"usageMetadata":{"promptTokenCount":9,"candidatesTokenCount":2}}` + "\n\n",
+		`data: {"candidates":[{"content":{"role":"model","parts":[{"text":"check.","thought":true},{"text":"check."}]}}],"usageMetadata":{"promptTokenCount":9,"candidatesTokenCount":3}}` + "\n\n",
+		"data: {not json}\n\n",
+		`data: {"candidates":[{"content":{"role":"model","parts":[{"functionCall":{"name":"lookup","args":{"q":"rain"}}}]},"finishReason":"STOP"}],"usageMetadata":{"promptTokenCount":9,"candidatesTokenCount":8,"thoughtsTokenCount":4}}` + "\n\n",
+	}
+	srv, captured := fakeGeminiUpstream(t, func(_ geminiRequest) (int, string, string) {
+		return 200, strings.Join(frames, ""), "text/event-stream"
+	})
+	defer srv.Close()
+	g := NewWithT(t)
+	cp := newGeminiTranslateCloudProxy(t, srv.URL+"/v1beta/models/gemini-2.5-pro:generateContent")
+
+	results := make(chan *pb.Reply, 16)
+	done := make(chan error, 1)
+	go func() {
+		done <- cp.PredictStreamRich(&pb.PredictOptions{
+			Messages: []*pb.Message{{Role: "user", Content: "rain?"}},
+		}, results)
+		close(results)
+	}()
+
+	var (
+		content strings.Builder
+		calls   []*pb.ToolCallDelta
+		last    *pb.Reply
+	)
+	for reply := range results {
+		content.Write(reply.GetMessage())
+		for _, cd := range reply.GetChatDeltas() {
+			calls = append(calls, cd.GetToolCalls()...)
+		}
+		last = reply
+	}
+	g.Expect(<-done).NotTo(HaveOccurred())
+
+	// A full method URL keeps its model; only the method is swapped.
+	g.Expect(captured.path).To(Equal("/v1beta/models/gemini-2.5-pro:streamGenerateContent"))
+	g.Expect(captured.query).To(Equal("alt=sse"))
+	g.Expect(content.String()).To(Equal("Let me check."))
+	g.Expect(calls).To(HaveLen(1))
+	g.Expect(calls[0].GetId()).To(Equal("call_0"))
+	g.Expect(calls[0].GetName()).To(Equal("lookup"))
+	g.Expect(calls[0].GetArguments()).To(MatchJSON(`{"q":"rain"}`))
+	// usageMetadata is cumulative: only the final totals are reported,
+	// in the last Reply the core reads usage from.
+	g.Expect(last.GetPromptTokens()).To(Equal(int32(9)))
+	g.Expect(last.GetTokens()).To(Equal(int32(12)))
+}
+
+func TestGeminiEndpoint(t *testing.T) {
+	g := NewWithT(t)
+	cfg := &proxyConfig{upstreamURL: "https://generativelanguage.googleapis.com/v1beta/", localModel: "models/gemini-2.0-flash"}
+	u, err := geminiEndpoint(cfg, false)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(u).To(Equal("https://generativelanguage.googleapis.com/v1beta/models/gemini-2.0-flash:generateContent"))
+
+	cfg.upstreamURL = "https://europe-west4-aiplatform.googleapis.com/v1/projects/p/locations/europe-west4/publishers/google/models/gemini-2.5-flash:streamGenerateContent?alt=sse"
+	u, err = geminiEndpoint(cfg, false)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(u).To(Equal("https://europe-west4-aiplatform.googleapis.com/v1/projects/p/locations/europe-west4/publishers/google/models/gemini-2.5-flash:generateContent"))
+}
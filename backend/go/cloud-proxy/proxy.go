@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -27,6 +28,8 @@ const (
 
 	providerOpenAI    = "openai"
 	providerAnthropic = "anthropic"
+	providerGemini    = "gemini"
+	providerBedrock   = "bedrock"
 )
 
 // CloudProxy is the LocalAI backend that proxies model traffic to a
@@ -87,9 +90,13 @@ func (c *CloudProxy) Load(opts *pb.ModelOptions) error {
 			// implemented in provider_openai.go
 		case providerAnthropic:
 			// implemented in provider_anthropic.go
+		case providerGemini:
+			// implemented in provider_gemini.go
+		case providerBedrock:
+			// implemented in provider_bedrock.go
 		default:
-			return fmt.Errorf("cloud-proxy: translate mode requires provider in {%s, %s}, got %q",
-				providerOpenAI, providerAnthropic, po.GetProvider())
+			return fmt.Errorf("cloud-proxy: translate mode requires provider in {%s, %s, %s, %s}, got %q",
+				providerOpenAI, providerAnthropic, providerGemini, providerBedrock, po.GetProvider())
 		}
 	default:
 		return fmt.Errorf("cloud-proxy: unknown mode %q", mode)
@@ -165,6 +172,10 @@ func (c *CloudProxy) PredictRich(opts *pb.PredictOptions) (reply *pb.Reply, err
 		return c.predictOpenAIRich(ctx, cfg, opts)
 	case providerAnthropic:
 		return c.predictAnthropicRich(ctx, cfg, opts)
+	case providerGemini:
+		return c.predictGeminiRich(ctx, cfg, opts)
+	case providerBedrock:
+		return c.predictBedrockRich(ctx, cfg, opts)
 	default:
 		return nil, fmt.Errorf("cloud-proxy: predict not implemented for provider %q", cfg.provider)
 	}
@@ -195,6 +206,10 @@ func (c *CloudProxy) PredictStreamRich(opts *pb.PredictOptions, results chan<- *
 		return c.predictOpenAIStreamRich(ctx, cfg, opts, results)
 	case providerAnthropic:
 		return c.predictAnthropicStreamRich(ctx, cfg, opts, results)
+	case providerGemini:
+		return c.predictGeminiStreamRich(ctx, cfg, opts, results)
+	case providerBedrock:
+		return c.predictBedrockStreamRich(ctx, cfg, opts, results)
 	default:
 		return fmt.Errorf("cloud-proxy: predictStream not implemented for provider %q", cfg.provider)
 	}
@@ -235,7 +250,7 @@ func (c *CloudProxy) PredictStream(opts *pb.PredictOptions, results chan string)
 
 // sendReply pushes one Reply onto a stream channel honouring ctx
 // cancellation. Returns false on cancel so the caller can exit with
-// ctx.Err(). Used by both translate-mode providers.
+// ctx.Err(). Used by every translate-mode provider.
 func sendReply(ctx context.Context, results chan<- *pb.Reply, reply *pb.Reply) bool {
 	select {
 	case results <- reply:
@@ -247,7 +262,7 @@ func sendReply(ctx context.Context, results chan<- *pb.Reply, reply *pb.Reply) b
 
 // newToolCallDelta is a small constructor for the cross-provider
 // tool-call delta shape. Centralised so the int32 cast and the four
-// fields stay consistent across the OpenAI / Anthropic translators.
+// fields stay consistent across the translate-mode providers.
 // Empty name/args are valid — Anthropic streaming announces the call
 // with id+name then sends arguments incrementally; OpenAI's reverse
 // pattern (args without name) also lands here.
@@ -260,6 +275,45 @@ func newToolCallDelta(index int, id, name, args string) *pb.ToolCallDelta {
 	}
 }
 
+// parseOpenAIToolCalls decodes the OpenAI-shaped tool_calls JSON of a
+// prior assistant turn. Malformed input yields no calls, matching how
+// the OpenAI translator treats it.
+func parseOpenAIToolCalls(toolCallsJSON string) []openAIToolCall {
+	if toolCallsJSON == "" {
+		return nil
+	}
+	var toolCalls []openAIToolCall
+	if err := json.Unmarshal([]byte(toolCallsJSON), &toolCalls); err != nil {
+		return nil
+	}
+	return toolCalls
+}
+
+// jsonObjectOrEmpty returns s as raw JSON when it is a JSON object, else
+// an empty object. Gemini and Bedrock take tool-call arguments as an
+// object and reject the whole request over a malformed one, which
+// poorly-formed local model output would otherwise cause.
+func jsonObjectOrEmpty(s string) json.RawMessage {
+	var probe map[string]json.RawMessage
+	if json.Unmarshal([]byte(s), &probe) != nil || probe == nil {
+		return emptyJSONObject
+	}
+	return json.RawMessage(s)
+}
+
+// toolResultObject wraps a tool message's content in the JSON object
+// Gemini requires as a function response. Content that already is a
+// JSON object is passed as-is; anything else becomes {"output": ...},
+// the key Gemini documents for function output.
+func toolResultObject(content string) json.RawMessage {
+	var probe map[string]json.RawMessage
+	if json.Unmarshal([]byte(content), &probe) == nil && probe != nil {
+		return json.RawMessage(content)
+	}
+	b, _ := json.Marshal(map[string]string{"output": content})
+	return b
+}
+
 // Forward shovels bytes between a Forward gRPC stream and an upstream
 // HTTP request. First request message carries path/method/headers and
 // the initial body chunk; subsequent messages append body chunks. The
@@ -410,7 +464,10 @@ func composeURL(upstream, reqPath string) (string, error) {
 // applyAuthHeader writes the appropriate authorization header for the
 // provider. OpenAI/Anthropic/most providers use Bearer; Anthropic
 // historically uses x-api-key + anthropic-version, but accepts Bearer
-// too via the OpenAI-compatible path. Default to Bearer when provider
+// too via the OpenAI-compatible path. Gemini's native API takes
+// x-goog-api-key; Bedrock API keys are plain Bearer tokens (SigV4
+// signing is not supported — front the endpoint with a signing gateway
+// if IAM credentials are required). Default to Bearer when provider
 // is empty (passthrough mode where the operator doesn't claim a
 // provider).
 func applyAuthHeader(req *http.Request, provider, key string) {
@@ -420,6 +477,8 @@ func applyAuthHeader(req *http.Request, provider, key string) {
 		if req.Header.Get("anthropic-version") == "" {
 			req.Header.Set("anthropic-version", "2023-06-01")
 		}
+	case providerGemini:
+		req.Header.Set("x-goog-api-key", key)
 	default:
 		req.Header.Set("Authorization", "Bearer "+key)
 	}
@@ -176,6 +176,24 @@ func TestLoad_ValidatesConfig(t *testing.T) {
 	}})
 	g.Expect(err).NotTo(HaveOccurred())
 
+	// translate + gemini / bedrock take the API base as upstream_url.
+	for _, provider := range []string{providerGemini, providerBedrock} {
+		err = cp.Load(&pb.ModelOptions{Proxy: &pb.ProxyOptions{
+			UpstreamUrl: "https://example.com",
+			Mode:        modeTranslate,
+			Provider:    provider,
+		}})
+		g.Expect(err).NotTo(HaveOccurred())
+	}
+
+	err = cp.Load(&pb.ModelOptions{Proxy: &pb.ProxyOptions{
+		UpstreamUrl: "https://example.com",
+		Mode:        modeTranslate,
+		Provider:    "cohere",
+	}})
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.Error()).To(ContainSubstring("gemini"))
+
 	err = cp.Load(&pb.ModelOptions{Proxy: &pb.ProxyOptions{
 		UpstreamUrl: "https://example.com",
 		ApiKeyEnv:   "DEFINITELY_UNSET_ENV_VAR_XYZ",
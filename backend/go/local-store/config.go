@@ -0,0 +1,199 @@
+package main
+
+// Index + persistence configuration for local-store.
+//
+// Like valkey-store, configuration is read from the model config `options:`
+// list (repeated `key:value` strings carried over gRPC in
+// ModelOptions.Options), so every store namespace can pick its own index.
+// Nothing is required: with no options the store keeps its historical
+// behaviour — exact cosine search over an in-memory slice, lost on restart.
+//
+// Example model YAML:
+//
+//	name: my-vector-store
+//	backend: local-store
+//	options:
+//	  - index:hnsw
+//	  - hnsw_m:16
+//	  - hnsw_ef_search:64
+//	  - persist_path:stores/my-vector-store.idx
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	pb "github.com/mudler/LocalAI/pkg/grpc/proto"
+	"github.com/mudler/xlog"
+)
+
+const (
+	// _defaultIndex is the exact linear scan, so a store without options
+	// returns the same (exact) results it always did.
+	_defaultIndex = indexExact
+
+	// HNSW graph defaults (only used when index=hnsw). M and efConstruction
+	// follow the values of the original paper and most libraries; efSearch
+	// trades latency for recall at query time and can be raised freely.
+	_defaultHNSWM              = 16
+	_defaultHNSWEFConstruction = 200
+	_defaultHNSWEFSearch       = 64
+
+	// _defaultANNMinEntries keeps small stores on the exact scan even when
+	// an ANN index is configured: below a few thousand entries the linear
+	// scan is both exact and about as fast as walking the graph.
+	_defaultANNMinEntries = 1000
+
+	// _defaultRecallSampleEvery re-runs one ANN query in this many with the
+	// exact scan to estimate recall. 0 disables sampling.
+	_defaultRecallSampleEvery = 100
+
+	// _defaultPersistIntervalMS is how often a dirty store is written back
+	// to persist_path. The store is also written on a graceful shutdown.
+	_defaultPersistIntervalMS = 10000
+
+	// Supported index types.
+	indexExact = "exact"
+	indexHNSW  = "hnsw"
+
+	// Option keys recognised in the model config `options:` list.
+	optIndex              = "index"
+	optHNSWM              = "hnsw_m"
+	optHNSWEFConstruction = "hnsw_ef_construction"
+	optHNSWEFSearch       = "hnsw_ef_search"
+	optANNMinEntries      = "ann_min_entries"
+	optRecallSampleEvery  = "recall_sample_every"
+	optPersistPath        = "persist_path"
+	optPersistIntervalMS  = "persist_interval_ms"
+)
+
+// hnswParams holds the HNSW tuning knobs. They are ignored unless
+// Index == indexHNSW.
+type hnswParams struct {
+	M              int
+	EFConstruction int
+	EFSearch       int
+}
+
+// Config is the fully-resolved store configuration produced by loadConfig().
+type Config struct {
+	Index             string
+	HNSW              hnswParams
+	ANNMinEntries     int
+	RecallSampleEvery int
+	// PersistPath is empty when the store is memory-only. A relative path
+	// is resolved against the model path LocalAI sends on Load.
+	PersistPath     string
+	PersistInterval time.Duration
+}
+
+// defaultConfig is the configuration of a store loaded without options.
+func defaultConfig() Config {
+	return Config{
+		Index: _defaultIndex,
+		HNSW: hnswParams{
+			M:              _defaultHNSWM,
+			EFConstruction: _defaultHNSWEFConstruction,
+			EFSearch:       _defaultHNSWEFSearch,
+		},
+		ANNMinEntries:     _defaultANNMinEntries,
+		RecallSampleEvery: _defaultRecallSampleEvery,
+		PersistInterval:   time.Duration(_defaultPersistIntervalMS) * time.Millisecond,
+	}
+}
+
+// parseOptions turns the repeated `key:value` ModelOptions.Options list into a
+// lookup map, splitting on the FIRST ':' so values such as Windows paths keep
+// their colons. Malformed entries are warned about and skipped.
+func parseOptions(opts *pb.ModelOptions) map[string]string {
+	m := make(map[string]string)
+	if opts == nil {
+		return m
+	}
+	for _, o := range opts.GetOptions() {
+		k, v, ok := strings.Cut(o, ":")
+		if !ok {
+			xlog.Warn("local-store: ignoring malformed option (want key:value)", "option", o)
+			continue
+		}
+		m[strings.ToLower(strings.TrimSpace(k))] = strings.TrimSpace(v)
+	}
+	return m
+}
+
+// loadConfig resolves the store configuration from the model config options.
+// It fails fast on an unknown index type or a malformed/out-of-range number
+// so a misconfiguration surfaces at Load() rather than as poor recall.
+func loadConfig(opts *pb.ModelOptions) (Config, error) {
+	o := parseOptions(opts)
+	def := defaultConfig()
+
+	// The first parse error wins and is returned below.
+	var parseErr error
+	intOr := func(key string, fallback int) int {
+		v, ok := o[key]
+		if !ok || v == "" {
+			return fallback
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			if parseErr == nil {
+				parseErr = fmt.Errorf("local-store: invalid option %s %q: %w", key, v, err)
+			}
+			return fallback
+		}
+		return n
+	}
+
+	cfg := Config{
+		Index: strings.ToLower(strOr(o, optIndex, def.Index)),
+		HNSW: hnswParams{
+			M:              intOr(optHNSWM, def.HNSW.M),
+			EFConstruction: intOr(optHNSWEFConstruction, def.HNSW.EFConstruction),
+			EFSearch:       intOr(optHNSWEFSearch, def.HNSW.EFSearch),
+		},
+		ANNMinEntries:     intOr(optANNMinEntries, def.ANNMinEntries),
+		RecallSampleEvery: intOr(optRecallSampleEvery, def.RecallSampleEvery),
+		PersistPath:       o[optPersistPath],
+		PersistInterval:   time.Duration(intOr(optPersistIntervalMS, _defaultPersistIntervalMS)) * time.Millisecond,
+	}
+	if parseErr != nil {
+		return Config{}, parseErr
+	}
+
+	switch cfg.Index {
+	case indexExact, indexHNSW:
+	default:
+		return Config{}, fmt.Errorf("local-store: invalid option %s %q (want exact or hnsw)", optIndex, cfg.Index)
+	}
+	if cfg.HNSW.M < 2 {
+		return Config{}, fmt.Errorf("local-store: invalid option %s %d (must be >= 2)", optHNSWM, cfg.HNSW.M)
+	}
+	if cfg.HNSW.EFConstruction < 1 {
+		return Config{}, fmt.Errorf("local-store: invalid option %s %d (must be >= 1)", optHNSWEFConstruction, cfg.HNSW.EFConstruction)
+	}
+	if cfg.HNSW.EFSearch < 1 {
+		return Config{}, fmt.Errorf("local-store: invalid option %s %d (must be >= 1)", optHNSWEFSearch, cfg.HNSW.EFSearch)
+	}
+	if cfg.ANNMinEntries < 0 || cfg.RecallSampleEvery < 0 {
+		return Config{}, fmt.Errorf("local-store: %s and %s must be >= 0", optANNMinEntries, optRecallSampleEvery)
+	}
+	if cfg.PersistInterval <= 0 {
+		cfg.PersistInterval = def.PersistInterval
+	}
+	if cfg.PersistPath != "" && !filepath.IsAbs(cfg.PersistPath) && opts.GetModelPath() != "" {
+		cfg.PersistPath = filepath.Join(opts.GetModelPath(), cfg.PersistPath)
+	}
+
+	return cfg, nil
+}
+
+// strOr returns the option value for key, or fallback when it is unset/empty.
+func strOr(o map[string]string, key, fallback string) string {
+	if v, ok := o[key]; ok && v != "" {
+		return v
+	}
+	return fallback
+}
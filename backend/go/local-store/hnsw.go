@@ -0,0 +1,366 @@
+package main
+
+// Hierarchical Navigable Small World graph (Malkov & Yashunin, 2016) used as
+// the optional approximate index of a Store.
+//
+// The graph only holds vectors: values stay in the Store's sorted slices
+// and are looked up by key once the graph has produced candidates, so an
+// upsert that only changes a value never touches the graph. Distances are
+// cosine distances over unit-normalised copies of the keys; similarities
+// returned to callers are recomputed from the original keys so they are on
+// the same scale as the exact scan.
+//
+// Deletion is a tombstone: the node keeps its links (so the graph stays
+// navigable) but is never returned. When tombstones outnumber live nodes
+// the graph is rebuilt from the live keys.
+
+import (
+	"cmp"
+	"container/heap"
+	"encoding/binary"
+	"math"
+	"math/rand/v2"
+	"slices"
+)
+
+// _hnswMaxLevel caps the random level so a pathological draw cannot build an
+// absurdly tall graph. With M=16, level 16 needs ~10^19 nodes.
+const _hnswMaxLevel = 16
+
+type hnsw struct {
+	params    hnswParams
+	levelMult float64
+	rng       *rand.Rand
+
+	// Per node, indexed by node id.
+	vecs    [][]float32 // unit-normalised key, used for distances
+	keys    [][]float32 // original key, used to find the value in the Store
+	links   [][][]int32 // links[node][level]
+	deleted []bool
+
+	ids       map[string]int32 // keyID -> live node
+	entry     int32            // -1 when the graph is empty
+	maxLevel  int
+	tombstone int
+
+	// visited marks nodes seen by the current search; a node is visited
+	// when visited[n] == visitGen, so clearing is a counter bump.
+	visited  []uint32
+	visitGen uint32
+}
+
+func newHNSW(params hnswParams) *hnsw {
+	h := &hnsw{params: params, levelMult: 1 / math.Log(float64(params.M))}
+	h.reset()
+	return h
+}
+
+func (h *hnsw) reset() {
+	h.rng = rand.New(rand.NewPCG(uint64(h.params.M), uint64(h.params.EFConstruction)))
+	h.vecs, h.keys, h.links, h.deleted = nil, nil, nil, nil
+	h.ids = make(map[string]int32)
+	h.entry = -1
+	h.maxLevel = 0
+	h.tombstone = 0
+	h.visited = nil
+}
+
+// size is the number of live (searchable) nodes.
+func (h *hnsw) size() int { return len(h.ids) }
+
+// insert adds key to the graph. Keys already present are left alone: Set is
+// an upsert and the vector of an existing key cannot change.
+func (h *hnsw) insert(key []float32) {
+	id := keyID(key)
+	if _, ok := h.ids[id]; ok {
+		return
+	}
+	vec := unitCopy(key)
+	level := h.randomLevel()
+	n := int32(len(h.vecs))
+	h.vecs = append(h.vecs, vec)
+	h.keys = append(h.keys, key)
+	h.links = append(h.links, make([][]int32, level+1))
+	h.deleted = append(h.deleted, false)
+	h.ids[id] = n
+
+	if h.entry < 0 {
+		h.entry, h.maxLevel = n, level
+		return
+	}
+
+	ep := h.entry
+	for l := h.maxLevel; l > level; l-- {
+		ep = h.searchLayer(vec, []int32{ep}, 1, l)[0].id
+	}
+	eps := []int32{ep}
+	for l := min(level, h.maxLevel); l >= 0; l-- {
+		cands := h.searchLayer(vec, eps, h.params.EFConstruction, l)
+		neighbors := h.selectNeighbors(cands, h.params.M)
+		h.links[n][l] = neighbors
+		for _, nb := range neighbors {
+			h.connect(nb, n, l)
+		}
+		eps = eps[:0]
+		for _, c := range cands {
+			eps = append(eps, c.id)
+		}
+	}
+	if level > h.maxLevel {
+		h.entry, h.maxLevel = n, level
+	}
+}
+
+// remove tombstones key. It reports whether the key was in the graph.
+func (h *hnsw) remove(key []float32) bool {
+	id := keyID(key)
+	n, ok := h.ids[id]
+	if !ok {
+		return false
+	}
+	delete(h.ids, id)
+	h.deleted[n] = true
+	h.tombstone++
+	switch {
+	case len(h.ids) == 0:
+		h.reset()
+	case h.tombstone > len(h.ids):
+		h.rebuild()
+	}
+	return true
+}
+
+// rebuild drops the tombstones by re-inserting every live key into a fresh
+// graph.
+func (h *hnsw) rebuild() {
+	live := make([][]float32, 0, len(h.ids))
+	for n, k := range h.keys {
+		if !h.deleted[n] {
+			live = append(live, k)
+		}
+	}
+	h.reset()
+	for _, k := range live {
+		h.insert(k)
+	}
+}
+
+// search returns the keys of (approximately) the k nearest live nodes,
+// nearest first. A non-nil accept restricts the result to the keys it
+// accepts; the beam is widened until k of them are found or the whole graph
+// was visited, so selective filters cost up to an exact scan.
+func (h *hnsw) search(query []float32, k int, accept func([]float32) bool) [][]float32 {
+	if h.entry < 0 {
+		return nil
+	}
+	q := unitCopy(query)
+	ep := h.entry
+	for l := h.maxLevel; l > 0; l-- {
+		ep = h.searchLayer(q, []int32{ep}, 1, l)[0].id
+	}
+	want := min(k, len(h.ids))
+	ef := max(h.params.EFSearch, k)
+	for {
+		var out [][]float32
+		for _, c := range h.searchLayer(q, []int32{ep}, ef, 0) {
+			if !h.deleted[c.id] && (accept == nil || accept(h.keys[c.id])) {
+				out = append(out, h.keys[c.id])
+				if len(out) == k {
+					break
+				}
+			}
+		}
+		// Tombstones and filtered-out nodes can crowd accepted nodes out
+		// of the candidate list; widen the beam until enough are found.
+		if len(out) >= want || ef >= len(h.vecs) {
+			return out
+		}
+		ef *= 2
+	}
+}
+
+// candidate is a node and its distance to the current query.
+type candidate struct {
+	id   int32
+	dist float32
+}
+
+// searchLayer is the beam search of the paper (Algorithm 2): it returns up to
+// ef nodes of level l closest to q, nearest first, deleted nodes included.
+func (h *hnsw) searchLayer(q []float32, eps []int32, ef, l int) []candidate {
+	h.nextVisit()
+	var cands minCandidates
+	var found maxCandidates
+	for _, ep := range eps {
+		h.visited[ep] = h.visitGen
+		c := candidate{id: ep, dist: h.dist(q, ep)}
+		heap.Push(&cands, c)
+		heap.Push(&found, c)
+	}
+	for cands.Len() > 0 {
+		c := heap.Pop(&cands).(candidate)
+		if found.Len() >= ef && c.dist > found[0].dist {
+			break
+		}
+		if l >= len(h.links[c.id]) {
+			continue
+		}
+		for _, nb := range h.links[c.id][l] {
+			if h.visited[nb] == h.visitGen {
+				continue
+			}
+			h.visited[nb] = h.visitGen
+			d := h.dist(q, nb)
+			if found.Len() < ef || d < found[0].dist {
+				heap.Push(&cands, candidate{id: nb, dist: d})
+				heap.Push(&found, candidate{id: nb, dist: d})
+				if found.Len() > ef {
+					heap.Pop(&found)
+				}
+			}
+		}
+	}
+	out := make([]candidate, found.Len())
+	for i := len(out) - 1; i >= 0; i-- {
+		out[i] = heap.Pop(&found).(candidate)
+	}
+	return out
+}
+
+// selectNeighbors is the neighbour selection heuristic of the paper
+// (Algorithm 4, keeping pruned connections): a candidate is preferred when
+// it is closer to the base than to every neighbour already picked, which
+// keeps links spread across clusters. cands must be sorted nearest first.
+func (h *hnsw) selectNeighbors(cands []candidate, m int) []int32 {
+	out := make([]int32, 0, m)
+	var pruned []int32
+	for _, c := range cands {
+		if len(out) == m {
+			break
+		}
+		good := true
+		for _, r := range out {
+			if h.dist(h.vecs[c.id], r) < c.dist {
+				good = false
+				break
+			}
+		}
+		if good {
+			out = append(out, c.id)
+		} else {
+			pruned = append(pruned, c.id)
+		}
+	}
+	for _, p := range pruned {
+		if len(out) == m {
+			break
+		}
+		out = append(out, p)
+	}
+	return out
+}
+
+// connect adds a link from -> to on level l, shrinking the neighbour list
+// of from when it overflows.
+func (h *hnsw) connect(from, to int32, l int) {
+	links := append(h.links[from][l], to)
+	maxConn := h.params.M
+	if l == 0 {
+		maxConn = 2 * h.params.M
+	}
+	if len(links) > maxConn {
+		cands := make([]candidate, len(links))
+		for i, nb := range links {
+			cands[i] = candidate{id: nb, dist: h.dist(h.vecs[from], nb)}
+		}
+		sortCandidates(cands)
+		links = h.selectNeighbors(cands, maxConn)
+	}
+	h.links[from][l] = links
+}
+
+func (h *hnsw) randomLevel() int {
+	level := int(-math.Log(1-h.rng.Float64()) * h.levelMult)
+	return min(level, _hnswMaxLevel)
+}
+
+func (h *hnsw) dist(q []float32, n int32) float32 {
+	v := h.vecs[n]
+	var dot float32
+	for i := range v {
+		dot += q[i] * v[i]
+	}
+	return 1 - dot
+}
+
+func (h *hnsw) nextVisit() {
+	if len(h.visited) < len(h.vecs) {
+		h.visited = append(h.visited, make([]uint32, len(h.vecs)-len(h.visited))...)
+	}
+	h.visitGen++
+	if h.visitGen == 0 {
+		clear(h.visited)
+		h.visitGen = 1
+	}
+}
+
+// keyID is the map key of a vector: its exact float bits.
+func keyID(k []float32) string {
+	b := make([]byte, 4*len(k))
+	for i, v := range k {
+		binary.LittleEndian.PutUint32(b[4*i:], math.Float32bits(v))
+	}
+	return string(b)
+}
+
+// unitCopy returns k scaled to unit length. A zero vector is returned as is.
+func unitCopy(k []float32) []float32 {
+	var sum float64
+	for _, v := range k {
+		sum += float64(v) * float64(v)
+	}
+	out := make([]float32, len(k))
+	if sum == 0 {
+		return out
+	}
+	inv := 1 / math.Sqrt(sum)
+	for i, v := range k {
+		out[i] = float32(float64(v) * inv)
+	}
+	return out
+}
+
+func sortCandidates(cands []candidate) {
+	slices.SortFunc(cands, func(a, b candidate) int { return cmp.Compare(a.dist, b.dist) })
+}
+
+// minCandidates pops the nearest candidate first.
+type minCandidates []candidate
+
+func (c minCandidates) Len() int           { return len(c) }
+func (c minCandidates) Less(i, j int) bool { return c[i].dist < c[j].dist }
+func (c minCandidates) Swap(i, j int)      { c[i], c[j] = c[j], c[i] }
+func (c *minCandidates) Push(x any)        { *c = append(*c, x.(candidate)) }
+func (c *minCandidates) Pop() any {
+	old := *c
+	n := len(old)
+	item := old[n-1]
+	*c = old[:n-1]
+	return item
+}
+
+// maxCandidates pops the farthest candidate first, so c[0] is the worst of
+// the current result set.
+type maxCandidates []candidate
+
+func (c maxCandidates) Len() int           { return len(c) }
+func (c maxCandidates) Less(i, j int) bool { return c[i].dist > c[j].dist }
+func (c maxCandidates) Swap(i, j int)      { c[i], c[j] = c[j], c[i] }
+func (c *maxCandidates) Push(x any)        { *c = append(*c, x.(candidate)) }
+func (c *maxCandidates) Pop() any {
+	old := *c
+	n := len(old)
+	item := old[n-1]
+	*c = old[:n-1]
+	return item
+}
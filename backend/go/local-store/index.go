@@ -0,0 +1,200 @@
+package main
+
+// Glue between the Store and its optional approximate index: routing Find
+// to the graph or the exact scan, and the recall/latency statistics
+// reported through Status().
+
+import (
+	"math"
+	"slices"
+	"sync"
+	"time"
+
+	pb "github.com/mudler/LocalAI/pkg/grpc/proto"
+	"github.com/mudler/LocalAI/pkg/store"
+	"github.com/mudler/xlog"
+)
+
+// indexStats are the counters reported in Status(). They are written by
+// Find (under the SingleThread lock) and read by Status, which the gRPC
+// server does not serialise — hence the dedicated mutex.
+type indexStats struct {
+	mu sync.Mutex
+
+	annQueries   uint64
+	annTime      time.Duration
+	exactQueries uint64
+	exactTime    time.Duration
+
+	// recallSamples ANN queries were re-run exactly; recallHits of the
+	// recallWanted exact neighbours were also returned by the graph.
+	recallSamples uint64
+	recallHits    uint64
+	recallWanted  uint64
+}
+
+// record counts a Find and returns the number of queries so far on the same
+// path.
+func (st *indexStats) record(ann bool, d time.Duration) uint64 {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if ann {
+		st.annQueries++
+		st.annTime += d
+		return st.annQueries
+	}
+	st.exactQueries++
+	st.exactTime += d
+	return st.exactQueries
+}
+
+func (st *indexStats) recordRecall(hits, wanted int) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.recallSamples++
+	st.recallHits += uint64(hits)
+	st.recallWanted += uint64(wanted)
+}
+
+// recall is the fraction of exact neighbours the graph returned across all
+// samples, or -1 before the first sample.
+func (st *indexStats) recall() float64 {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.recallWanted == 0 {
+		return -1
+	}
+	return float64(st.recallHits) / float64(st.recallWanted)
+}
+
+// findANN returns the topK entries matching filters that the graph considers
+// nearest. Values are looked up in the sorted slices and similarities
+// recomputed from the original keys, so they are directly comparable with
+// the exact scan.
+func (c *collection) findANN(query []float32, topK int, filters []store.Filter) found {
+	var accept func([]float32) bool
+	if len(filters) > 0 {
+		accept = func(k []float32) bool {
+			j, ok := slices.BinarySearchFunc(c.keys, k, slices.Compare[[]float32])
+			return ok && store.MatchAll(filters, c.metadata[j])
+		}
+	}
+	var res found
+	qmag := magnitude(query)
+	for _, k := range c.index.search(query, topK, accept) {
+		j, ok := slices.BinarySearchFunc(c.keys, k, slices.Compare[[]float32])
+		assert(ok, "findANN: graph returned a key missing from the store")
+		if !ok {
+			continue
+		}
+		res.keys = append(res.keys, c.keys[j])
+		res.values = append(res.values, c.values[j])
+		res.metadata = append(res.metadata, c.metadata[j])
+		res.similarities = append(res.similarities, cosine(query, qmag, k))
+	}
+	// The graph orders by distance over normalised copies; re-sort on the
+	// reported similarity so float rounding cannot produce an out-of-order
+	// result.
+	order := make([]int, len(res.keys))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortStableFunc(order, func(a, b int) int {
+		switch {
+		case res.similarities[a] > res.similarities[b]:
+			return -1
+		case res.similarities[a] < res.similarities[b]:
+			return 1
+		}
+		return 0
+	})
+	return found{
+		keys:         permute(res.keys, order),
+		values:       permute(res.values, order),
+		metadata:     permute(res.metadata, order),
+		similarities: permute(res.similarities, order),
+	}
+}
+
+// sampleRecall re-runs an ANN query with the exact scan and records how many
+// of the exact neighbours the graph found.
+func (s *Store) sampleRecall(c *collection, query []float32, topK int, filters []store.Filter, annKeys [][]float32) {
+	var exact found
+	if c.keysAreNormalized && isNormalized(query) {
+		exact = c.findNormalized(query, topK, filters)
+	} else {
+		exact = c.findFallback(query, topK, filters)
+	}
+	got := make(map[string]struct{}, len(annKeys))
+	for _, k := range annKeys {
+		got[keyID(k)] = struct{}{}
+	}
+	hits := 0
+	for _, k := range exact.keys {
+		if _, ok := got[keyID(k)]; ok {
+			hits++
+		}
+	}
+	s.stats.recordRecall(hits, len(exact.keys))
+	xlog.Debug("local-store: ANN recall sample", "topK", topK, "hits", hits, "wanted", len(exact.keys), "recall", s.stats.recall())
+}
+
+// Status reports the index statistics alongside the base memory usage. The
+// counters ride in Memory.Breakdown (the only free-form map of the status
+// response) under index-* keys; averages are in microseconds and recall in
+// permille so they fit the uint64 values.
+func (s *Store) Status() (pb.StatusResponse, error) {
+	res, err := s.SingleThread.Status()
+	if err != nil {
+		return pb.StatusResponse{}, err
+	}
+	st := &s.stats
+	recall := st.recall()
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	b := res.Memory.Breakdown
+	b["index-ann-queries"] = st.annQueries
+	b["index-exact-queries"] = st.exactQueries
+	if st.annQueries > 0 {
+		b["index-ann-latency-avg-us"] = uint64(st.annTime.Microseconds()) / st.annQueries
+	}
+	if st.exactQueries > 0 {
+		b["index-exact-latency-avg-us"] = uint64(st.exactTime.Microseconds()) / st.exactQueries
+	}
+	if recall >= 0 {
+		b["index-recall-samples"] = st.recallSamples
+		b["index-recall-permille"] = uint64(math.Round(recall * 1000))
+	}
+	return pb.StatusResponse{State: res.State, Memory: res.Memory}, nil
+}
+
+func magnitude(v []float32) float64 {
+	var sum float64
+	for _, x := range v {
+		sum += float64(x) * float64(x)
+	}
+	return math.Sqrt(sum)
+}
+
+// cosine mirrors the similarity computed by findFallback.
+func cosine(query []float32, qmag float64, k []float32) float32 {
+	var dot, kmag float64
+	for j := range k {
+		dot += float64(query[j]) * float64(k[j])
+		kmag += float64(k[j]) * float64(k[j])
+	}
+	denom := qmag * math.Sqrt(kmag)
+	if denom == 0 {
+		return 0
+	}
+	return float32(dot / denom)
+}
+
+func permute[T any](in []T, order []int) []T {
+	out := make([]T, len(order))
+	for i, j := range order {
+		out[i] = in[j]
+	}
+	return out
+}
@@ -0,0 +1,215 @@
+package main
+
+// On-disk snapshots of a Store (entries + HNSW graph per namespace), written when
+// persist_path is configured so a restart neither loses the entries nor
+// rebuilds the graph from scratch.
+//
+// A snapshot is a gob stream written to a temporary file and renamed over
+// the previous one, so a crash mid-write leaves the last good snapshot in
+// place. Writes happen on a timer when the store changed since the last
+// snapshot, and on Free (graceful shutdown). Changes made after the last
+// snapshot are lost on a crash; callers that keep their own source of
+// truth (e.g. vector stores) re-sync on start, since Set is an upsert.
+
+import (
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/mudler/LocalAI/pkg/store"
+	"github.com/mudler/xlog"
+)
+
+// _snapshotVersion is bumped on incompatible changes of the snapshot layout;
+// a snapshot with another version is refused rather than misread.
+const _snapshotVersion = 2
+
+type snapshot struct {
+	Version int
+	// Collections is keyed by namespace, "" being the default one.
+	Collections map[string]collectionSnapshot
+}
+
+type collectionSnapshot struct {
+	KeyLen            int
+	KeysAreNormalized bool
+	Keys              [][]float32
+	Values            [][]byte
+	Metadata          []store.Metadata
+	// Graph is nil when the store ran without an ANN index.
+	Graph *graphSnapshot
+}
+
+// graphSnapshot is the persisted part of an hnsw: the per-node state and the
+// entry point. Normalised vectors and the key map are derived on restore.
+type graphSnapshot struct {
+	Params   hnswParams
+	Keys     [][]float32
+	Links    [][][]int32
+	Deleted  []bool
+	Entry    int32
+	MaxLevel int
+}
+
+func (h *hnsw) snapshot() *graphSnapshot {
+	return &graphSnapshot{
+		Params:   h.params,
+		Keys:     h.keys,
+		Links:    h.links,
+		Deleted:  h.deleted,
+		Entry:    h.entry,
+		MaxLevel: h.maxLevel,
+	}
+}
+
+// restoreHNSW rebuilds an hnsw from a snapshot taken with the same
+// parameters.
+func restoreHNSW(g *graphSnapshot) *hnsw {
+	h := newHNSW(g.Params)
+	h.keys, h.links, h.deleted = g.Keys, g.Links, g.Deleted
+	h.entry, h.maxLevel = g.Entry, g.MaxLevel
+	h.vecs = make([][]float32, len(g.Keys))
+	for n, k := range g.Keys {
+		h.vecs[n] = unitCopy(k)
+		if g.Deleted[n] {
+			h.tombstone++
+		} else {
+			h.ids[keyID(k)] = int32(n)
+		}
+	}
+	return h
+}
+
+// save writes the store to path.
+func (s *Store) save(path string) error {
+	snap := snapshot{
+		Version:     _snapshotVersion,
+		Collections: make(map[string]collectionSnapshot, len(s.namespaces)+1),
+	}
+	s.collections(func(ns string, c *collection) {
+		cs := collectionSnapshot{
+			KeyLen:            c.keyLen,
+			KeysAreNormalized: c.keysAreNormalized,
+			Keys:              c.keys,
+			Values:            c.values,
+			Metadata:          c.metadata,
+		}
+		if c.index != nil {
+			cs.Graph = c.index.snapshot()
+		}
+		snap.Collections[ns] = cs
+	})
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return fmt.Errorf("local-store: creating snapshot directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("local-store: creating snapshot: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if err := gob.NewEncoder(tmp).Encode(&snap); err != nil {
+		tmp.Close()
+		return fmt.Errorf("local-store: writing snapshot: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("local-store: writing snapshot: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("local-store: writing snapshot: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("local-store: replacing snapshot: %w", err)
+	}
+	s.dirty = false
+	return nil
+}
+
+// restore loads the snapshot at path, if any, replacing the store contents.
+func (s *Store) restore(path string) error {
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("local-store: opening snapshot: %w", err)
+	}
+	defer f.Close()
+
+	var snap snapshot
+	if err := gob.NewDecoder(f).Decode(&snap); err != nil {
+		return fmt.Errorf("local-store: reading snapshot %s: %w", path, err)
+	}
+	if snap.Version != _snapshotVersion {
+		return fmt.Errorf("local-store: snapshot %s has version %d, want %d", path, snap.Version, _snapshotVersion)
+	}
+	for ns, cs := range snap.Collections {
+		if len(cs.Keys) != len(cs.Values) || len(cs.Keys) != len(cs.Metadata) {
+			return fmt.Errorf("local-store: snapshot %s is corrupt: namespace %q has %d keys, %d values, %d metadata", path, ns, len(cs.Keys), len(cs.Values), len(cs.Metadata))
+		}
+	}
+
+	s.collection = newCollection()
+	if s.cfg.Index == indexHNSW {
+		s.index = newHNSW(s.cfg.HNSW)
+	}
+	s.namespaces = make(map[string]*collection, len(snap.Collections))
+	for ns, cs := range snap.Collections {
+		if len(cs.Keys) == 0 {
+			continue
+		}
+		c := s.create(ns)
+		c.keys, c.values, c.metadata = cs.Keys, cs.Values, cs.Metadata
+		c.keyLen, c.keysAreNormalized = cs.KeyLen, cs.KeysAreNormalized
+		s.restoreIndex(path, ns, c, cs.Graph)
+	}
+	return nil
+}
+
+// restoreIndex reuses the persisted graph of a collection when it was built
+// with the configured parameters; otherwise (index type or HNSW parameters
+// changed) the graph is rebuilt from the restored keys.
+func (s *Store) restoreIndex(path, namespace string, c *collection, g *graphSnapshot) {
+	if c.index == nil {
+		return
+	}
+	if g != nil && g.Params == s.cfg.HNSW && len(g.Keys) == len(g.Links) && len(g.Keys) == len(g.Deleted) {
+		c.index = restoreHNSW(g)
+		if c.index.size() == len(c.keys) {
+			return
+		}
+		xlog.Warn("local-store: snapshot graph does not match its entries, rebuilding", "path", path, "namespace", namespace)
+	}
+	start := time.Now()
+	c.index = newHNSW(s.cfg.HNSW)
+	for _, k := range c.keys {
+		c.index.insert(k)
+	}
+	xlog.Info("local-store: rebuilt HNSW index from snapshot", "path", path, "namespace", namespace, "entries", len(c.keys), "took", time.Since(start))
+}
+
+// runSaver writes the store to disk every PersistInterval while it is dirty,
+// until stop is closed.
+func (s *Store) runSaver(path string, stop <-chan struct{}) {
+	ticker := time.NewTicker(s.cfg.PersistInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			s.Lock()
+			if s.dirty {
+				if err := s.save(path); err != nil {
+					xlog.Error("local-store: snapshot failed", "path", path, "error", err)
+				}
+			}
+			s.Unlock()
+		}
+	}
+}
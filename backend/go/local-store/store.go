@@ -8,11 +8,22 @@ package main
 // code that consumes it.
 //
 // Storage is a sorted parallel-slice (keys [][]float32, values
-// [][]byte). Set/Delete preserve the sort so Get can binary-search.
-// Find scans linearly and uses a heap to keep the top-K — fine for
-// the tens-to-thousands range. The "normalized fast path" (Find when
-// every stored key has unit magnitude AND the query is normalized)
-// skips the per-item magnitude calculation.
+// [][]byte, metadata []store.Metadata). Set/Delete preserve the sort
+// so Get can binary-search. Find scans linearly and uses a heap to
+// keep the top-K — fine for the tens-to-thousands range. The
+// "normalized fast path" (Find when every stored key has unit
+// magnitude AND the query is normalized) skips the per-item magnitude
+// calculation. Metadata filters are evaluated during the scan, so a
+// filtered Find still returns the topK best *matching* entries.
+//
+// Each request namespace is its own collection of slices, with its
+// own dimension; the default (empty) namespace is embedded in Store.
+//
+// Larger stores can opt into an HNSW graph (index:hnsw, see config.go):
+// Set/Delete keep it in sync incrementally and Find walks it instead of
+// scanning once the collection holds ann_min_entries entries. The exact
+// scan stays the default and the fallback. With persist_path set the
+// store is snapshotted to disk (persist.go) and restored on Load.
 //
 // Concurrency: base.SingleThread serialises gRPC calls so the
 // non-thread-safe slice/heap manipulation here is sound.
@@ -23,6 +34,7 @@ import (
 	"math"
 	"slices"
 	"strings"
+	"time"
 
 	"github.com/mudler/LocalAI/pkg/grpc/base"
 	pb "github.com/mudler/LocalAI/pkg/grpc/proto"
@@ -32,8 +44,26 @@ import (
 type Store struct {
 	base.SingleThread
 
+	// collection is the default namespace; namespaces holds the
+	// others, created by their first Set and dropped once empty.
+	collection
+	namespaces map[string]*collection
+
+	cfg   Config
+	stats indexStats
+
+	// dirty is set by every mutation and cleared by a snapshot.
+	dirty     bool
+	stopSaver chan struct{}
+}
+
+// collection is the storage of one namespace.
+type collection struct {
 	keys   [][]float32
 	values [][]byte
+	// metadata is aligned with keys; entries set without metadata
+	// hold nil.
+	metadata []store.Metadata
 
 	// keysAreNormalized stays true until any non-unit-magnitude key
 	// is added; once false, the magnitude-aware fallback path is
@@ -48,66 +78,170 @@ type Store struct {
 	// rejected so cosine similarity (which requires equal-length
 	// vectors) doesn't silently mis-match.
 	keyLen int
+
+	// index is the ANN graph, nil when cfg.Index is exact.
+	index *hnsw
 }
 
 func NewStore() *Store {
 	return &Store{
+		collection: newCollection(),
+		namespaces: make(map[string]*collection),
+		cfg:        defaultConfig(),
+	}
+}
+
+func newCollection() collection {
+	return collection{
 		keys:              make([][]float32, 0),
 		values:            make([][]byte, 0),
+		metadata:          make([]store.Metadata, 0),
 		keysAreNormalized: true,
 		keyLen:            -1,
 	}
 }
 
-// Load only validates the namespace — local-store has no on-disk
-// artefact. opts.Model is a namespace identifier, which core's
-// StoreBackend always sends with store.NamespacePrefix; anything else
-// is the model loader's greedy autoload probing with a real model name,
-// which must be refused or the LLM binds to the vector store. Isolation
-// is already handled upstream (ModelLoader spawns a fresh local-store
-// process per (backend, model) tuple, so each namespace is its own
-// Store{} instance).
+// lookup returns the collection of a namespace, or nil when nothing
+// was ever stored in it.
+func (s *Store) lookup(namespace string) *collection {
+	if namespace == "" {
+		return &s.collection
+	}
+	return s.namespaces[namespace]
+}
+
+// create returns the collection of a namespace, creating it if needed.
+func (s *Store) create(namespace string) *collection {
+	if c := s.lookup(namespace); c != nil {
+		return c
+	}
+	c := newCollection()
+	if s.cfg.Index == indexHNSW {
+		c.index = newHNSW(s.cfg.HNSW)
+	}
+	s.namespaces[namespace] = &c
+	return &c
+}
+
+// collections calls fn for the default collection and every namespace.
+func (s *Store) collections(fn func(namespace string, c *collection)) {
+	fn("", &s.collection)
+	for ns, c := range s.namespaces {
+		fn(ns, c)
+	}
+}
+
+// Load validates the namespace, applies the index options and restores the
+// snapshot at persist_path, if any. opts.Model is a namespace identifier,
+// which core's StoreBackend always sends with store.NamespacePrefix;
+// anything else is the model loader's greedy autoload probing with a real
+// model name, which must be refused or the LLM binds to the vector store.
+// Isolation is already handled upstream (ModelLoader spawns a fresh
+// local-store process per (backend, model) tuple, so each namespace is its
+// own Store{} instance).
 func (s *Store) Load(opts *pb.ModelOptions) error {
 	if !strings.HasPrefix(opts.GetModel(), store.NamespacePrefix) {
 		return fmt.Errorf("local-store: refusing to load %q: not a store namespace (expected %q prefix)", opts.GetModel(), store.NamespacePrefix)
 	}
+	cfg, err := loadConfig(opts)
+	if err != nil {
+		return err
+	}
+	s.cfg = cfg
+	s.collections(func(_ string, c *collection) {
+		c.index = nil
+		if cfg.Index == indexHNSW {
+			c.index = newHNSW(cfg.HNSW)
+			for _, k := range c.keys {
+				c.index.insert(k)
+			}
+		}
+	})
+	if s.stopSaver != nil {
+		close(s.stopSaver)
+		s.stopSaver = nil
+	}
+	if cfg.PersistPath == "" {
+		return nil
+	}
+	if err := s.restore(cfg.PersistPath); err != nil {
+		return err
+	}
+	s.stopSaver = make(chan struct{})
+	go s.runSaver(cfg.PersistPath, s.stopSaver)
+	return nil
+}
+
+// Free writes a final snapshot when persistence is enabled. The gRPC server
+// does not serialise Free with the other calls, so it takes the lock itself.
+func (s *Store) Free() error {
+	s.Lock()
+	defer s.Unlock()
+	if s.stopSaver != nil {
+		close(s.stopSaver)
+		s.stopSaver = nil
+	}
+	if s.cfg.PersistPath != "" && s.dirty {
+		return s.save(s.cfg.PersistPath)
+	}
 	return nil
 }
 
 func (s *Store) StoresSet(opts *pb.StoresSetOptions) error {
 	keys := store.UnwrapKeys(opts.Keys)
 	values := store.UnwrapValues(opts.Values)
+	metadata := store.UnwrapMetadata(opts.Metadata)
 	if len(keys) == 0 {
 		return fmt.Errorf("local-store: Set: no keys to add")
 	}
 	if len(keys) != len(values) {
 		return fmt.Errorf("local-store: Set: len(keys) = %d, len(values) = %d", len(keys), len(values))
 	}
+	if len(metadata) != 0 && len(metadata) != len(keys) {
+		return fmt.Errorf("local-store: Set: len(keys) = %d, len(metadata) = %d", len(keys), len(metadata))
+	}
 
-	if s.keyLen == -1 {
-		s.keyLen = len(keys[0])
-	} else if len(keys[0]) != s.keyLen {
-		return fmt.Errorf("local-store: Set: key length %d does not match existing %d", len(keys[0]), s.keyLen)
+	c := s.lookup(opts.Namespace)
+	keyLen := len(keys[0])
+	if c != nil && c.keyLen != -1 {
+		keyLen = c.keyLen
+	}
+	for i, k := range keys {
+		if len(k) != keyLen {
+			if i == 0 {
+				return fmt.Errorf("local-store: Set: key length %d does not match existing %d", len(k), keyLen)
+			}
+			return fmt.Errorf("local-store: Set: key %d length %d does not match existing %d", i, len(k), keyLen)
+		}
 	}
 
+	c = s.create(opts.Namespace)
+	c.keyLen = keyLen
 	kvs := make([]incomingPair, len(keys))
 	for i, k := range keys {
-		if len(k) != s.keyLen {
-			return fmt.Errorf("local-store: Set: key %d length %d does not match existing %d", i, len(k), s.keyLen)
-		}
-		if s.keysAreNormalized && !isNormalized(k) {
-			s.keysAreNormalized = false
+		if c.keysAreNormalized && !isNormalized(k) {
+			c.keysAreNormalized = false
 		}
 		kvs[i] = incomingPair{key: k, value: values[i]}
+		if len(metadata) != 0 {
+			kvs[i].metadata = metadata[i]
+		}
 	}
 
 	slices.SortFunc(kvs, func(a, b incomingPair) int { return slices.Compare(a.key, b.key) })
 
-	merged := mergeSortedPairs(s.keys, s.values, kvs)
-	s.keys = merged.keys
-	s.values = merged.values
-	assert(slices.IsSortedFunc(s.keys, slices.Compare[[]float32]), "Set: s.keys not sorted post-merge")
-	assert(len(s.keys) == len(s.values), "Set: keys/values length skew")
+	merged := mergeSortedPairs(c.keys, c.values, c.metadata, kvs)
+	c.keys = merged.keys
+	c.values = merged.values
+	c.metadata = merged.metadata
+	if c.index != nil {
+		for _, kv := range kvs {
+			c.index.insert(kv.key)
+		}
+	}
+	s.dirty = true
+	assert(slices.IsSortedFunc(c.keys, slices.Compare[[]float32]), "Set: keys not sorted post-merge")
+	assert(len(c.keys) == len(c.values) && len(c.keys) == len(c.metadata), "Set: keys/values/metadata length skew")
 	return nil
 }
 
@@ -116,39 +250,56 @@ func (s *Store) StoresDelete(opts *pb.StoresDeleteOptions) error {
 	if len(keys) == 0 {
 		return fmt.Errorf("local-store: Delete: no keys to delete")
 	}
-	if s.keyLen != -1 {
+	c := s.lookup(opts.Namespace)
+	if c == nil {
+		return nil
+	}
+	if c.keyLen != -1 {
 		for i, k := range keys {
-			if len(k) != s.keyLen {
-				return fmt.Errorf("local-store: Delete: key %d length %d does not match existing %d", i, len(k), s.keyLen)
+			if len(k) != c.keyLen {
+				return fmt.Errorf("local-store: Delete: key %d length %d does not match existing %d", i, len(k), c.keyLen)
 			}
 		}
 	}
 	sortedKeys := append([][]float32(nil), keys...)
 	slices.SortFunc(sortedKeys, slices.Compare[[]float32])
 
-	mergedK := make([][]float32, 0, len(s.keys))
-	mergedV := make([][]byte, 0, len(s.keys))
-	tailK := s.keys
-	tailV := s.values
+	mergedK := make([][]float32, 0, len(c.keys))
+	mergedV := make([][]byte, 0, len(c.keys))
+	mergedM := make([]store.Metadata, 0, len(c.keys))
+	tailK := c.keys
+	tailV := c.values
+	tailM := c.metadata
 	for _, k := range sortedKeys {
 		j, ok := slices.BinarySearchFunc(tailK, k, slices.Compare[[]float32])
 		if ok {
+			if c.index != nil {
+				c.index.remove(tailK[j])
+			}
+			s.dirty = true
 			mergedK = append(mergedK, tailK[:j]...)
 			mergedV = append(mergedV, tailV[:j]...)
+			mergedM = append(mergedM, tailM[:j]...)
 			tailK = tailK[j+1:]
 			tailV = tailV[j+1:]
+			tailM = tailM[j+1:]
 		}
 	}
 	mergedK = append(mergedK, tailK...)
 	mergedV = append(mergedV, tailV...)
-	s.keys = mergedK
-	s.values = mergedV
-	if len(s.keys) == 0 {
-		s.keyLen = -1
-		s.keysAreNormalized = true
-	}
-	assert(slices.IsSortedFunc(s.keys, slices.Compare[[]float32]), "Delete: s.keys not sorted post-merge")
-	assert(len(s.keys) == len(s.values), "Delete: keys/values length skew")
+	mergedM = append(mergedM, tailM...)
+	c.keys = mergedK
+	c.values = mergedV
+	c.metadata = mergedM
+	if len(c.keys) == 0 {
+		c.keyLen = -1
+		c.keysAreNormalized = true
+		if opts.Namespace != "" {
+			delete(s.namespaces, opts.Namespace)
+		}
+	}
+	assert(slices.IsSortedFunc(c.keys, slices.Compare[[]float32]), "Delete: keys not sorted post-merge")
+	assert(len(c.keys) == len(c.values) && len(c.keys) == len(c.metadata), "Delete: keys/values/metadata length skew")
 	return nil
 }
 
@@ -158,13 +309,14 @@ func (s *Store) StoresDelete(opts *pb.StoresDeleteOptions) error {
 // them. Returned slices are aligned.
 func (s *Store) StoresGet(opts *pb.StoresGetOptions) (pb.StoresGetResult, error) {
 	keys := store.UnwrapKeys(opts.Keys)
-	if len(s.keys) == 0 {
+	c := s.lookup(opts.Namespace)
+	if c == nil || len(c.keys) == 0 {
 		return pb.StoresGetResult{}, nil
 	}
-	if s.keyLen != -1 {
+	if c.keyLen != -1 {
 		for i, k := range keys {
-			if len(k) != s.keyLen {
-				return pb.StoresGetResult{}, fmt.Errorf("local-store: Get: key %d length %d does not match existing %d", i, len(k), s.keyLen)
+			if len(k) != c.keyLen {
+				return pb.StoresGetResult{}, fmt.Errorf("local-store: Get: key %d length %d does not match existing %d", i, len(k), c.keyLen)
 			}
 		}
 	}
@@ -173,8 +325,10 @@ func (s *Store) StoresGet(opts *pb.StoresGetOptions) (pb.StoresGetResult, error)
 
 	var foundKeys [][]float32
 	var foundValues [][]byte
-	tailK := s.keys
-	tailV := s.values
+	var foundMetadata []store.Metadata
+	tailK := c.keys
+	tailV := c.values
+	tailM := c.metadata
 	for _, k := range sortedKeys {
 		j, ok := slices.BinarySearchFunc(tailK, k, slices.Compare[[]float32])
 		if !ok {
@@ -182,58 +336,86 @@ func (s *Store) StoresGet(opts *pb.StoresGetOptions) (pb.StoresGetResult, error)
 		}
 		foundKeys = append(foundKeys, tailK[j])
 		foundValues = append(foundValues, tailV[j])
+		foundMetadata = append(foundMetadata, tailM[j])
 		tailK = tailK[j+1:]
 		tailV = tailV[j+1:]
+		tailM = tailM[j+1:]
 	}
 	return pb.StoresGetResult{
-		Keys:   store.WrapKeys(foundKeys),
-		Values: store.WrapValues(foundValues),
+		Keys:     store.WrapKeys(foundKeys),
+		Values:   store.WrapValues(foundValues),
+		Metadata: wrapMetadata(foundMetadata),
 	}, nil
 }
 
 // StoresFind returns the topK nearest stored entries by cosine
-// similarity, ordered most-similar first. An empty store returns
-// empty slices and no error.
+// similarity, ordered most-similar first. With filters, only entries
+// whose metadata matches all of them are considered. An empty store
+// returns empty slices and no error.
 func (s *Store) StoresFind(opts *pb.StoresFindOptions) (pb.StoresFindResult, error) {
-	query := opts.Key.Floats
+	query := opts.Key.GetFloats()
 	topK := int(opts.TopK)
 	if topK < 1 {
 		return pb.StoresFindResult{}, fmt.Errorf("local-store: Find: topK = %d, must be >= 1", topK)
 	}
-	if len(s.keys) == 0 {
+	filters, err := store.UnwrapFilters(opts.Filters)
+	if err != nil {
+		return pb.StoresFindResult{}, fmt.Errorf("local-store: Find: %w", err)
+	}
+	c := s.lookup(opts.Namespace)
+	if c == nil || len(c.keys) == 0 {
 		return pb.StoresFindResult{}, nil
 	}
-	if len(query) != s.keyLen {
-		return pb.StoresFindResult{}, fmt.Errorf("local-store: Find: query length %d does not match existing %d", len(query), s.keyLen)
+	if len(query) != c.keyLen {
+		return pb.StoresFindResult{}, fmt.Errorf("local-store: Find: query length %d does not match existing %d", len(query), c.keyLen)
 	}
 
-	var keys [][]float32
-	var values [][]byte
-	var sims []float32
-	if s.keysAreNormalized && isNormalized(query) {
-		keys, values, sims = s.findNormalized(query, topK)
-	} else {
-		keys, values, sims = s.findFallback(query, topK)
+	var res found
+	start := time.Now()
+	ann := c.index != nil && len(c.keys) >= s.cfg.ANNMinEntries
+	switch {
+	case ann:
+		res = c.findANN(query, topK, filters)
+	case c.keysAreNormalized && isNormalized(query):
+		res = c.findNormalized(query, topK, filters)
+	default:
+		res = c.findFallback(query, topK, filters)
+	}
+	n := s.stats.record(ann, time.Since(start))
+	if ann && s.cfg.RecallSampleEvery > 0 && n%uint64(s.cfg.RecallSampleEvery) == 0 {
+		s.sampleRecall(c, query, topK, filters, res.keys)
 	}
 	return pb.StoresFindResult{
-		Keys:         store.WrapKeys(keys),
-		Values:       store.WrapValues(values),
-		Similarities: sims,
+		Keys:         store.WrapKeys(res.keys),
+		Values:       store.WrapValues(res.values),
+		Similarities: res.similarities,
+		Metadata:     wrapMetadata(res.metadata),
 	}, nil
 }
 
-func (s *Store) findNormalized(query []float32, topK int) (keys [][]float32, values [][]byte, similarities []float32) {
-	assert(s.keysAreNormalized, "findNormalized: s.keysAreNormalized is false")
+// found is the column-wise result of a Find, most similar first.
+type found struct {
+	keys         [][]float32
+	values       [][]byte
+	metadata     []store.Metadata
+	similarities []float32
+}
+
+func (c *collection) findNormalized(query []float32, topK int, filters []store.Filter) found {
+	assert(c.keysAreNormalized, "findNormalized: keysAreNormalized is false")
 	assert(isNormalized(query), "findNormalized: query is not unit-length")
 	pq := make(priorityQueue, 0, topK)
 	heap.Init(&pq)
-	for i, k := range s.keys {
+	for i, k := range c.keys {
+		if len(filters) > 0 && !store.MatchAll(filters, c.metadata[i]) {
+			continue
+		}
 		var dot float32
 		for j := range k {
 			dot += query[j] * k[j]
 		}
 		assert(dot >= -1.01 && dot <= 1.01, fmt.Sprintf("findNormalized: dot %f out of [-1, 1] — keysAreNormalized invariant violated", dot))
-		heap.Push(&pq, &priorityItem{similarity: dot, key: k, value: s.values[i]})
+		heap.Push(&pq, &priorityItem{similarity: dot, key: k, value: c.values[i], metadata: c.metadata[i]})
 		if pq.Len() > topK {
 			heap.Pop(&pq)
 		}
@@ -241,7 +423,7 @@ func (s *Store) findNormalized(query []float32, topK int) (keys [][]float32, val
 	return drainPQ(&pq)
 }
 
-func (s *Store) findFallback(query []float32, topK int) (keys [][]float32, values [][]byte, similarities []float32) {
+func (c *collection) findFallback(query []float32, topK int, filters []store.Filter) found {
 	var qmag float64
 	for _, v := range query {
 		qmag += float64(v) * float64(v)
@@ -249,7 +431,10 @@ func (s *Store) findFallback(query []float32, topK int) (keys [][]float32, value
 	qmag = math.Sqrt(qmag)
 	pq := make(priorityQueue, 0, topK)
 	heap.Init(&pq)
-	for i, k := range s.keys {
+	for i, k := range c.keys {
+		if len(filters) > 0 && !store.MatchAll(filters, c.metadata[i]) {
+			continue
+		}
 		var dot, kmag float64
 		for j := range k {
 			dot += float64(query[j]) * float64(k[j])
@@ -260,7 +445,7 @@ func (s *Store) findFallback(query []float32, topK int) (keys [][]float32, value
 		if denom > 0 {
 			sim = float32(dot / denom)
 		}
-		heap.Push(&pq, &priorityItem{similarity: sim, key: k, value: s.values[i]})
+		heap.Push(&pq, &priorityItem{similarity: sim, key: k, value: c.values[i], metadata: c.metadata[i]})
 		if pq.Len() > topK {
 			heap.Pop(&pq)
 		}
@@ -277,34 +462,48 @@ func isNormalized(k []float32) bool {
 	return mag >= 0.99 && mag <= 1.01
 }
 
+// wrapMetadata converts result metadata to its wire form. The metadata
+// was unwrapped from a Set request, so it always converts.
+func wrapMetadata(md []store.Metadata) []*pb.StoresMetadata {
+	out, err := store.WrapMetadata(md)
+	assert(err == nil, fmt.Sprintf("wrapMetadata: stored metadata does not convert: %v", err))
+	return out
+}
+
 type incomingPair struct {
-	key   []float32
-	value []byte
+	key      []float32
+	value    []byte
+	metadata store.Metadata
 }
 
 type pairs struct {
-	keys   [][]float32
-	values [][]byte
+	keys     [][]float32
+	values   [][]byte
+	metadata []store.Metadata
 }
 
 // mergeSortedPairs merges (existing, incoming) into a fresh sorted
-// slice. Equal keys take the incoming value — Set is upsert.
-func mergeSortedPairs(existingK [][]float32, existingV [][]byte, incoming []incomingPair) pairs {
+// slice. Equal keys take the incoming value and metadata — Set is
+// upsert.
+func mergeSortedPairs(existingK [][]float32, existingV [][]byte, existingM []store.Metadata, incoming []incomingPair) pairs {
 	assert(slices.IsSortedFunc(existingK, slices.Compare[[]float32]), "mergeSortedPairs: existing not sorted")
 	assert(slices.IsSortedFunc(incoming, func(a, b incomingPair) int { return slices.Compare(a.key, b.key) }), "mergeSortedPairs: incoming not sorted")
 	l := len(existingK) + len(incoming)
 	mk := make([][]float32, 0, l)
 	mv := make([][]byte, 0, l)
+	mm := make([]store.Metadata, 0, l)
 	i, j := 0, 0
 	for i < len(incoming) || j < len(existingK) {
 		switch {
 		case j >= len(existingK):
 			mk = append(mk, incoming[i].key)
 			mv = append(mv, incoming[i].value)
+			mm = append(mm, incoming[i].metadata)
 			i++
 		case i >= len(incoming):
 			mk = append(mk, existingK[j])
 			mv = append(mv, existingV[j])
+			mm = append(mm, existingM[j])
 			j++
 		default:
 			c := slices.Compare(incoming[i].key, existingK[j])
@@ -312,26 +511,30 @@ func mergeSortedPairs(existingK [][]float32, existingV [][]byte, incoming []inco
 			case c < 0:
 				mk = append(mk, incoming[i].key)
 				mv = append(mv, incoming[i].value)
+				mm = append(mm, incoming[i].metadata)
 				i++
 			case c > 0:
 				mk = append(mk, existingK[j])
 				mv = append(mv, existingV[j])
+				mm = append(mm, existingM[j])
 				j++
 			default:
 				mk = append(mk, incoming[i].key)
 				mv = append(mv, incoming[i].value)
+				mm = append(mm, incoming[i].metadata)
 				i++
 				j++
 			}
 		}
 	}
-	return pairs{keys: mk, values: mv}
+	return pairs{keys: mk, values: mv, metadata: mm}
 }
 
 type priorityItem struct {
 	similarity float32
 	key        []float32
 	value      []byte
+	metadata   store.Metadata
 }
 
 type priorityQueue []*priorityItem
@@ -348,16 +551,20 @@ func (pq *priorityQueue) Pop() any {
 	return item
 }
 
-func drainPQ(pq *priorityQueue) (keys [][]float32, values [][]byte, similarities []float32) {
+func drainPQ(pq *priorityQueue) found {
 	n := pq.Len()
-	keys = make([][]float32, n)
-	values = make([][]byte, n)
-	similarities = make([]float32, n)
+	res := found{
+		keys:         make([][]float32, n),
+		values:       make([][]byte, n),
+		metadata:     make([]store.Metadata, n),
+		similarities: make([]float32, n),
+	}
 	for i := n - 1; i >= 0; i-- {
 		item := heap.Pop(pq).(*priorityItem)
-		keys[i] = item.key
-		values[i] = item.value
-		similarities[i] = item.similarity
+		res.keys[i] = item.key
+		res.values[i] = item.value
+		res.metadata[i] = item.metadata
+		res.similarities[i] = item.similarity
 	}
-	return keys, values, similarities
+	return res
 }
@@ -7,8 +7,12 @@ package main
 // matches the production import shape.
 
 import (
+	"cmp"
+	"fmt"
 	"math"
 	"math/rand/v2"
+	"path/filepath"
+	"slices"
 	"testing"
 
 	pb "github.com/mudler/LocalAI/pkg/grpc/proto"
@@ -242,6 +246,269 @@ var _ = Describe("StoresLoad", func() {
 	})
 })
 
+var _ = Describe("HNSW index", func() {
+	const dim = 32
+
+	randomKeys := func(n int, seed int64) ([][]float32, [][]byte) {
+		keys := make([][]float32, n)
+		values := make([][]byte, n)
+		for i := range keys {
+			keys[i] = normalizeVec(randVec(dim, seed+int64(i)))
+			values[i] = []byte(fmt.Sprint(i))
+		}
+		return keys, values
+	}
+
+	find := func(s *Store, query []float32, topK int) *pb.StoresFindResult {
+		res, err := s.StoresFind(&pb.StoresFindOptions{Key: &pb.StoresKey{Floats: query}, TopK: int32(topK)})
+		ExpectWithOffset(1, err).NotTo(HaveOccurred())
+		return &res
+	}
+
+	It("finds nearly the same neighbours as the exact scan", func() {
+		keys, values := randomKeys(2000, 1)
+		ann := loadStore("index:hnsw", "ann_min_entries:0")
+		exact := NewStore()
+		mustSet(ann, keys, values)
+		mustSet(exact, keys, values)
+
+		hits, wanted := 0, 0
+		for q := range 50 {
+			query := normalizeVec(randVec(dim, 100000+int64(q)))
+			got := find(ann, query, 10)
+			want := find(exact, query, 10)
+			Expect(got.Keys).To(HaveLen(10))
+			Expect(slices.IsSortedFunc(got.Similarities, func(a, b float32) int { return cmp.Compare(b, a) })).To(BeTrue())
+			seen := map[string]bool{}
+			for _, k := range got.Keys {
+				seen[keyID(k.Floats)] = true
+			}
+			for _, k := range want.Keys {
+				if seen[keyID(k.Floats)] {
+					hits++
+				}
+			}
+			wanted += len(want.Keys)
+		}
+		Expect(float64(hits) / float64(wanted)).To(BeNumerically(">=", 0.95))
+	})
+
+	It("keeps the graph in sync with deletes", func() {
+		keys, values := randomKeys(500, 1)
+		s := loadStore("index:hnsw", "ann_min_entries:0")
+		mustSet(s, keys, values)
+		Expect(s.StoresDelete(&pb.StoresDeleteOptions{Keys: wrapKeys(keys[:400])})).To(Succeed())
+		Expect(s.index.size()).To(Equal(100))
+
+		deleted := map[string]bool{}
+		for _, k := range keys[:400] {
+			deleted[keyID(k)] = true
+		}
+		for _, k := range keys[:20] {
+			res := find(s, k, 5)
+			Expect(res.Keys).To(HaveLen(5))
+			for _, got := range res.Keys {
+				Expect(deleted[keyID(got.Floats)]).To(BeFalse())
+			}
+		}
+		// A surviving key is its own nearest neighbour.
+		res := find(s, keys[450], 1)
+		Expect(res.Values[0].Bytes).To(Equal(values[450]))
+
+		Expect(s.StoresDelete(&pb.StoresDeleteOptions{Keys: wrapKeys(keys[400:])})).To(Succeed())
+		Expect(s.index.size()).To(BeZero())
+		Expect(find(s, keys[0], 1).Keys).To(BeEmpty())
+	})
+
+	It("falls back to the exact scan below ann_min_entries", func() {
+		keys, values := randomKeys(50, 1)
+		s := loadStore("index:hnsw", "ann_min_entries:100", "recall_sample_every:0")
+		mustSet(s, keys, values)
+		find(s, keys[0], 3)
+		Expect(s.stats.exactQueries).To(Equal(uint64(1)))
+		Expect(s.stats.annQueries).To(BeZero())
+	})
+
+	It("reports recall and latency in Status", func() {
+		keys, values := randomKeys(300, 1)
+		s := loadStore("index:hnsw", "ann_min_entries:0", "recall_sample_every:1")
+		mustSet(s, keys, values)
+		for _, k := range keys[:10] {
+			find(s, k, 3)
+		}
+		status, err := s.Status()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(status.Memory.Breakdown).To(HaveKeyWithValue("index-ann-queries", uint64(10)))
+		Expect(status.Memory.Breakdown).To(HaveKeyWithValue("index-recall-samples", uint64(10)))
+		Expect(status.Memory.Breakdown).To(HaveKey("index-ann-latency-avg-us"))
+		Expect(status.Memory.Breakdown["index-recall-permille"]).To(BeNumerically(">=", 900))
+	})
+
+	It("persists the entries and the graph across restarts", func() {
+		keys, values := randomKeys(300, 1)
+		path := filepath.Join(GinkgoT().TempDir(), "store.idx")
+		s := loadStore("index:hnsw", "ann_min_entries:0", "persist_path:"+path)
+		mustSet(s, keys, values)
+		Expect(s.StoresDelete(&pb.StoresDeleteOptions{Keys: wrapKeys(keys[:10])})).To(Succeed())
+		Expect(s.Free()).To(Succeed())
+		Expect(path).To(BeAnExistingFile())
+
+		restarted := loadStore("index:hnsw", "ann_min_entries:0", "persist_path:"+path)
+		DeferCleanup(restarted.Free)
+		Expect(restarted.keys).To(Equal(s.keys))
+		Expect(restarted.index.links).To(Equal(s.index.links))
+		Expect(restarted.index.size()).To(Equal(290))
+		Expect(singleGet(restarted, keys[100])).To(Equal(values[100]))
+		Expect(find(restarted, keys[100], 1).Values[0].Bytes).To(Equal(values[100]))
+
+		// Changing the graph parameters rebuilds it from the entries.
+		rebuilt := loadStore("index:hnsw", "hnsw_m:8", "ann_min_entries:0", "persist_path:"+path)
+		DeferCleanup(rebuilt.Free)
+		Expect(rebuilt.index.size()).To(Equal(290))
+		Expect(rebuilt.index.params.M).To(Equal(8))
+	})
+})
+
+var _ = Describe("Namespaces and metadata", func() {
+	setNS := func(s *Store, ns string, keys [][]float32, values [][]byte, md []store.Metadata) error {
+		wrapped, err := store.WrapMetadata(md)
+		ExpectWithOffset(1, err).NotTo(HaveOccurred())
+		return s.StoresSet(&pb.StoresSetOptions{Keys: wrapKeys(keys), Values: wrapValues(values), Metadata: wrapped, Namespace: ns})
+	}
+	findFiltered := func(s *Store, ns string, query []float32, topK int, filters ...store.Filter) []string {
+		wrapped, err := store.WrapFilters(filters)
+		ExpectWithOffset(1, err).NotTo(HaveOccurred())
+		res, err := s.StoresFind(&pb.StoresFindOptions{Key: &pb.StoresKey{Floats: query}, TopK: int32(topK), Filters: wrapped, Namespace: ns})
+		ExpectWithOffset(1, err).NotTo(HaveOccurred())
+		var out []string
+		for _, v := range res.Values {
+			out = append(out, string(v.Bytes))
+		}
+		return out
+	}
+	ptr := func(f float64) *float64 { return &f }
+
+	It("keeps namespaces isolated, each with its own dimension", func() {
+		s := NewStore()
+		mustSet(s, [][]float32{{1, 0, 0}}, [][]byte{[]byte("default")})
+		Expect(setNS(s, "tenant-a", [][]float32{{1, 0}}, [][]byte{[]byte("a")}, nil)).To(Succeed())
+
+		Expect(findFiltered(s, "", []float32{1, 0, 0}, 5)).To(Equal([]string{"default"}))
+		Expect(findFiltered(s, "tenant-a", []float32{1, 0}, 5)).To(Equal([]string{"a"}))
+		Expect(findFiltered(s, "tenant-b", []float32{1, 0}, 5)).To(BeEmpty())
+		Expect(s.namespaces).NotTo(HaveKey("tenant-b"), "reads must not create namespaces")
+
+		Expect(s.StoresDelete(&pb.StoresDeleteOptions{Keys: wrapKeys([][]float32{{1, 0}}), Namespace: "tenant-a"})).To(Succeed())
+		Expect(s.namespaces).NotTo(HaveKey("tenant-a"), "an emptied namespace is dropped")
+		Expect(singleGet(s, []float32{1, 0, 0})).To(Equal([]byte("default")))
+	})
+
+	It("returns metadata from Get and Find and replaces it on upsert", func() {
+		s := NewStore()
+		Expect(setNS(s, "", [][]float32{{1, 0}, {0, 1}}, [][]byte{[]byte("a"), []byte("b")},
+			[]store.Metadata{{"lang": "en", "year": 2024}, nil})).To(Succeed())
+
+		res, err := s.StoresGet(&pb.StoresGetOptions{Keys: wrapKeys([][]float32{{1, 0}, {0, 1}})})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(store.UnwrapMetadata(res.Metadata)).To(Equal([]store.Metadata{nil, {"lang": "en", "year": float64(2024)}}))
+
+		Expect(setNS(s, "", [][]float32{{0, 1}}, [][]byte{[]byte("b")}, []store.Metadata{{"lang": "de"}})).To(Succeed())
+		found, err := s.StoresFind(&pb.StoresFindOptions{Key: &pb.StoresKey{Floats: []float32{0, 1}}, TopK: 1})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(store.UnwrapMetadata(found.Metadata)).To(Equal([]store.Metadata{{"lang": "de"}}))
+	})
+
+	It("rejects misaligned metadata and malformed filters", func() {
+		s := NewStore()
+		Expect(setNS(s, "", [][]float32{{1, 0}, {0, 1}}, [][]byte{[]byte("a"), []byte("b")},
+			[]store.Metadata{{"lang": "en"}})).To(MatchError(ContainSubstring("len(metadata)")))
+		mustSet(s, [][]float32{{1, 0}}, [][]byte{[]byte("a")})
+		_, err := s.StoresFind(&pb.StoresFindOptions{
+			Key:     &pb.StoresKey{Floats: []float32{1, 0}},
+			TopK:    1,
+			Filters: []*pb.StoresFilter{{Field: "lang"}},
+		})
+		Expect(err).To(MatchError(ContainSubstring("exactly one")))
+	})
+
+	DescribeTable("filters the topK to matching entries",
+		func(options []string) {
+			const n = 400
+			s := loadStore(options...)
+			keys := make([][]float32, n)
+			values := make([][]byte, n)
+			md := make([]store.Metadata, n)
+			for i := range keys {
+				keys[i] = normalizeVec(randVec(16, int64(i)+1))
+				values[i] = []byte(fmt.Sprint(i))
+				md[i] = store.Metadata{"parity": []string{"even", "odd"}[i%2], "n": i, "tagged": i%10 == 0}
+			}
+			Expect(setNS(s, "", keys, values, md)).To(Succeed())
+
+			got := findFiltered(s, "", keys[3], 5, store.Filter{Field: "parity", Eq: "odd"})
+			Expect(got).To(HaveLen(5))
+			Expect(got[0]).To(Equal("3"))
+			for _, v := range got {
+				var i int
+				fmt.Sscan(v, &i)
+				Expect(i % 2).To(Equal(1))
+			}
+
+			got = findFiltered(s, "", keys[3], 50,
+				store.Filter{Field: "n", Range: &store.Range{Gte: ptr(100), Lt: ptr(200)}},
+				store.Filter{Field: "tagged", Eq: true})
+			Expect(got).To(ConsistOf("100", "110", "120", "130", "140", "150", "160", "170", "180", "190"))
+
+			got = findFiltered(s, "", keys[3], 5, store.Filter{Field: "n", In: []any{7, 9, "11"}})
+			Expect(got).To(ConsistOf("7", "9"), "a string never matches a number")
+
+			Expect(findFiltered(s, "", keys[3], 5, store.Filter{Field: "missing", Eq: "x"})).To(BeEmpty())
+		},
+		Entry("exact scan", []string(nil)),
+		Entry("HNSW", []string{"index:hnsw", "ann_min_entries:0"}),
+	)
+
+	It("persists namespaces and metadata", func() {
+		path := filepath.Join(GinkgoT().TempDir(), "store.idx")
+		s := loadStore("index:hnsw", "ann_min_entries:0", "persist_path:"+path)
+		Expect(setNS(s, "tenant-a", [][]float32{{1, 0}, {0, 1}}, [][]byte{[]byte("a"), []byte("b")},
+			[]store.Metadata{{"lang": "en"}, {"lang": "de"}})).To(Succeed())
+		mustSet(s, [][]float32{{1, 0, 0}}, [][]byte{[]byte("default")})
+		Expect(s.Free()).To(Succeed())
+
+		restarted := loadStore("index:hnsw", "ann_min_entries:0", "persist_path:"+path)
+		DeferCleanup(restarted.Free)
+		Expect(findFiltered(restarted, "tenant-a", []float32{1, 0}, 5, store.Filter{Field: "lang", Eq: "de"})).To(Equal([]string{"b"}))
+		Expect(restarted.namespaces["tenant-a"].index.size()).To(Equal(2))
+		Expect(singleGet(restarted, []float32{1, 0, 0})).To(Equal([]byte("default")))
+	})
+})
+
+var _ = Describe("loadConfig", func() {
+	It("defaults to the exact scan without persistence", func() {
+		cfg, err := loadConfig(&pb.ModelOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cfg).To(Equal(defaultConfig()))
+		Expect(cfg.Index).To(Equal(indexExact))
+	})
+
+	It("resolves a relative persist_path against the model path", func() {
+		cfg, err := loadConfig(&pb.ModelOptions{ModelPath: "/models", Options: []string{"persist_path:stores/a.idx", "index:HNSW"}})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cfg.PersistPath).To(Equal(filepath.Join("/models", "stores/a.idx")))
+		Expect(cfg.Index).To(Equal(indexHNSW))
+	})
+
+	It("rejects unknown index types and malformed numbers", func() {
+		_, err := loadConfig(&pb.ModelOptions{Options: []string{"index:ivf"}})
+		Expect(err).To(MatchError(ContainSubstring("want exact or hnsw")))
+		_, err = loadConfig(&pb.ModelOptions{Options: []string{"hnsw_m:1x6"}})
+		Expect(err).To(MatchError(ContainSubstring("hnsw_m")))
+		_, err = loadConfig(&pb.ModelOptions{Options: []string{"hnsw_m:1"}})
+		Expect(err).To(HaveOccurred())
+	})
+})
+
 func BenchmarkStoresFindNormalized(b *testing.B) {
 	const dim = 768
 	for _, n := range []int{8, 32, 128, 512} {
@@ -261,6 +528,12 @@ func BenchmarkStoresFindNormalized(b *testing.B) {
 
 // --- test helpers ---
 
+func loadStore(options ...string) *Store {
+	s := NewStore()
+	ExpectWithOffset(1, s.Load(&pb.ModelOptions{Model: store.NamespacePrefix + "test", Options: options})).To(Succeed())
+	return s
+}
+
 func mustSet(s *Store, keys [][]float32, values [][]byte) {
 	ExpectWithOffset(1, s.StoresSet(&pb.StoresSetOptions{Keys: wrapKeys(keys), Values: wrapValues(values)})).To(Succeed())
 }
@@ -20,10 +20,12 @@ package main
 //	  - addr:valkey.internal:6379
 //	  - index_algo:HNSW
 //	  - distance_metric:COSINE
+//	  - metadata_fields:lang:tag,year:numeric
 
 import (
 	"fmt"
 	"os"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
@@ -71,6 +73,11 @@ const (
 	distanceL2     = "L2"
 	distanceIP     = "IP"
 
+	// Metadata field types. TAG fields match strings and booleans exactly,
+	// NUMERIC fields support equality and ranges.
+	metadataTag     = "tag"
+	metadataNumeric = "numeric"
+
 	// Option keys recognised in the model config `options:` list. They mirror
 	// the previous VALKEY_* env var names without the prefix and lower-cased, so
 	// operators migrating a config have an obvious 1:1 mapping.
@@ -90,8 +97,13 @@ const (
 	optHNSWEFConstruction = "hnsw_ef_construction"
 	optHNSWEFRuntime      = "hnsw_ef_runtime"
 	optRequestTimeoutMS   = "request_timeout_ms"
+	optMetadataFields     = "metadata_fields"
 )
 
+// metadataFieldName restricts declared metadata field names to characters
+// that need no escaping in FT.CREATE and FT.SEARCH.
+var metadataFieldName = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
 // hnswParams holds the HNSW-only tuning knobs. They are ignored unless
 // IndexAlgo == indexAlgoHNSW.
 type hnswParams struct {
@@ -114,6 +126,15 @@ type Config struct {
 	DistanceMetric string
 	HNSW           hnswParams
 	RequestTimeout time.Duration
+	// MetadataFields are the metadata fields indexed for StoresFind filters.
+	// Undeclared fields are stored and returned but cannot be filtered on.
+	MetadataFields []metadataField
+}
+
+// metadataField is a declared, filterable metadata field.
+type metadataField struct {
+	Name string
+	Type string
 }
 
 // parseOptions turns the repeated `key:value` ModelOptions.Options list into a
@@ -213,9 +234,42 @@ func loadConfig(opts *pb.ModelOptions) (Config, error) {
 		cfg.RequestTimeout = time.Duration(_defaultRequestTimeoutMS) * time.Millisecond
 	}
 
+	fields, err := parseMetadataFields(o[optMetadataFields])
+	if err != nil {
+		return Config{}, err
+	}
+	cfg.MetadataFields = fields
+
 	return cfg, nil
 }
 
+// parseMetadataFields parses the `name:type,name:type` list of the
+// metadata_fields option. Names are restricted so they can be interpolated
+// into FT.CREATE and FT.SEARCH without escaping.
+func parseMetadataFields(v string) ([]metadataField, error) {
+	if v == "" {
+		return nil, nil
+	}
+	var fields []metadataField
+	seen := make(map[string]bool)
+	for _, entry := range strings.Split(v, ",") {
+		name, typ, _ := strings.Cut(strings.TrimSpace(entry), ":")
+		typ = strings.ToLower(strings.TrimSpace(typ))
+		if !metadataFieldName.MatchString(name) {
+			return nil, fmt.Errorf("valkey-store: invalid option %s: field name %q (want letters, digits and '_')", optMetadataFields, name)
+		}
+		if typ != metadataTag && typ != metadataNumeric {
+			return nil, fmt.Errorf("valkey-store: invalid option %s: field %q type %q (want tag or numeric)", optMetadataFields, name, typ)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("valkey-store: invalid option %s: field %q declared twice", optMetadataFields, name)
+		}
+		seen[name] = true
+		fields = append(fields, metadataField{Name: name, Type: typ})
+	}
+	return fields, nil
+}
+
 // strOr returns the option value for key, or fallback when it is unset/empty.
 func strOr(o map[string]string, key, fallback string) string {
 	if v, ok := o[key]; ok && v != "" {
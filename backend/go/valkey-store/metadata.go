@@ -0,0 +1,236 @@
+package main
+
+// Entry metadata and the Find prefilter built from store.Filter.
+//
+// The whole metadata map is stored as JSON in the `meta` hash field and
+// returned by Get and Find. Fields declared with the metadata_fields option
+// are additionally written to `m_<name>` hash fields, which the FT index
+// covers as TAG (strings, booleans) or NUMERIC attributes. Filters are
+// translated into a Valkey Search query that the KNN runs on:
+//
+//	(@m_lang:{en | de} @m_year:[2020 +inf])=>[KNN 5 @vec $q AS __score]
+//
+// so the topK are the nearest *matching* entries, as with local-store.
+// Filtering on an undeclared field is an error rather than an empty result,
+// since Valkey cannot evaluate it.
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/mudler/LocalAI/pkg/store"
+)
+
+const (
+	// _metadataFieldPrefix prefixes the indexed copies of declared metadata
+	// fields so they can never collide with vec/val/meta.
+	_metadataFieldPrefix = "m_"
+
+	// _tagSeparator is the TAG SEPARATOR of the indexed fields. A value
+	// containing it would be split into several tags, so such values are
+	// rejected on Set.
+	_tagSeparator = ","
+)
+
+// metadataEntry is the hash representation of one entry's metadata.
+type metadataEntry struct {
+	json string
+	// indexed are the m_<name> field/value pairs to HSET.
+	indexed [][2]string
+	// absent are the m_<name> fields of declared fields the metadata lacks,
+	// removed so a previous value stops matching.
+	absent []string
+}
+
+// encodeMetadata validates md against the declared fields and builds its hash
+// representation. A declared field must hold a value its type can index.
+func (s *ValkeyStore) encodeMetadata(md store.Metadata) (metadataEntry, error) {
+	var e metadataEntry
+	if len(md) > 0 {
+		b, err := json.Marshal(md)
+		if err != nil {
+			return metadataEntry{}, err
+		}
+		e.json = string(b)
+	}
+	for _, f := range s.cfg.MetadataFields {
+		field := _metadataFieldPrefix + f.Name
+		v, ok := md[f.Name]
+		if !ok {
+			e.absent = append(e.absent, field)
+			continue
+		}
+		var enc string
+		var err error
+		if f.Type == metadataTag {
+			enc, err = tagValue(f.Name, v)
+			if err == nil && strings.Contains(enc, _tagSeparator) {
+				err = fmt.Errorf("tag field %q value %q contains %q", f.Name, enc, _tagSeparator)
+			}
+		} else {
+			enc, err = numericValue(f.Name, v)
+		}
+		if err != nil {
+			return metadataEntry{}, err
+		}
+		e.indexed = append(e.indexed, [2]string{field, enc})
+	}
+	return e, nil
+}
+
+// decodeMetadata parses the `meta` hash field. Entries without metadata (or
+// written before metadata support) decode to nil.
+func decodeMetadata(raw string) (store.Metadata, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	var md store.Metadata
+	if err := json.Unmarshal([]byte(raw), &md); err != nil {
+		return nil, fmt.Errorf("decode metadata: %w", err)
+	}
+	return md, nil
+}
+
+// metadataSchema returns the FT.CREATE SCHEMA tokens of the declared fields.
+func (s *ValkeyStore) metadataSchema() []string {
+	var args []string
+	for _, f := range s.cfg.MetadataFields {
+		field := _metadataFieldPrefix + f.Name
+		if f.Type == metadataTag {
+			args = append(args, field, "TAG", "SEPARATOR", _tagSeparator, "CASESENSITIVE")
+		} else {
+			args = append(args, field, "NUMERIC")
+		}
+	}
+	return args
+}
+
+// filterQuery translates filters into the KNN prefilter, "*" when there are
+// none. Field names come from the validated declaration, never from the
+// request, and tag values are escaped, so the result is safe to interpolate.
+func (s *ValkeyStore) filterQuery(filters []store.Filter) (string, error) {
+	if len(filters) == 0 {
+		return "*", nil
+	}
+	declared := make(map[string]string, len(s.cfg.MetadataFields))
+	for _, f := range s.cfg.MetadataFields {
+		declared[f.Name] = f.Type
+	}
+	parts := make([]string, 0, len(filters))
+	for _, f := range filters {
+		typ, ok := declared[f.Field]
+		if !ok {
+			return "", fmt.Errorf("filter on undeclared metadata field %q (add it to the %s option)", f.Field, optMetadataFields)
+		}
+		field := "@" + _metadataFieldPrefix + f.Field
+		var part string
+		var err error
+		if typ == metadataTag {
+			part, err = tagClause(field, f)
+		} else {
+			part, err = numericClause(field, f)
+		}
+		if err != nil {
+			return "", err
+		}
+		parts = append(parts, part)
+	}
+	return "(" + strings.Join(parts, " ") + ")", nil
+}
+
+func tagClause(field string, f store.Filter) (string, error) {
+	if f.Range != nil {
+		return "", fmt.Errorf("range filter on tag field %q", f.Field)
+	}
+	values := f.In
+	if f.Eq != nil {
+		values = []any{f.Eq}
+	}
+	escaped := make([]string, len(values))
+	for i, v := range values {
+		enc, err := tagValue(f.Field, v)
+		if err != nil {
+			return "", err
+		}
+		escaped[i] = escapeTag(enc)
+	}
+	return field + ":{" + strings.Join(escaped, " | ") + "}", nil
+}
+
+func numericClause(field string, f store.Filter) (string, error) {
+	if r := f.Range; r != nil {
+		// With both an inclusive and an exclusive bound on one side, the
+		// tighter one wins, as both must hold in store.Filter.Match.
+		lo, hi := "-inf", "+inf"
+		switch {
+		case r.Gt != nil && (r.Gte == nil || *r.Gt >= *r.Gte):
+			lo = "(" + formatNumber(*r.Gt)
+		case r.Gte != nil:
+			lo = formatNumber(*r.Gte)
+		}
+		switch {
+		case r.Lt != nil && (r.Lte == nil || *r.Lt <= *r.Lte):
+			hi = "(" + formatNumber(*r.Lt)
+		case r.Lte != nil:
+			hi = formatNumber(*r.Lte)
+		}
+		return fmt.Sprintf("%s:[%s %s]", field, lo, hi), nil
+	}
+	values := f.In
+	if f.Eq != nil {
+		values = []any{f.Eq}
+	}
+	clauses := make([]string, len(values))
+	for i, v := range values {
+		enc, err := numericValue(f.Field, v)
+		if err != nil {
+			return "", err
+		}
+		clauses[i] = fmt.Sprintf("%s:[%s %s]", field, enc, enc)
+	}
+	if len(clauses) == 1 {
+		return clauses[0], nil
+	}
+	return "(" + strings.Join(clauses, " | ") + ")", nil
+}
+
+// tagValue formats a string or boolean for a TAG field.
+func tagValue(name string, v any) (string, error) {
+	switch x := v.(type) {
+	case string:
+		return x, nil
+	case bool:
+		return strconv.FormatBool(x), nil
+	}
+	return "", fmt.Errorf("tag field %q: want a string or boolean, got %T", name, v)
+}
+
+// numericValue formats a number for a NUMERIC field.
+func numericValue(name string, v any) (string, error) {
+	x, ok := v.(float64)
+	if !ok || math.IsInf(x, 0) {
+		return "", fmt.Errorf("numeric field %q: want a finite number, got %v", name, v)
+	}
+	return formatNumber(x), nil
+}
+
+func formatNumber(x float64) string {
+	return strconv.FormatFloat(x, 'g', -1, 64)
+}
+
+// escapeTag backslash-escapes everything but letters, digits and '_', which
+// covers the tag query syntax characters (braces, '|', spaces, ...).
+func escapeTag(v string) string {
+	var b strings.Builder
+	b.Grow(len(v))
+	for _, r := range v {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_') {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -9,10 +9,16 @@ package main
 //
 //	prefix + hex(little-endian float32 bytes of the vector)
 //
-// with two fields: `vec` (the raw float32 bytes, indexed by a lazily-created
-// FT VECTOR index of the discovered dimension) and `val` (the opaque value
-// bytes). The vector-IS-the-key encoding (see encoding.go) makes Set an
-// HSET upsert, Get an HGET, Delete a DEL, and Find an FT.SEARCH KNN.
+// with the fields `vec` (the raw float32 bytes, indexed by a lazily-created
+// FT VECTOR index of the discovered dimension), `val` (the opaque value
+// bytes) and `meta` (the entry metadata as JSON). Metadata fields declared
+// with the metadata_fields option are also copied to `m_<name>` fields and
+// indexed as TAG/NUMERIC attributes, so Find can pre-filter on them (see
+// metadata.go). The vector-IS-the-key encoding (see encoding.go) makes Set an
+// HSET upsert, Get an HMGET, Delete a DEL, and Find an FT.SEARCH KNN.
+//
+// Request namespaces (StoresSetOptions.Namespace, ...) get their own key
+// prefix and index under the model's, each with its own dimension.
 //
 // Similarity — Valkey returns cosine *distance* (0 = identical, 2 = opposite),
 // while local-store returns cosine *similarity* (1 = identical, -1 = opposite).
@@ -44,9 +50,11 @@ import (
 )
 
 const (
-	// Hash field names. `vec` is the indexed vector; `val` is the opaque value.
-	_vecField = "vec"
-	_valField = "val"
+	// Hash field names. `vec` is the indexed vector; `val` is the opaque value;
+	// `meta` is the JSON metadata.
+	_vecField  = "vec"
+	_valField  = "val"
+	_metaField = "meta"
 	// _scoreField is the KNN distance alias produced by the query and returned
 	// by FT.SEARCH. Double-underscore avoids colliding with a stored field.
 	_scoreField = "__score"
@@ -77,6 +85,16 @@ type ValkeyStore struct {
 	client valkey.Client
 	cfg    Config
 
+	// model is the Load namespace the request namespaces are nested under.
+	model string
+	// collection is the default (empty) request namespace; namespaces holds
+	// the others, recovered from FT.INFO on first use.
+	collection
+	namespaces map[string]*collection
+}
+
+// collection is the keyspace and index of one request namespace.
+type collection struct {
 	// prefix is the per-namespace key prefix; indexName is the FT index name.
 	prefix    string
 	indexName string
@@ -91,7 +109,7 @@ type ValkeyStore struct {
 // NewValkeyStore returns a store with an open dimension and no index yet. The
 // Valkey client is established in Load once the connection config is known.
 func NewValkeyStore() *ValkeyStore {
-	return &ValkeyStore{keyLen: -1}
+	return &ValkeyStore{collection: collection{keyLen: -1}, namespaces: make(map[string]*collection)}
 }
 
 // newWithClient builds a store around an already-constructed client for a given
@@ -99,12 +117,38 @@ func NewValkeyStore() *ValkeyStore {
 // Valkey server; Load is the production path.
 func newWithClient(client valkey.Client, cfg Config, namespace string) *ValkeyStore {
 	return &ValkeyStore{
-		client:    client,
-		cfg:       cfg,
-		prefix:    keyPrefix(namespace),
-		indexName: indexName(namespace),
+		client: client,
+		cfg:    cfg,
+		model:  namespace,
+		collection: collection{
+			prefix:    keyPrefix(namespace),
+			indexName: indexName(namespace),
+			keyLen:    -1,
+		},
+		namespaces: make(map[string]*collection),
+	}
+}
+
+// namespace returns the collection of a request namespace. A namespace seen
+// for the first time is looked up with FT.INFO, so entries written by a
+// previous run are found again.
+func (s *ValkeyStore) namespace(ns string) *collection {
+	if ns == "" {
+		return &s.collection
+	}
+	if c, ok := s.namespaces[ns]; ok {
+		return c
+	}
+	c := &collection{
+		prefix:    nestedKeyPrefix(s.model, ns),
+		indexName: nestedIndexName(s.model, ns),
 		keyLen:    -1,
 	}
+	ctx, cancel := s.ctx()
+	defer cancel()
+	s.loadIndexState(ctx, c)
+	s.namespaces[ns] = c
+	return c
 }
 
 // Load reads the store config from the model config options, connects, and
@@ -133,8 +177,9 @@ func (s *ValkeyStore) Load(opts *pb.ModelOptions) error {
 	s.cfg = cfg
 
 	namespace := opts.Model
-	s.prefix = keyPrefix(namespace)
-	s.indexName = indexName(namespace)
+	s.model = namespace
+	s.collection = collection{prefix: keyPrefix(namespace), indexName: indexName(namespace), keyLen: -1}
+	s.namespaces = make(map[string]*collection)
 
 	clientOpt := valkey.ClientOption{
 		InitAddress: []string{cfg.Addr},
@@ -188,7 +233,7 @@ func (s *ValkeyStore) Load(opts *pb.ModelOptions) error {
 	// validates the incoming dimension against the real persisted DIM instead
 	// of silently re-learning a wrong one and dropping mismatched vectors from
 	// the index (which would return success while making the entry unsearchable).
-	s.loadIndexState(ctx)
+	s.loadIndexState(ctx, &s.collection)
 
 	// Log the sanitized index name (which identifies the namespace) rather than
 	// the raw model-derived namespace, which could carry control characters.
@@ -196,7 +241,8 @@ func (s *ValkeyStore) Load(opts *pb.ModelOptions) error {
 	return nil
 }
 
-// loadIndexState issues one FT.INFO at Load to recover the persisted index
+// loadIndexState issues one FT.INFO at Load (or on the first use of a request
+// namespace) to recover the persisted index
 // state. FT.INFO returns an error for an unknown index, so a successful reply
 // means the index exists (indexCreated=true). We then recover the vector
 // dimension from the reply and seed keyLen with it: without this, keyLen would
@@ -205,14 +251,14 @@ func (s *ValkeyStore) Load(opts *pb.ModelOptions) error {
 // FT never indexes (silent search-side data loss). If the dimension can't be
 // parsed (e.g. an unexpected FT.INFO layout on some server version), keyLen
 // is left at -1 and validation degrades to the pre-restart lazy behaviour.
-func (s *ValkeyStore) loadIndexState(ctx context.Context) {
-	msg, err := s.client.Do(ctx, s.client.B().FtInfo().Index(s.indexName).Build()).ToMessage()
+func (s *ValkeyStore) loadIndexState(ctx context.Context, c *collection) {
+	msg, err := s.client.Do(ctx, s.client.B().FtInfo().Index(c.indexName).Build()).ToMessage()
 	if err != nil {
 		return
 	}
-	s.indexCreated = true
+	c.indexCreated = true
 	if dim, ok := findDimensions(msg); ok && dim > 0 {
-		s.keyLen = dim
+		c.keyLen = dim
 	}
 }
 
@@ -321,27 +367,44 @@ func (s *ValkeyStore) ctx() (context.Context, context.CancelFunc) {
 func (s *ValkeyStore) StoresSet(opts *pb.StoresSetOptions) error {
 	keys := store.UnwrapKeys(opts.Keys)
 	values := store.UnwrapValues(opts.Values)
+	metadata := store.UnwrapMetadata(opts.Metadata)
 	if len(keys) == 0 {
 		return fmt.Errorf("valkey-store: Set: no keys to add")
 	}
 	if len(keys) != len(values) {
 		return fmt.Errorf("valkey-store: Set: len(keys) = %d, len(values) = %d", len(keys), len(values))
 	}
+	if len(metadata) != 0 && len(metadata) != len(keys) {
+		return fmt.Errorf("valkey-store: Set: len(keys) = %d, len(metadata) = %d", len(keys), len(metadata))
+	}
+	entries := make([]metadataEntry, len(keys))
+	for i := range keys {
+		var md store.Metadata
+		if len(metadata) != 0 {
+			md = metadata[i]
+		}
+		e, err := s.encodeMetadata(md)
+		if err != nil {
+			return fmt.Errorf("valkey-store: Set: metadata %d: %w", i, err)
+		}
+		entries[i] = e
+	}
+	c := s.namespace(opts.Namespace)
 
 	// Learn the dimension from the first key ever set (mirrors local-store's
 	// keyLen == -1 sentinel), then reject anything that disagrees. checkDims is
 	// the single source of truth for the per-key length check (shared with
 	// Get/Delete/Find) so the four RPCs cannot drift apart.
-	if s.keyLen == -1 {
-		s.keyLen = len(keys[0])
+	if c.keyLen == -1 {
+		c.keyLen = len(keys[0])
 	}
-	if err := s.checkDims("Set", keys); err != nil {
+	if err := c.checkDims("Set", keys); err != nil {
 		return err
 	}
 
 	// The index needs the dimension up front, but local-store learns it from
 	// the first Set — so we create it lazily here, once, before writing.
-	if err := s.ensureIndex(s.keyLen); err != nil {
+	if err := s.ensureIndex(c, c.keyLen); err != nil {
 		return err
 	}
 
@@ -361,14 +424,24 @@ func (s *ValkeyStore) StoresSet(opts *pb.StoresSetOptions) error {
 	// loop: an unbounded SetCols against a remote Valkey would otherwise exhaust
 	// a single aggregate deadline mid-batch and leave a partial, non-atomic
 	// write.
+	//
+	// Set replaces the metadata, so declared fields the new metadata lacks are
+	// removed with an HDEL — otherwise a stale m_<name> would keep matching.
 	for i, k := range keys {
-		cmd := s.client.B().Hset().Key(encodeKey(s.prefix, k)).
+		key := encodeKey(c.prefix, k)
+		hset := s.client.B().Hset().Key(key).
 			FieldValue().
 			FieldValue(_vecField, valkey.BinaryString(vecToBytes(k))).
 			FieldValue(_valField, valkey.BinaryString(values[i])).
-			Build()
+			FieldValue(_metaField, entries[i].json)
+		for _, f := range entries[i].indexed {
+			hset = hset.FieldValue(f[0], f[1])
+		}
 		ctx, cancel := s.ctx()
-		err := s.client.Do(ctx, cmd).Error()
+		err := s.client.Do(ctx, hset.Build()).Error()
+		if err == nil && len(entries[i].absent) > 0 {
+			err = s.client.Do(ctx, s.client.B().Hdel().Key(key).Field(entries[i].absent...).Build()).Error()
+		}
 		cancel()
 		if err != nil {
 			return fmt.Errorf("valkey-store: Set: HSET key %d: %w", i, err)
@@ -384,7 +457,8 @@ func (s *ValkeyStore) StoresGet(opts *pb.StoresGetOptions) (pb.StoresGetResult,
 	if len(keys) == 0 {
 		return pb.StoresGetResult{}, nil
 	}
-	if err := s.checkDims("Get", keys); err != nil {
+	c := s.namespace(opts.Namespace)
+	if err := c.checkDims("Get", keys); err != nil {
 		return pb.StoresGetResult{}, err
 	}
 
@@ -400,28 +474,47 @@ func (s *ValkeyStore) StoresGet(opts *pb.StoresGetOptions) (pb.StoresGetResult,
 
 	cmds := make([]valkey.Completed, len(keys))
 	for i, k := range keys {
-		cmds[i] = s.client.B().Hget().Key(encodeKey(s.prefix, k)).Field(_valField).Build()
+		cmds[i] = s.client.B().Hmget().Key(encodeKey(c.prefix, k)).Field(_valField, _metaField).Build()
 	}
 
 	var foundKeys [][]float32
 	var foundValues [][]byte
+	var foundMetadata []store.Metadata
 	for i, res := range s.client.DoMulti(ctx, cmds...) {
-		v, err := res.ToString()
+		fields, err := res.ToArray()
+		if err != nil {
+			return pb.StoresGetResult{}, fmt.Errorf("valkey-store: Get: HMGET key %d: %w", i, err)
+		}
+		v, err := fields[0].ToString()
 		if err != nil {
 			// A nil reply means the key/field is absent — omit it, don't error.
 			if valkey.IsValkeyNil(err) {
 				continue
 			}
-			return pb.StoresGetResult{}, fmt.Errorf("valkey-store: Get: HGET key %d: %w", i, err)
+			return pb.StoresGetResult{}, fmt.Errorf("valkey-store: Get: HMGET key %d: %w", i, err)
+		}
+		var md store.Metadata
+		if len(fields) > 1 {
+			// Entries written before metadata support have no meta field.
+			raw, _ := fields[1].ToString()
+			if md, err = decodeMetadata(raw); err != nil {
+				return pb.StoresGetResult{}, fmt.Errorf("valkey-store: Get: key %d: %w", i, err)
+			}
 		}
 		// The request vector is exact, so we return it verbatim as the key.
 		foundKeys = append(foundKeys, keys[i])
 		foundValues = append(foundValues, []byte(v))
+		foundMetadata = append(foundMetadata, md)
 	}
 
+	wrapped, err := store.WrapMetadata(foundMetadata)
+	if err != nil {
+		return pb.StoresGetResult{}, fmt.Errorf("valkey-store: Get: %w", err)
+	}
 	return pb.StoresGetResult{
-		Keys:   store.WrapKeys(foundKeys),
-		Values: store.WrapValues(foundValues),
+		Keys:     store.WrapKeys(foundKeys),
+		Values:   store.WrapValues(foundValues),
+		Metadata: wrapped,
 	}, nil
 }
 
@@ -432,7 +525,8 @@ func (s *ValkeyStore) StoresDelete(opts *pb.StoresDeleteOptions) error {
 	if len(keys) == 0 {
 		return fmt.Errorf("valkey-store: Delete: no keys to delete")
 	}
-	if err := s.checkDims("Delete", keys); err != nil {
+	c := s.namespace(opts.Namespace)
+	if err := c.checkDims("Delete", keys); err != nil {
 		return err
 	}
 
@@ -444,7 +538,7 @@ func (s *ValkeyStore) StoresDelete(opts *pb.StoresDeleteOptions) error {
 	// deadline mid-batch.
 	for i, k := range keys {
 		ctx, cancel := s.ctx()
-		err := s.client.Do(ctx, s.client.B().Del().Key(encodeKey(s.prefix, k)).Build()).Error()
+		err := s.client.Do(ctx, s.client.B().Del().Key(encodeKey(c.prefix, k)).Build()).Error()
 		cancel()
 		if err != nil {
 			return fmt.Errorf("valkey-store: Delete: DEL key %d: %w", i, err)
@@ -454,8 +548,10 @@ func (s *ValkeyStore) StoresDelete(opts *pb.StoresDeleteOptions) error {
 }
 
 // StoresFind returns the topK nearest entries by the configured distance
-// metric, ordered most-similar first. An empty/uncreated index returns empty
-// slices and no error, matching local-store's empty-store behaviour.
+// metric, ordered most-similar first. Filters are evaluated by Valkey Search
+// as a KNN pre-filter, so they can only name declared metadata fields. An
+// empty/uncreated index returns empty slices and no error, matching
+// local-store's empty-store behaviour.
 func (s *ValkeyStore) StoresFind(opts *pb.StoresFindOptions) (pb.StoresFindResult, error) {
 	// Guard against a malformed gRPC request with a nil/empty Key before
 	// dereferencing it — a nil opts.Key would otherwise panic the backend.
@@ -471,9 +567,18 @@ func (s *ValkeyStore) StoresFind(opts *pb.StoresFindOptions) (pb.StoresFindResul
 		xlog.Warn("valkey-store: Find topK clamped", "requested", topK, "max", _maxTopK)
 		topK = _maxTopK
 	}
+	filters, err := store.UnwrapFilters(opts.Filters)
+	if err != nil {
+		return pb.StoresFindResult{}, fmt.Errorf("valkey-store: Find: %w", err)
+	}
+	prefilter, err := s.filterQuery(filters)
+	if err != nil {
+		return pb.StoresFindResult{}, fmt.Errorf("valkey-store: Find: %w", err)
+	}
+	c := s.namespace(opts.Namespace)
 	// No index yet means nothing has been Set (and none was found at Load) —
 	// an empty result, not an error.
-	if !s.indexCreated {
+	if !c.indexCreated {
 		return pb.StoresFindResult{}, nil
 	}
 	// Enforce the query dimension against the known keyLen — recovered from
@@ -481,15 +586,15 @@ func (s *ValkeyStore) StoresFind(opts *pb.StoresFindOptions) (pb.StoresFindResul
 	// wrong-dimension query gets the clean local-store-style error. keyLen is
 	// only -1 in the degraded case where FT.INFO gave no parseable dimension;
 	// then we let Valkey's own FT.SEARCH validate the query vector.
-	if s.keyLen != -1 && len(query) != s.keyLen {
-		return pb.StoresFindResult{}, fmt.Errorf("valkey-store: Find: query length %d does not match existing %d", len(query), s.keyLen)
+	if c.keyLen != -1 && len(query) != c.keyLen {
+		return pb.StoresFindResult{}, fmt.Errorf("valkey-store: Find: query length %d does not match existing %d", len(query), c.keyLen)
 	}
 
 	ctx, cancel := s.ctx()
 	defer cancel()
 
-	// KNN pre-filter query: match everything, rank by vector distance into the
-	// __score alias. A pure KNN query already returns its topK results ordered
+	// KNN pre-filter query: match everything (or the metadata prefilter), rank
+	// by vector distance into the __score alias. A pure KNN query already returns its topK results ordered
 	// by distance ascending (nearest-first), so we do NOT add SORTBY __score:
 	// Valkey Search rejects sorting on the KNN score alias ("Index field
 	// `__score` does not exist" — it is a query-time computed field, not a
@@ -497,14 +602,14 @@ func (s *ValkeyStore) StoresFind(opts *pb.StoresFindOptions) (pb.StoresFindResul
 	// required for the =>[KNN ...] vector syntax. The __score field is still
 	// returned in each document and read back for the similarity conversion.
 	//
-	// Injection-safety: the only caller-controlled value interpolated here is
-	// topK (an int, already bounded above). _vecField and _scoreField are
-	// compile-time constants, so this Sprintf cannot be used to inject query
-	// syntax. Do NOT make those fields operator-configurable without sanitizing
-	// them first — the KNN query string is otherwise built only from constants.
-	q := fmt.Sprintf("*=>[KNN %d @%s $q AS %s]", topK, _vecField, _scoreField)
-	cmd := s.client.B().FtSearch().Index(s.indexName).Query(q).
-		Return("3").Identifier(_vecField).Identifier(_valField).Identifier(_scoreField).
+	// Injection-safety: the caller-controlled values interpolated here are
+	// topK (an int, already bounded above) and the prefilter, whose field names
+	// are validated at Load and whose tag values are escaped by filterQuery.
+	// _vecField and _scoreField are compile-time constants. Do NOT make those
+	// fields operator-configurable without sanitizing them first.
+	q := fmt.Sprintf("%s=>[KNN %d @%s $q AS %s]", prefilter, topK, _vecField, _scoreField)
+	cmd := s.client.B().FtSearch().Index(c.indexName).Query(q).
+		Return("4").Identifier(_vecField).Identifier(_valField).Identifier(_metaField).Identifier(_scoreField).
 		Limit().OffsetNum(0, int64(topK)).
 		Params().Nargs(2).NameValue().NameValue("q", valkey.VectorString32(query)).
 		Dialect(2).
@@ -519,7 +624,7 @@ func (s *ValkeyStore) StoresFind(opts *pb.StoresFindOptions) (pb.StoresFindResul
 		// it, rather than surfacing a hard error for what looks like an empty
 		// store to the caller.
 		if isNoSuchIndexErr(err) {
-			s.indexCreated = false
+			c.indexCreated = false
 			return pb.StoresFindResult{}, nil
 		}
 		return pb.StoresFindResult{}, fmt.Errorf("valkey-store: Find: FT.SEARCH: %w", err)
@@ -527,6 +632,7 @@ func (s *ValkeyStore) StoresFind(opts *pb.StoresFindOptions) (pb.StoresFindResul
 
 	keys := make([][]float32, 0, len(docs))
 	values := make([][]byte, 0, len(docs))
+	metadata := make([]store.Metadata, 0, len(docs))
 	sims := make([]float32, 0, len(docs))
 	for _, doc := range docs {
 		// Decode the key from the returned `vec` bytes rather than the Valkey
@@ -541,24 +647,35 @@ func (s *ValkeyStore) StoresFind(opts *pb.StoresFindOptions) (pb.StoresFindResul
 		if err != nil {
 			return pb.StoresFindResult{}, fmt.Errorf("valkey-store: Find: parse score %q: %w", doc.Doc[_scoreField], err)
 		}
+		md, err := decodeMetadata(doc.Doc[_metaField])
+		if err != nil {
+			return pb.StoresFindResult{}, fmt.Errorf("valkey-store: Find: %w", err)
+		}
 		keys = append(keys, k)
 		values = append(values, []byte(doc.Doc[_valField]))
+		metadata = append(metadata, md)
 		sims = append(sims, distanceToSimilarity(s.cfg.DistanceMetric, dist))
 	}
 
+	wrapped, err := store.WrapMetadata(metadata)
+	if err != nil {
+		return pb.StoresFindResult{}, fmt.Errorf("valkey-store: Find: %w", err)
+	}
 	return pb.StoresFindResult{
 		Keys:         store.WrapKeys(keys),
 		Values:       store.WrapValues(values),
 		Similarities: sims,
+		Metadata:     wrapped,
 	}, nil
 }
 
 // ensureIndex creates the FT vector index once, lazily, on the first Set. The
 // dimension is fixed at creation (a second guard on top of the Go-side keyLen
 // check). An "already exists" error is treated as success so a restart against
-// a persisted index is a no-op.
-func (s *ValkeyStore) ensureIndex(dim int) error {
-	if s.indexCreated {
+// a persisted index is a no-op — which also means metadata_fields declared
+// after the index was created are not indexed until it is dropped.
+func (s *ValkeyStore) ensureIndex(c *collection, dim int) error {
+	if c.indexCreated {
 		return nil
 	}
 
@@ -575,12 +692,13 @@ func (s *ValkeyStore) ensureIndex(dim int) error {
 	}
 
 	args := []string{
-		s.indexName,
+		c.indexName,
 		"ON", "HASH",
-		"PREFIX", "1", s.prefix,
+		"PREFIX", "1", c.prefix,
 		"SCHEMA", _vecField, "VECTOR", s.cfg.IndexAlgo, strconv.Itoa(len(attrs)),
 	}
 	args = append(args, attrs...)
+	args = append(args, s.metadataSchema()...)
 
 	// FT.CREATE has no typed builder entry point, so we use the Arbitrary escape
 	// hatch. All tokens are non-key args in standalone mode.
@@ -588,22 +706,22 @@ func (s *ValkeyStore) ensureIndex(dim int) error {
 	defer cancel()
 	err := s.client.Do(ctx, s.client.B().Arbitrary("FT.CREATE").Args(args...).Build()).Error()
 	if err != nil && !isIndexExistsErr(err) {
-		return fmt.Errorf("valkey-store: FT.CREATE %s: %w", s.indexName, err)
+		return fmt.Errorf("valkey-store: FT.CREATE %s: %w", c.indexName, err)
 	}
 
-	s.indexCreated = true
+	c.indexCreated = true
 	return nil
 }
 
 // checkDims rejects any key whose dimension disagrees with the learned keyLen.
 // When keyLen is still open (-1, nothing set yet) there is nothing to check.
-func (s *ValkeyStore) checkDims(op string, keys [][]float32) error {
-	if s.keyLen == -1 {
+func (c *collection) checkDims(op string, keys [][]float32) error {
+	if c.keyLen == -1 {
 		return nil
 	}
 	for i, k := range keys {
-		if len(k) != s.keyLen {
-			return fmt.Errorf("valkey-store: %s: key %d length %d does not match existing %d", op, i, len(k), s.keyLen)
+		if len(k) != c.keyLen {
+			return fmt.Errorf("valkey-store: %s: key %d length %d does not match existing %d", op, i, len(k), c.keyLen)
 		}
 	}
 	return nil
@@ -651,6 +769,18 @@ func indexName(namespace string) string {
 	return _indexPrefix + nsToken(namespace)
 }
 
+// nestedKeyPrefix / nestedIndexName derive the identifiers of a request
+// namespace within a model's. The '@' separator never appears in a token, so a
+// nested prefix never matches the model's default PREFIX (which continues with
+// ':') and the two indexes never see each other's entries.
+func nestedKeyPrefix(model, namespace string) string {
+	return _keyPrefixPrefix + nsToken(model) + "@" + nsToken(namespace) + ":"
+}
+
+func nestedIndexName(model, namespace string) string {
+	return _indexPrefix + nsToken(model) + "@" + nsToken(namespace)
+}
+
 // nsToken maps a namespace to a collision-resistant, printable token. sanitize()
 // alone is lossy (many distinct characters all fold to '_'), so namespaces like
 // "a b", "a/b" and "a:b" would otherwise share one keyspace and FT index — a
@@ -254,8 +254,8 @@ var _ = Describe("StoresGet", func() {
 		s.indexCreated = true
 		// First key present, second missing (nil reply).
 		c.EXPECT().DoMulti(gomock.Any(), gomock.Any(), gomock.Any()).Return([]valkey.ValkeyResult{
-			mock.Result(mock.ValkeyString("hello")),
-			mock.Result(mock.ValkeyNil()),
+			mock.Result(mock.ValkeyArray(mock.ValkeyString("hello"), mock.ValkeyString(`{"lang":"en"}`))),
+			mock.Result(mock.ValkeyArray(mock.ValkeyNil(), mock.ValkeyNil())),
 		}).Times(1)
 
 		res, err := s.StoresGet(&pb.StoresGetOptions{
@@ -265,6 +265,7 @@ var _ = Describe("StoresGet", func() {
 		Expect(res.Keys).To(HaveLen(1))
 		Expect(res.Values).To(HaveLen(1))
 		Expect(res.Values[0].Bytes).To(Equal([]byte("hello")))
+		Expect(store.UnwrapMetadata(res.Metadata)).To(Equal([]store.Metadata{{"lang": "en"}}))
 	})
 
 	It("rejects dimension mismatch", func() {
@@ -381,18 +382,140 @@ var _ = Describe("ensureIndex arg-shape", func() {
 				Expect(toks).To(ContainElements("HNSW", "M", "16", "EF_CONSTRUCTION", "200", "EF_RUNTIME", "10"))
 				return mock.Result(mock.ValkeyString("OK"))
 			}).Times(1)
-		Expect(s.ensureIndex(4)).To(Succeed())
+		Expect(s.ensureIndex(&s.collection, 4)).To(Succeed())
 	})
 
 	It("treats an already-exists error as success", func() {
 		s, c := newMockStore(testCfg())
 		c.EXPECT().Do(gomock.Any(), gomock.Any()).Return(
 			mock.ErrorResult(fmt.Errorf("Index already exists"))).Times(1)
-		Expect(s.ensureIndex(4)).To(Succeed())
+		Expect(s.ensureIndex(&s.collection, 4)).To(Succeed())
 		Expect(s.indexCreated).To(BeTrue())
 	})
 })
 
+var _ = Describe("Namespaces and metadata", func() {
+	metadataCfg := func() Config {
+		cfg, err := loadConfig(opts("metadata_fields:lang:tag,year:numeric"))
+		Expect(err).NotTo(HaveOccurred())
+		return cfg
+	}
+	findQuery := func(s *ValkeyStore, c *mock.Client, filters ...store.Filter) (string, error) {
+		var query string
+		c.EXPECT().Do(gomock.Any(), gomock.Any()).DoAndReturn(
+			func(_ context.Context, cmd valkey.Completed) valkey.ValkeyResult {
+				query = cmd.Commands()[2]
+				return mock.Result(ftSearchReply(s.prefix, nil))
+			}).MaxTimes(1)
+		wrapped, err := store.WrapFilters(filters)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = s.StoresFind(&pb.StoresFindOptions{Key: &pb.StoresKey{Floats: []float32{1, 0, 0}}, TopK: 5, Filters: wrapped})
+		return query, err
+	}
+	ptr := func(f float64) *float64 { return &f }
+
+	It("parses and validates metadata_fields", func() {
+		Expect(metadataCfg().MetadataFields).To(Equal([]metadataField{{Name: "lang", Type: "tag"}, {Name: "year", Type: "numeric"}}))
+		_, err := loadConfig(opts("metadata_fields:la ng:tag"))
+		Expect(err).To(MatchError(ContainSubstring("field name")))
+		_, err = loadConfig(opts("metadata_fields:lang:text"))
+		Expect(err).To(MatchError(ContainSubstring("want tag or numeric")))
+	})
+
+	It("keeps request namespaces under the model's prefix without overlapping it", func() {
+		Expect(nestedKeyPrefix("m", "tenant")).NotTo(HavePrefix(keyPrefix("m")))
+		Expect(nestedKeyPrefix("m", "tenant")).To(HavePrefix(_keyPrefixPrefix + nsToken("m")))
+		Expect(nestedIndexName("m", "a")).NotTo(Equal(nestedIndexName("m", "b")))
+
+		s, c := newMockStore(testCfg())
+		var infos int
+		c.EXPECT().Do(gomock.Any(), gomock.Any()).DoAndReturn(
+			func(_ context.Context, cmd valkey.Completed) valkey.ValkeyResult {
+				toks := cmd.Commands()
+				switch toks[0] {
+				case "FT.INFO":
+					infos++
+					Expect(toks[1]).To(Equal(nestedIndexName(testNamespace, "tenant")))
+					return mock.ErrorResult(fmt.Errorf("Index with name '%s' not found", toks[1]))
+				case "FT.CREATE":
+					Expect(toks).To(ContainElements("PREFIX", "1", nestedKeyPrefix(testNamespace, "tenant")))
+					return mock.Result(mock.ValkeyString("OK"))
+				case "HSET":
+					Expect(toks[1]).To(HavePrefix(nestedKeyPrefix(testNamespace, "tenant")))
+					return mock.Result(mock.ValkeyInt64(1))
+				}
+				Fail("unexpected command: " + toks[0])
+				return valkey.ValkeyResult{}
+			}).AnyTimes()
+		set := wrapSet([][]float32{{1, 0}}, [][]byte{[]byte("a")})
+		set.Namespace = "tenant"
+		Expect(s.StoresSet(set)).To(Succeed())
+		Expect(s.StoresSet(set)).To(Succeed())
+		Expect(infos).To(Equal(1), "the namespace state is probed once")
+		Expect(s.namespaces["tenant"].keyLen).To(Equal(2))
+		Expect(s.keyLen).To(Equal(-1), "the default namespace is untouched")
+	})
+
+	It("indexes declared fields and writes the metadata JSON", func() {
+		s, c := newMockStore(metadataCfg())
+		var hset, hdel []string
+		c.EXPECT().Do(gomock.Any(), gomock.Any()).DoAndReturn(
+			func(_ context.Context, cmd valkey.Completed) valkey.ValkeyResult {
+				toks := cmd.Commands()
+				switch toks[0] {
+				case "FT.CREATE":
+					Expect(toks).To(ContainElements("m_lang", "TAG", "SEPARATOR", ",", "CASESENSITIVE", "m_year", "NUMERIC"))
+					return mock.Result(mock.ValkeyString("OK"))
+				case "HSET":
+					hset = toks
+				case "HDEL":
+					hdel = toks
+				}
+				return mock.Result(mock.ValkeyInt64(1))
+			}).AnyTimes()
+		set := wrapSet([][]float32{{1, 0, 0}}, [][]byte{[]byte("a")})
+		set.Metadata, _ = store.WrapMetadata([]store.Metadata{{"lang": "en", "note": "free text"}})
+		Expect(s.StoresSet(set)).To(Succeed())
+		Expect(hset).To(ContainElements("meta", "m_lang", "en"))
+		Expect(hset).NotTo(ContainElement("m_year"))
+		Expect(hdel[2:]).To(Equal([]string{"m_year"}), "a declared field absent from the metadata is removed")
+
+		set.Metadata, _ = store.WrapMetadata([]store.Metadata{{"lang": "en,de"}})
+		Expect(s.StoresSet(set)).To(MatchError(ContainSubstring("contains")))
+		set.Metadata, _ = store.WrapMetadata([]store.Metadata{{"year": "2024"}})
+		Expect(s.StoresSet(set)).To(MatchError(ContainSubstring("want a finite number")))
+	})
+
+	It("translates filters into the KNN prefilter", func() {
+		s, c := newMockStore(metadataCfg())
+		s.keyLen = 3
+		s.indexCreated = true
+		q, err := findQuery(s, c,
+			store.Filter{Field: "lang", In: []any{"en", "pt br"}},
+			store.Filter{Field: "year", Range: &store.Range{Gte: ptr(2020), Lt: ptr(2025)}})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(q).To(Equal(`(@m_lang:{en | pt\ br} @m_year:[2020 (2025])=>[KNN 5 @vec $q AS __score]`))
+
+		q, err = findQuery(s, c, store.Filter{Field: "year", In: []any{2023, 2024}})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(q).To(HavePrefix(`((@m_year:[2023 2023] | @m_year:[2024 2024]))=>`))
+
+		q, err = findQuery(s, c)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(q).To(HavePrefix("*=>"))
+	})
+
+	It("rejects filters Valkey cannot evaluate", func() {
+		s, c := newMockStore(metadataCfg())
+		s.keyLen = 3
+		s.indexCreated = true
+		_, err := findQuery(s, c, store.Filter{Field: "author", Eq: "me"})
+		Expect(err).To(MatchError(ContainSubstring("undeclared metadata field")))
+		_, err = findQuery(s, c, store.Filter{Field: "lang", Range: &store.Range{Gt: ptr(1)}})
+		Expect(err).To(MatchError(ContainSubstring("range filter on tag field")))
+	})
+})
+
 var _ = Describe("distanceToSimilarity", func() {
 	It("converts cosine distance to similarity", func() {
 		Expect(distanceToSimilarity(distanceCosine, 0)).To(Equal(float32(1)))
@@ -439,7 +562,7 @@ var _ = Describe("loadIndexState", func() {
 
 		ctx, cancel := s.ctx()
 		defer cancel()
-		s.loadIndexState(ctx)
+		s.loadIndexState(ctx, &s.collection)
 		Expect(s.indexCreated).To(BeTrue())
 		Expect(s.keyLen).To(Equal(768))
 	})
@@ -451,7 +574,7 @@ var _ = Describe("loadIndexState", func() {
 
 		ctx, cancel := s.ctx()
 		defer cancel()
-		s.loadIndexState(ctx)
+		s.loadIndexState(ctx, &s.collection)
 		Expect(s.indexCreated).To(BeFalse())
 		Expect(s.keyLen).To(Equal(-1))
 	})
@@ -463,7 +586,7 @@ var _ = Describe("loadIndexState", func() {
 
 		ctx, cancel := s.ctx()
 		defer cancel()
-		s.loadIndexState(ctx)
+		s.loadIndexState(ctx, &s.collection)
 		Expect(s.indexCreated).To(BeTrue())
 		Expect(s.keyLen).To(Equal(-1))
 	})
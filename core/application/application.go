@@ -3,6 +3,7 @@ package application
 import (
 	"cmp"
 	"context"
+	"errors"
 	"math/rand/v2"
 	"path/filepath"
 	"sync"
@@ -14,9 +15,11 @@ import (
 	"github.com/mudler/LocalAI/core/http/auth"
 	mcpTools "github.com/mudler/LocalAI/core/http/endpoints/mcp"
 	"github.com/mudler/LocalAI/core/services/agentpool"
+	"github.com/mudler/LocalAI/core/services/agents"
 	"github.com/mudler/LocalAI/core/services/cloudproxy/mitm"
 	"github.com/mudler/LocalAI/core/services/facerecognition"
 	"github.com/mudler/LocalAI/core/services/galleryop"
+	"github.com/mudler/LocalAI/core/services/jobs"
 	"github.com/mudler/LocalAI/core/services/monitoring"
 	"github.com/mudler/LocalAI/core/services/nodes"
 	"github.com/mudler/LocalAI/core/services/routing/admission"
@@ -24,6 +27,7 @@ import (
 	"github.com/mudler/LocalAI/core/services/routing/corpus"
 	"github.com/mudler/LocalAI/core/services/routing/pii"
 	"github.com/mudler/LocalAI/core/services/routing/piidetector"
+	"github.com/mudler/LocalAI/core/services/routing/responsecache"
 	"github.com/mudler/LocalAI/core/services/routing/router"
 	"github.com/mudler/LocalAI/core/services/voiceprofile"
 	"github.com/mudler/LocalAI/core/services/voicerecognition"
@@ -66,6 +70,7 @@ type Application struct {
 	voiceProfileStore  *voiceprofile.Store
 	authDB             *gorm.DB
 	metricsService     *monitoring.LocalAIMetricsService
+	tracingShutdown    func(context.Context) error
 	statsRecorder      *billing.Recorder
 	fallbackUser       *auth.User
 	piiRedactor        *pii.Redactor
@@ -82,6 +87,7 @@ type Application struct {
 	routerRegistry    *router.Registry
 	routerCorpus      *corpus.Manager
 	admissionLimiter  *admission.Limiter
+	responseCache     *responsecache.Cache
 	watchdogMutex     sync.Mutex
 	watchdogStop      chan bool
 	p2pMutex          sync.Mutex
@@ -478,6 +484,12 @@ func (a *Application) AdmissionLimiter() *admission.Limiter {
 	return a.admissionLimiter
 }
 
+// ResponseCache returns the process-wide response cache of the chat and
+// completion endpoints. Only models with a response_cache block use it.
+func (a *Application) ResponseCache() *responsecache.Cache {
+	return a.responseCache
+}
+
 // StartupConfig returns the original startup configuration (from env vars, before file loading)
 func (a *Application) StartupConfig() *config.ApplicationConfig {
 	return a.startupConfig
@@ -512,6 +524,15 @@ func (a *Application) Shutdown() error {
 				err = closeErr
 			}
 		}
+		if a.tracingShutdown != nil {
+			// Flush the spans of the requests that were in flight; bounded
+			// so an unreachable collector cannot hold up exit.
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			if flushErr := a.tracingShutdown(ctx); flushErr != nil {
+				xlog.Warn("Failed to flush OpenTelemetry spans", "error", flushErr)
+			}
+			cancel()
+		}
 	})
 	return err
 }
@@ -682,6 +703,18 @@ func (a *Application) StartAgentPool() {
 	if d := a.Distributed(); d != nil {
 		usm.SetJobSyncNATS(d.Nats)
 	}
+	// Fire job_completed agent triggers. Every frontend sees the job results;
+	// the agent scheduler fires each job once.
+	if d := a.Distributed(); d != nil && d.Dispatcher != nil {
+		d.Dispatcher.AddResultHook(func(job *jobs.JobRecord) {
+			evt := agents.JobTriggerEvent(job.UserID, job.TaskID, job.ID, job.Status, job.Result, job.Error)
+			go func() {
+				if _, err := aps.TriggerAgentsForUser(a.applicationConfig.Context, evt); err != nil && !errors.Is(err, agentpool.ErrSchedulingUnavailable) {
+					xlog.Warn("Failed to fire agent job triggers", "job_id", job.ID, "error", err)
+				}
+			}()
+		})
+	}
 	aps.SetUserServicesManager(usm)
 
 	a.agentPoolService.Store(aps)
@@ -70,7 +70,7 @@ func (a *Application) StartP2P() error {
 
 		// Here a new node is created and started
 		// and a service is exposed by the node
-		node, err := p2p.ExposeService(ctx, "localhost", port, a.applicationConfig.P2PToken, p2p.NetworkID(networkID, p2p.FederatedID))
+		node, err := p2p.ExposeService(ctx, "localhost", port, a.applicationConfig.P2PToken, p2p.NetworkID(networkID, p2p.FederatedID), a.advertiseModels)
 		if err != nil {
 			return err
 		}
@@ -138,6 +138,20 @@ func (a *Application) StartP2P() error {
 	return nil
 }
 
+// advertiseModels publishes the models this instance serves and its load, so
+// a model-aware federated server can route requests here.
+func (a *Application) advertiseModels(nd *schema.NodeData) {
+	for _, cfg := range a.ModelConfigLoader().GetAllModelsConfigs() {
+		nd.Models = append(nd.Models, cfg.Name)
+	}
+	for _, m := range a.ModelLoader().ListLoadedModels() {
+		nd.LoadedModels = append(nd.LoadedModels, m.ID)
+		if m.IsBusy() {
+			nd.Busy++
+		}
+	}
+}
+
 // RestartP2P restarts the P2P stack with current ApplicationConfig settings
 // Note: This method signals that P2P should be restarted, but the actual restart
 // is handled by the caller to avoid import cycles
@@ -21,6 +21,7 @@ import (
 	"github.com/mudler/LocalAI/core/services/routing/admission"
 	"github.com/mudler/LocalAI/core/services/routing/billing"
 	"github.com/mudler/LocalAI/core/services/routing/pii"
+	"github.com/mudler/LocalAI/core/services/routing/responsecache"
 	"github.com/mudler/LocalAI/core/services/routing/router"
 	"github.com/mudler/LocalAI/core/services/storage"
 	coreStartup "github.com/mudler/LocalAI/core/startup"
@@ -29,6 +30,7 @@ import (
 	"github.com/mudler/LocalAI/pkg/downloader"
 	"github.com/mudler/LocalAI/pkg/modelartifacts"
 	"github.com/mudler/LocalAI/pkg/signals"
+	"github.com/mudler/LocalAI/pkg/tracing"
 	"github.com/mudler/LocalAI/pkg/vram"
 
 	"github.com/mudler/LocalAI/pkg/model"
@@ -193,6 +195,21 @@ func New(opts ...config.AppOption) (*Application, error) {
 			// metrics service exports. Without this, billing's counters
 			// resolve via the OTel global and never reach /metrics.
 			billing.SetMeter(ms.Meter)
+			admission.SetMeter(ms.Meter)
+			responsecache.SetMeter(ms.Meter)
+		}
+	}
+
+	// OpenTelemetry span export. The propagator is installed even with no
+	// collector configured, so incoming trace context still reaches the
+	// backends. Like metrics, this runs before anything creates a tracer.
+	tracingShutdown, err := tracing.Setup(options.Context, options.OTelTracing)
+	if err != nil {
+		xlog.Error("failed to initialize OpenTelemetry tracing", "error", err)
+	} else {
+		application.tracingShutdown = tracingShutdown
+		if options.OTelTracing.Enabled() {
+			xlog.Info("OpenTelemetry trace export enabled", "endpoint", options.OTelTracing.Endpoint, "protocol", options.OTelTracing.Protocol)
 		}
 	}
 
@@ -219,7 +236,7 @@ func New(opts ...config.AppOption) (*Application, error) {
 			xlog.Info("stats: using in-memory ring buffer (no-auth single-user mode)")
 		}
 		application.fallbackUser = billing.LocalUser(options.DataPath)
-		application.statsRecorder = billing.NewRecorder(statsBackend)
+		application.statsRecorder = billing.NewRecorder(statsBackend, billing.WithPrices(billing.ModelConfigPrices(application.backendLoader)))
 		// Drain pending records on SIGTERM. The GORM backend buffers up
 		// to maxPending (5k) records across a 5s flush tick, so without
 		// this the last few seconds of usage disappear on graceful exit.
@@ -236,15 +253,37 @@ func New(opts ...config.AppOption) (*Application, error) {
 	// (pii.detectors → the detector model's pii_detection policy), run
 	// request-side by the chat middleware and the MITM input path. The
 	// regex tier was removed; redaction is opt-in per model via
-	// PIIIsEnabled(). The event store backs the /api/pii/events audit log.
+	// PIIIsEnabled(). The event store backs the /api/pii/events audit log:
+	// persisted to the auth DB when there is one so the trail survives
+	// restarts, a ring buffer otherwise.
 	application.piiRedactor = &pii.Redactor{}
 	application.piiEvents = pii.NewMemoryEventStore(0)
+	if application.authDB != nil {
+		if store, err := pii.NewGormEventStore(options.Context, application.authDB, options.AuditLogRetention); err != nil {
+			xlog.Error("pii: failed to open the event table, keeping events in memory", "error", err)
+		} else {
+			application.piiEvents = store
+			signals.RegisterGracefulTerminationHandler(func() {
+				_ = store.Close()
+			})
+		}
+	}
 
 	// Wire the routing decision log. Always-on when stats are enabled —
 	// the per-router admin page reads this as the live activity feed
 	// and as input to drift checks for subsystem 5.
 	if !options.DisableStats {
 		application.routerDecisions = router.NewMemoryDecisionStore(0)
+		if application.authDB != nil {
+			if store, err := router.NewGormDecisionStore(options.Context, application.authDB, options.AuditLogRetention); err != nil {
+				xlog.Error("router: failed to open the decision table, keeping decisions in memory", "error", err)
+			} else {
+				application.routerDecisions = store
+				signals.RegisterGracefulTerminationHandler(func() {
+					_ = store.Close()
+				})
+			}
+		}
 	}
 	// Process-wide classifier cache shared across all route middlewares so
 	// the embedding-cache stats endpoint sees a single source of truth.
@@ -254,6 +293,7 @@ func New(opts ...config.AppOption) (*Application, error) {
 	// model that gains a limits: block via gallery install or YAML
 	// edit takes effect on the next restart without conditional plumbing.
 	application.admissionLimiter = admission.New()
+	application.responseCache = responsecache.New()
 
 	// Wire JobStore for DB-backed task/job persistence whenever auth DB is available.
 	// This ensures tasks and jobs survive restarts in both single-node and distributed modes.
@@ -22,7 +22,10 @@ import (
 	"github.com/mudler/LocalAI/pkg/distributedhdr"
 	"github.com/mudler/LocalAI/pkg/grpc/proto"
 	model "github.com/mudler/LocalAI/pkg/model"
+	"github.com/mudler/LocalAI/pkg/tracing"
 	"github.com/mudler/LocalAI/pkg/utils"
+	"go.opentelemetry.io/otel/attribute"
+	oteltrace "go.opentelemetry.io/otel/trace"
 )
 
 type LLMResponse struct {
@@ -173,7 +176,7 @@ func ModelInference(ctx context.Context, s string, messages schema.Messages, ima
 
 	// in GRPC, the backend is supposed to answer to 1 single token if stream is not supported
 	var capturedPredictOpts *proto.PredictOptions
-	fn := func() (LLMResponse, error) {
+	predict := func(ctx context.Context) (LLMResponse, error) {
 		opts := gRPCPredictOpts(*c, loader.ModelPath)
 		// Merge request-level metadata (overrides config defaults)
 		for k, v := range metadata {
@@ -347,6 +350,26 @@ func ModelInference(ctx context.Context, s string, messages schema.Messages, ima
 		}
 	}
 
+	// Token generation is a span of its own, carrying the usage and the
+	// backend's timings, so a trace separates it from model load and
+	// queueing. The Predict RPCs run under its context.
+	fn := func() (LLMResponse, error) {
+		genCtx, span := tracing.Start(ctx, "llm.generate", oteltrace.WithAttributes(
+			attribute.String("localai.model", c.Name),
+			attribute.String("localai.backend", c.Backend),
+			attribute.Bool("localai.streaming", tokenCallback != nil),
+		))
+		resp, err := predict(genCtx)
+		span.SetAttributes(
+			attribute.Int("localai.usage.prompt_tokens", resp.Usage.Prompt),
+			attribute.Int("localai.usage.completion_tokens", resp.Usage.Completion),
+			attribute.Float64("localai.timing.prompt_processing_ms", resp.Usage.TimingPromptProcessing),
+			attribute.Float64("localai.timing.token_generation_ms", resp.Usage.TimingTokenGeneration),
+		)
+		tracing.End(span, err)
+		return resp, err
+	}
+
 	if o.EnableTracing {
 		trace.InitBackendTracingIfEnabled(o.TracingMaxItems, o.TracingMaxBodyBytes)
 
@@ -196,10 +196,20 @@ func (cmd *AgentWorkerCMD) Run(ctx *cliContext.Context) error {
 		return fmt.Errorf("starting dispatcher: %w", err)
 	}
 
+	// MCP client capabilities: the worker loads no models and keeps no
+	// OAuth credentials, so sampling requests of MCP servers and the tokens
+	// of OAuth-protected servers go through the LocalAI API; elicitation
+	// answers arrive over NATS.
+	mcpTools.SetSampler(mcpTools.NewAPISampler(apiURL, cmd.APIToken))
+	mcpTools.UseOAuthAPI(apiURL, cmd.APIToken)
+	if err := mcpTools.EnableDistributed(natsClient); err != nil {
+		return fmt.Errorf("enabling distributed MCP client capabilities: %w", err)
+	}
+
 	// Subscribe to MCP tool execution requests (load-balanced across workers).
 	// The frontend routes model-level MCP tool calls here via NATS request-reply.
 	if _, err := natsClient.QueueSubscribeReply(messaging.SubjectMCPToolExecute, messaging.QueueAgentWorkers, func(data []byte, reply func([]byte)) {
-		handleMCPToolRequest(data, reply)
+		handleMCPToolRequest(natsClient, data, reply)
 	}); err != nil {
 		return fmt.Errorf("subscribing to %s: %w", messaging.SubjectMCPToolExecute, err)
 	}
@@ -265,7 +275,8 @@ func (cmd *AgentWorkerCMD) Run(ctx *cliContext.Context) error {
 
 // handleMCPToolRequest handles a NATS request-reply for MCP tool execution.
 // The worker creates/caches MCP sessions from the serialized config and executes the tool.
-func handleMCPToolRequest(data []byte, reply func([]byte)) {
+// Elicitation requests of the tool's server are forwarded to the frontend when it asked for them.
+func handleMCPToolRequest(natsClient mcpTools.MCPNATSClient, data []byte, reply func([]byte)) {
 	var req mcpRemote.MCPToolRequest
 	if err := json.Unmarshal(data, &req); err != nil {
 		sendMCPToolReply(reply, "", fmt.Sprintf("unmarshal error: %v", err))
@@ -274,6 +285,12 @@ func handleMCPToolRequest(data []byte, reply func([]byte)) {
 
 	ctx, cancel := context.WithTimeout(context.Background(), config.DefaultMCPToolTimeout)
 	defer cancel()
+	if req.ElicitationSubject != "" {
+		ctx = mcpTools.WithElicitationHandler(ctx, mcpTools.ForwardElicitation(natsClient, req.ElicitationSubject))
+	}
+	if req.CallerID != "" {
+		ctx = mcpTools.WithCallerID(ctx, req.CallerID)
+	}
 
 	// Create/cache named MCP sessions from the provided config
 	namedSessions, err := mcpTools.NamedSessionsFromMCPConfig(req.ModelName, req.RemoteServers, req.StdioServers, nil)
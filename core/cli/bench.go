@@ -0,0 +1,198 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	cliContext "github.com/mudler/LocalAI/core/cli/context"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/gallery"
+	"github.com/mudler/LocalAI/core/services/bench"
+	"github.com/mudler/LocalAI/internal"
+	"github.com/mudler/LocalAI/pkg/model"
+	"github.com/mudler/LocalAI/pkg/system"
+	"github.com/mudler/LocalAI/pkg/vram"
+	"github.com/mudler/xlog"
+)
+
+type BenchCMD struct {
+	Model string `arg:"" name:"model" help:"Name of the installed model to benchmark"`
+
+	Mode         string   `default:"auto" enum:"auto,llm,embeddings,tts,transcription" help:"Workload to run; auto picks it from the model usecases [${enum}]"`
+	Requests     int      `short:"n" default:"20" help:"Number of measured requests"`
+	Concurrency  int      `short:"c" default:"1" help:"Number of requests in flight at any time"`
+	Warmup       int      `default:"1" help:"Requests to run before measuring, to warm up caches"`
+	Prompts      string   `type:"existingfile" help:"File with one prompt (or text to synthesize) per line"`
+	InputTokens  int      `default:"128" help:"Approximate length of the generated prompts, in tokens; ignored with --prompts"`
+	OutputTokens int      `default:"128" help:"Tokens generated by each LLM request, ignoring end of stream (0 keeps the model default)"`
+	Audio        []string `type:"existingfile" help:"Audio files to transcribe (transcription workload)"`
+	Voice        string   `help:"Voice to synthesize with (tts workload)"`
+	Language     string   `short:"l" help:"Language of the speech (tts and transcription workloads)"`
+	Output       string   `short:"o" type:"path" help:"Write the JSON report to this file, for comparing runs"`
+
+	BackendsPath string `env:"LOCALAI_BACKENDS_PATH,BACKENDS_PATH" type:"path" default:"${basepath}/backends" help:"Path containing backends used for inferencing" group:"storage"`
+	ModelsPath   string `env:"LOCALAI_MODELS_PATH,MODELS_PATH" type:"path" default:"${basepath}/models" help:"Path containing models used for inferencing" group:"storage"`
+}
+
+func (b *BenchCMD) Run(ctx *cliContext.Context) error {
+	if b.Requests <= 0 {
+		return errors.New("--requests must be positive")
+	}
+
+	systemState, err := system.GetSystemState(
+		system.WithBackendPath(b.BackendsPath),
+		system.WithModelPath(b.ModelsPath),
+	)
+	if err != nil {
+		return err
+	}
+
+	outputDir, err := os.MkdirTemp("", "localai-bench")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(outputDir)
+
+	runCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	opts := &config.ApplicationConfig{
+		SystemState:         systemState,
+		Context:             runCtx,
+		GeneratedContentDir: outputDir,
+	}
+
+	cl := config.NewModelConfigLoader(b.ModelsPath)
+	ml := model.NewModelLoader(systemState)
+
+	if err := gallery.RegisterBackends(systemState, ml); err != nil {
+		xlog.Error("error registering external backends", "error", err)
+	}
+
+	if err := cl.LoadModelConfigsFromPath(b.ModelsPath); err != nil {
+		return err
+	}
+
+	cfg, exists := cl.GetModelConfig(b.Model)
+	if !exists {
+		return fmt.Errorf("model %q not found. Run 'local-ai models list-installed' to see the installed models", b.Model)
+	}
+
+	mode := b.Mode
+	if mode == "auto" {
+		mode = bench.DetectMode(&cfg)
+	}
+	if mode == bench.ModeTranscription && cfg.Backend == "" {
+		cfg.Backend = model.WhisperBackend
+	}
+
+	settings := bench.Settings{
+		Options: bench.Options{Requests: b.Requests, Concurrency: b.Concurrency, Warmup: b.Warmup},
+	}
+	var inputs []string
+	switch {
+	case mode == bench.ModeTranscription:
+		if len(b.Audio) == 0 {
+			return errors.New("the transcription workload needs --audio files")
+		}
+		inputs = b.Audio
+	case b.Prompts != "":
+		if inputs, err = bench.ReadPrompts(b.Prompts); err != nil {
+			return err
+		}
+	default:
+		inputs = bench.SyntheticPrompts(b.Warmup+b.Requests, b.InputTokens)
+		settings.InputTokens = b.InputTokens
+	}
+	settings.Prompts = len(inputs)
+
+	rt := bench.Runtime{Loader: ml, Configs: cl, App: opts}
+	var task bench.Task
+	switch mode {
+	case bench.ModeLLM:
+		task = bench.LLMTask(rt, cfg, inputs, b.OutputTokens)
+		settings.OutputTokens = b.OutputTokens
+	case bench.ModeEmbeddings:
+		task = bench.EmbeddingsTask(rt, cfg, inputs)
+	case bench.ModeTTS:
+		task = bench.TTSTask(rt, cfg, inputs, b.Voice, b.Language)
+	case bench.ModeTranscription:
+		task = bench.TranscriptionTask(rt, cfg, inputs, b.Language)
+	}
+
+	defer func() {
+		err := ml.StopAllGRPC()
+		if err != nil {
+			xlog.Error("unable to stop all grpc processes", "error", err)
+		}
+	}()
+
+	report := bench.Report{
+		Version:   internal.PrintableVersion(),
+		Model:     b.Model,
+		Backend:   cfg.Backend,
+		Mode:      mode,
+		StartedAt: time.Now().UTC().Truncate(time.Second),
+		Settings:  settings,
+	}
+
+	fmt.Printf("Loading %s...\n", b.Model)
+	start := time.Now()
+	pid, err := rt.Load(runCtx, cfg)
+	if err != nil {
+		return fmt.Errorf("failed to load model %s: %w", b.Model, err)
+	}
+	report.LoadSeconds = time.Since(start).Round(time.Millisecond).Seconds()
+
+	fmt.Printf("Running %d %s requests (concurrency %d, warmup %d)...\n", b.Requests, mode, b.Concurrency, b.Warmup)
+	sampler := bench.StartMemorySampler(runCtx, 500*time.Millisecond, bench.ProcessMemory(pid))
+	report.Result = bench.Run(runCtx, settings.Options, task)
+	report.PeakMemory = sampler.Stop()
+
+	printBenchReport(report)
+
+	if b.Output != "" {
+		dat, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(b.Output, append(dat, '\n'), 0644); err != nil {
+			return err
+		}
+		fmt.Printf("Report written to %s\n", b.Output)
+	}
+
+	if report.Result.Errors == report.Result.Requests {
+		return fmt.Errorf("all requests failed: %s", report.Result.FirstError)
+	}
+	return nil
+}
+
+func printBenchReport(r bench.Report) {
+	res := r.Result
+	fmt.Printf("\nModel:        %s (%s, %s)\n", r.Model, r.Backend, r.Mode)
+	fmt.Printf("Load time:    %.2fs\n", r.LoadSeconds)
+	fmt.Printf("Requests:     %d (%d failed) in %.2fs, %.2f req/s\n", res.Requests, res.Errors, res.WallSeconds, res.RequestsPerSecond)
+	if res.FirstError != "" {
+		fmt.Printf("First error:  %s\n", res.FirstError)
+	}
+	if res.OutputTokens > 0 {
+		fmt.Printf("Tokens:       %d in, %d out, %.2f tokens/s\n", res.InputTokens, res.OutputTokens, res.TokensPerSecond)
+	}
+	printDistribution("Latency (ms)", res.Latency)
+	printDistribution("TTFT (ms)", res.TTFT)
+	printDistribution("Tokens/s/req", res.RequestTokensPerSecond)
+	fmt.Printf("Peak memory:  %s RSS, %s VRAM\n", vram.FormatBytes(r.PeakMemory.RSS), vram.FormatBytes(r.PeakMemory.VRAM))
+}
+
+func printDistribution(name string, d *bench.Distribution) {
+	if d == nil {
+		return
+	}
+	fmt.Printf("%-13s p50 %.1f  p95 %.1f  p99 %.1f  (min %.1f, mean %.1f, max %.1f)\n", name+":", d.P50, d.P95, d.P99, d.Min, d.Mean, d.Max)
+}
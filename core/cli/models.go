@@ -5,6 +5,13 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/tabwriter"
 
 	cliContext "github.com/mudler/LocalAI/core/cli/context"
 	"github.com/mudler/LocalAI/core/config"
@@ -16,6 +23,7 @@ import (
 	"github.com/mudler/LocalAI/pkg/model"
 	"github.com/mudler/LocalAI/pkg/modelartifacts"
 	"github.com/mudler/LocalAI/pkg/system"
+	"github.com/mudler/LocalAI/pkg/vram"
 	"github.com/mudler/xlog"
 	"github.com/schollz/progressbar/v3"
 )
@@ -46,9 +54,47 @@ type ModelsInstall struct {
 	ModelsCMDFlags `embed:""`
 }
 
+type ModelsRemove struct {
+	ModelArgs []string `arg:"" name:"models" help:"Names of the installed models to remove"`
+
+	ModelsCMDFlags `embed:""`
+}
+
+type ModelsUpgrade struct {
+	ModelArgs               []string `arg:"" optional:"" name:"models" help:"Installed models to upgrade (empty = upgrade all)"`
+	DryRun                  bool     `name:"dry-run" help:"Only report the models that have an upgrade available"`
+	DisablePredownloadScan  bool     `env:"LOCALAI_DISABLE_PREDOWNLOAD_SCAN" help:"If true, disables the best-effort security scanner before downloading any files." group:"hardening" default:"false"`
+	RequireBackendIntegrity bool     `env:"LOCALAI_REQUIRE_BACKEND_INTEGRITY,REQUIRE_BACKEND_INTEGRITY" help:"If true, reject backend installs without a configured signature verification policy (OCI URIs) or SHA256 (tarball/HTTP URIs)." group:"hardening" default:"false"`
+
+	ModelsCMDFlags `embed:""`
+}
+
+type ModelsListInstalled struct {
+	ModelsCMDFlags `embed:""`
+}
+
+type ModelsExport struct {
+	Model  string `arg:"" name:"model" help:"Name of the installed model to export"`
+	Output string `name:"output" short:"o" type:"path" help:"Path of the bundle to write (defaults to <model>.tar.gz in the current directory)"`
+
+	ModelsCMDFlags `embed:""`
+}
+
+type ModelsImport struct {
+	Bundles []string `arg:"" name:"bundles" type:"existingfile" help:"Model bundles created with 'local-ai models export'"`
+	Force   bool     `name:"force" help:"Overwrite models that are already installed"`
+
+	ModelsCMDFlags `embed:""`
+}
+
 type ModelsCMD struct {
-	List    ModelsList    `cmd:"" help:"List the models available in your galleries" default:"withargs"`
-	Install ModelsInstall `cmd:"" help:"Install a model from the gallery"`
+	List          ModelsList          `cmd:"" help:"List the models available in your galleries" default:"withargs"`
+	Install       ModelsInstall       `cmd:"" help:"Install a model from the gallery"`
+	Remove        ModelsRemove        `cmd:"" help:"Remove installed models and the files no other model uses"`
+	Upgrade       ModelsUpgrade       `cmd:"" help:"Reinstall gallery models whose gallery entry changed"`
+	ListInstalled ModelsListInstalled `cmd:"" name:"list-installed" help:"List the installed models with their backend and size on disk"`
+	Export        ModelsExport        `cmd:"" help:"Export an installed model to a self-contained bundle"`
+	Import        ModelsImport        `cmd:"" help:"Import models from bundles created with 'local-ai models export'"`
 }
 
 func (ml *ModelsList) Run(ctx *cliContext.Context) error {
@@ -162,3 +208,232 @@ func (mi *ModelsInstall) Run(ctx *cliContext.Context) error {
 	}
 	return nil
 }
+
+func (mr *ModelsRemove) Run(ctx *cliContext.Context) error {
+	systemState, err := system.GetSystemState(
+		system.WithModelPath(mr.ModelsPath),
+		system.WithBackendPath(mr.BackendsPath),
+	)
+	if err != nil {
+		return err
+	}
+
+	for _, modelName := range mr.ModelArgs {
+		xlog.Info("removing model", "model", modelName)
+		if err := gallery.DeleteModelFromSystem(systemState, modelName); err != nil {
+			return fmt.Errorf("failed to remove model %s: %w", modelName, err)
+		}
+		fmt.Printf("Model %s removed successfully\n", modelName)
+	}
+	return nil
+}
+
+func (mu *ModelsUpgrade) Run(ctx *cliContext.Context) error {
+	var galleries []config.Gallery
+	if err := json.Unmarshal([]byte(mu.Galleries), &galleries); err != nil {
+		xlog.Error("unable to load galleries", "error", err)
+	}
+
+	var backendGalleries []config.Gallery
+	if err := json.Unmarshal([]byte(mu.BackendGalleries), &backendGalleries); err != nil {
+		xlog.Error("unable to load backend galleries", "error", err)
+	}
+
+	systemState, err := system.GetSystemState(
+		system.WithModelPath(mu.ModelsPath),
+		system.WithBackendPath(mu.BackendsPath),
+	)
+	if err != nil {
+		return err
+	}
+
+	upgrades, err := gallery.CheckModelUpgrades(context.Background(), galleries, systemState)
+	if err != nil {
+		return fmt.Errorf("failed to check for upgrades: %w", err)
+	}
+
+	// Filter to specified models if args given
+	toUpgrade := upgrades
+	if len(mu.ModelArgs) > 0 {
+		toUpgrade = make(map[string]gallery.ModelUpgradeInfo)
+		for _, name := range mu.ModelArgs {
+			if info, ok := upgrades[name]; ok {
+				toUpgrade[name] = info
+			} else {
+				fmt.Printf("Model %s: no upgrade available\n", name)
+			}
+		}
+	}
+
+	if len(toUpgrade) == 0 {
+		fmt.Println("All models are up to date.")
+		return nil
+	}
+
+	names := slices.Sorted(maps.Keys(toUpgrade))
+	if mu.DryRun {
+		for _, name := range names {
+			info := toUpgrade[name]
+			fmt.Printf(" * %s (%d changed, %d new files)\n", name, len(info.ChangedFiles), len(info.NewFiles))
+		}
+		return nil
+	}
+
+	artifactMaterializer := modelartifacts.NewDefaultManager(
+		modelartifacts.WithHuggingFaceToken(mu.HFToken),
+		modelartifacts.WithDownloadConcurrency(mu.ArtifactDownloadConcurrency),
+	)
+	modelLoader := model.NewModelLoader(systemState)
+	var errs error
+	for _, name := range names {
+		fmt.Printf("Upgrading %s...\n", name)
+
+		progressBar := progressbar.NewOptions(
+			1000,
+			progressbar.OptionSetDescription(fmt.Sprintf("downloading %s", name)),
+			progressbar.OptionShowBytes(false),
+			progressbar.OptionClearOnFinish(),
+		)
+		progressCallback := func(fileName string, current string, total string, percentage float64) {
+			v := int(percentage * 10)
+			if err := progressBar.Set(v); err != nil {
+				xlog.Error("error updating progress bar", "error", err)
+			}
+		}
+
+		if err := gallery.UpgradeModel(context.Background(), galleries, backendGalleries, systemState, modelLoader, toUpgrade[name], progressCallback,
+			!mu.DisablePredownloadScan, true, mu.RequireBackendIntegrity, gallery.WithArtifactMaterializer(artifactMaterializer)); err != nil {
+			fmt.Printf("Failed to upgrade %s: %v\n", name, err)
+			errs = errors.Join(errs, err)
+		} else {
+			fmt.Printf("Model %s upgraded successfully\n", name)
+		}
+	}
+
+	return errs
+}
+
+func (ml *ModelsListInstalled) Run(ctx *cliContext.Context) error {
+	systemState, err := system.GetSystemState(
+		system.WithModelPath(ml.ModelsPath),
+		system.WithBackendPath(ml.BackendsPath),
+	)
+	if err != nil {
+		return err
+	}
+
+	loader := config.NewModelConfigLoader(ml.ModelsPath)
+	if err := loader.LoadModelConfigsFromPath(ml.ModelsPath); err != nil {
+		return err
+	}
+
+	configs := loader.GetAllModelsConfigs()
+	slices.SortFunc(configs, func(a, b config.ModelConfig) int { return strings.Compare(a.Name, b.Name) })
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tBACKEND\tSIZE")
+	for _, cfg := range configs {
+		backend := cfg.Backend
+		if backend == "" {
+			backend = "-"
+		}
+		size := "-"
+		if files, err := gallery.InstalledModelFiles(systemState, cfg.Name); err == nil {
+			size = vram.FormatBytes(diskUsage(ml.ModelsPath, files))
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", cfg.Name, backend, size)
+	}
+	return w.Flush()
+}
+
+// diskUsage sums the size of the given paths, relative to base, walking
+// directories.
+func diskUsage(base string, paths []string) uint64 {
+	var total uint64
+	for _, p := range paths {
+		filepath.WalkDir(filepath.Join(base, p), func(_ string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return nil
+			}
+			if info, err := d.Info(); err == nil {
+				total += uint64(info.Size())
+			}
+			return nil
+		})
+	}
+	return total
+}
+
+func (me *ModelsExport) Run(ctx *cliContext.Context) error {
+	systemState, err := system.GetSystemState(
+		system.WithModelPath(me.ModelsPath),
+		system.WithBackendPath(me.BackendsPath),
+	)
+	if err != nil {
+		return err
+	}
+
+	output := me.Output
+	if output == "" {
+		output = strings.ReplaceAll(me.Model, string(os.PathSeparator), "__") + ".tar.gz"
+	}
+
+	f, err := os.Create(output)
+	if err != nil {
+		return err
+	}
+	manifest, err := gallery.ExportModel(systemState, me.Model, f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(output)
+		return fmt.Errorf("failed to export model %s: %w", me.Model, err)
+	}
+
+	fmt.Printf("Model %s exported to %s (%d files)\n", me.Model, output, len(manifest.Files))
+	if manifest.Backend.Name != "" {
+		fmt.Printf("It runs on the %s backend, which must be installed on the target machine\n", manifest.Backend.Name)
+	}
+	return nil
+}
+
+func (mi *ModelsImport) Run(ctx *cliContext.Context) error {
+	systemState, err := system.GetSystemState(
+		system.WithModelPath(mi.ModelsPath),
+		system.WithBackendPath(mi.BackendsPath),
+	)
+	if err != nil {
+		return err
+	}
+
+	for _, bundle := range mi.Bundles {
+		f, err := os.Open(bundle)
+		if err != nil {
+			return err
+		}
+		manifest, err := gallery.ImportModel(systemState, f, mi.Force)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("failed to import %s: %w", bundle, err)
+		}
+		fmt.Printf("Model %s imported successfully\n", manifest.Name)
+
+		backend := manifest.Backend
+		if backend.Name == "" {
+			continue
+		}
+		backends, err := gallery.ListSystemBackends(systemState)
+		if err != nil {
+			return err
+		}
+		if !backends.Exists(backend.Name) {
+			source := backend.URI
+			if source == "" {
+				source = backend.Name
+			}
+			fmt.Printf("Warning: the %s backend is not installed. Install it with 'local-ai backends install %s'\n", backend.Name, source)
+		}
+	}
+	return nil
+}
@@ -19,6 +19,7 @@ import (
 	"github.com/mudler/LocalAI/pkg/modelartifacts"
 	"github.com/mudler/LocalAI/pkg/signals"
 	"github.com/mudler/LocalAI/pkg/system"
+	"github.com/mudler/LocalAI/pkg/tracing"
 	"github.com/mudler/LocalAI/pkg/vrambudget"
 	"github.com/mudler/LocalAI/pkg/xsysinfo"
 	"github.com/mudler/xlog"
@@ -119,6 +120,11 @@ type RunCMD struct {
 	TracingMaxBodyBytes                int      `env:"LOCALAI_TRACING_MAX_BODY_BYTES" default:"65536" help:"Maximum bytes captured per request/response body in the trace buffer (0 = uncapped). Caps memory growth from chatty endpoints like /embeddings." group:"api"`
 	AgentJobRetentionDays              int      `env:"LOCALAI_AGENT_JOB_RETENTION_DAYS,AGENT_JOB_RETENTION_DAYS" default:"30" help:"Number of days to keep agent job history (default: 30)" group:"api"`
 	OpenResponsesStoreTTL              string   `env:"LOCALAI_OPEN_RESPONSES_STORE_TTL,OPEN_RESPONSES_STORE_TTL" default:"0" help:"TTL for Open Responses store (e.g., 1h, 30m, 0 = no expiration)" group:"api"`
+	OpenResponsesPersist               bool     `env:"LOCALAI_OPEN_RESPONSES_PERSIST" default:"false" help:"Persist Open Responses (responses, items and stream events) to the auth database so they survive restarts. Requires authentication to be enabled" group:"api"`
+	OpenResponsesStoreRetention        string   `env:"LOCALAI_OPEN_RESPONSES_STORE_RETENTION" default:"720h" help:"How long persisted Open Responses are kept when no store TTL is set (e.g., 720h, 0 = forever)" group:"api"`
+	AuditLogRetention                  string   `env:"LOCALAI_AUDIT_LOG_RETENTION" default:"2160h" help:"How long PII events and router decisions are kept when they are persisted to the auth database (e.g., 2160h, 0 = forever)" group:"api"`
+	BatchConcurrency                   int      `env:"LOCALAI_BATCH_CONCURRENCY" default:"4" help:"Number of lines of a /v1/batches batch executed in parallel" group:"api"`
+	VectorStoreEmbeddingModel          string   `env:"LOCALAI_VECTOR_STORE_EMBEDDING_MODEL" help:"Embedding model used by /v1/vector_stores when a vector store is created without one" group:"api"`
 
 	// LocalAI Assistant chat modality (in-process admin MCP server)
 	DisableLocalAIAssistant bool `env:"LOCALAI_DISABLE_ASSISTANT" default:"false" help:"Disable the LocalAI Assistant chat modality (in-process admin MCP server)" group:"assistant"`
@@ -199,6 +205,12 @@ type RunCMD struct {
 	MITMListen string `env:"LOCALAI_MITM_LISTEN" help:"Address (host:port) for the cloudproxy MITM listener. Empty = disabled. Clients set HTTPS_PROXY=http://<this>:<port>. Intercept hosts are declared per-model via the model YAML mitm.hosts: block; create one from the Add Model UI." group:"middleware"`
 	MITMCADir  string `env:"LOCALAI_MITM_CA_DIR" type:"path" help:"Directory holding the MITM proxy CA cert + key. Defaults to <data-path>/mitm-ca." group:"middleware"`
 
+	// OpenTelemetry trace export (off unless a collector is configured).
+	OTelEndpoint    string  `name:"otel-endpoint" env:"LOCALAI_OTEL_ENDPOINT" help:"OTLP collector to export OpenTelemetry traces to, as host:port or URL (e.g. otel-collector:4317, http://otel-collector:4318/v1/traces). Empty falls back to OTEL_EXPORTER_OTLP_ENDPOINT; with neither set, no spans are exported." group:"observability"`
+	OTelProtocol    string  `name:"otel-protocol" env:"LOCALAI_OTEL_PROTOCOL" default:"grpc" enum:"grpc,http/protobuf" help:"OTLP transport: grpc or http/protobuf" group:"observability"`
+	OTelInsecure    bool    `name:"otel-insecure" env:"LOCALAI_OTEL_INSECURE" default:"false" help:"Connect to the OTLP collector without TLS" group:"observability"`
+	OTelSampleRatio float64 `name:"otel-sample-ratio" env:"LOCALAI_OTEL_SAMPLE_RATIO" default:"1" help:"Fraction of new traces to record, between 0 and 1. Requests arriving with a sampled traceparent are always recorded." group:"observability"`
+
 	PIIDefaultDetectors []string `env:"LOCALAI_PII_DEFAULT_DETECTORS" help:"Instance-wide default PII/secret detector model names applied to any PII-enabled model (chiefly cloud-proxy / MITM models) that names no pii.detectors of its own. Comma-separated, e.g. privacy-filter-nemotron,secret-filter. Takes precedence over the value persisted via the Middleware UI." group:"middleware"`
 }
 
@@ -328,6 +340,8 @@ func (r *RunCMD) Run(ctx *cliContext.Context) error {
 		config.WithMITMCADir(r.MITMCADir),
 		config.WithPIIDefaultDetectors(r.PIIDefaultDetectors),
 		config.WithAgentJobRetentionDays(r.AgentJobRetentionDays),
+		config.WithBatchConcurrency(r.BatchConcurrency),
+		config.WithVectorStoreEmbeddingModel(r.VectorStoreEmbeddingModel),
 		config.WithLlamaCPPTunnelCallback(func(tunnels []string) {
 			tunnelEnvVar := strings.Join(tunnels, ",")
 			os.Setenv("LLAMACPP_GRPC_SERVERS", tunnelEnvVar)
@@ -478,6 +492,13 @@ func (r *RunCMD) Run(ctx *cliContext.Context) error {
 	opts = append(opts, config.WithMaxConcurrentBackendRequests(r.MaxConcurrentBackendRequests))
 	opts = append(opts, config.WithTracingMaxItems(r.TracingMaxItems))
 	opts = append(opts, config.WithTracingMaxBodyBytes(r.TracingMaxBodyBytes))
+	opts = append(opts, config.WithOTelTracing(tracing.Config{
+		Endpoint:    r.OTelEndpoint,
+		Protocol:    r.OTelProtocol,
+		Insecure:    r.OTelInsecure,
+		SampleRatio: r.OTelSampleRatio,
+		ServiceName: "local-ai",
+	}))
 
 	token := ""
 	if r.Peer2Peer || r.Peer2PeerToken != "" {
@@ -706,6 +727,23 @@ func (r *RunCMD) Run(ctx *cliContext.Context) error {
 		}
 		opts = append(opts, config.WithOpenResponsesStoreTTL(dur))
 	}
+	if r.OpenResponsesPersist {
+		opts = append(opts, config.WithOpenResponsesPersist(true))
+		if r.OpenResponsesStoreRetention != "" && r.OpenResponsesStoreRetention != "0" {
+			dur, err := time.ParseDuration(r.OpenResponsesStoreRetention)
+			if err != nil {
+				return fmt.Errorf("invalid Open Responses store retention: %w", err)
+			}
+			opts = append(opts, config.WithOpenResponsesStoreRetention(dur))
+		}
+	}
+	if r.AuditLogRetention != "" && r.AuditLogRetention != "0" {
+		dur, err := time.ParseDuration(r.AuditLogRetention)
+		if err != nil {
+			return fmt.Errorf("invalid audit log retention: %w", err)
+		}
+		opts = append(opts, config.WithAuditLogRetention(dur))
+	}
 
 	// split ":" to get backend name and the uri
 	for _, v := range r.ExternalGRPCBackends {
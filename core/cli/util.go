@@ -25,6 +25,7 @@ type UtilCMD struct {
 	CreateOCIImage   CreateOCIImageCMD   `cmd:"" name:"create-oci-image" help:"Create an OCI image from a file or a directory"`
 	HFScan           HFScanCMD           `cmd:"" name:"hf-scan" help:"Checks installed models for known security issues. WARNING: this is a best-effort feature and may not catch everything!"`
 	UsecaseHeuristic UsecaseHeuristicCMD `cmd:"" name:"usecase-heuristic" help:"Checks a specific model config and prints what usecase LocalAI will offer for it."`
+	Bench            BenchCMD            `cmd:"" name:"bench" help:"Benchmark the latency, throughput and memory usage of an installed model"`
 }
 
 type GGUFInfoCMD struct {
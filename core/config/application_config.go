@@ -8,6 +8,7 @@ import (
 
 	"github.com/mudler/LocalAI/pkg/modelartifacts"
 	"github.com/mudler/LocalAI/pkg/system"
+	"github.com/mudler/LocalAI/pkg/tracing"
 	"github.com/mudler/LocalAI/pkg/vrambudget"
 	"github.com/mudler/LocalAI/pkg/xsysinfo"
 	"github.com/mudler/xlog"
@@ -45,8 +46,11 @@ type ApplicationConfig struct {
 	MaxConcurrentBackendRequests        int
 	TracingMaxItems                     int
 	TracingMaxBodyBytes                 int // Per-body cap for captured request/response bodies; 0 disables the cap
-	EnableBackendLogging                bool
-	GeneratedContentDir                 string
+	// OTelTracing configures OpenTelemetry span export. It is unrelated to
+	// EnableTracing, which keeps the in-memory API/backend trace buffer.
+	OTelTracing          tracing.Config
+	EnableBackendLogging bool
+	GeneratedContentDir  string
 
 	UploadDir string
 	DataPath  string // Persistent data directory for collectiondb, agents, etc.
@@ -178,7 +182,15 @@ type ApplicationConfig struct {
 
 	AgentJobRetentionDays int // Default: 30 days
 
-	OpenResponsesStoreTTL time.Duration // TTL for Open Responses store (0 = no expiration)
+	OpenResponsesStoreTTL       time.Duration // TTL for Open Responses store (0 = no expiration)
+	OpenResponsesPersist        bool          // Persist Open Responses to the auth database
+	OpenResponsesStoreRetention time.Duration // Retention of persisted responses when no TTL is set (0 = forever)
+
+	AuditLogRetention time.Duration // Retention of persisted PII events and router decisions (0 = forever)
+
+	BatchConcurrency int // Lines of a /v1/batches batch executed in parallel (0 = default)
+
+	VectorStoreEmbeddingModel string // Default embedding model of /v1/vector_stores
 
 	PathWithoutAuth []string
 
@@ -402,6 +414,12 @@ var EnableTracing = func(o *ApplicationConfig) {
 	o.EnableTracing = true
 }
 
+func WithOTelTracing(cfg tracing.Config) AppOption {
+	return func(o *ApplicationConfig) {
+		o.OTelTracing = cfg
+	}
+}
+
 var EnableBackendLogging = func(o *ApplicationConfig) {
 	o.EnableBackendLogging = true
 }
@@ -859,6 +877,36 @@ func WithOpenResponsesStoreTTL(ttl time.Duration) AppOption {
 	}
 }
 
+func WithOpenResponsesPersist(enabled bool) AppOption {
+	return func(o *ApplicationConfig) {
+		o.OpenResponsesPersist = enabled
+	}
+}
+
+func WithOpenResponsesStoreRetention(retention time.Duration) AppOption {
+	return func(o *ApplicationConfig) {
+		o.OpenResponsesStoreRetention = retention
+	}
+}
+
+func WithAuditLogRetention(retention time.Duration) AppOption {
+	return func(o *ApplicationConfig) {
+		o.AuditLogRetention = retention
+	}
+}
+
+func WithBatchConcurrency(concurrency int) AppOption {
+	return func(o *ApplicationConfig) {
+		o.BatchConcurrency = concurrency
+	}
+}
+
+func WithVectorStoreEmbeddingModel(model string) AppOption {
+	return func(o *ApplicationConfig) {
+		o.VectorStoreEmbeddingModel = model
+	}
+}
+
 func WithEnforcedPredownloadScans(enforced bool) AppOption {
 	return func(o *ApplicationConfig) {
 		o.EnforcePredownloadScans = enforced
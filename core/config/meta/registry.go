@@ -93,6 +93,14 @@ func DefaultRegistry() map[string]FieldMetaOverride {
 			Advanced:    true,
 			Order:       9,
 		},
+		"fallbacks": {
+			Section:              "general",
+			Label:                "Fallback Models",
+			Description:          "Models a chat or completion request is retried on, in order, when this model fails to load, its backend is unavailable, it times out or the prompt overflows its context. Streamed requests only fall back before the first token.",
+			Component:            "model-multi-select",
+			AutocompleteProvider: ProviderModels,
+			Order:                10,
+		},
 
 		// --- LLM ---
 		"context_size": {
@@ -836,6 +844,13 @@ func DefaultRegistry() map[string]FieldMetaOverride {
 			Advanced:    true,
 			Order:       72,
 		},
+		"function.grammar.schema_retries": {
+			Section:     "functions",
+			Label:       "Schema Retries",
+			Description: "Times an output failing validation against a strict json_schema response_format is regenerated before the error is returned",
+			Advanced:    true,
+			Order:       73,
+		},
 
 		// --- MCP ---
 		"mcp.remote": {
@@ -938,6 +953,13 @@ func DefaultRegistry() map[string]FieldMetaOverride {
 			Description: "Suffix for reversible redaction tokens. Defaults to ].",
 			Order:       204,
 		},
+		"pii.scan_responses": {
+			Section:     "pii",
+			Label:       "Scan Responses",
+			Description: "Also scan generated responses with the detectors, masking, blocking or logging PII the model leaks. Streamed responses hold back the last 256 bytes of text until it is scanned.",
+			Component:   "toggle",
+			Order:       205,
+		},
 
 		// --- PII detection policy (on a token_classify detector model) ---
 		"pii_detection.min_score": {
@@ -994,7 +1016,7 @@ func DefaultRegistry() map[string]FieldMetaOverride {
 		"proxy.mode": {
 			Section:     "proxy",
 			Label:       "Proxy Mode",
-			Description: "passthrough forwards the client's OpenAI body verbatim — point upstream_url at an OpenAI-compatible endpoint (incl. Anthropic's /v1/chat/completions compat layer). translate converts OpenAI ↔ Anthropic Messages so you can target a native API (/v1/messages); tool_calls and usage tokens survive the round-trip.",
+			Description: "passthrough forwards the client's OpenAI body verbatim — point upstream_url at an OpenAI-compatible endpoint (incl. Anthropic's /v1/chat/completions compat layer). translate converts OpenAI ↔ Anthropic Messages, Gemini generateContent or Bedrock Converse so you can target a native API; tool_calls and usage tokens survive the round-trip.",
 			Component:   "select",
 			Options: []FieldOption{
 				{Value: "passthrough", Label: "passthrough (raw forward)"},
@@ -1006,11 +1028,13 @@ func DefaultRegistry() map[string]FieldMetaOverride {
 		"proxy.provider": {
 			Section:     "proxy",
 			Label:       "Proxy Provider",
-			Description: "Upstream API family. Drives auth header shape (Bearer vs x-api-key + anthropic-version) and, in translate mode, which request/response codec is used.",
+			Description: "Upstream API family. Drives auth header shape (Bearer, x-api-key + anthropic-version, or x-goog-api-key) and, in translate mode, which request/response codec is used.",
 			Component:   "select",
 			Options: []FieldOption{
 				{Value: "openai", Label: "OpenAI"},
 				{Value: "anthropic", Label: "Anthropic"},
+				{Value: "gemini", Label: "Google Gemini"},
+				{Value: "bedrock", Label: "AWS Bedrock (Converse)"},
 			},
 			Default: "openai",
 			Order:   209,
@@ -1018,7 +1042,7 @@ func DefaultRegistry() map[string]FieldMetaOverride {
 		"proxy.upstream_url": {
 			Section:     "proxy",
 			Label:       "Proxy Upstream URL",
-			Description: "Full POST endpoint of the upstream provider (e.g. https://api.openai.com/v1/chat/completions). Only used when Backend is cloud-proxy.",
+			Description: "Full POST endpoint of the upstream provider (e.g. https://api.openai.com/v1/chat/completions). For gemini and bedrock the API base is enough (e.g. https://generativelanguage.googleapis.com/v1beta). Only used when Backend is cloud-proxy.",
 			Component:   "input",
 			Order:       210,
 		},
@@ -1066,6 +1090,150 @@ func DefaultRegistry() map[string]FieldMetaOverride {
 			Order:       220,
 		},
 
+		// --- Limits ---
+		// Admission control: requests past max_concurrent wait in the
+		// priority queue, or are rejected with 503 + Retry-After.
+		"limits.max_concurrent": {
+			Section:     "limits",
+			Label:       "Max Concurrent Requests",
+			Description: "Simultaneous in-flight requests allowed for this model. 0 = unlimited.",
+			Component:   "number",
+			Min:         f64(0),
+			Order:       280,
+		},
+		"limits.queue_size": {
+			Section:     "limits",
+			Label:       "Queue Size",
+			Description: "Requests allowed to wait for a slot when all max_concurrent slots are busy, served by priority class then fairly across users. 0 rejects at once.",
+			Component:   "number",
+			Min:         f64(0),
+			Order:       281,
+		},
+		"limits.queue_timeout_seconds": {
+			Section:     "limits",
+			Label:       "Queue Timeout (seconds)",
+			Description: "How long a request waits in the queue before it is rejected. 0 defaults to 30s.",
+			Component:   "number",
+			Min:         f64(0),
+			Order:       282,
+		},
+		"limits.retry_after_seconds": {
+			Section:     "limits",
+			Label:       "Retry-After (seconds)",
+			Description: "Retry-After hint sent with a 503 rejection. 0 defaults to 1s.",
+			Component:   "number",
+			Min:         f64(0),
+			Order:       283,
+		},
+
+		// --- Pricing ---
+		// Rates the billing recorder applies to compute per-request
+		// spend; cost-based quota rules cap that spend.
+		"pricing.input_per_million": {
+			Section:     "pricing",
+			Label:       "Input (per 1M tokens)",
+			Description: "Price of one million prompt tokens, in the currency unit all price tables and budgets share",
+			Component:   "number",
+			Min:         f64(0),
+			Step:        f64(0.01),
+			Order:       300,
+		},
+		"pricing.output_per_million": {
+			Section:     "pricing",
+			Label:       "Output (per 1M tokens)",
+			Description: "Price of one million completion tokens",
+			Component:   "number",
+			Min:         f64(0),
+			Step:        f64(0.01),
+			Order:       301,
+		},
+		"pricing.cached_input_per_million": {
+			Section:     "pricing",
+			Label:       "Cached Input (per 1M tokens)",
+			Description: "Price of one million prompt tokens served from the prompt cache. Empty charges cached tokens as regular input.",
+			Component:   "number",
+			Min:         f64(0),
+			Step:        f64(0.01),
+			Order:       302,
+		},
+		"pricing.per_audio_second": {
+			Section:     "pricing",
+			Label:       "Audio (per second)",
+			Description: "Price of one second of transcribed or generated audio",
+			Component:   "number",
+			Min:         f64(0),
+			Step:        f64(0.0001),
+			Order:       303,
+		},
+		"pricing.per_image": {
+			Section:     "pricing",
+			Label:       "Image (each)",
+			Description: "Price of one generated image",
+			Component:   "number",
+			Min:         f64(0),
+			Step:        f64(0.001),
+			Order:       304,
+		},
+
+		// --- Response cache ---
+		// Repeated chat/completion requests are answered from cached
+		// responses; the semantic block also matches paraphrases.
+		"response_cache.enabled": {
+			Section:     "response_cache",
+			Label:       "Enable Response Cache",
+			Description: "Serve requests whose normalized messages and sampling parameters match a cached request from the cache. Only enable it for deterministic workloads.",
+			Component:   "toggle",
+			Order:       310,
+		},
+		"response_cache.ttl_seconds": {
+			Section:     "response_cache",
+			Label:       "TTL (seconds)",
+			Description: "How long a response stays cached. 0 defaults to one hour.",
+			Component:   "number",
+			Min:         f64(0),
+			Order:       311,
+		},
+		"response_cache.max_entries": {
+			Section:     "response_cache",
+			Label:       "Max Entries",
+			Description: "Responses cached for this model before the least recently used one is evicted. 0 defaults to 1000.",
+			Component:   "number",
+			Min:         f64(0),
+			Order:       312,
+		},
+		"response_cache.shared": {
+			Section:     "response_cache",
+			Label:       "Share Across Users",
+			Description: "Serve cached responses to every user. By default users only hit the responses of their own requests.",
+			Component:   "toggle",
+			Order:       313,
+		},
+		"response_cache.semantic.embedding_model": {
+			Section:              "response_cache",
+			Label:                "Semantic: Embedding Model",
+			Description:          "Embedding model used to match semantically similar requests. Empty serves exact matches only.",
+			Component:            "model-select",
+			AutocompleteProvider: ProviderModels,
+			Order:                314,
+		},
+		"response_cache.semantic.similarity_threshold": {
+			Section:     "response_cache",
+			Label:       "Semantic: Similarity Threshold",
+			Description: "Cosine-similarity floor a cached request must clear to be served. 0 picks the default (0.95).",
+			Component:   "slider",
+			Min:         f64(0),
+			Max:         f64(1),
+			Step:        f64(0.01),
+			Order:       315,
+		},
+		"response_cache.semantic.store_name": {
+			Section:     "response_cache",
+			Label:       "Semantic: Store Name",
+			Description: "Optional override for the local-store collection of the semantic index. Empty defaults to \"response-cache-<model-name>\".",
+			Component:   "input",
+			Order:       316,
+		},
+
 		// --- Router ---
 		// Routing turns this model config into a dispatcher: the
 		// classifier scores every policy label as a continuation of
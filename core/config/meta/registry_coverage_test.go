@@ -175,8 +175,6 @@ var grandfatheredUnregistered = []string{
 	"limit_mm_per_prompt.audio",
 	"limit_mm_per_prompt.image",
 	"limit_mm_per_prompt.video",
-	"limits.max_concurrent",
-	"limits.retry_after_seconds",
 	"load_format",
 	"lora_adapter",
 	"lora_adapters",
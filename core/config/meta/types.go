@@ -85,6 +85,9 @@ func DefaultSections() []Section {
 		{ID: "proxy", Label: "Proxy", Icon: "cloud", Order: 80},
 		{ID: "mitm", Label: "MITM Proxy", Icon: "shield", Order: 82},
 		{ID: "pii", Label: "PII", Icon: "shield", Order: 84},
+		{ID: "limits", Label: "Limits", Icon: "gauge", Order: 85},
+		{ID: "pricing", Label: "Pricing", Icon: "dollar-sign", Order: 86},
+		{ID: "response_cache", Label: "Response Cache", Icon: "database", Order: 88},
 		{ID: "other", Label: "Other", Icon: "more-horizontal", Order: 100},
 	}
 }
@@ -151,6 +151,14 @@ type ModelConfig struct {
 	Proxy        ProxyConfig        `yaml:"proxy,omitempty" json:"proxy,omitempty"`
 	MITM         MITMModelConfig    `yaml:"mitm,omitempty" json:"mitm,omitempty"`
 	Limits       LimitsConfig       `yaml:"limits,omitempty" json:"limits,omitempty"`
+	Pricing      PricingConfig      `yaml:"pricing,omitempty" json:"pricing,omitempty"`
+	// ResponseCache serves repeated chat and completion requests from a
+	// cache of past responses instead of running a generation.
+	ResponseCache ResponseCacheConfig `yaml:"response_cache,omitempty" json:"response_cache,omitempty"`
+	// Fallbacks are the models a chat or completion request is retried
+	// on, in order, when this model fails before producing a token
+	// (load error, backend unavailable, timeout, context overflow).
+	Fallbacks []string `yaml:"fallbacks,omitempty" json:"fallbacks,omitempty"`
 }
 
 // CompressionConfig controls opt-in compression of chat history before inference.
@@ -183,6 +191,63 @@ type LimitsConfig struct {
 	// let an in-flight request finish on a busy local model. The
 	// value is sent verbatim in the Retry-After response header.
 	RetryAfterSeconds int `yaml:"retry_after_seconds,omitempty" json:"retry_after_seconds,omitempty"`
+
+	// QueueSize lets up to this many requests wait for a slot when
+	// all MaxConcurrent slots are busy, instead of being rejected at
+	// once. Waiters are served by priority class (from the API key or
+	// the user role), then fairly across users. 0 = no queue (default).
+	QueueSize int `yaml:"queue_size,omitempty" json:"queue_size,omitempty"`
+
+	// QueueTimeoutSeconds bounds the time a request waits in the
+	// queue before it is rejected like a request finding no room.
+	// 0 defaults to 30s.
+	QueueTimeoutSeconds int `yaml:"queue_timeout_seconds,omitempty" json:"queue_timeout_seconds,omitempty"`
+}
+
+// @Description Response cache for the chat and completion endpoints.
+// A request whose normalized messages (or prompt) and sampling
+// parameters match a cached one is answered with the cached response,
+// replayed as a stream when the request streams. The cache is opt-in
+// because only deterministic workloads (FAQ bots, test suites, low
+// temperature) should see the same answer twice.
+type ResponseCacheConfig struct {
+	Enabled bool `yaml:"enabled,omitempty" json:"enabled,omitempty"`
+
+	// TTLSeconds is how long a response stays cached. 0 defaults to
+	// one hour.
+	TTLSeconds int `yaml:"ttl_seconds,omitempty" json:"ttl_seconds,omitempty"`
+
+	// MaxEntries bounds the responses cached for this model; the least
+	// recently used one is evicted first. 0 defaults to 1000.
+	MaxEntries int `yaml:"max_entries,omitempty" json:"max_entries,omitempty"`
+
+	// Shared serves cached responses across users. By default every
+	// user only hits the responses generated for their own requests.
+	Shared bool `yaml:"shared,omitempty" json:"shared,omitempty"`
+
+	// Semantic also serves the cached response of a semantically
+	// similar request, found by embedding similarity. Omit the block
+	// to serve exact matches only.
+	Semantic *ResponseCacheSemanticConfig `yaml:"semantic,omitempty" json:"semantic,omitempty"`
+}
+
+// ResponseCacheSemanticConfig configures the embedding-similarity
+// lookup of the response cache. It shares the embedding + local-store
+// plumbing with the router's EmbeddingCacheConfig.
+type ResponseCacheSemanticConfig struct {
+	// EmbeddingModel names the loaded LocalAI model used to embed the
+	// conversation. Required.
+	EmbeddingModel string `yaml:"embedding_model" json:"embedding_model"`
+
+	// SimilarityThreshold is the cosine-similarity floor a cached
+	// request must clear to be served. 0 picks the package default
+	// (0.95): unlike a routing decision, a response is only right for
+	// a near-paraphrase of its request.
+	SimilarityThreshold float64 `yaml:"similarity_threshold,omitempty" json:"similarity_threshold,omitempty"`
+
+	// StoreName overrides the local-store collection name. Empty
+	// defaults to "response-cache-<model>".
+	StoreName string `yaml:"store_name,omitempty" json:"store_name,omitempty"`
 }
 
 // @Description MITM intercept binding for the model. When the cloudproxy
@@ -215,7 +280,11 @@ type MITMModelConfig struct {
 type ProxyConfig struct {
 	// UpstreamURL is the full POST endpoint, e.g.
 	// https://api.openai.com/v1/chat/completions or
-	// https://api.anthropic.com/v1/messages. Required.
+	// https://api.anthropic.com/v1/messages. Required. The gemini and
+	// bedrock providers pick the method per request (streaming or not),
+	// so for them it may also be the API base, e.g.
+	// https://generativelanguage.googleapis.com/v1beta or
+	// https://bedrock-runtime.us-east-1.amazonaws.com.
 	UpstreamURL string `yaml:"upstream_url,omitempty" json:"upstream_url,omitempty"`
 
 	// Mode selects passthrough (wire-perfect) or translate (full
@@ -223,8 +292,8 @@ type ProxyConfig struct {
 	Mode string `yaml:"mode,omitempty" json:"mode,omitempty"`
 
 	// Provider identifies the upstream's wire format for translate
-	// mode (openai, anthropic). Ignored in passthrough mode — the
-	// wire format there is whatever the client sent.
+	// mode (openai, anthropic, gemini, bedrock). Ignored in passthrough
+	// mode — the wire format there is whatever the client sent.
 	Provider string `yaml:"provider,omitempty" json:"provider,omitempty"`
 
 	// APIKeyEnv names the environment variable holding the upstream
@@ -273,6 +342,8 @@ const (
 const (
 	ProxyProviderOpenAI    = "openai"
 	ProxyProviderAnthropic = "anthropic"
+	ProxyProviderGemini    = "gemini"
+	ProxyProviderBedrock   = "bedrock"
 )
 
 // IsCloudProxyBackendPassthrough reports whether this model uses the
@@ -564,11 +635,18 @@ type PIIConfig struct {
 	ReversibleRedactions  bool   `yaml:"reversible_redactions,omitempty" json:"reversible_redactions,omitempty"`
 	ReversibleTokenPrefix string `yaml:"reversible_token_prefix,omitempty" json:"reversible_token_prefix,omitempty"`
 	ReversibleTokenSuffix string `yaml:"reversible_token_suffix,omitempty" json:"reversible_token_suffix,omitempty"`
+
+	// ScanResponses runs the same detectors over the generated response,
+	// so PII the model memorized or pulled from RAG context is masked,
+	// blocked or logged on the way out. Streams are scanned with a
+	// bounded look-behind, which holds back the last few tokens.
+	ScanResponses bool `yaml:"scan_responses,omitempty" json:"scan_responses,omitempty"`
 }
 
 func (c ModelConfig) PIIReversibleRedactions() bool    { return c.PII.ReversibleRedactions }
 func (c ModelConfig) PIIReversibleTokenPrefix() string { return c.PII.ReversibleTokenPrefix }
 func (c ModelConfig) PIIReversibleTokenSuffix() string { return c.PII.ReversibleTokenSuffix }
+func (c ModelConfig) PIIScanResponses() bool           { return c.PII.ScanResponses }
 
 // @Description Detection policy for a token-classification (NER) model
 // used as a PII detector. Lives on the detector model's own config so the
@@ -738,6 +816,14 @@ type (
 type MCPRemoteServer struct {
 	URL   string `json:"url,omitempty"`
 	Token string `json:"token,omitempty"`
+	// OAuth authorizes the connection with OAuth 2.1 instead of a static
+	// token: LocalAI discovers the authorization server from the server's
+	// protected resource metadata and attaches the access token it obtains.
+	OAuth *MCPOAuthConfig `yaml:"oauth,omitempty" json:"oauth,omitempty"`
+	// Sampling and Elicitation advertise the matching client capabilities
+	// to the server (see MCPSTDIOServer).
+	Sampling    bool `yaml:"sampling,omitempty" json:"sampling,omitempty"`
+	Elicitation bool `yaml:"elicitation,omitempty" json:"elicitation,omitempty"`
 }
 
 // @Description MCP STDIO server configuration
@@ -745,6 +831,31 @@ type MCPSTDIOServer struct {
 	Args    []string          `json:"args,omitempty"`
 	Env     map[string]string `json:"env,omitempty"`
 	Command string            `json:"command,omitempty"`
+	// Sampling lets the server request completions (sampling/createMessage),
+	// which are served by the model the server is configured on.
+	Sampling bool `yaml:"sampling,omitempty" json:"sampling,omitempty"`
+	// Elicitation lets the server ask the API caller for input while one
+	// of its tools runs.
+	Elicitation bool `yaml:"elicitation,omitempty" json:"elicitation,omitempty"`
+}
+
+// OAuth grant types supported for remote MCP servers.
+const (
+	MCPOAuthAuthorizationCode = "authorization_code"
+	MCPOAuthClientCredentials = "client_credentials"
+)
+
+// @Description MCP remote server OAuth 2.1 configuration
+type MCPOAuthConfig struct {
+	// GrantType is authorization_code (the default, an administrator
+	// authorizes LocalAI once in the browser) or client_credentials.
+	GrantType    string   `yaml:"grant_type,omitempty" json:"grant_type,omitempty"`
+	ClientID     string   `yaml:"client_id,omitempty" json:"client_id,omitempty"`
+	ClientSecret string   `yaml:"client_secret,omitempty" json:"client_secret,omitempty"`
+	Scopes       []string `yaml:"scopes,omitempty" json:"scopes,omitempty"`
+	// RedirectURL is LocalAI's /api/mcp/oauth/callback as the browser
+	// reaches it. Required for the authorization_code grant.
+	RedirectURL string `yaml:"redirect_url,omitempty" json:"redirect_url,omitempty"`
 }
 
 // @Description Pipeline defines other models to use for audio-to-audio
@@ -1567,6 +1678,35 @@ func (c *ModelConfig) Validate() (bool, error) {
 			return false, fmt.Errorf("compression: unknown on_post_compression_overflow %q", c.Compression.OnPostCompressionOverflow)
 		}
 	}
+	if err := c.Pricing.Validate(); err != nil {
+		return false, err
+	}
+	if rc := c.ResponseCache; rc.Enabled {
+		if rc.TTLSeconds < 0 || rc.MaxEntries < 0 {
+			return false, fmt.Errorf("response_cache: ttl_seconds and max_entries cannot be negative")
+		}
+		if rc.Semantic != nil {
+			if rc.Semantic.EmbeddingModel == "" {
+				return false, fmt.Errorf("response_cache: semantic requires embedding_model")
+			}
+			if rc.Semantic.SimilarityThreshold < 0 || rc.Semantic.SimilarityThreshold > 1 {
+				return false, fmt.Errorf("response_cache: similarity_threshold must be between 0 and 1")
+			}
+		}
+	}
+	seenFallbacks := make(map[string]struct{}, len(c.Fallbacks))
+	for _, name := range c.Fallbacks {
+		if name == "" {
+			return false, fmt.Errorf("fallbacks: empty model name")
+		}
+		if name == c.Name {
+			return false, fmt.Errorf("fallbacks: model %q cannot fall back to itself", c.Name)
+		}
+		if _, exists := seenFallbacks[name]; exists {
+			return false, fmt.Errorf("fallbacks: duplicate model %q", name)
+		}
+		seenFallbacks[name] = struct{}{}
+	}
 	if c.IsAlias() && len(c.Artifacts) > 0 {
 		return false, fmt.Errorf("alias model %q cannot declare artifacts", c.Name)
 	}
@@ -1668,8 +1808,8 @@ func (c *ModelConfig) Validate() (bool, error) {
 			c.Proxy.Mode, ProxyModePassthrough, ProxyModeTranslate)
 	}
 	if c.Proxy.Mode == ProxyModeTranslate && c.Proxy.Provider == "" {
-		return false, fmt.Errorf("proxy: translate mode requires provider (%s, %s)",
-			ProxyProviderOpenAI, ProxyProviderAnthropic)
+		return false, fmt.Errorf("proxy: translate mode requires provider (%s, %s, %s, %s)",
+			ProxyProviderOpenAI, ProxyProviderAnthropic, ProxyProviderGemini, ProxyProviderBedrock)
 	}
 
 	// Score on llama-cpp runs through the slot loop (SERVER_TASK_TYPE_SCORE,
@@ -0,0 +1,108 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+)
+
+// @Description Per-model price table. The billing recorder multiplies
+// the units a request consumed by these rates to compute its spend,
+// which cost-based quota rules then cap. All rates are in the same
+// currency unit (the usage record and Prometheus metric call it USD;
+// any single currency works as long as every price table and budget
+// uses it). A model with no rates set is not priced: its usage is
+// recorded without a cost and never counts against a budget.
+type PricingConfig struct {
+	// InputPerMillion is the price of one million prompt tokens.
+	InputPerMillion float64 `yaml:"input_per_million,omitempty" json:"input_per_million,omitempty"`
+
+	// OutputPerMillion is the price of one million completion tokens.
+	OutputPerMillion float64 `yaml:"output_per_million,omitempty" json:"output_per_million,omitempty"`
+
+	// CachedInputPerMillion is the price of one million prompt tokens
+	// served from the prompt cache. Cached tokens are a subset of the
+	// prompt tokens, so they are charged at this rate instead of
+	// InputPerMillion. Nil charges them as regular input.
+	CachedInputPerMillion *float64 `yaml:"cached_input_per_million,omitempty" json:"cached_input_per_million,omitempty"`
+
+	// PerAudioSecond is the price of one second of audio: the input
+	// audio for transcription, the generated audio for speech.
+	PerAudioSecond float64 `yaml:"per_audio_second,omitempty" json:"per_audio_second,omitempty"`
+
+	// PerImage is the price of one generated image.
+	PerImage float64 `yaml:"per_image,omitempty" json:"per_image,omitempty"`
+}
+
+// BillableUsage is what a single request consumed, in the units
+// PricingConfig has rates for.
+type BillableUsage struct {
+	PromptTokens     int64
+	CompletionTokens int64
+	CachedTokens     int64
+	AudioSeconds     float64
+	Images           int64
+}
+
+// IsSet reports whether any rate is configured.
+func (p PricingConfig) IsSet() bool {
+	return p.InputPerMillion != 0 || p.OutputPerMillion != 0 || p.CachedInputPerMillion != nil ||
+		p.PerAudioSecond != 0 || p.PerImage != 0
+}
+
+// Validate rejects negative rates.
+func (p PricingConfig) Validate() error {
+	rates := map[string]float64{
+		"input_per_million":  p.InputPerMillion,
+		"output_per_million": p.OutputPerMillion,
+		"per_audio_second":   p.PerAudioSecond,
+		"per_image":          p.PerImage,
+	}
+	if p.CachedInputPerMillion != nil {
+		rates["cached_input_per_million"] = *p.CachedInputPerMillion
+	}
+	for name, v := range rates {
+		if v < 0 {
+			return fmt.Errorf("pricing: %s cannot be negative", name)
+		}
+	}
+	return nil
+}
+
+// Cost returns the spend for u under this price table.
+func (p PricingConfig) Cost(u BillableUsage) float64 {
+	cached := min(max(u.CachedTokens, 0), u.PromptTokens)
+	cachedRate := p.InputPerMillion
+	if p.CachedInputPerMillion != nil {
+		cachedRate = *p.CachedInputPerMillion
+	}
+	cost := float64(u.PromptTokens-cached) * p.InputPerMillion / 1e6
+	cost += float64(cached) * cachedRate / 1e6
+	cost += float64(u.CompletionTokens) * p.OutputPerMillion / 1e6
+	cost += u.AudioSeconds * p.PerAudioSecond
+	cost += float64(u.Images) * p.PerImage
+	return cost
+}
+
+// Version identifies the rates in effect, so a usage record's cost can
+// be audited against the table that produced it after the config has
+// changed. Equal tables have equal versions.
+func (p PricingConfig) Version() string {
+	cached := "-"
+	if p.CachedInputPerMillion != nil {
+		cached = strconv.FormatFloat(*p.CachedInputPerMillion, 'g', -1, 64)
+	}
+	h := sha256.New()
+	for _, v := range []string{
+		strconv.FormatFloat(p.InputPerMillion, 'g', -1, 64),
+		strconv.FormatFloat(p.OutputPerMillion, 'g', -1, 64),
+		cached,
+		strconv.FormatFloat(p.PerAudioSecond, 'g', -1, 64),
+		strconv.FormatFloat(p.PerImage, 'g', -1, 64),
+	} {
+		h.Write([]byte(v))
+		h.Write([]byte{0})
+	}
+	return "cfg-" + hex.EncodeToString(h.Sum(nil))[:16]
+}
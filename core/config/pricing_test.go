@@ -0,0 +1,42 @@
+package config
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("PricingConfig", func() {
+	It("charges cached prompt tokens at the cached rate", func() {
+		cachedRate := 0.5
+		p := PricingConfig{InputPerMillion: 2, OutputPerMillion: 8, CachedInputPerMillion: &cachedRate}
+		cost := p.Cost(BillableUsage{PromptTokens: 1_000_000, CachedTokens: 400_000, CompletionTokens: 500_000})
+		Expect(cost).To(BeNumerically("~", 0.6*2+0.4*0.5+0.5*8, 1e-9))
+	})
+
+	It("charges cached tokens as input without a cached rate and clamps them to the prompt", func() {
+		p := PricingConfig{InputPerMillion: 2}
+		Expect(p.Cost(BillableUsage{PromptTokens: 1_000_000, CachedTokens: 5_000_000})).To(BeNumerically("~", 2, 1e-9))
+	})
+
+	It("prices audio seconds and images", func() {
+		p := PricingConfig{PerAudioSecond: 0.001, PerImage: 0.04}
+		Expect(p.Cost(BillableUsage{AudioSeconds: 90, Images: 2})).To(BeNumerically("~", 0.09+0.08, 1e-9))
+	})
+
+	It("versions equal tables equally", func() {
+		zero := 0.0
+		a := PricingConfig{InputPerMillion: 1, OutputPerMillion: 2}
+		Expect(a.Version()).To(Equal(PricingConfig{InputPerMillion: 1, OutputPerMillion: 2}.Version()))
+		Expect(a.Version()).To(HavePrefix("cfg-"))
+		Expect(a.Version()).ToNot(Equal(PricingConfig{InputPerMillion: 1, OutputPerMillion: 3}.Version()))
+		Expect(a.Version()).ToNot(Equal(PricingConfig{InputPerMillion: 1, OutputPerMillion: 2, CachedInputPerMillion: &zero}.Version()))
+	})
+
+	It("is rejected by model validation when a rate is negative", func() {
+		cfg := poolingTestConfig("", 0)
+		cfg.Pricing = PricingConfig{PerImage: -1}
+		_, err := cfg.Validate()
+		Expect(err).To(MatchError(ContainSubstring("per_image")))
+		Expect(PricingConfig{}.IsSet()).To(BeFalse())
+	})
+})
@@ -0,0 +1,315 @@
+package gallery
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	lconfig "github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/pkg/modelartifacts"
+	"github.com/mudler/LocalAI/pkg/system"
+	"github.com/mudler/LocalAI/pkg/utils"
+	"github.com/mudler/xlog"
+	"gopkg.in/yaml.v3"
+)
+
+// BundleManifestFile is the name of the manifest stored first in a model
+// bundle. It is never extracted into the models directory.
+const BundleManifestFile = ".localai-bundle.yaml"
+
+// ErrModelExists is returned when importing a bundle over an installed model.
+var ErrModelExists = errors.New("model already exists")
+
+// BundleManifest describes the content of a model bundle: a gzipped tarball
+// holding everything needed to run a model on a machine without network
+// access, except the backend, which is only referenced.
+type BundleManifest struct {
+	Name       string           `yaml:"name"`
+	Backend    BundleBackendRef `yaml:"backend,omitempty"`
+	Files      []string         `yaml:"files"`
+	ExportedAt string           `yaml:"exported_at"`
+}
+
+// BundleBackendRef identifies the backend a bundled model runs on, so that it
+// can be installed (or copied) on the target machine before the model is used.
+type BundleBackendRef struct {
+	Name    string `yaml:"name,omitempty"`
+	URI     string `yaml:"uri,omitempty"`
+	Version string `yaml:"version,omitempty"`
+	Digest  string `yaml:"digest,omitempty"`
+}
+
+// InstalledModelFiles returns the paths, relative to the models directory, of
+// everything an installed model is made of: its config, its gallery record,
+// its weights and its artifact snapshot. Entries may be directories; paths
+// that do not exist on disk are left out.
+func InstalledModelFiles(systemState *system.SystemState, name string) ([]string, error) {
+	modelsPath := systemState.Model.ModelsPath
+	configFile := filepath.Join(modelsPath, fmt.Sprintf("%s.yaml", name))
+	if err := utils.VerifyPath(fmt.Sprintf("%s.yaml", name), modelsPath); err != nil {
+		return nil, fmt.Errorf("failed to verify path %s: %w", configFile, err)
+	}
+	dat, err := os.ReadFile(configFile)
+	if err != nil {
+		return nil, fmt.Errorf("model %q is not installed: %w", name, err)
+	}
+
+	files, err := listModelFiles(systemState, name)
+	if err != nil {
+		return nil, err
+	}
+	files = append(files, configFile)
+
+	cfg := &lconfig.ModelConfig{}
+	if err := yaml.Unmarshal(dat, cfg); err != nil {
+		return nil, err
+	}
+	if len(cfg.Artifacts) > 0 && cfg.Artifacts[0].Resolved != nil {
+		if snapshot, err := modelartifacts.RelativeSnapshotPath(cfg.Artifacts[0].Resolved.CacheKey); err == nil {
+			files = append(files, filepath.Join(modelsPath, snapshot))
+		}
+	}
+
+	var relative []string
+	for _, f := range utils.Unique(files) {
+		if _, err := os.Stat(f); err != nil {
+			continue
+		}
+		rel, err := filepath.Rel(modelsPath, f)
+		if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		relative = append(relative, rel)
+	}
+	slices.Sort(relative)
+	return relative, nil
+}
+
+// ExportModel writes a bundle of the installed model to w.
+func ExportModel(systemState *system.SystemState, name string, w io.Writer) (*BundleManifest, error) {
+	files, err := InstalledModelFiles(systemState, name)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := &BundleManifest{
+		Name:       name,
+		ExportedAt: time.Now().UTC().Format(time.RFC3339),
+	}
+	manifest.Backend = bundleBackendRef(systemState, name)
+
+	modelsPath := systemState.Model.ModelsPath
+	// Directories are expanded so the manifest lists every regular file.
+	for _, f := range files {
+		err := filepath.WalkDir(filepath.Join(modelsPath, f), func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			info, err := os.Stat(path)
+			if err != nil {
+				return err
+			}
+			if info.Mode().IsRegular() {
+				rel, _ := filepath.Rel(modelsPath, path)
+				manifest.Files = append(manifest.Files, filepath.ToSlash(rel))
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	manifest.Files = utils.Unique(manifest.Files)
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	data, err := yaml.Marshal(manifest)
+	if err != nil {
+		return nil, err
+	}
+	if err := tw.WriteHeader(&tar.Header{Name: BundleManifestFile, Mode: 0644, Size: int64(len(data)), ModTime: time.Now()}); err != nil {
+		return nil, err
+	}
+	if _, err := tw.Write(data); err != nil {
+		return nil, err
+	}
+
+	for _, f := range manifest.Files {
+		xlog.Debug("Adding file to bundle", "model", name, "file", f)
+		if err := addBundleFile(tw, filepath.Join(modelsPath, filepath.FromSlash(f)), f); err != nil {
+			return nil, fmt.Errorf("failed to add %s to the bundle: %w", f, err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	return manifest, gz.Close()
+}
+
+// bundleBackendRef resolves the backend of the model's config against the
+// installed backends, to record where it was installed from.
+func bundleBackendRef(systemState *system.SystemState, name string) BundleBackendRef {
+	cfg, err := ReadConfigFile[lconfig.ModelConfig](filepath.Join(systemState.Model.ModelsPath, fmt.Sprintf("%s.yaml", name)))
+	if err != nil || cfg.Backend == "" {
+		return BundleBackendRef{}
+	}
+	ref := BundleBackendRef{Name: cfg.Backend}
+
+	backends, err := ListSystemBackends(systemState)
+	if err != nil {
+		return ref
+	}
+	if backend, ok := backends.Get(cfg.Backend); ok && backend.Metadata != nil {
+		ref.URI = backend.Metadata.URI
+		ref.Version = backend.Metadata.Version
+		ref.Digest = backend.Metadata.Digest
+	}
+	return ref
+}
+
+func addBundleFile(tw *tar.Writer, path, name string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if err := tw.WriteHeader(&tar.Header{Name: name, Mode: int64(info.Mode().Perm()), Size: info.Size(), ModTime: info.ModTime()}); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, f)
+	return err
+}
+
+// ImportModel extracts a bundle written by ExportModel into the models
+// directory. Unless overwrite is set, it refuses to replace any file of an
+// installed model, and checks so before writing anything. The files are
+// staged under temporary names and only moved into place once all of them
+// were extracted, so a failed import leaves the models directory as it
+// was. The caller is responsible for making the referenced backend
+// available.
+func ImportModel(systemState *system.SystemState, r io.Reader, overwrite bool) (*BundleManifest, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("not a model bundle: %w", err)
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+
+	hdr, err := tr.Next()
+	if err != nil || hdr.Name != BundleManifestFile {
+		return nil, fmt.Errorf("not a model bundle: missing %s", BundleManifestFile)
+	}
+	manifest := &BundleManifest{}
+	if err := yaml.NewDecoder(tr).Decode(manifest); err != nil {
+		return nil, fmt.Errorf("invalid bundle manifest: %w", err)
+	}
+	if manifest.Name == "" {
+		return nil, fmt.Errorf("invalid bundle manifest: no model name")
+	}
+
+	modelsPath := systemState.Model.ModelsPath
+	configName := fmt.Sprintf("%s.yaml", manifest.Name)
+	if err := utils.VerifyPath(configName, modelsPath); err != nil {
+		return nil, fmt.Errorf("invalid model name %q: %w", manifest.Name, err)
+	}
+	for _, f := range manifest.Files {
+		name := filepath.FromSlash(f)
+		if filepath.IsAbs(name) {
+			return nil, fmt.Errorf("unexpected file in bundle: %s", f)
+		}
+		if err := utils.VerifyPath(name, modelsPath); err != nil {
+			return nil, fmt.Errorf("unexpected file in bundle: %s: %w", f, err)
+		}
+		if overwrite {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(modelsPath, name)); err == nil {
+			return nil, fmt.Errorf("%w: %s (%s is installed)", ErrModelExists, manifest.Name, f)
+		}
+	}
+
+	var staged []string
+	discard := func() {
+		for _, path := range staged {
+			os.Remove(path + bundleTmpSuffix)
+		}
+	}
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			discard()
+			return nil, err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		if !slices.Contains(manifest.Files, hdr.Name) {
+			discard()
+			return nil, fmt.Errorf("unexpected file in bundle: %s", hdr.Name)
+		}
+		path := filepath.Join(modelsPath, filepath.FromSlash(hdr.Name))
+		if err := stageBundleFile(tr, path, hdr); err != nil {
+			discard()
+			return nil, fmt.Errorf("failed to extract %s: %w", hdr.Name, err)
+		}
+		staged = append(staged, path)
+	}
+
+	for i, path := range staged {
+		if err := os.Rename(path+bundleTmpSuffix, path); err != nil {
+			// Take back the files already moved into place too.
+			for _, done := range staged[:i] {
+				os.Remove(done)
+			}
+			staged = staged[i:]
+			discard()
+			return nil, fmt.Errorf("failed to install %s: %w", path, err)
+		}
+	}
+
+	return manifest, nil
+}
+
+// bundleTmpSuffix is appended to the files of a bundle while it is
+// extracted.
+const bundleTmpSuffix = ".import-tmp"
+
+// stageBundleFile writes the file under its temporary name, removing it
+// again when the write fails.
+func stageBundleFile(r io.Reader, path string, hdr *tar.Header) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return err
+	}
+	tmp := path + bundleTmpSuffix
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, fs.FileMode(hdr.Mode).Perm()|0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,238 @@
+package gallery_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"gopkg.in/yaml.v3"
+
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/gallery"
+	"github.com/mudler/LocalAI/pkg/system"
+)
+
+var _ = Describe("Installed model lifecycle", func() {
+	var tempdir, sourceDir string
+	var server *httptest.Server
+	var served map[string]string
+	var galleries []config.Gallery
+	var systemState *system.SystemState
+	// The gallery listing is cached on the name and URL pair, so every
+	// revision gets a gallery of its own.
+	galleryRevision := 0
+
+	newGallery := func(entries ...gallery.GalleryModel) {
+		out, err := yaml.Marshal(entries)
+		Expect(err).ToNot(HaveOccurred())
+		name := fmt.Sprintf("lifecycle-%d", galleryRevision)
+		galleryRevision++
+		galleryPath := filepath.Join(sourceDir, name+".yaml")
+		Expect(os.WriteFile(galleryPath, out, 0600)).To(Succeed())
+		galleries = []config.Gallery{{Name: name, URL: "file://" + galleryPath}}
+	}
+
+	// sourceFile publishes content on the test server and returns the
+	// gallery file that downloads it.
+	sourceFile := func(filename, content string) gallery.File {
+		path := fmt.Sprintf("/%d/%s", galleryRevision, filename)
+		served[path] = content
+		sum := sha256.Sum256([]byte(content))
+		return gallery.File{Filename: filename, URI: server.URL + path, SHA256: hex.EncodeToString(sum[:])}
+	}
+
+	entry := func(name string, files ...gallery.File) gallery.GalleryModel {
+		e := gallery.GalleryModel{Overrides: map[string]any{
+			"backend":    "llama-cpp",
+			"parameters": map[string]any{"model": files[0].Filename},
+		}}
+		e.Name = name
+		e.AdditionalFiles = files
+		return e
+	}
+
+	install := func(name string) {
+		Expect(gallery.InstallModelFromGallery(
+			context.TODO(), galleries, []config.Gallery{}, systemState, nil,
+			name, gallery.GalleryModel{}, func(string, string, string, float64) {}, false, false, false)).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		var err error
+		tempdir, err = os.MkdirTemp("", "model-lifecycle")
+		Expect(err).ToNot(HaveOccurred())
+		DeferCleanup(func() { Expect(os.RemoveAll(tempdir)).To(Succeed()) })
+		// file:// galleries must live under the models directory.
+		sourceDir = filepath.Join(tempdir, "source")
+		Expect(os.Mkdir(sourceDir, 0750)).To(Succeed())
+
+		served = map[string]string{}
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			content, ok := served[r.URL.Path]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			w.Write([]byte(content))
+		}))
+		DeferCleanup(server.Close)
+
+		systemState, err = system.GetSystemState(system.WithModelPath(tempdir))
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("lists the files an installed model is made of", func() {
+		newGallery(entry("tiny", sourceFile("tiny.gguf", "v1")))
+		install("tiny")
+
+		files, err := gallery.InstalledModelFiles(systemState, "tiny")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(files).To(ConsistOf("tiny.yaml", "._gallery_tiny.yaml", "tiny.gguf"))
+
+		_, err = gallery.InstalledModelFiles(systemState, "missing")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("detects and applies upgrades of gallery models", func() {
+		newGallery(entry("tiny", sourceFile("tiny.gguf", "v1")))
+		install("tiny")
+
+		upgrades, err := gallery.CheckModelUpgrades(context.TODO(), galleries, systemState)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(upgrades).To(BeEmpty())
+
+		newGallery(entry("tiny", sourceFile("tiny.gguf", "v2"), sourceFile("mmproj.gguf", "proj")))
+		upgrades, err = gallery.CheckModelUpgrades(context.TODO(), galleries, systemState)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(upgrades).To(HaveKey("tiny"))
+		Expect(upgrades["tiny"].EntryName).To(Equal("tiny"))
+		Expect(upgrades["tiny"].ChangedFiles).To(ConsistOf("tiny.gguf"))
+		Expect(upgrades["tiny"].NewFiles).To(ConsistOf("mmproj.gguf"))
+
+		Expect(gallery.UpgradeModel(context.TODO(), galleries, []config.Gallery{}, systemState, nil, upgrades["tiny"],
+			func(string, string, string, float64) {}, false, false, false)).To(Succeed())
+		Expect(os.ReadFile(filepath.Join(tempdir, "tiny.gguf"))).To(BeEquivalentTo("v2"))
+		Expect(filepath.Join(tempdir, "mmproj.gguf")).To(BeAnExistingFile())
+		Expect(filepath.Join(tempdir, "tiny.gguf.upgrade-backup")).ToNot(BeAnExistingFile())
+
+		upgrades, err = gallery.CheckModelUpgrades(context.TODO(), galleries, systemState)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(upgrades).To(BeEmpty())
+	})
+
+	It("keeps the installed files when an upgrade fails", func() {
+		newGallery(entry("tiny", sourceFile("tiny.gguf", "v1")))
+		install("tiny")
+
+		newGallery(entry("tiny", sourceFile("tiny.gguf", "v2")))
+		upgrades, err := gallery.CheckModelUpgrades(context.TODO(), galleries, systemState)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(upgrades["tiny"].ChangedFiles).To(ConsistOf("tiny.gguf"))
+
+		clear(served)
+		Expect(gallery.UpgradeModel(context.TODO(), galleries, []config.Gallery{}, systemState, nil, upgrades["tiny"],
+			func(string, string, string, float64) {}, false, false, false)).ToNot(Succeed())
+		Expect(os.ReadFile(filepath.Join(tempdir, "tiny.gguf"))).To(BeEquivalentTo("v1"))
+		Expect(filepath.Join(tempdir, "tiny.gguf.upgrade-backup")).ToNot(BeAnExistingFile())
+	})
+
+	It("exports a model to a bundle that imports on another machine", func() {
+		newGallery(entry("tiny", sourceFile("tiny.gguf", "weights")))
+		install("tiny")
+
+		var bundle bytes.Buffer
+		manifest, err := gallery.ExportModel(systemState, "tiny", &bundle)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(manifest.Name).To(Equal("tiny"))
+		Expect(manifest.Backend.Name).To(Equal("llama-cpp"))
+		Expect(manifest.Files).To(ConsistOf("tiny.yaml", "._gallery_tiny.yaml", "tiny.gguf"))
+
+		target, err := os.MkdirTemp("", "model-lifecycle-target")
+		Expect(err).ToNot(HaveOccurred())
+		DeferCleanup(os.RemoveAll, target)
+		targetState, err := system.GetSystemState(system.WithModelPath(target))
+		Expect(err).ToNot(HaveOccurred())
+
+		imported, err := gallery.ImportModel(targetState, bytes.NewReader(bundle.Bytes()), false)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(imported.Backend.Name).To(Equal("llama-cpp"))
+		Expect(os.ReadFile(filepath.Join(target, "tiny.gguf"))).To(BeEquivalentTo("weights"))
+		Expect(filepath.Join(target, "tiny.yaml")).To(BeAnExistingFile())
+		Expect(filepath.Join(target, gallery.BundleManifestFile)).ToNot(BeAnExistingFile())
+
+		_, err = gallery.ImportModel(targetState, bytes.NewReader(bundle.Bytes()), false)
+		Expect(err).To(MatchError(gallery.ErrModelExists))
+		_, err = gallery.ImportModel(targetState, bytes.NewReader(bundle.Bytes()), true)
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("refuses bundles writing outside the models directory", func() {
+		var bundle bytes.Buffer
+		gz := gzip.NewWriter(&bundle)
+		tw := tar.NewWriter(gz)
+		manifest, _ := yaml.Marshal(gallery.BundleManifest{Name: "evil", Files: []string{"evil.yaml", "../escaped"}})
+		for name, content := range map[string][]byte{gallery.BundleManifestFile: manifest} {
+			Expect(tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content))})).To(Succeed())
+			tw.Write(content)
+		}
+		Expect(tw.WriteHeader(&tar.Header{Name: "../escaped", Mode: 0644, Size: 1})).To(Succeed())
+		tw.Write([]byte("x"))
+		Expect(tw.Close()).To(Succeed())
+		Expect(gz.Close()).To(Succeed())
+
+		_, err := gallery.ImportModel(systemState, &bundle, false)
+		Expect(err).To(HaveOccurred())
+		Expect(filepath.Join(filepath.Dir(tempdir), "escaped")).ToNot(BeAnExistingFile())
+	})
+
+	// bundleOf writes a bundle of model "shared" with files, in order.
+	bundleOf := func(files ...string) *bytes.Buffer {
+		var bundle bytes.Buffer
+		gz := gzip.NewWriter(&bundle)
+		tw := tar.NewWriter(gz)
+		manifest, _ := yaml.Marshal(gallery.BundleManifest{Name: "shared", Files: files})
+		Expect(tw.WriteHeader(&tar.Header{Name: gallery.BundleManifestFile, Mode: 0644, Size: int64(len(manifest))})).To(Succeed())
+		tw.Write(manifest)
+		for _, f := range files {
+			Expect(tw.WriteHeader(&tar.Header{Name: f, Mode: 0644, Size: int64(len(f))})).To(Succeed())
+			tw.Write([]byte(f))
+		}
+		Expect(tw.Close()).To(Succeed())
+		Expect(gz.Close()).To(Succeed())
+		return &bundle
+	}
+
+	It("refuses bundles replacing any installed file without overwrite", func() {
+		Expect(os.WriteFile(filepath.Join(tempdir, "shared.gguf"), []byte("installed"), 0600)).To(Succeed())
+
+		_, err := gallery.ImportModel(systemState, bundleOf("shared.yaml", "shared.gguf"), false)
+		Expect(err).To(MatchError(gallery.ErrModelExists))
+		Expect(err).To(MatchError(ContainSubstring("shared.gguf")))
+		Expect(filepath.Join(tempdir, "shared.yaml")).ToNot(BeAnExistingFile())
+		Expect(os.ReadFile(filepath.Join(tempdir, "shared.gguf"))).To(BeEquivalentTo("installed"))
+	})
+
+	It("leaves nothing behind when a bundle fails to extract", func() {
+		bundle := bundleOf("shared.yaml", "shared.gguf")
+		// Cut the archive in the middle of the second file.
+		truncated := bundle.Bytes()[:bundle.Len()-40]
+
+		_, err := gallery.ImportModel(systemState, bytes.NewReader(truncated), false)
+		Expect(err).To(HaveOccurred())
+		entries, err := os.ReadDir(tempdir)
+		Expect(err).ToNot(HaveOccurred())
+		for _, e := range entries {
+			Expect(e.Name()).ToNot(HavePrefix("shared"))
+		}
+	})
+})
@@ -13,6 +13,7 @@ import (
 	"github.com/mudler/LocalAI/pkg/model"
 	"github.com/mudler/LocalAI/pkg/oci"
 	"github.com/mudler/LocalAI/pkg/system"
+	"github.com/mudler/LocalAI/pkg/utils"
 	"github.com/mudler/xlog"
 	cp "github.com/otiai10/copy"
 )
@@ -378,3 +379,168 @@ func UpgradeBackend(ctx context.Context, systemState *system.SystemState, modelL
 	xlog.Info("Backend upgraded successfully", "backend", backendName, "version", galleryEntry.Version)
 	return nil
 }
+
+// ModelUpgradeInfo holds details about an installed gallery model whose
+// gallery entry now points at different files than the ones installed.
+type ModelUpgradeInfo struct {
+	ModelName string `json:"model_name"`
+	// EntryName is the gallery entry the model is reinstalled from. For an
+	// entry with variants it is the entry itself, not the resolved variant,
+	// so a reinstall resolves (and honors pins) exactly like an install.
+	EntryName string `json:"entry_name"`
+	// ChangedFiles are the installed files whose checksum or source changed
+	// or that the entry no longer ships.
+	ChangedFiles []string `json:"changed_files"`
+	// NewFiles are the files the entry ships that are not installed.
+	NewFiles []string `json:"new_files,omitempty"`
+}
+
+// CheckModelUpgrades compares the files recorded when each gallery model was
+// installed against what its gallery entry currently ships. Models installed
+// outside the gallery, or whose entry has been removed, are skipped.
+func CheckModelUpgrades(ctx context.Context, galleries []config.Gallery, systemState *system.SystemState) (map[string]ModelUpgradeInfo, error) {
+	models, err := AvailableGalleryModels(galleries, systemState)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list available models: %w", err)
+	}
+
+	records, err := filepath.Glob(filepath.Join(systemState.Model.ModelsPath, galleryFileName("*")))
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]ModelUpgradeInfo)
+	for _, recordFile := range records {
+		name := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(recordFile), "._gallery_"), ".yaml")
+		if _, err := os.Stat(filepath.Join(systemState.Model.ModelsPath, fmt.Sprintf("%s.yaml", name))); err != nil {
+			continue
+		}
+		record, err := ReadConfigFile[ModelConfig](recordFile)
+		if err != nil {
+			xlog.Warn("Failed to read gallery record", "model", name, "error", err)
+			continue
+		}
+
+		entryName := name
+		if record.EntryName != "" {
+			entryName = record.EntryName
+		}
+		entry := FindGalleryElement(models, entryName)
+		if entry == nil {
+			continue
+		}
+		// The files come from the variant that was installed, if any.
+		source := entry
+		if record.ResolvedVariant != "" {
+			if source = FindGalleryElement(models, record.ResolvedVariant); source == nil {
+				continue
+			}
+		}
+
+		expected, err := galleryModelFiles(ctx, source, systemState)
+		if err != nil {
+			xlog.Warn("Failed to resolve gallery model files for upgrade check", "model", name, "error", err)
+			continue
+		}
+		changed, added := diffModelFiles(record.Files, expected)
+		if len(changed) > 0 || len(added) > 0 {
+			result[name] = ModelUpgradeInfo{
+				ModelName:    name,
+				EntryName:    entryName,
+				ChangedFiles: changed,
+				NewFiles:     added,
+			}
+		}
+	}
+	return result, nil
+}
+
+// galleryModelFiles returns the files an install of the entry would record:
+// those of its config URL followed by its own additional files.
+func galleryModelFiles(ctx context.Context, m *GalleryModel, systemState *system.SystemState) ([]File, error) {
+	var files []File
+	if m.URL != "" {
+		cfg, err := GetGalleryConfigFromURLWithContext[ModelConfig](ctx, m.URL, systemState.Model.ModelsPath)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, cfg.Files...)
+	}
+	return append(files, m.AdditionalFiles...), nil
+}
+
+// diffModelFiles compares installed and expected files by filename. A file
+// changed when its checksum differs, or its URI when neither side has one.
+func diffModelFiles(installed, expected []File) (changed, added []string) {
+	byName := make(map[string]File, len(expected))
+	for _, f := range expected {
+		byName[f.Filename] = f
+	}
+	seen := make(map[string]bool, len(installed))
+	for _, f := range installed {
+		seen[f.Filename] = true
+		want, ok := byName[f.Filename]
+		switch {
+		case !ok:
+			changed = append(changed, f.Filename)
+		case f.SHA256 != "" || want.SHA256 != "":
+			if !strings.EqualFold(f.SHA256, want.SHA256) {
+				changed = append(changed, f.Filename)
+			}
+		case f.URI != want.URI:
+			changed = append(changed, f.Filename)
+		}
+	}
+	for _, f := range expected {
+		if !seen[f.Filename] {
+			added = append(added, f.Filename)
+		}
+	}
+	return changed, added
+}
+
+// UpgradeModel reinstalls a model from its gallery entry. The downloader
+// keeps files that already exist, so the changed files are moved aside first
+// and restored if the reinstall fails.
+func UpgradeModel(ctx context.Context, modelGalleries, backendGalleries []config.Gallery, systemState *system.SystemState, modelLoader *model.ModelLoader, info ModelUpgradeInfo, downloadStatus func(string, string, string, float64), enforceScan, automaticallyInstallBackend, requireBackendIntegrity bool, options ...InstallOption) (err error) {
+	backups := map[string]string{}
+	defer func() {
+		for path, backupPath := range backups {
+			if err == nil {
+				os.Remove(backupPath)
+				continue
+			}
+			// Drop whatever the failed install left behind before restoring.
+			os.Remove(path)
+			if restoreErr := os.Rename(backupPath, path); restoreErr != nil {
+				xlog.Error("Failed to restore model file after failed upgrade", "file", path, "error", restoreErr)
+			}
+		}
+	}()
+	for _, f := range info.ChangedFiles {
+		if err := utils.VerifyPath(f, systemState.Model.ModelsPath); err != nil {
+			return fmt.Errorf("failed to verify path %s: %w", f, err)
+		}
+		path := filepath.Join(systemState.Model.ModelsPath, f)
+		backupPath := path + ".upgrade-backup"
+		os.Remove(backupPath)
+		if err := os.Rename(path, backupPath); err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return fmt.Errorf("failed to move outdated file %s aside: %w", f, err)
+		}
+		backups[path] = backupPath
+	}
+
+	var req GalleryModel
+	if info.ModelName != info.EntryName {
+		req.Name = info.ModelName
+	}
+	if err := InstallModelFromGallery(ctx, modelGalleries, backendGalleries, systemState, modelLoader, info.EntryName, req, downloadStatus, enforceScan, automaticallyInstallBackend, requireBackendIntegrity, options...); err != nil {
+		return fmt.Errorf("failed to reinstall model %q: %w", info.ModelName, err)
+	}
+
+	xlog.Info("Model upgraded successfully", "model", info.ModelName, "entry", info.EntryName)
+	return nil
+}
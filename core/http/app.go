@@ -1,6 +1,7 @@
 package http
 
 import (
+	"cmp"
 	"embed"
 	"errors"
 	"fmt"
@@ -10,6 +11,7 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -28,12 +30,15 @@ import (
 
 	"github.com/mudler/LocalAI/core/application"
 	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/core/services/batches"
 	"github.com/mudler/LocalAI/core/services/distributed"
 	"github.com/mudler/LocalAI/core/services/finetune"
 	"github.com/mudler/LocalAI/core/services/galleryop"
 	"github.com/mudler/LocalAI/core/services/messaging"
 	"github.com/mudler/LocalAI/core/services/nodes"
 	"github.com/mudler/LocalAI/core/services/quantization"
+	"github.com/mudler/LocalAI/core/services/storage"
+	"github.com/mudler/LocalAI/core/services/vectorstores"
 
 	"github.com/mudler/xlog"
 )
@@ -178,6 +183,10 @@ func setModelLoadingRetryAfter(loadErr *nodes.ModelLoadingError, c echo.Context)
 // @tag.description Peer-to-peer networking nodes and tokens
 // @tag.name rerank
 // @tag.description Document reranking
+// @tag.name files
+// @tag.description File uploads for the batch API (OpenAI-compatible)
+// @tag.name batches
+// @tag.description Asynchronous batch execution of JSONL requests (OpenAI-compatible)
 // @tag.name instructions
 // @tag.description API instruction discovery — browse instruction areas and get endpoint guides
 
@@ -349,6 +358,12 @@ func API(application *application.Application) (*echo.Echo, error) {
 		})
 	}
 
+	// OpenTelemetry server spans. Polling and probe paths would dominate
+	// every trace view without telling anything, so they are skipped.
+	e.Use(httpMiddleware.OTelTracing(func(c echo.Context) bool {
+		return slices.Contains(quietPaths, c.Request().URL.Path) || c.Path() == "/metrics"
+	}))
+
 	// Health Checks should always be exempt from auth, so register these first
 	routes.HealthRoutes(e, application.Ready)
 
@@ -587,9 +602,44 @@ func API(application *application.Application) (*echo.Echo, error) {
 	}
 
 	routes.RegisterOpenAIRoutes(e, requestExtractor, application)
+
+	// Batch API: batches and their files are persisted in the auth DB so a
+	// restarted (or another) replica resumes them; object storage mirrors the
+	// file contents in distributed mode.
+	var bService *batches.Service
+	if application.AuthDB() != nil {
+		var fileMgr *storage.FileManager
+		if d := application.Distributed(); d != nil {
+			fileMgr = d.FileMgr
+		}
+		bStore, err := batches.NewStore(application.AuthDB())
+		if err == nil {
+			batchDir := filepath.Join(cmp.Or(application.ApplicationConfig().DataPath, application.ApplicationConfig().DynamicConfigsDir, "."), "batches")
+			bService, err = batches.NewService(bStore, batchDir, fileMgr, application.ApplicationConfig().BatchConcurrency)
+		}
+		if err != nil {
+			xlog.Error("Failed to initialize batch service", "error", err)
+		}
+	}
+	routes.RegisterBatchRoutes(e, bService, requestExtractor, application)
+
+	// Vector stores build on the Files API: attached files are chunked,
+	// embedded and kept in the auth DB, and served from a local-store index.
+	var vsService *vectorstores.Service
+	if bService != nil {
+		vsStore, err := vectorstores.NewStore(application.AuthDB())
+		if err != nil {
+			xlog.Error("Failed to initialize vector store service", "error", err)
+		} else {
+			vsService = vectorstores.NewService(vsStore, bService, application.Embedder, application.VectorStore,
+				application.ApplicationConfig().VectorStoreEmbeddingModel)
+		}
+	}
+	routes.RegisterVectorStoreRoutes(e, vsService, application)
+
 	routes.RegisterAnthropicRoutes(e, requestExtractor, application)
-	routes.RegisterOpenResponsesRoutes(e, requestExtractor, application)
-	routes.RegisterOllamaRoutes(e, requestExtractor, application)
+	routes.RegisterOpenResponsesRoutes(e, requestExtractor, application, vsService)
+	routes.RegisterOllamaRoutes(e, requestExtractor, application, adminMiddleware)
 	if application.ApplicationConfig().OllamaAPIRootEndpoint {
 		routes.RegisterOllamaRootEndpoint(e)
 	}
@@ -705,6 +755,10 @@ func API(application *application.Application) (*echo.Echo, error) {
 		}
 	}
 	routes.RegisterJINARoutes(e, requestExtractor, application.ModelConfigLoader(), application.ModelLoader(), application.ApplicationConfig())
+	routes.RegisterMCPSampler(e, requestExtractor, application)
+	if !application.ApplicationConfig().DisableMCP {
+		routes.RegisterMCPServerRoutes(e, requestExtractor, application)
+	}
 
 	// Note: 404 handling is done via HTTPErrorHandler above, no need for catch-all route
 
@@ -112,7 +112,10 @@ func RevokeAPIKey(db *gorm.DB, keyID, userID string) error {
 	if result.RowsAffected == 0 {
 		return fmt.Errorf("API key not found or not owned by user")
 	}
-	return result.Error
+	if result.Error != nil {
+		return result.Error
+	}
+	return deleteKeyQuotaRules(db, keyID, userID)
 }
 
 // CleanExpiredAPIKeys removes all API keys that have passed their expiry time.
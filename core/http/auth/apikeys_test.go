@@ -209,4 +209,28 @@ var _ = Describe("API Keys", func() {
 			Expect(err).To(HaveOccurred())
 		})
 	})
+
+	Describe("Priority", func() {
+		It("stores the priority of a key and validates it", func() {
+			plaintext, record, err := auth.CreateAPIKey(db, user.ID, "batch", auth.RoleUser, hmacSecret, nil)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(auth.SetAPIKeyPriority(db, record.ID, user.ID, auth.PriorityLow)).To(Succeed())
+			key, err := auth.ValidateAPIKey(db, plaintext, hmacSecret)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(key.Priority).To(Equal(auth.PriorityLow))
+
+			Expect(auth.SetAPIKeyPriority(db, record.ID, user.ID, "urgent")).ToNot(Succeed())
+			other := createTestUser(db, "other@example.com", auth.RoleUser, auth.ProviderGitHub)
+			Expect(auth.SetAPIKeyPriority(db, record.ID, other.ID, auth.PriorityLow)).ToNot(Succeed())
+		})
+
+		It("never lets a key outrank its role", func() {
+			Expect(auth.PriorityAllowed(auth.RoleUser, auth.PriorityLow)).To(BeTrue())
+			Expect(auth.PriorityAllowed(auth.RoleUser, auth.PriorityNormal)).To(BeTrue())
+			Expect(auth.PriorityAllowed(auth.RoleUser, auth.PriorityHigh)).To(BeFalse())
+			Expect(auth.PriorityAllowed(auth.RoleAdmin, auth.PriorityHigh)).To(BeTrue())
+			Expect(auth.PriorityAllowed(auth.RoleAdmin, "")).To(BeFalse())
+		})
+	})
 })
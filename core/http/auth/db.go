@@ -37,6 +37,15 @@ func InitDB(databaseURL string) (*gorm.DB, error) {
 		return nil, fmt.Errorf("failed to migrate auth tables: %w", err)
 	}
 
+	// Quota rules gained an API-key scope: the unique index now spans
+	// (user_id, api_key_id, model), and the old (user_id, model) one would
+	// reject a key-scoped rule next to the user-wide rule for that model.
+	if db.Migrator().HasIndex(&QuotaRule{}, "idx_quota_user_model") {
+		if err := db.Migrator().DropIndex(&QuotaRule{}, "idx_quota_user_model"); err != nil {
+			return nil, fmt.Errorf("failed to drop legacy quota index: %w", err)
+		}
+	}
+
 	// Backfill: users created before the provider column existed have an empty
 	// provider - treat them as local accounts so the UI can identify them.
 	db.Exec("UPDATE users SET provider = ? WHERE provider = '' OR provider IS NULL", ProviderLocal)
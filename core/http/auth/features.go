@@ -22,18 +22,28 @@ var RouteFeatureRegistry = []RouteFeature{
 	// Anthropic
 	{"POST", "/v1/messages", FeatureChat},
 	{"POST", "/messages", FeatureChat},
+	{"POST", "/v1/messages/count_tokens", FeatureChat},
+	{"POST", "/messages/count_tokens", FeatureChat},
 
 	// Open Responses
 	{"POST", "/v1/responses", FeatureChat},
 	{"POST", "/responses", FeatureChat},
 	{"GET", "/v1/responses", FeatureChat},
 	{"GET", "/responses", FeatureChat},
+	{"POST", "/v1/conversations", FeatureChat},
+	{"POST", "/conversations", FeatureChat},
 
 	// Embeddings
 	{"POST", "/v1/embeddings", FeatureEmbeddings},
 	{"POST", "/embeddings", FeatureEmbeddings},
 	{"POST", "/v1/engines/:model/embeddings", FeatureEmbeddings},
 
+	// Vector stores (adding files and searching run the embedding model)
+	{"POST", "/v1/vector_stores/:vector_store_id/files", FeatureEmbeddings},
+	{"POST", "/vector_stores/:vector_store_id/files", FeatureEmbeddings},
+	{"POST", "/v1/vector_stores/:vector_store_id/search", FeatureEmbeddings},
+	{"POST", "/vector_stores/:vector_store_id/search", FeatureEmbeddings},
+
 	// Images
 	{"POST", "/v1/images/generations", FeatureImages},
 	{"POST", "/images/generations", FeatureImages},
@@ -41,10 +51,16 @@ var RouteFeatureRegistry = []RouteFeature{
 	{"POST", "/images/inpainting", FeatureImages},
 	{"POST", "/v1/images/upscale", FeatureImages},
 	{"POST", "/images/upscale", FeatureImages},
+	{"POST", "/v1/images/edits", FeatureImages},
+	{"POST", "/images/edits", FeatureImages},
+	{"POST", "/v1/images/variations", FeatureImages},
+	{"POST", "/images/variations", FeatureImages},
 
 	// Audio transcription
 	{"POST", "/v1/audio/transcriptions", FeatureAudioTranscription},
 	{"POST", "/audio/transcriptions", FeatureAudioTranscription},
+	{"POST", "/v1/audio/translations", FeatureAudioTranscription},
+	{"POST", "/audio/translations", FeatureAudioTranscription},
 
 	// Audio diarization (speaker turns)
 	{"POST", "/v1/audio/diarization", FeatureAudioDiarization},
@@ -110,6 +126,7 @@ var RouteFeatureRegistry = []RouteFeature{
 	{"POST", "/v1/mcp/chat/completions", FeatureMCP},
 	{"POST", "/mcp/v1/chat/completions", FeatureMCP},
 	{"POST", "/mcp/chat/completions", FeatureMCP},
+	{"*", "/mcp", FeatureMCP},
 
 	// Tokenize
 	{"POST", "/v1/tokenize", FeatureTokenize},
@@ -155,6 +172,13 @@ var RouteFeatureRegistry = []RouteFeature{
 	{"GET", "/api/quantization/jobs/:id/download", FeatureQuantization},
 }
 
+// quotaExemptRoutes are routes of RouteFeatureRegistry that run no model,
+// so RequireQuota lets them through even when the user is over quota.
+var quotaExemptRoutes = map[string]bool{
+	"POST:/v1/messages/count_tokens": true,
+	"POST:/messages/count_tokens":    true,
+}
+
 // FeatureMeta describes a feature for the admin API/UI.
 type FeatureMeta struct {
 	Key          string `json:"key"`
@@ -133,6 +133,35 @@ func RequireAdmin() echo.MiddlewareFunc {
 	}
 }
 
+// RequireAgentWorker returns middleware that checks the user is an
+// agent-worker service account or an admin.
+func RequireAgentWorker() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			user := GetUser(c)
+			if user == nil {
+				return c.JSON(http.StatusUnauthorized, schema.ErrorResponse{
+					Error: &schema.APIError{
+						Message: "Authentication required",
+						Code:    http.StatusUnauthorized,
+						Type:    "authentication_error",
+					},
+				})
+			}
+			if user.Role != RoleAdmin && user.Provider != ProviderAgentWorker {
+				return c.JSON(http.StatusForbidden, schema.ErrorResponse{
+					Error: &schema.APIError{
+						Message: "Agent worker access required",
+						Code:    http.StatusForbidden,
+						Type:    "authorization_error",
+					},
+				})
+			}
+			return next(c)
+		}
+	}
+}
+
 // NoopMiddleware returns a middleware that does nothing (pass-through).
 // Used when auth is disabled to satisfy route registration that expects
 // an admin middleware parameter.
@@ -208,6 +237,32 @@ func GetUser(c echo.Context) *User {
 	return u
 }
 
+// GetUserID returns the ID of the authenticated user, or empty string.
+func GetUserID(c echo.Context) string {
+	if u := GetUser(c); u != nil {
+		return u.ID
+	}
+	return ""
+}
+
+// SetUser marks u as the authenticated user of the request. Used by
+// in-process dispatchers (e.g. the batch runner) that replay a request on
+// behalf of a user authenticated earlier.
+func SetUser(c echo.Context, u *User) {
+	c.Set(contextKeyUser, u)
+	c.Set(contextKeyRole, u.Role)
+}
+
+// SetSource records how the request was authenticated, with the API key
+// used for UsageSourceAPIKey. Like SetUser, it lets in-process dispatchers
+// keep the attribution of the request they replay.
+func SetSource(c echo.Context, source string, key *UserAPIKey) {
+	c.Set(contextKeySource, source)
+	if key != nil {
+		c.Set(contextKeyAPIKey, key)
+	}
+}
+
 // GetUserRole returns the role of the authenticated user, or empty string.
 func GetUserRole(c echo.Context) string {
 	role, _ := c.Get(contextKeyRole).(string)
@@ -386,9 +441,11 @@ func extractModelFromRequest(c echo.Context) string {
 	return ""
 }
 
-// RequireQuota returns a global middleware that enforces per-user quota rules.
+// RequireQuota returns a global middleware that enforces per-user quota rules,
+// including the spend budgets of rules with MaxCost.
 // If no auth DB is provided, it's a no-op. Admin users always bypass quotas.
-// Only inference routes (those listed in RouteFeatureRegistry) count toward quota.
+// Only inference routes (those listed in RouteFeatureRegistry) count toward quota,
+// except the ones in quotaExemptRoutes, such as token counting.
 func RequireQuota(db *gorm.DB) echo.MiddlewareFunc {
 	if db == nil {
 		return NoopMiddleware()
@@ -397,6 +454,9 @@ func RequireQuota(db *gorm.DB) echo.MiddlewareFunc {
 	// should count toward quota. Mirrors RequireRouteFeature's approach.
 	inferenceRoutes := map[string]bool{}
 	for _, rf := range RouteFeatureRegistry {
+		if quotaExemptRoutes[rf.Method+":"+rf.Pattern] {
+			continue
+		}
 		inferenceRoutes[rf.Method+":"+rf.Pattern] = true
 	}
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
@@ -418,14 +478,26 @@ func RequireQuota(db *gorm.DB) echo.MiddlewareFunc {
 
 			model := extractModelFromRequest(c)
 
-			exceeded, retryAfter, msg := QuotaExceeded(db, user.ID, model)
+			apiKeyID := ""
+			if key := GetAPIKey(c); key != nil {
+				apiKeyID = key.ID
+			}
+
+			exceeded, kind, retryAfter, msg := QuotaExceeded(db, user.ID, apiKeyID, model)
 			if exceeded {
+				// An exhausted budget uses OpenAI's insufficient_quota type,
+				// which clients already treat as "stop retrying" rather than
+				// as a transient rate limit.
+				errType := "quota_exceeded"
+				if kind == QuotaKindBudget {
+					errType = "insufficient_quota"
+				}
 				c.Response().Header().Set("Retry-After", fmt.Sprintf("%d", retryAfter))
 				return c.JSON(http.StatusTooManyRequests, schema.ErrorResponse{
 					Error: &schema.APIError{
 						Message: msg,
 						Code:    http.StatusTooManyRequests,
-						Type:    "quota_exceeded",
+						Type:    errType,
 					},
 				})
 			}
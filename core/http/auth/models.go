@@ -48,6 +48,7 @@ type UserAPIKey struct {
 	KeyHash   string `gorm:"size:64;uniqueIndex"`
 	KeyPrefix string `gorm:"size:12"` // first 8 chars of key for display
 	Role      string `gorm:"size:20"`
+	Priority  string `gorm:"size:20"` // admission priority class; empty = role default
 	CreatedAt time.Time
 	ExpiresAt *time.Time `gorm:"index"`
 	LastUsed  *time.Time
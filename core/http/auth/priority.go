@@ -0,0 +1,68 @@
+package auth
+
+import (
+	"fmt"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// Admission priority classes. When a model queues requests for its
+// concurrency slots (limits.queue_size), higher classes are served
+// first.
+const (
+	PriorityHigh   = "high"
+	PriorityNormal = "normal"
+	PriorityLow    = "low"
+)
+
+var priorityRank = map[string]int{PriorityLow: 0, PriorityNormal: 1, PriorityHigh: 2}
+
+// ValidPriority reports whether p names a priority class.
+func ValidPriority(p string) bool {
+	_, ok := priorityRank[p]
+	return ok
+}
+
+// RolePriority returns the default priority class of a role: admins
+// are served first, everybody else is normal.
+func RolePriority(role string) string {
+	if role == RoleAdmin {
+		return PriorityHigh
+	}
+	return PriorityNormal
+}
+
+// PriorityAllowed reports whether a user with the given role may give
+// an API key the priority p. Keys can lower their class (e.g. for batch
+// scripts) but never rise above the role default.
+func PriorityAllowed(role, p string) bool {
+	return ValidPriority(p) && priorityRank[p] <= priorityRank[RolePriority(role)]
+}
+
+// GetPriority returns the admission priority class of the request: the
+// one set on its API key, or else the default of the user's role. A key
+// never outranks its user, even one demoted after the key was created.
+func GetPriority(c echo.Context) string {
+	role := GetUserRole(c)
+	if k := GetAPIKey(c); k != nil && PriorityAllowed(role, k.Priority) {
+		return k.Priority
+	}
+	return RolePriority(role)
+}
+
+// SetAPIKeyPriority sets the priority class of an API key owned by
+// userID. An empty priority restores the role default.
+func SetAPIKeyPriority(db *gorm.DB, keyID, userID, priority string) error {
+	if priority != "" && !ValidPriority(priority) {
+		return fmt.Errorf("invalid priority %q", priority)
+	}
+	result := db.Model(&UserAPIKey{}).Where("id = ? AND user_id = ?", keyID, userID).Update("priority", priority)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("API key not found or not owned by user")
+	}
+	return nil
+}
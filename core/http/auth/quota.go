@@ -9,14 +9,22 @@ import (
 	"gorm.io/gorm"
 )
 
-// QuotaRule defines a rate/token limit for a user, optionally scoped to a model.
+// QuotaRule defines a rate, token or spend limit for a user, optionally
+// scoped to a model and to one of the user's API keys.
+//
+// MaxCost is a budget in the currency unit of the model price tables
+// (see config.PricingConfig); spend is the sum of the UsageRecord costs
+// the billing recorder computed. Requests to unpriced models cost
+// nothing and so never exhaust a budget.
 type QuotaRule struct {
-	ID             string `gorm:"primaryKey;size:36"`
-	UserID         string `gorm:"size:36;uniqueIndex:idx_quota_user_model"`
-	Model          string `gorm:"size:255;uniqueIndex:idx_quota_user_model"` // "" = all models
-	MaxRequests    *int64 // nil = no request limit
-	MaxTotalTokens *int64 // nil = no token limit
-	WindowSeconds  int64  // e.g., 3600 = 1h
+	ID             string   `gorm:"primaryKey;size:36"`
+	UserID         string   `gorm:"size:36;uniqueIndex:idx_quota_user_key_model"`
+	APIKeyID       string   `gorm:"size:36;not null;default:'';uniqueIndex:idx_quota_user_key_model"` // "" = every key and session of the user
+	Model          string   `gorm:"size:255;uniqueIndex:idx_quota_user_key_model"`                    // "" = all models
+	MaxRequests    *int64   // nil = no request limit
+	MaxTotalTokens *int64   // nil = no token limit
+	MaxCost        *float64 // nil = no spend limit
+	WindowSeconds  int64    // e.g., 3600 = 1h
 	CreatedAt      time.Time
 	UpdatedAt      time.Time
 	User           User `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
@@ -24,47 +32,60 @@ type QuotaRule struct {
 
 // QuotaStatus is returned to clients with current usage included.
 type QuotaStatus struct {
-	ID              string `json:"id"`
-	Model           string `json:"model"`
-	MaxRequests     *int64 `json:"max_requests"`
-	MaxTotalTokens  *int64 `json:"max_total_tokens"`
-	Window          string `json:"window"`
-	CurrentRequests int64  `json:"current_requests"`
-	CurrentTokens   int64  `json:"current_total_tokens"`
-	ResetsAt        string `json:"resets_at,omitempty"`
+	ID              string   `json:"id"`
+	Model           string   `json:"model"`
+	APIKeyID        string   `json:"api_key_id,omitempty"`
+	APIKeyName      string   `json:"api_key_name,omitempty"`
+	MaxRequests     *int64   `json:"max_requests"`
+	MaxTotalTokens  *int64   `json:"max_total_tokens"`
+	MaxCost         *float64 `json:"max_cost"`
+	Window          string   `json:"window"`
+	CurrentRequests int64    `json:"current_requests"`
+	CurrentTokens   int64    `json:"current_total_tokens"`
+	CurrentCost     float64  `json:"current_cost"`
+	RemainingCost   *float64 `json:"remaining_cost,omitempty"` // set when MaxCost is; never negative
+	ResetsAt        string   `json:"resets_at,omitempty"`
 }
 
 // ── CRUD ──
 
-// CreateOrUpdateQuotaRule upserts a quota rule for the given user+model.
+// CreateOrUpdateQuotaRule upserts a request/token quota rule for the
+// given user+model, covering all of the user's keys. It is
+// UpsertQuotaRule without a budget or key scope.
 func CreateOrUpdateQuotaRule(db *gorm.DB, userID, model string, maxReqs, maxTokens *int64, windowSecs int64) (*QuotaRule, error) {
+	return UpsertQuotaRule(db, QuotaRule{
+		UserID:         userID,
+		Model:          model,
+		MaxRequests:    maxReqs,
+		MaxTotalTokens: maxTokens,
+		WindowSeconds:  windowSecs,
+	})
+}
+
+// UpsertQuotaRule creates the rule for rule's (user, API key, model)
+// scope, or replaces the limits and window of the existing one.
+func UpsertQuotaRule(db *gorm.DB, rule QuotaRule) (*QuotaRule, error) {
 	var existing QuotaRule
-	err := db.Where("user_id = ? AND model = ?", userID, model).First(&existing).Error
+	err := db.Where("user_id = ? AND api_key_id = ? AND model = ?", rule.UserID, rule.APIKeyID, rule.Model).First(&existing).Error
 	if err == gorm.ErrRecordNotFound {
-		rule := QuotaRule{
-			ID:             uuid.New().String(),
-			UserID:         userID,
-			Model:          model,
-			MaxRequests:    maxReqs,
-			MaxTotalTokens: maxTokens,
-			WindowSeconds:  windowSecs,
-		}
+		rule.ID = uuid.New().String()
 		if err := db.Create(&rule).Error; err != nil {
 			return nil, err
 		}
-		quotaCache.invalidateUser(userID)
+		quotaCache.invalidateUser(rule.UserID)
 		return &rule, nil
 	}
 	if err != nil {
 		return nil, err
 	}
-	existing.MaxRequests = maxReqs
-	existing.MaxTotalTokens = maxTokens
-	existing.WindowSeconds = windowSecs
+	existing.MaxRequests = rule.MaxRequests
+	existing.MaxTotalTokens = rule.MaxTotalTokens
+	existing.MaxCost = rule.MaxCost
+	existing.WindowSeconds = rule.WindowSeconds
 	if err := db.Save(&existing).Error; err != nil {
 		return nil, err
 	}
-	quotaCache.invalidateUser(userID)
+	quotaCache.invalidateUser(rule.UserID)
 	return &existing, nil
 }
 
@@ -87,23 +108,39 @@ func DeleteQuotaRule(db *gorm.DB, ruleID, userID string) error {
 	return nil
 }
 
+// deleteKeyQuotaRules removes the rules scoped to an API key that no
+// longer exists. They could never match again, but would keep showing
+// up in quota listings.
+func deleteKeyQuotaRules(db *gorm.DB, keyID, userID string) error {
+	if err := db.Where("api_key_id = ? AND user_id = ?", keyID, userID).Delete(&QuotaRule{}).Error; err != nil {
+		return err
+	}
+	quotaCache.invalidateUser(userID)
+	return nil
+}
+
 // ── Usage queries ──
 
 type usageCounts struct {
 	RequestCount int64
 	TotalTokens  int64
+	TotalCost    float64
 }
 
-// getUsageSince counts requests and tokens for a user since the given time.
-func getUsageSince(db *gorm.DB, userID string, since time.Time, model string) (usageCounts, error) {
+// getUsageSince counts requests, tokens and spend for a user since the
+// given time, narrowed to one API key and/or model when those are set.
+func getUsageSince(db *gorm.DB, userID, apiKeyID string, since time.Time, model string) (usageCounts, error) {
 	var result usageCounts
 	q := db.Model(&UsageRecord{}).
-		Select("COUNT(*) as request_count, COALESCE(SUM(total_tokens), 0) as total_tokens").
+		Select("COUNT(*) as request_count, COALESCE(SUM(total_tokens), 0) as total_tokens, COALESCE(SUM(cost_usd), 0) as total_cost").
 		Where("user_id = ? AND created_at >= ?", userID, since)
+	if apiKeyID != "" {
+		q = q.Where("api_key_id = ?", apiKeyID)
+	}
 	if model != "" {
 		q = q.Where("model = ?", model)
 	}
-	if err := q.Row().Scan(&result.RequestCount, &result.TotalTokens); err != nil {
+	if err := q.Row().Scan(&result.RequestCount, &result.TotalTokens, &result.TotalCost); err != nil {
 		return result, err
 	}
 	return result, nil
@@ -115,43 +152,78 @@ func GetQuotaStatuses(db *gorm.DB, userID string) ([]QuotaStatus, error) {
 	if err != nil {
 		return nil, err
 	}
+	keyNames := map[string]string{}
+	for _, r := range rules {
+		if r.APIKeyID != "" {
+			keys, err := ListAPIKeys(db, userID)
+			if err == nil {
+				for _, k := range keys {
+					keyNames[k.ID] = k.Name
+				}
+			}
+			break
+		}
+	}
 	statuses := make([]QuotaStatus, 0, len(rules))
 	now := time.Now()
 	for _, r := range rules {
 		windowStart := now.Add(-time.Duration(r.WindowSeconds) * time.Second)
-		counts, err := getUsageSince(db, userID, windowStart, r.Model)
+		counts, err := getUsageSince(db, userID, r.APIKeyID, windowStart, r.Model)
 		if err != nil {
 			counts = usageCounts{}
 		}
-		statuses = append(statuses, QuotaStatus{
+		status := QuotaStatus{
 			ID:              r.ID,
 			Model:           r.Model,
+			APIKeyID:        r.APIKeyID,
+			APIKeyName:      keyNames[r.APIKeyID],
 			MaxRequests:     r.MaxRequests,
 			MaxTotalTokens:  r.MaxTotalTokens,
+			MaxCost:         r.MaxCost,
 			Window:          formatWindowDuration(r.WindowSeconds),
 			CurrentRequests: counts.RequestCount,
 			CurrentTokens:   counts.TotalTokens,
+			CurrentCost:     counts.TotalCost,
 			ResetsAt:        windowStart.Add(time.Duration(r.WindowSeconds) * time.Second).UTC().Format(time.RFC3339),
-		})
+		}
+		if r.MaxCost != nil {
+			remaining := max(*r.MaxCost-counts.TotalCost, 0)
+			status.RemainingCost = &remaining
+		}
+		statuses = append(statuses, status)
 	}
 	return statuses, nil
 }
 
 // ── Quota check (used by middleware) ──
 
+// Kinds of quota violation reported by QuotaExceeded.
+const (
+	QuotaKindRequests = "requests"
+	QuotaKindTokens   = "tokens"
+	QuotaKindBudget   = "budget"
+)
+
 // QuotaExceeded checks whether the user has exceeded any applicable quota rule.
-// Returns (exceeded bool, retryAfterSeconds int64, message string).
-func QuotaExceeded(db *gorm.DB, userID, model string) (bool, int64, string) {
+// apiKeyID is the key the request authenticated with ("" for sessions and
+// legacy keys); rules scoped to another key do not apply.
+// Returns (exceeded bool, kind string, retryAfterSeconds int64, message string),
+// where kind is one of the QuotaKind* constants.
+func QuotaExceeded(db *gorm.DB, userID, apiKeyID, model string) (bool, string, int64, string) {
 	rules := quotaCache.getRules(db, userID)
 	if len(rules) == 0 {
-		return false, 0, ""
+		return false, "", 0, ""
 	}
 
 	now := time.Now()
+	applies := func(r QuotaRule) bool {
+		// Model-specific rules match that model, global (empty) applies to all;
+		// likewise for key-scoped rules.
+		return (r.Model == "" || r.Model == model) && (r.APIKeyID == "" || r.APIKeyID == apiKeyID)
+	}
 
 	for _, r := range rules {
-		// Check if rule applies: model-specific rules match that model, global (empty) applies to all.
-		if r.Model != "" && r.Model != model {
+		if !applies(r) {
 			continue
 		}
 
@@ -159,49 +231,56 @@ func QuotaExceeded(db *gorm.DB, userID, model string) (bool, int64, string) {
 		retryAfter := r.WindowSeconds // worst case: full window
 
 		// Try cache first
-		counts, ok := quotaCache.getUsage(userID, r.Model, windowStart)
+		counts, ok := quotaCache.getUsage(userID, r.APIKeyID, r.Model, windowStart)
 		if !ok {
 			var err error
-			counts, err = getUsageSince(db, userID, windowStart, r.Model)
+			counts, err = getUsageSince(db, userID, r.APIKeyID, windowStart, r.Model)
 			if err != nil {
 				continue // on error, don't block the request
 			}
-			quotaCache.setUsage(userID, r.Model, windowStart, counts)
+			quotaCache.setUsage(userID, r.APIKeyID, r.Model, windowStart, counts)
 		}
 
+		scope := "all models"
+		if r.Model != "" {
+			scope = "model " + r.Model
+		}
+		if r.APIKeyID != "" {
+			scope += " with this API key"
+		}
 		if r.MaxRequests != nil && counts.RequestCount >= *r.MaxRequests {
-			scope := "all models"
-			if r.Model != "" {
-				scope = "model " + r.Model
-			}
-			return true, retryAfter, fmt.Sprintf(
+			return true, QuotaKindRequests, retryAfter, fmt.Sprintf(
 				"Request quota exceeded for %s: %d/%d requests in %s window",
 				scope, counts.RequestCount, *r.MaxRequests, formatWindowDuration(r.WindowSeconds),
 			)
 		}
 		if r.MaxTotalTokens != nil && counts.TotalTokens >= *r.MaxTotalTokens {
-			scope := "all models"
-			if r.Model != "" {
-				scope = "model " + r.Model
-			}
-			return true, retryAfter, fmt.Sprintf(
+			return true, QuotaKindTokens, retryAfter, fmt.Sprintf(
 				"Token quota exceeded for %s: %d/%d tokens in %s window",
 				scope, counts.TotalTokens, *r.MaxTotalTokens, formatWindowDuration(r.WindowSeconds),
 			)
 		}
+		if r.MaxCost != nil && counts.TotalCost >= *r.MaxCost {
+			return true, QuotaKindBudget, retryAfter, fmt.Sprintf(
+				"Budget exhausted for %s: %.4f/%.4f spent in %s window",
+				scope, counts.TotalCost, *r.MaxCost, formatWindowDuration(r.WindowSeconds),
+			)
+		}
 	}
 
 	// Optimistic increment: bump cached counters so subsequent requests in the
 	// same cache window see an updated count without re-querying the DB.
+	// Spend is only known once the request completes, so budgets catch up
+	// when the cached usage expires.
 	for _, r := range rules {
-		if r.Model != "" && r.Model != model {
+		if !applies(r) {
 			continue
 		}
 		windowStart := now.Add(-time.Duration(r.WindowSeconds) * time.Second)
-		quotaCache.incrementUsage(userID, r.Model, windowStart)
+		quotaCache.incrementUsage(userID, r.APIKeyID, r.Model, windowStart)
 	}
 
-	return false, 0, ""
+	return false, "", 0, ""
 }
 
 // ── In-memory cache ──
@@ -211,7 +290,7 @@ var quotaCache = newQuotaCacheStore()
 type quotaCacheStore struct {
 	mu    sync.RWMutex
 	rules map[string]cachedRules // userID -> rules
-	usage map[string]cachedUsage // "userID|model|windowStart" -> counts
+	usage map[string]cachedUsage // "userID|apiKeyID|model|windowStart" -> counts
 }
 
 type cachedRules struct {
@@ -262,12 +341,12 @@ func (c *quotaCacheStore) invalidateUser(userID string) {
 	c.mu.Unlock()
 }
 
-func usageKey(userID, model string, windowStart time.Time) string {
-	return userID + "|" + model + "|" + windowStart.Truncate(time.Second).Format(time.RFC3339)
+func usageKey(userID, apiKeyID, model string, windowStart time.Time) string {
+	return userID + "|" + apiKeyID + "|" + model + "|" + windowStart.Truncate(time.Second).Format(time.RFC3339)
 }
 
-func (c *quotaCacheStore) getUsage(userID, model string, windowStart time.Time) (usageCounts, bool) {
-	key := usageKey(userID, model, windowStart)
+func (c *quotaCacheStore) getUsage(userID, apiKeyID, model string, windowStart time.Time) (usageCounts, bool) {
+	key := usageKey(userID, apiKeyID, model, windowStart)
 	c.mu.RLock()
 	cached, ok := c.usage[key]
 	c.mu.RUnlock()
@@ -277,15 +356,15 @@ func (c *quotaCacheStore) getUsage(userID, model string, windowStart time.Time)
 	return usageCounts{}, false
 }
 
-func (c *quotaCacheStore) setUsage(userID, model string, windowStart time.Time, counts usageCounts) {
-	key := usageKey(userID, model, windowStart)
+func (c *quotaCacheStore) setUsage(userID, apiKeyID, model string, windowStart time.Time, counts usageCounts) {
+	key := usageKey(userID, apiKeyID, model, windowStart)
 	c.mu.Lock()
 	c.usage[key] = cachedUsage{counts: counts, fetchedAt: time.Now()}
 	c.mu.Unlock()
 }
 
-func (c *quotaCacheStore) incrementUsage(userID, model string, windowStart time.Time) {
-	key := usageKey(userID, model, windowStart)
+func (c *quotaCacheStore) incrementUsage(userID, apiKeyID, model string, windowStart time.Time) {
+	key := usageKey(userID, apiKeyID, model, windowStart)
 	c.mu.Lock()
 	if cached, ok := c.usage[key]; ok {
 		cached.counts.RequestCount++
@@ -0,0 +1,127 @@
+//go:build auth
+
+package auth_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/mudler/LocalAI/core/http/auth"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"gorm.io/gorm"
+)
+
+var _ = Describe("Quotas", func() {
+	var (
+		db   *gorm.DB
+		user *auth.User
+	)
+
+	BeforeEach(func() {
+		db = testDB()
+		user = createTestUser(db, generateTestID()+"@example.com", auth.RoleUser, auth.ProviderGitHub)
+	})
+
+	spend := func(keyID *string, cost float64) {
+		Expect(auth.RecordUsage(db, &auth.UsageRecord{
+			UserID:      user.ID,
+			Model:       "gpt-4",
+			Endpoint:    "/v1/chat/completions",
+			TotalTokens: 10,
+			APIKeyID:    keyID,
+			CostUSD:     cost,
+			CreatedAt:   time.Now(),
+		})).To(Succeed())
+	}
+
+	It("reports a budget as exhausted once spend reaches it", func() {
+		budget := 1.0
+		_, err := auth.UpsertQuotaRule(db, auth.QuotaRule{UserID: user.ID, MaxCost: &budget, WindowSeconds: 3600})
+		Expect(err).ToNot(HaveOccurred())
+		spend(nil, 0.4)
+		spend(nil, 0.6)
+
+		exceeded, kind, retryAfter, msg := auth.QuotaExceeded(db, user.ID, "", "gpt-4")
+		Expect(exceeded).To(BeTrue())
+		Expect(kind).To(Equal(auth.QuotaKindBudget))
+		Expect(retryAfter).To(Equal(int64(3600)))
+		Expect(msg).To(ContainSubstring("Budget exhausted"))
+
+		statuses, err := auth.GetQuotaStatuses(db, user.ID)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(statuses).To(HaveLen(1))
+		Expect(statuses[0].CurrentCost).To(BeNumerically("~", 1.0, 1e-9))
+		Expect(statuses[0].RemainingCost).ToNot(BeNil())
+		Expect(*statuses[0].RemainingCost).To(BeZero())
+	})
+
+	It("scopes a key budget to requests made with that key", func() {
+		_, key, err := auth.CreateAPIKey(db, user.ID, "ci", auth.RoleUser, "", nil)
+		Expect(err).ToNot(HaveOccurred())
+		budget := 0.5
+		_, err = auth.UpsertQuotaRule(db, auth.QuotaRule{UserID: user.ID, APIKeyID: key.ID, MaxCost: &budget, WindowSeconds: 3600})
+		Expect(err).ToNot(HaveOccurred())
+		spend(&key.ID, 0.5)
+
+		exceeded, _, _, _ := auth.QuotaExceeded(db, user.ID, key.ID, "gpt-4")
+		Expect(exceeded).To(BeTrue())
+		exceeded, _, _, _ = auth.QuotaExceeded(db, user.ID, "", "gpt-4")
+		Expect(exceeded).To(BeFalse(), "sessions and other keys are outside the key's budget")
+
+		statuses, err := auth.GetQuotaStatuses(db, user.ID)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(statuses).To(HaveLen(1))
+		Expect(statuses[0].APIKeyName).To(Equal("ci"))
+
+		Expect(auth.RevokeAPIKey(db, key.ID, user.ID)).To(Succeed())
+		rules, err := auth.ListQuotaRules(db, user.ID)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(rules).To(BeEmpty())
+	})
+
+	It("keeps a user-wide rule and a key rule for the same model apart", func() {
+		_, key, err := auth.CreateAPIKey(db, user.ID, "ci", auth.RoleUser, "", nil)
+		Expect(err).ToNot(HaveOccurred())
+		reqs := int64(10)
+		_, err = auth.CreateOrUpdateQuotaRule(db, user.ID, "gpt-4", &reqs, nil, 60)
+		Expect(err).ToNot(HaveOccurred())
+		budget := 2.0
+		_, err = auth.UpsertQuotaRule(db, auth.QuotaRule{UserID: user.ID, APIKeyID: key.ID, Model: "gpt-4", MaxCost: &budget, WindowSeconds: 60})
+		Expect(err).ToNot(HaveOccurred())
+
+		rules, err := auth.ListQuotaRules(db, user.ID)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(rules).To(HaveLen(2))
+	})
+
+	It("lets token counting through once the quota is exhausted", func() {
+		budget := 1.0
+		_, err := auth.UpsertQuotaRule(db, auth.QuotaRule{UserID: user.ID, MaxCost: &budget, WindowSeconds: 3600})
+		Expect(err).ToNot(HaveOccurred())
+		spend(nil, 1.0)
+
+		e := echo.New()
+		e.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+			return func(c echo.Context) error {
+				auth.SetUser(c, user)
+				return next(c)
+			}
+		})
+		e.Use(auth.RequireQuota(db))
+		ok := func(c echo.Context) error { return c.NoContent(http.StatusOK) }
+		e.POST("/v1/messages", ok)
+		e.POST("/v1/messages/count_tokens", ok)
+
+		serve := func(path string) int {
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{"model":"gpt-4"}`)))
+			return rec.Code
+		}
+		Expect(serve("/v1/messages")).To(Equal(http.StatusTooManyRequests))
+		Expect(serve("/v1/messages/count_tokens")).To(Equal(http.StatusOK))
+	})
+})
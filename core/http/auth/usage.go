@@ -58,6 +58,8 @@ type UsageRecord struct {
 	CachedTokens           int64   // backend-reported KV-cache hit tokens
 	PrefillTokens          int64   // backend-reported prefill tokens (subset of prompt)
 	DraftTokens            int64   // speculative-decoding draft tokens
+	AudioSeconds           float64 // transcribed or generated audio, for per-second pricing
+	Images                 int64   // generated images, for per-image pricing
 	PricingVersionID       string  `gorm:"size:64;index"` // FK to pricing_version; "" when no pricing was applied
 	CostUSD                float64 // computed at insert when pricing is available; 0 with empty PricingVersionID = unknown
 
@@ -74,25 +76,27 @@ func RecordUsage(db *gorm.DB, record *UsageRecord) error {
 
 // UsageBucket is an aggregated time bucket for the dashboard.
 type UsageBucket struct {
-	Bucket           string `json:"bucket"`
-	Model            string `json:"model,omitempty"`
-	UserID           string `json:"user_id,omitempty"`
-	UserName         string `json:"user_name,omitempty"`
-	Source           string `json:"source,omitempty"`
-	APIKeyID         string `json:"api_key_id,omitempty"`
-	APIKeyName       string `json:"api_key_name,omitempty"`
-	PromptTokens     int64  `json:"prompt_tokens"`
-	CompletionTokens int64  `json:"completion_tokens"`
-	TotalTokens      int64  `json:"total_tokens"`
-	RequestCount     int64  `json:"request_count"`
+	Bucket           string  `json:"bucket"`
+	Model            string  `json:"model,omitempty"`
+	UserID           string  `json:"user_id,omitempty"`
+	UserName         string  `json:"user_name,omitempty"`
+	Source           string  `json:"source,omitempty"`
+	APIKeyID         string  `json:"api_key_id,omitempty"`
+	APIKeyName       string  `json:"api_key_name,omitempty"`
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+	TotalTokens      int64   `json:"total_tokens"`
+	RequestCount     int64   `json:"request_count"`
+	Cost             float64 `json:"cost"`
 }
 
 // UsageTotals is a summary of all usage.
 type UsageTotals struct {
-	PromptTokens     int64 `json:"prompt_tokens"`
-	CompletionTokens int64 `json:"completion_tokens"`
-	TotalTokens      int64 `json:"total_tokens"`
-	RequestCount     int64 `json:"request_count"`
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+	TotalTokens      int64   `json:"total_tokens"`
+	RequestCount     int64   `json:"request_count"`
+	Cost             float64 `json:"cost"`
 }
 
 // periodToWindow returns the time window and SQL date format for a period.
@@ -151,6 +155,7 @@ func GetUserUsage(db *gorm.DB, userID, period string) ([]UsageBucket, error) {
 			"SUM(prompt_tokens) as prompt_tokens, "+
 			"SUM(completion_tokens) as completion_tokens, "+
 			"SUM(total_tokens) as total_tokens, "+
+			"COALESCE(SUM(cost_usd), 0) as cost, "+
 			"COUNT(*) as request_count").
 		Where("user_id = ?", userID).
 		Group("bucket, model").
@@ -201,6 +206,7 @@ func GetAllUsage(db *gorm.DB, period, userID string) ([]UsageBucket, error) {
 			"SUM(prompt_tokens) as prompt_tokens, " +
 			"SUM(completion_tokens) as completion_tokens, " +
 			"SUM(total_tokens) as total_tokens, " +
+			"COALESCE(SUM(cost_usd), 0) as cost, " +
 			"COUNT(*) as request_count").
 		Group("bucket, model, user_id, user_name").
 		Order("bucket ASC")
@@ -319,8 +325,8 @@ func computeSourceTotals(db *gorm.DB, userID, apiKeyID string, since time.Time,
 	}
 
 	byKeyQ := db.Model(&UsageRecord{}).
-		Select("COALESCE(api_key_id, '') as api_key_id, api_key_name, "+
-			"user_id, user_name, "+
+		Select("COALESCE(api_key_id, '') as api_key_id, api_key_name, " +
+			"user_id, user_name, " +
 			"SUM(total_tokens) as tokens, COUNT(*) as requests, MAX(created_at) as last_used").
 		Where("api_key_id IS NOT NULL AND api_key_id <> ''").
 		Group("api_key_id, api_key_name, user_id, user_name").
@@ -421,11 +427,11 @@ func GetAllUsageBySource(db *gorm.DB, period, userID, apiKeyID string) ([]UsageB
 	bucketExpr := fmt.Sprintf("%s as bucket", dateFmt)
 
 	query := db.Model(&UsageRecord{}).
-		Select(bucketExpr+", source, COALESCE(api_key_id, '') as api_key_id, api_key_name, "+
-			"user_id, user_name, "+
-			"SUM(prompt_tokens) as prompt_tokens, "+
-			"SUM(completion_tokens) as completion_tokens, "+
-			"SUM(total_tokens) as total_tokens, "+
+		Select(bucketExpr + ", source, COALESCE(api_key_id, '') as api_key_id, api_key_name, " +
+			"user_id, user_name, " +
+			"SUM(prompt_tokens) as prompt_tokens, " +
+			"SUM(completion_tokens) as completion_tokens, " +
+			"SUM(total_tokens) as total_tokens, " +
 			"COUNT(*) as request_count").
 		Group("bucket, source, api_key_id, api_key_name, user_id, user_name").
 		Order("bucket ASC")
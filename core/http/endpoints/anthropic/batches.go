@@ -0,0 +1,187 @@
+package anthropic
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"github.com/mudler/LocalAI/core/http/auth"
+	"github.com/mudler/LocalAI/core/http/middleware"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/core/services/batches"
+)
+
+// CreateMessageBatchEndpoint creates a Message Batch. Every request runs
+// asynchronously against /v1/messages on behalf of the caller.
+// https://docs.anthropic.com/en/api/creating-message-batches
+// @Summary Create a Message Batch.
+// @Tags batches
+// @Param request body schema.AnthropicMessageBatchCreateRequest true "query params"
+// @Success 200 {object} schema.AnthropicMessageBatch "Response"
+// @Router /v1/messages/batches [post]
+func CreateMessageBatchEndpoint(svc *batches.Service) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var req schema.AnthropicMessageBatchCreateRequest
+		if err := c.Bind(&req); err != nil {
+			return sendBatchError(c, fmt.Errorf("%w: %v", batches.ErrInvalidRequest, err))
+		}
+		b, err := svc.CreateMessageBatch(c.Request().Context(), batchUserID(c), req)
+		if err != nil {
+			return sendBatchError(c, err)
+		}
+		return c.JSON(http.StatusOK, messageBatchToSchema(c, *b))
+	}
+}
+
+// ListMessageBatchesEndpoint lists the Message Batches of the current user,
+// newest first.
+// @Summary List Message Batches.
+// @Tags batches
+// @Param after_id query string false "Cursor: ID of the last batch of the previous page"
+// @Param limit query int false "Page size (1-1000, default 20)"
+// @Success 200 {object} schema.AnthropicMessageBatchList "Response"
+// @Router /v1/messages/batches [get]
+func ListMessageBatchesEndpoint(svc *batches.Service) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		limit, err := pageLimit(c)
+		if err != nil {
+			return sendBatchError(c, err)
+		}
+		if c.QueryParam("before_id") != "" {
+			return sendBatchError(c, fmt.Errorf("%w: before_id is not supported, page forward with after_id", batches.ErrInvalidRequest))
+		}
+		list, hasMore, err := svc.ListMessageBatches(batchUserID(c), c.QueryParam("after_id"), limit)
+		if err != nil {
+			return sendBatchError(c, err)
+		}
+		resp := schema.AnthropicMessageBatchList{Data: make([]schema.AnthropicMessageBatch, 0, len(list)), HasMore: hasMore}
+		for _, b := range list {
+			resp.Data = append(resp.Data, messageBatchToSchema(c, b))
+		}
+		if len(resp.Data) > 0 {
+			resp.FirstID = &resp.Data[0].ID
+			resp.LastID = &resp.Data[len(resp.Data)-1].ID
+		}
+		return c.JSON(http.StatusOK, resp)
+	}
+}
+
+// GetMessageBatchEndpoint returns a Message Batch.
+// @Summary Retrieve a Message Batch.
+// @Tags batches
+// @Param message_batch_id path string true "Message Batch ID"
+// @Success 200 {object} schema.AnthropicMessageBatch "Response"
+// @Router /v1/messages/batches/{message_batch_id} [get]
+func GetMessageBatchEndpoint(svc *batches.Service) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		b, err := svc.GetMessageBatch(batchUserID(c), c.Param("message_batch_id"))
+		if err != nil {
+			return sendBatchError(c, err)
+		}
+		return c.JSON(http.StatusOK, messageBatchToSchema(c, *b))
+	}
+}
+
+// CancelMessageBatchEndpoint cancels a Message Batch that is still being
+// processed. Requests that already ran keep their results.
+// @Summary Cancel a Message Batch.
+// @Tags batches
+// @Param message_batch_id path string true "Message Batch ID"
+// @Success 200 {object} schema.AnthropicMessageBatch "Response"
+// @Router /v1/messages/batches/{message_batch_id}/cancel [post]
+func CancelMessageBatchEndpoint(svc *batches.Service) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		b, err := svc.CancelMessageBatch(batchUserID(c), c.Param("message_batch_id"))
+		if err != nil {
+			return sendBatchError(c, err)
+		}
+		return c.JSON(http.StatusOK, messageBatchToSchema(c, *b))
+	}
+}
+
+// DeleteMessageBatchEndpoint deletes a Message Batch that has ended, along
+// with its results.
+// @Summary Delete a Message Batch.
+// @Tags batches
+// @Param message_batch_id path string true "Message Batch ID"
+// @Success 200 {object} schema.AnthropicDeletedMessageBatch "Response"
+// @Router /v1/messages/batches/{message_batch_id} [delete]
+func DeleteMessageBatchEndpoint(svc *batches.Service) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		id := c.Param("message_batch_id")
+		if err := svc.DeleteMessageBatch(c.Request().Context(), batchUserID(c), id); err != nil {
+			return sendBatchError(c, err)
+		}
+		return c.JSON(http.StatusOK, schema.AnthropicDeletedMessageBatch{ID: id, Type: "message_batch_deleted"})
+	}
+}
+
+// MessageBatchResultsEndpoint streams the results of an ended Message Batch
+// as JSONL, one line per request in submission order.
+// @Summary Retrieve the results of a Message Batch.
+// @Tags batches
+// @Produce application/x-jsonl
+// @Param message_batch_id path string true "Message Batch ID"
+// @Success 200 {object} schema.AnthropicMessageBatchResultLine "One line per request"
+// @Router /v1/messages/batches/{message_batch_id}/results [get]
+func MessageBatchResultsEndpoint(svc *batches.Service) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		id := c.Param("message_batch_id")
+		// Check the batch first: once the body starts streaming an error
+		// can no longer change the status code.
+		b, err := svc.GetMessageBatch(batchUserID(c), id)
+		if err != nil {
+			return sendBatchError(c, err)
+		}
+		if messageBatchToSchema(c, *b).ProcessingStatus != schema.AnthropicBatchEnded {
+			return sendBatchError(c, fmt.Errorf("%w: batch %q is still processing, results are available once it has ended", batches.ErrInvalidRequest, id))
+		}
+		c.Response().Header().Set(echo.HeaderContentType, "application/x-jsonl")
+		c.Response().WriteHeader(http.StatusOK)
+		return svc.WriteMessageBatchResults(c.Request().Context(), batchUserID(c), id, c.Response())
+	}
+}
+
+// messageBatchToSchema converts a batch and points results_url at the host
+// the batch was requested through.
+func messageBatchToSchema(c echo.Context, b batches.BatchRecord) schema.AnthropicMessageBatch {
+	out := batches.MessageBatchToSchema(b)
+	if out.ProcessingStatus == schema.AnthropicBatchEnded {
+		url := middleware.BaseURL(c) + "v1/messages/batches/" + b.ID + "/results"
+		out.ResultsURL = &url
+	}
+	return out
+}
+
+func pageLimit(c echo.Context) (int, error) {
+	v := c.QueryParam("limit")
+	if v == "" {
+		return 20, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 || n > 1000 {
+		return 0, fmt.Errorf("%w: limit must be between 1 and 1000", batches.ErrInvalidRequest)
+	}
+	return n, nil
+}
+
+func batchUserID(c echo.Context) string {
+	if user := auth.GetUser(c); user != nil {
+		return user.ID
+	}
+	return ""
+}
+
+// sendBatchError maps a batches service error onto an Anthropic error
+// response.
+func sendBatchError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, batches.ErrNotFound):
+		return sendAnthropicError(c, http.StatusNotFound, "not_found_error", err.Error())
+	case errors.Is(err, batches.ErrInvalidRequest):
+		return sendAnthropicError(c, http.StatusBadRequest, "invalid_request_error", err.Error())
+	}
+	return sendAnthropicError(c, http.StatusInternalServerError, "api_error", err.Error())
+}
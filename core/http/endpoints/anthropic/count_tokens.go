@@ -0,0 +1,61 @@
+package anthropic
+
+import (
+	"fmt"
+
+	"github.com/labstack/echo/v4"
+	"github.com/mudler/LocalAI/core/backend"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/http/middleware"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/core/templates"
+	"github.com/mudler/LocalAI/pkg/model"
+)
+
+// CountTokensEndpoint is the Anthropic token counting endpoint
+// https://docs.anthropic.com/en/api/messages-count-tokens
+// The request is rendered through the same template path as
+// MessagesEndpoint and tokenized by the model's backend. For models relying
+// on the tokenizer's own chat template the rendered prompt lacks the chat
+// markup, so the count is a close lower bound rather than exact.
+// @Summary Count the input tokens of a Messages API request.
+// @Tags inference
+// @Param request body schema.AnthropicRequest true "query params"
+// @Success 200 {object} schema.AnthropicCountTokensResponse "Response"
+// @Router /v1/messages/count_tokens [post]
+func CountTokensEndpoint(ml *model.ModelLoader, evaluator *templates.Evaluator, appConfig *config.ApplicationConfig) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		input, ok := c.Get(middleware.CONTEXT_LOCALS_KEY_LOCALAI_REQUEST).(*schema.AnthropicRequest)
+		if !ok || input.Model == "" {
+			return sendAnthropicError(c, 400, "invalid_request_error", "model is required")
+		}
+
+		cfg, ok := c.Get(middleware.CONTEXT_LOCALS_KEY_MODEL_CONFIG).(*config.ModelConfig)
+		if !ok || cfg == nil {
+			return sendAnthropicError(c, 400, "invalid_request_error", "model configuration not found")
+		}
+
+		// The cloud-proxy backend forwards to a fixed upstream URL and has
+		// no tokenizer of its own.
+		if cfg.IsCloudProxyBackendPassthrough() {
+			return sendAnthropicError(c, 400, "invalid_request_error", "token counting is not supported for cloud-proxied models")
+		}
+
+		predInput := renderPrompt(evaluator, input, cfg)
+		resp, err := backend.ModelTokenize(predInput, ml, *cfg, appConfig)
+		if err != nil {
+			return sendAnthropicError(c, 500, "api_error", fmt.Sprintf("tokenization failed: %v", err))
+		}
+
+		return c.JSON(200, schema.AnthropicCountTokensResponse{InputTokens: len(resp.Tokens)})
+	}
+}
+
+// renderPrompt templates an Anthropic request, tools included, the way
+// MessagesEndpoint does before inference. MCP context is not injected: it
+// is opted into through metadata, which token counting does not take.
+func renderPrompt(evaluator *templates.Evaluator, input *schema.AnthropicRequest, cfg *config.ModelConfig) string {
+	funcs, shouldUseFn := convertAnthropicTools(input, cfg)
+	openAIReq := newOpenAIRequest(input, convertAnthropicToOpenAIMessages(input))
+	return evaluator.TemplateMessages(*openAIReq, openAIReq.Messages, cfg, funcs, shouldUseFn)
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/labstack/echo/v4"
 	"github.com/mudler/LocalAI/core/backend"
 	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/http/auth"
 	mcpTools "github.com/mudler/LocalAI/core/http/endpoints/mcp"
 	openaiEndpoint "github.com/mudler/LocalAI/core/http/endpoints/openai"
 	"github.com/mudler/LocalAI/core/http/middleware"
@@ -70,7 +71,7 @@ func MessagesEndpoint(cl *config.ModelConfigLoader, ml *model.ModelLoader, evalu
 		if (len(mcpServers) > 0 || mcpPromptName != "" || len(mcpResourceURIs) > 0) && (cfg.MCP.Servers != "" || cfg.MCP.Stdio != "") {
 			remote, stdio, mcpErr := cfg.MCP.MCPConfigFromYAML()
 			if mcpErr == nil {
-				mcpExecutor = mcpTools.NewToolExecutor(c.Request().Context(), natsClient, cfg.Name, remote, stdio, mcpServers)
+				mcpExecutor = mcpTools.ExecutorFor(auth.GetUserID(c), mcpTools.NewToolExecutor(c.Request().Context(), natsClient, cfg.Name, remote, stdio, mcpServers))
 
 				// Prompt and resource injection (pre-processing step — resolves locally regardless of distributed mode)
 				namedSessions, sessErr := mcpTools.NamedSessionsFromMCPConfig(cfg.Name, remote, stdio, mcpServers)
@@ -101,24 +102,7 @@ func MessagesEndpoint(cl *config.ModelConfigLoader, ml *model.ModelLoader, evalu
 		}
 
 		// Create an OpenAI-compatible request for internal processing
-		openAIReq := &schema.OpenAIRequest{
-			PredictionOptions: schema.PredictionOptions{
-				BasicModelRequest: schema.BasicModelRequest{Model: input.Model},
-				Temperature:       input.Temperature,
-				TopK:              input.TopK,
-				TopP:              input.TopP,
-				Maxtokens:         &input.MaxTokens,
-			},
-			Messages: openAIMessages,
-			Stream:   input.Stream,
-			Context:  input.Context,
-			Cancel:   input.Cancel,
-		}
-
-		// Set stop sequences
-		if len(input.StopSequences) > 0 {
-			openAIReq.Stop = input.StopSequences
-		}
+		openAIReq := newOpenAIRequest(input, openAIMessages)
 
 		// Merge config settings
 		if input.Temperature != nil {
@@ -147,6 +131,31 @@ func MessagesEndpoint(cl *config.ModelConfigLoader, ml *model.ModelLoader, evalu
 	}
 }
 
+// newOpenAIRequest builds the OpenAI-compatible request an Anthropic
+// request is templated and predicted with. Token counting goes through it as
+// well, so both see the same prompt.
+func newOpenAIRequest(input *schema.AnthropicRequest, messages []schema.Message) *schema.OpenAIRequest {
+	req := &schema.OpenAIRequest{
+		PredictionOptions: schema.PredictionOptions{
+			BasicModelRequest: schema.BasicModelRequest{Model: input.Model},
+			Temperature:       input.Temperature,
+			TopK:              input.TopK,
+			TopP:              input.TopP,
+			Maxtokens:         &input.MaxTokens,
+		},
+		Messages: messages,
+		Stream:   input.Stream,
+		Context:  input.Context,
+		Cancel:   input.Cancel,
+	}
+
+	// Set stop sequences
+	if len(input.StopSequences) > 0 {
+		req.Stop = input.StopSequences
+	}
+	return req
+}
+
 func handleAnthropicNonStream(c echo.Context, id string, input *schema.AnthropicRequest, cfg *config.ModelConfig, ml *model.ModelLoader, cl *config.ModelConfigLoader, appConfig *config.ApplicationConfig, predInput string, openAIReq *schema.OpenAIRequest, funcs functions.Functions, shouldUseFn bool, mcpExecutor mcpTools.ToolExecutor, evaluator *templates.Evaluator) error {
 	mcpMaxIterations := 10
 	if cfg.Agent.MaxIterations > 0 {
@@ -1041,7 +1050,8 @@ func convertAnthropicTools(input *schema.AnthropicRequest, cfg *config.ModelConf
 // and hands the body off to the cloud-proxy gRPC backend. Model swap +
 // upstream auth headers are applied inside the backend. Request-side PII
 // redaction already ran in the middleware; the response is forwarded
-// unmodified.
+// as-is and only scanned by the middleware when the model has
+// pii.scan_responses on.
 func forwardCloudProxyAnthropicViaBackend(c echo.Context, cfg *config.ModelConfig, input *schema.AnthropicRequest, ml *model.ModelLoader, appConfig *config.ApplicationConfig) error {
 	body, err := json.Marshal(input)
 	if err != nil {
@@ -0,0 +1,118 @@
+package anthropic
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/mudler/LocalAI/core/config"
+	openaiEndpoint "github.com/mudler/LocalAI/core/http/endpoints/openai"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/pkg/model"
+	"gorm.io/gorm"
+)
+
+// ListModelsEndpoint is the Anthropic Models API listing
+// https://docs.anthropic.com/en/api/models-list
+// It lists the same models as the OpenAI /v1/models endpoint.
+// @Summary List models in the Anthropic format.
+// @Tags models
+// @Param after_id query string false "Cursor: return the models after this ID"
+// @Param before_id query string false "Cursor: return the models before this ID"
+// @Param limit query int false "Page size (1-1000, default 20)"
+// @Success 200 {object} schema.AnthropicModelList "Response"
+// @Router /v1/models [get]
+func ListModelsEndpoint(cl *config.ModelConfigLoader, ml *model.ModelLoader, authDB *gorm.DB) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		names, err := openaiEndpoint.ListVisibleModelNames(c, cl, ml, authDB)
+		if err != nil {
+			return sendAnthropicError(c, http.StatusInternalServerError, "api_error", err.Error())
+		}
+		// The cursors are positions in the list, so it needs a stable order.
+		slices.Sort(names)
+
+		limit := 20
+		if v := c.QueryParam("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 || n > 1000 {
+				return sendAnthropicError(c, http.StatusBadRequest, "invalid_request_error", "limit must be between 1 and 1000")
+			}
+			limit = n
+		}
+
+		start, end := 0, len(names)
+		if after := c.QueryParam("after_id"); after != "" {
+			start = slices.Index(names, after) + 1
+		}
+		if before := c.QueryParam("before_id"); before != "" {
+			if i := slices.Index(names, before); i >= 0 {
+				end = i
+			}
+			// Paging backwards keeps the models closest to the cursor.
+			start = max(start, end-limit)
+		}
+		if start > end {
+			start = end
+		}
+		page := names[start:min(end, start+limit)]
+
+		resp := schema.AnthropicModelList{Data: make([]schema.AnthropicModel, 0, len(page))}
+		for _, name := range page {
+			resp.Data = append(resp.Data, anthropicModel(cl, ml, name))
+		}
+		if len(resp.Data) > 0 {
+			resp.FirstID = &resp.Data[0].ID
+			resp.LastID = &resp.Data[len(resp.Data)-1].ID
+			resp.HasMore = start+len(page) < end
+			if c.QueryParam("before_id") != "" {
+				resp.HasMore = start > 0
+			}
+		}
+		return c.JSON(http.StatusOK, resp)
+	}
+}
+
+// GetModelEndpoint is the Anthropic Models API lookup
+// https://docs.anthropic.com/en/api/models
+// @Summary Get a model in the Anthropic format.
+// @Tags models
+// @Param model_id path string true "Model ID"
+// @Success 200 {object} schema.AnthropicModel "Response"
+// @Router /v1/models/{model_id} [get]
+func GetModelEndpoint(cl *config.ModelConfigLoader, ml *model.ModelLoader, authDB *gorm.DB) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		names, err := openaiEndpoint.ListVisibleModelNames(c, cl, ml, authDB)
+		if err != nil {
+			return sendAnthropicError(c, http.StatusInternalServerError, "api_error", err.Error())
+		}
+		id := c.Param("model_id")
+		if !slices.Contains(names, id) {
+			return sendAnthropicError(c, http.StatusNotFound, "not_found_error", "model: "+id)
+		}
+		return c.JSON(http.StatusOK, anthropicModel(cl, ml, id))
+	}
+}
+
+// anthropicModel describes a model. Creation time is taken from the model's
+// configuration file, or from the model file itself for models served
+// without one.
+func anthropicModel(cl *config.ModelConfigLoader, ml *model.ModelLoader, name string) schema.AnthropicModel {
+	path := filepath.Join(ml.ModelPath, name)
+	if cfg, ok := cl.GetModelConfig(name); ok && cfg.GetModelConfigFile() != "" {
+		path = cfg.GetModelConfigFile()
+	}
+	created := time.Unix(0, 0)
+	if fi, err := os.Stat(path); err == nil {
+		created = fi.ModTime()
+	}
+	return schema.AnthropicModel{
+		Type:        "model",
+		ID:          name,
+		DisplayName: name,
+		CreatedAt:   created.UTC().Format(time.RFC3339),
+	}
+}
@@ -0,0 +1,107 @@
+package anthropic
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+
+	"github.com/labstack/echo/v4"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/core/templates"
+	"github.com/mudler/LocalAI/pkg/model"
+	"github.com/mudler/LocalAI/pkg/system"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Anthropic models listing", func() {
+	var (
+		e   *echo.Echo
+		cl  *config.ModelConfigLoader
+		ml  *model.ModelLoader
+		dir string
+	)
+
+	BeforeEach(func() {
+		e = echo.New()
+		dir = GinkgoT().TempDir()
+		st, err := system.GetSystemState(system.WithModelPath(dir))
+		Expect(err).ToNot(HaveOccurred())
+		ml = model.NewModelLoader(st)
+		cl = config.NewModelConfigLoader(dir)
+		for _, name := range []string{"alpha", "beta", "gamma"} {
+			path := filepath.Join(dir, name+".yaml")
+			Expect(os.WriteFile(path, []byte("name: "+name+"\nbackend: llama-cpp\n"), 0o644)).To(Succeed())
+			Expect(cl.ReadModelConfig(path)).To(Succeed())
+		}
+	})
+
+	list := func(query string) schema.AnthropicModelList {
+		req := httptest.NewRequest(http.MethodGet, "/v1/models?"+query, nil)
+		rec := httptest.NewRecorder()
+		Expect(ListModelsEndpoint(cl, ml, nil)(e.NewContext(req, rec))).To(Succeed())
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		var resp schema.AnthropicModelList
+		Expect(json.Unmarshal(rec.Body.Bytes(), &resp)).To(Succeed())
+		return resp
+	}
+
+	ids := func(resp schema.AnthropicModelList) []string {
+		var out []string
+		for _, m := range resp.Data {
+			out = append(out, m.ID)
+		}
+		return out
+	}
+
+	It("pages through the models with after_id and before_id", func() {
+		resp := list("limit=2")
+		Expect(ids(resp)).To(Equal([]string{"alpha", "beta"}))
+		Expect(resp.HasMore).To(BeTrue())
+		Expect(*resp.LastID).To(Equal("beta"))
+		Expect(resp.Data[0].Type).To(Equal("model"))
+		Expect(resp.Data[0].CreatedAt).ToNot(HavePrefix("1970"))
+
+		resp = list("limit=2&after_id=beta")
+		Expect(ids(resp)).To(Equal([]string{"gamma"}))
+		Expect(resp.HasMore).To(BeFalse())
+
+		resp = list("limit=1&before_id=gamma")
+		Expect(ids(resp)).To(Equal([]string{"beta"}))
+		Expect(resp.HasMore).To(BeTrue())
+	})
+
+	It("looks up a single model", func() {
+		req := httptest.NewRequest(http.MethodGet, "/v1/models/missing", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("model_id")
+		c.SetParamValues("missing")
+		Expect(GetModelEndpoint(cl, ml, nil)(c)).To(Succeed())
+		Expect(rec.Code).To(Equal(http.StatusNotFound))
+		Expect(rec.Body.String()).To(ContainSubstring("not_found_error"))
+	})
+})
+
+var _ = Describe("Anthropic token counting", func() {
+	It("renders the prompt with the model's chat template", func() {
+		cfg := &config.ModelConfig{}
+		cfg.TemplateConfig.ChatMessage = "<{{.RoleName}}>{{.Content}}"
+		cfg.TemplateConfig.Chat = "{{.Input}}<assistant>"
+		input := &schema.AnthropicRequest{
+			Model:  "m",
+			System: "Be brief.",
+			Messages: []schema.AnthropicMessage{
+				{Role: "user", Content: "hello"},
+			},
+		}
+
+		prompt := renderPrompt(templates.NewEvaluator(GinkgoT().TempDir()), input, cfg)
+		Expect(prompt).To(ContainSubstring("<system>Be brief."))
+		Expect(prompt).To(ContainSubstring("<user>hello"))
+		Expect(prompt).To(HaveSuffix("<assistant>"))
+	})
+})
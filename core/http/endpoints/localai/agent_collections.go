@@ -1,12 +1,16 @@
 package localai
 
 import (
+	"errors"
 	"net/http"
 	"net/url"
 	"strings"
 
 	"github.com/labstack/echo/v4"
 	"github.com/mudler/LocalAI/core/application"
+	"github.com/mudler/LocalAI/core/services/agentpool"
+	"github.com/mudler/LocalAI/core/services/agents"
+	"github.com/mudler/xlog"
 )
 
 func ListCollectionsEndpoint(app *application.Application) echo.HandlerFunc {
@@ -92,6 +96,10 @@ func UploadToCollectionEndpoint(app *application.Application) echo.HandlerFunc {
 			}
 			return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 		}
+		// Run the agents with a knowledge trigger on this collection
+		if _, err := svc.TriggerAgentsForUser(c.Request().Context(), agents.KnowledgeTriggerEvent(userID, name, file.Filename)); err != nil && !errors.Is(err, agentpool.ErrSchedulingUnavailable) {
+			xlog.Warn("Failed to fire agent knowledge triggers", "collection", name, "error", err)
+		}
 		return c.JSON(http.StatusOK, map[string]string{"status": "ok", "filename": file.Filename, "key": key})
 	}
 }
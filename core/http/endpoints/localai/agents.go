@@ -1,7 +1,9 @@
 package localai
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"maps"
@@ -129,11 +131,17 @@ func CreateAgentEndpoint(app *application.Application) echo.HandlerFunc {
 	return func(c echo.Context) error {
 		svc := app.AgentPoolService()
 		userID := getUserID(c)
-		var cfg state.AgentConfig
-		if err := c.Bind(&cfg); err != nil {
+		cfg, sched, err := bindAgentConfig(c, agents.AgentScheduling{})
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		if err := svc.ValidateAgentScheduling(sched); err != nil {
 			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
 		}
-		if err := svc.CreateAgentForUser(userID, &cfg); err != nil {
+		if err := svc.CreateAgentForUser(userID, cfg); err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		if err := svc.SetAgentSchedulingForUser(userID, cfg.Name, sched); err != nil {
 			return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 		}
 		return c.JSON(http.StatusCreated, map[string]string{"status": "ok"})
@@ -160,16 +168,23 @@ func UpdateAgentEndpoint(app *application.Application) echo.HandlerFunc {
 		svc := app.AgentPoolService()
 		userID := effectiveUserID(c)
 		name := decodedParam(c, "name")
-		var cfg state.AgentConfig
-		if err := c.Bind(&cfg); err != nil {
+		// Scheduling settings left out of the body are kept.
+		cfg, sched, err := bindAgentConfig(c, svc.GetAgentSchedulingForUser(userID, name))
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		if err := svc.ValidateAgentScheduling(sched); err != nil {
 			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
 		}
-		if err := svc.UpdateAgentForUser(userID, name, &cfg); err != nil {
+		if err := svc.UpdateAgentForUser(userID, name, cfg); err != nil {
 			if strings.Contains(err.Error(), "not found") {
 				return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
 			}
 			return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 		}
+		if err := svc.SetAgentSchedulingForUser(userID, name, sched); err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
 		return c.JSON(http.StatusOK, map[string]string{"status": "ok"})
 	}
 }
@@ -195,7 +210,79 @@ func GetAgentConfigEndpoint(app *application.Application) echo.HandlerFunc {
 		if cfg == nil {
 			return c.JSON(http.StatusNotFound, map[string]string{"error": "Agent not found"})
 		}
-		return c.JSON(http.StatusOK, cfg)
+		dat, err := json.Marshal(cfg)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		if dat, err = agents.MergeSchedulingJSON(dat, svc.GetAgentSchedulingForUser(userID, name)); err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		return c.JSONBlob(http.StatusOK, dat)
+	}
+}
+
+// bindAgentConfig binds an agent config request. The cron schedule and
+// triggers are LocalAI settings that LocalAGI's config drops, so they are
+// decoded from the same body separately, over sched: the settings the body
+// leaves out keep their value there.
+func bindAgentConfig(c echo.Context, sched agents.AgentScheduling) (*state.AgentConfig, agents.AgentScheduling, error) {
+	body, err := io.ReadAll(c.Request().Body)
+	if err != nil {
+		return nil, sched, err
+	}
+	c.Request().Body = io.NopCloser(bytes.NewReader(body))
+	var cfg state.AgentConfig
+	if err := c.Bind(&cfg); err != nil {
+		return nil, sched, err
+	}
+	if strings.HasPrefix(c.Request().Header.Get(echo.HeaderContentType), echo.MIMEApplicationJSON) {
+		if sched, err = agents.ApplySchedulingJSON(sched, body); err != nil {
+			return nil, sched, err
+		}
+	}
+	return &cfg, sched, nil
+}
+
+// maxWebhookPayload bounds the body of an agent webhook call.
+const maxWebhookPayload = 1 << 20
+
+// TriggerAgentEndpoint fires the webhook trigger of an agent: the request
+// body is passed on to the agent. Deliveries retried with the same
+// Idempotency-Key header run the agent once.
+func TriggerAgentEndpoint(app *application.Application) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		svc := app.AgentPoolService()
+		userID := effectiveUserID(c)
+		name := decodedParam(c, "name")
+		active, exists := svc.ListAgentsForUser(userID)[name]
+		if !exists {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "Agent not found"})
+		}
+		if !active {
+			return c.JSON(http.StatusConflict, map[string]string{"error": "Agent is paused"})
+		}
+
+		body, err := io.ReadAll(io.LimitReader(c.Request().Body, maxWebhookPayload+1))
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "failed to read request body"})
+		}
+		if len(body) > maxWebhookPayload {
+			return c.JSON(http.StatusRequestEntityTooLarge, map[string]string{"error": "payload too large"})
+		}
+
+		evt := agents.WebhookTriggerEvent(userID, name, c.Request().Header.Get("Idempotency-Key"), body)
+		queued, err := svc.TriggerAgentsForUser(c.Request().Context(), evt)
+		if err != nil {
+			if errors.Is(err, agents.ErrNoMatchingTrigger) || errors.Is(err, agentpool.ErrSchedulingUnavailable) {
+				return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+			}
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		status := "triggered"
+		if queued == 0 {
+			status = "duplicate"
+		}
+		return c.JSON(http.StatusAccepted, map[string]string{"status": status})
 	}
 }
 
@@ -385,14 +472,22 @@ func ImportAgentEndpoint(app *application.Application) echo.HandlerFunc {
 		}
 
 		// Try JSON body
+		body, err := io.ReadAll(c.Request().Body)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "failed to read request body"})
+		}
 		var cfg state.AgentConfig
-		if err := json.NewDecoder(c.Request().Body).Decode(&cfg); err != nil {
+		var sched agents.AgentScheduling
+		if json.Unmarshal(body, &cfg) != nil || json.Unmarshal(body, &sched) != nil {
 			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request: provide a file or JSON body"})
 		}
 		data, err := json.Marshal(&cfg)
 		if err != nil {
 			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
 		}
+		if data, err = agents.MergeSchedulingJSON(data, sched); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
 		if err := svc.ImportAgentForUser(userID, data); err != nil {
 			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
 		}
@@ -0,0 +1,177 @@
+package localai
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/http/auth"
+	mcpTools "github.com/mudler/LocalAI/core/http/endpoints/mcp"
+	"github.com/mudler/LocalAI/core/services/messaging"
+	"github.com/mudler/xlog"
+)
+
+// MCPElicitationAnswerEndpoint delivers the caller's answer to an MCP
+// elicitation request announced by an "mcp_elicitation" stream event.
+// In distributed mode an answer for a request streamed by another replica
+// is forwarded to it.
+// @Summary Answer an MCP elicitation request
+// @Tags mcp
+// @Param id path string true "Elicitation ID"
+// @Param request body mcpTools.ElicitationAnswer true "accept, decline or cancel, with the content on accept"
+// @Success 204
+// @Router /v1/mcp/elicitations/{id} [post]
+func MCPElicitationAnswerEndpoint(nats messaging.Publisher) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var answer mcpTools.ElicitationAnswer
+		if err := c.Bind(&answer); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
+		}
+		userID := ""
+		if u := auth.GetUser(c); u != nil {
+			userID = u.ID
+		}
+		id := c.Param("id")
+		err := mcpTools.AnswerElicitation(id, userID, answer)
+		switch {
+		case err == nil:
+			return c.NoContent(http.StatusNoContent)
+		case errors.Is(err, mcpTools.ErrElicitationNotFound) && nats != nil:
+			if err := mcpTools.PublishElicitationAnswer(nats, id, userID, answer); err != nil {
+				return echo.NewHTTPError(http.StatusBadGateway, err.Error())
+			}
+			return c.NoContent(http.StatusAccepted)
+		case errors.Is(err, mcpTools.ErrElicitationNotFound):
+			return echo.NewHTTPError(http.StatusNotFound, err.Error())
+		default:
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+	}
+}
+
+// MCPOAuthAuthorization is an OAuth-protected MCP server waiting for an
+// administrator to authorize LocalAI.
+type MCPOAuthAuthorization struct {
+	Model            string    `json:"model"`
+	Server           string    `json:"server"`
+	AuthorizationURL string    `json:"authorization_url"`
+	ExpiresAt        time.Time `json:"expires_at"`
+}
+
+// MCPOAuthAuthorizationsEndpoint lists the pending authorizations of
+// OAuth-protected MCP servers with the URL an administrator opens to grant
+// LocalAI access. The token obtained is shared by every user of the model.
+// GET /api/mcp/oauth/authorizations
+func MCPOAuthAuthorizationsEndpoint() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		flows, err := mcpTools.PendingOAuthAuthorizations(c.Request().Context())
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+		out := make([]MCPOAuthAuthorization, 0, len(flows))
+		for _, f := range flows {
+			out = append(out, MCPOAuthAuthorization{Model: f.Model, Server: f.Server, AuthorizationURL: f.AuthURL, ExpiresAt: f.ExpiresAt})
+		}
+		return c.JSON(http.StatusOK, map[string]any{"authorizations": out})
+	}
+}
+
+// MCPOAuthCallbackEndpoint is the redirect URL of the authorization_code
+// flows LocalAI starts for OAuth-protected MCP servers, reached by the
+// administrator's browser after they granted access. The state must name a
+// pending flow of the OAuth store, which every replica shares; callbacks
+// with an unknown or expired state are dropped.
+// GET /api/mcp/oauth/callback
+func MCPOAuthCallbackEndpoint() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if e := c.QueryParam("error"); e != "" {
+			return c.String(http.StatusBadRequest, "MCP server authorization failed: "+e+" "+c.QueryParam("error_description"))
+		}
+		state, code := c.QueryParam("state"), c.QueryParam("code")
+		if state == "" || code == "" {
+			return c.String(http.StatusBadRequest, "missing state or code")
+		}
+		err := mcpTools.CompleteOAuthAuthorization(c.Request().Context(), state, code)
+		switch {
+		case err == nil:
+			return c.String(http.StatusOK, "LocalAI is now authorized to use the MCP server. You can close this window.")
+		case errors.Is(err, mcpTools.ErrOAuthFlowNotFound):
+			return c.String(http.StatusNotFound, "This authorization link expired or was already used. Retry the MCP server to get a new one.")
+		default:
+			xlog.Error("MCP server authorization failed", "error", err)
+			return c.String(http.StatusBadGateway, "MCP server authorization failed: "+err.Error())
+		}
+	}
+}
+
+// MCPOAuthTokenEndpoint hands an agent worker the current access token of
+// an OAuth-protected MCP server of a model, refreshed when it expired. The
+// refresh token and client secret stay on the frontend. Answers 204 when the
+// server is not authorized.
+// GET /api/mcp/oauth/token?model=&server=
+func MCPOAuthTokenEndpoint(cl *config.ModelConfigLoader) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		model, server := c.QueryParam("model"), c.QueryParam("server")
+		remote, err := oauthServer(cl, model, server)
+		if err != nil {
+			return err
+		}
+		tok, err := mcpTools.OAuthToken(c.Request().Context(), model, server, remote)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadGateway, err.Error())
+		}
+		if tok == nil {
+			return c.NoContent(http.StatusNoContent)
+		}
+		return c.JSON(http.StatusOK, tok)
+	}
+}
+
+// MCPOAuthAuthorizeEndpoint authorizes LocalAI with an OAuth-protected MCP
+// server of a model after it refused an agent worker's request. Answers 204
+// once a token is stored, and 409 with the authorization URL when the
+// server has to be authorized in the browser.
+// POST /api/mcp/oauth/authorize
+func MCPOAuthAuthorizeEndpoint(cl *config.ModelConfigLoader) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var req mcpTools.OAuthAuthorizeRequest
+		if err := c.Bind(&req); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
+		}
+		remote, err := oauthServer(cl, req.Model, req.Server)
+		if err != nil {
+			return err
+		}
+		err = mcpTools.AuthorizeOAuth(c.Request().Context(), req.Model, req.Server, remote, req.Challenge)
+		var authErr *mcpTools.AuthorizationRequiredError
+		switch {
+		case err == nil:
+			return c.NoContent(http.StatusNoContent)
+		case errors.As(err, &authErr):
+			return c.JSON(http.StatusConflict, mcpTools.OAuthAuthorizeError{Error: err.Error(), AuthorizationURL: authErr.URL})
+		default:
+			return echo.NewHTTPError(http.StatusBadGateway, err.Error())
+		}
+	}
+}
+
+// oauthServer returns the OAuth-protected remote MCP server serverName of
+// model from the model's configuration.
+func oauthServer(cl *config.ModelConfigLoader, model, serverName string) (config.MCPRemoteServer, error) {
+	cfg, ok := cl.GetModelConfig(model)
+	if !ok {
+		return config.MCPRemoteServer{}, echo.NewHTTPError(http.StatusNotFound, fmt.Sprintf("model %q not found", model))
+	}
+	remote, _, err := cfg.MCP.MCPConfigFromYAML()
+	if err != nil {
+		return config.MCPRemoteServer{}, echo.NewHTTPError(http.StatusUnprocessableEntity, fmt.Sprintf("failed to parse MCP config: %v", err))
+	}
+	server, ok := remote.Servers[serverName]
+	if !ok || server.OAuth == nil {
+		return config.MCPRemoteServer{}, echo.NewHTTPError(http.StatusNotFound, fmt.Sprintf("model %q has no OAuth-protected MCP server %q", model, serverName))
+	}
+	return server, nil
+}
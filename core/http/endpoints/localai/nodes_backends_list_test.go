@@ -21,7 +21,7 @@ type stubNodeCommandSender struct {
 	listBackendsCalled bool
 }
 
-func (s *stubNodeCommandSender) InstallBackend(_, _, _, _, _, _, _ string, _ int, _ string, _ func(messaging.BackendInstallProgressEvent)) (*messaging.BackendInstallReply, error) {
+func (s *stubNodeCommandSender) InstallBackend(_ context.Context, _, _, _, _, _, _, _ string, _ int, _ string, _ func(messaging.BackendInstallProgressEvent)) (*messaging.BackendInstallReply, error) {
 	return &messaging.BackendInstallReply{}, nil
 }
 
@@ -27,7 +27,8 @@ func StoresSetEndpoint(sl *model.ModelLoader, cl *config.ModelConfigLoader, appC
 			vals[i] = []byte(v)
 		}
 
-		err = store.SetCols(c.Request().Context(), sb, input.Keys, vals)
+		err = store.SetCols(c.Request().Context(), sb, input.Keys, vals,
+			store.WithNamespace(input.Namespace), store.WithMetadata(input.Metadata))
 		if err != nil {
 			return err
 		}
@@ -49,7 +50,7 @@ func StoresDeleteEndpoint(sl *model.ModelLoader, cl *config.ModelConfigLoader, a
 			return err
 		}
 
-		if err := store.DeleteCols(c.Request().Context(), sb, input.Keys); err != nil {
+		if err := store.DeleteCols(c.Request().Context(), sb, input.Keys, store.WithNamespace(input.Namespace)); err != nil {
 			return err
 		}
 
@@ -70,18 +71,25 @@ func StoresGetEndpoint(sl *model.ModelLoader, cl *config.ModelConfigLoader, appC
 			return err
 		}
 
-		keys, vals, err := store.GetCols(c.Request().Context(), sb, input.Keys)
+		entries, err := store.GetEntries(c.Request().Context(), sb, input.Keys, store.WithNamespace(input.Namespace))
 		if err != nil {
 			return err
 		}
 
 		res := schema.StoresGetResponse{
-			Keys:   keys,
-			Values: make([]string, len(vals)),
+			Keys:   make([][]float32, len(entries)),
+			Values: make([]string, len(entries)),
 		}
 
-		for i, v := range vals {
-			res.Values[i] = string(v)
+		for i, e := range entries {
+			res.Keys[i] = e.Key
+			res.Values[i] = string(e.Value)
+			if e.Metadata != nil {
+				if res.Metadata == nil {
+					res.Metadata = make([]store.Metadata, len(entries))
+				}
+				res.Metadata[i] = e.Metadata
+			}
 		}
 
 		return c.JSON(200, res)
@@ -101,19 +109,28 @@ func StoresFindEndpoint(sl *model.ModelLoader, cl *config.ModelConfigLoader, app
 			return err
 		}
 
-		keys, vals, similarities, err := store.Find(c.Request().Context(), sb, input.Key, input.Topk)
+		entries, err := store.FindEntries(c.Request().Context(), sb, input.Key, input.Topk,
+			store.WithNamespace(input.Namespace), store.WithFilters(input.Filters...))
 		if err != nil {
 			return err
 		}
 
 		res := schema.StoresFindResponse{
-			Keys:         keys,
-			Values:       make([]string, len(vals)),
-			Similarities: similarities,
-		}
-
-		for i, v := range vals {
-			res.Values[i] = string(v)
+			Keys:         make([][]float32, len(entries)),
+			Values:       make([]string, len(entries)),
+			Similarities: make([]float32, len(entries)),
+		}
+
+		for i, e := range entries {
+			res.Keys[i] = e.Key
+			res.Values[i] = string(e.Value)
+			res.Similarities[i] = e.Similarity
+			if e.Metadata != nil {
+				if res.Metadata == nil {
+					res.Metadata = make([]store.Metadata, len(entries))
+				}
+				res.Metadata[i] = e.Metadata
+			}
 		}
 
 		return c.JSON(200, res)
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"os"
 	"path/filepath"
 
 	"github.com/labstack/echo/v4"
@@ -95,8 +96,18 @@ func TTSEndpoint(cl *config.ModelConfigLoader, ml *model.ModelLoader, appConfig
 			c.Response().Header().Set("Cache-Control", "no-cache")
 			c.Response().Header().Set("Connection", "keep-alive")
 
-			// Stream audio chunks as they're generated
+			// Stream audio chunks as they're generated. ModelTTSStream
+			// opens with a 16-bit mono WAV header carrying the sample rate,
+			// which turns the PCM byte count into seconds for billing.
+			headerSeen := false
+			sampleRate, pcmBytes := 0, 0
 			err := backend.ModelTTSStream(c.Request().Context(), input.Input, cfg.Voice, cfg.Language, input.Instructions, input.Params, ml, appConfig, *cfg, func(audioChunk []byte) error {
+				if !headerSeen {
+					_, sampleRate = audio.ParseWAV(audioChunk)
+					headerSeen = true
+				} else {
+					pcmBytes += len(audioChunk)
+				}
 				_, writeErr := c.Response().Write(audioChunk)
 				if writeErr != nil {
 					return writeErr
@@ -107,6 +118,9 @@ func TTSEndpoint(cl *config.ModelConfigLoader, ml *model.ModelLoader, appConfig
 			if err != nil {
 				return err
 			}
+			if sampleRate > 0 {
+				middleware.StampMediaUsage(c, input.Model, float64(pcmBytes)/float64(sampleRate*2), 0)
+			}
 
 			return nil
 		}
@@ -116,6 +130,13 @@ func TTSEndpoint(cl *config.ModelConfigLoader, ml *model.ModelLoader, appConfig
 		if err != nil {
 			return err
 		}
+		// Measure before resampling/conversion: the backend's output is a
+		// WAV, the converted file may not be.
+		if data, err := os.ReadFile(filePath); err == nil {
+			if secs, ok := audio.WAVDuration(data); ok {
+				middleware.StampMediaUsage(c, input.Model, secs, 0)
+			}
+		}
 
 		// Resample to requested sample rate if specified
 		if input.SampleRate > 0 {
@@ -0,0 +1,480 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type stubSampler struct {
+	model  string
+	req    SamplingRequest
+	caller string
+}
+
+func (s *stubSampler) Sample(ctx context.Context, modelName string, req SamplingRequest) (string, error) {
+	s.model, s.req = modelName, req
+	s.caller, _ = CallerIDFromContext(ctx)
+	return "Tuesday", nil
+}
+
+// recordingNATSClient records the MCP tool request it is sent.
+type recordingNATSClient struct {
+	data  string
+	reply string
+}
+
+func (c *recordingNATSClient) Request(_ string, data []byte, _ time.Duration) ([]byte, error) {
+	c.data = string(data)
+	return []byte(c.reply), nil
+}
+
+// bookingSession connects, through clientFor, to an in-memory server whose
+// "book" tool samples a day from the client's model and then asks the user
+// to confirm it.
+func bookingSession(ctx context.Context) []MCPToolInfo {
+	server := mcp.NewServer(&mcp.Implementation{Name: "booking", Version: "v1"}, nil)
+	mcp.AddTool(server, &mcp.Tool{Name: "book"}, func(ctx context.Context, req *mcp.CallToolRequest, _ struct{}) (*mcp.CallToolResult, any, error) {
+		sampled, err := req.Session.CreateMessage(ctx, &mcp.CreateMessageParams{
+			SystemPrompt: "Answer with a day of the week.",
+			Messages:     []*mcp.SamplingMessage{{Role: "user", Content: &mcp.TextContent{Text: "Pick a day"}}},
+			MaxTokens:    16,
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		day := sampled.Content.(*mcp.TextContent).Text
+		answer, err := req.Session.Elicit(ctx, &mcp.ElicitParams{
+			Message: "Book on " + day + "?",
+			RequestedSchema: map[string]any{
+				"type":       "object",
+				"properties": map[string]any{"date": map[string]any{"type": "string"}},
+			},
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		text := fmt.Sprintf("%s %s %v", day, answer.Action, answer.Content["date"])
+		return &mcp.CallToolResult{Content: []mcp.Content{&mcp.TextContent{Text: text}}}, nil, nil
+	})
+
+	serverTransport, clientTransport := mcp.NewInMemoryTransports()
+	serverSession, err := server.Connect(ctx, serverTransport, nil)
+	Expect(err).ToNot(HaveOccurred())
+	DeferCleanup(serverSession.Close)
+
+	session, err := clientFor("m", true, true).Connect(ctx, clientTransport, nil)
+	Expect(err).ToNot(HaveOccurred())
+	DeferCleanup(session.Close)
+	return []MCPToolInfo{{ServerName: "booking", ToolName: "book", Session: session}}
+}
+
+var _ = Describe("MCP client capabilities", func() {
+	var sampler *stubSampler
+
+	BeforeEach(func() {
+		sampler = &stubSampler{}
+		SetSampler(sampler)
+		DeferCleanup(func() { SetSampler(nil) })
+	})
+
+	It("answers sampling with the model and routes elicitation to the tool call's handler", func(ctx SpecContext) {
+		tools := bookingSession(ctx)
+
+		var asked string
+		toolCtx := WithElicitationHandler(ctx, func(_ context.Context, serverName string, params *mcp.ElicitParams) (*mcp.ElicitResult, error) {
+			asked = serverName + ": " + params.Message
+			return &mcp.ElicitResult{Action: "accept", Content: map[string]any{"date": "2026-10-20"}}, nil
+		})
+		out, err := ExecuteMCPToolCall(WithCallerID(toolCtx, "alice"), tools, "book", "")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(out).To(Equal("Tuesday accept 2026-10-20"))
+		Expect(asked).To(Equal("booking: Book on Tuesday?"))
+
+		Expect(sampler.model).To(Equal("m"))
+		Expect(sampler.caller).To(Equal("alice"))
+		Expect(sampler.req.MaxTokens).To(Equal(16))
+		Expect(sampler.req.Messages).To(HaveLen(2))
+		Expect(sampler.req.Messages[0].Role).To(Equal("system"))
+		Expect(sampler.req.Messages[1].StringContent).To(Equal("Pick a day"))
+	})
+
+	It("samples as the tool call's user on agent workers", func(ctx SpecContext) {
+		// The frontend names the user in the forwarded tool call...
+		nc := &recordingNATSClient{reply: `{"result":"ok"}`}
+		_, err := ExecuteMCPToolCallRemote(WithCallerID(ctx, "alice"), nc, "m",
+			config.MCPGenericConfig[config.MCPRemoteServers]{}, config.MCPGenericConfig[config.MCPSTDIOServers]{}, "book", "")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(nc.data).To(ContainSubstring(`"caller_id":"alice"`))
+
+		// ...and the worker's sampler passes it on to the frontend.
+		var path, userID, authz string
+		api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			path, userID, authz = r.URL.Path, r.URL.Query().Get("user_id"), r.Header.Get("Authorization")
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"Tuesday"}}]}`)
+		}))
+		DeferCleanup(api.Close)
+
+		out, err := NewAPISampler(api.URL, "worker-token").Sample(WithCallerID(ctx, "alice"), "m", SamplingRequest{
+			Messages: []schema.Message{{Role: "user", StringContent: "Pick a day"}},
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(out).To(Equal("Tuesday"))
+		Expect(path).To(Equal("/api/mcp/sampling/chat/completions"))
+		Expect(userID).To(Equal("alice"))
+		Expect(authz).To(Equal("Bearer worker-token"))
+	})
+
+	It("cancels elicitation when the tool call has no handler", func(ctx SpecContext) {
+		out, err := ExecuteMCPToolCall(ctx, bookingSession(ctx), "book", "")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(out).To(Equal("Tuesday cancel <nil>"))
+		Expect(sampler.caller).To(BeEmpty())
+	})
+
+	It("refuses sampling when no sampler is installed", func(ctx SpecContext) {
+		SetSampler(nil)
+		_, err := createMessage(ctx, "m", &mcp.CreateMessageRequest{Params: &mcp.CreateMessageParams{
+			Messages: []*mcp.SamplingMessage{{Role: "user", Content: &mcp.TextContent{Text: "hi"}}},
+		}})
+		Expect(err).To(MatchError(ContainSubstring("sampling is not available")))
+	})
+
+	It("rejects sampling requests with non-text content", func() {
+		_, err := samplingRequest(&mcp.CreateMessageParams{
+			Messages: []*mcp.SamplingMessage{{Role: "user", Content: &mcp.ImageContent{MIMEType: "image/png"}}},
+		})
+		Expect(err).To(MatchError(ContainSubstring("only text is supported")))
+
+		_, err = samplingRequest(&mcp.CreateMessageParams{})
+		Expect(err).To(MatchError(ContainSubstring("no messages")))
+	})
+})
+
+var _ = Describe("NewDispatchSampler", func() {
+	It("runs a chat completion on the handler as the sampling user", func(ctx SpecContext) {
+		var caller string
+		var body map[string]any
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			Expect(r.URL.Path).To(Equal("/v1/chat/completions"))
+			caller, _ = CallerIDFromContext(r.Context())
+			Expect(json.NewDecoder(r.Body).Decode(&body)).To(Succeed())
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"choices": []any{map[string]any{"message": map[string]any{"role": "assistant", "content": " Tuesday "}}},
+			})
+		})
+
+		out, err := NewDispatchSampler(handler).Sample(WithCallerID(ctx, "alice"), "m", SamplingRequest{
+			Messages:  []schema.Message{{Role: "user", StringContent: "Pick a day"}},
+			MaxTokens: 16,
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(out).To(Equal("Tuesday"))
+		Expect(caller).To(Equal("alice"))
+		Expect(body["model"]).To(Equal("m"))
+		Expect(body["max_tokens"]).To(BeEquivalentTo(16))
+	})
+
+	It("returns the API error of the handler", func(ctx SpecContext) {
+		handler := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusTooManyRequests)
+			_, _ = w.Write([]byte(`{"error":{"message":"quota exceeded","code":429}}`))
+		})
+		_, err := NewDispatchSampler(handler).Sample(ctx, "m", SamplingRequest{
+			Messages: []schema.Message{{Role: "user", StringContent: "hi"}},
+		})
+		Expect(err).To(MatchError(ContainSubstring("quota exceeded")))
+	})
+})
+
+var _ = Describe("StreamElicitation", func() {
+	It("waits for the streaming user's answer", func(ctx SpecContext) {
+		events := make(chan ElicitationEvent, 1)
+		h := StreamElicitation("alice", func(e ElicitationEvent) { events <- e })
+
+		result := make(chan *mcp.ElicitResult, 1)
+		go func() {
+			defer GinkgoRecover()
+			res, err := h(ctx, "booking", &mcp.ElicitParams{Message: "Confirm?"})
+			Expect(err).ToNot(HaveOccurred())
+			result <- res
+		}()
+
+		var e ElicitationEvent
+		Eventually(events).Should(Receive(&e))
+		Expect(e.Type).To(Equal("mcp_elicitation"))
+		Expect(e.Server).To(Equal("booking"))
+		Expect(e.Message).To(Equal("Confirm?"))
+
+		Expect(AnswerElicitation(e.ID, "bob", ElicitationAnswer{Action: "accept"})).To(MatchError(ErrElicitationNotFound))
+		Expect(AnswerElicitation(e.ID, "alice", ElicitationAnswer{Action: "maybe"})).To(MatchError(ContainSubstring("invalid action")))
+		Expect(AnswerElicitation(e.ID, "alice", ElicitationAnswer{Action: "decline"})).To(Succeed())
+
+		var res *mcp.ElicitResult
+		Eventually(result).Should(Receive(&res))
+		Expect(res.Action).To(Equal("decline"))
+		Expect(AnswerElicitation(e.ID, "alice", ElicitationAnswer{Action: "accept"})).To(MatchError(ErrElicitationNotFound))
+	})
+
+	It("cancels when the caller goes away", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		h := StreamElicitation("alice", func(ElicitationEvent) { cancel() })
+		res, err := h(ctx, "booking", &mcp.ElicitParams{Message: "Confirm?"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res.Action).To(Equal("cancel"))
+	})
+})
+
+var _ = Describe("OAuth-protected remote servers", func() {
+	var (
+		srv      *httptest.Server
+		endpoint string
+		tokens   int
+	)
+
+	BeforeEach(func() {
+		SetOAuthStore(newMemoryOAuthStore())
+		DeferCleanup(func() { SetOAuthStore(newMemoryOAuthStore()) })
+
+		tokens = 0
+		mux := http.NewServeMux()
+		srv = httptest.NewServer(mux)
+		DeferCleanup(srv.Close)
+		endpoint = srv.URL + "/mcp"
+
+		mux.HandleFunc("/.well-known/oauth-protected-resource", func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"resource":              endpoint,
+				"authorization_servers": []string{srv.URL},
+			})
+		})
+		mux.HandleFunc("/.well-known/oauth-authorization-server", func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"issuer":                           srv.URL,
+				"authorization_endpoint":           srv.URL + "/authorize",
+				"token_endpoint":                   srv.URL + "/token",
+				"response_types_supported":         []string{"code"},
+				"code_challenge_methods_supported": []string{"S256"},
+			})
+		})
+		// The authorization server rotates refresh tokens: each one is
+		// accepted once.
+		used := map[string]bool{}
+		mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+			Expect(r.ParseForm()).To(Succeed())
+			grant := r.Form.Get("grant_type")
+			if grant == "refresh_token" {
+				rt := r.Form.Get("refresh_token")
+				if used[rt] {
+					w.WriteHeader(http.StatusBadRequest)
+					_ = json.NewEncoder(w).Encode(map[string]any{"error": "invalid_grant"})
+					return
+				}
+				used[rt] = true
+			} else {
+				Expect(r.Form.Get("resource")).To(Equal(endpoint))
+			}
+			tokens++
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"access_token":  fmt.Sprintf("token-%d-%s", tokens, grant),
+				"refresh_token": fmt.Sprintf("refresh-%d", tokens),
+				"token_type":    "Bearer",
+				"expires_in":    3600,
+			})
+		})
+	})
+
+	challenge := func() *http.Response {
+		rec := httptest.NewRecorder()
+		rec.Header().Set("WWW-Authenticate", `Bearer resource_metadata="`+srv.URL+`/.well-known/oauth-protected-resource"`)
+		rec.WriteHeader(http.StatusUnauthorized)
+		return rec.Result()
+	}
+
+	accessToken := func(ctx context.Context, h *oauthHandler) string {
+		ts, err := h.TokenSource(ctx)
+		Expect(err).ToNot(HaveOccurred())
+		tok, err := ts.Token()
+		Expect(err).ToNot(HaveOccurred())
+		if tok == nil {
+			return ""
+		}
+		return tok.AccessToken
+	}
+
+	authorizationCodeServer := func() config.MCPRemoteServer {
+		return config.MCPRemoteServer{URL: endpoint, OAuth: &config.MCPOAuthConfig{
+			ClientID:    "localai",
+			RedirectURL: "https://localai.example/api/mcp/oauth/callback",
+		}}
+	}
+
+	// authorize runs an authorization_code flow of h to completion.
+	authorize := func(ctx context.Context, h *oauthHandler) {
+		var authErr *AuthorizationRequiredError
+		Expect(errors.As(h.Authorize(ctx, nil, challenge()), &authErr)).To(BeTrue())
+		authURL, err := url.Parse(authErr.URL)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(CompleteOAuthAuthorization(ctx, authURL.Query().Get("state"), "code")).To(Succeed())
+	}
+
+	It("obtains a token with the client_credentials grant", func(ctx SpecContext) {
+		h := oauthHandlerFor("m", "crm", config.MCPRemoteServer{URL: endpoint, OAuth: &config.MCPOAuthConfig{
+			GrantType:    config.MCPOAuthClientCredentials,
+			ClientID:     "localai",
+			ClientSecret: "secret",
+		}})
+
+		Expect(accessToken(ctx, h)).To(BeEmpty())
+		Expect(h.Authorize(ctx, nil, challenge())).To(Succeed())
+		Expect(accessToken(ctx, h)).To(Equal("token-1-client_credentials"))
+	})
+
+	It("hands out an authorization URL and completes the flow on callback", func(ctx SpecContext) {
+		h := oauthHandlerFor("m", "crm", authorizationCodeServer())
+
+		err := h.Authorize(ctx, nil, challenge())
+		var authErr *AuthorizationRequiredError
+		Expect(errors.As(err, &authErr)).To(BeTrue())
+		Expect(authErr.Server).To(Equal("crm"))
+		// Any user of the model may see the error; only administrators
+		// get the URL.
+		Expect(authErr.Error()).ToNot(ContainSubstring(authErr.URL))
+		pending, err := PendingOAuthAuthorizations(ctx)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(pending).To(HaveLen(1))
+		Expect(pending[0].Model).To(Equal("m"))
+		Expect(pending[0].Server).To(Equal("crm"))
+		Expect(pending[0].AuthURL).To(Equal(authErr.URL))
+
+		// A second attempt while the flow is pending reuses its URL.
+		var again *AuthorizationRequiredError
+		Expect(errors.As(h.Authorize(ctx, nil, challenge()), &again)).To(BeTrue())
+		Expect(again.URL).To(Equal(authErr.URL))
+
+		authURL, err := url.Parse(authErr.URL)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(authURL.Path).To(Equal("/authorize"))
+		q := authURL.Query()
+		Expect(q.Get("code_challenge_method")).To(Equal("S256"))
+		Expect(q.Get("resource")).To(Equal(endpoint))
+
+		Expect(CompleteOAuthAuthorization(ctx, "unknown", "code")).To(MatchError(ErrOAuthFlowNotFound))
+		Expect(CompleteOAuthAuthorization(ctx, q.Get("state"), "code")).To(Succeed())
+		Expect(CompleteOAuthAuthorization(ctx, q.Get("state"), "code")).To(MatchError(ErrOAuthFlowNotFound))
+
+		Expect(accessToken(ctx, h)).To(Equal("token-1-authorization_code"))
+	})
+
+	It("keeps credentials encrypted in the database and refreshes them once across processes", func(ctx SpecContext) {
+		dsn := fmt.Sprintf("file:mcp_oauth_%d?mode=memory&cache=shared", time.Now().UnixNano())
+		db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+		Expect(err).ToNot(HaveOccurred())
+		store, err := NewGormOAuthStore(db, "hmac-secret")
+		Expect(err).ToNot(HaveOccurred())
+		SetOAuthStore(store)
+
+		h := oauthHandlerFor("m", "crm", authorizationCodeServer())
+		authorize(ctx, h)
+
+		var rows []oauthCredentialRecord
+		Expect(db.Find(&rows).Error).To(Succeed())
+		Expect(rows).To(HaveLen(1))
+		Expect(string(rows[0].Sealed)).ToNot(ContainSubstring("token-1"))
+		Expect(string(rows[0].Sealed)).ToNot(ContainSubstring("refresh-1"))
+
+		// Expire the token; handlers of several processes then refresh it
+		// concurrently, and only one refresh reaches the server.
+		cred, err := store.Credential(ctx, h.key)
+		Expect(err).ToNot(HaveOccurred())
+		cred.Token.Expiry = time.Now().Add(-time.Minute)
+		Expect(store.SaveCredential(ctx, h.key, cred)).To(Succeed())
+
+		var wg sync.WaitGroup
+		got := make([]string, 4)
+		for i := range got {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer GinkgoRecover()
+				got[i] = accessToken(ctx, oauthHandlerFor("m", "crm", authorizationCodeServer()))
+			}()
+		}
+		wg.Wait()
+		Expect(got).To(HaveEach("token-2-refresh_token"))
+		Expect(tokens).To(Equal(2))
+
+		// Another replica with the same secret reads the credential back.
+		other, err := NewGormOAuthStore(db, "hmac-secret")
+		Expect(err).ToNot(HaveOccurred())
+		cred, err = other.Credential(ctx, h.key)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(cred.Token.RefreshToken).To(Equal("refresh-2"))
+	})
+
+	It("authorizes agent workers through the frontend API", func(ctx SpecContext) {
+		server := authorizationCodeServer()
+		frontend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			Expect(r.Header.Get("Authorization")).To(Equal("Bearer worker-key"))
+			switch r.URL.Path {
+			case "/api/mcp/oauth/token":
+				Expect(r.URL.Query().Get("server")).To(Equal("crm"))
+				tok, err := OAuthToken(r.Context(), r.URL.Query().Get("model"), "crm", server)
+				Expect(err).ToNot(HaveOccurred())
+				if tok == nil {
+					w.WriteHeader(http.StatusNoContent)
+					return
+				}
+				Expect(tok.RefreshToken).To(BeEmpty())
+				_ = json.NewEncoder(w).Encode(tok)
+			case "/api/mcp/oauth/authorize":
+				var req OAuthAuthorizeRequest
+				Expect(json.NewDecoder(r.Body).Decode(&req)).To(Succeed())
+				var authErr *AuthorizationRequiredError
+				Expect(errors.As(AuthorizeOAuth(r.Context(), req.Model, req.Server, server, req.Challenge), &authErr)).To(BeTrue())
+				w.WriteHeader(http.StatusConflict)
+				_ = json.NewEncoder(w).Encode(OAuthAuthorizeError{Error: authErr.Error(), AuthorizationURL: authErr.URL})
+			}
+		}))
+		DeferCleanup(frontend.Close)
+
+		UseOAuthAPI(frontend.URL, "worker-key")
+		DeferCleanup(func() {
+			oauthState.mu.Lock()
+			oauthState.api = nil
+			oauthState.mu.Unlock()
+		})
+
+		h := oauthHandlerFor("m", "crm", server)
+		Expect(accessToken(ctx, h)).To(BeEmpty())
+		err := h.Authorize(ctx, nil, challenge())
+		var authErr *AuthorizationRequiredError
+		Expect(errors.As(err, &authErr)).To(BeTrue())
+
+		authURL, err := url.Parse(authErr.URL)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(CompleteOAuthAuthorization(ctx, authURL.Query().Get("state"), "code")).To(Succeed())
+		Expect(accessToken(ctx, h)).To(Equal("token-1-authorization_code"))
+	})
+})
@@ -29,7 +29,7 @@ var _ = Describe("connectMCP", func() {
 		transport := &mcp.CommandTransport{Command: exec.CommandContext(ctx, "sleep", "60")}
 
 		start := time.Now()
-		session, err := connectMCP(ctx, transport, 200*time.Millisecond)
+		session, err := connectMCP(ctx, client, transport, 200*time.Millisecond)
 		elapsed := time.Since(start)
 
 		Expect(err).To(HaveOccurred())
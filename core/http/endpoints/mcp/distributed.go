@@ -0,0 +1,29 @@
+package mcp
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/mudler/LocalAI/core/services/messaging"
+	"github.com/mudler/xlog"
+)
+
+// EnableDistributed wires the MCP client capabilities of this process
+// (frontend or agent worker) into the cluster: elicitation answers that
+// landed on another process are delivered here when this process holds the
+// pending request. OAuth needs no wiring: credentials and pending
+// authorizations live in the OAuth store the frontends share, and agent
+// workers fetch tokens through the API (see UseOAuthAPI).
+func EnableDistributed(nats messaging.MessagingClient) error {
+	if nats == nil {
+		return nil
+	}
+	if _, err := messaging.SubscribeJSON(nats, messaging.SubjectMCPElicitationAnswers, func(m elicitationAnswerMessage) {
+		if err := AnswerElicitation(m.ID, m.UserID, m.Answer); err != nil && !errors.Is(err, ErrElicitationNotFound) {
+			xlog.Warn("Failed to deliver MCP elicitation answer", "id", m.ID, "error", err)
+		}
+	}); err != nil {
+		return fmt.Errorf("subscribing to %s: %w", messaging.SubjectMCPElicitationAnswers, err)
+	}
+	return nil
+}
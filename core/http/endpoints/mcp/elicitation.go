@@ -0,0 +1,291 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/mudler/LocalAI/core/config"
+	mcpRemote "github.com/mudler/LocalAI/core/services/mcp"
+	"github.com/mudler/LocalAI/core/services/messaging"
+	"github.com/mudler/xlog"
+)
+
+// ElicitationHandler answers an elicitation request that an MCP server
+// sent while one of the request's tool calls was running. ctx is the
+// context of that tool call.
+type ElicitationHandler func(ctx context.Context, serverName string, params *mcp.ElicitParams) (*mcp.ElicitResult, error)
+
+type elicitationHandlerKey struct{}
+
+// WithElicitationHandler returns a context whose MCP tool calls route the
+// elicitation requests of their server to h. Without one, elicitation
+// requests are cancelled.
+func WithElicitationHandler(ctx context.Context, h ElicitationHandler) context.Context {
+	return context.WithValue(ctx, elicitationHandlerKey{}, h)
+}
+
+func elicitationHandlerFrom(ctx context.Context) ElicitationHandler {
+	h, _ := ctx.Value(elicitationHandlerKey{}).(ElicitationHandler)
+	return h
+}
+
+// boundCall is a tool call running on a session. handler is nil when the
+// call has no elicitation handler.
+type boundCall struct {
+	ctx        context.Context
+	serverName string
+	handler    ElicitationHandler
+}
+
+// inflight tracks the tool calls running on each session, so that an
+// elicitation or sampling request (which the SDK delivers per session)
+// reaches the caller whose tool call triggered it.
+var inflight = struct {
+	mu    sync.Mutex
+	calls map[*mcp.ClientSession][]*boundCall
+}{calls: make(map[*mcp.ClientSession][]*boundCall)}
+
+// bindCall registers a tool call on session until the returned function
+// is called.
+func bindCall(ctx context.Context, session *mcp.ClientSession, serverName string, h ElicitationHandler) func() {
+	b := &boundCall{ctx: ctx, serverName: serverName, handler: h}
+	inflight.mu.Lock()
+	inflight.calls[session] = append(inflight.calls[session], b)
+	inflight.mu.Unlock()
+	return func() {
+		inflight.mu.Lock()
+		defer inflight.mu.Unlock()
+		calls := inflight.calls[session]
+		for i, c := range calls {
+			if c == b {
+				calls = append(calls[:i], calls[i+1:]...)
+				break
+			}
+		}
+		if len(calls) == 0 {
+			delete(inflight.calls, session)
+		} else {
+			inflight.calls[session] = calls
+		}
+	}
+}
+
+// elicit is the SDK elicitation handler of every client advertising
+// elicitation. With no tool call with a handler bound to the session, or
+// with several (the request cannot be attributed), the request is
+// cancelled.
+func elicit(_ context.Context, req *mcp.ElicitRequest) (*mcp.ElicitResult, error) {
+	inflight.mu.Lock()
+	var b *boundCall
+	for _, c := range inflight.calls[req.Session] {
+		if c.handler == nil {
+			continue
+		}
+		if b != nil {
+			b = nil
+			break
+		}
+		b = c
+	}
+	inflight.mu.Unlock()
+	if b == nil {
+		xlog.Debug("MCP elicitation request without a single caller to answer it, cancelling")
+		return &mcp.ElicitResult{Action: "cancel"}, nil
+	}
+	return b.handler(b.ctx, b.serverName, req.Params)
+}
+
+// elicitationAnswerTimeout bounds how long a tool call waits for the API
+// caller to answer an elicitation request.
+const elicitationAnswerTimeout = 5 * time.Minute
+
+// ElicitationEvent is the "mcp_elicitation" event streamed to the API
+// caller. The caller answers by posting an ElicitationAnswer to
+// /v1/mcp/elicitations/{id}.
+type ElicitationEvent struct {
+	Type            string `json:"type"`
+	ID              string `json:"id"`
+	Server          string `json:"server"`
+	Message         string `json:"message"`
+	Mode            string `json:"mode,omitempty"`
+	URL             string `json:"url,omitempty"`
+	RequestedSchema any    `json:"requested_schema,omitempty"`
+}
+
+// ElicitationAnswer is the caller's answer to an elicitation request.
+type ElicitationAnswer struct {
+	Action  string         `json:"action"`
+	Content map[string]any `json:"content,omitempty"`
+}
+
+// ErrElicitationNotFound is returned by AnswerElicitation when this process
+// holds no pending elicitation with that ID for the user.
+var ErrElicitationNotFound = errors.New("elicitation not found")
+
+type pendingElicitation struct {
+	userID string
+	answer chan ElicitationAnswer
+}
+
+var pendingElicitations = struct {
+	mu sync.Mutex
+	m  map[string]*pendingElicitation
+}{m: make(map[string]*pendingElicitation)}
+
+// StreamElicitation returns an ElicitationHandler that announces each
+// request to the API caller through emit and waits for userID to answer
+// it. Unanswered requests are cancelled when the caller goes away or after
+// elicitationAnswerTimeout.
+func StreamElicitation(userID string, emit func(ElicitationEvent)) ElicitationHandler {
+	return func(ctx context.Context, serverName string, params *mcp.ElicitParams) (*mcp.ElicitResult, error) {
+		id := uuid.NewString()
+		p := &pendingElicitation{userID: userID, answer: make(chan ElicitationAnswer, 1)}
+		pendingElicitations.mu.Lock()
+		pendingElicitations.m[id] = p
+		pendingElicitations.mu.Unlock()
+		defer func() {
+			pendingElicitations.mu.Lock()
+			delete(pendingElicitations.m, id)
+			pendingElicitations.mu.Unlock()
+		}()
+
+		emit(ElicitationEvent{
+			Type:            "mcp_elicitation",
+			ID:              id,
+			Server:          serverName,
+			Message:         params.Message,
+			Mode:            params.Mode,
+			URL:             params.URL,
+			RequestedSchema: params.RequestedSchema,
+		})
+
+		timer := time.NewTimer(elicitationAnswerTimeout)
+		defer timer.Stop()
+		select {
+		case a := <-p.answer:
+			return &mcp.ElicitResult{Action: a.Action, Content: a.Content}, nil
+		case <-ctx.Done():
+		case <-timer.C:
+			xlog.Debug("MCP elicitation not answered in time, cancelling", "id", id, "server", serverName)
+		}
+		return &mcp.ElicitResult{Action: "cancel"}, nil
+	}
+}
+
+// AnswerElicitation delivers userID's answer to a pending elicitation of
+// this process.
+func AnswerElicitation(id, userID string, a ElicitationAnswer) error {
+	switch a.Action {
+	case "accept", "decline", "cancel":
+	default:
+		return fmt.Errorf("invalid action %q (want accept, decline or cancel)", a.Action)
+	}
+	pendingElicitations.mu.Lock()
+	p, ok := pendingElicitations.m[id]
+	pendingElicitations.mu.Unlock()
+	if !ok || p.userID != userID {
+		return ErrElicitationNotFound
+	}
+	select {
+	case p.answer <- a:
+	default:
+		// Already answered.
+	}
+	return nil
+}
+
+// elicitationAnswerMessage is broadcast on SubjectMCPElicitationAnswers
+// when an answer lands on a frontend replica other than the one streaming
+// the request.
+type elicitationAnswerMessage struct {
+	ID     string            `json:"id"`
+	UserID string            `json:"user_id"`
+	Answer ElicitationAnswer `json:"answer"`
+}
+
+// PublishElicitationAnswer hands an answer this replica could not deliver
+// to its peers.
+func PublishElicitationAnswer(nats messaging.Publisher, id, userID string, a ElicitationAnswer) error {
+	return nats.Publish(messaging.SubjectMCPElicitationAnswers, elicitationAnswerMessage{ID: id, UserID: userID, Answer: a})
+}
+
+// replySubscriber is implemented by NATS clients that can serve
+// request-reply subjects, needed to answer the elicitation requests of a
+// tool call running on an agent worker.
+type replySubscriber interface {
+	SubscribeReply(subject string, handler func(data []byte, reply func([]byte))) (messaging.Subscription, error)
+}
+
+// serveRemoteElicitation answers, with h, the elicitation requests an
+// agent worker sends for one forwarded tool call. It returns the subject
+// the worker must send them to and the function tearing the subscription
+// down.
+func serveRemoteElicitation(ctx context.Context, nats replySubscriber, h ElicitationHandler) (string, func(), error) {
+	subject := messaging.SubjectMCPElicitationRequest(uuid.NewString())
+	sub, err := nats.SubscribeReply(subject, func(data []byte, reply func([]byte)) {
+		var req mcpRemote.MCPElicitationRequest
+		resp := mcpRemote.MCPElicitationResponse{Action: "cancel"}
+		if err := json.Unmarshal(data, &req); err != nil {
+			resp.Error = fmt.Sprintf("unmarshal error: %v", err)
+		} else if res, err := h(ctx, req.ServerName, &mcp.ElicitParams{
+			Message:         req.Message,
+			Mode:            req.Mode,
+			URL:             req.URL,
+			RequestedSchema: req.RequestedSchema,
+		}); err != nil {
+			resp.Error = err.Error()
+		} else {
+			resp.Action, resp.Content = res.Action, res.Content
+		}
+		out, _ := json.Marshal(resp)
+		reply(out)
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	return subject, func() {
+		if err := sub.Unsubscribe(); err != nil {
+			xlog.Debug("Failed to unsubscribe MCP elicitation subject", "subject", subject, "error", err)
+		}
+	}, nil
+}
+
+// ForwardElicitation returns the ElicitationHandler of an agent worker
+// running a forwarded tool call: requests are sent back to the frontend
+// on subject, which asks the API caller.
+func ForwardElicitation(nats MCPNATSClient, subject string) ElicitationHandler {
+	return func(ctx context.Context, serverName string, params *mcp.ElicitParams) (*mcp.ElicitResult, error) {
+		data, err := json.Marshal(mcpRemote.MCPElicitationRequest{
+			ServerName:      serverName,
+			Message:         params.Message,
+			Mode:            params.Mode,
+			URL:             params.URL,
+			RequestedSchema: params.RequestedSchema,
+		})
+		if err != nil {
+			return nil, err
+		}
+		timeout := config.DefaultMCPToolTimeout
+		if deadline, ok := ctx.Deadline(); ok {
+			timeout = time.Until(deadline)
+		}
+		replyData, err := nats.Request(subject, data, timeout)
+		if err != nil {
+			return nil, fmt.Errorf("forwarding MCP elicitation: %w", err)
+		}
+		var resp mcpRemote.MCPElicitationResponse
+		if err := json.Unmarshal(replyData, &resp); err != nil {
+			return nil, fmt.Errorf("unmarshal MCP elicitation reply: %w", err)
+		}
+		if resp.Error != "" {
+			return nil, fmt.Errorf("MCP elicitation failed: %s", resp.Error)
+		}
+		return &mcp.ElicitResult{Action: resp.Action, Content: resp.Content}, nil
+	}
+}
@@ -58,6 +58,25 @@ func (e *LocalToolExecutor) HasTools() bool {
 	return len(e.tools) > 0
 }
 
+// ExecutorFor returns e with its tool calls made on behalf of userID (see
+// WithCallerID). Without a user, when authentication is disabled, e is
+// returned as is.
+func ExecutorFor(userID string, e ToolExecutor) ToolExecutor {
+	if userID == "" {
+		return e
+	}
+	return callerExecutor{ToolExecutor: e, userID: userID}
+}
+
+type callerExecutor struct {
+	ToolExecutor
+	userID string
+}
+
+func (e callerExecutor) ExecuteTool(ctx context.Context, toolName, arguments string) (string, error) {
+	return e.ToolExecutor.ExecuteTool(WithCallerID(ctx, e.userID), toolName, arguments)
+}
+
 // DistributedToolExecutor routes tool operations through NATS to agent workers.
 type DistributedToolExecutor struct {
 	natsClient MCPNATSClient
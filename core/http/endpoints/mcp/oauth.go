@@ -0,0 +1,429 @@
+package mcp
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/auth"
+	"github.com/modelcontextprotocol/go-sdk/oauthex"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/pkg/httpclient"
+	"github.com/mudler/xlog"
+	"golang.org/x/oauth2"
+)
+
+const (
+	// oauthHTTPTimeout bounds each discovery, registration and token
+	// request made to an authorization server.
+	oauthHTTPTimeout = 30 * time.Second
+	// oauthFlowTTL is how long an authorization URL stays valid.
+	oauthFlowTTL = 15 * time.Minute
+)
+
+// ErrOAuthFlowNotFound is returned by CompleteOAuthAuthorization when no
+// authorization is pending with that state (or it expired).
+var ErrOAuthFlowNotFound = errors.New("no pending MCP authorization for this state")
+
+// AuthorizationRequiredError is returned while connecting to a remote
+// server configured with the authorization_code grant that has not been
+// authorized yet. It surfaces as the server's connection error, which any
+// user of the model may see, so its message leaves out URL: the credential
+// an authorization yields is shared by every user of the model, and only
+// administrators get the URL (see PendingOAuthAuthorizations).
+type AuthorizationRequiredError struct {
+	Server string
+	URL    string
+}
+
+func (e *AuthorizationRequiredError) Error() string {
+	return fmt.Sprintf("MCP server %q requires authorization: an administrator must grant LocalAI access", e.Server)
+}
+
+// oauthState holds the store of the OAuth credentials and pending flows of
+// this process, and on agent workers the LocalAI API they obtain tokens
+// from instead.
+var oauthState = struct {
+	mu    sync.RWMutex
+	store OAuthStore
+	api   *oauthAPI
+}{
+	store: newMemoryOAuthStore(),
+}
+
+// SetOAuthStore installs the store of the OAuth credentials of MCP
+// servers. Until one is set they are kept in memory.
+func SetOAuthStore(s OAuthStore) {
+	oauthState.mu.Lock()
+	defer oauthState.mu.Unlock()
+	oauthState.store = s
+}
+
+func oauthStore() OAuthStore {
+	oauthState.mu.RLock()
+	defer oauthState.mu.RUnlock()
+	return oauthState.store
+}
+
+func currentOAuthAPI() *oauthAPI {
+	oauthState.mu.RLock()
+	defer oauthState.mu.RUnlock()
+	return oauthState.api
+}
+
+// oauthContext carries the HTTP client of the oauth2 token requests.
+func oauthContext(ctx context.Context, c *http.Client) context.Context {
+	return context.WithValue(ctx, oauth2.HTTPClient, c)
+}
+
+// oauthHandler authorizes the requests of one remote MCP server. It
+// implements auth.OAuthHandler for the streamable HTTP transport: the
+// transport attaches TokenSource's token to every request and calls
+// Authorize when the server answers 401 or 403.
+type oauthHandler struct {
+	key        string
+	model      string
+	server     string
+	endpoint   string
+	cfg        config.MCPOAuthConfig
+	httpClient *http.Client
+
+	mu     sync.Mutex
+	cached *oauth2.Token
+}
+
+var _ auth.OAuthHandler = (*oauthHandler)(nil)
+
+func oauthHandlerFor(modelName, serverName string, server config.MCPRemoteServer) *oauthHandler {
+	h := sha256.New()
+	for _, part := range []string{modelName, serverName, server.URL, server.OAuth.GrantType, server.OAuth.ClientID, strings.Join(server.OAuth.Scopes, " ")} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return &oauthHandler{
+		key:        hex.EncodeToString(h.Sum(nil)),
+		model:      modelName,
+		server:     serverName,
+		endpoint:   server.URL,
+		cfg:        *server.OAuth,
+		httpClient: httpclient.New(httpclient.WithTimeout(oauthHTTPTimeout)),
+	}
+}
+
+func (h *oauthHandler) TokenSource(ctx context.Context) (oauth2.TokenSource, error) {
+	return handlerTokenSource{ctx: ctx, h: h}, nil
+}
+
+// handlerTokenSource returns the handler's current token, or a nil token
+// (the request goes out unauthenticated and Authorize follows) when
+// LocalAI holds none.
+type handlerTokenSource struct {
+	ctx context.Context
+	h   *oauthHandler
+}
+
+func (s handlerTokenSource) Token() (*oauth2.Token, error) {
+	h := s.h
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.cached.Valid() {
+		return h.cached, nil
+	}
+	var tok *oauth2.Token
+	var err error
+	if api := currentOAuthAPI(); api != nil {
+		tok, err = api.token(s.ctx, h.model, h.server)
+	} else {
+		tok, err = h.token(s.ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+	h.cached = tok
+	return tok, nil
+}
+
+func (h *oauthHandler) Authorize(ctx context.Context, _ *http.Request, resp *http.Response) error {
+	defer resp.Body.Close()
+	h.mu.Lock()
+	h.cached = nil
+	h.mu.Unlock()
+	challenge := resp.Header.Values("WWW-Authenticate")
+	if api := currentOAuthAPI(); api != nil {
+		return api.authorize(ctx, h.model, h.server, challenge)
+	}
+	return h.authorize(ctx, challenge)
+}
+
+// token returns the stored token of the server, refreshing it when it
+// expired. The refresh runs under the store's lock of the credential and
+// re-reads it first, so the processes sharing the store refresh it once and
+// a rotated refresh token is never used twice. A credential that can no
+// longer be refreshed yields no token, so that the server's 401 starts a
+// new authorization.
+func (h *oauthHandler) token(ctx context.Context) (*oauth2.Token, error) {
+	store := oauthStore()
+	cred, err := store.Credential(ctx, h.key)
+	if err != nil || cred == nil || cred.Token.Valid() {
+		return credentialToken(cred), err
+	}
+	err = store.Locked(ctx, h.key, func() error {
+		cred, err = store.Credential(ctx, h.key)
+		if err != nil || cred == nil || cred.Token.Valid() {
+			return err
+		}
+		tok, err := cred.refresh(oauthContext(ctx, h.httpClient))
+		if err != nil {
+			xlog.Warn("Failed to refresh MCP OAuth token", "server", h.server, "error", err)
+			cred = nil
+			return nil
+		}
+		cred.Token = tok
+		return store.SaveCredential(ctx, h.key, cred)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return credentialToken(cred), nil
+}
+
+// credentialToken returns the access token of c, without its refresh
+// token.
+func credentialToken(c *OAuthCredential) *oauth2.Token {
+	if c == nil || c.Token == nil {
+		return nil
+	}
+	return &oauth2.Token{AccessToken: c.Token.AccessToken, TokenType: c.Token.TokenType, Expiry: c.Token.Expiry}
+}
+
+// authorize obtains a new credential after the server refused a request
+// with challenge, its WWW-Authenticate values.
+func (h *oauthHandler) authorize(ctx context.Context, challenge []string) error {
+	// The server refused the token we hold, if any: it is expired beyond
+	// refresh, revoked or lacks a scope.
+	if err := oauthStore().DeleteCredential(ctx, h.key); err != nil {
+		return err
+	}
+
+	resource, asm, scopes, err := h.discover(ctx, challenge)
+	if err != nil {
+		return fmt.Errorf("discovering the authorization server of MCP server %q: %w", h.server, err)
+	}
+	if h.cfg.GrantType == config.MCPOAuthClientCredentials {
+		return h.clientCredentials(ctx, asm, resource, scopes)
+	}
+	return h.authorizationCode(ctx, asm, resource, scopes)
+}
+
+// OAuthToken returns the current access token of a model's OAuth-protected
+// MCP server, refreshing it when it expired; nil when LocalAI holds none.
+// It serves the token requests of agent workers.
+func OAuthToken(ctx context.Context, modelName, serverName string, server config.MCPRemoteServer) (*oauth2.Token, error) {
+	return oauthHandlerFor(modelName, serverName, server).token(ctx)
+}
+
+// AuthorizeOAuth authorizes LocalAI with a model's OAuth-protected MCP
+// server after the server refused a request with challenge, its
+// WWW-Authenticate values. It serves the authorization requests of agent
+// workers.
+func AuthorizeOAuth(ctx context.Context, modelName, serverName string, server config.MCPRemoteServer, challenge []string) error {
+	return oauthHandlerFor(modelName, serverName, server).authorize(ctx, challenge)
+}
+
+// discover finds the authorization server of the MCP server: from the
+// protected resource metadata (RFC 9728) it advertises in its challenge or
+// at the well-known locations, falling back to the server's own origin for
+// servers predating resource metadata.
+func (h *oauthHandler) discover(ctx context.Context, challenge []string) (resource string, asm *oauthex.AuthServerMeta, scopes []string, err error) {
+	resource, scopes = h.endpoint, h.cfg.Scopes
+	var metadataURLs []string
+	if challenges, err := oauthex.ParseWWWAuthenticate(challenge); err == nil {
+		for _, c := range challenges {
+			if c.Scheme != "bearer" {
+				continue
+			}
+			if u := c.Params["resource_metadata"]; u != "" {
+				metadataURLs = append(metadataURLs, u)
+			}
+			if s := c.Params["scope"]; s != "" && len(scopes) == 0 {
+				scopes = strings.Fields(s)
+			}
+		}
+	}
+	u, err := url.Parse(h.endpoint)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	origin := u.Scheme + "://" + u.Host
+	if p := strings.TrimRight(u.Path, "/"); p != "" {
+		metadataURLs = append(metadataURLs, origin+"/.well-known/oauth-protected-resource"+p)
+	}
+	metadataURLs = append(metadataURLs, origin+"/.well-known/oauth-protected-resource")
+
+	issuer := origin
+	for _, metadataURL := range metadataURLs {
+		prm, err := oauthex.GetProtectedResourceMetadata(ctx, metadataURL, h.endpoint, h.httpClient)
+		if err != nil {
+			xlog.Debug("MCP protected resource metadata not usable", "server", h.server, "url", metadataURL, "error", err)
+			continue
+		}
+		if len(prm.AuthorizationServers) > 0 {
+			issuer = prm.AuthorizationServers[0]
+		}
+		resource = prm.Resource
+		if len(scopes) == 0 {
+			scopes = prm.ScopesSupported
+		}
+		break
+	}
+
+	asm, err = auth.GetAuthServerMetadata(ctx, issuer, h.httpClient)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	if asm == nil {
+		return "", nil, nil, fmt.Errorf("no authorization server metadata found for %s", issuer)
+	}
+	return resource, asm, scopes, nil
+}
+
+func (h *oauthHandler) clientCredentials(ctx context.Context, asm *oauthex.AuthServerMeta, resource string, scopes []string) error {
+	cred := &OAuthCredential{
+		Server:       h.server,
+		GrantType:    config.MCPOAuthClientCredentials,
+		ClientID:     h.cfg.ClientID,
+		ClientSecret: h.cfg.ClientSecret,
+		TokenURL:     asm.TokenEndpoint,
+		Scopes:       scopes,
+		Resource:     resource,
+	}
+	tok, err := cred.refresh(oauthContext(ctx, h.httpClient))
+	if err != nil {
+		return fmt.Errorf("client credentials grant for MCP server %q: %w", h.server, err)
+	}
+	cred.Token = tok
+	return oauthStore().SaveCredential(ctx, h.key, cred)
+}
+
+// authorizationCode starts an authorization_code flow with PKCE and
+// returns an AuthorizationRequiredError carrying the URL an administrator
+// opens to authorize LocalAI. The authorization server redirects to
+// /api/mcp/oauth/callback, where the administrator completes the flow; the
+// next connection attempt then uses the token.
+func (h *oauthHandler) authorizationCode(ctx context.Context, asm *oauthex.AuthServerMeta, resource string, scopes []string) error {
+	if h.cfg.RedirectURL == "" {
+		return fmt.Errorf("MCP server %q: oauth.redirect_url is required for the authorization_code grant", h.server)
+	}
+
+	store := oauthStore()
+	pending, err := store.PendingFlow(ctx, h.key)
+	if err != nil {
+		return err
+	}
+	if pending != nil {
+		return &AuthorizationRequiredError{Server: h.server, URL: pending.AuthURL}
+	}
+
+	clientID, clientSecret := h.cfg.ClientID, h.cfg.ClientSecret
+	if clientID == "" {
+		if asm.RegistrationEndpoint == "" {
+			return fmt.Errorf("MCP server %q: no oauth.client_id configured and the authorization server does not support dynamic client registration", h.server)
+		}
+		reg, err := oauthex.RegisterClient(ctx, asm.RegistrationEndpoint, &oauthex.ClientRegistrationMetadata{
+			RedirectURIs:            []string{h.cfg.RedirectURL},
+			TokenEndpointAuthMethod: "none",
+			GrantTypes:              []string{"authorization_code", "refresh_token"},
+			ResponseTypes:           []string{"code"},
+			ClientName:              "LocalAI",
+		}, h.httpClient)
+		if err != nil {
+			return fmt.Errorf("registering LocalAI with the authorization server of MCP server %q: %w", h.server, err)
+		}
+		clientID, clientSecret = reg.ClientID, reg.ClientSecret
+	}
+
+	conf := &oauth2.Config{
+		ClientID:    clientID,
+		Endpoint:    oauth2.Endpoint{AuthURL: asm.AuthorizationEndpoint},
+		RedirectURL: h.cfg.RedirectURL,
+		Scopes:      scopes,
+	}
+	state := rand.Text()
+	verifier := oauth2.GenerateVerifier()
+	f := &OAuthFlow{
+		Key:          h.key,
+		Model:        h.model,
+		Server:       h.server,
+		AuthURL:      conf.AuthCodeURL(state, oauth2.S256ChallengeOption(verifier), oauth2.SetAuthURLParam("resource", resource)),
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		TokenURL:     asm.TokenEndpoint,
+		RedirectURL:  h.cfg.RedirectURL,
+		Scopes:       scopes,
+		Verifier:     verifier,
+		Resource:     resource,
+		ExpiresAt:    time.Now().Add(oauthFlowTTL),
+	}
+	if err := store.SaveFlow(ctx, state, f); err != nil {
+		return err
+	}
+
+	xlog.Warn("MCP server requires authorization", "server", h.server, "url", f.AuthURL)
+	return &AuthorizationRequiredError{Server: h.server, URL: f.AuthURL}
+}
+
+// PendingOAuthAuthorizations returns the authorization_code flows waiting
+// for an administrator to grant LocalAI access.
+func PendingOAuthAuthorizations(ctx context.Context) ([]OAuthFlow, error) {
+	return oauthStore().PendingFlows(ctx)
+}
+
+// CompleteOAuthAuthorization exchanges the code an authorization server
+// redirected back with for a token, completing the pending flow started
+// with state by any process sharing the OAuth store.
+func CompleteOAuthAuthorization(ctx context.Context, state, code string) error {
+	store := oauthStore()
+	f, err := store.TakeFlow(ctx, state)
+	if err != nil {
+		return err
+	}
+	if f == nil {
+		return ErrOAuthFlowNotFound
+	}
+
+	conf := &oauth2.Config{
+		ClientID:     f.ClientID,
+		ClientSecret: f.ClientSecret,
+		Endpoint:     oauth2.Endpoint{TokenURL: f.TokenURL},
+		RedirectURL:  f.RedirectURL,
+		Scopes:       f.Scopes,
+	}
+	httpClient := httpclient.New(httpclient.WithTimeout(oauthHTTPTimeout))
+	tok, err := conf.Exchange(oauthContext(ctx, httpClient), code,
+		oauth2.VerifierOption(f.Verifier), oauth2.SetAuthURLParam("resource", f.Resource))
+	if err != nil {
+		return fmt.Errorf("exchanging the authorization code of MCP server %q: %w", f.Server, err)
+	}
+	if err := store.SaveCredential(ctx, f.Key, &OAuthCredential{
+		Server:       f.Server,
+		GrantType:    config.MCPOAuthAuthorizationCode,
+		Token:        tok,
+		ClientID:     f.ClientID,
+		ClientSecret: f.ClientSecret,
+		TokenURL:     f.TokenURL,
+		Scopes:       f.Scopes,
+		Resource:     f.Resource,
+	}); err != nil {
+		return err
+	}
+	xlog.Info("MCP server authorized", "server", f.Server)
+	return nil
+}
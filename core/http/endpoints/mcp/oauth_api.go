@@ -0,0 +1,118 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/mudler/LocalAI/pkg/httpclient"
+	"golang.org/x/oauth2"
+)
+
+// oauthAPI authorizes the OAuth-protected servers of an agent worker
+// through the LocalAI API. Workers keep no credentials: the frontend stores
+// them and refreshes them, and hands the worker the current access token
+// only.
+type oauthAPI struct {
+	baseURL string
+	apiKey  string
+	client  *http.Client
+}
+
+// UseOAuthAPI makes this process (an agent worker) obtain the tokens of
+// OAuth-protected MCP servers from the LocalAI API at apiURL, authenticated
+// with apiToken, instead of authorizing them itself.
+func UseOAuthAPI(apiURL, apiToken string) {
+	oauthState.mu.Lock()
+	defer oauthState.mu.Unlock()
+	oauthState.api = &oauthAPI{
+		baseURL: strings.TrimRight(apiURL, "/") + "/api/mcp/oauth",
+		apiKey:  apiToken,
+		client:  httpclient.New(httpclient.WithTimeout(oauthHTTPTimeout)),
+	}
+}
+
+// OAuthAuthorizeRequest is the body of POST /api/mcp/oauth/authorize: an
+// agent worker's server refused a request with Challenge, its
+// WWW-Authenticate values.
+type OAuthAuthorizeRequest struct {
+	Model     string   `json:"model"`
+	Server    string   `json:"server"`
+	Challenge []string `json:"challenge,omitempty"`
+}
+
+// OAuthAuthorizeError is the body of the 409 answering an
+// OAuthAuthorizeRequest when the server needs to be authorized in the
+// browser.
+type OAuthAuthorizeError struct {
+	Error            string `json:"error"`
+	AuthorizationURL string `json:"authorization_url,omitempty"`
+}
+
+func (a *oauthAPI) do(req *http.Request) (*http.Response, error) {
+	if a.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+a.apiKey)
+	}
+	return a.client.Do(req)
+}
+
+func (a *oauthAPI) token(ctx context.Context, model, server string) (*oauth2.Token, error) {
+	q := url.Values{"model": {model}, "server": {server}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, a.baseURL+"/token?"+q.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := a.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetching the OAuth token of MCP server %q: %w", server, err)
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		var tok oauth2.Token
+		if err := json.NewDecoder(resp.Body).Decode(&tok); err != nil {
+			return nil, fmt.Errorf("decoding the OAuth token of MCP server %q: %w", server, err)
+		}
+		return &tok, nil
+	case http.StatusNoContent:
+		return nil, nil
+	default:
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, fmt.Errorf("fetching the OAuth token of MCP server %q: %s: %s", server, resp.Status, bytes.TrimSpace(body))
+	}
+}
+
+func (a *oauthAPI) authorize(ctx context.Context, model, server string, challenge []string) error {
+	body, err := json.Marshal(OAuthAuthorizeRequest{Model: model, Server: server, Challenge: challenge})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.baseURL+"/authorize", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := a.do(req)
+	if err != nil {
+		return fmt.Errorf("authorizing MCP server %q: %w", server, err)
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusNoContent, http.StatusOK:
+		return nil
+	case http.StatusConflict:
+		var e OAuthAuthorizeError
+		if err := json.NewDecoder(resp.Body).Decode(&e); err != nil {
+			return fmt.Errorf("authorizing MCP server %q: %s", server, resp.Status)
+		}
+		return &AuthorizationRequiredError{Server: server, URL: e.AuthorizationURL}
+	default:
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("authorizing MCP server %q: %s: %s", server, resp.Status, bytes.TrimSpace(msg))
+	}
+}
@@ -0,0 +1,207 @@
+package mcp
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mudler/LocalAI/core/services/advisorylock"
+	"gorm.io/gorm"
+)
+
+// oauthCredentialRecord is the GORM model of an OAuth credential. The
+// credential itself (tokens and client secret) is sealed.
+type oauthCredentialRecord struct {
+	ServerKey string `gorm:"primaryKey;size:64"`
+	Server    string `gorm:"size:255"`
+	Sealed    []byte
+	UpdatedAt time.Time
+}
+
+func (oauthCredentialRecord) TableName() string { return "mcp_oauth_credentials" }
+
+// oauthFlowRecord is the GORM model of a pending authorization_code flow,
+// keyed by the hash of its state parameter. The flow (PKCE verifier and
+// client secret included) is sealed.
+type oauthFlowRecord struct {
+	StateHash string `gorm:"primaryKey;size:64"`
+	ServerKey string `gorm:"index;size:64"`
+	Server    string `gorm:"size:255"`
+	Sealed    []byte
+	ExpiresAt time.Time `gorm:"index"`
+}
+
+func (oauthFlowRecord) TableName() string { return "mcp_oauth_flows" }
+
+// gormOAuthStore is the OAuthStore of instances with a database. Secrets
+// are encrypted with AES-GCM under a key derived from the API key HMAC
+// secret, which every replica shares; refreshes are serialised with an
+// advisory lock per credential.
+type gormOAuthStore struct {
+	db   *gorm.DB
+	aead cipher.AEAD
+}
+
+// NewGormOAuthStore returns an OAuthStore persisting to db, encrypting the
+// credentials with a key derived from secret.
+func NewGormOAuthStore(db *gorm.DB, secret string) (OAuthStore, error) {
+	if secret == "" {
+		return nil, errors.New("an encryption secret is required to store MCP OAuth credentials")
+	}
+	key := sha256.Sum256([]byte("localai-mcp-oauth\x00" + secret))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if err := advisorylock.WithLockCtx(context.Background(), db, advisorylock.KeySchemaMigrate, func() error {
+		return db.AutoMigrate(&oauthCredentialRecord{}, &oauthFlowRecord{})
+	}); err != nil {
+		return nil, fmt.Errorf("migrating MCP OAuth tables: %w", err)
+	}
+	return &gormOAuthStore{db: db, aead: aead}, nil
+}
+
+// seal encrypts v as JSON, bound to the row id it is stored under.
+func (s *gormOAuthStore) seal(id string, v any) ([]byte, error) {
+	plain, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return s.aead.Seal(nonce, nonce, plain, []byte(id)), nil
+}
+
+func (s *gormOAuthStore) open(id string, sealed []byte, v any) error {
+	n := s.aead.NonceSize()
+	if len(sealed) < n {
+		return errors.New("sealed MCP OAuth record is truncated")
+	}
+	plain, err := s.aead.Open(nil, sealed[:n], sealed[n:], []byte(id))
+	if err != nil {
+		return fmt.Errorf("decrypting MCP OAuth record: %w", err)
+	}
+	return json.Unmarshal(plain, v)
+}
+
+func stateHash(state string) string {
+	h := sha256.Sum256([]byte(state))
+	return hex.EncodeToString(h[:])
+}
+
+func (s *gormOAuthStore) Credential(ctx context.Context, key string) (*OAuthCredential, error) {
+	var rec oauthCredentialRecord
+	err := s.db.WithContext(ctx).Where("server_key = ?", key).First(&rec).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var c OAuthCredential
+	if err := s.open(key, rec.Sealed, &c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+func (s *gormOAuthStore) SaveCredential(ctx context.Context, key string, c *OAuthCredential) error {
+	sealed, err := s.seal(key, c)
+	if err != nil {
+		return err
+	}
+	return s.db.WithContext(ctx).Save(&oauthCredentialRecord{ServerKey: key, Server: c.Server, Sealed: sealed}).Error
+}
+
+func (s *gormOAuthStore) DeleteCredential(ctx context.Context, key string) error {
+	return s.db.WithContext(ctx).Where("server_key = ?", key).Delete(&oauthCredentialRecord{}).Error
+}
+
+func (s *gormOAuthStore) Locked(ctx context.Context, key string, fn func() error) error {
+	return advisorylock.WithLockCtx(ctx, s.db, advisorylock.KeyFromString("mcp-oauth:"+key), fn)
+}
+
+func (s *gormOAuthStore) SaveFlow(ctx context.Context, state string, f *OAuthFlow) error {
+	db := s.db.WithContext(ctx)
+	if err := db.Where("expires_at < ?", time.Now()).Delete(&oauthFlowRecord{}).Error; err != nil {
+		return err
+	}
+	id := stateHash(state)
+	sealed, err := s.seal(id, f)
+	if err != nil {
+		return err
+	}
+	return db.Create(&oauthFlowRecord{StateHash: id, ServerKey: f.Key, Server: f.Server, Sealed: sealed, ExpiresAt: f.ExpiresAt}).Error
+}
+
+func (s *gormOAuthStore) PendingFlow(ctx context.Context, key string) (*OAuthFlow, error) {
+	var rec oauthFlowRecord
+	err := s.db.WithContext(ctx).Where("server_key = ? AND expires_at > ?", key, time.Now()).
+		Order("expires_at DESC").First(&rec).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var f OAuthFlow
+	if err := s.open(rec.StateHash, rec.Sealed, &f); err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+func (s *gormOAuthStore) TakeFlow(ctx context.Context, state string) (*OAuthFlow, error) {
+	db := s.db.WithContext(ctx)
+	var rec oauthFlowRecord
+	err := db.Where("state_hash = ?", stateHash(state)).First(&rec).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	// Only the process whose delete removes the row completes the flow.
+	res := db.Where("state_hash = ?", rec.StateHash).Delete(&oauthFlowRecord{})
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 || time.Now().After(rec.ExpiresAt) {
+		return nil, nil
+	}
+	var f OAuthFlow
+	if err := s.open(rec.StateHash, rec.Sealed, &f); err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+func (s *gormOAuthStore) PendingFlows(ctx context.Context) ([]OAuthFlow, error) {
+	var recs []oauthFlowRecord
+	if err := s.db.WithContext(ctx).Where("expires_at > ?", time.Now()).
+		Order("expires_at ASC").Find(&recs).Error; err != nil {
+		return nil, err
+	}
+	out := make([]OAuthFlow, 0, len(recs))
+	for _, rec := range recs {
+		var f OAuthFlow
+		if err := s.open(rec.StateHash, rec.Sealed, &f); err != nil {
+			return nil, err
+		}
+		out = append(out, f)
+	}
+	return out, nil
+}
@@ -0,0 +1,187 @@
+package mcp
+
+import (
+	"context"
+	"net/url"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/mudler/LocalAI/core/config"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+)
+
+// OAuthCredential is what LocalAI holds for an OAuth-protected MCP server:
+// its current token and what refreshing it needs.
+type OAuthCredential struct {
+	Server       string        `json:"server"`
+	GrantType    string        `json:"grant_type"`
+	Token        *oauth2.Token `json:"token"`
+	ClientID     string        `json:"client_id"`
+	ClientSecret string        `json:"client_secret,omitempty"`
+	TokenURL     string        `json:"token_url"`
+	Scopes       []string      `json:"scopes,omitempty"`
+	Resource     string        `json:"resource,omitempty"`
+}
+
+// refresh obtains a new token: a client_credentials grant, or a
+// refresh_token grant for tokens obtained with authorization_code.
+func (c *OAuthCredential) refresh(ctx context.Context) (*oauth2.Token, error) {
+	if c.GrantType == config.MCPOAuthClientCredentials {
+		cc := &clientcredentials.Config{
+			ClientID:       c.ClientID,
+			ClientSecret:   c.ClientSecret,
+			TokenURL:       c.TokenURL,
+			Scopes:         c.Scopes,
+			EndpointParams: url.Values{"resource": {c.Resource}},
+		}
+		return cc.Token(ctx)
+	}
+	conf := &oauth2.Config{
+		ClientID:     c.ClientID,
+		ClientSecret: c.ClientSecret,
+		Endpoint:     oauth2.Endpoint{TokenURL: c.TokenURL},
+		Scopes:       c.Scopes,
+	}
+	return conf.TokenSource(ctx, c.Token).Token()
+}
+
+// OAuthFlow is an authorization_code flow waiting for its callback.
+type OAuthFlow struct {
+	Key          string    `json:"key"`
+	Model        string    `json:"model"`
+	Server       string    `json:"server"`
+	AuthURL      string    `json:"auth_url"`
+	ClientID     string    `json:"client_id"`
+	ClientSecret string    `json:"client_secret,omitempty"`
+	TokenURL     string    `json:"token_url"`
+	RedirectURL  string    `json:"redirect_url"`
+	Scopes       []string  `json:"scopes,omitempty"`
+	Verifier     string    `json:"verifier"`
+	Resource     string    `json:"resource"`
+	ExpiresAt    time.Time `json:"expires_at"`
+}
+
+// OAuthStore persists the credentials of OAuth-protected MCP servers and
+// the authorization_code flows waiting for their callback, so that every
+// frontend replica serves the same ones. Keys are oauthHandler keys.
+type OAuthStore interface {
+	// Credential returns the credential stored under key, nil when there
+	// is none.
+	Credential(ctx context.Context, key string) (*OAuthCredential, error)
+	SaveCredential(ctx context.Context, key string, c *OAuthCredential) error
+	DeleteCredential(ctx context.Context, key string) error
+	// Locked runs fn holding the lock of key across the processes sharing
+	// the store, so that a credential is refreshed by one of them at a
+	// time.
+	Locked(ctx context.Context, key string, fn func() error) error
+
+	SaveFlow(ctx context.Context, state string, f *OAuthFlow) error
+	// PendingFlow returns the unexpired flow started for key, nil when
+	// there is none.
+	PendingFlow(ctx context.Context, key string) (*OAuthFlow, error)
+	// TakeFlow removes and returns the unexpired flow started with state,
+	// nil when there is none.
+	TakeFlow(ctx context.Context, state string) (*OAuthFlow, error)
+	// PendingFlows returns the unexpired flows, soonest to expire first.
+	PendingFlows(ctx context.Context) ([]OAuthFlow, error)
+}
+
+// memoryOAuthStore keeps the credentials and flows in this process. It is
+// the store of instances without a database.
+type memoryOAuthStore struct {
+	mu          sync.Mutex
+	refreshMu   sync.Mutex
+	credentials map[string]*OAuthCredential
+	flows       map[string]*OAuthFlow
+}
+
+func newMemoryOAuthStore() *memoryOAuthStore {
+	return &memoryOAuthStore{
+		credentials: make(map[string]*OAuthCredential),
+		flows:       make(map[string]*OAuthFlow),
+	}
+}
+
+func (s *memoryOAuthStore) Credential(_ context.Context, key string) (*OAuthCredential, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.credentials[key]
+	if !ok {
+		return nil, nil
+	}
+	cp := *c
+	return &cp, nil
+}
+
+func (s *memoryOAuthStore) SaveCredential(_ context.Context, key string, c *OAuthCredential) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cp := *c
+	s.credentials[key] = &cp
+	return nil
+}
+
+func (s *memoryOAuthStore) DeleteCredential(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.credentials, key)
+	return nil
+}
+
+func (s *memoryOAuthStore) Locked(_ context.Context, _ string, fn func() error) error {
+	s.refreshMu.Lock()
+	defer s.refreshMu.Unlock()
+	return fn()
+}
+
+func (s *memoryOAuthStore) SaveFlow(_ context.Context, state string, f *OAuthFlow) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for st, pending := range s.flows {
+		if now.After(pending.ExpiresAt) {
+			delete(s.flows, st)
+		}
+	}
+	s.flows[state] = f
+	return nil
+}
+
+func (s *memoryOAuthStore) PendingFlow(_ context.Context, key string) (*OAuthFlow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for _, f := range s.flows {
+		if f.Key == key && now.Before(f.ExpiresAt) {
+			return f, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *memoryOAuthStore) TakeFlow(_ context.Context, state string) (*OAuthFlow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, ok := s.flows[state]
+	delete(s.flows, state)
+	if !ok || time.Now().After(f.ExpiresAt) {
+		return nil, nil
+	}
+	return f, nil
+}
+
+func (s *memoryOAuthStore) PendingFlows(_ context.Context) ([]OAuthFlow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	var out []OAuthFlow
+	for _, f := range s.flows {
+		if now.Before(f.ExpiresAt) {
+			out = append(out, *f)
+		}
+	}
+	slices.SortFunc(out, func(a, b OAuthFlow) int { return a.ExpiresAt.Compare(b.ExpiresAt) })
+	return out, nil
+}
@@ -0,0 +1,219 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/sashabaranov/go-openai"
+)
+
+// SamplingRequest is a sampling/createMessage request of an MCP server,
+// converted to LocalAI messages.
+type SamplingRequest struct {
+	Messages  []schema.Message
+	MaxTokens int
+	// Temperature is the requested temperature, 0 keeps the model's own.
+	Temperature float64
+	Stop        []string
+}
+
+// Sampler serves the sampling requests of MCP servers that opted into
+// sampling, with the model the server is configured on. Both
+// implementations run them as chat completions: the frontend on its
+// in-process inference routes (NewDispatchSampler), agent workers against
+// the LocalAI API (NewAPISampler). ctx carries the user the request is
+// served for, see CallerIDFromContext.
+type Sampler interface {
+	Sample(ctx context.Context, modelName string, req SamplingRequest) (string, error)
+}
+
+var (
+	samplerMu sync.RWMutex
+	sampler   Sampler
+)
+
+// SetSampler installs the Sampler of this process. Until one is set,
+// sampling requests are refused.
+func SetSampler(s Sampler) {
+	samplerMu.Lock()
+	defer samplerMu.Unlock()
+	sampler = s
+}
+
+func currentSampler() Sampler {
+	samplerMu.RLock()
+	defer samplerMu.RUnlock()
+	return sampler
+}
+
+type callerIDKey struct{}
+
+// WithCallerID returns a context whose MCP tool calls run on behalf of the
+// user userID: the sampling requests their servers send are served as
+// that user.
+func WithCallerID(ctx context.Context, userID string) context.Context {
+	return context.WithValue(ctx, callerIDKey{}, userID)
+}
+
+// CallerIDFromContext returns the user set by WithCallerID. A Sampler
+// finds there the user a sampling request is served for.
+func CallerIDFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(callerIDKey{}).(string)
+	return id, ok
+}
+
+// samplingCaller returns the user of the tool calls running on session,
+// when they all run for the same one.
+func samplingCaller(session *mcp.ClientSession) (string, bool) {
+	inflight.mu.Lock()
+	defer inflight.mu.Unlock()
+	var userID string
+	calls := inflight.calls[session]
+	for i, c := range calls {
+		id, ok := CallerIDFromContext(c.ctx)
+		if !ok || (i > 0 && id != userID) {
+			return "", false
+		}
+		userID = id
+	}
+	return userID, len(calls) > 0
+}
+
+// createMessage answers a server's sampling/createMessage request, as the
+// user of the tool call that triggered it.
+func createMessage(ctx context.Context, modelName string, r *mcp.CreateMessageRequest) (*mcp.CreateMessageResult, error) {
+	s := currentSampler()
+	if s == nil {
+		return nil, fmt.Errorf("sampling is not available on this LocalAI instance")
+	}
+	req, err := samplingRequest(r.Params)
+	if err != nil {
+		return nil, err
+	}
+	if userID, ok := samplingCaller(r.Session); ok {
+		ctx = WithCallerID(ctx, userID)
+	}
+	// Sampling requests arrive on the session's context, which lives as
+	// long as the session: bound the inference like a tool call.
+	ctx, cancel := context.WithTimeout(ctx, config.DefaultMCPToolTimeout)
+	defer cancel()
+	text, err := s.Sample(ctx, modelName, req)
+	if err != nil {
+		return nil, fmt.Errorf("sampling with model %q failed: %w", modelName, err)
+	}
+	return &mcp.CreateMessageResult{
+		Content:    &mcp.TextContent{Text: text},
+		Model:      modelName,
+		Role:       "assistant",
+		StopReason: "endTurn",
+	}, nil
+}
+
+// samplingRequest converts createMessage params to LocalAI messages. Only
+// text content is supported; the system prompt becomes a leading system
+// message.
+func samplingRequest(params *mcp.CreateMessageParams) (SamplingRequest, error) {
+	if params == nil || len(params.Messages) == 0 {
+		return SamplingRequest{}, fmt.Errorf("sampling request has no messages")
+	}
+	req := SamplingRequest{
+		MaxTokens:   int(params.MaxTokens),
+		Temperature: params.Temperature,
+		Stop:        params.StopSequences,
+	}
+	if params.SystemPrompt != "" {
+		req.Messages = append(req.Messages, schema.Message{Role: "system", Content: params.SystemPrompt, StringContent: params.SystemPrompt})
+	}
+	for i, m := range params.Messages {
+		tc, ok := m.Content.(*mcp.TextContent)
+		if !ok {
+			return SamplingRequest{}, fmt.Errorf("sampling message %d: unsupported content type %T (only text is supported)", i, m.Content)
+		}
+		req.Messages = append(req.Messages, schema.Message{Role: string(m.Role), Content: tc.Text, StringContent: tc.Text})
+	}
+	return req, nil
+}
+
+// APISampler runs sampling requests as chat completions against a LocalAI
+// API, for processes that load no models themselves.
+type APISampler struct {
+	client *openai.Client
+}
+
+// NewAPISampler returns a sampler calling the LocalAI API at apiURL with
+// the given API token, an agent worker's. The requests go to the sampling
+// endpoint of the frontend, naming the user from CallerIDFromContext, so
+// they run as that user rather than as the worker.
+func NewAPISampler(apiURL, apiToken string) *APISampler {
+	cfg := openai.DefaultConfig(apiToken)
+	cfg.BaseURL = strings.TrimRight(apiURL, "/") + "/api/mcp/sampling"
+	cfg.HTTPClient = callerDoer{http.DefaultClient}
+	return &APISampler{client: openai.NewClientWithConfig(cfg)}
+}
+
+// callerDoer adds the user a sampling request is served for to the
+// requests it sends, as the user_id query parameter.
+type callerDoer struct {
+	client *http.Client
+}
+
+func (d callerDoer) Do(req *http.Request) (*http.Response, error) {
+	if userID, ok := CallerIDFromContext(req.Context()); ok && userID != "" {
+		q := req.URL.Query()
+		q.Set("user_id", userID)
+		req.URL.RawQuery = q.Encode()
+	}
+	return d.client.Do(req)
+}
+
+// NewDispatchSampler returns a sampler serving the chat completions with
+// handler, an in-process dispatcher of the inference routes. The requests
+// carry the sampling context, so handler can authenticate them as the
+// user from CallerIDFromContext and apply that user's quota, usage
+// accounting and PII policy as for any other completion.
+func NewDispatchSampler(handler http.Handler) *APISampler {
+	cfg := openai.DefaultConfig("")
+	cfg.BaseURL = "http://localai/v1"
+	cfg.HTTPClient = handlerDoer{handler}
+	return &APISampler{client: openai.NewClientWithConfig(cfg)}
+}
+
+// handlerDoer serves requests with an http.Handler instead of the network.
+type handlerDoer struct {
+	handler http.Handler
+}
+
+func (d handlerDoer) Do(req *http.Request) (*http.Response, error) {
+	rec := httptest.NewRecorder()
+	d.handler.ServeHTTP(rec, req)
+	res := rec.Result()
+	res.Request = req
+	return res, nil
+}
+
+func (s *APISampler) Sample(ctx context.Context, modelName string, req SamplingRequest) (string, error) {
+	chatReq := openai.ChatCompletionRequest{
+		Model:       modelName,
+		MaxTokens:   req.MaxTokens,
+		Temperature: float32(req.Temperature),
+		Stop:        req.Stop,
+	}
+	for _, m := range req.Messages {
+		chatReq.Messages = append(chatReq.Messages, openai.ChatCompletionMessage{Role: m.Role, Content: m.StringContent})
+	}
+	resp, err := s.client.CreateChatCompletion(ctx, chatReq)
+	if err != nil {
+		return "", err
+	}
+	if len(resp.Choices) == 0 {
+		return "", fmt.Errorf("model %q returned no choices", modelName)
+	}
+	return strings.TrimSpace(resp.Choices[0].Message.Content), nil
+}
@@ -97,9 +97,50 @@ var (
 		configHashes: make(map[string][sha256.Size]byte),
 	}
 
-	client = mcp.NewClient(&mcp.Implementation{Name: "LocalAI", Version: "v1.0.0"}, nil)
+	client = mcp.NewClient(clientImplementation, nil)
 )
 
+var clientImplementation = &mcp.Implementation{Name: "LocalAI", Version: "v1.0.0"}
+
+// clientFor returns the MCP client a server's session is created with.
+// Sampling and elicitation are advertised per client by the SDK, so servers
+// that opt into either get a client of their own; everything else shares
+// the default one.
+func clientFor(modelName string, sampling, elicitation bool) *mcp.Client {
+	if !sampling && !elicitation {
+		return client
+	}
+	opts := &mcp.ClientOptions{}
+	if sampling {
+		opts.CreateMessageHandler = func(ctx context.Context, req *mcp.CreateMessageRequest) (*mcp.CreateMessageResult, error) {
+			return createMessage(ctx, modelName, req)
+		}
+	}
+	if elicitation {
+		opts.ElicitationHandler = elicit
+	}
+	return mcp.NewClient(clientImplementation, opts)
+}
+
+// remoteTransport builds the transport of a remote server. Servers with an
+// oauth block are authorized through their OAuth handler, which replaces
+// the static bearer token.
+func remoteTransport(modelName, serverName string, server config.MCPRemoteServer) *mcp.StreamableClientTransport {
+	token := server.Token
+	if server.OAuth != nil {
+		token = ""
+	}
+	httpClient := httpclient.New(
+		httpclient.WithTimeout(config.DefaultMCPToolTimeout),
+		httpclient.WithTransport(newBearerTokenRoundTripper(token, httpclient.HardenedTransport())),
+	)
+	transport := &mcp.StreamableClientTransport{Endpoint: server.URL, HTTPClient: httpClient}
+	if server.OAuth != nil {
+		transport.OAuthHandler = oauthHandlerFor(modelName, serverName, server)
+	}
+	return transport
+}
+
 // MCPNATSClient is the interface for NATS request-reply operations needed by MCP routing.
 type MCPNATSClient interface {
 	Request(subject string, data []byte, timeout time.Duration) ([]byte, error)
@@ -161,7 +202,7 @@ func MCPServersFromMetadata(metadata map[string]string) []string {
 // shared with sibling servers that may already have connected. A genuinely
 // stalled goroutine holds only that shared ctx and is reaped when the model's
 // sessions are cancelled on eviction/shutdown.
-func connectMCP(ctx context.Context, transport mcp.Transport, timeout time.Duration) (*mcp.ClientSession, error) {
+func connectMCP(ctx context.Context, c *mcp.Client, transport mcp.Transport, timeout time.Duration) (*mcp.ClientSession, error) {
 	type result struct {
 		session *mcp.ClientSession
 		err     error
@@ -170,7 +211,7 @@ func connectMCP(ctx context.Context, transport mcp.Transport, timeout time.Durat
 	// waiting on the timeout branch.
 	done := make(chan result, 1)
 	go func() {
-		s, err := client.Connect(ctx, transport, nil)
+		s, err := c.Connect(ctx, transport, nil)
 		done <- result{session: s, err: err}
 	}()
 	select {
@@ -222,16 +263,10 @@ func SessionsFromMCPConfig(
 	ctx, cancel := context.WithCancel(context.Background())
 
 	// Get the list of all the tools that the Agent will be esposed to
-	for _, server := range remote.Servers {
+	for serverName, server := range remote.Servers {
 		xlog.Debug("[MCP remote server] Configuration", "server", server)
-		// Create HTTP client with custom roundtripper for bearer token injection
-		httpClient := httpclient.New(
-			httpclient.WithTimeout(config.DefaultMCPToolTimeout),
-			httpclient.WithTransport(newBearerTokenRoundTripper(server.Token, httpclient.HardenedTransport())),
-		)
-
-		transport := &mcp.StreamableClientTransport{Endpoint: server.URL, HTTPClient: httpClient}
-		mcpSession, err := connectMCP(ctx, transport, config.DefaultMCPDiscoveryTimeout)
+		transport := remoteTransport(name, serverName, server)
+		mcpSession, err := connectMCP(ctx, clientFor(name, server.Sampling, server.Elicitation), transport, config.DefaultMCPDiscoveryTimeout)
 		if err != nil {
 			xlog.Error("Failed to connect to MCP server", "error", err, "url", server.URL)
 			continue
@@ -249,7 +284,7 @@ func SessionsFromMCPConfig(
 			command.Env = append(command.Env, key+"="+value)
 		}
 		transport := &mcp.CommandTransport{Command: command}
-		mcpSession, err := connectMCP(ctx, transport, config.DefaultMCPDiscoveryTimeout)
+		mcpSession, err := connectMCP(ctx, clientFor(name, server.Sampling, server.Elicitation), transport, config.DefaultMCPDiscoveryTimeout)
 		if err != nil {
 			xlog.Error("Failed to start MCP server", "error", err, "command", command)
 			continue
@@ -335,13 +370,8 @@ func NamedSessionsFromMCPConfig(
 
 		for serverName, server := range remote.Servers {
 			xlog.Debug("[MCP remote server] Configuration", "name", serverName, "server", server)
-			httpClient := httpclient.New(
-				httpclient.WithTimeout(config.DefaultMCPToolTimeout),
-				httpclient.WithTransport(newBearerTokenRoundTripper(server.Token, httpclient.HardenedTransport())),
-			)
-
-			transport := &mcp.StreamableClientTransport{Endpoint: server.URL, HTTPClient: httpClient}
-			mcpSession, err := connectMCP(ctx, transport, config.DefaultMCPDiscoveryTimeout)
+			transport := remoteTransport(name, serverName, server)
+			mcpSession, err := connectMCP(ctx, clientFor(name, server.Sampling, server.Elicitation), transport, config.DefaultMCPDiscoveryTimeout)
 			if err != nil {
 				xlog.Error("Failed to connect to MCP server", "error", err, "name", serverName, "url", server.URL)
 				allSessions = append(allSessions, NamedSession{
@@ -367,7 +397,7 @@ func NamedSessionsFromMCPConfig(
 				command.Env = append(command.Env, key+"="+value)
 			}
 			transport := &mcp.CommandTransport{Command: command}
-			mcpSession, err := connectMCP(ctx, transport, config.DefaultMCPDiscoveryTimeout)
+			mcpSession, err := connectMCP(ctx, clientFor(name, server.Sampling, server.Elicitation), transport, config.DefaultMCPDiscoveryTimeout)
 			if err != nil {
 				xlog.Error("Failed to start MCP server", "error", err, "name", serverName, "command", command)
 				allSessions = append(allSessions, NamedSession{
@@ -483,6 +513,8 @@ func ExecuteMCPToolCall(ctx context.Context, tools []MCPToolInfo, toolName strin
 		}
 	}
 
+	defer bindCall(ctx, toolInfo.Session, toolInfo.ServerName, elicitationHandlerFrom(ctx))()
+
 	result, err := toolInfo.Session.CallTool(ctx, &mcp.CallToolParams{
 		Name:      toolName,
 		Arguments: args,
@@ -538,6 +570,20 @@ func ExecuteMCPToolCallRemote(
 		RemoteServers: remote,
 		StdioServers:  stdio,
 	}
+	if userID, ok := CallerIDFromContext(ctx); ok {
+		req.CallerID = userID
+	}
+	if h := elicitationHandlerFrom(ctx); h != nil {
+		if rs, ok := natsClient.(replySubscriber); ok {
+			subject, stop, err := serveRemoteElicitation(ctx, rs, h)
+			if err != nil {
+				xlog.Warn("Failed to serve MCP elicitation for remote tool call", "tool", toolName, "error", err)
+			} else {
+				defer stop()
+				req.ElicitationSubject = subject
+			}
+		}
+	}
 	reqData, _ := json.Marshal(req)
 
 	replyData, err := natsClient.Request(messaging.SubjectMCPToolExecute, reqData, config.DefaultMCPToolTimeout)
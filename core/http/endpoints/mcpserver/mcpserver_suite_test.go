@@ -0,0 +1,13 @@
+package mcpserver
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestMCPServer(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "core/http/endpoints/mcpserver test suite")
+}
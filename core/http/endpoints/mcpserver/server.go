@@ -0,0 +1,185 @@
+// Package mcpserver serves the models installed on LocalAI as the tools of
+// an MCP server over streamable HTTP, so MCP-capable IDEs and agents can
+// use them directly.
+//
+// Every tool call is replayed against an in-process dispatcher serving the
+// regular inference routes as the calling user, so API keys, per-user
+// feature and model permissions, quotas and usage accounting apply exactly
+// as they do to the equivalent REST request.
+package mcpserver
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/http/auth"
+	"github.com/mudler/LocalAI/internal"
+	"github.com/mudler/xlog"
+	"gorm.io/gorm"
+)
+
+// ServerName is the Implementation.Name the endpoint advertises.
+const ServerName = "localai-models"
+
+// Caller is the authenticated user an MCP request is served for, with the
+// permissions deciding which tools they see.
+type Caller struct {
+	// User is nil when authentication is disabled.
+	User *auth.User
+	// APIKey and Source record how the caller authenticated, so usage of
+	// the tool calls is attributed like that of direct API calls. APIKey
+	// is nil unless Source is auth.UsageSourceAPIKey.
+	APIKey *auth.UserAPIKey
+	Source string
+
+	// features and models are nil when every feature, respectively every
+	// model, is allowed.
+	features auth.PermissionMap
+	models   map[string]bool
+}
+
+func (c *Caller) allowed(model, feature string) bool {
+	if c.features != nil && !c.features[feature] {
+		return false
+	}
+	return c.models == nil || c.models[model]
+}
+
+// resolveCaller loads the permissions of the request's user. Without an
+// auth database everything is allowed.
+func resolveCaller(c echo.Context, db *gorm.DB) *Caller {
+	caller := &Caller{User: auth.GetUser(c), APIKey: auth.GetAPIKey(c), Source: auth.GetSource(c)}
+	if db == nil {
+		return caller
+	}
+	if caller.User == nil {
+		caller.features, caller.models = auth.PermissionMap{}, map[string]bool{}
+		return caller
+	}
+	caller.features = auth.GetPermissionMapForUser(db, caller.User)
+	if caller.User.Role == auth.RoleAdmin {
+		return caller
+	}
+	perm, err := auth.GetCachedUserPermissions(c, db, caller.User.ID)
+	if err != nil {
+		xlog.Error("Failed to load permissions for the MCP server", "user", caller.User.ID, "error", err)
+		caller.features, caller.models = auth.PermissionMap{}, map[string]bool{}
+		return caller
+	}
+	if perm.AllowedModels.Enabled {
+		caller.models = map[string]bool{}
+		for _, m := range perm.AllowedModels.Models {
+			caller.models[m] = true
+		}
+	}
+	return caller
+}
+
+type callerKey struct{}
+
+// WithCaller returns a context carrying caller.
+func WithCaller(ctx context.Context, caller *Caller) context.Context {
+	return context.WithValue(ctx, callerKey{}, caller)
+}
+
+// CallerFromContext returns the caller set by WithCaller.
+func CallerFromContext(ctx context.Context) (*Caller, bool) {
+	caller, ok := ctx.Value(callerKey{}).(*Caller)
+	return caller, ok
+}
+
+// Options configure the MCP endpoint.
+type Options struct {
+	// DisableLocalhostProtection turns off the SDK's DNS rebinding
+	// protection, which rejects requests reaching a loopback address with
+	// a non-local Host header. It must be disabled behind a reverse proxy
+	// running on the same host, and is only safe when requests are
+	// authenticated.
+	DisableLocalhostProtection bool
+}
+
+// Endpoint serves the MCP server. dispatcher runs the tool calls: it must
+// serve the inference routes and authenticate requests as the Caller set
+// with WithCaller.
+//
+// The server is stateless: each request gets a server listing the tools of
+// the models its caller may use, so no session is bound to a replica or
+// outlives a permission change.
+// @Summary MCP server exposing the installed models as tools (streamable HTTP transport)
+// @Tags mcp
+// @Success 200
+// @Router /mcp [post]
+func Endpoint(configs *config.ModelConfigLoader, db *gorm.DB, dispatcher http.Handler, opts Options) echo.HandlerFunc {
+	handler := mcp.NewStreamableHTTPHandler(func(r *http.Request) *mcp.Server {
+		caller, ok := CallerFromContext(r.Context())
+		if !ok {
+			return nil
+		}
+		return NewServer(configs, dispatcher, caller)
+	}, &mcp.StreamableHTTPOptions{
+		Stateless:                  true,
+		DisableLocalhostProtection: opts.DisableLocalhostProtection,
+	})
+	return func(c echo.Context) error {
+		req := c.Request()
+		handler.ServeHTTP(c.Response(), req.WithContext(WithCaller(req.Context(), resolveCaller(c, db))))
+		return nil
+	}
+}
+
+// NewServer builds the MCP server of caller: one tool per capability of
+// every model they may use.
+func NewServer(configs *config.ModelConfigLoader, dispatcher http.Handler, caller *Caller) *mcp.Server {
+	srv := mcp.NewServer(&mcp.Implementation{
+		Name:    ServerName,
+		Version: internal.PrintableVersion(),
+	}, &mcp.ServerOptions{
+		Instructions: "Each tool runs one of the models installed on this LocalAI instance. Tool names are <capability>_<model>.",
+	})
+
+	cfgs := configs.GetAllModelsConfigs()
+	slices.SortFunc(cfgs, func(a, b config.ModelConfig) int { return strings.Compare(a.Name, b.Name) })
+	d := &dispatch{handler: dispatcher, caller: caller}
+	seen := map[string]bool{}
+	for _, cfg := range cfgs {
+		for _, c := range capabilities {
+			if !cfg.HasUsecases(c.usecase) || !caller.allowed(cfg.Name, c.feature) {
+				continue
+			}
+			name := toolName(c.name, cfg.Name)
+			if seen[name] {
+				xlog.Debug("MCP server: skipping model whose tool name collides with another model", "model", cfg.Name, "tool", name)
+				continue
+			}
+			seen[name] = true
+			c.register(srv, &mcp.Tool{Name: name, Description: c.describe(cfg)}, d, cfg.Name)
+		}
+	}
+	return srv
+}
+
+// toolName derives a tool name from a capability and a model name,
+// replacing the characters tool names cannot contain.
+func toolName(capability, model string) string {
+	var b strings.Builder
+	b.WriteString(capability)
+	b.WriteByte('_')
+	for _, r := range model {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '-', r == '.':
+			b.WriteRune(r)
+		default:
+			b.WriteByte('_')
+		}
+	}
+	name := b.String()
+	if len(name) > 128 {
+		name = name[:128]
+	}
+	return name
+}
@@ -0,0 +1,189 @@
+package mcpserver
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/labstack/echo/v4"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/http/auth"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// fakeInference stands in for the in-process dispatcher: it records the
+// dispatched requests and answers like the inference routes.
+type fakeInference struct {
+	mu       sync.Mutex
+	requests []dispatched
+}
+
+type dispatched struct {
+	path   string
+	caller *Caller
+	body   map[string]any
+}
+
+func (f *fakeInference) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	caller, _ := CallerFromContext(r.Context())
+	var body map[string]any
+	data, _ := io.ReadAll(r.Body)
+	_ = json.Unmarshal(data, &body)
+	f.mu.Lock()
+	f.requests = append(f.requests, dispatched{path: r.URL.Path, caller: caller, body: body})
+	f.mu.Unlock()
+
+	switch r.URL.Path {
+	case "/v1/chat/completions":
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"Hello from ` + body["model"].(string) + `"}}]}`))
+	case "/v1/audio/speech":
+		w.Header().Set("Content-Type", "audio/wav")
+		_, _ = w.Write([]byte("RIFF"))
+	default:
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte(`{"error":{"code":403,"message":"feature not enabled for your account: embeddings"}}`))
+	}
+}
+
+func (f *fakeInference) last() dispatched {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.requests[len(f.requests)-1]
+}
+
+func connect(ctx context.Context, srv *mcp.Server) *mcp.ClientSession {
+	serverTransport, clientTransport := mcp.NewInMemoryTransports()
+	serverSession, err := srv.Connect(ctx, serverTransport, nil)
+	Expect(err).ToNot(HaveOccurred())
+	DeferCleanup(serverSession.Close)
+	session, err := mcp.NewClient(&mcp.Implementation{Name: "test"}, nil).Connect(ctx, clientTransport, nil)
+	Expect(err).ToNot(HaveOccurred())
+	DeferCleanup(session.Close)
+	return session
+}
+
+func toolNames(ctx context.Context, session *mcp.ClientSession) []string {
+	res, err := session.ListTools(ctx, nil)
+	Expect(err).ToNot(HaveOccurred())
+	var names []string
+	for _, t := range res.Tools {
+		names = append(names, t.Name)
+	}
+	return names
+}
+
+var _ = Describe("MCP server", func() {
+	var (
+		configs   *config.ModelConfigLoader
+		inference *fakeInference
+	)
+
+	BeforeEach(func() {
+		dir := GinkgoT().TempDir()
+		for file, content := range map[string]string{
+			"chat.yaml":  "name: qwen3\nknown_usecases: [chat]\ndescription: A small assistant model.\n",
+			"embed.yaml": "name: nomic-embed\nknown_usecases: [embeddings]\n",
+			"tts.yaml":   "name: piper:en\nknown_usecases: [tts]\n",
+		} {
+			Expect(os.WriteFile(filepath.Join(dir, file), []byte(content), 0o600)).To(Succeed())
+		}
+		configs = config.NewModelConfigLoader(dir)
+		Expect(configs.LoadModelConfigsFromPath(dir)).To(Succeed())
+		inference = &fakeInference{}
+	})
+
+	It("exposes one tool per model capability", func(ctx SpecContext) {
+		session := connect(ctx, NewServer(configs, inference, &Caller{}))
+		Expect(toolNames(ctx, session)).To(ConsistOf("chat_qwen3", "embed_nomic-embed", "tts_piper_en"))
+
+		res, err := session.ListTools(ctx, nil)
+		Expect(err).ToNot(HaveOccurred())
+		for _, t := range res.Tools {
+			if t.Name == "chat_qwen3" {
+				Expect(t.Description).To(ContainSubstring("A small assistant model."))
+			}
+		}
+	})
+
+	It("lists only the models and features the caller is allowed", func(ctx SpecContext) {
+		caller := &Caller{
+			features: auth.PermissionMap{auth.FeatureChat: true, auth.FeatureAudioSpeech: false, auth.FeatureEmbeddings: true},
+			models:   map[string]bool{"qwen3": true, "piper:en": true},
+		}
+		session := connect(ctx, NewServer(configs, inference, caller))
+		Expect(toolNames(ctx, session)).To(ConsistOf("chat_qwen3"))
+	})
+
+	It("runs tool calls on the dispatcher as the caller", func(ctx SpecContext) {
+		caller := &Caller{User: &auth.User{ID: "alice"}}
+		session := connect(ctx, NewServer(configs, inference, caller))
+
+		res, err := session.CallTool(ctx, &mcp.CallToolParams{Name: "chat_qwen3", Arguments: map[string]any{"prompt": "Hi", "system": "Be brief", "max_tokens": 32}})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res.IsError).To(BeFalse())
+		Expect(res.Content[0].(*mcp.TextContent).Text).To(Equal("Hello from qwen3"))
+
+		req := inference.last()
+		Expect(req.path).To(Equal("/v1/chat/completions"))
+		Expect(req.caller).To(BeIdenticalTo(caller))
+		Expect(req.body["max_tokens"]).To(BeNumerically("==", 32))
+		Expect(req.body["messages"]).To(HaveLen(2))
+
+		res, err = session.CallTool(ctx, &mcp.CallToolParams{Name: "tts_piper_en", Arguments: map[string]any{"text": "Hi"}})
+		Expect(err).ToNot(HaveOccurred())
+		audio := res.Content[0].(*mcp.AudioContent)
+		Expect(audio.MIMEType).To(Equal("audio/wav"))
+		Expect(string(audio.Data)).To(Equal("RIFF"))
+		Expect(inference.last().body["model"]).To(Equal("piper:en"))
+	})
+
+	It("reports API errors as tool errors", func(ctx SpecContext) {
+		session := connect(ctx, NewServer(configs, inference, &Caller{}))
+		res, err := session.CallTool(ctx, &mcp.CallToolParams{Name: "embed_nomic-embed", Arguments: map[string]any{"input": []string{"a"}}})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res.IsError).To(BeTrue())
+		Expect(res.Content[0].(*mcp.TextContent).Text).To(ContainSubstring("feature not enabled for your account"))
+	})
+
+	It("serves the caller's tools over streamable HTTP", func(ctx SpecContext) {
+		e := echo.New()
+		e.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+			return func(c echo.Context) error {
+				auth.SetUser(c, &auth.User{ID: "bob", Role: auth.RoleUser})
+				auth.SetSource(c, auth.UsageSourceAPIKey, &auth.UserAPIKey{ID: "key-1", UserID: "bob"})
+				return next(c)
+			}
+		})
+		e.Match([]string{http.MethodPost, http.MethodGet, http.MethodDelete}, "/mcp", Endpoint(configs, nil, inference, Options{}))
+		srv := httptest.NewServer(e)
+		DeferCleanup(srv.Close)
+
+		session, err := mcp.NewClient(&mcp.Implementation{Name: "test"}, nil).Connect(ctx, &mcp.StreamableClientTransport{Endpoint: srv.URL + "/mcp"}, nil)
+		Expect(err).ToNot(HaveOccurred())
+		DeferCleanup(session.Close)
+
+		Expect(toolNames(ctx, session)).To(ContainElement("chat_qwen3"))
+		_, err = session.CallTool(ctx, &mcp.CallToolParams{Name: "chat_qwen3", Arguments: map[string]any{"prompt": "Hi"}})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(inference.last().caller.User.ID).To(Equal("bob"))
+		Expect(inference.last().caller.Source).To(Equal(auth.UsageSourceAPIKey))
+		Expect(inference.last().caller.APIKey.ID).To(Equal("key-1"))
+	})
+})
+
+var _ = Describe("toolName", func() {
+	It("replaces the characters tool names cannot contain", func() {
+		Expect(toolName("chat", "org/model:Q4 K")).To(Equal("chat_org_model_Q4_K"))
+		Expect(toolName("chat", string(make([]byte, 200)))).To(HaveLen(128))
+	})
+})
@@ -0,0 +1,312 @@
+package mcpserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/http/auth"
+	"github.com/mudler/LocalAI/core/schema"
+)
+
+// capability is a kind of tool: models advertising usecase get one, named
+// <name>_<model>, when the caller has feature.
+type capability struct {
+	name     string
+	usecase  config.ModelConfigUsecase
+	feature  string
+	summary  string // printf format taking the model name
+	register func(s *mcp.Server, t *mcp.Tool, d *dispatch, model string)
+}
+
+func (c capability) describe(cfg config.ModelConfig) string {
+	desc := fmt.Sprintf(c.summary, cfg.Name)
+	if cfg.Description != "" {
+		desc += " Model description: " + cfg.Description
+	}
+	return desc
+}
+
+var capabilities = []capability{
+	{"chat", config.FLAG_CHAT, auth.FeatureChat, "Send a prompt to the %s language model and get its reply.", registerChat},
+	{"embed", config.FLAG_EMBEDDINGS, auth.FeatureEmbeddings, "Compute embedding vectors of texts with the %s model.", registerEmbed},
+	{"transcribe", config.FLAG_TRANSCRIPT, auth.FeatureAudioTranscription, "Transcribe an audio file to text with the %s model.", registerTranscribe},
+	{"tts", config.FLAG_TTS, auth.FeatureAudioSpeech, "Synthesize speech from text with the %s model.", registerTTS},
+	{"image", config.FLAG_IMAGE, auth.FeatureImages, "Generate an image from a text prompt with the %s model.", registerImage},
+	{"rerank", config.FLAG_RERANK, auth.FeatureRerank, "Rank documents by relevance to a query with the %s model.", registerRerank},
+}
+
+type chatArgs struct {
+	Prompt      string   `json:"prompt"                jsonschema:"The user message."`
+	System      string   `json:"system,omitempty"      jsonschema:"Optional system prompt."`
+	MaxTokens   int      `json:"max_tokens,omitempty"  jsonschema:"Maximum number of tokens to generate. The model's default when zero."`
+	Temperature *float64 `json:"temperature,omitempty" jsonschema:"Sampling temperature. The model's default when omitted."`
+}
+
+func registerChat(s *mcp.Server, t *mcp.Tool, d *dispatch, model string) {
+	mcp.AddTool(s, t, func(ctx context.Context, _ *mcp.CallToolRequest, args chatArgs) (*mcp.CallToolResult, any, error) {
+		if args.Prompt == "" {
+			return errorResult(errors.New("prompt is required")), nil, nil
+		}
+		var messages []map[string]string
+		if args.System != "" {
+			messages = append(messages, map[string]string{"role": "system", "content": args.System})
+		}
+		messages = append(messages, map[string]string{"role": "user", "content": args.Prompt})
+		req := map[string]any{"model": model, "messages": messages}
+		if args.MaxTokens > 0 {
+			req["max_tokens"] = args.MaxTokens
+		}
+		if args.Temperature != nil {
+			req["temperature"] = *args.Temperature
+		}
+		var resp struct {
+			Choices []struct {
+				Message struct {
+					Content string `json:"content"`
+				} `json:"message"`
+			} `json:"choices"`
+		}
+		if err := d.postJSON(ctx, "/v1/chat/completions", req, &resp); err != nil {
+			return errorResult(err), nil, nil
+		}
+		if len(resp.Choices) == 0 {
+			return errorResult(errors.New("the model returned no choices")), nil, nil
+		}
+		return textResult(resp.Choices[0].Message.Content), nil, nil
+	})
+}
+
+type embedArgs struct {
+	Input []string `json:"input" jsonschema:"The texts to embed."`
+}
+
+func registerEmbed(s *mcp.Server, t *mcp.Tool, d *dispatch, model string) {
+	mcp.AddTool(s, t, func(ctx context.Context, _ *mcp.CallToolRequest, args embedArgs) (*mcp.CallToolResult, any, error) {
+		if len(args.Input) == 0 {
+			return errorResult(errors.New("input is required")), nil, nil
+		}
+		var resp struct {
+			Data []struct {
+				Index     int       `json:"index"`
+				Embedding []float32 `json:"embedding"`
+			} `json:"data"`
+		}
+		if err := d.postJSON(ctx, "/v1/embeddings", map[string]any{"model": model, "input": args.Input}, &resp); err != nil {
+			return errorResult(err), nil, nil
+		}
+		embeddings := make([][]float32, len(args.Input))
+		for _, item := range resp.Data {
+			if item.Index >= 0 && item.Index < len(embeddings) {
+				embeddings[item.Index] = item.Embedding
+			}
+		}
+		return jsonResult(embeddings), nil, nil
+	})
+}
+
+type transcribeArgs struct {
+	Audio    string `json:"audio"              jsonschema:"The audio file, base64-encoded."`
+	Filename string `json:"filename,omitempty" jsonschema:"File name of the audio, whose extension tells its format (e.g. speech.mp3). Defaults to audio.wav."`
+	Language string `json:"language,omitempty" jsonschema:"Spoken language as an ISO-639-1 code. Detected when omitted."`
+}
+
+func registerTranscribe(s *mcp.Server, t *mcp.Tool, d *dispatch, model string) {
+	mcp.AddTool(s, t, func(ctx context.Context, _ *mcp.CallToolRequest, args transcribeArgs) (*mcp.CallToolResult, any, error) {
+		audio, err := base64.StdEncoding.DecodeString(args.Audio)
+		if err != nil || len(audio) == 0 {
+			return errorResult(errors.New("audio must be a non-empty base64-encoded file")), nil, nil
+		}
+		filename := args.Filename
+		if filename == "" {
+			filename = "audio.wav"
+		}
+
+		var body bytes.Buffer
+		w := multipart.NewWriter(&body)
+		_ = w.WriteField("model", model)
+		if args.Language != "" {
+			_ = w.WriteField("language", args.Language)
+		}
+		fw, err := w.CreateFormFile("file", filename)
+		if err != nil {
+			return errorResult(err), nil, nil
+		}
+		if _, err := fw.Write(audio); err != nil {
+			return errorResult(err), nil, nil
+		}
+		if err := w.Close(); err != nil {
+			return errorResult(err), nil, nil
+		}
+
+		out, _, err := d.post(ctx, "/v1/audio/transcriptions", w.FormDataContentType(), &body)
+		if err != nil {
+			return errorResult(err), nil, nil
+		}
+		var resp schema.TranscriptionResult
+		if err := json.Unmarshal(out, &resp); err != nil {
+			return errorResult(fmt.Errorf("decoding the transcription: %w", err)), nil, nil
+		}
+		return textResult(resp.Text), nil, nil
+	})
+}
+
+type ttsArgs struct {
+	Text     string `json:"text"               jsonschema:"The text to speak."`
+	Voice    string `json:"voice,omitempty"    jsonschema:"Voice or speaker of the model. The model's default when omitted."`
+	Language string `json:"language,omitempty" jsonschema:"Language of the text, for models supporting several."`
+}
+
+func registerTTS(s *mcp.Server, t *mcp.Tool, d *dispatch, model string) {
+	mcp.AddTool(s, t, func(ctx context.Context, _ *mcp.CallToolRequest, args ttsArgs) (*mcp.CallToolResult, any, error) {
+		if args.Text == "" {
+			return errorResult(errors.New("text is required")), nil, nil
+		}
+		body, err := json.Marshal(schema.TTSRequest{
+			BasicModelRequest: schema.BasicModelRequest{Model: model},
+			Input:             args.Text,
+			Voice:             args.Voice,
+			Language:          args.Language,
+		})
+		if err != nil {
+			return errorResult(err), nil, nil
+		}
+		audio, contentType, err := d.post(ctx, "/v1/audio/speech", "application/json", bytes.NewReader(body))
+		if err != nil {
+			return errorResult(err), nil, nil
+		}
+		if contentType == "" || !strings.HasPrefix(contentType, "audio/") {
+			contentType = "audio/wav"
+		}
+		return &mcp.CallToolResult{Content: []mcp.Content{&mcp.AudioContent{Data: audio, MIMEType: contentType}}}, nil, nil
+	})
+}
+
+type imageArgs struct {
+	Prompt string `json:"prompt"         jsonschema:"Description of the image to generate."`
+	Size   string `json:"size,omitempty" jsonschema:"Image size as WIDTHxHEIGHT (e.g. 512x512). The model's default when omitted."`
+}
+
+func registerImage(s *mcp.Server, t *mcp.Tool, d *dispatch, model string) {
+	mcp.AddTool(s, t, func(ctx context.Context, _ *mcp.CallToolRequest, args imageArgs) (*mcp.CallToolResult, any, error) {
+		if args.Prompt == "" {
+			return errorResult(errors.New("prompt is required")), nil, nil
+		}
+		req := map[string]any{"model": model, "prompt": args.Prompt, "n": 1, "response_format": "b64_json"}
+		if args.Size != "" {
+			req["size"] = args.Size
+		}
+		var resp struct {
+			Data []struct {
+				B64JSON string `json:"b64_json"`
+			} `json:"data"`
+		}
+		if err := d.postJSON(ctx, "/v1/images/generations", req, &resp); err != nil {
+			return errorResult(err), nil, nil
+		}
+		if len(resp.Data) == 0 {
+			return errorResult(errors.New("the model returned no image")), nil, nil
+		}
+		img, err := base64.StdEncoding.DecodeString(resp.Data[0].B64JSON)
+		if err != nil {
+			return errorResult(fmt.Errorf("decoding the image: %w", err)), nil, nil
+		}
+		return &mcp.CallToolResult{Content: []mcp.Content{&mcp.ImageContent{Data: img, MIMEType: "image/png"}}}, nil, nil
+	})
+}
+
+type rerankArgs struct {
+	Query     string   `json:"query"           jsonschema:"The query to rank the documents against."`
+	Documents []string `json:"documents"       jsonschema:"The documents to rank."`
+	TopN      int      `json:"top_n,omitempty" jsonschema:"Number of top results to return. All documents when zero."`
+}
+
+func registerRerank(s *mcp.Server, t *mcp.Tool, d *dispatch, model string) {
+	mcp.AddTool(s, t, func(ctx context.Context, _ *mcp.CallToolRequest, args rerankArgs) (*mcp.CallToolResult, any, error) {
+		if args.Query == "" || len(args.Documents) == 0 {
+			return errorResult(errors.New("query and documents are required")), nil, nil
+		}
+		req := schema.JINARerankRequest{
+			BasicModelRequest: schema.BasicModelRequest{Model: model},
+			Query:             args.Query,
+			Documents:         args.Documents,
+		}
+		if args.TopN > 0 {
+			req.TopN = &args.TopN
+		}
+		var resp schema.JINARerankResponse
+		if err := d.postJSON(ctx, "/v1/rerank", req, &resp); err != nil {
+			return errorResult(err), nil, nil
+		}
+		return jsonResult(resp.Results), nil, nil
+	})
+}
+
+// dispatch replays tool calls on the inference routes as the caller.
+type dispatch struct {
+	handler http.Handler
+	caller  *Caller
+}
+
+// post sends body to path and returns the response body and content type,
+// or an error carrying the API's error message.
+func (d *dispatch) post(ctx context.Context, path, contentType string, body io.Reader) ([]byte, string, error) {
+	req, err := http.NewRequestWithContext(WithCaller(ctx, d.caller), http.MethodPost, path, body)
+	if err != nil {
+		return nil, "", err
+	}
+	req.Header.Set("Content-Type", contentType)
+	rec := httptest.NewRecorder()
+	d.handler.ServeHTTP(rec, req)
+	if rec.Code < 200 || rec.Code >= 300 {
+		var apiErr schema.ErrorResponse
+		if json.Unmarshal(rec.Body.Bytes(), &apiErr) == nil && apiErr.Error != nil && apiErr.Error.Message != "" {
+			return nil, "", errors.New(apiErr.Error.Message)
+		}
+		return nil, "", fmt.Errorf("%s failed with status %d: %s", path, rec.Code, strings.TrimSpace(rec.Body.String()))
+	}
+	return rec.Body.Bytes(), rec.Header().Get("Content-Type"), nil
+}
+
+func (d *dispatch) postJSON(ctx context.Context, path string, in, out any) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	data, _, err := d.post(ctx, path, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("decoding the %s response: %w", path, err)
+	}
+	return nil
+}
+
+func errorResult(err error) *mcp.CallToolResult {
+	r := &mcp.CallToolResult{}
+	r.SetError(err)
+	return r
+}
+
+func textResult(text string) *mcp.CallToolResult {
+	return &mcp.CallToolResult{Content: []mcp.Content{&mcp.TextContent{Text: text}}}
+}
+
+func jsonResult(v any) *mcp.CallToolResult {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return errorResult(fmt.Errorf("marshal tool result: %w", err))
+	}
+	return textResult(string(data))
+}
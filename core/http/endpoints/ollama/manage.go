@@ -0,0 +1,466 @@
+package ollama
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/gallery"
+	"github.com/mudler/LocalAI/core/gallery/importers"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/core/services/galleryop"
+	"github.com/mudler/LocalAI/core/services/modeladmin"
+	"github.com/mudler/LocalAI/pkg/utils"
+	"github.com/mudler/xlog"
+
+	"gopkg.in/yaml.v3"
+)
+
+// opPollInterval matches the coalescing window of the gallery worker's
+// progress updates; polling faster only re-reads the same status.
+var opPollInterval = 250 * time.Millisecond
+
+// configNameFromOllama maps an Ollama model reference onto the LocalAI
+// config name it is installed under: the default registry, the library
+// namespace and the :latest tag are implied, and a namespace separator
+// cannot appear in a config file name.
+func configNameFromOllama(name string) string {
+	name = strings.TrimPrefix(name, "registry.ollama.ai/")
+	name = strings.TrimPrefix(name, "library/")
+	name = strings.TrimSuffix(name, ":latest")
+	return strings.ReplaceAll(name, "/", "__")
+}
+
+// lookupModelConfig resolves an Ollama model reference to a loaded config,
+// trying the name as given and then its normalized form.
+func lookupModelConfig(bcl *config.ModelConfigLoader, name string) (config.ModelConfig, bool) {
+	for _, candidate := range []string{name, configNameFromOllama(name)} {
+		if cfg, exists := bcl.GetModelConfig(candidate); exists {
+			return cfg, true
+		}
+	}
+	return config.ModelConfig{}, false
+}
+
+// fakeDigest gives Ollama clients a stable per-file key to group progress
+// lines by; the layers LocalAI downloads are not content-addressed.
+func fakeDigest(s string) string {
+	return fmt.Sprintf("sha256:%x", sha256.Sum256([]byte(s)))
+}
+
+// waitForOp polls a gallery operation until the worker marks it processed,
+// handing every intermediate status to progress (which may be nil).
+func waitForOp(ctx context.Context, gs *galleryop.GalleryService, id string, progress func(*galleryop.OpStatus)) (*galleryop.OpStatus, error) {
+	ticker := time.NewTicker(opPollInterval)
+	defer ticker.Stop()
+	for {
+		status := gs.GetStatus(id)
+		if status != nil && status.Processed {
+			return status, nil
+		}
+		if status != nil && progress != nil {
+			progress(status)
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// opError returns the failure carried by a processed operation status.
+func opError(status *galleryop.OpStatus) error {
+	switch {
+	case status.Error != nil:
+		return status.Error
+	case status.Cancelled:
+		return errors.New("operation cancelled")
+	}
+	return nil
+}
+
+// resolvePullOp builds the install operation for an Ollama model
+// reference: a gallery entry of the same name wins, anything else is
+// imported from the Ollama registry through the ollama:// downloader.
+func resolvePullOp(name string, appConfig *config.ApplicationConfig) (galleryop.ManagementOp[gallery.GalleryModel, gallery.ModelConfig], error) {
+	configName := configNameFromOllama(name)
+	op := galleryop.ManagementOp[gallery.GalleryModel, gallery.ModelConfig]{
+		Galleries:        appConfig.Galleries,
+		BackendGalleries: appConfig.BackendGalleries,
+	}
+
+	if len(appConfig.Galleries) > 0 {
+		models, err := gallery.AvailableGalleryModels(appConfig.Galleries, appConfig.SystemState)
+		if err != nil {
+			xlog.Warn("Ollama pull: could not list gallery models, falling back to the Ollama registry", "error", err)
+		} else if m := gallery.FindGalleryElement(models, configName); m != nil {
+			op.GalleryElementName = fmt.Sprintf("%s@%s", m.Gallery.Name, m.Name)
+			return op, nil
+		}
+	}
+
+	preferences, err := json.Marshal(map[string]string{"name": configName})
+	if err != nil {
+		return op, err
+	}
+	modelConfig, err := importers.DiscoverModelConfig("ollama://"+strings.TrimPrefix(name, "registry.ollama.ai/"), preferences)
+	if err != nil {
+		return op, fmt.Errorf("failed to resolve %q: %w", name, err)
+	}
+	op.GalleryElementName = modelConfig.Name
+	op.GalleryElement = &modelConfig
+	op.Req = gallery.GalleryModel{Overrides: map[string]any{}}
+	return op, nil
+}
+
+// PullModelEndpoint handles Ollama-compatible POST /api/pull
+func PullModelEndpoint(cl *config.ModelConfigLoader, gs *galleryop.GalleryService, appConfig *config.ApplicationConfig) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var req schema.OllamaPullRequest
+		if err := c.Bind(&req); err != nil {
+			return ollamaError(c, 400, "invalid request body")
+		}
+		name := req.Model
+		if name == "" {
+			name = req.Name
+		}
+		if name == "" {
+			return ollamaError(c, 400, "model is required")
+		}
+
+		// An installed model is already "up to date": gallery installs are
+		// not versioned, so there is nothing to compare against.
+		var op galleryop.ManagementOp[gallery.GalleryModel, gallery.ModelConfig]
+		_, installed := lookupModelConfig(cl, name)
+		if !installed {
+			var err error
+			op, err = resolvePullOp(name, appConfig)
+			if err != nil {
+				return ollamaError(c, 500, err.Error())
+			}
+		}
+
+		var pauseFunc context.CancelFunc
+		if !installed {
+			id, err := uuid.NewUUID()
+			if err != nil {
+				return err
+			}
+			var cancelFunc context.CancelFunc
+			op.ID = id.String()
+			op.Context, cancelFunc, pauseFunc = galleryop.NewUserCancellableContext(context.Background())
+			op.CancelFunc = cancelFunc
+			op.PauseFunc = pauseFunc
+			gs.StoreCancellationActions(op.ID, cancelFunc, pauseFunc)
+			gs.EnqueueModelOp(op)
+		}
+
+		wait := func(progress func(*galleryop.OpStatus)) error {
+			if installed {
+				return nil
+			}
+			status, err := waitForOp(c.Request().Context(), gs, op.ID, progress)
+			if err != nil {
+				// The client went away: stop the download but keep the
+				// partial file so pulling again resumes it.
+				pauseFunc()
+				return err
+			}
+			return opError(status)
+		}
+
+		if !req.IsStream() {
+			if err := wait(nil); err != nil {
+				return ollamaError(c, 500, err.Error())
+			}
+			return c.JSON(200, schema.OllamaProgressResponse{Status: "success"})
+		}
+
+		c.Response().Header().Set("Content-Type", "application/x-ndjson")
+		c.Response().Header().Set("Cache-Control", "no-cache")
+		c.Response().WriteHeader(200)
+		writeNDJSON(c, schema.OllamaProgressResponse{Status: "pulling manifest"})
+
+		var last schema.OllamaProgressResponse
+		err := wait(func(status *galleryop.OpStatus) {
+			if status.FileName == "" || status.TotalBytes <= 0 {
+				return
+			}
+			digest := fakeDigest(status.FileName)
+			line := schema.OllamaProgressResponse{
+				Status:    "pulling " + strings.TrimPrefix(digest, "sha256:")[:12],
+				Digest:    digest,
+				Total:     status.TotalBytes,
+				Completed: status.CurrentBytes,
+			}
+			if line != last {
+				last = line
+				writeNDJSON(c, line)
+			}
+		})
+		if err != nil {
+			if c.Request().Context().Err() == nil {
+				writeNDJSON(c, map[string]string{"error": err.Error()})
+			}
+			return nil
+		}
+		for _, status := range []string{"verifying sha256 digest", "writing manifest", "success"} {
+			writeNDJSON(c, schema.OllamaProgressResponse{Status: status})
+		}
+		return nil
+	}
+}
+
+// PushModelEndpoint handles Ollama-compatible POST /api/push. LocalAI has
+// no registry to push to, so the request is refused with a clear error
+// rather than a 404 that clients report as a missing model.
+func PushModelEndpoint() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var req schema.OllamaPushRequest
+		if err := c.Bind(&req); err != nil {
+			return ollamaError(c, 400, "invalid request body")
+		}
+		return ollamaError(c, 501, "pushing models to a registry is not supported by LocalAI")
+	}
+}
+
+// DeleteModelEndpoint handles Ollama-compatible DELETE /api/delete
+func DeleteModelEndpoint(cl *config.ModelConfigLoader, gs *galleryop.GalleryService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var req schema.OllamaDeleteRequest
+		if err := c.Bind(&req); err != nil {
+			return ollamaError(c, 400, "invalid request body")
+		}
+		name := req.Model
+		if name == "" {
+			name = req.Name
+		}
+		if name == "" {
+			return ollamaError(c, 400, "model is required")
+		}
+
+		cfg, exists := lookupModelConfig(cl, name)
+		if !exists {
+			return ollamaError(c, 404, fmt.Sprintf("model '%s' not found", name))
+		}
+
+		id, err := uuid.NewUUID()
+		if err != nil {
+			return err
+		}
+		gs.EnqueueModelOp(galleryop.ManagementOp[gallery.GalleryModel, gallery.ModelConfig]{
+			ID:                 id.String(),
+			Delete:             true,
+			GalleryElementName: cfg.Name,
+		})
+
+		// Deletion cannot be interrupted once started, so a client that
+		// hangs up early only stops waiting for the result.
+		status, err := waitForOp(c.Request().Context(), gs, id.String(), nil)
+		if err != nil {
+			return err
+		}
+		if err := opError(status); err != nil {
+			return ollamaError(c, 500, err.Error())
+		}
+		return c.NoContent(200)
+	}
+}
+
+// CopyModelEndpoint handles Ollama-compatible POST /api/copy. The copy
+// is an alias config pointing at the source, so it shares the source's
+// weights and follows later edits to it.
+func CopyModelEndpoint(cl *config.ModelConfigLoader, gs *galleryop.GalleryService, appConfig *config.ApplicationConfig) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var req schema.OllamaCopyRequest
+		if err := c.Bind(&req); err != nil {
+			return ollamaError(c, 400, "invalid request body")
+		}
+		if req.Source == "" || req.Destination == "" {
+			return ollamaError(c, 400, "source and destination are required")
+		}
+
+		src, exists := lookupModelConfig(cl, req.Source)
+		if !exists {
+			return ollamaError(c, 404, fmt.Sprintf("model '%s' not found", req.Source))
+		}
+		// Aliases cannot chain, so copying an alias points at its target.
+		target := src.Name
+		if src.IsAlias() {
+			target = src.Alias
+		}
+
+		dst := configNameFromOllama(req.Destination)
+		if _, exists := cl.GetModelConfig(dst); exists {
+			return ollamaError(c, 409, fmt.Sprintf("model '%s' already exists", req.Destination))
+		}
+
+		if err := saveModelConfig(cl, gs, appConfig, &config.ModelConfig{Name: dst, Alias: target}, false); err != nil {
+			return ollamaError(c, 400, err.Error())
+		}
+		return c.NoContent(200)
+	}
+}
+
+// CreateModelEndpoint handles Ollama-compatible POST /api/create
+func CreateModelEndpoint(cl *config.ModelConfigLoader, gs *galleryop.GalleryService, appConfig *config.ApplicationConfig) echo.HandlerFunc {
+	svc := modeladmin.NewConfigService(cl, appConfig)
+	return func(c echo.Context) error {
+		var req schema.OllamaCreateRequest
+		if err := c.Bind(&req); err != nil {
+			return ollamaError(c, 400, "invalid request body")
+		}
+		name := req.Model
+		if name == "" {
+			name = req.Name
+		}
+		if name == "" {
+			return ollamaError(c, 400, "model is required")
+		}
+		// Files and adapters reference blobs uploaded through /api/blobs,
+		// which LocalAI does not store.
+		switch {
+		case len(req.Files) > 0 || len(req.Adapters) > 0:
+			return ollamaError(c, 400, "creating models from uploaded blobs is not supported; place the files in the models directory and reference them with FROM")
+		case req.Quantize != "":
+			return ollamaError(c, 400, "quantization is not supported")
+		}
+
+		mf, err := modelfileFromRequest(&req)
+		if err != nil {
+			return ollamaError(c, 400, err.Error())
+		}
+		if mf.From == "" {
+			return ollamaError(c, 400, "neither 'from' or 'files' was specified")
+		}
+
+		cfg, err := baseConfigFrom(c.Request().Context(), svc, cl, appConfig, mf.From)
+		if err != nil {
+			return ollamaError(c, 400, err.Error())
+		}
+		cfg.Name = configNameFromOllama(name)
+		if err := mf.Apply(cfg); err != nil {
+			return ollamaError(c, 400, err.Error())
+		}
+
+		stream := req.IsStream()
+		if stream {
+			c.Response().Header().Set("Content-Type", "application/x-ndjson")
+			c.Response().WriteHeader(200)
+			writeNDJSON(c, schema.OllamaProgressResponse{Status: "parsing modelfile"})
+		}
+		if err := saveModelConfig(cl, gs, appConfig, cfg, true); err != nil {
+			if stream {
+				writeNDJSON(c, map[string]string{"error": err.Error()})
+				return nil
+			}
+			return ollamaError(c, 400, err.Error())
+		}
+		if !stream {
+			return c.JSON(200, schema.OllamaProgressResponse{Status: "success"})
+		}
+		writeNDJSON(c, schema.OllamaProgressResponse{Status: "writing manifest"})
+		writeNDJSON(c, schema.OllamaProgressResponse{Status: "success"})
+		return nil
+	}
+}
+
+// baseConfigFrom resolves a Modelfile FROM to the config the new model
+// starts from: an installed model (followed through aliases) or a weights
+// file inside the models directory.
+func baseConfigFrom(ctx context.Context, svc *modeladmin.ConfigService, cl *config.ModelConfigLoader, appConfig *config.ApplicationConfig, from string) (*config.ModelConfig, error) {
+	if base, exists := lookupModelConfig(cl, from); exists {
+		if base.IsAlias() {
+			target, exists := cl.GetModelConfig(base.Alias)
+			if !exists {
+				return nil, fmt.Errorf("alias target %q does not exist", base.Alias)
+			}
+			base = target
+		}
+		// Start from what the user wrote on disk: the loaded copy has
+		// runtime defaults applied that must not be persisted. Files that
+		// hold several configs fall back to the loaded copy.
+		cfg := &config.ModelConfig{}
+		view, err := svc.GetConfig(ctx, base.Name)
+		if err != nil || yaml.Unmarshal([]byte(view.YAML), cfg) != nil || cfg.Name != base.Name {
+			*cfg = base
+		}
+		return cfg, nil
+	}
+
+	modelsPath := appConfig.SystemState.Model.ModelsPath
+	rel := from
+	if filepath.IsAbs(from) {
+		var err error
+		if rel, err = filepath.Rel(modelsPath, from); err != nil {
+			return nil, fmt.Errorf("model '%s' not found", from)
+		}
+	}
+	if err := utils.VerifyPath(rel, modelsPath); err != nil {
+		return nil, fmt.Errorf("FROM %q is outside the models directory", from)
+	}
+	if _, err := os.Stat(filepath.Join(modelsPath, rel)); err != nil {
+		return nil, fmt.Errorf("model '%s' not found; pull it first", from)
+	}
+
+	cfg := &config.ModelConfig{}
+	cfg.Model = rel
+	if strings.EqualFold(filepath.Ext(rel), ".gguf") {
+		cfg.Backend = "llama-cpp"
+		cfg.KnownUsecaseStrings = []string{config.UsecaseChat}
+		cfg.Options = []string{"use_jinja:true"}
+		cfg.TemplateConfig.UseTokenizerTemplate = true
+	}
+	return cfg, nil
+}
+
+// saveModelConfig validates cfg, writes it to <models>/<name>.yaml and
+// reloads the loader, mirroring ImportModelEndpoint. overwrite allows
+// replacing a config that was written to that same file.
+func saveModelConfig(cl *config.ModelConfigLoader, gs *galleryop.GalleryService, appConfig *config.ApplicationConfig, cfg *config.ModelConfig, overwrite bool) error {
+	modelsPath := appConfig.SystemState.Model.ModelsPath
+	if valid, err := cfg.Validate(); !valid {
+		if err != nil {
+			return err
+		}
+		return errors.New("invalid configuration")
+	}
+	if err := cl.ValidateAliasTarget(cfg); err != nil {
+		return err
+	}
+	if err := utils.VerifyPath(cfg.Name+".yaml", modelsPath); err != nil {
+		return fmt.Errorf("model path not trusted: %w", err)
+	}
+	configPath := filepath.Join(modelsPath, cfg.Name+".yaml")
+	if existing, exists := cl.GetModelConfig(cfg.Name); exists && (!overwrite || existing.GetModelConfigFile() != configPath) {
+		return fmt.Errorf("model '%s' already exists", cfg.Name)
+	}
+
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal configuration: %w", err)
+	}
+	if err := os.WriteFile(configPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write configuration file: %w", err)
+	}
+	if err := cl.LoadModelConfigsFromPath(modelsPath, appConfig.ToConfigLoaderOptions()...); err != nil {
+		return fmt.Errorf("failed to reload configurations: %w", err)
+	}
+	if err := cl.Preload(modelsPath); err != nil {
+		return fmt.Errorf("failed to preload model: %w", err)
+	}
+	if gs != nil {
+		gs.BroadcastModelsChanged(cfg.Name, "install")
+	}
+	return nil
+}
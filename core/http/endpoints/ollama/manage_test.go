@@ -0,0 +1,225 @@
+package ollama_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/gallery"
+	"github.com/mudler/LocalAI/core/http/endpoints/ollama"
+	"github.com/mudler/LocalAI/core/services/galleryop"
+	"github.com/mudler/LocalAI/pkg/system"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Ollama model management endpoints", func() {
+	var (
+		e         *echo.Echo
+		tmpDir    string
+		cl        *config.ModelConfigLoader
+		gs        *galleryop.GalleryService
+		appConfig *config.ApplicationConfig
+	)
+
+	BeforeEach(func() {
+		e = echo.New()
+		var err error
+		tmpDir, err = os.MkdirTemp("", "ollama-manage-test-*")
+		Expect(err).ToNot(HaveOccurred())
+		systemState, err := system.GetSystemState(system.WithModelPath(tmpDir))
+		Expect(err).ToNot(HaveOccurred())
+		appConfig = &config.ApplicationConfig{SystemState: systemState}
+		cl = config.NewModelConfigLoader(tmpDir)
+		gs = galleryop.NewGalleryService(appConfig, nil)
+	})
+
+	AfterEach(func() {
+		_ = os.RemoveAll(tmpDir)
+	})
+
+	writeConfig := func(name, yaml string) {
+		path := filepath.Join(tmpDir, name+".yaml")
+		Expect(os.WriteFile(path, []byte(yaml), 0o644)).To(Succeed())
+		Expect(cl.LoadModelConfigsFromPath(tmpDir)).To(Succeed())
+	}
+
+	call := func(method, path, body string, handler echo.HandlerFunc) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		Expect(handler(e.NewContext(req, rec))).To(Succeed())
+		return rec
+	}
+
+	ndjsonStatuses := func(body string) []string {
+		var statuses []string
+		scanner := bufio.NewScanner(strings.NewReader(body))
+		for scanner.Scan() {
+			var line map[string]any
+			Expect(json.Unmarshal(scanner.Bytes(), &line)).To(Succeed())
+			if s, ok := line["status"].(string); ok {
+				statuses = append(statuses, s)
+			} else {
+				statuses = append(statuses, "error: "+line["error"].(string))
+			}
+		}
+		return statuses
+	}
+
+	// fakeWorker stands in for the gallery worker: it takes one operation,
+	// reports a download tick, holds it for a few status polls and
+	// completes it.
+	fakeWorker := func() <-chan galleryop.ManagementOp[gallery.GalleryModel, gallery.ModelConfig] {
+		received := make(chan galleryop.ManagementOp[gallery.GalleryModel, gallery.ModelConfig], 1)
+		go func() {
+			defer GinkgoRecover()
+			op := <-gs.ModelGalleryChannel
+			received <- op
+			gs.UpdateStatus(op.ID, &galleryop.OpStatus{FileName: "model.gguf", CurrentBytes: 50, TotalBytes: 100})
+			time.Sleep(600 * time.Millisecond)
+			gs.UpdateStatus(op.ID, &galleryop.OpStatus{Processed: true, Deletion: op.Delete, Message: "completed", Progress: 100})
+		}()
+		return received
+	}
+
+	const baseModel = `
+name: base
+backend: llama-cpp
+parameters:
+  model: base.gguf
+  temperature: 0.5
+`
+
+	Describe("PullModelEndpoint", func() {
+		It("installs a gallery model of the same name and streams its progress", func() {
+			galleryPath := filepath.Join(tmpDir, "gallery.yaml")
+			Expect(os.WriteFile(galleryPath, []byte("- name: tinyllama\n  description: test\n"), 0o644)).To(Succeed())
+			appConfig.Galleries = []config.Gallery{{Name: "test", URL: "file://" + galleryPath}}
+			received := fakeWorker()
+
+			rec := call(http.MethodPost, "/api/pull", `{"model":"tinyllama:latest"}`, ollama.PullModelEndpoint(cl, gs, appConfig))
+			Expect(rec.Code).To(Equal(http.StatusOK))
+			Expect(rec.Header().Get("Content-Type")).To(Equal("application/x-ndjson"))
+
+			op := <-received
+			Expect(op.GalleryElementName).To(Equal("test@tinyllama"))
+			statuses := ndjsonStatuses(rec.Body.String())
+			Expect(statuses[0]).To(Equal("pulling manifest"))
+			Expect(statuses[1]).To(HavePrefix("pulling "))
+			Expect(statuses[len(statuses)-1]).To(Equal("success"))
+			Expect(rec.Body.String()).To(ContainSubstring(`"total":100,"completed":50`))
+		})
+
+		It("reports success without downloading an installed model", func() {
+			writeConfig("base", baseModel)
+			rec := call(http.MethodPost, "/api/pull", `{"model":"base","stream":false}`, ollama.PullModelEndpoint(cl, gs, appConfig))
+			Expect(rec.Code).To(Equal(http.StatusOK))
+			Expect(rec.Body.String()).To(ContainSubstring(`"status":"success"`))
+			Expect(gs.GetAllStatus()).To(BeEmpty())
+		})
+	})
+
+	Describe("PushModelEndpoint", func() {
+		It("refuses to push", func() {
+			rec := call(http.MethodPost, "/api/push", `{"model":"base"}`, ollama.PushModelEndpoint())
+			Expect(rec.Code).To(Equal(http.StatusNotImplemented))
+		})
+	})
+
+	Describe("DeleteModelEndpoint", func() {
+		It("deletes an installed model through the gallery worker", func() {
+			writeConfig("base", baseModel)
+			received := fakeWorker()
+
+			rec := call(http.MethodDelete, "/api/delete", `{"model":"base:latest"}`, ollama.DeleteModelEndpoint(cl, gs))
+			Expect(rec.Code).To(Equal(http.StatusOK))
+			op := <-received
+			Expect(op.Delete).To(BeTrue())
+			Expect(op.GalleryElementName).To(Equal("base"))
+		})
+
+		It("returns 404 for an unknown model", func() {
+			rec := call(http.MethodDelete, "/api/delete", `{"model":"missing"}`, ollama.DeleteModelEndpoint(cl, gs))
+			Expect(rec.Code).To(Equal(http.StatusNotFound))
+		})
+	})
+
+	Describe("CopyModelEndpoint", func() {
+		It("creates an alias of the source", func() {
+			writeConfig("base", baseModel)
+			rec := call(http.MethodPost, "/api/copy", `{"source":"base:latest","destination":"copy"}`, ollama.CopyModelEndpoint(cl, gs, appConfig))
+			Expect(rec.Code).To(Equal(http.StatusOK))
+
+			data, err := os.ReadFile(filepath.Join(tmpDir, "copy.yaml"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(data)).To(Equal("name: copy\nalias: base\n"))
+			cfg, exists := cl.GetModelConfig("copy")
+			Expect(exists).To(BeTrue())
+			Expect(cfg.Alias).To(Equal("base"))
+		})
+
+		It("refuses to overwrite an existing model", func() {
+			writeConfig("base", baseModel)
+			writeConfig("other", "name: other\nbackend: llama-cpp\nparameters:\n  model: other.gguf\n")
+			rec := call(http.MethodPost, "/api/copy", `{"source":"base","destination":"other"}`, ollama.CopyModelEndpoint(cl, gs, appConfig))
+			Expect(rec.Code).To(Equal(http.StatusConflict))
+		})
+	})
+
+	Describe("CreateModelEndpoint", func() {
+		It("derives a model from an installed one without persisting runtime defaults", func() {
+			writeConfig("base", baseModel)
+			rec := call(http.MethodPost, "/api/create", `{"model":"pirate","stream":false,"modelfile":"FROM base\nSYSTEM Talk like a pirate.\nPARAMETER top_k 10"}`,
+				ollama.CreateModelEndpoint(cl, gs, appConfig))
+			Expect(rec.Code).To(Equal(http.StatusOK), rec.Body.String())
+
+			cfg, exists := cl.GetModelConfig("pirate")
+			Expect(exists).To(BeTrue())
+			Expect(cfg.Model).To(Equal("base.gguf"))
+			Expect(cfg.SystemPrompt).To(Equal("Talk like a pirate."))
+			Expect(*cfg.TopK).To(Equal(10))
+			Expect(*cfg.Temperature).To(Equal(0.5))
+
+			data, err := os.ReadFile(filepath.Join(tmpDir, "pirate.yaml"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(data)).ToNot(ContainSubstring("top_p"))
+		})
+
+		It("creates a llama-cpp model from a GGUF in the models directory", func() {
+			Expect(os.WriteFile(filepath.Join(tmpDir, "weights.gguf"), []byte("gguf"), 0o644)).To(Succeed())
+			rec := call(http.MethodPost, "/api/create", `{"model":"local","from":"weights.gguf","parameters":{"stop":["A","B"],"num_ctx":1048576,"seed":1e7}}`,
+				ollama.CreateModelEndpoint(cl, gs, appConfig))
+			Expect(rec.Code).To(Equal(http.StatusOK))
+			Expect(ndjsonStatuses(rec.Body.String())).To(Equal([]string{"parsing modelfile", "writing manifest", "success"}))
+
+			cfg, exists := cl.GetModelConfig("local")
+			Expect(exists).To(BeTrue())
+			Expect(cfg.Backend).To(Equal("llama-cpp"))
+			Expect(cfg.StopWords).To(ConsistOf("A", "B"))
+			Expect(*cfg.ContextSize).To(Equal(1048576))
+			Expect(*cfg.Seed).To(Equal(10000000))
+		})
+
+		It("rejects a FROM outside the models directory", func() {
+			rec := call(http.MethodPost, "/api/create", `{"model":"evil","stream":false,"from":"../etc/passwd"}`,
+				ollama.CreateModelEndpoint(cl, gs, appConfig))
+			Expect(rec.Code).To(Equal(http.StatusBadRequest))
+			Expect(rec.Body.String()).To(ContainSubstring("outside the models directory"))
+		})
+
+		It("rejects blob uploads", func() {
+			rec := call(http.MethodPost, "/api/create", `{"model":"blob","files":{"model.gguf":"sha256:abc"}}`,
+				ollama.CreateModelEndpoint(cl, gs, appConfig))
+			Expect(rec.Code).To(Equal(http.StatusBadRequest))
+			Expect(rec.Body.String()).To(ContainSubstring("uploaded blobs"))
+		})
+	})
+})
@@ -0,0 +1,384 @@
+package ollama
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+)
+
+// Modelfile is a parsed Ollama Modelfile.
+type Modelfile struct {
+	From       string
+	Parameters []ModelfileParameter // in file order; stop may repeat
+	Template   string
+	System     string
+	Adapters   []string
+	License    []string
+	Messages   []schema.OllamaMessage
+}
+
+// ModelfileParameter is one PARAMETER instruction.
+type ModelfileParameter struct {
+	Name  string
+	Value string
+}
+
+// ParseModelfile parses the Modelfile format: one instruction per line,
+// `#` comments, and arguments that are bare (to end of line), "quoted",
+// or """triple-quoted""" to span lines.
+func ParseModelfile(src string) (*Modelfile, error) {
+	p := &modelfileParser{src: src, line: 1}
+	mf := &Modelfile{}
+	for {
+		p.skipBlank()
+		if p.eof() {
+			return mf, nil
+		}
+		if p.peek() == '#' {
+			p.skipLine()
+			continue
+		}
+
+		line := p.line
+		instruction := strings.ToUpper(p.word())
+		var err error
+		switch instruction {
+		case "FROM":
+			mf.From, err = p.value()
+		case "PARAMETER":
+			var param ModelfileParameter
+			if param.Name, err = p.requiredWord(instruction); err == nil {
+				param.Name = strings.ToLower(param.Name)
+				param.Value, err = p.value()
+				mf.Parameters = append(mf.Parameters, param)
+			}
+		case "TEMPLATE":
+			mf.Template, err = p.value()
+		case "SYSTEM":
+			mf.System, err = p.value()
+		case "ADAPTER":
+			var adapter string
+			if adapter, err = p.value(); err == nil {
+				mf.Adapters = append(mf.Adapters, adapter)
+			}
+		case "LICENSE":
+			var license string
+			if license, err = p.value(); err == nil {
+				mf.License = append(mf.License, license)
+			}
+		case "MESSAGE":
+			var msg schema.OllamaMessage
+			if msg.Role, err = p.requiredWord(instruction); err == nil {
+				msg.Content, err = p.value()
+				mf.Messages = append(mf.Messages, msg)
+			}
+		case "REQUIRES":
+			// Minimum Ollama version; meaningless for LocalAI.
+			_, err = p.value()
+		default:
+			return nil, fmt.Errorf("line %d: unknown instruction %q", line, instruction)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s: %w", line, instruction, err)
+		}
+	}
+}
+
+type modelfileParser struct {
+	src  string
+	pos  int
+	line int
+}
+
+func (p *modelfileParser) eof() bool  { return p.pos >= len(p.src) }
+func (p *modelfileParser) peek() byte { return p.src[p.pos] }
+
+func (p *modelfileParser) advance(n int) {
+	p.line += strings.Count(p.src[p.pos:p.pos+n], "\n")
+	p.pos += n
+}
+
+// skipBlank skips whitespace including newlines.
+func (p *modelfileParser) skipBlank() {
+	for !p.eof() && strings.IndexByte(" \t\r\n", p.peek()) >= 0 {
+		p.advance(1)
+	}
+}
+
+// skipSpace skips whitespace on the current line.
+func (p *modelfileParser) skipSpace() {
+	for !p.eof() && (p.peek() == ' ' || p.peek() == '\t') {
+		p.advance(1)
+	}
+}
+
+func (p *modelfileParser) skipLine() {
+	if i := strings.IndexByte(p.src[p.pos:], '\n'); i >= 0 {
+		p.advance(i + 1)
+		return
+	}
+	p.advance(len(p.src) - p.pos)
+}
+
+// word reads up to the next whitespace.
+func (p *modelfileParser) word() string {
+	p.skipSpace()
+	start := p.pos
+	for !p.eof() && strings.IndexByte(" \t\r\n", p.peek()) < 0 {
+		p.advance(1)
+	}
+	return p.src[start:p.pos]
+}
+
+func (p *modelfileParser) requiredWord(instruction string) (string, error) {
+	w := p.word()
+	if w == "" {
+		return "", fmt.Errorf("missing %s name", strings.ToLower(instruction))
+	}
+	return w, nil
+}
+
+// value reads the argument that runs to the end of the instruction.
+func (p *modelfileParser) value() (string, error) {
+	p.skipSpace()
+	rest := p.src[p.pos:]
+	var v string
+	switch {
+	case strings.HasPrefix(rest, `"""`):
+		end := strings.Index(rest[3:], `"""`)
+		if end < 0 {
+			return "", fmt.Errorf(`unterminated """`)
+		}
+		v = rest[3 : 3+end]
+		p.advance(end + 6)
+	case strings.HasPrefix(rest, `"`):
+		end := 1
+		for ; end < len(rest) && rest[end] != '"' && rest[end] != '\n'; end++ {
+			if rest[end] == '\\' {
+				end++
+			}
+		}
+		if end >= len(rest) || rest[end] != '"' {
+			return "", fmt.Errorf("unterminated quote")
+		}
+		unquoted, err := strconv.Unquote(rest[:end+1])
+		if err != nil {
+			return "", err
+		}
+		v = unquoted
+		p.advance(end + 1)
+	default:
+		end := strings.IndexByte(rest, '\n')
+		if end < 0 {
+			end = len(rest)
+		}
+		v = strings.TrimSpace(rest[:end])
+		p.advance(end)
+		if v == "" {
+			return "", fmt.Errorf("missing value")
+		}
+		return v, nil
+	}
+	p.skipSpace()
+	if !p.eof() && p.peek() != '\n' && p.peek() != '\r' {
+		return "", fmt.Errorf("unexpected text after closing quote")
+	}
+	return v, nil
+}
+
+// modelfileParameters maps Ollama PARAMETER names onto the config field
+// LocalAI uses for the same knob.
+var modelfileParameters = map[string]func(cfg *config.ModelConfig, v string) error{
+	"temperature":       floatPtrParam(func(c *config.ModelConfig) **float64 { return &c.Temperature }),
+	"top_p":             floatPtrParam(func(c *config.ModelConfig) **float64 { return &c.TopP }),
+	"min_p":             floatPtrParam(func(c *config.ModelConfig) **float64 { return &c.MinP }),
+	"typical_p":         floatPtrParam(func(c *config.ModelConfig) **float64 { return &c.TypicalP }),
+	"tfs_z":             floatPtrParam(func(c *config.ModelConfig) **float64 { return &c.TFZ }),
+	"mirostat_eta":      floatPtrParam(func(c *config.ModelConfig) **float64 { return &c.MirostatETA }),
+	"mirostat_tau":      floatPtrParam(func(c *config.ModelConfig) **float64 { return &c.MirostatTAU }),
+	"top_k":             intPtrParam(func(c *config.ModelConfig) **int { return &c.TopK }),
+	"num_ctx":           intPtrParam(func(c *config.ModelConfig) **int { return &c.ContextSize }),
+	"num_predict":       intPtrParam(func(c *config.ModelConfig) **int { return &c.Maxtokens }),
+	"seed":              intPtrParam(func(c *config.ModelConfig) **int { return &c.Seed }),
+	"mirostat":          intPtrParam(func(c *config.ModelConfig) **int { return &c.Mirostat }),
+	"num_gpu":           intPtrParam(func(c *config.ModelConfig) **int { return &c.NGPULayers }),
+	"num_thread":        intPtrParam(func(c *config.ModelConfig) **int { return &c.Threads }),
+	"use_mmap":          boolPtrParam(func(c *config.ModelConfig) **bool { return &c.MMap }),
+	"use_mlock":         boolPtrParam(func(c *config.ModelConfig) **bool { return &c.MMlock }),
+	"repeat_penalty":    floatParam(func(c *config.ModelConfig) *float64 { return &c.RepeatPenalty }),
+	"presence_penalty":  floatParam(func(c *config.ModelConfig) *float64 { return &c.PresencePenalty }),
+	"frequency_penalty": floatParam(func(c *config.ModelConfig) *float64 { return &c.FrequencyPenalty }),
+	"repeat_last_n":     intParam(func(c *config.ModelConfig) *int { return &c.RepeatLastN }),
+	"num_keep":          intParam(func(c *config.ModelConfig) *int { return &c.Keep }),
+	"num_batch":         intParam(func(c *config.ModelConfig) *int { return &c.Batch }),
+	"stop": func(cfg *config.ModelConfig, v string) error {
+		cfg.StopWords = append(cfg.StopWords, v)
+		return nil
+	},
+}
+
+func floatPtrParam(field func(*config.ModelConfig) **float64) func(*config.ModelConfig, string) error {
+	return func(cfg *config.ModelConfig, v string) error {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return err
+		}
+		*field(cfg) = &f
+		return nil
+	}
+}
+
+func intPtrParam(field func(*config.ModelConfig) **int) func(*config.ModelConfig, string) error {
+	return func(cfg *config.ModelConfig, v string) error {
+		i, err := strconv.Atoi(v)
+		if err != nil {
+			return err
+		}
+		*field(cfg) = &i
+		return nil
+	}
+}
+
+func boolPtrParam(field func(*config.ModelConfig) **bool) func(*config.ModelConfig, string) error {
+	return func(cfg *config.ModelConfig, v string) error {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return err
+		}
+		*field(cfg) = &b
+		return nil
+	}
+}
+
+func floatParam(field func(*config.ModelConfig) *float64) func(*config.ModelConfig, string) error {
+	return func(cfg *config.ModelConfig, v string) error {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return err
+		}
+		*field(cfg) = f
+		return nil
+	}
+}
+
+func intParam(field func(*config.ModelConfig) *int) func(*config.ModelConfig, string) error {
+	return func(cfg *config.ModelConfig, v string) error {
+		i, err := strconv.Atoi(v)
+		if err != nil {
+			return err
+		}
+		*field(cfg) = i
+		return nil
+	}
+}
+
+var (
+	ollamaResponseAction = regexp.MustCompile(`\{\{-?\s*\.Response\s*-?\}\}`)
+	ollamaUnsupportedVar = regexp.MustCompile(`\.(Messages|Tools|ToolCalls|Response|Suffix)\b`)
+	ollamaSystemVar      = regexp.MustCompile(`\.System\b`)
+	ollamaPromptVar      = regexp.MustCompile(`\.Prompt\b`)
+)
+
+// translateTemplate rewrites a legacy Ollama prompt template, which
+// renders one turn from .System and .Prompt and marks where generation
+// starts with .Response, into a LocalAI template over .SystemPrompt and
+// .Input. Templates that loop over .Messages have no LocalAI equivalent.
+func translateTemplate(tmpl string) (string, error) {
+	if loc := ollamaResponseAction.FindStringIndex(tmpl); loc != nil {
+		tmpl = tmpl[:loc[0]]
+	}
+	if m := ollamaUnsupportedVar.FindString(tmpl); m != "" {
+		return "", fmt.Errorf("template variable %s cannot be translated; omit TEMPLATE to use the model's own chat template", m)
+	}
+	tmpl = ollamaSystemVar.ReplaceAllString(tmpl, ".SystemPrompt")
+	return ollamaPromptVar.ReplaceAllString(tmpl, ".Input"), nil
+}
+
+// Apply sets the parameters, template, system prompt and adapters of
+// the Modelfile on cfg. FROM is resolved by the caller, which owns the
+// models directory. LICENSE is metadata and is dropped.
+func (mf *Modelfile) Apply(cfg *config.ModelConfig) error {
+	if len(mf.Messages) > 0 {
+		return fmt.Errorf("MESSAGE is not supported")
+	}
+	for _, p := range mf.Parameters {
+		set, ok := modelfileParameters[p.Name]
+		if !ok {
+			return fmt.Errorf("unsupported PARAMETER %q", p.Name)
+		}
+		if err := set(cfg, p.Value); err != nil {
+			return fmt.Errorf("PARAMETER %s: invalid value %q", p.Name, p.Value)
+		}
+	}
+	if mf.Template != "" {
+		tmpl, err := translateTemplate(mf.Template)
+		if err != nil {
+			return err
+		}
+		cfg.TemplateConfig.Chat = tmpl
+		cfg.TemplateConfig.Completion = tmpl
+		cfg.TemplateConfig.ChatMessage = ""
+		cfg.TemplateConfig.UseTokenizerTemplate = false
+	}
+	if mf.System != "" {
+		cfg.SystemPrompt = mf.System
+	}
+	switch len(mf.Adapters) {
+	case 0:
+	case 1:
+		cfg.LoraAdapter = mf.Adapters[0]
+	default:
+		cfg.LoraAdapters = append(cfg.LoraAdapters, mf.Adapters...)
+	}
+	return nil
+}
+
+// modelfileFromRequest builds the Modelfile of a create request: the
+// verbatim modelfile older clients send, overlaid with the parsed fields
+// current clients send instead.
+func modelfileFromRequest(req *schema.OllamaCreateRequest) (*Modelfile, error) {
+	mf := &Modelfile{}
+	if req.Modelfile != "" {
+		parsed, err := ParseModelfile(req.Modelfile)
+		if err != nil {
+			return nil, err
+		}
+		mf = parsed
+	}
+	if req.From != "" {
+		mf.From = req.From
+	}
+	if req.Template != "" {
+		mf.Template = req.Template
+	}
+	if req.System != "" {
+		mf.System = req.System
+	}
+	mf.Messages = append(mf.Messages, req.Messages...)
+	for name, v := range req.Parameters {
+		name = strings.ToLower(name)
+		values, ok := v.([]any)
+		if !ok {
+			values = []any{v}
+		}
+		for _, value := range values {
+			mf.Parameters = append(mf.Parameters, ModelfileParameter{Name: name, Value: parameterValue(value)})
+		}
+	}
+	return mf, nil
+}
+
+// parameterValue renders a JSON parameter value as it would be written in
+// a Modelfile. JSON numbers decode to float64, which fmt prints in
+// exponent form from 1e6 on — something the integer parameters can't
+// parse — so they are formatted without an exponent.
+func parameterValue(v any) string {
+	if f, ok := v.(float64); ok {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,94 @@
+package ollama_test
+
+import (
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/http/endpoints/ollama"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Modelfile", func() {
+	It("parses instructions, comments and quoted values", func() {
+		mf, err := ollama.ParseModelfile(`# a comment
+from llama3.2
+PARAMETER temperature 0.7
+PARAMETER stop "<|eot_id|>"
+PARAMETER stop <|end|>
+SYSTEM """You are
+a pirate."""
+TEMPLATE "{{ .Prompt }}"
+ADAPTER ./lora.gguf
+LICENSE """MIT"""
+`)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(mf.From).To(Equal("llama3.2"))
+		Expect(mf.Parameters).To(Equal([]ollama.ModelfileParameter{
+			{Name: "temperature", Value: "0.7"},
+			{Name: "stop", Value: "<|eot_id|>"},
+			{Name: "stop", Value: "<|end|>"},
+		}))
+		Expect(mf.System).To(Equal("You are\na pirate."))
+		Expect(mf.Template).To(Equal("{{ .Prompt }}"))
+		Expect(mf.Adapters).To(ConsistOf("./lora.gguf"))
+		Expect(mf.License).To(ConsistOf("MIT"))
+	})
+
+	It("reports the line of a malformed instruction", func() {
+		_, err := ollama.ParseModelfile("FROM x\n\nSYSTEM \"\"\"never closed")
+		Expect(err).To(MatchError(ContainSubstring("line 3")))
+
+		_, err = ollama.ParseModelfile("FROM x\nQUANTIZE q4")
+		Expect(err).To(MatchError(ContainSubstring(`unknown instruction "QUANTIZE"`)))
+	})
+
+	It("maps parameters, system prompt and adapters onto the config", func() {
+		mf, err := ollama.ParseModelfile(`FROM base
+PARAMETER temperature 0.2
+PARAMETER top_k 20
+PARAMETER num_ctx 8192
+PARAMETER repeat_penalty 1.1
+PARAMETER use_mmap false
+PARAMETER stop END
+SYSTEM Be brief.
+ADAPTER a.gguf
+`)
+		Expect(err).ToNot(HaveOccurred())
+
+		cfg := &config.ModelConfig{}
+		Expect(mf.Apply(cfg)).To(Succeed())
+		Expect(*cfg.Temperature).To(Equal(0.2))
+		Expect(*cfg.TopK).To(Equal(20))
+		Expect(*cfg.ContextSize).To(Equal(8192))
+		Expect(cfg.RepeatPenalty).To(Equal(1.1))
+		Expect(*cfg.MMap).To(BeFalse())
+		Expect(cfg.StopWords).To(ConsistOf("END"))
+		Expect(cfg.SystemPrompt).To(Equal("Be brief."))
+		Expect(cfg.LoraAdapter).To(Equal("a.gguf"))
+	})
+
+	It("rejects unknown parameters and malformed values", func() {
+		mf, err := ollama.ParseModelfile("FROM base\nPARAMETER penalize_newline true")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(mf.Apply(&config.ModelConfig{})).To(MatchError(ContainSubstring("penalize_newline")))
+
+		mf, err = ollama.ParseModelfile("FROM base\nPARAMETER top_k lots")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(mf.Apply(&config.ModelConfig{})).To(MatchError(ContainSubstring(`invalid value "lots"`)))
+	})
+
+	It("translates a single-turn template and drops the tokenizer template", func() {
+		mf := &ollama.Modelfile{Template: "{{ if .System }}<sys>{{ .System }}</sys>{{ end }}<user>{{ .Prompt }}</user><bot>{{ .Response }}</bot>"}
+		cfg := &config.ModelConfig{}
+		cfg.TemplateConfig.UseTokenizerTemplate = true
+
+		Expect(mf.Apply(cfg)).To(Succeed())
+		Expect(cfg.TemplateConfig.Chat).To(Equal("{{ if .SystemPrompt }}<sys>{{ .SystemPrompt }}</sys>{{ end }}<user>{{ .Input }}</user><bot>"))
+		Expect(cfg.TemplateConfig.Completion).To(Equal(cfg.TemplateConfig.Chat))
+		Expect(cfg.TemplateConfig.UseTokenizerTemplate).To(BeFalse())
+	})
+
+	It("rejects templates that iterate over messages", func() {
+		mf := &ollama.Modelfile{Template: "{{ range .Messages }}{{ .Content }}{{ end }}"}
+		Expect(mf.Apply(&config.ModelConfig{})).To(MatchError(ContainSubstring(".Messages")))
+	})
+})
@@ -65,10 +65,7 @@ func ShowModelEndpoint(bcl *config.ModelConfigLoader) echo.HandlerFunc {
 			return ollamaError(c, 400, "name is required")
 		}
 
-		// Strip tag suffix for config lookup
-		configName := strings.Split(name, ":")[0]
-
-		cfg, exists := bcl.GetModelConfig(configName)
+		cfg, exists := resolveModelConfig(bcl, name)
 		if !exists {
 			return ollamaError(c, 404, fmt.Sprintf("model '%s' not found", name))
 		}
@@ -135,14 +132,23 @@ func HeartbeatEndpoint() echo.HandlerFunc {
 // Ollama details block and capability list. Returns zero values when the model
 // is not configured.
 func modelMetaFromConfig(bcl *config.ModelConfigLoader, name string) (schema.OllamaModelDetails, []string) {
-	configName := strings.Split(name, ":")[0]
-	cfg, exists := bcl.GetModelConfig(configName)
+	cfg, exists := resolveModelConfig(bcl, name)
 	if !exists {
 		return schema.OllamaModelDetails{}, nil
 	}
 	return modelDetailsFromModelConfig(&cfg), modelCapabilities(&cfg)
 }
 
+// resolveModelConfig is lookupModelConfig falling back to the name with
+// its tag stripped, so the read-only endpoints answer for any tag of a
+// model. Endpoints that modify a model must not use it.
+func resolveModelConfig(bcl *config.ModelConfigLoader, name string) (config.ModelConfig, bool) {
+	if cfg, exists := lookupModelConfig(bcl, name); exists {
+		return cfg, true
+	}
+	return bcl.GetModelConfig(strings.Split(name, ":")[0])
+}
+
 func modelDetailsFromModelConfig(cfg *config.ModelConfig) schema.OllamaModelDetails {
 	family := cfg.Backend
 	details := schema.OllamaModelDetails{
@@ -0,0 +1,98 @@
+package openai
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/core/services/batches"
+)
+
+// CreateBatchEndpoint creates a batch from an uploaded JSONL file. Each line
+// is executed asynchronously against the target endpoint.
+// @Summary Create a batch.
+// @Tags batches
+// @Param request body schema.BatchCreateRequest true "query params"
+// @Success 200 {object} schema.Batch "Response"
+// @Router /v1/batches [post]
+func CreateBatchEndpoint(svc *batches.Service) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var req schema.BatchCreateRequest
+		if err := c.Bind(&req); err != nil {
+			return batchAPIError(c, fmt.Errorf("%w: %v", batches.ErrInvalidRequest, err))
+		}
+		b, err := svc.CreateBatch(batchUserID(c), req)
+		if err != nil {
+			return batchAPIError(c, err)
+		}
+		return c.JSON(http.StatusOK, batches.BatchToSchema(*b))
+	}
+}
+
+// ListBatchesEndpoint lists the batches of the current user, newest first.
+// @Summary List batches.
+// @Tags batches
+// @Param after query string false "Cursor: ID of the last batch of the previous page"
+// @Param limit query int false "Page size (1-100, default 20)"
+// @Success 200 {object} schema.BatchList "Response"
+// @Router /v1/batches [get]
+func ListBatchesEndpoint(svc *batches.Service) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		limit := 20
+		if v := c.QueryParam("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 || n > 100 {
+				return batchAPIError(c, fmt.Errorf("%w: limit must be between 1 and 100", batches.ErrInvalidRequest))
+			}
+			limit = n
+		}
+		list, hasMore, err := svc.ListBatches(batchUserID(c), c.QueryParam("after"), limit)
+		if err != nil {
+			return batchAPIError(c, err)
+		}
+		resp := schema.BatchList{Object: "list", Data: make([]schema.Batch, 0, len(list)), HasMore: hasMore}
+		for _, b := range list {
+			resp.Data = append(resp.Data, batches.BatchToSchema(b))
+		}
+		if len(resp.Data) > 0 {
+			resp.FirstID = resp.Data[0].ID
+			resp.LastID = resp.Data[len(resp.Data)-1].ID
+		}
+		return c.JSON(http.StatusOK, resp)
+	}
+}
+
+// GetBatchEndpoint returns a batch.
+// @Summary Retrieve a batch.
+// @Tags batches
+// @Param batch_id path string true "Batch ID"
+// @Success 200 {object} schema.Batch "Response"
+// @Router /v1/batches/{batch_id} [get]
+func GetBatchEndpoint(svc *batches.Service) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		b, err := svc.GetBatch(batchUserID(c), c.Param("batch_id"))
+		if err != nil {
+			return batchAPIError(c, err)
+		}
+		return c.JSON(http.StatusOK, batches.BatchToSchema(*b))
+	}
+}
+
+// CancelBatchEndpoint cancels a batch that has not finished yet. Results of
+// the lines already executed remain available.
+// @Summary Cancel a batch.
+// @Tags batches
+// @Param batch_id path string true "Batch ID"
+// @Success 200 {object} schema.Batch "Response"
+// @Router /v1/batches/{batch_id}/cancel [post]
+func CancelBatchEndpoint(svc *batches.Service) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		b, err := svc.CancelBatch(batchUserID(c), c.Param("batch_id"))
+		if err != nil {
+			return batchAPIError(c, err)
+		}
+		return c.JSON(http.StatusOK, batches.BatchToSchema(*b))
+	}
+}
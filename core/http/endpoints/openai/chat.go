@@ -10,6 +10,7 @@ import (
 	"github.com/labstack/echo/v4"
 	"github.com/mudler/LocalAI/core/backend"
 	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/http/auth"
 	mcpTools "github.com/mudler/LocalAI/core/http/endpoints/mcp"
 	"github.com/mudler/LocalAI/core/http/middleware"
 	"github.com/mudler/LocalAI/core/schema"
@@ -152,8 +153,8 @@ func ChatEndpoint(cl *config.ModelConfigLoader, ml *model.ModelLoader, evaluator
 		// Cloud-proxy bail. Bypasses the local pipeline (templating,
 		// MCP injection, gRPC backend) and forwards via the cloud-
 		// proxy backend, which does the outbound HTTP. Request-side PII
-		// redaction already ran in the middleware; the response is
-		// forwarded unmodified.
+		// redaction already ran in the middleware, which also scans the
+		// response on the way out when the model has scan_responses on.
 		if config.IsCloudProxyBackendPassthrough() {
 			if err := middleware.CompressChatRequest(c, compressor); err != nil {
 				return err
@@ -218,7 +219,7 @@ func ChatEndpoint(cl *config.ModelConfigLoader, ml *model.ModelLoader, evaluator
 		if (len(mcpServers) > 0 || mcpPromptName != "" || len(mcpResourceURIs) > 0) && (config.MCP.Servers != "" || config.MCP.Stdio != "") {
 			remote, stdio, mcpErr := config.MCP.MCPConfigFromYAML()
 			if mcpErr == nil {
-				mcpExecutor = mcpTools.NewToolExecutor(c.Request().Context(), natsClient, config.Name, remote, stdio, mcpServers)
+				mcpExecutor = mcpTools.ExecutorFor(auth.GetUserID(c), mcpTools.NewToolExecutor(c.Request().Context(), natsClient, config.Name, remote, stdio, mcpServers))
 
 				// Prompt and resource injection (pre-processing step — resolves locally regardless of distributed mode)
 				namedSessions, sessErr := mcpTools.NamedSessionsFromMCPConfig(config.Name, remote, stdio, mcpServers)
@@ -280,6 +281,7 @@ func ChatEndpoint(cl *config.ModelConfigLoader, ml *model.ModelLoader, evaluator
 		}
 
 		// If we are using a response format, we need to generate a grammar for it
+		var schemaValidator *functions.SchemaValidator
 		if config.ResponseFormatMap != nil {
 			d := schema.ChatCompletionResponseFormat{}
 			dat, err := json.Marshal(config.ResponseFormatMap)
@@ -295,19 +297,8 @@ func ChatEndpoint(cl *config.ModelConfigLoader, ml *model.ModelLoader, evaluator
 			case "json_object":
 				input.Grammar = functions.JSONBNF
 			case "json_schema":
-				d := schema.JsonSchemaRequest{}
-				dat, err := json.Marshal(config.ResponseFormatMap)
-				if err != nil {
-					return err
-				}
-				err = json.Unmarshal(dat, &d)
-				if err != nil {
-					return err
-				}
-				fs := &functions.JSONFunctionStructure{
-					AnyOf: []functions.Item{d.JsonSchema.Schema},
-				}
-				g, err := fs.Grammar(config.FunctionsConfig.GrammarOptions()...)
+				var g string
+				g, schemaValidator, err = JSONSchemaResponseFormat(config.ResponseFormatMap, config)
 				if err == nil {
 					input.Grammar = g
 				} else {
@@ -480,7 +471,7 @@ func ChatEndpoint(cl *config.ModelConfigLoader, ml *model.ModelLoader, evaluator
 						// We collect the RAW (unfiltered) content so the model's tool-call
 						// markup keeps parsing correctly even when PII redaction would mask
 						// substrings.
-						if (hasMCPToolsStream || config.FunctionsConfig.AutomaticToolParsingFallback) && haveContent {
+						if (hasMCPToolsStream || config.FunctionsConfig.AutomaticToolParsingFallback || schemaValidator != nil) && haveContent {
 							collectedContent += rawContent
 						}
 						respData, err := json.Marshal(ev)
@@ -502,6 +493,11 @@ func ChatEndpoint(cl *config.ModelConfigLoader, ml *model.ModelLoader, evaluator
 							finalUsage = res.usage
 							break LOOP
 						}
+						if middleware.WillFallBack(c, res.err) {
+							// No token was sent: the fallback model
+							// answers instead.
+							return res.err
+						}
 						xlog.Error("Stream ended with error", "error", res.err)
 
 						errorResp := schema.ErrorResponse{
@@ -554,13 +550,27 @@ func ChatEndpoint(cl *config.ModelConfigLoader, ml *model.ModelLoader, evaluator
 						}
 						input.Messages = append(input.Messages, assistantMsg)
 
+						// Elicitation requests of the servers are streamed as
+						// mcp_elicitation events; the caller answers them on
+						// /v1/mcp/elicitations/:id while the tool call waits.
+						elicitationUser := ""
+						if u := auth.GetUser(c); u != nil {
+							elicitationUser = u.ID
+						}
+						toolCtx := mcpTools.WithElicitationHandler(c.Request().Context(), mcpTools.StreamElicitation(elicitationUser, func(ev mcpTools.ElicitationEvent) {
+							if evData, err := json.Marshal(ev); err == nil {
+								fmt.Fprintf(c.Response().Writer, "data: %s\n\n", evData)
+								c.Response().Flush()
+							}
+						}))
+
 						// Execute MCP tool calls and stream results as tool_result events
 						for _, tc := range collectedToolCalls {
 							if mcpExecutor == nil || !mcpExecutor.IsTool(tc.FunctionCall.Name) {
 								continue
 							}
 							xlog.Debug("Executing MCP tool (stream)", "tool", tc.FunctionCall.Name, "iteration", mcpStreamIter)
-							toolResult, toolErr := mcpExecutor.ExecuteTool(c.Request().Context(), tc.FunctionCall.Name, tc.FunctionCall.Arguments)
+							toolResult, toolErr := mcpExecutor.ExecuteTool(toolCtx, tc.FunctionCall.Name, tc.FunctionCall.Arguments)
 							if toolErr != nil {
 								xlog.Error("MCP tool execution failed", "tool", tc.FunctionCall.Name, "error", toolErr)
 								toolResult = fmt.Sprintf("Error: %v", toolErr)
@@ -627,6 +637,20 @@ func ChatEndpoint(cl *config.ModelConfigLoader, ml *model.ModelLoader, evaluator
 					}
 				}
 
+				// Streamed output cannot be regenerated, so a strict
+				// json_schema mismatch is reported in place of the stop chunk.
+				if schemaValidator != nil && !toolsCalled && input.Context.Err() == nil {
+					if err := schemaValidator.Validate(collectedContent); err != nil {
+						xlog.Warn("Streamed output does not match the response_format schema", "error", err)
+						middleware.StampUsage(c, input.Model, finalUsage.Prompt, finalUsage.Completion)
+						respData, _ := json.Marshal(SchemaValidationError(err))
+						fmt.Fprintf(c.Response().Writer, "data: %s\n\n", respData)
+						fmt.Fprintf(c.Response().Writer, "data: [DONE]\n\n")
+						c.Response().Flush()
+						return nil
+					}
+				}
+
 				// No MCP tools to execute, send final stop message
 				finishReason := FinishReasonStop
 				if toolsCalled && len(input.Tools) > 0 {
@@ -743,47 +767,64 @@ func ChatEndpoint(cl *config.ModelConfigLoader, ml *model.ModelLoader, evaluator
 					cbReasoning = reasoning
 				}
 
-				var result []schema.Choice
+				// tokenUsage is that of the last attempt, billedUsage the sum
+				// over the schema regenerations, which all ran on the backend.
 				var tokenUsage backend.TokenUsage
-				var err error
-
 				var chatDeltas []*pb.ChatDelta
-				result, tokenUsage, chatDeltas, err = ComputeChoices(
-					input,
-					predInput,
-					config,
-					cl,
-					startupOptions,
-					ml,
-					tokenCallback,
-					nil,
-					func(attempt int) bool {
-						if !shouldUseFn {
+				validator := schemaValidator
+				if shouldUseFn {
+					validator = nil
+				}
+				result, billedUsage, schemaErr, err := regenerateForSchema(validator, config.FunctionsConfig.GrammarConfig.SchemaRetries, func() ([]schema.Choice, backend.TokenUsage, error) {
+					var choices []schema.Choice
+					var err error
+					choices, tokenUsage, chatDeltas, err = ComputeChoices(
+						input,
+						predInput,
+						config,
+						cl,
+						startupOptions,
+						ml,
+						tokenCallback,
+						nil,
+						func(attempt int) bool {
+							if !shouldUseFn {
+								return false
+							}
+							// Retry when backend produced only reasoning and no content/tool calls.
+							// Full tool parsing is deferred until after ComputeChoices returns
+							// (when chat deltas are available), but we can detect the empty case here.
+							if cbRawResult == "" && textContentToReturn == "" {
+								xlog.Warn("Backend produced reasoning without actionable content, retrying",
+									"reasoning_len", len(cbReasoning), "attempt", attempt+1)
+								cbRawResult = ""
+								cbReasoning = ""
+								textContentToReturn = ""
+								return true
+							}
 							return false
-						}
-						// Retry when backend produced only reasoning and no content/tool calls.
-						// Full tool parsing is deferred until after ComputeChoices returns
-						// (when chat deltas are available), but we can detect the empty case here.
-						if cbRawResult == "" && textContentToReturn == "" {
-							xlog.Warn("Backend produced reasoning without actionable content, retrying",
-								"reasoning_len", len(cbReasoning), "attempt", attempt+1)
-							cbRawResult = ""
-							cbReasoning = ""
-							textContentToReturn = ""
-							return true
-						}
-						return false
-					},
-				)
+						},
+					)
+					if err != nil {
+						return nil, tokenUsage, err
+					}
+
+					// For non-tool requests: prefer C++ autoparser chat deltas over
+					// Go-side tag extraction (which can mangle output when thinkingStartToken
+					// differs from the model's actual reasoning tags, e.g. Gemma 4).
+					if !shouldUseFn {
+						choices = applyAutoparserOverride(chatDeltas, thinkingStartToken, config.ReasoningConfig, choices)
+					}
+					return choices, tokenUsage, nil
+				})
 				if err != nil {
 					return err
 				}
-
-				// For non-tool requests: prefer C++ autoparser chat deltas over
-				// Go-side tag extraction (which can mangle output when thinkingStartToken
-				// differs from the model's actual reasoning tags, e.g. Gemma 4).
-				if !shouldUseFn {
-					result = applyAutoparserOverride(chatDeltas, thinkingStartToken, config.ReasoningConfig, result)
+				if schemaErr != nil {
+					// Every attempt ran on the backend, so it is billed even
+					// though no output is returned.
+					middleware.StampUsage(c, input.Model, billedUsage.Prompt, billedUsage.Completion)
+					return c.JSON(http.StatusInternalServerError, SchemaValidationError(schemaErr))
 				}
 
 				// Tool parsing is deferred here (only when shouldUseFn) so chat deltas are available
@@ -1023,13 +1064,13 @@ func ChatEndpoint(cl *config.ModelConfigLoader, ml *model.ModelLoader, evaluator
 
 				// No MCP tools to execute (or no MCP tools configured), return response
 				usage := schema.OpenAIUsage{
-					PromptTokens:     tokenUsage.Prompt,
-					CompletionTokens: tokenUsage.Completion,
-					TotalTokens:      tokenUsage.Prompt + tokenUsage.Completion,
+					PromptTokens:     billedUsage.Prompt,
+					CompletionTokens: billedUsage.Completion,
+					TotalTokens:      billedUsage.Prompt + billedUsage.Completion,
 				}
 				if extraUsage {
-					usage.TimingTokenGeneration = tokenUsage.TimingTokenGeneration
-					usage.TimingPromptProcessing = tokenUsage.TimingPromptProcessing
+					usage.TimingTokenGeneration = billedUsage.TimingTokenGeneration
+					usage.TimingPromptProcessing = billedUsage.TimingPromptProcessing
 				}
 				usage.CompressionMeta = middleware.CompressionMetadata(c)
 
@@ -1097,7 +1138,8 @@ func handleQuestion(config *config.ModelConfig, funcResults []functions.FuncCall
 // HTTP. The chat endpoint owns the body construction because it's the
 // only place the request lands as a parsed *schema.OpenAIRequest.
 // Request-side PII redaction already ran in the middleware; the
-// response is forwarded unmodified.
+// response is forwarded as-is and only scanned by the middleware when
+// the model has pii.scan_responses on.
 func forwardCloudProxyOpenAIViaBackend(c echo.Context, cfg *config.ModelConfig, input *schema.OpenAIRequest, ml *model.ModelLoader, appConfig *config.ApplicationConfig) error {
 	body, err := json.Marshal(input)
 	if err != nil {
@@ -185,6 +185,11 @@ func CompletionEndpoint(cl *config.ModelConfigLoader, ml *model.ModelLoader, eva
 					if err == nil {
 						break LOOP
 					}
+					if middleware.WillFallBack(c, err) {
+						// No token was sent: the fallback model
+						// answers instead.
+						return err
+					}
 					xlog.Error("Stream ended with error", "error", err)
 
 					stopReason := FinishReasonStop
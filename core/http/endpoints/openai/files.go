@@ -0,0 +1,136 @@
+package openai
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/mudler/LocalAI/core/http/auth"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/core/services/batches"
+)
+
+// batchUserID returns the ID files and batches are scoped to. Without an
+// authenticated user everything lives in the shared "" namespace.
+func batchUserID(c echo.Context) string {
+	if user := auth.GetUser(c); user != nil {
+		return user.ID
+	}
+	return ""
+}
+
+// batchAPIError maps a batches service error onto an OpenAI error response.
+func batchAPIError(c echo.Context, err error) error {
+	code, typ := http.StatusInternalServerError, "server_error"
+	switch {
+	case errors.Is(err, batches.ErrNotFound):
+		code, typ = http.StatusNotFound, "invalid_request_error"
+	case errors.Is(err, batches.ErrInvalidRequest):
+		code, typ = http.StatusBadRequest, "invalid_request_error"
+	}
+	return c.JSON(code, schema.ErrorResponse{
+		Error: &schema.APIError{Message: err.Error(), Code: code, Type: typ},
+	})
+}
+
+// UploadFileEndpoint stores a JSONL file to be used as batch input.
+// @Summary Upload a file to be used as batch input.
+// @Tags files
+// @Accept multipart/form-data
+// @Param file formData file true "JSONL file"
+// @Param purpose formData string true "File purpose, batch or assistants"
+// @Success 200 {object} schema.OpenAIFile "Response"
+// @Router /v1/files [post]
+func UploadFileEndpoint(svc *batches.Service) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		fh, err := c.FormFile("file")
+		if err != nil {
+			return batchAPIError(c, fmt.Errorf("%w: file is required", batches.ErrInvalidRequest))
+		}
+		src, err := fh.Open()
+		if err != nil {
+			return batchAPIError(c, err)
+		}
+		defer src.Close()
+
+		rec, err := svc.UploadFile(c.Request().Context(), batchUserID(c), fh.Filename, c.FormValue("purpose"), src)
+		if err != nil {
+			return batchAPIError(c, err)
+		}
+		return c.JSON(http.StatusOK, batches.FileToSchema(*rec))
+	}
+}
+
+// ListFilesEndpoint lists the files of the current user.
+// @Summary List files.
+// @Tags files
+// @Param purpose query string false "Only return files with this purpose"
+// @Success 200 {object} schema.OpenAIFileList "Response"
+// @Router /v1/files [get]
+func ListFilesEndpoint(svc *batches.Service) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		files, err := svc.ListFiles(batchUserID(c), c.QueryParam("purpose"))
+		if err != nil {
+			return batchAPIError(c, err)
+		}
+		resp := schema.OpenAIFileList{Object: "list", Data: make([]schema.OpenAIFile, 0, len(files))}
+		for _, f := range files {
+			resp.Data = append(resp.Data, batches.FileToSchema(f))
+		}
+		return c.JSON(http.StatusOK, resp)
+	}
+}
+
+// GetFileEndpoint returns the metadata of a file.
+// @Summary Retrieve a file.
+// @Tags files
+// @Param file_id path string true "File ID"
+// @Success 200 {object} schema.OpenAIFile "Response"
+// @Router /v1/files/{file_id} [get]
+func GetFileEndpoint(svc *batches.Service) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		rec, err := svc.GetFile(batchUserID(c), c.Param("file_id"))
+		if err != nil {
+			return batchAPIError(c, err)
+		}
+		return c.JSON(http.StatusOK, batches.FileToSchema(*rec))
+	}
+}
+
+// GetFileContentEndpoint streams the content of a file, e.g. a batch output.
+// @Summary Retrieve the content of a file.
+// @Tags files
+// @Param file_id path string true "File ID"
+// @Success 200 {string} string "JSONL content"
+// @Router /v1/files/{file_id}/content [get]
+func GetFileContentEndpoint(svc *batches.Service) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		_, r, err := svc.OpenFile(c.Request().Context(), batchUserID(c), c.Param("file_id"))
+		if err != nil {
+			return batchAPIError(c, err)
+		}
+		defer r.Close()
+		c.Response().Header().Set(echo.HeaderContentType, "application/jsonl")
+		c.Response().WriteHeader(http.StatusOK)
+		_, err = io.Copy(c.Response(), r)
+		return err
+	}
+}
+
+// DeleteFileEndpoint deletes a file.
+// @Summary Delete a file.
+// @Tags files
+// @Param file_id path string true "File ID"
+// @Success 200 {object} schema.OpenAIDeleteResponse "Response"
+// @Router /v1/files/{file_id} [delete]
+func DeleteFileEndpoint(svc *batches.Service) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		id := c.Param("file_id")
+		if err := svc.DeleteFile(c.Request().Context(), batchUserID(c), id); err != nil {
+			return batchAPIError(c, err)
+		}
+		return c.JSON(http.StatusOK, schema.OpenAIDeleteResponse{ID: id, Object: "file", Deleted: true})
+	}
+}
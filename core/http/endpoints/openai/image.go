@@ -130,22 +130,9 @@ func ImageEndpoint(cl *config.ModelConfigLoader, ml *model.ModelLoader, appConfi
 			config.Backend = model.StableDiffusionGGMLBackend
 		}
 
-		if !strings.Contains(input.Size, "x") {
-			input.Size = "512x512"
-			xlog.Warn("Invalid size, using default 512x512")
-		}
-
-		sizeParts := strings.Split(input.Size, "x")
-		if len(sizeParts) != 2 {
-			return fmt.Errorf("invalid value for 'size'")
-		}
-		width, err := strconv.Atoi(sizeParts[0])
-		if err != nil {
-			return fmt.Errorf("invalid value for 'size'")
-		}
-		height, err := strconv.Atoi(sizeParts[1])
+		width, height, err := parseImageSize(input.Size)
 		if err != nil {
-			return fmt.Errorf("invalid value for 'size'")
+			return err
 		}
 
 		b64JSON := config.ResponseFormat == "b64_json"
@@ -251,11 +238,36 @@ func ImageEndpoint(cl *config.ModelConfigLoader, ml *model.ModelLoader, appConfi
 		jsonResult, _ := json.Marshal(resp)
 		xlog.Debug("Response", "response", string(jsonResult))
 
+		middleware.StampMediaUsage(c, input.Model, 0, len(result))
+
 		// Return the prediction in the response body
 		return c.JSON(200, resp)
 	}
 }
 
+// parseImageSize parses a WIDTHxHEIGHT size, defaulting to 512x512 when
+// size is not in that form.
+func parseImageSize(size string) (width, height int, err error) {
+	if !strings.Contains(size, "x") {
+		xlog.Warn("Invalid size, using default 512x512")
+		return 512, 512, nil
+	}
+
+	sizeParts := strings.Split(size, "x")
+	if len(sizeParts) != 2 {
+		return 0, 0, fmt.Errorf("invalid value for 'size'")
+	}
+	width, err = strconv.Atoi(sizeParts[0])
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid value for 'size'")
+	}
+	height, err = strconv.Atoi(sizeParts[1])
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid value for 'size'")
+	}
+	return width, height, nil
+}
+
 // processImageFile handles a single image file (URL or base64) and returns the path to the temporary file
 func processImageFile(file string, generatedContentDir string) string {
 	fileData := []byte{}
@@ -0,0 +1,287 @@
+package openai
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/mudler/xlog"
+
+	"github.com/mudler/LocalAI/core/backend"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/http/middleware"
+	"github.com/mudler/LocalAI/core/schema"
+	model "github.com/mudler/LocalAI/pkg/model"
+)
+
+// maxImagesPerRequest is the upper bound OpenAI puts on `n` for edits and
+// variations.
+const maxImagesPerRequest = 10
+
+// ImageEditEndpoint is the OpenAI image edit API endpoint https://platform.openai.com/docs/api-reference/images/createEdit
+// With a mask the request is served by the inpainting path, otherwise by
+// img2img from the first image.
+// @Summary Creates edited images given an image and a prompt.
+// @Tags images
+// @Accept multipart/form-data
+// @Produce application/json
+// @Param model formData string true "model"
+// @Param image formData file true "image to edit; send image[] for several"
+// @Param mask formData file false "mask image (white = area to edit)"
+// @Param prompt formData string true "description of the desired edit"
+// @Param n formData int false "number of images to generate (1-10)"
+// @Param size formData string false "WIDTHxHEIGHT"
+// @Param response_format formData string false "url or b64_json"
+// @Success 200 {object} schema.OpenAIResponse "Response"
+// @Router /v1/images/edits [post]
+func ImageEditEndpoint(cl *config.ModelConfigLoader, ml *model.ModelLoader, appConfig *config.ApplicationConfig) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		prompt := c.FormValue("prompt")
+		if prompt == "" {
+			return echo.NewHTTPError(http.StatusBadRequest, "missing prompt")
+		}
+		return generateFromUpload(c, ml, appConfig, prompt, true)
+	}
+}
+
+// ImageVariationEndpoint is the OpenAI image variation API endpoint https://platform.openai.com/docs/api-reference/images/createVariation
+// Variations are img2img generations from the image without a prompt.
+// @Summary Creates variations of a given image.
+// @Tags images
+// @Accept multipart/form-data
+// @Produce application/json
+// @Param model formData string true "model"
+// @Param image formData file true "image to use as the basis for the variations"
+// @Param n formData int false "number of images to generate (1-10)"
+// @Param size formData string false "WIDTHxHEIGHT"
+// @Param response_format formData string false "url or b64_json"
+// @Success 200 {object} schema.OpenAIResponse "Response"
+// @Router /v1/images/variations [post]
+func ImageVariationEndpoint(cl *config.ModelConfigLoader, ml *model.ModelLoader, appConfig *config.ApplicationConfig) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		return generateFromUpload(c, ml, appConfig, "", false)
+	}
+}
+
+// imageUploadOptions are the generation options of an edit or variation
+// request, read from its multipart form.
+type imageUploadOptions struct {
+	n       int
+	width   int
+	height  int
+	b64JSON bool
+}
+
+func parseImageUploadOptions(c echo.Context, cfg *config.ModelConfig) (imageUploadOptions, error) {
+	opts := imageUploadOptions{n: 1}
+	if v := c.FormValue("n"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxImagesPerRequest {
+			return opts, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("n must be between 1 and %d", maxImagesPerRequest))
+		}
+		opts.n = n
+	}
+
+	var err error
+	if opts.width, opts.height, err = parseImageSize(c.FormValue("size")); err != nil {
+		return opts, echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	format := c.FormValue("response_format")
+	if format == "" {
+		format = cfg.ResponseFormat
+	}
+	switch format {
+	case "", "url":
+	case "b64_json":
+		opts.b64JSON = true
+	default:
+		return opts, echo.NewHTTPError(http.StatusBadRequest, "response_format must be url or b64_json")
+	}
+	return opts, nil
+}
+
+// generateFromUpload runs the image generations of an edit or variation
+// request. The uploaded images and mask are staged in a scratch directory
+// that is removed once the images are generated.
+func generateFromUpload(c echo.Context, ml *model.ModelLoader, appConfig *config.ApplicationConfig, prompt string, allowMask bool) error {
+	cfg, ok := c.Get(middleware.CONTEXT_LOCALS_KEY_MODEL_CONFIG).(*config.ModelConfig)
+	if !ok || cfg == nil {
+		xlog.Error("Image Edit Endpoint - model config not found in context")
+		return echo.ErrBadRequest
+	}
+	modelName := c.FormValue("model")
+	if input, ok := c.Get(middleware.CONTEXT_LOCALS_KEY_LOCALAI_REQUEST).(*schema.OpenAIRequest); ok && input.Model != "" {
+		modelName = input.Model
+	}
+
+	opts, err := parseImageUploadOptions(c, cfg)
+	if err != nil {
+		return err
+	}
+
+	form, err := c.MultipartForm()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "expected a multipart/form-data request")
+	}
+	images := slices.Concat(form.File["image"], form.File["image[]"])
+	if len(images) == 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "missing image file")
+	}
+	var mask *multipart.FileHeader
+	if allowMask && len(form.File["mask"]) > 0 {
+		mask = form.File["mask"][0]
+	}
+
+	outputDir := filepath.Join(appConfig.GeneratedContentDir, "images")
+	if err := os.MkdirAll(outputDir, 0750); err != nil {
+		xlog.Error("Image Edit Endpoint - failed to create generated content dir", "error", err, "dir", outputDir)
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to prepare storage")
+	}
+	workDir, err := os.MkdirTemp(appConfig.GeneratedContentDir, "edit_")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(workDir)
+
+	var imagePaths []string
+	for i, fh := range images {
+		path, err := saveUploadedImage(fh, workDir, fmt.Sprintf("image_%d", i))
+		if err != nil {
+			return err
+		}
+		imagePaths = append(imagePaths, path)
+	}
+
+	// Without a mask this is img2img from the first image, the others
+	// being reference images, as in /v1/images/generations. With one, the
+	// source follows /v1/images/inpainting: a JSON file with both images
+	// base64-encoded, plus the image and mask as reference images.
+	src, refImages := imagePaths[0], imagePaths[1:]
+	if mask != nil {
+		maskPath, err := saveUploadedImage(mask, workDir, "mask")
+		if err != nil {
+			return err
+		}
+		if src, err = writeInpaintingSource(workDir, imagePaths[0], maskPath); err != nil {
+			return err
+		}
+		refImages = []string{imagePaths[0], maskPath}
+	}
+
+	mc := *cfg
+	switch mc.Backend {
+	case "stablediffusion", "":
+		mc.Backend = model.StableDiffusionGGMLBackend
+	}
+	step := mc.Step
+	if step == 0 {
+		step = 15
+	}
+	seed := 0
+	if mc.Seed != nil {
+		seed = *mc.Seed
+	}
+	positivePrompt, negativePrompt, _ := strings.Cut(prompt, "|")
+
+	baseURL := middleware.BaseURL(c)
+	var result []schema.Item
+	for range opts.n {
+		dir := outputDir
+		if opts.b64JSON {
+			dir = workDir
+		}
+		output := filepath.Join(dir, fmt.Sprintf("edit_%s.png", uuid.New().String()))
+
+		fn, err := backend.ImageGenerationFunc(c.Request().Context(), opts.height, opts.width, step, seed, positivePrompt, negativePrompt, src, output, ml, mc, appConfig, refImages)
+		if err != nil {
+			return err
+		}
+		if err := fn(); err != nil {
+			_ = os.Remove(output)
+			return err
+		}
+
+		var item schema.Item
+		if opts.b64JSON {
+			data, err := os.ReadFile(output)
+			if err != nil {
+				return err
+			}
+			item.B64JSON = base64.StdEncoding.EncodeToString(data)
+		} else {
+			item.URL, err = url.JoinPath(baseURL, "generated-images", filepath.Base(output))
+			if err != nil {
+				return err
+			}
+		}
+		result = append(result, item)
+	}
+
+	resp := &schema.OpenAIResponse{
+		ID:      uuid.New().String(),
+		Created: int(time.Now().Unix()),
+		Data:    result,
+		Usage: &schema.OpenAIUsage{
+			InputTokensDetails: &schema.InputTokensDetails{},
+		},
+	}
+	middleware.StampMediaUsage(c, modelName, 0, len(result))
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+// saveUploadedImage copies an uploaded file to dir/name and returns its path.
+func saveUploadedImage(fh *multipart.FileHeader, dir, name string) (string, error) {
+	src, err := fh.Open()
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+
+	path := filepath.Join(dir, name)
+	dst, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+	defer dst.Close()
+
+	if _, err := io.Copy(dst, src); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// writeInpaintingSource writes the JSON source the inpainting backends
+// expect, with the image and mask base64-encoded, and returns its path.
+func writeInpaintingSource(dir, imagePath, maskPath string) (string, error) {
+	img, err := os.ReadFile(imagePath)
+	if err != nil {
+		return "", err
+	}
+	mask, err := os.ReadFile(maskPath)
+	if err != nil {
+		return "", err
+	}
+	dat, err := json.Marshal(map[string]string{
+		"image":      base64.StdEncoding.EncodeToString(img),
+		"mask_image": base64.StdEncoding.EncodeToString(mask),
+	})
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, "inpaint.json")
+	return path, os.WriteFile(path, dat, 0600)
+}
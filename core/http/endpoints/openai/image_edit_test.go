@@ -0,0 +1,165 @@
+package openai
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+
+	"github.com/labstack/echo/v4"
+	"github.com/mudler/LocalAI/core/backend"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/http/middleware"
+	"github.com/mudler/LocalAI/core/schema"
+	model "github.com/mudler/LocalAI/pkg/model"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Image edits and variations", func() {
+	type generation struct {
+		width, height int
+		prompt        string
+		src           string
+		srcData       []byte
+		refImages     []string
+	}
+
+	var (
+		tmpDir      string
+		appConf     *config.ApplicationConfig
+		generations []generation
+	)
+
+	BeforeEach(func() {
+		var err error
+		tmpDir, err = os.MkdirTemp("", "gencontent")
+		Expect(err).ToNot(HaveOccurred())
+		DeferCleanup(func() { os.RemoveAll(tmpDir) })
+		appConf = config.NewApplicationConfig(config.WithGeneratedContentDir(tmpDir))
+
+		generations = nil
+		orig := backend.ImageGenerationFunc
+		backend.ImageGenerationFunc = func(ctx context.Context, height, width, step, seed int, positive_prompt, negative_prompt, src, dst string, loader *model.ModelLoader, modelConfig config.ModelConfig, appConfig *config.ApplicationConfig, refImages []string) (func() error, error) {
+			return func() error {
+				// The staged uploads are gone once the handler returns:
+				// keep what the backend would have read.
+				srcData, err := os.ReadFile(src)
+				if err != nil {
+					return err
+				}
+				generations = append(generations, generation{
+					width: width, height: height, prompt: positive_prompt,
+					src: src, srcData: srcData, refImages: refImages,
+				})
+				return os.WriteFile(dst, []byte("PNGDATA"), 0644)
+			}, nil
+		}
+		DeferCleanup(func() { backend.ImageGenerationFunc = orig })
+	})
+
+	call := func(h echo.HandlerFunc, fields map[string]string, files map[string][]byte) (*httptest.ResponseRecorder, error) {
+		req, _ := makeMultipartRequest(fields, files)
+		rec := httptest.NewRecorder()
+		c := echo.New().NewContext(req, rec)
+		c.Set(middleware.CONTEXT_LOCALS_KEY_MODEL_CONFIG, &config.ModelConfig{Backend: "diffusers"})
+		return rec, h(c)
+	}
+
+	decode := func(rec *httptest.ResponseRecorder) schema.OpenAIResponse {
+		var resp schema.OpenAIResponse
+		Expect(json.Unmarshal(rec.Body.Bytes(), &resp)).To(Succeed())
+		return resp
+	}
+
+	It("edits with img2img when there is no mask", func() {
+		rec, err := call(ImageEditEndpoint(nil, nil, appConf),
+			map[string]string{"model": "sd", "prompt": "a red hat", "size": "256x128"},
+			map[string][]byte{"image": []byte("IMAGEDATA")})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(rec.Code).To(Equal(http.StatusOK))
+
+		Expect(generations).To(HaveLen(1))
+		g := generations[0]
+		Expect(g.prompt).To(Equal("a red hat"))
+		Expect(g.width).To(Equal(256))
+		Expect(g.height).To(Equal(128))
+		Expect(g.srcData).To(Equal([]byte("IMAGEDATA")))
+		Expect(g.refImages).To(BeEmpty())
+
+		resp := decode(rec)
+		Expect(resp.Data).To(HaveLen(1))
+		Expect(resp.Data[0].URL).To(ContainSubstring("generated-images/"))
+		_, err = os.Stat(filepath.Join(tmpDir, "images", filepath.Base(resp.Data[0].URL)))
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("edits with the inpainting source when a mask is sent", func() {
+		rec, err := call(ImageEditEndpoint(nil, nil, appConf),
+			map[string]string{"model": "sd", "prompt": "a red hat"},
+			map[string][]byte{"image": []byte("IMAGEDATA"), "mask": []byte("MASKDATA")})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(rec.Code).To(Equal(http.StatusOK))
+
+		Expect(generations).To(HaveLen(1))
+		var src map[string]string
+		Expect(json.Unmarshal(generations[0].srcData, &src)).To(Succeed())
+		Expect(src["image"]).To(Equal(base64.StdEncoding.EncodeToString([]byte("IMAGEDATA"))))
+		Expect(src["mask_image"]).To(Equal(base64.StdEncoding.EncodeToString([]byte("MASKDATA"))))
+		Expect(generations[0].refImages).To(HaveLen(2))
+	})
+
+	It("honours n and b64_json", func() {
+		rec, err := call(ImageEditEndpoint(nil, nil, appConf),
+			map[string]string{"model": "sd", "prompt": "a red hat", "n": "3", "response_format": "b64_json"},
+			map[string][]byte{"image": []byte("IMAGEDATA")})
+		Expect(err).ToNot(HaveOccurred())
+
+		resp := decode(rec)
+		Expect(resp.Data).To(HaveLen(3))
+		for _, item := range resp.Data {
+			Expect(item.URL).To(BeEmpty())
+			Expect(item.B64JSON).To(Equal(base64.StdEncoding.EncodeToString([]byte("PNGDATA"))))
+		}
+		// b64_json results are not kept on disk.
+		entries, err := os.ReadDir(filepath.Join(tmpDir, "images"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(entries).To(BeEmpty())
+	})
+
+	It("rejects invalid requests", func() {
+		h := ImageEditEndpoint(nil, nil, appConf)
+		image := map[string][]byte{"image": []byte("IMAGEDATA")}
+
+		_, err := call(h, map[string]string{"model": "sd"}, image)
+		Expect(err).To(MatchError(ContainSubstring("missing prompt")))
+
+		_, err = call(h, map[string]string{"model": "sd", "prompt": "p", "n": "11"}, image)
+		Expect(err).To(MatchError(ContainSubstring("n must be between 1 and 10")))
+
+		_, err = call(h, map[string]string{"model": "sd", "prompt": "p", "response_format": "png"}, image)
+		Expect(err).To(MatchError(ContainSubstring("response_format must be url or b64_json")))
+
+		_, err = call(h, map[string]string{"model": "sd", "prompt": "p"}, nil)
+		Expect(err).To(MatchError(ContainSubstring("missing image file")))
+		Expect(generations).To(BeEmpty())
+	})
+
+	It("generates variations without a prompt, ignoring masks", func() {
+		rec, err := call(ImageVariationEndpoint(nil, nil, appConf),
+			map[string]string{"model": "sd", "n": "2"},
+			map[string][]byte{"image": []byte("IMAGEDATA"), "mask": []byte("MASKDATA")})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(rec.Code).To(Equal(http.StatusOK))
+
+		Expect(generations).To(HaveLen(2))
+		for _, g := range generations {
+			Expect(g.prompt).To(BeEmpty())
+			Expect(g.srcData).To(Equal([]byte("IMAGEDATA")))
+		}
+		Expect(decode(rec).Data).To(HaveLen(2))
+	})
+})
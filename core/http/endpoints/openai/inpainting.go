@@ -273,6 +273,7 @@ func InpaintingEndpoint(cl *config.ModelConfigLoader, ml *model.ModelLoader, app
 
 		// mark success so defer cleanup will not remove output files
 		success = true
+		middleware.StampMediaUsage(c, modelName, 0, 1)
 
 		return c.JSON(http.StatusOK, resp)
 	}
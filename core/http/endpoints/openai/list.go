@@ -1,6 +1,10 @@
 package openai
 
 import (
+	"fmt"
+	"net/http"
+	"slices"
+
 	"github.com/labstack/echo/v4"
 	"github.com/mudler/LocalAI/core/config"
 	"github.com/mudler/LocalAI/core/http/auth"
@@ -21,7 +25,7 @@ func ListModelsEndpoint(bcl *config.ModelConfigLoader, ml *model.ModelLoader, ap
 		authDB = db[0]
 	}
 	return func(c echo.Context) error {
-		modelNames, err := listVisibleModelNames(c, bcl, ml, authDB)
+		modelNames, err := ListVisibleModelNames(c, bcl, ml, authDB)
 		if err != nil {
 			return err
 		}
@@ -39,11 +43,34 @@ func ListModelsEndpoint(bcl *config.ModelConfigLoader, ml *model.ModelLoader, ap
 	}
 }
 
-// listVisibleModelNames resolves the model names visible to the caller, applying
+// GetModelEndpoint returns a single model visible to the caller.
+// @Summary Retrieve a model.
+// @Tags models
+// @Param model_id path string true "Model ID"
+// @Success 200 {object} schema.OpenAIModel "Response"
+// @Router /v1/models/{model_id} [get]
+func GetModelEndpoint(bcl *config.ModelConfigLoader, ml *model.ModelLoader, authDB *gorm.DB) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		modelNames, err := ListVisibleModelNames(c, bcl, ml, authDB)
+		if err != nil {
+			return err
+		}
+		id := c.Param("model_id")
+		if !slices.Contains(modelNames, id) {
+			return c.JSON(http.StatusNotFound, schema.ErrorResponse{
+				Error: &schema.APIError{Message: fmt.Sprintf("model %q not found", id), Code: http.StatusNotFound, Type: "invalid_request_error"},
+			})
+		}
+		return c.JSON(http.StatusOK, schema.OpenAIModel{ID: id, Object: "model"})
+	}
+}
+
+// ListVisibleModelNames resolves the model names visible to the caller, applying
 // the same query filters (filter, excludeConfigured) and per-user allowlist as
-// the OpenAI models listing. Shared by ListModelsEndpoint and
-// ListModelCapabilitiesEndpoint so both stay consistent.
-func listVisibleModelNames(c echo.Context, bcl *config.ModelConfigLoader, ml *model.ModelLoader, authDB *gorm.DB) ([]string, error) {
+// the OpenAI models listing. Shared by ListModelsEndpoint,
+// ListModelCapabilitiesEndpoint and the Anthropic models listing so all of
+// them stay consistent.
+func ListVisibleModelNames(c echo.Context, bcl *config.ModelConfigLoader, ml *model.ModelLoader, authDB *gorm.DB) ([]string, error) {
 	// If blank, no filter is applied.
 	filter := c.QueryParam("filter")
 
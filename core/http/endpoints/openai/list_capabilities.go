@@ -26,7 +26,7 @@ func ListModelCapabilitiesEndpoint(bcl *config.ModelConfigLoader, ml *model.Mode
 		authDB = db[0]
 	}
 	return func(c echo.Context) error {
-		modelNames, err := listVisibleModelNames(c, bcl, ml, authDB)
+		modelNames, err := ListVisibleModelNames(c, bcl, ml, authDB)
 		if err != nil {
 			return err
 		}
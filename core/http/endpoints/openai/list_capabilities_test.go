@@ -51,7 +51,7 @@ var _ = Describe("ListModelCapabilitiesEndpoint", func() {
 
 	// call exercises the endpoint with auth disabled (no auth DB), which is the
 	// standard deployment path. The per-user allowlist branch is shared verbatim
-	// with ListModelsEndpoint (listVisibleModelNames) and covered there.
+	// with ListModelsEndpoint (ListVisibleModelNames) and covered there.
 	call := func() schema.ModelCapabilitiesResponse {
 		req := httptest.NewRequest(http.MethodGet, "/v1/models/capabilities", nil)
 		rec := httptest.NewRecorder()
@@ -0,0 +1,97 @@
+package openai
+
+import (
+	"encoding/json"
+
+	"github.com/mudler/LocalAI/core/backend"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/pkg/functions"
+	"github.com/mudler/xlog"
+)
+
+// JSONSchemaResponseFormat builds the grammar of a json_schema
+// response_format. Strict schemas also get a validator, which the final
+// output is checked against since the grammar cannot enforce every keyword.
+func JSONSchemaResponseFormat(format map[string]any, cfg *config.ModelConfig) (string, *functions.SchemaValidator, error) {
+	d := schema.JsonSchemaRequest{}
+	dat, err := json.Marshal(format)
+	if err != nil {
+		return "", nil, err
+	}
+	if err := json.Unmarshal(dat, &d); err != nil {
+		return "", nil, err
+	}
+
+	var validator *functions.SchemaValidator
+	if d.JsonSchema.Strict {
+		validator, err = functions.NewSchemaValidator(d.JsonSchema.Schema)
+		if err != nil {
+			xlog.Warn("Output will not be validated against the response_format schema", "error", err)
+		}
+	}
+
+	g, err := functions.JSONSchemaGrammar(d.JsonSchema.Schema, cfg.FunctionsConfig.GrammarOptions()...)
+	return g, validator, err
+}
+
+// validateChoices checks the content of every choice against the schema.
+func validateChoices(validator *functions.SchemaValidator, choices []schema.Choice) error {
+	for _, choice := range choices {
+		if choice.Message == nil {
+			continue
+		}
+		var content string
+		switch v := choice.Message.Content.(type) {
+		case string:
+			content = v
+		case *string:
+			if v != nil {
+				content = *v
+			}
+		}
+		if err := validator.Validate(content); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// regenerateForSchema runs generate until its choices validate against the
+// schema or retries regenerations have failed too; a nil validator runs it
+// once. billed adds up the usage of every attempt, as each ran on the
+// backend, and schemaErr is the mismatch of the last one.
+func regenerateForSchema(validator *functions.SchemaValidator, retries int, generate func() ([]schema.Choice, backend.TokenUsage, error)) (choices []schema.Choice, billed backend.TokenUsage, schemaErr, err error) {
+	for attempt := 0; ; attempt++ {
+		var usage backend.TokenUsage
+		choices, usage, err = generate()
+		if err != nil {
+			return choices, billed, nil, err
+		}
+		billed.Prompt += usage.Prompt
+		billed.Completion += usage.Completion
+		billed.TimingPromptProcessing += usage.TimingPromptProcessing
+		billed.TimingTokenGeneration += usage.TimingTokenGeneration
+
+		if validator == nil {
+			return choices, billed, nil, nil
+		}
+		schemaErr = validateChoices(validator, choices)
+		if schemaErr == nil || attempt >= retries {
+			return choices, billed, schemaErr, nil
+		}
+		xlog.Warn("Output does not match the response_format schema, regenerating", "error", schemaErr, "attempt", attempt+1)
+	}
+}
+
+// SchemaValidationError is the error reported when the output of a strict
+// json_schema response_format does not validate against the schema.
+func SchemaValidationError(err error) schema.ErrorResponse {
+	return schema.ErrorResponse{
+		Error: &schema.APIError{
+			Message: "model output does not match the response_format schema: " + err.Error(),
+			Type:    "server_error",
+			Code:    "json_schema_validation_failed",
+		},
+	}
+}
@@ -0,0 +1,89 @@
+package openai
+
+import (
+	"errors"
+
+	"github.com/mudler/LocalAI/core/backend"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("JSONSchemaResponseFormat", func() {
+	format := func(strict bool) map[string]any {
+		return map[string]any{
+			"type": "json_schema",
+			"json_schema": map[string]any{
+				"name":   "answer",
+				"strict": strict,
+				"schema": map[string]any{
+					"type":       "object",
+					"properties": map[string]any{"answer": map[string]any{"type": "string", "maxLength": 5}},
+					"required":   []any{"answer"},
+				},
+			},
+		}
+	}
+
+	It("validates the output of strict schemas only", func() {
+		g, validator, err := JSONSchemaResponseFormat(format(false), &config.ModelConfig{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(g).To(ContainSubstring(`"\"" char{0,5} "\"" space`))
+		Expect(validator).To(BeNil())
+
+		_, validator, err = JSONSchemaResponseFormat(format(true), &config.ModelConfig{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(validator).ToNot(BeNil())
+
+		valid, invalid := `{"answer": "yes"}`, `{"answer": "absolutely"}`
+		Expect(validateChoices(validator, []schema.Choice{{Message: &schema.Message{Content: &valid}}})).To(Succeed())
+		Expect(validateChoices(validator, []schema.Choice{
+			{Message: &schema.Message{Content: valid}},
+			{Message: &schema.Message{Content: &invalid}},
+		})).ToNot(Succeed())
+	})
+
+	It("bills every attempt when none matches the schema", func() {
+		_, validator, err := JSONSchemaResponseFormat(format(true), &config.ModelConfig{})
+		Expect(err).ToNot(HaveOccurred())
+
+		attempts := 0
+		invalid := `{"answer": "absolutely"}`
+		_, billed, schemaErr, err := regenerateForSchema(validator, 2, func() ([]schema.Choice, backend.TokenUsage, error) {
+			attempts++
+			return []schema.Choice{{Message: &schema.Message{Content: invalid}}}, backend.TokenUsage{Prompt: 10, Completion: 4}, nil
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(schemaErr).To(HaveOccurred())
+		Expect(attempts).To(Equal(3))
+		Expect(billed.Prompt).To(Equal(30))
+		Expect(billed.Completion).To(Equal(12))
+	})
+
+	It("stops regenerating once the output matches", func() {
+		_, validator, err := JSONSchemaResponseFormat(format(true), &config.ModelConfig{})
+		Expect(err).ToNot(HaveOccurred())
+
+		outputs := []string{`{"answer": "absolutely"}`, `{"answer": "yes"}`}
+		attempts := 0
+		choices, billed, schemaErr, err := regenerateForSchema(validator, 2, func() ([]schema.Choice, backend.TokenUsage, error) {
+			out := outputs[attempts]
+			attempts++
+			return []schema.Choice{{Message: &schema.Message{Content: out}}}, backend.TokenUsage{Prompt: 10, Completion: 4}, nil
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(schemaErr).ToNot(HaveOccurred())
+		Expect(attempts).To(Equal(2))
+		Expect(choices[0].Message.Content).To(Equal(`{"answer": "yes"}`))
+		Expect(billed.Completion).To(Equal(8))
+	})
+
+	It("reports mismatches as server errors", func() {
+		resp := SchemaValidationError(errors.New("boom"))
+		Expect(resp.Error.Type).To(Equal("server_error"))
+		Expect(resp.Error.Code).To(Equal("json_schema_validation_failed"))
+		Expect(resp.Error.Message).To(ContainSubstring("boom"))
+	})
+})
@@ -69,7 +69,7 @@ func TranscriptEndpoint(cl *config.ModelConfigLoader, ml *model.ModelLoader, app
 		}
 
 		diarize := c.FormValue("diarize") != "false"
-		prompt := c.FormValue("prompt")
+		prompt := audioPrompt(input)
 		responseFormat := schema.TranscriptionResponseFormatType(c.FormValue("response_format"))
 
 		// OpenAI accepts `temperature` as a string in multipart form. Tolerate
@@ -104,36 +104,11 @@ func TranscriptEndpoint(cl *config.ModelConfigLoader, ml *model.ModelLoader, app
 			}
 		}
 
-		// retrieve the file data from the request
-		file, err := c.FormFile("file")
+		dst, cleanup, err := saveAudioUpload(c)
 		if err != nil {
 			return err
 		}
-		f, err := file.Open()
-		if err != nil {
-			return err
-		}
-		defer f.Close()
-
-		dir, err := os.MkdirTemp("", "whisper")
-
-		if err != nil {
-			return err
-		}
-		defer os.RemoveAll(dir)
-
-		dst := filepath.Join(dir, path.Base(file.Filename))
-		dstFile, err := os.Create(dst)
-		if err != nil {
-			return err
-		}
-
-		if _, err := io.Copy(dstFile, f); err != nil {
-			xlog.Debug("Audio file copying error", "filename", file.Filename, "dst", dst, "error", err)
-			return err
-		}
-
-		xlog.Debug("Audio file copied", "dst", dst)
+		defer cleanup()
 
 		// Language/translate resolve with the request form field taking
 		// precedence over the model config default (parameters.language /
@@ -173,52 +148,115 @@ func TranscriptEndpoint(cl *config.ModelConfigLoader, ml *model.ModelLoader, app
 		}
 
 		xlog.Debug("Transcribed", "transcription", tr)
+		middleware.StampMediaUsage(c, input.Model, transcriptionAudioSeconds(tr), 0)
 
-		switch responseFormat {
-		case schema.TranscriptionResponseFormatLrc, schema.TranscriptionResponseFormatText, schema.TranscriptionResponseFormatSrt, schema.TranscriptionResponseFormatVtt:
-			return c.String(http.StatusOK, schema.TranscriptionResponse(tr, responseFormat))
-		case schema.TranscriptionResponseFormatJson:
-			tr.Segments = nil
-			tr.Words = nil
-			fallthrough
-		case schema.TranscriptionResponseFormatJsonVerbose, "": // maintain backwards compatibility
-			trs := schema.TranscriptionResultSeconds{
-				Text:     tr.Text,
-				Language: tr.Language,
-				Duration: tr.Duration,
-				Words:    []schema.TranscriptionWordSeconds{},
-				Segments: []schema.TranscriptionSegmentSeconds{},
-			}
-			for _, word := range tr.Words {
-				trs.Words = append(trs.Words, schema.TranscriptionWordSeconds{
+		return writeTranscriptionResponse(c, tr, responseFormat)
+	}
+}
+
+// BindAudioPrompt carries the `prompt` form field of a multipart audio
+// request over to the parsed request, which the form binding leaves
+// empty, so that request middlewares (PII) see and rewrite it.
+func BindAudioPrompt(c echo.Context) {
+	input, ok := c.Get(middleware.CONTEXT_LOCALS_KEY_LOCALAI_REQUEST).(*schema.OpenAIRequest)
+	if !ok || input.Prompt != nil {
+		return
+	}
+	if prompt := c.FormValue("prompt"); prompt != "" {
+		input.Prompt = prompt
+	}
+}
+
+// audioPrompt returns the prompt bound by BindAudioPrompt.
+func audioPrompt(input *schema.OpenAIRequest) string {
+	prompt, _ := input.Prompt.(string)
+	return prompt
+}
+
+// saveAudioUpload copies the uploaded `file` form field to a temporary
+// directory, for backends that read audio from disk. cleanup removes it.
+func saveAudioUpload(c echo.Context) (dst string, cleanup func(), err error) {
+	file, err := c.FormFile("file")
+	if err != nil {
+		return "", nil, err
+	}
+	f, err := file.Open()
+	if err != nil {
+		return "", nil, err
+	}
+	defer f.Close()
+
+	dir, err := os.MkdirTemp("", "whisper")
+	if err != nil {
+		return "", nil, err
+	}
+	cleanup = func() { os.RemoveAll(dir) }
+
+	dst = filepath.Join(dir, path.Base(file.Filename))
+	dstFile, err := os.Create(dst)
+	if err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	defer dstFile.Close()
+
+	if _, err := io.Copy(dstFile, f); err != nil {
+		xlog.Debug("Audio file copying error", "filename", file.Filename, "dst", dst, "error", err)
+		cleanup()
+		return "", nil, err
+	}
+
+	xlog.Debug("Audio file copied", "dst", dst)
+	return dst, cleanup, nil
+}
+
+// writeTranscriptionResponse renders tr in the requested response_format,
+// shared by the transcription and translation endpoints.
+func writeTranscriptionResponse(c echo.Context, tr *schema.TranscriptionResult, responseFormat schema.TranscriptionResponseFormatType) error {
+	switch responseFormat {
+	case schema.TranscriptionResponseFormatLrc, schema.TranscriptionResponseFormatText, schema.TranscriptionResponseFormatSrt, schema.TranscriptionResponseFormatVtt:
+		return c.String(http.StatusOK, schema.TranscriptionResponse(tr, responseFormat))
+	case schema.TranscriptionResponseFormatJson:
+		tr.Segments = nil
+		tr.Words = nil
+		fallthrough
+	case schema.TranscriptionResponseFormatJsonVerbose, "": // maintain backwards compatibility
+		trs := schema.TranscriptionResultSeconds{
+			Text:     tr.Text,
+			Language: tr.Language,
+			Duration: tr.Duration,
+			Words:    []schema.TranscriptionWordSeconds{},
+			Segments: []schema.TranscriptionSegmentSeconds{},
+		}
+		for _, word := range tr.Words {
+			trs.Words = append(trs.Words, schema.TranscriptionWordSeconds{
+				Start: word.Start.Seconds(),
+				End:   word.End.Seconds(),
+				Text:  word.Text,
+			})
+		}
+		for _, seg := range tr.Segments {
+			segWords := []schema.TranscriptionWordSeconds{}
+			for _, word := range seg.Words {
+				segWords = append(segWords, schema.TranscriptionWordSeconds{
 					Start: word.Start.Seconds(),
 					End:   word.End.Seconds(),
 					Text:  word.Text,
 				})
 			}
-			for _, seg := range tr.Segments {
-				segWords := []schema.TranscriptionWordSeconds{}
-				for _, word := range seg.Words {
-					segWords = append(segWords, schema.TranscriptionWordSeconds{
-						Start: word.Start.Seconds(),
-						End:   word.End.Seconds(),
-						Text:  word.Text,
-					})
-				}
-				trs.Segments = append(trs.Segments, schema.TranscriptionSegmentSeconds{
-					Id:      seg.Id,
-					Start:   seg.Start.Seconds(),
-					End:     seg.End.Seconds(),
-					Text:    seg.Text,
-					Tokens:  seg.Tokens,
-					Speaker: seg.Speaker,
-					Words:   segWords,
-				})
-			}
-			return c.JSON(http.StatusOK, trs)
-		default:
-			return errors.New("invalid response_format")
+			trs.Segments = append(trs.Segments, schema.TranscriptionSegmentSeconds{
+				Id:      seg.Id,
+				Start:   seg.Start.Seconds(),
+				End:     seg.End.Seconds(),
+				Text:    seg.Text,
+				Tokens:  seg.Tokens,
+				Speaker: seg.Speaker,
+				Words:   segWords,
+			})
 		}
+		return c.JSON(http.StatusOK, trs)
+	default:
+		return errors.New("invalid response_format")
 	}
 }
 
@@ -321,5 +359,19 @@ func streamTranscription(c echo.Context, req backend.TranscriptionRequest, ml *m
 	_ = writeEvent(doneEvent)
 	_, _ = fmt.Fprintf(c.Response().Writer, "data: [DONE]\n\n")
 	c.Response().Flush()
+	middleware.StampMediaUsage(c, config.Name, transcriptionAudioSeconds(finalResult), 0)
 	return nil
 }
+
+// transcriptionAudioSeconds is the length of the transcribed audio, for
+// per-second pricing: the backend-reported duration, or the end of the
+// last segment for backends that don't report one.
+func transcriptionAudioSeconds(tr *schema.TranscriptionResult) float64 {
+	if tr.Duration > 0 {
+		return tr.Duration
+	}
+	if n := len(tr.Segments); n > 0 {
+		return tr.Segments[n-1].End.Seconds()
+	}
+	return 0
+}
@@ -0,0 +1,79 @@
+package openai
+
+import (
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"github.com/mudler/LocalAI/core/backend"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/http/middleware"
+	"github.com/mudler/LocalAI/core/schema"
+	model "github.com/mudler/LocalAI/pkg/model"
+
+	"github.com/mudler/xlog"
+)
+
+// TranslationEndpoint is the OpenAI Whisper API endpoint https://platform.openai.com/docs/api-reference/audio/createTranslation
+// @Summary Translates audio into English.
+// @Tags audio
+// @accept multipart/form-data
+// @Param model formData string true "model"
+// @Param file formData file true "file"
+// @Param prompt formData string false "optional text to guide the model's style, in English"
+// @Param response_format formData string false "json, text, srt, verbose_json or vtt"
+// @Param temperature formData number false "sampling temperature"
+// @Success 200 {object} map[string]string	 "Response"
+// @Router /v1/audio/translations [post]
+func TranslationEndpoint(cl *config.ModelConfigLoader, ml *model.ModelLoader, appConfig *config.ApplicationConfig) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		input, ok := c.Get(middleware.CONTEXT_LOCALS_KEY_LOCALAI_REQUEST).(*schema.OpenAIRequest)
+		if !ok || input.Model == "" {
+			return echo.ErrBadRequest
+		}
+
+		config, ok := c.Get(middleware.CONTEXT_LOCALS_KEY_MODEL_CONFIG).(*config.ModelConfig)
+		if !ok || config == nil {
+			return echo.ErrBadRequest
+		}
+
+		responseFormat := schema.TranscriptionResponseFormatType(c.FormValue("response_format"))
+
+		var temperature float32
+		if v := c.FormValue("temperature"); v != "" {
+			if t, err := strconv.ParseFloat(v, 32); err == nil {
+				temperature = float32(t)
+			}
+		}
+
+		dst, cleanup, err := saveAudioUpload(c)
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+
+		// The language is the one spoken in the audio: OpenAI detects it,
+		// but a hint (form field or parameters.language) still helps
+		// backends that can't.
+		req := backend.TranscriptionRequest{
+			Audio:       dst,
+			Language:    resolveTranscriptionLanguage(c.FormValue("language"), input.Language, config.Language),
+			Translate:   true,
+			Prompt:      audioPrompt(input),
+			Temperature: temperature,
+		}
+
+		tr, err := backend.ModelTranscriptionWithOptions(c.Request().Context(), req, ml, *config, appConfig)
+		if err != nil {
+			xlog.Error("Translation failed",
+				"model", config.Name,
+				"audio", dst,
+				"error", err)
+			return err
+		}
+
+		xlog.Debug("Translated", "translation", tr)
+		middleware.StampMediaUsage(c, input.Model, transcriptionAudioSeconds(tr), 0)
+
+		return writeTranscriptionResponse(c, tr, responseFormat)
+	}
+}
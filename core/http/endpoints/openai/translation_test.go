@@ -0,0 +1,107 @@
+package openai
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/mudler/LocalAI/core/http/middleware"
+	"github.com/mudler/LocalAI/core/schema"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Audio translation helpers", func() {
+	newContext := func(fields map[string]string) (echo.Context, *httptest.ResponseRecorder) {
+		body := &bytes.Buffer{}
+		w := multipart.NewWriter(body)
+		for k, v := range fields {
+			Expect(w.WriteField(k, v)).To(Succeed())
+		}
+		Expect(w.Close()).To(Succeed())
+		req := httptest.NewRequest(http.MethodPost, "/v1/audio/translations", body)
+		req.Header.Set(echo.HeaderContentType, w.FormDataContentType())
+		rec := httptest.NewRecorder()
+		return echo.New().NewContext(req, rec), rec
+	}
+
+	Describe("BindAudioPrompt", func() {
+		It("copies the prompt form field to the parsed request", func() {
+			c, _ := newContext(map[string]string{"prompt": "Glossary: LocalAI"})
+			input := &schema.OpenAIRequest{}
+			c.Set(middleware.CONTEXT_LOCALS_KEY_LOCALAI_REQUEST, input)
+
+			BindAudioPrompt(c)
+			Expect(input.Prompt).To(Equal("Glossary: LocalAI"))
+			Expect(audioPrompt(input)).To(Equal("Glossary: LocalAI"))
+		})
+
+		It("keeps a prompt already on the request", func() {
+			c, _ := newContext(map[string]string{"prompt": "original"})
+			input := &schema.OpenAIRequest{}
+			input.Prompt = "[REDACTED]"
+			c.Set(middleware.CONTEXT_LOCALS_KEY_LOCALAI_REQUEST, input)
+
+			BindAudioPrompt(c)
+			Expect(audioPrompt(input)).To(Equal("[REDACTED]"))
+		})
+
+		It("leaves the prompt unset when the form has none", func() {
+			c, _ := newContext(nil)
+			input := &schema.OpenAIRequest{}
+			c.Set(middleware.CONTEXT_LOCALS_KEY_LOCALAI_REQUEST, input)
+
+			BindAudioPrompt(c)
+			Expect(input.Prompt).To(BeNil())
+			Expect(audioPrompt(input)).To(BeEmpty())
+		})
+	})
+
+	Describe("writeTranscriptionResponse", func() {
+		result := func() *schema.TranscriptionResult {
+			return &schema.TranscriptionResult{
+				Text: "Hello world",
+				Segments: []schema.TranscriptionSegment{
+					{Id: 0, Start: 0, End: 1500 * time.Millisecond, Text: "Hello world"},
+				},
+			}
+		}
+
+		It("drops segments for json", func() {
+			c, rec := newContext(nil)
+			Expect(writeTranscriptionResponse(c, result(), schema.TranscriptionResponseFormatJson)).To(Succeed())
+
+			var out schema.TranscriptionResultSeconds
+			Expect(json.Unmarshal(rec.Body.Bytes(), &out)).To(Succeed())
+			Expect(out.Text).To(Equal("Hello world"))
+			Expect(out.Segments).To(BeEmpty())
+		})
+
+		It("reports segments in seconds for verbose_json", func() {
+			c, rec := newContext(nil)
+			Expect(writeTranscriptionResponse(c, result(), schema.TranscriptionResponseFormatJsonVerbose)).To(Succeed())
+
+			var out schema.TranscriptionResultSeconds
+			Expect(json.Unmarshal(rec.Body.Bytes(), &out)).To(Succeed())
+			Expect(out.Segments).To(HaveLen(1))
+			Expect(out.Segments[0].End).To(Equal(1.5))
+		})
+
+		It("renders text formats as plain text", func() {
+			c, rec := newContext(nil)
+			Expect(writeTranscriptionResponse(c, result(), schema.TranscriptionResponseFormatText)).To(Succeed())
+			Expect(rec.Body.String()).To(ContainSubstring("Hello world"))
+			Expect(rec.Header().Get(echo.HeaderContentType)).To(HavePrefix(echo.MIMETextPlain))
+		})
+
+		It("rejects unknown formats", func() {
+			c, _ := newContext(nil)
+			Expect(writeTranscriptionResponse(c, result(), "xml")).To(MatchError("invalid response_format"))
+		})
+	})
+})
@@ -128,6 +128,7 @@ func UpscaleEndpoint(cl *config.ModelConfigLoader, ml *model.ModelLoader, appCon
 				InputTokensDetails: &schema.InputTokensDetails{},
 			},
 		}
+		middleware.StampMediaUsage(c, modelName, 0, 1)
 
 		return c.JSON(http.StatusOK, resp)
 	}
@@ -0,0 +1,229 @@
+package openai
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/core/services/vectorstores"
+)
+
+// vectorStoreAPIError maps a vector store service error onto an OpenAI
+// error response.
+func vectorStoreAPIError(c echo.Context, err error) error {
+	code, typ := http.StatusInternalServerError, "server_error"
+	switch {
+	case errors.Is(err, vectorstores.ErrNotFound):
+		code, typ = http.StatusNotFound, "invalid_request_error"
+	case errors.Is(err, vectorstores.ErrInvalidRequest):
+		code, typ = http.StatusBadRequest, "invalid_request_error"
+	}
+	return c.JSON(code, schema.ErrorResponse{
+		Error: &schema.APIError{Message: err.Error(), Code: code, Type: typ},
+	})
+}
+
+// vectorStorePageSize reads the limit query parameter of the list endpoints.
+func vectorStorePageSize(c echo.Context) (int, error) {
+	limit := 20
+	if v := c.QueryParam("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 100 {
+			return 0, fmt.Errorf("%w: limit must be between 1 and 100", vectorstores.ErrInvalidRequest)
+		}
+		limit = n
+	}
+	return limit, nil
+}
+
+// CreateVectorStoreEndpoint creates a vector store, optionally attaching
+// already uploaded files to it.
+// @Summary Create a vector store.
+// @Tags vector_stores
+// @Param request body schema.VectorStoreCreateRequest true "query params"
+// @Success 200 {object} schema.VectorStore "Response"
+// @Router /v1/vector_stores [post]
+func CreateVectorStoreEndpoint(svc *vectorstores.Service) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var req schema.VectorStoreCreateRequest
+		if err := c.Bind(&req); err != nil {
+			return vectorStoreAPIError(c, fmt.Errorf("%w: %v", vectorstores.ErrInvalidRequest, err))
+		}
+		vs, err := svc.CreateVectorStore(batchUserID(c), req)
+		if err != nil {
+			return vectorStoreAPIError(c, err)
+		}
+		return c.JSON(http.StatusOK, vs)
+	}
+}
+
+// ListVectorStoresEndpoint lists the vector stores of the current user,
+// newest first.
+// @Summary List vector stores.
+// @Tags vector_stores
+// @Param after query string false "Cursor: ID of the last vector store of the previous page"
+// @Param limit query int false "Page size (1-100, default 20)"
+// @Success 200 {object} schema.VectorStoreList "Response"
+// @Router /v1/vector_stores [get]
+func ListVectorStoresEndpoint(svc *vectorstores.Service) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		limit, err := vectorStorePageSize(c)
+		if err != nil {
+			return vectorStoreAPIError(c, err)
+		}
+		list, hasMore, err := svc.ListVectorStores(batchUserID(c), c.QueryParam("after"), limit)
+		if err != nil {
+			return vectorStoreAPIError(c, err)
+		}
+		resp := schema.VectorStoreList{Object: "list", Data: list, HasMore: hasMore}
+		if len(list) > 0 {
+			resp.FirstID = list[0].ID
+			resp.LastID = list[len(list)-1].ID
+		}
+		return c.JSON(http.StatusOK, resp)
+	}
+}
+
+// GetVectorStoreEndpoint returns a vector store.
+// @Summary Retrieve a vector store.
+// @Tags vector_stores
+// @Param vector_store_id path string true "Vector store ID"
+// @Success 200 {object} schema.VectorStore "Response"
+// @Router /v1/vector_stores/{vector_store_id} [get]
+func GetVectorStoreEndpoint(svc *vectorstores.Service) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		vs, err := svc.GetVectorStore(batchUserID(c), c.Param("vector_store_id"))
+		if err != nil {
+			return vectorStoreAPIError(c, err)
+		}
+		return c.JSON(http.StatusOK, vs)
+	}
+}
+
+// DeleteVectorStoreEndpoint deletes a vector store. The files attached to it
+// are kept.
+// @Summary Delete a vector store.
+// @Tags vector_stores
+// @Param vector_store_id path string true "Vector store ID"
+// @Success 200 {object} schema.OpenAIDeleteResponse "Response"
+// @Router /v1/vector_stores/{vector_store_id} [delete]
+func DeleteVectorStoreEndpoint(svc *vectorstores.Service) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		id := c.Param("vector_store_id")
+		if err := svc.DeleteVectorStore(c.Request().Context(), batchUserID(c), id); err != nil {
+			return vectorStoreAPIError(c, err)
+		}
+		return c.JSON(http.StatusOK, schema.OpenAIDeleteResponse{ID: id, Object: "vector_store.deleted", Deleted: true})
+	}
+}
+
+// CreateVectorStoreFileEndpoint attaches an uploaded file to a vector store.
+// The file is chunked and embedded in the background.
+// @Summary Add a file to a vector store.
+// @Tags vector_stores
+// @Param vector_store_id path string true "Vector store ID"
+// @Param request body schema.VectorStoreFileCreateRequest true "query params"
+// @Success 200 {object} schema.VectorStoreFile "Response"
+// @Router /v1/vector_stores/{vector_store_id}/files [post]
+func CreateVectorStoreFileEndpoint(svc *vectorstores.Service) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var req schema.VectorStoreFileCreateRequest
+		if err := c.Bind(&req); err != nil {
+			return vectorStoreAPIError(c, fmt.Errorf("%w: %v", vectorstores.ErrInvalidRequest, err))
+		}
+		f, err := svc.AddFile(batchUserID(c), c.Param("vector_store_id"), req)
+		if err != nil {
+			return vectorStoreAPIError(c, err)
+		}
+		return c.JSON(http.StatusOK, f)
+	}
+}
+
+// ListVectorStoreFilesEndpoint lists the files of a vector store, newest
+// first.
+// @Summary List the files of a vector store.
+// @Tags vector_stores
+// @Param vector_store_id path string true "Vector store ID"
+// @Param filter query string false "Only return files with this status"
+// @Param after query string false "Cursor: ID of the last file of the previous page"
+// @Param limit query int false "Page size (1-100, default 20)"
+// @Success 200 {object} schema.VectorStoreFileList "Response"
+// @Router /v1/vector_stores/{vector_store_id}/files [get]
+func ListVectorStoreFilesEndpoint(svc *vectorstores.Service) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		limit, err := vectorStorePageSize(c)
+		if err != nil {
+			return vectorStoreAPIError(c, err)
+		}
+		list, hasMore, err := svc.ListFiles(batchUserID(c), c.Param("vector_store_id"), c.QueryParam("filter"), c.QueryParam("after"), limit)
+		if err != nil {
+			return vectorStoreAPIError(c, err)
+		}
+		resp := schema.VectorStoreFileList{Object: "list", Data: list, HasMore: hasMore}
+		if len(list) > 0 {
+			resp.FirstID = list[0].ID
+			resp.LastID = list[len(list)-1].ID
+		}
+		return c.JSON(http.StatusOK, resp)
+	}
+}
+
+// GetVectorStoreFileEndpoint returns a file of a vector store.
+// @Summary Retrieve a vector store file.
+// @Tags vector_stores
+// @Param vector_store_id path string true "Vector store ID"
+// @Param file_id path string true "File ID"
+// @Success 200 {object} schema.VectorStoreFile "Response"
+// @Router /v1/vector_stores/{vector_store_id}/files/{file_id} [get]
+func GetVectorStoreFileEndpoint(svc *vectorstores.Service) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		f, err := svc.GetFile(batchUserID(c), c.Param("vector_store_id"), c.Param("file_id"))
+		if err != nil {
+			return vectorStoreAPIError(c, err)
+		}
+		return c.JSON(http.StatusOK, f)
+	}
+}
+
+// DeleteVectorStoreFileEndpoint detaches a file from a vector store. The
+// uploaded file itself is kept.
+// @Summary Remove a file from a vector store.
+// @Tags vector_stores
+// @Param vector_store_id path string true "Vector store ID"
+// @Param file_id path string true "File ID"
+// @Success 200 {object} schema.OpenAIDeleteResponse "Response"
+// @Router /v1/vector_stores/{vector_store_id}/files/{file_id} [delete]
+func DeleteVectorStoreFileEndpoint(svc *vectorstores.Service) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		id := c.Param("file_id")
+		if err := svc.DeleteFile(c.Request().Context(), batchUserID(c), c.Param("vector_store_id"), id); err != nil {
+			return vectorStoreAPIError(c, err)
+		}
+		return c.JSON(http.StatusOK, schema.OpenAIDeleteResponse{ID: id, Object: "vector_store.file.deleted", Deleted: true})
+	}
+}
+
+// SearchVectorStoreEndpoint returns the chunks of a vector store closest to
+// a query.
+// @Summary Search a vector store.
+// @Tags vector_stores
+// @Param vector_store_id path string true "Vector store ID"
+// @Param request body schema.VectorStoreSearchRequest true "query params"
+// @Success 200 {object} schema.VectorStoreSearchResponse "Response"
+// @Router /v1/vector_stores/{vector_store_id}/search [post]
+func SearchVectorStoreEndpoint(svc *vectorstores.Service) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var req schema.VectorStoreSearchRequest
+		if err := c.Bind(&req); err != nil {
+			return vectorStoreAPIError(c, fmt.Errorf("%w: %v", vectorstores.ErrInvalidRequest, err))
+		}
+		resp, err := svc.Search(c.Request().Context(), batchUserID(c), c.Param("vector_store_id"), req)
+		if err != nil {
+			return vectorStoreAPIError(c, err)
+		}
+		return c.JSON(http.StatusOK, resp)
+	}
+}
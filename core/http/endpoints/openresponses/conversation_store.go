@@ -0,0 +1,485 @@
+package openresponses
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/xlog"
+	"gorm.io/gorm"
+)
+
+// ErrConversationNotFound is returned for an unknown conversation ID.
+var ErrConversationNotFound = errors.New("conversation not found")
+
+// StoredConversation is a conversation: an ordered list of items that grows
+// with every response created with the conversation parameter.
+type StoredConversation struct {
+	ID        string
+	CreatedAt int64
+	Metadata  map[string]string
+
+	// Owner is the identity that created the conversation; see
+	// StoredResponse.Owner.
+	Owner string
+}
+
+// Resource returns the API representation of the conversation.
+func (c *StoredConversation) Resource() *schema.ORConversation {
+	metadata := c.Metadata
+	if metadata == nil {
+		metadata = map[string]string{}
+	}
+	return &schema.ORConversation{
+		ID:        c.ID,
+		Object:    "conversation",
+		CreatedAt: c.CreatedAt,
+		Metadata:  metadata,
+	}
+}
+
+// conversationAccessAllowed applies the accessAllowed rule to conversations.
+func conversationAccessAllowed(conv *StoredConversation, callerID string) bool {
+	return conv.Owner == "" || conv.Owner == callerID
+}
+
+// conversationBackend holds conversations and their items. Conversations live
+// in memory until the response store is persisted, then in the database so
+// every replica sees the same history.
+//
+// Conversations expire with the responses: once they went unused for the
+// store TTL or, in the database, the persistence retention (see
+// ResponseStore.conversationRetention). The in-memory backend also holds at
+// most maxMemoryConversations, forgetting the least recently used ones.
+type conversationBackend interface {
+	create(conv *StoredConversation, items []schema.ORItemField) error
+	get(id string) (*StoredConversation, error)
+	delete(id string) error
+	appendItems(id string, items []schema.ORItemField) error
+	items(id string) ([]schema.ORItemField, error)
+	listItems(id, after string, limit int, desc bool) ([]schema.ORItemField, bool, error)
+	findItem(itemID string) (*schema.ORItemField, string, bool)
+	// prune deletes the conversations last used before before.
+	prune(before time.Time) (int64, error)
+}
+
+// maxMemoryConversations bounds the conversations held in memory.
+const maxMemoryConversations = 10000
+
+// ConversationStore provides thread-safe storage for conversations.
+type ConversationStore struct {
+	mu      sync.RWMutex
+	backend conversationBackend
+}
+
+// NewConversationStore creates an in-memory conversation store.
+func NewConversationStore() *ConversationStore {
+	return &ConversationStore{backend: &memoryConversations{conversations: map[string]*memoryConversation{}, max: maxMemoryConversations}}
+}
+
+func (s *ConversationStore) store() conversationBackend {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.backend
+}
+
+// usePersistence moves the store onto db. The tables must already exist.
+func (s *ConversationStore) usePersistence(db *gorm.DB) {
+	s.mu.Lock()
+	s.backend = &dbConversations{db: db}
+	s.mu.Unlock()
+}
+
+// Create creates a conversation holding the given initial items.
+func (s *ConversationStore) Create(owner string, metadata map[string]string, items []schema.ORItemField) (*StoredConversation, error) {
+	conv := &StoredConversation{
+		ID:        fmt.Sprintf("conv_%s", uuid.New().String()),
+		CreatedAt: time.Now().Unix(),
+		Metadata:  metadata,
+		Owner:     owner,
+	}
+	if err := s.store().create(conv, items); err != nil {
+		return nil, err
+	}
+	return conv, nil
+}
+
+// Get returns a conversation by ID.
+func (s *ConversationStore) Get(id string) (*StoredConversation, error) {
+	return s.store().get(id)
+}
+
+// Delete removes a conversation and its items.
+func (s *ConversationStore) Delete(id string) error {
+	return s.store().delete(id)
+}
+
+// AppendItems adds items to the end of a conversation.
+func (s *ConversationStore) AppendItems(id string, items []schema.ORItemField) error {
+	if len(items) == 0 {
+		return nil
+	}
+	return s.store().appendItems(id, items)
+}
+
+// Items returns every item of a conversation, oldest first.
+func (s *ConversationStore) Items(id string) ([]schema.ORItemField, error) {
+	return s.store().items(id)
+}
+
+// ListItems returns one page of a conversation's items; see paginateItems.
+func (s *ConversationStore) ListItems(id, after string, limit int, desc bool) ([]schema.ORItemField, bool, error) {
+	return s.store().listItems(id, after, limit, desc)
+}
+
+// FindItem searches every conversation for an item, returning the item and
+// the ID of the conversation holding it.
+func (s *ConversationStore) FindItem(itemID string) (*schema.ORItemField, string, bool) {
+	return s.store().findItem(itemID)
+}
+
+// prune deletes the conversations last used before before.
+func (s *ConversationStore) prune(before time.Time) {
+	n, err := s.store().prune(before)
+	if err != nil {
+		xlog.Warn("Failed to prune expired conversations", "error", err)
+	} else if n > 0 {
+		xlog.Debug("Pruned expired conversations", "count", n)
+	}
+}
+
+// Conversations returns the conversation store backing /v1/conversations.
+func (s *ResponseStore) Conversations() *ConversationStore {
+	return s.conversations
+}
+
+// recordConversationTurn appends the input and output items of a finished
+// response to the conversation it was created in. Failed and cancelled
+// responses leave the conversation untouched, like in OpenAI.
+func (s *ResponseStore) recordConversationTurn(responseID string, request *schema.OpenResponsesRequest, response *schema.ORResponseResource) {
+	if request == nil || response == nil {
+		return
+	}
+	conversationID := request.ConversationID()
+	if conversationID == "" {
+		return
+	}
+	if response.Status != schema.ORStatusCompleted && response.Status != schema.ORStatusIncomplete {
+		return
+	}
+
+	items := append(s.inputItems(responseID, request.Input), response.Output...)
+	if err := s.conversations.AppendItems(conversationID, items); err != nil {
+		xlog.Warn("Failed to append response to conversation", "response_id", responseID, "conversation_id", conversationID, "error", err)
+	}
+}
+
+// --- in-memory backend ---
+
+type memoryConversation struct {
+	conv     *StoredConversation
+	items    []schema.ORItemField
+	lastUsed time.Time
+}
+
+type memoryConversations struct {
+	mu            sync.RWMutex
+	conversations map[string]*memoryConversation
+	max           int
+}
+
+func (m *memoryConversations) create(conv *StoredConversation, items []schema.ORItemField) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for len(m.conversations) >= m.max {
+		oldest := ""
+		for id, c := range m.conversations {
+			if oldest == "" || c.lastUsed.Before(m.conversations[oldest].lastUsed) {
+				oldest = id
+			}
+		}
+		delete(m.conversations, oldest)
+	}
+	m.conversations[conv.ID] = &memoryConversation{conv: conv, items: slices.Clone(items), lastUsed: time.Now()}
+	return nil
+}
+
+func (m *memoryConversations) get(id string) (*StoredConversation, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	c, ok := m.conversations[id]
+	if !ok {
+		return nil, ErrConversationNotFound
+	}
+	return c.conv, nil
+}
+
+func (m *memoryConversations) delete(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.conversations[id]; !ok {
+		return ErrConversationNotFound
+	}
+	delete(m.conversations, id)
+	return nil
+}
+
+func (m *memoryConversations) appendItems(id string, items []schema.ORItemField) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c, ok := m.conversations[id]
+	if !ok {
+		return ErrConversationNotFound
+	}
+	c.items = append(c.items, items...)
+	c.lastUsed = time.Now()
+	return nil
+}
+
+func (m *memoryConversations) items(id string) ([]schema.ORItemField, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	c, ok := m.conversations[id]
+	if !ok {
+		return nil, ErrConversationNotFound
+	}
+	return slices.Clone(c.items), nil
+}
+
+func (m *memoryConversations) listItems(id, after string, limit int, desc bool) ([]schema.ORItemField, bool, error) {
+	items, err := m.items(id)
+	if err != nil {
+		return nil, false, err
+	}
+	return paginateItems(items, after, limit, desc)
+}
+
+func (m *memoryConversations) findItem(itemID string) (*schema.ORItemField, string, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for id, c := range m.conversations {
+		for i := range c.items {
+			if c.items[i].ID == itemID {
+				item := c.items[i]
+				return &item, id, true
+			}
+		}
+	}
+	return nil, "", false
+}
+
+func (m *memoryConversations) prune(before time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var n int64
+	for id, c := range m.conversations {
+		if c.lastUsed.Before(before) {
+			delete(m.conversations, id)
+			n++
+		}
+	}
+	return n, nil
+}
+
+// --- database backend ---
+
+// ConversationRecord is the GORM model for a conversation. UpdatedAt is
+// when items were last appended; rows written before it existed have none
+// and expire from CreatedAt.
+type ConversationRecord struct {
+	ID        string `gorm:"primaryKey;size:64"`
+	Owner     string `gorm:"index;size:255"`
+	Metadata  string `gorm:"type:text"`
+	CreatedAt time.Time
+	UpdatedAt *time.Time `gorm:"index"`
+}
+
+func (ConversationRecord) TableName() string { return "open_conversations" }
+
+// ConversationItemRecord is one item of a conversation. The auto-increment ID
+// orders the items and serves as the pagination cursor.
+type ConversationItemRecord struct {
+	ID             uint   `gorm:"primaryKey;autoIncrement"`
+	ConversationID string `gorm:"index;size:64"`
+	ItemID         string `gorm:"index;size:255"`
+	Data           string `gorm:"type:text"`
+}
+
+func (ConversationItemRecord) TableName() string { return "open_conversation_items" }
+
+type dbConversations struct {
+	db *gorm.DB
+}
+
+func itemRecords(conversationID string, items []schema.ORItemField) ([]ConversationItemRecord, error) {
+	recs := make([]ConversationItemRecord, 0, len(items))
+	for _, item := range items {
+		data, err := json.Marshal(item)
+		if err != nil {
+			return nil, err
+		}
+		recs = append(recs, ConversationItemRecord{ConversationID: conversationID, ItemID: item.ID, Data: string(data)})
+	}
+	return recs, nil
+}
+
+func decodeItemRecords(recs []ConversationItemRecord) []schema.ORItemField {
+	items := make([]schema.ORItemField, 0, len(recs))
+	for _, r := range recs {
+		var item schema.ORItemField
+		if err := json.Unmarshal([]byte(r.Data), &item); err != nil {
+			continue
+		}
+		restoreContentParts(&item)
+		items = append(items, item)
+	}
+	return items
+}
+
+func (d *dbConversations) create(conv *StoredConversation, items []schema.ORItemField) error {
+	metadata, err := json.Marshal(conv.Metadata)
+	if err != nil {
+		return err
+	}
+	recs, err := itemRecords(conv.ID, items)
+	if err != nil {
+		return err
+	}
+	return d.db.Transaction(func(tx *gorm.DB) error {
+		createdAt := time.Unix(conv.CreatedAt, 0)
+		if err := tx.Create(&ConversationRecord{
+			ID:        conv.ID,
+			Owner:     conv.Owner,
+			Metadata:  string(metadata),
+			CreatedAt: createdAt,
+			UpdatedAt: &createdAt,
+		}).Error; err != nil {
+			return err
+		}
+		if len(recs) == 0 {
+			return nil
+		}
+		return tx.Create(&recs).Error
+	})
+}
+
+func (d *dbConversations) get(id string) (*StoredConversation, error) {
+	var rec ConversationRecord
+	if err := d.db.Where("id = ?", id).First(&rec).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrConversationNotFound
+		}
+		return nil, err
+	}
+	conv := &StoredConversation{ID: rec.ID, CreatedAt: rec.CreatedAt.Unix(), Owner: rec.Owner}
+	if rec.Metadata != "" {
+		_ = json.Unmarshal([]byte(rec.Metadata), &conv.Metadata)
+	}
+	return conv, nil
+}
+
+func (d *dbConversations) delete(id string) error {
+	return d.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Where("id = ?", id).Delete(&ConversationRecord{})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrConversationNotFound
+		}
+		return tx.Where("conversation_id = ?", id).Delete(&ConversationItemRecord{}).Error
+	})
+}
+
+func (d *dbConversations) appendItems(id string, items []schema.ORItemField) error {
+	if _, err := d.get(id); err != nil {
+		return err
+	}
+	recs, err := itemRecords(id, items)
+	if err != nil {
+		return err
+	}
+	return d.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&recs).Error; err != nil {
+			return err
+		}
+		return tx.Model(&ConversationRecord{}).Where("id = ?", id).Update("updated_at", time.Now()).Error
+	})
+}
+
+func (d *dbConversations) items(id string) ([]schema.ORItemField, error) {
+	if _, err := d.get(id); err != nil {
+		return nil, err
+	}
+	var recs []ConversationItemRecord
+	if err := d.db.Where("conversation_id = ?", id).Order("id ASC").Find(&recs).Error; err != nil {
+		return nil, err
+	}
+	return decodeItemRecords(recs), nil
+}
+
+func (d *dbConversations) listItems(id, after string, limit int, desc bool) ([]schema.ORItemField, bool, error) {
+	if _, err := d.get(id); err != nil {
+		return nil, false, err
+	}
+	q := d.db.Where("conversation_id = ?", id)
+	if after != "" {
+		var cursor ConversationItemRecord
+		if err := d.db.Where("conversation_id = ? AND item_id = ?", id, after).First(&cursor).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, false, fmt.Errorf("%w: %s", ErrCursorNotFound, after)
+			}
+			return nil, false, err
+		}
+		if desc {
+			q = q.Where("id < ?", cursor.ID)
+		} else {
+			q = q.Where("id > ?", cursor.ID)
+		}
+	}
+	order := "id ASC"
+	if desc {
+		order = "id DESC"
+	}
+	var recs []ConversationItemRecord
+	if err := q.Order(order).Limit(limit + 1).Find(&recs).Error; err != nil {
+		return nil, false, err
+	}
+	hasMore := len(recs) > limit
+	if hasMore {
+		recs = recs[:limit]
+	}
+	return decodeItemRecords(recs), hasMore, nil
+}
+
+func (d *dbConversations) findItem(itemID string) (*schema.ORItemField, string, bool) {
+	var rec ConversationItemRecord
+	if err := d.db.Where("item_id = ?", itemID).Order("id DESC").First(&rec).Error; err != nil {
+		return nil, "", false
+	}
+	items := decodeItemRecords([]ConversationItemRecord{rec})
+	if len(items) == 0 {
+		return nil, "", false
+	}
+	return &items[0], rec.ConversationID, true
+}
+
+func (d *dbConversations) prune(before time.Time) (int64, error) {
+	var deleted int64
+	err := d.db.Transaction(func(tx *gorm.DB) error {
+		expired := "COALESCE(updated_at, created_at) < ?"
+		if err := tx.Where("conversation_id IN (?)", tx.Model(&ConversationRecord{}).Select("id").Where(expired, before)).
+			Delete(&ConversationItemRecord{}).Error; err != nil {
+			return err
+		}
+		res := tx.Where(expired, before).Delete(&ConversationRecord{})
+		deleted = res.RowsAffected
+		return res.Error
+	})
+	return deleted, err
+}
@@ -0,0 +1,207 @@
+package openresponses
+
+import (
+	"time"
+
+	"github.com/mudler/LocalAI/core/schema"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Conversations", func() {
+	var store *ResponseStore
+
+	BeforeEach(func() {
+		store = NewResponseStore(0)
+	})
+
+	itemIDs := func(items []schema.ORItemField) []string {
+		ids := make([]string, 0, len(items))
+		for _, item := range items {
+			ids = append(ids, item.ID)
+		}
+		return ids
+	}
+
+	completed := func(id, text string) *schema.ORResponseResource {
+		return &schema.ORResponseResource{
+			ID:     id,
+			Object: "response",
+			Status: schema.ORStatusCompleted,
+			Output: []schema.ORItemField{{
+				Type:    "message",
+				ID:      "msg_out_" + id,
+				Status:  "completed",
+				Role:    "assistant",
+				Content: []schema.ORContentPart{makeOutputTextPart(text)},
+			}},
+		}
+	}
+
+	Describe("input items", func() {
+		It("derives stable IDs for items sent without one", func() {
+			request := &schema.OpenResponsesRequest{Model: "m", Input: []any{
+				map[string]any{"role": "user", "content": "first"},
+				map[string]any{"type": "message", "id": "msg_client", "role": "user", "content": "second"},
+			}}
+			store.Store("resp_abc", request, completed("resp_abc", "ok"))
+
+			items, err := store.InputItems("resp_abc")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(itemIDs(items)).To(Equal([]string{"msg_abc_0", "msg_client"}))
+			Expect(items[0].Type).To(Equal("message"))
+			Expect(items[0].Content).To(Equal([]schema.ORContentPart{{Type: "input_text", Text: "first"}}))
+
+			again, err := store.InputItems("resp_abc")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(itemIDs(again)).To(Equal(itemIDs(items)))
+		})
+
+		It("turns a string input into a user message", func() {
+			store.Store("resp_abc", &schema.OpenResponsesRequest{Model: "m", Input: "hello"}, completed("resp_abc", "ok"))
+
+			items, err := store.InputItems("resp_abc")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(items).To(HaveLen(1))
+			Expect(items[0].Role).To(Equal("user"))
+		})
+
+		It("resolves item references", func() {
+			store.Store("resp_1", &schema.OpenResponsesRequest{Model: "m", Input: "hi"}, completed("resp_1", "hello"))
+			store.Store("resp_2", &schema.OpenResponsesRequest{Model: "m", Input: []any{
+				map[string]any{"type": "item_reference", "id": "msg_out_resp_1"},
+			}}, completed("resp_2", "again"))
+
+			items, err := store.InputItems("resp_2")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(items).To(HaveLen(1))
+			Expect(items[0].Role).To(Equal("assistant"))
+		})
+	})
+
+	Describe("pagination", func() {
+		items := []schema.ORItemField{{ID: "a"}, {ID: "b"}, {ID: "c"}, {ID: "d"}}
+
+		It("pages in ascending order", func() {
+			page, hasMore, err := paginateItems(items, "", 2, false)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(itemIDs(page)).To(Equal([]string{"a", "b"}))
+			Expect(hasMore).To(BeTrue())
+
+			page, hasMore, err = paginateItems(items, "b", 2, false)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(itemIDs(page)).To(Equal([]string{"c", "d"}))
+			Expect(hasMore).To(BeFalse())
+		})
+
+		It("pages in descending order", func() {
+			page, hasMore, err := paginateItems(items, "c", 10, true)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(itemIDs(page)).To(Equal([]string{"b", "a"}))
+			Expect(hasMore).To(BeFalse())
+		})
+
+		It("rejects an unknown cursor", func() {
+			_, _, err := paginateItems(items, "zz", 10, false)
+			Expect(err).To(MatchError(ErrCursorNotFound))
+		})
+	})
+
+	Describe("conversation store", func() {
+		It("appends each completed turn to the conversation", func() {
+			conv, err := store.Conversations().Create("user-1", map[string]string{"topic": "test"}, normalizeNewItems([]schema.ORItemParam{
+				{Role: "system", Content: "be brief"},
+			}))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(conv.Resource().Object).To(Equal("conversation"))
+
+			request := &schema.OpenResponsesRequest{Model: "m", Input: "hi", Conversation: map[string]any{"id": conv.ID}}
+			store.Store("resp_1", request, completed("resp_1", "hello"))
+
+			items, err := store.Conversations().Items(conv.ID)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(items).To(HaveLen(3))
+			Expect(items[0].Role).To(Equal("system"))
+			Expect(items[1].ID).To(Equal("msg_1_0"))
+			Expect(items[2].ID).To(Equal("msg_out_resp_1"))
+
+			messages, err := convertORItemsToMessages(items, conv.ID)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(messages).To(HaveLen(3))
+			Expect(messages[2].StringContent).To(Equal("hello"))
+
+			page, hasMore, err := store.Conversations().ListItems(conv.ID, "", 1, true)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(itemIDs(page)).To(Equal([]string{"msg_out_resp_1"}))
+			Expect(hasMore).To(BeTrue())
+		})
+
+		It("skips failed responses", func() {
+			conv, err := store.Conversations().Create("", nil, nil)
+			Expect(err).ToNot(HaveOccurred())
+
+			failed := completed("resp_1", "partial")
+			failed.Status = schema.ORStatusFailed
+			store.Store("resp_1", &schema.OpenResponsesRequest{Model: "m", Input: "hi", Conversation: conv.ID}, failed)
+
+			items, err := store.Conversations().Items(conv.ID)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(items).To(BeEmpty())
+		})
+
+		It("makes conversation items referenceable", func() {
+			conv, err := store.Conversations().Create("", nil, normalizeNewItems([]schema.ORItemParam{
+				{ID: "msg_seed", Role: "user", Content: "seed"},
+			}))
+			Expect(err).ToNot(HaveOccurred())
+
+			item, sourceID, err := store.FindItem("msg_seed")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(sourceID).To(Equal(conv.ID))
+			Expect(item.Role).To(Equal("user"))
+		})
+
+		It("deletes conversations", func() {
+			conv, err := store.Conversations().Create("", nil, nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(store.Conversations().Delete(conv.ID)).To(Succeed())
+
+			_, err = store.Conversations().Get(conv.ID)
+			Expect(err).To(MatchError(ErrConversationNotFound))
+			Expect(store.Conversations().Delete(conv.ID)).To(MatchError(ErrConversationNotFound))
+		})
+
+		It("forgets conversations unused for the store TTL", func() {
+			store = NewResponseStore(time.Hour)
+			DeferCleanup(store.SetTTL, time.Duration(0))
+			idle, err := store.Conversations().Create("", nil, nil)
+			Expect(err).ToNot(HaveOccurred())
+			active, err := store.Conversations().Create("", nil, nil)
+			Expect(err).ToNot(HaveOccurred())
+
+			backend := store.Conversations().store().(*memoryConversations)
+			backend.conversations[idle.ID].lastUsed = time.Now().Add(-2 * time.Hour)
+			backend.conversations[active.ID].lastUsed = time.Now().Add(-2 * time.Hour)
+			store.Store("resp_1", &schema.OpenResponsesRequest{Model: "m", Input: "hi", Conversation: active.ID}, completed("resp_1", "hello"))
+
+			store.Cleanup()
+			_, err = store.Conversations().Get(idle.ID)
+			Expect(err).To(MatchError(ErrConversationNotFound))
+			_, err = store.Conversations().Get(active.ID)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("holds a bounded number of conversations in memory", func() {
+			backend := &memoryConversations{conversations: map[string]*memoryConversation{}, max: 2}
+			for _, id := range []string{"conv_a", "conv_b"} {
+				Expect(backend.create(&StoredConversation{ID: id}, nil)).To(Succeed())
+			}
+			backend.conversations["conv_a"].lastUsed = time.Now().Add(time.Minute)
+
+			Expect(backend.create(&StoredConversation{ID: "conv_c"}, nil)).To(Succeed())
+			Expect(backend.conversations).To(HaveLen(2))
+			Expect(backend.conversations).To(HaveKey("conv_a"))
+			Expect(backend.conversations).To(HaveKey("conv_c"))
+		})
+	})
+})
@@ -0,0 +1,154 @@
+package openresponses
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/labstack/echo/v4"
+	"github.com/mudler/LocalAI/core/schema"
+)
+
+// conversationFromRequest loads the conversation named by the :id path
+// parameter. When it does not exist or belongs to another caller, a 404 is
+// written and the conversation is nil; err is then the result of writing it.
+func conversationFromRequest(c echo.Context) (*StoredConversation, error) {
+	conversationID := c.Param("id")
+	conv, err := GetGlobalStore().Conversations().Get(conversationID)
+	// Return 404 (not 403) on owner mismatch so existence is not leaked.
+	if err != nil || !conversationAccessAllowed(conv, ownerFromContext(c)) {
+		return nil, sendOpenResponsesError(c, 404, "not_found", fmt.Sprintf("conversation not found: %s", conversationID), "id")
+	}
+	return conv, nil
+}
+
+// normalizeNewItems assigns IDs to items added to a conversation directly.
+func normalizeNewItems(items []schema.ORItemParam) []schema.ORItemField {
+	out := make([]schema.ORItemField, 0, len(items))
+	for _, item := range items {
+		out = append(out, normalizeItem(item, newItemID))
+	}
+	return out
+}
+
+// CreateConversationEndpoint returns a handler for POST /conversations
+// @Summary Create a conversation
+// @Tags inference
+// @Param request body schema.ORConversationRequest true "query params"
+// @Success 200 {object} schema.ORConversation "Conversation"
+// @Failure 400 {object} map[string]any "Bad Request"
+// @Router /v1/conversations [post]
+func CreateConversationEndpoint() func(c echo.Context) error {
+	return func(c echo.Context) error {
+		var req schema.ORConversationRequest
+		if err := c.Bind(&req); err != nil {
+			return sendOpenResponsesError(c, 400, "invalid_request_error", fmt.Sprintf("invalid request body: %v", err), "")
+		}
+
+		conv, err := GetGlobalStore().Conversations().Create(ownerFromContext(c), req.Metadata, normalizeNewItems(req.Items))
+		if err != nil {
+			return sendOpenResponsesError(c, 500, "server_error", fmt.Sprintf("failed to create conversation: %v", err), "")
+		}
+		return c.JSON(200, conv.Resource())
+	}
+}
+
+// GetConversationEndpoint returns a handler for GET /conversations/:id
+// @Summary Get a conversation by ID
+// @Tags inference
+// @Param id path string true "Conversation ID"
+// @Success 200 {object} schema.ORConversation "Conversation"
+// @Failure 404 {object} map[string]any "Not Found"
+// @Router /v1/conversations/{id} [get]
+func GetConversationEndpoint() func(c echo.Context) error {
+	return func(c echo.Context) error {
+		conv, err := conversationFromRequest(c)
+		if conv == nil {
+			return err
+		}
+		return c.JSON(200, conv.Resource())
+	}
+}
+
+// DeleteConversationEndpoint returns a handler for DELETE /conversations/:id
+// @Summary Delete a conversation
+// @Tags inference
+// @Param id path string true "Conversation ID"
+// @Success 200 {object} schema.ORConversationDeleted "Deleted"
+// @Failure 404 {object} map[string]any "Not Found"
+// @Router /v1/conversations/{id} [delete]
+func DeleteConversationEndpoint() func(c echo.Context) error {
+	return func(c echo.Context) error {
+		conv, err := conversationFromRequest(c)
+		if conv == nil {
+			return err
+		}
+		if err := GetGlobalStore().Conversations().Delete(conv.ID); err != nil && !errors.Is(err, ErrConversationNotFound) {
+			return sendOpenResponsesError(c, 500, "server_error", fmt.Sprintf("failed to delete conversation: %v", err), "")
+		}
+		return c.JSON(200, schema.ORConversationDeleted{ID: conv.ID, Object: "conversation.deleted", Deleted: true})
+	}
+}
+
+// ListConversationItemsEndpoint returns a handler for GET /conversations/:id/items
+// @Summary List the items of a conversation
+// @Tags inference
+// @Param id path string true "Conversation ID"
+// @Param limit query int false "Number of items to return (1-100, default 20)"
+// @Param order query string false "asc or desc (default desc)"
+// @Param after query string false "Item ID to list after"
+// @Success 200 {object} schema.ORItemList "Items"
+// @Failure 400 {object} map[string]any "Bad Request"
+// @Failure 404 {object} map[string]any "Not Found"
+// @Router /v1/conversations/{id}/items [get]
+func ListConversationItemsEndpoint() func(c echo.Context) error {
+	return func(c echo.Context) error {
+		limit, desc, after, err := itemListParams(c)
+		if err != nil {
+			return sendOpenResponsesError(c, 400, "invalid_request_error", err.Error(), "")
+		}
+		conv, err := conversationFromRequest(c)
+		if conv == nil {
+			return err
+		}
+
+		items, hasMore, err := GetGlobalStore().Conversations().ListItems(conv.ID, after, limit, desc)
+		if err != nil {
+			if errors.Is(err, ErrCursorNotFound) {
+				return sendOpenResponsesError(c, 400, "invalid_request_error", err.Error(), "after")
+			}
+			return sendOpenResponsesError(c, 500, "server_error", fmt.Sprintf("failed to list conversation items: %v", err), "")
+		}
+		return c.JSON(200, newItemList(items, hasMore))
+	}
+}
+
+// CreateConversationItemsEndpoint returns a handler for POST /conversations/:id/items
+// @Summary Add items to a conversation
+// @Tags inference
+// @Param id path string true "Conversation ID"
+// @Param request body schema.ORConversationItemsRequest true "query params"
+// @Success 200 {object} schema.ORItemList "Added items"
+// @Failure 400 {object} map[string]any "Bad Request"
+// @Failure 404 {object} map[string]any "Not Found"
+// @Router /v1/conversations/{id}/items [post]
+func CreateConversationItemsEndpoint() func(c echo.Context) error {
+	return func(c echo.Context) error {
+		var req schema.ORConversationItemsRequest
+		if err := c.Bind(&req); err != nil {
+			return sendOpenResponsesError(c, 400, "invalid_request_error", fmt.Sprintf("invalid request body: %v", err), "")
+		}
+		if len(req.Items) == 0 {
+			return sendOpenResponsesError(c, 400, "invalid_request_error", "items must not be empty", "items")
+		}
+		conv, err := conversationFromRequest(c)
+		if conv == nil {
+			return err
+		}
+
+		items := normalizeNewItems(req.Items)
+		if err := GetGlobalStore().Conversations().AppendItems(conv.ID, items); err != nil {
+			return sendOpenResponsesError(c, 500, "server_error", fmt.Sprintf("failed to add conversation items: %v", err), "")
+		}
+		return c.JSON(200, newItemList(items, false))
+	}
+}
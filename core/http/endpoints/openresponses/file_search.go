@@ -0,0 +1,148 @@
+package openresponses
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	mcpTools "github.com/mudler/LocalAI/core/http/endpoints/mcp"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/pkg/functions"
+)
+
+// fileSearchToolName is the function the model calls to run a file_search
+// tool.
+const fileSearchToolName = "file_search"
+
+// FileSearcher searches the vector stores a file_search tool names. It is
+// implemented by the vector stores service.
+type FileSearcher interface {
+	Search(ctx context.Context, userID, vectorStoreID string, req schema.VectorStoreSearchRequest) (*schema.VectorStoreSearchResponse, error)
+}
+
+// fileSearchExecutor runs file_search calls server-side and hands every other
+// tool to the MCP executor it wraps (nil when there is none), so the MCP tool
+// loops of the handlers execute both.
+type fileSearchExecutor struct {
+	next           mcpTools.ToolExecutor
+	searcher       FileSearcher
+	userID         string
+	vectorStoreIDs []string
+	maxNumResults  *int
+}
+
+var fileSearchFunction = functions.Function{
+	Name:        fileSearchToolName,
+	Description: "Search the files the user uploaded for passages relevant to a query.",
+	Parameters: map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"query": map[string]any{
+				"type":        "string",
+				"description": "What to search for.",
+			},
+		},
+		"required": []string{"query"},
+	},
+}
+
+// withFileSearch adds the file_search tool of a request, if any, to funcs and
+// returns the executor running it. Without a file_search tool (or without a
+// vector stores service) funcs and executor are returned unchanged.
+func withFileSearch(input *schema.OpenResponsesRequest, searcher FileSearcher, userID string, funcs functions.Functions, executor mcpTools.ToolExecutor) (functions.Functions, mcpTools.ToolExecutor) {
+	if searcher == nil || input.ToolChoice == "none" {
+		return funcs, executor
+	}
+	if len(input.AllowedTools) > 0 && !slices.Contains(input.AllowedTools, fileSearchToolName) {
+		return funcs, executor
+	}
+	idx := slices.IndexFunc(input.Tools, func(t schema.ORFunctionTool) bool { return t.Type == "file_search" })
+	if idx < 0 || len(input.Tools[idx].VectorStoreIDs) == 0 {
+		return funcs, executor
+	}
+	tool := input.Tools[idx]
+	return append(funcs, fileSearchFunction), &fileSearchExecutor{
+		next:           executor,
+		searcher:       searcher,
+		userID:         userID,
+		vectorStoreIDs: tool.VectorStoreIDs,
+		maxNumResults:  tool.MaxNumResults,
+	}
+}
+
+func (e *fileSearchExecutor) DiscoverTools(ctx context.Context) ([]functions.Function, error) {
+	fns := []functions.Function{fileSearchFunction}
+	if e.next == nil {
+		return fns, nil
+	}
+	more, err := e.next.DiscoverTools(ctx)
+	return append(fns, more...), err
+}
+
+func (e *fileSearchExecutor) IsTool(name string) bool {
+	return name == fileSearchToolName || (e.next != nil && e.next.IsTool(name))
+}
+
+func (e *fileSearchExecutor) HasTools() bool { return true }
+
+func (e *fileSearchExecutor) ExecuteTool(ctx context.Context, toolName, arguments string) (string, error) {
+	if toolName != fileSearchToolName {
+		if e.next == nil {
+			return "", fmt.Errorf("unknown tool %q", toolName)
+		}
+		return e.next.ExecuteTool(ctx, toolName, arguments)
+	}
+
+	var args struct {
+		Query string `json:"query"`
+	}
+	if err := json.Unmarshal([]byte(arguments), &args); err != nil || strings.TrimSpace(args.Query) == "" {
+		return "", fmt.Errorf("file_search expects a JSON object with a non-empty query")
+	}
+	req := schema.VectorStoreSearchRequest{Query: schema.VectorStoreSearchQuery{args.Query}, MaxNumResults: e.maxNumResults}
+
+	var results []schema.VectorStoreSearchResult
+	for _, id := range e.vectorStoreIDs {
+		resp, err := e.searcher.Search(ctx, e.userID, id, req)
+		if err != nil {
+			return "", fmt.Errorf("searching vector store %s: %w", id, err)
+		}
+		results = append(results, resp.Data...)
+	}
+	slices.SortStableFunc(results, func(a, b schema.VectorStoreSearchResult) int {
+		return cmp.Compare(b.Score, a.Score)
+	})
+	if limit := cmp.Or(derefInt(e.maxNumResults), 10); len(results) > limit {
+		results = results[:limit]
+	}
+	return formatFileSearchResults(results), nil
+}
+
+// formatFileSearchResults renders search results as the tool output the
+// model reads.
+func formatFileSearchResults(results []schema.VectorStoreSearchResult) string {
+	if len(results) == 0 {
+		return "No relevant passages found."
+	}
+	var b strings.Builder
+	for i, r := range results {
+		if i > 0 {
+			b.WriteString("\n\n")
+		}
+		fmt.Fprintf(&b, "[%d] %s (file_id: %s, score: %.3f)\n", i+1, cmp.Or(r.Filename, r.FileID), r.FileID, r.Score)
+		for _, c := range r.Content {
+			b.WriteString(c.Text)
+		}
+	}
+	return b.String()
+}
+
+func derefInt(p *int) int {
+	if p == nil {
+		return 0
+	}
+	return *p
+}
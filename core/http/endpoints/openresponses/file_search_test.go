@@ -0,0 +1,76 @@
+package openresponses
+
+import (
+	"context"
+
+	"github.com/mudler/LocalAI/core/schema"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// fakeSearcher returns one result per vector store, with the score scores
+// assigns to it, and records the user it searched for.
+type fakeSearcher struct {
+	scores map[string]float64
+	userID string
+}
+
+func (f *fakeSearcher) Search(_ context.Context, userID, vectorStoreID string, req schema.VectorStoreSearchRequest) (*schema.VectorStoreSearchResponse, error) {
+	f.userID = userID
+	return &schema.VectorStoreSearchResponse{Data: []schema.VectorStoreSearchResult{{
+		FileID:   "file-" + vectorStoreID,
+		Filename: vectorStoreID + ".txt",
+		Score:    f.scores[vectorStoreID],
+		Content:  []schema.VectorStoreSearchContent{{Type: "text", Text: "about " + req.Query[0] + " in " + vectorStoreID}},
+	}}}, nil
+}
+
+var _ = Describe("file_search tool", func() {
+	searcher := &fakeSearcher{scores: map[string]float64{"vs_a": 0.2, "vs_b": 0.9}}
+
+	request := func() *schema.OpenResponsesRequest {
+		return &schema.OpenResponsesRequest{Tools: []schema.ORFunctionTool{
+			{Type: "function", Name: "lookup"},
+			{Type: "file_search", VectorStoreIDs: []string{"vs_a", "vs_b"}},
+		}}
+	}
+
+	It("exposes file_search as a server-side tool", func() {
+		funcs, executor := withFileSearch(request(), searcher, "alice", nil, nil)
+		Expect(funcs).To(HaveLen(1))
+		Expect(funcs[0].Name).To(Equal(fileSearchToolName))
+		Expect(executor.IsTool(fileSearchToolName)).To(BeTrue())
+		Expect(executor.IsTool("lookup")).To(BeFalse())
+
+		out, err := executor.ExecuteTool(context.Background(), fileSearchToolName, `{"query":"tides"}`)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(searcher.userID).To(Equal("alice"))
+		Expect(out).To(Equal("[1] vs_b.txt (file_id: file-vs_b, score: 0.900)\nabout tides in vs_b\n\n" +
+			"[2] vs_a.txt (file_id: file-vs_a, score: 0.200)\nabout tides in vs_a"))
+
+		_, err = executor.ExecuteTool(context.Background(), fileSearchToolName, `{}`)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("caps the results at max_num_results", func() {
+		req := request()
+		one := 1
+		req.Tools[1].MaxNumResults = &one
+		_, executor := withFileSearch(req, searcher, "", nil, nil)
+		out, err := executor.ExecuteTool(context.Background(), fileSearchToolName, `{"query":"tides"}`)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(out).ToNot(ContainSubstring("vs_a"))
+	})
+
+	It("is left out when not requested or not available", func() {
+		req := request()
+		req.ToolChoice = "none"
+		funcs, executor := withFileSearch(req, searcher, "", nil, nil)
+		Expect(funcs).To(BeEmpty())
+		Expect(executor).To(BeNil())
+
+		funcs, executor = withFileSearch(request(), nil, "", nil, nil)
+		Expect(funcs).To(BeEmpty())
+		Expect(executor).To(BeNil())
+	})
+})
@@ -0,0 +1,169 @@
+package openresponses
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/mudler/LocalAI/core/schema"
+)
+
+const (
+	defaultItemListLimit = 20
+	maxItemListLimit     = 100
+)
+
+// ErrCursorNotFound is returned when the `after` cursor of an item listing
+// does not name an item of the listed collection.
+var ErrCursorNotFound = errors.New("cursor item not found")
+
+// itemIDPrefix returns the ID prefix OpenAI uses for an item type.
+func itemIDPrefix(itemType string) string {
+	switch itemType {
+	case "message":
+		return "msg"
+	case "function_call":
+		return "fc"
+	case "function_call_output":
+		return "fco"
+	case "reasoning":
+		return "rs"
+	default:
+		return "item"
+	}
+}
+
+// newItemID generates a random ID for an item created outside a response,
+// e.g. one added to a conversation directly.
+func newItemID(itemType string) string {
+	return fmt.Sprintf("%s_%s", itemIDPrefix(itemType), strings.ReplaceAll(uuid.New().String(), "-", ""))
+}
+
+// normalizeItem turns a client-supplied item into the shape the API returns:
+// typed, with an ID (from newID when it has none), and with message content
+// as content parts.
+func normalizeItem(item schema.ORItemField, newID func(itemType string) string) schema.ORItemField {
+	if item.Type == "" && item.Role != "" {
+		item.Type = "message"
+	}
+	if item.ID == "" {
+		item.ID = newID(item.Type)
+	}
+	if item.Type == "message" {
+		if item.Status == "" {
+			item.Status = schema.ORStatusCompleted
+		}
+		if text, ok := item.Content.(string); ok {
+			part := schema.ORContentPart{Type: "input_text", Text: text}
+			if item.Role == "assistant" {
+				part = makeOutputTextPart(text)
+			}
+			item.Content = []schema.ORContentPart{part}
+		}
+	}
+	restoreContentParts(&item)
+	return item
+}
+
+// inputItems returns the input of a request as items. Items sent without an
+// ID get one derived from the response ID and their position, so the same
+// request always yields the same IDs: on this replica, on a peer that only
+// has the replicated request, and after a restart. Item references are
+// resolved to the item they point at.
+func (s *ResponseStore) inputItems(responseID string, input any) []schema.ORItemField {
+	suffix := strings.TrimPrefix(responseID, "resp_")
+	derivedID := func(position int) func(string) string {
+		return func(itemType string) string {
+			return fmt.Sprintf("%s_%s_%d", itemIDPrefix(itemType), suffix, position)
+		}
+	}
+
+	switch v := input.(type) {
+	case string:
+		return []schema.ORItemField{
+			normalizeItem(schema.ORItemField{Type: "message", Role: "user", Content: v}, derivedID(0)),
+		}
+	case []any:
+		items := make([]schema.ORItemField, 0, len(v))
+		for i, raw := range v {
+			data, err := json.Marshal(raw)
+			if err != nil {
+				continue
+			}
+			var item schema.ORItemField
+			if err := json.Unmarshal(data, &item); err != nil {
+				continue
+			}
+			if item.Type == "item_reference" {
+				if ref, _, err := s.FindItem(item.ID); err == nil {
+					items = append(items, *ref)
+					continue
+				}
+			}
+			items = append(items, normalizeItem(item, derivedID(i)))
+		}
+		return items
+	default:
+		return nil
+	}
+}
+
+// paginateItems returns the page of items following the item with ID after
+// (from the start when empty), in ascending or descending order, and whether
+// more items follow the page.
+func paginateItems(items []schema.ORItemField, after string, limit int, desc bool) ([]schema.ORItemField, bool, error) {
+	ordered := slices.Clone(items)
+	if desc {
+		slices.Reverse(ordered)
+	}
+	if after != "" {
+		idx := slices.IndexFunc(ordered, func(item schema.ORItemField) bool { return item.ID == after })
+		if idx < 0 {
+			return nil, false, fmt.Errorf("%w: %s", ErrCursorNotFound, after)
+		}
+		ordered = ordered[idx+1:]
+	}
+	if len(ordered) > limit {
+		return ordered[:limit], true, nil
+	}
+	return ordered, false, nil
+}
+
+// newItemList wraps a page of items in the list object.
+func newItemList(items []schema.ORItemField, hasMore bool) *schema.ORItemList {
+	list := &schema.ORItemList{Object: "list", Data: items, HasMore: hasMore}
+	if list.Data == nil {
+		list.Data = []schema.ORItemField{}
+	}
+	if len(items) > 0 {
+		list.FirstID = &items[0].ID
+		list.LastID = &items[len(items)-1].ID
+	}
+	return list
+}
+
+// itemListParams reads the limit, order and after query parameters shared by
+// the item listing endpoints. Order defaults to descending, like OpenAI.
+func itemListParams(c echo.Context) (limit int, desc bool, after string, err error) {
+	limit = defaultItemListLimit
+	if v := c.QueryParam("limit"); v != "" {
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxItemListLimit {
+			return 0, false, "", fmt.Errorf("limit must be an integer between 1 and %d", maxItemListLimit)
+		}
+	}
+	switch c.QueryParam("order") {
+	case "", "desc":
+		desc = true
+	case "asc":
+		desc = false
+	default:
+		return 0, false, "", errors.New("order must be 'asc' or 'desc'")
+	}
+	return limit, desc, c.QueryParam("after"), nil
+}
@@ -0,0 +1,506 @@
+package openresponses
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/core/services/advisorylock"
+	"github.com/mudler/xlog"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// eventFlushInterval bounds how long a streamed event may sit in the
+	// write-behind buffer before it is persisted. Events are produced per
+	// token, so writing them one row at a time would put the database on the
+	// generation hot path.
+	eventFlushInterval = 250 * time.Millisecond
+
+	// pruneInterval is how often expired rows are deleted.
+	pruneInterval = 10 * time.Minute
+)
+
+// ResponseRecord is the GORM model for a stored Open Responses response.
+// Request and response are kept as JSON: they are only ever read back whole,
+// and the schema types evolve faster than a column layout could.
+type ResponseRecord struct {
+	ID            string     `gorm:"primaryKey;size:64"`
+	Owner         string     `gorm:"index;size:255"`
+	OwnerReplica  string     `gorm:"size:255"`
+	Status        string     `gorm:"index;size:32"`
+	RequestJSON   string     `gorm:"column:request;type:text"`
+	ResponseJSON  string     `gorm:"column:response;type:text"`
+	StreamEnabled bool       `gorm:"default:false"`
+	IsBackground  bool       `gorm:"default:false"`
+	StoredAt      time.Time  `gorm:"index"`
+	ExpiresAt     *time.Time `gorm:"index"`
+	UpdatedAt     time.Time
+}
+
+func (ResponseRecord) TableName() string { return "open_responses" }
+
+// ResponseItemRecord indexes the output items of a stored response so an
+// item_reference can be resolved without scanning every response.
+type ResponseItemRecord struct {
+	ID         uint   `gorm:"primaryKey;autoIncrement"`
+	ResponseID string `gorm:"index;size:64"`
+	ItemID     string `gorm:"index;size:255"`
+	Position   int
+	Data       string `gorm:"type:text"`
+}
+
+func (ResponseItemRecord) TableName() string { return "open_response_items" }
+
+// ResponseEventRecord is one buffered SSE event of a streamed response, kept
+// so a stream can be resumed with starting_after after a restart.
+type ResponseEventRecord struct {
+	ID             uint   `gorm:"primaryKey;autoIncrement"`
+	ResponseID     string `gorm:"index:idx_open_response_events_seq,priority:1;size:64"`
+	SequenceNumber int    `gorm:"index:idx_open_response_events_seq,priority:2"`
+	EventType      string `gorm:"size:128"`
+	Data           string `gorm:"type:text"`
+}
+
+func (ResponseEventRecord) TableName() string { return "open_response_events" }
+
+// responsePersistence is the database leg of a ResponseStore. The in-memory
+// map stays the source of truth for responses this process is generating;
+// the database is written through on every state change and read only on a
+// local (and replicated) miss.
+type responsePersistence struct {
+	db        *gorm.DB
+	retention time.Duration
+
+	mu      sync.Mutex
+	pending []ResponseEventRecord
+	// flushMu serializes flushes so events of one response are inserted in
+	// sequence order even when a flush is forced from a request goroutine.
+	flushMu sync.Mutex
+}
+
+// EnablePersistence makes the store write every response, its items and its
+// streamed events to db, so previous_response_id chains, polling and stream
+// resume survive a restart or rolling upgrade. Conversations move to db too.
+//
+// Rows expire with the store TTL when one is set, otherwise after retention
+// (0 keeps them forever); conversations once unused for as long. When recoverInterrupted is true, responses left
+// queued or in progress by a previous process are marked failed: their
+// generation died with that process. Only standalone deployments should set
+// it, since in distributed mode such a response may still be running on a
+// peer.
+func (s *ResponseStore) EnablePersistence(ctx context.Context, db *gorm.DB, retention time.Duration, recoverInterrupted bool) error {
+	if db == nil {
+		return nil
+	}
+	if err := advisorylock.WithLockCtx(ctx, db, advisorylock.KeySchemaMigrate, func() error {
+		return db.AutoMigrate(&ResponseRecord{}, &ResponseItemRecord{}, &ResponseEventRecord{},
+			&ConversationRecord{}, &ConversationItemRecord{})
+	}); err != nil {
+		return fmt.Errorf("migrating open responses tables: %w", err)
+	}
+
+	p := &responsePersistence{db: db, retention: retention}
+	if recoverInterrupted {
+		if n, err := p.failInterrupted(); err != nil {
+			xlog.Warn("Failed to recover interrupted Open Responses", "error", err)
+		} else if n > 0 {
+			xlog.Info("Marked interrupted Open Responses as failed", "count", n)
+		}
+	}
+
+	s.mu.Lock()
+	s.persistence = p
+	s.mu.Unlock()
+	s.conversations.usePersistence(db)
+
+	go p.flushLoop(ctx)
+	go advisorylock.RunLeaderLoop(ctx, db, advisorylock.KeyOpenResponsesPrune, pruneInterval, func() {
+		now := time.Now()
+		if n, err := p.prune(now); err != nil {
+			xlog.Warn("Failed to prune expired Open Responses", "error", err)
+		} else if n > 0 {
+			xlog.Debug("Pruned expired Open Responses", "count", n)
+		}
+		if keep := s.conversationRetention(retention); keep > 0 {
+			s.conversations.prune(now.Add(-keep))
+		}
+	})
+
+	xlog.Info("Open Responses store persisting to database", "retention", retention)
+	return nil
+}
+
+// persister returns the database leg, or nil when persistence is disabled.
+func (s *ResponseStore) persister() *responsePersistence {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.persistence
+}
+
+// persist writes the current state of a locally-owned response. Like mirror,
+// it must be called with s.mu released.
+func (s *ResponseStore) persist(responseID string, stored *StoredResponse) {
+	p := s.persister()
+	if p == nil {
+		return
+	}
+
+	replicaID := s.replicaIdentity()
+	stored.mu.RLock()
+	rec, items, err := p.toRecords(responseID, stored, replicaID)
+	stored.mu.RUnlock()
+	if err != nil {
+		xlog.Warn("Failed to serialize Open Responses response", "response_id", responseID, "error", err)
+		return
+	}
+
+	// Events buffered before this state change must land first, so a reader
+	// that sees a terminal status also sees the complete event log.
+	p.flush()
+	if err := p.save(rec, items); err != nil {
+		xlog.Warn("Failed to persist Open Responses response", "response_id", responseID, "error", err)
+	}
+}
+
+// unpersist removes a response and everything attached to it.
+func (s *ResponseStore) unpersist(responseID string) {
+	p := s.persister()
+	if p == nil {
+		return
+	}
+	p.flush()
+	if err := p.delete(responseID); err != nil {
+		xlog.Warn("Failed to delete persisted Open Responses response", "response_id", responseID, "error", err)
+	}
+}
+
+// persistEvent queues a streamed event for the write-behind flusher.
+func (s *ResponseStore) persistEvent(responseID string, event StreamedEvent) {
+	p := s.persister()
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	p.pending = append(p.pending, ResponseEventRecord{
+		ResponseID:     responseID,
+		SequenceNumber: event.SequenceNumber,
+		EventType:      event.EventType,
+		Data:           string(event.Data),
+	})
+	p.mu.Unlock()
+}
+
+// persistedGet materialises a read-only view of a response from the
+// database. Like the remote view it carries no CancelFunc and no live event
+// channel; Persisted tells the stream-resume path to read the event log from
+// the database instead.
+func (s *ResponseStore) persistedGet(responseID string) (*StoredResponse, bool) {
+	p := s.persister()
+	if p == nil {
+		return nil, false
+	}
+	var rec ResponseRecord
+	err := p.db.Where("id = ? AND (expires_at IS NULL OR expires_at > ?)", responseID, time.Now()).First(&rec).Error
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			xlog.Warn("Failed to load persisted Open Responses response", "response_id", responseID, "error", err)
+		}
+		return nil, false
+	}
+	stored, err := fromRecord(&rec)
+	if err != nil {
+		xlog.Warn("Failed to decode persisted Open Responses response", "response_id", responseID, "error", err)
+		return nil, false
+	}
+	return stored, true
+}
+
+// persistedFindItem resolves an item ID from the persisted item index.
+func (s *ResponseStore) persistedFindItem(itemID string) (*schema.ORItemField, string, bool) {
+	p := s.persister()
+	if p == nil {
+		return nil, "", false
+	}
+	var rec ResponseItemRecord
+	err := p.db.Joins("JOIN open_responses ON open_responses.id = open_response_items.response_id").
+		Where("open_response_items.item_id = ?", itemID).
+		Where("open_responses.expires_at IS NULL OR open_responses.expires_at > ?", time.Now()).
+		Order("open_response_items.id DESC").
+		First(&rec).Error
+	if err != nil {
+		return nil, "", false
+	}
+	var item schema.ORItemField
+	if err := json.Unmarshal([]byte(rec.Data), &item); err != nil {
+		return nil, "", false
+	}
+	restoreContentParts(&item)
+	return &item, rec.ResponseID, true
+}
+
+// persistedEventsAfter returns the persisted events of a response with a
+// sequence number greater than startingAfter.
+func (s *ResponseStore) persistedEventsAfter(responseID string, startingAfter int) ([]StreamedEvent, error) {
+	p := s.persister()
+	if p == nil {
+		return nil, fmt.Errorf("response not found: %s", responseID)
+	}
+	p.flush()
+	var recs []ResponseEventRecord
+	if err := p.db.Where("response_id = ? AND sequence_number > ?", responseID, startingAfter).
+		Order("sequence_number ASC").Find(&recs).Error; err != nil {
+		return nil, err
+	}
+	events := make([]StreamedEvent, 0, len(recs))
+	for _, r := range recs {
+		events = append(events, StreamedEvent{SequenceNumber: r.SequenceNumber, EventType: r.EventType, Data: []byte(r.Data)})
+	}
+	return events, nil
+}
+
+// persistedCancel cancels a response only the database knows about, e.g.
+// one orphaned by a replica that is gone. There is no generation left to
+// stop, so this only moves the recorded status to cancelled.
+func (s *ResponseStore) persistedCancel(stored *StoredResponse) (*schema.ORResponseResource, error) {
+	response := stored.Response
+	if isTerminalStatus(response.Status) {
+		return response, nil
+	}
+	now := time.Now().Unix()
+	response.Status = schema.ORStatusCancelled
+	response.CompletedAt = &now
+
+	p := s.persister()
+	rec, items, err := p.toRecords(response.ID, stored, stored.OwnerReplica)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.save(rec, items); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func isTerminalStatus(status string) bool {
+	return status == schema.ORStatusCompleted || status == schema.ORStatusFailed ||
+		status == schema.ORStatusIncomplete || status == schema.ORStatusCancelled
+}
+
+// --- database helpers ---
+
+func (p *responsePersistence) toRecords(responseID string, stored *StoredResponse, replicaID string) (*ResponseRecord, []ResponseItemRecord, error) {
+	reqJSON, err := json.Marshal(stored.Request)
+	if err != nil {
+		return nil, nil, err
+	}
+	respJSON, err := json.Marshal(stored.Response)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	expiresAt := stored.ExpiresAt
+	if expiresAt == nil && p.retention > 0 {
+		t := stored.StoredAt.Add(p.retention)
+		expiresAt = &t
+	}
+
+	rec := &ResponseRecord{
+		ID:            responseID,
+		Owner:         stored.Owner,
+		OwnerReplica:  replicaID,
+		RequestJSON:   string(reqJSON),
+		ResponseJSON:  string(respJSON),
+		StreamEnabled: stored.StreamEnabled,
+		IsBackground:  stored.IsBackground,
+		StoredAt:      stored.StoredAt,
+		ExpiresAt:     expiresAt,
+	}
+	var items []ResponseItemRecord
+	if stored.Response != nil {
+		rec.Status = stored.Response.Status
+		for i, item := range stored.Response.Output {
+			if item.ID == "" {
+				continue
+			}
+			data, err := json.Marshal(item)
+			if err != nil {
+				return nil, nil, err
+			}
+			items = append(items, ResponseItemRecord{ResponseID: responseID, ItemID: item.ID, Position: i, Data: string(data)})
+		}
+	}
+	return rec, items, nil
+}
+
+func fromRecord(rec *ResponseRecord) (*StoredResponse, error) {
+	var request schema.OpenResponsesRequest
+	if rec.RequestJSON != "" && rec.RequestJSON != "null" {
+		if err := json.Unmarshal([]byte(rec.RequestJSON), &request); err != nil {
+			return nil, err
+		}
+	}
+	var response schema.ORResponseResource
+	if err := json.Unmarshal([]byte(rec.ResponseJSON), &response); err != nil {
+		return nil, err
+	}
+
+	items := make(map[string]*schema.ORItemField)
+	for i := range response.Output {
+		item := &response.Output[i]
+		restoreContentParts(item)
+		if item.ID != "" {
+			items[item.ID] = item
+		}
+	}
+	return &StoredResponse{
+		Request:        &request,
+		Response:       &response,
+		Items:          items,
+		StoredAt:       rec.StoredAt,
+		ExpiresAt:      rec.ExpiresAt,
+		Owner:          rec.Owner,
+		StreamEnabled:  rec.StreamEnabled,
+		IsBackground:   rec.IsBackground,
+		Persisted:      true,
+		OwnerReplica:   rec.OwnerReplica,
+		droppedThrough: -1,
+	}, nil
+}
+
+// restoreContentParts gives a decoded item back the []schema.ORContentPart
+// content it was stored with. JSON decodes the `any` field as []any, which
+// the message conversion would otherwise silently treat as empty.
+func restoreContentParts(item *schema.ORItemField) {
+	raw, ok := item.Content.([]any)
+	if !ok {
+		return
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return
+	}
+	var parts []schema.ORContentPart
+	if err := json.Unmarshal(data, &parts); err == nil {
+		item.Content = parts
+	}
+}
+
+func (p *responsePersistence) save(rec *ResponseRecord, items []ResponseItemRecord) error {
+	rec.UpdatedAt = time.Now()
+	return p.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(rec).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("response_id = ?", rec.ID).Delete(&ResponseItemRecord{}).Error; err != nil {
+			return err
+		}
+		if len(items) == 0 {
+			return nil
+		}
+		return tx.Create(&items).Error
+	})
+}
+
+func (p *responsePersistence) delete(responseID string) error {
+	return p.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("response_id = ?", responseID).Delete(&ResponseEventRecord{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("response_id = ?", responseID).Delete(&ResponseItemRecord{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("id = ?", responseID).Delete(&ResponseRecord{}).Error
+	})
+}
+
+// flush writes every buffered event. Safe to call from any goroutine.
+func (p *responsePersistence) flush() {
+	p.flushMu.Lock()
+	defer p.flushMu.Unlock()
+
+	p.mu.Lock()
+	batch := p.pending
+	p.pending = nil
+	p.mu.Unlock()
+	if len(batch) == 0 {
+		return
+	}
+	if err := p.db.CreateInBatches(batch, 500).Error; err != nil {
+		xlog.Warn("Failed to persist Open Responses stream events", "count", len(batch), "error", err)
+	}
+}
+
+func (p *responsePersistence) flushLoop(ctx context.Context) {
+	ticker := time.NewTicker(eventFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			p.flush()
+			return
+		case <-ticker.C:
+			p.flush()
+		}
+	}
+}
+
+// prune deletes every response whose retention elapsed, with its items and
+// events.
+func (p *responsePersistence) prune(now time.Time) (int64, error) {
+	var deleted int64
+	err := p.db.Transaction(func(tx *gorm.DB) error {
+		expired := tx.Model(&ResponseRecord{}).Select("id").Where("expires_at IS NOT NULL AND expires_at < ?", now)
+		if err := tx.Where("response_id IN (?)", expired).Delete(&ResponseEventRecord{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("response_id IN (?)", expired).Delete(&ResponseItemRecord{}).Error; err != nil {
+			return err
+		}
+		res := tx.Where("expires_at IS NOT NULL AND expires_at < ?", now).Delete(&ResponseRecord{})
+		deleted = res.RowsAffected
+		return res.Error
+	})
+	return deleted, err
+}
+
+// failInterrupted marks responses left queued or in progress by a previous
+// process as failed, so polling clients get a terminal answer instead of
+// waiting forever.
+func (p *responsePersistence) failInterrupted() (int, error) {
+	var recs []ResponseRecord
+	if err := p.db.Where("status IN ?", []string{schema.ORStatusQueued, schema.ORStatusInProgress}).Find(&recs).Error; err != nil {
+		return 0, err
+	}
+	now := time.Now().Unix()
+	for i := range recs {
+		var response schema.ORResponseResource
+		if err := json.Unmarshal([]byte(recs[i].ResponseJSON), &response); err != nil {
+			continue
+		}
+		response.Status = schema.ORStatusFailed
+		response.CompletedAt = &now
+		response.Error = &schema.ORError{
+			Type:    "server_error",
+			Code:    "server_restarted",
+			Message: "the server restarted before this response completed",
+		}
+		data, err := json.Marshal(&response)
+		if err != nil {
+			continue
+		}
+		if err := p.db.Model(&ResponseRecord{}).Where("id = ?", recs[i].ID).Updates(map[string]any{
+			"status":     response.Status,
+			"response":   string(data),
+			"updated_at": time.Now(),
+		}).Error; err != nil {
+			return i, err
+		}
+	}
+	return len(recs), nil
+}
@@ -0,0 +1,204 @@
+//go:build auth
+
+package openresponses
+
+import (
+	"context"
+	"time"
+
+	"github.com/mudler/LocalAI/core/http/auth"
+	"github.com/mudler/LocalAI/core/schema"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"gorm.io/gorm"
+)
+
+var _ = Describe("ResponseStore persistence", func() {
+	var (
+		db     *gorm.DB
+		ctx    context.Context
+		cancel context.CancelFunc
+	)
+
+	newPersistentStore := func(retention time.Duration, recoverInterrupted bool) *ResponseStore {
+		s := NewResponseStore(0)
+		Expect(s.EnablePersistence(ctx, db, retention, recoverInterrupted)).To(Succeed())
+		return s
+	}
+
+	newResponse := func(id, status string) *schema.ORResponseResource {
+		return &schema.ORResponseResource{
+			ID:        id,
+			Object:    "response",
+			CreatedAt: time.Now().Unix(),
+			Status:    status,
+			Model:     "test-model",
+			Output: []schema.ORItemField{{
+				Type:    "message",
+				ID:      "msg_" + id,
+				Status:  "completed",
+				Role:    "assistant",
+				Content: []schema.ORContentPart{{Type: "output_text", Text: "Hello"}},
+			}},
+		}
+	}
+
+	BeforeEach(func() {
+		var err error
+		db, err = auth.InitDB(":memory:")
+		Expect(err).ToNot(HaveOccurred())
+		ctx, cancel = context.WithCancel(context.Background())
+	})
+
+	AfterEach(func() {
+		cancel()
+	})
+
+	It("reloads a stored response in a new store", func() {
+		first := newPersistentStore(0, false)
+		first.Store("resp_1", &schema.OpenResponsesRequest{Model: "test-model", Input: "Hi"}, newResponse("resp_1", schema.ORStatusCompleted))
+		first.SetOwner("resp_1", "user-1")
+
+		second := newPersistentStore(0, false)
+		stored, err := second.Get("resp_1")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stored.Persisted).To(BeTrue())
+		Expect(stored.Owner).To(Equal("user-1"))
+		Expect(stored.Request.Model).To(Equal("test-model"))
+		Expect(stored.Response.Status).To(Equal(schema.ORStatusCompleted))
+		Expect(stored.Items).To(HaveKey("msg_resp_1"))
+
+		item, responseID, err := second.FindItem("msg_resp_1")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(responseID).To(Equal("resp_1"))
+		Expect(item.Content).To(Equal([]schema.ORContentPart{{Type: "output_text", Text: "Hello"}}))
+	})
+
+	It("forgets deleted responses", func() {
+		first := newPersistentStore(0, false)
+		first.Store("resp_1", nil, newResponse("resp_1", schema.ORStatusCompleted))
+		first.Delete("resp_1")
+
+		second := newPersistentStore(0, false)
+		_, err := second.Get("resp_1")
+		Expect(err).To(HaveOccurred())
+		_, _, err = second.FindItem("msg_resp_1")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("resumes a stream from the persisted event log", func() {
+		first := newPersistentStore(0, false)
+		first.StoreBackground("resp_1", nil, newResponse("resp_1", schema.ORStatusInProgress), func() {}, true)
+		for i := 1; i <= 3; i++ {
+			Expect(first.AppendEvent("resp_1", &schema.ORStreamEvent{Type: "response.output_text.delta", SequenceNumber: i})).To(Succeed())
+		}
+		Expect(first.UpdateStatus("resp_1", schema.ORStatusCompleted, nil)).To(Succeed())
+
+		second := newPersistentStore(0, false)
+		streamEnabled, err := second.IsStreamEnabled("resp_1")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(streamEnabled).To(BeTrue())
+
+		events, err := second.GetEventsAfter("resp_1", 1)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(events).To(HaveLen(2))
+		Expect(events[0].SequenceNumber).To(Equal(2))
+		Expect(events[1].SequenceNumber).To(Equal(3))
+	})
+
+	It("marks interrupted responses failed on a standalone restart", func() {
+		first := newPersistentStore(0, false)
+		first.StoreBackground("resp_1", nil, newResponse("resp_1", schema.ORStatusQueued), func() {}, false)
+
+		second := newPersistentStore(0, true)
+		stored, err := second.Get("resp_1")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stored.Response.Status).To(Equal(schema.ORStatusFailed))
+		Expect(stored.Response.Error).ToNot(BeNil())
+		Expect(stored.Response.Error.Code).To(Equal("server_restarted"))
+	})
+
+	It("cancels a response only the database knows about", func() {
+		first := newPersistentStore(0, false)
+		first.StoreBackground("resp_1", nil, newResponse("resp_1", schema.ORStatusInProgress), func() {}, false)
+
+		second := newPersistentStore(0, false)
+		response, err := second.Cancel("resp_1")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(response.Status).To(Equal(schema.ORStatusCancelled))
+
+		stored, err := second.Get("resp_1")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stored.Response.Status).To(Equal(schema.ORStatusCancelled))
+	})
+
+	It("keeps conversations in the database", func() {
+		first := newPersistentStore(0, false)
+		conv, err := first.Conversations().Create("user-1", map[string]string{"k": "v"}, nil)
+		Expect(err).ToNot(HaveOccurred())
+		first.Store("resp_1", &schema.OpenResponsesRequest{Model: "test-model", Input: "Hi", Conversation: conv.ID}, newResponse("resp_1", schema.ORStatusCompleted))
+
+		second := newPersistentStore(0, false)
+		loaded, err := second.Conversations().Get(conv.ID)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(loaded.Owner).To(Equal("user-1"))
+		Expect(loaded.Metadata).To(Equal(map[string]string{"k": "v"}))
+
+		page, hasMore, err := second.Conversations().ListItems(conv.ID, "", 1, false)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(hasMore).To(BeTrue())
+		Expect(page[0].ID).To(Equal("msg_1_0"))
+
+		page, hasMore, err = second.Conversations().ListItems(conv.ID, "msg_1_0", 1, false)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(hasMore).To(BeFalse())
+		Expect(page[0].ID).To(Equal("msg_resp_1"))
+		Expect(page[0].Content).To(Equal([]schema.ORContentPart{{Type: "output_text", Text: "Hello"}}))
+
+		_, _, err = second.Conversations().ListItems(conv.ID, "msg_missing", 1, false)
+		Expect(err).To(MatchError(ErrCursorNotFound))
+
+		Expect(second.Conversations().Delete(conv.ID)).To(Succeed())
+		_, err = first.Conversations().Get(conv.ID)
+		Expect(err).To(MatchError(ErrConversationNotFound))
+	})
+
+	It("prunes responses past their retention", func() {
+		first := newPersistentStore(time.Hour, false)
+		first.Store("resp_1", nil, newResponse("resp_1", schema.ORStatusCompleted))
+
+		n, err := first.persister().prune(time.Now())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(n).To(BeZero())
+
+		n, err = first.persister().prune(time.Now().Add(2 * time.Hour))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(n).To(Equal(int64(1)))
+
+		var items int64
+		Expect(db.Model(&ResponseItemRecord{}).Count(&items).Error).To(Succeed())
+		Expect(items).To(BeZero())
+	})
+
+	It("prunes conversations unused past the retention", func() {
+		first := newPersistentStore(time.Hour, false)
+		Expect(first.conversationRetention(time.Hour)).To(Equal(time.Hour))
+		conv, err := first.Conversations().Create("user-1", nil, nil)
+		Expect(err).ToNot(HaveOccurred())
+		first.Store("resp_1", &schema.OpenResponsesRequest{Model: "test-model", Input: "Hi", Conversation: conv.ID}, newResponse("resp_1", schema.ORStatusCompleted))
+
+		n, err := first.Conversations().store().prune(time.Now().Add(-time.Hour))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(n).To(BeZero())
+
+		n, err = first.Conversations().store().prune(time.Now().Add(time.Minute))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(n).To(Equal(int64(1)))
+		_, err = first.Conversations().Get(conv.ID)
+		Expect(err).To(MatchError(ErrConversationNotFound))
+
+		var items int64
+		Expect(db.Model(&ConversationItemRecord{}).Count(&items).Error).To(Succeed())
+		Expect(items).To(BeZero())
+	})
+})
@@ -31,7 +31,7 @@ import (
 // @Param request body schema.OpenResponsesRequest true "Request body"
 // @Success 200 {object} schema.ORResponseResource "Response"
 // @Router /v1/responses [post]
-func ResponsesEndpoint(cl *config.ModelConfigLoader, ml *model.ModelLoader, evaluator *templates.Evaluator, appConfig *config.ApplicationConfig, natsClient mcpTools.MCPNATSClient) echo.HandlerFunc {
+func ResponsesEndpoint(cl *config.ModelConfigLoader, ml *model.ModelLoader, evaluator *templates.Evaluator, appConfig *config.ApplicationConfig, natsClient mcpTools.MCPNATSClient, fileSearcher FileSearcher) echo.HandlerFunc {
 	return func(c echo.Context) error {
 		createdAt := time.Now().Unix()
 		responseID := fmt.Sprintf("resp_%s", uuid.New().String())
@@ -87,6 +87,29 @@ func ResponsesEndpoint(cl *config.ModelConfigLoader, ml *model.ModelLoader, eval
 			messages = append(messages, previousOutputMessages...)
 		}
 
+		// Handle conversation if provided: its items are the history, and the
+		// store appends this turn to it once the response completes.
+		if conversationID := input.ConversationID(); conversationID != "" {
+			if input.PreviousResponseID != "" {
+				return sendOpenResponsesError(c, 400, "invalid_request_error", "previous_response_id cannot be used together with conversation", "conversation")
+			}
+			if !shouldStore {
+				return sendOpenResponsesError(c, 400, "invalid_request_error", "conversation requires store=true", "conversation")
+			}
+			conv, err := store.Conversations().Get(conversationID)
+			if err != nil || !conversationAccessAllowed(conv, ownerFromContext(c)) {
+				return sendOpenResponsesError(c, 404, "not_found", fmt.Sprintf("conversation not found: %s", conversationID), "conversation")
+			}
+			items, err := store.Conversations().Items(conversationID)
+			if err != nil {
+				return sendOpenResponsesError(c, 500, "server_error", fmt.Sprintf("failed to load conversation: %v", err), "")
+			}
+			messages, err = convertORItemsToMessages(items, conversationID)
+			if err != nil {
+				return sendOpenResponsesError(c, 400, "invalid_request", fmt.Sprintf("failed to convert conversation: %v", err), "")
+			}
+		}
+
 		// Convert Open Responses input to internal Messages
 		newMessages, err := convertORInputToMessages(input.Input, cfg)
 		if err != nil {
@@ -124,7 +147,7 @@ func ResponsesEndpoint(cl *config.ModelConfigLoader, ml *model.ModelLoader, eval
 				if !hasMCPRequest {
 					enabledServers = nil // backward compat: auto-activate all servers
 				}
-				mcpExecutor = mcpTools.NewToolExecutor(c.Request().Context(), natsClient, cfg.Name, remote, stdio, enabledServers)
+				mcpExecutor = mcpTools.ExecutorFor(auth.GetUserID(c), mcpTools.NewToolExecutor(c.Request().Context(), natsClient, cfg.Name, remote, stdio, enabledServers))
 
 				// Prompt and resource injection (pre-processing step — resolves locally regardless of distributed mode)
 				if hasMCPRequest {
@@ -160,6 +183,13 @@ func ResponsesEndpoint(cl *config.ModelConfigLoader, ml *model.ModelLoader, eval
 			}
 		}
 
+		// file_search tools run server-side against the vector stores they
+		// name, through the same tool loop as MCP tools.
+		if len(input.Tools) > 0 {
+			funcs, mcpExecutor = withFileSearch(input, fileSearcher, ownerFromContext(c), funcs, mcpExecutor)
+			shouldUseFn = len(funcs) > 0 && cfg.ShouldUseFunctions()
+		}
+
 		// Create OpenAI-compatible request for internal processing
 		openAIReq := &schema.OpenAIRequest{
 			PredictionOptions: schema.PredictionOptions{
@@ -178,6 +208,12 @@ func ResponsesEndpoint(cl *config.ModelConfigLoader, ml *model.ModelLoader, eval
 		// Handle text_format -> response_format conversion
 		if input.TextFormat != nil {
 			openAIReq.ResponseFormat = convertTextFormatToResponseFormat(input.TextFormat)
+			// Constrain plain answers to a json_schema text_format
+			if !shouldUseFn {
+				if g, _ := textFormatSchema(input, cfg); g != "" {
+					cfg.Grammar = g
+				}
+			}
 		}
 
 		// Generate grammar for function calling (similar to OpenAI chat endpoint)
@@ -393,6 +429,26 @@ func convertORInputToMessages(input any, cfg *config.ModelConfig) ([]schema.Mess
 	}
 }
 
+// convertORItemsToMessages converts stored items (e.g. the history of a
+// conversation) to internal Messages, merging contiguous assistant items.
+// Items of types that carry no conversational content are skipped.
+func convertORItemsToMessages(items []schema.ORItemField, sourceID string) ([]schema.Message, error) {
+	messages := make([]schema.Message, 0, len(items))
+	for i := range items {
+		switch items[i].Type {
+		case "message", "function_call", "function_call_output", "reasoning":
+		default:
+			continue
+		}
+		msg, err := convertORItemToMessage(&items[i], sourceID)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, msg)
+	}
+	return mergeContiguousAssistantMessages(messages), nil
+}
+
 // convertORReasoningItemToMessage converts an Open Responses reasoning item to an assistant Message fragment (for merging).
 func convertORReasoningItemToMessage(itemMap map[string]any) (schema.Message, error) {
 	var reasoning string
@@ -847,13 +903,13 @@ func handleBackgroundNonStream(ctx context.Context, store *ResponseStore, respon
 		}
 
 		var result string
-		cb := func(s string, c *[]schema.Choice) {
-			result = s
-		}
-		choices, tokenUsage, chatDeltas, err := openaiEndpoint.ComputeChoices(openAIReq, predInput, cfg, cl, appConfig, ml, cb, nil)
+		choices, tokenUsage, chatDeltas, schemaErr, err := computeSchemaChoices(input, openAIReq, predInput, cfg, cl, appConfig, ml, shouldUseFn, &result)
 		if err != nil {
 			return nil, fmt.Errorf("model inference failed: %w", err)
 		}
+		if schemaErr != nil {
+			return nil, fmt.Errorf("model output does not match the text_format schema: %w", schemaErr)
+		}
 
 		// Extract logprobs from choices if available
 		var resultLogprobs *schema.Logprobs
@@ -1337,14 +1393,14 @@ func handleOpenResponsesNonStream(c echo.Context, responseID string, createdAt i
 	openAIReq.LogitBias = input.LogitBias
 
 	var result string
-	cb := func(s string, c *[]schema.Choice) {
-		result = s
-	}
-	choices, tokenUsage, chatDeltas, err := openaiEndpoint.ComputeChoices(openAIReq, predInput, cfg, cl, appConfig, ml, cb, nil)
+	choices, tokenUsage, chatDeltas, schemaErr, err := computeSchemaChoices(input, openAIReq, predInput, cfg, cl, appConfig, ml, shouldUseFn, &result)
 	if err != nil {
 		xlog.Error("Open Responses model inference failed", "error", err)
 		return sendOpenResponsesError(c, 500, "model_error", fmt.Sprintf("model inference failed: %v", err), "")
 	}
+	if schemaErr != nil {
+		return sendOpenResponsesError(c, 500, "model_error", fmt.Sprintf("model output does not match the text_format schema: %v", schemaErr), "text_format")
+	}
 	var resultLogprobs *schema.Logprobs
 	if len(choices) > 0 {
 		resultLogprobs = choices[0].Logprobs
@@ -2876,6 +2932,11 @@ func buildORResponse(responseID string, createdAt int64, completedAt *int64, sta
 		instructions = &input.Instructions
 	}
 
+	var conversation *schema.ORConversationRef
+	if conversationID := input.ConversationID(); conversationID != "" {
+		conversation = &schema.ORConversationRef{ID: conversationID}
+	}
+
 	// Convert reasoning
 	var reasoning *schema.ORReasoning
 	if input.Reasoning != nil {
@@ -2904,6 +2965,7 @@ func buildORResponse(responseID string, createdAt int64, completedAt *int64, sta
 		IncompleteDetails:  nil, // null when complete
 		PreviousResponseID: previousResponseID,
 		Instructions:       instructions,
+		Conversation:       conversation,
 
 		// Tool-related fields
 		Tools:             tools,
@@ -3045,6 +3107,46 @@ func GetResponseEndpoint() func(c echo.Context) error {
 	}
 }
 
+// ListInputItemsEndpoint returns a handler for GET /responses/:id/input_items
+// @Summary List the input items of a response
+// @Tags inference
+// @Param id path string true "Response ID"
+// @Param limit query int false "Number of items to return (1-100, default 20)"
+// @Param order query string false "asc or desc (default desc)"
+// @Param after query string false "Item ID to list after"
+// @Success 200 {object} schema.ORItemList "Input items"
+// @Failure 400 {object} map[string]any "Bad Request"
+// @Failure 404 {object} map[string]any "Not Found"
+// @Router /v1/responses/{id}/input_items [get]
+func ListInputItemsEndpoint() func(c echo.Context) error {
+	return func(c echo.Context) error {
+		responseID := c.Param("id")
+		if responseID == "" {
+			return sendOpenResponsesError(c, 400, "invalid_request_error", "response ID is required", "id")
+		}
+		limit, desc, after, err := itemListParams(c)
+		if err != nil {
+			return sendOpenResponsesError(c, 400, "invalid_request_error", err.Error(), "")
+		}
+
+		store := GetGlobalStore()
+		stored, err := store.Get(responseID)
+		if err != nil || !accessAllowed(stored, ownerFromContext(c)) {
+			return sendOpenResponsesError(c, 404, "not_found", fmt.Sprintf("response not found: %s", responseID), "id")
+		}
+
+		items, err := store.InputItems(responseID)
+		if err != nil {
+			return sendOpenResponsesError(c, 404, "not_found", fmt.Sprintf("response not found: %s", responseID), "id")
+		}
+		page, hasMore, err := paginateItems(items, after, limit, desc)
+		if err != nil {
+			return sendOpenResponsesError(c, 400, "invalid_request_error", err.Error(), "after")
+		}
+		return c.JSON(200, newItemList(page, hasMore))
+	}
+}
+
 // handleStreamResume handles resuming a streaming response from a specific sequence number
 func handleStreamResume(c echo.Context, store *ResponseStore, responseID string, stored *StoredResponse, startingAfter int) error {
 	// The resume buffer is process-local by design (see ErrResponseNotLocal), so
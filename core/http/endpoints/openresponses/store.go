@@ -61,6 +61,14 @@ type ResponseStore struct {
 	replicaID  string
 	lifeCtx    context.Context
 	lifeCancel context.CancelFunc
+
+	// Database persistence. nil unless EnablePersistence was called (see
+	// persist.go). Guarded by mu.
+	persistence *responsePersistence
+
+	// conversations groups response items across turns (see
+	// conversation_store.go). Set at construction, never nil.
+	conversations *ConversationStore
 }
 
 // StreamedEvent represents a buffered SSE event for streaming resume
@@ -101,6 +109,11 @@ type StoredResponse struct {
 	Remote       bool
 	OwnerReplica string
 
+	// Persisted marks a read-only view loaded from the database because no
+	// live replica holds the response (e.g. after a restart). Its resume
+	// buffer is the persisted event log rather than StreamEvents.
+	Persisted bool
+
 	// streamBytes tracks the total serialized size of the events currently
 	// retained in StreamEvents, used to enforce the byte cap. droppedThrough
 	// is the highest sequence number evicted from the front of the buffer
@@ -151,6 +164,7 @@ func NewResponseStore(ttl time.Duration) *ResponseStore {
 		ttl:             ttl,
 		maxStreamEvents: defaultMaxStreamEvents,
 		maxStreamBytes:  defaultMaxStreamBytes,
+		conversations:   NewConversationStore(),
 	}
 
 	// Start cleanup goroutine if TTL is set
@@ -196,6 +210,8 @@ func (s *ResponseStore) Store(responseID string, request *schema.OpenResponsesRe
 	// Replicate outside the lock: the broadcast can be delivered synchronously
 	// and a subscriber re-enters the store.
 	s.mirror(responseID, stored)
+	s.persist(responseID, stored)
+	s.recordConversationTurn(responseID, request, response)
 	xlog.Debug("Stored Open Responses response", "response_id", responseID, "items_count", len(items))
 }
 
@@ -224,6 +240,12 @@ func (s *ResponseStore) Get(responseID string) (*StoredResponse, error) {
 		return remote, nil
 	}
 
+	// Neither this process nor a live peer holds it: the response may have
+	// been created before a restart.
+	if persisted, ok := s.persistedGet(responseID); ok {
+		return persisted, nil
+	}
+
 	return nil, fmt.Errorf("response not found: %s", responseID)
 }
 
@@ -242,6 +264,22 @@ func (s *ResponseStore) GetItem(responseID, itemID string) (*schema.ORItemField,
 	return item, nil
 }
 
+// InputItems returns the input items of a stored response, oldest first.
+func (s *ResponseStore) InputItems(responseID string) ([]schema.ORItemField, error) {
+	stored, err := s.Get(responseID)
+	if err != nil {
+		return nil, err
+	}
+
+	stored.mu.RLock()
+	request := stored.Request
+	stored.mu.RUnlock()
+	if request == nil {
+		return []schema.ORItemField{}, nil
+	}
+	return s.inputItems(responseID, request.Input), nil
+}
+
 // FindItem searches for an item across all stored responses
 // Returns the item and the response ID it was found in
 func (s *ResponseStore) FindItem(itemID string) (*schema.ORItemField, string, error) {
@@ -270,6 +308,16 @@ func (s *ResponseStore) FindItem(itemID string) (*schema.ORItemField, string, er
 		}
 	}
 
+	if item, responseID, found := s.persistedFindItem(itemID); found {
+		return item, responseID, nil
+	}
+
+	// Items added to a conversation directly belong to no response. The
+	// conversation ID is returned in place of the response ID.
+	if item, conversationID, found := s.conversations.FindItem(itemID); found {
+		return item, conversationID, nil
+	}
+
 	return nil, "", fmt.Errorf("item not found in any stored response: %s", itemID)
 }
 
@@ -300,6 +348,7 @@ func (s *ResponseStore) Delete(responseID string) {
 	s.mu.Unlock()
 
 	s.unmirror(responseID)
+	s.unpersist(responseID)
 	xlog.Debug("Deleted Open Responses response", "response_id", responseID)
 }
 
@@ -336,9 +385,26 @@ func (s *ResponseStore) Cleanup() int {
 		xlog.Debug("Cleaned up expired Open Responses", "count", len(expired))
 	}
 
+	// Persisted conversations are pruned by the database leader loop.
+	if s.persister() == nil {
+		s.conversations.prune(now.Add(-s.ttl))
+	}
+
 	return len(expired)
 }
 
+// conversationRetention is how long a conversation is kept once unused:
+// the TTL of the responses when one is set, otherwise the retention of
+// their persisted rows (0 keeps conversations forever).
+func (s *ResponseStore) conversationRetention(persistRetention time.Duration) time.Duration {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.ttl > 0 {
+		return s.ttl
+	}
+	return persistRetention
+}
+
 // cleanupLoop runs periodic cleanup of expired responses
 func (s *ResponseStore) cleanupLoop(ctx context.Context) {
 	if s.ttl == 0 {
@@ -405,6 +471,7 @@ func (s *ResponseStore) StoreBackground(responseID string, request *schema.OpenR
 	// Only the metadata crosses the bus. CancelFunc and the resume buffer stay
 	// here, which is what makes this replica the owner for cancel and resume.
 	s.mirror(responseID, stored)
+	s.persist(responseID, stored)
 	xlog.Debug("Stored background Open Responses response", "response_id", responseID, "stream_enabled", streamEnabled)
 }
 
@@ -426,6 +493,7 @@ func (s *ResponseStore) UpdateStatus(responseID string, status string, completed
 	// Peers poll this response too, so every status transition has to be
 	// republished or their view stays stuck at "queued" forever.
 	s.mirror(responseID, stored)
+	s.persist(responseID, stored)
 
 	xlog.Debug("Updated response status", "response_id", responseID, "status", status)
 	return nil
@@ -454,10 +522,13 @@ func (s *ResponseStore) UpdateResponse(responseID string, response *schema.ORRes
 
 	stored.Response = response
 	stored.Items = items
+	request := stored.Request
 	stored.mu.Unlock()
 
 	// The final output is what a peer's poll must return, so replicate it.
 	s.mirror(responseID, stored)
+	s.persist(responseID, stored)
+	s.recordConversationTurn(responseID, request, response)
 
 	xlog.Debug("Updated response", "response_id", responseID, "status", response.Status, "items_count", len(items))
 	return nil
@@ -479,12 +550,15 @@ func (s *ResponseStore) AppendEvent(responseID string, event *schema.ORStreamEve
 		return fmt.Errorf("failed to marshal event: %w", err)
 	}
 
-	stored.mu.Lock()
-	stored.StreamEvents = append(stored.StreamEvents, StreamedEvent{
+	streamed := StreamedEvent{
 		SequenceNumber: event.SequenceNumber,
 		EventType:      event.Type,
 		Data:           data,
-	})
+	}
+	s.persistEvent(responseID, streamed)
+
+	stored.mu.Lock()
+	stored.StreamEvents = append(stored.StreamEvents, streamed)
 	stored.streamBytes += len(data)
 
 	// Evict oldest events from the front once either cap is exceeded. The
@@ -530,6 +604,10 @@ func (s *ResponseStore) GetEventsAfter(responseID string, startingAfter int) ([]
 		if _, remote := s.remoteGet(responseID); remote {
 			return nil, ErrResponseNotLocal
 		}
+		// No live owner: serve the event log persisted before a restart.
+		if _, persisted := s.persistedGet(responseID); persisted {
+			return s.persistedEventsAfter(responseID, startingAfter)
+		}
 		return nil, fmt.Errorf("response not found: %s", responseID)
 	}
 
@@ -573,6 +651,7 @@ func (s *ResponseStore) Cancel(responseID string) (*schema.ORResponseResource, e
 			return nil, err
 		}
 		s.mirror(responseID, stored)
+		s.persist(responseID, stored)
 		return response, nil
 	}
 
@@ -582,6 +661,10 @@ func (s *ResponseStore) Cancel(responseID string) (*schema.ORResponseResource, e
 		}
 	}
 
+	if persisted, ok := s.persistedGet(responseID); ok {
+		return s.persistedCancel(persisted)
+	}
+
 	return nil, fmt.Errorf("response not found: %s", responseID)
 }
 
@@ -644,6 +727,9 @@ func (s *ResponseStore) IsStreamEnabled(responseID string) (bool, error) {
 		if remote, ok := s.remoteGet(responseID); ok {
 			return remote.StreamEnabled, nil
 		}
+		if persisted, ok := s.persistedGet(responseID); ok {
+			return persisted.StreamEnabled, nil
+		}
 		return false, fmt.Errorf("response not found: %s", responseID)
 	}
 
@@ -674,6 +760,7 @@ func (s *ResponseStore) SetOwner(responseID, owner string) {
 	// Owner is part of the replicated metadata: without it a peer would treat
 	// the response as ownerless and skip the access check in accessAllowed.
 	s.mirror(responseID, stored)
+	s.persist(responseID, stored)
 }
 
 // accessAllowed reports whether a caller identified by callerID may read or
@@ -340,5 +340,8 @@ func (s *ResponseStore) applyRemoteCancel(evt responseCancelEvent) {
 		xlog.Warn("failed to apply delegated Open Responses cancel", "response_id", evt.ResponseID, "error", err)
 		return
 	}
+	// The delegating replica only holds the metadata; the owner persists the
+	// full cancelled state.
+	s.persist(evt.ResponseID, stored)
 	xlog.Debug("Applied delegated Open Responses cancel", "response_id", evt.ResponseID, "origin", evt.Origin)
 }
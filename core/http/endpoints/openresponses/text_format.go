@@ -0,0 +1,69 @@
+package openresponses
+
+import (
+	"github.com/mudler/LocalAI/core/backend"
+	"github.com/mudler/LocalAI/core/config"
+	openaiEndpoint "github.com/mudler/LocalAI/core/http/endpoints/openai"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/pkg/functions"
+	pb "github.com/mudler/LocalAI/pkg/grpc/proto"
+	"github.com/mudler/LocalAI/pkg/model"
+	reason "github.com/mudler/LocalAI/pkg/reasoning"
+	"github.com/mudler/xlog"
+)
+
+// textFormatSchema builds the grammar of a json_schema text_format and, for
+// strict schemas, the validator the final output is checked against.
+func textFormatSchema(input *schema.OpenResponsesRequest, cfg *config.ModelConfig) (string, *functions.SchemaValidator) {
+	format, ok := convertTextFormatToResponseFormat(input.TextFormat).(map[string]any)
+	if !ok || format["type"] != "json_schema" {
+		return "", nil
+	}
+	g, validator, err := openaiEndpoint.JSONSchemaResponseFormat(format, cfg)
+	if err != nil {
+		xlog.Error("Open Responses - Failed generating grammar for text_format", "error", err)
+	}
+	return g, validator
+}
+
+// computeSchemaChoices runs inference like ComputeChoices, storing the raw
+// output in result. When the request has a strict json_schema text_format
+// and no tools, the output is checked against the schema and regenerated up
+// to schema_retries times; a mismatch that persists is returned as
+// schemaErr. tokenUsage adds up all the attempts.
+func computeSchemaChoices(input *schema.OpenResponsesRequest, openAIReq *schema.OpenAIRequest, predInput string, cfg *config.ModelConfig, cl *config.ModelConfigLoader, appConfig *config.ApplicationConfig, ml *model.ModelLoader, shouldUseFn bool, result *string) (choices []schema.Choice, tokenUsage backend.TokenUsage, chatDeltas []*pb.ChatDelta, schemaErr, err error) {
+	cb := func(s string, c *[]schema.Choice) {
+		*result = s
+	}
+	var validator *functions.SchemaValidator
+	if !shouldUseFn {
+		_, validator = textFormatSchema(input, cfg)
+	}
+
+	for attempt := 0; ; attempt++ {
+		var usage backend.TokenUsage
+		choices, usage, chatDeltas, err = openaiEndpoint.ComputeChoices(openAIReq, predInput, cfg, cl, appConfig, ml, cb, nil)
+		tokenUsage.Prompt += usage.Prompt
+		tokenUsage.Completion += usage.Completion
+		tokenUsage.TimingPromptProcessing += usage.TimingPromptProcessing
+		tokenUsage.TimingTokenGeneration += usage.TimingTokenGeneration
+		if err != nil || validator == nil {
+			return choices, tokenUsage, chatDeltas, nil, err
+		}
+
+		text := functions.ContentFromChatDeltas(chatDeltas)
+		if text == "" {
+			template := predInput
+			if cfg.TemplateConfig.UseTokenizerTemplate {
+				template = cfg.GetModelTemplate()
+			}
+			thinkingStartToken := reason.DetectThinkingStartToken(template, &cfg.ReasoningConfig)
+			_, text = reason.ExtractReasoningComplete(*result, thinkingStartToken, cfg.ReasoningConfig)
+		}
+		schemaErr = validator.Validate(text)
+		if schemaErr == nil || attempt >= cfg.FunctionsConfig.GrammarConfig.SchemaRetries {
+			return choices, tokenUsage, chatDeltas, schemaErr, nil
+		}
+		xlog.Warn("Open Responses - Output does not match the text_format schema, regenerating", "error", schemaErr, "attempt", attempt+1)
+	}
+}
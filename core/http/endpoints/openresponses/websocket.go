@@ -246,6 +246,11 @@ func handleWSResponseCreate(connCtx context.Context, conn *lockedConn, input *sc
 
 	if input.TextFormat != nil {
 		openAIReq.ResponseFormat = convertTextFormatToResponseFormat(input.TextFormat)
+		if !shouldUseFn {
+			if g, _ := textFormatSchema(input, cfg); g != "" {
+				cfg.Grammar = g
+			}
+		}
 	}
 
 	// Generate grammar for function calling
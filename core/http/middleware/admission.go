@@ -4,6 +4,7 @@ import (
 	"context"
 	"crypto/rand"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
@@ -12,20 +13,32 @@ import (
 
 	"github.com/labstack/echo/v4"
 	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/http/auth"
 	"github.com/mudler/LocalAI/core/services/routing/admission"
 	"github.com/mudler/LocalAI/core/services/routing/pii"
+	"github.com/mudler/LocalAI/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // AdmissionControl runs after RouteModel so the limit applies to the
 // SERVED model — a router fanout that lands on a saturated downstream
 // model gets rejected even though the requested router-model has slack.
 //
-// On reject: HTTP 503, Retry-After header, error JSON. An audit row
-// goes into the shared event store under KindAdmission so admins see
-// rejection rates alongside PII and proxy events.
+// Models with limits.queue_size let requests wait for a slot, in the
+// priority class of their API key or user role, up to the queue
+// deadline. On reject (no room in the queue, deadline reached, or
+// evicted by a higher priority request): HTTP 503, Retry-After
+// header, error JSON. An audit row goes into the shared event store
+// under KindAdmission so admins see rejection rates alongside PII and
+// proxy events.
 //
 // Models without limits.max_concurrent (the common case) hit a fast
-// no-op path — Acquire returns immediately for max <= 0.
+// no-op path — Wait returns immediately for max <= 0.
+//
+// The slot acquisition is traced as an admission.acquire span, with
+// localai.admitted=false on rejection, so a trace shows time spent
+// waiting on the limiter apart from the handler itself.
 func AdmissionControl(limiter *admission.Limiter, events pii.EventStore) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
@@ -34,15 +47,34 @@ func AdmissionControl(limiter *admission.Limiter, events pii.EventStore) echo.Mi
 				return next(c)
 			}
 			max := cfg.Limits.MaxConcurrent
-			release, ok := limiter.Acquire(cfg.Name, max)
-			if !ok {
+			priority := admission.ParsePriority(auth.GetPriority(c))
+			ctx, span := tracing.Start(c.Request().Context(), "admission.acquire", trace.WithAttributes(
+				attribute.String("localai.model", cfg.Name),
+				attribute.Int("localai.max_concurrent", max),
+				attribute.String("localai.priority", priority.String()),
+			))
+			release, err := limiter.Wait(ctx, admission.Request{
+				Model:         cfg.Name,
+				MaxConcurrent: max,
+				QueueSize:     cfg.Limits.QueueSize,
+				Timeout:       admission.QueueTimeout(cfg.Limits.QueueTimeoutSeconds),
+				Priority:      priority,
+				User:          admissionUser(c),
+			})
+			span.SetAttributes(attribute.Bool("localai.admitted", err == nil))
+			span.End()
+			if err != nil {
+				if c.Request().Context().Err() != nil {
+					// The client went away while queued: nobody to answer.
+					return c.Request().Context().Err()
+				}
 				retryAfter := admission.RetryAfter(cfg.Limits.RetryAfterSeconds)
 				recordAdmissionRejection(events, cfg.Name, retryAfter)
 				c.Response().Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds())))
 				return c.JSON(http.StatusServiceUnavailable, map[string]any{
 					"error": map[string]any{
 						"type":    "admission_rejected",
-						"message": fmt.Sprintf("model %q is at capacity (max_concurrent=%d); retry after %s", cfg.Name, max, retryAfter),
+						"message": admissionMessage(cfg, err, retryAfter),
 					},
 				})
 			}
@@ -52,6 +84,26 @@ func AdmissionControl(limiter *admission.Limiter, events pii.EventStore) echo.Mi
 	}
 }
 
+// admissionUser identifies the caller for fair sharing: the
+// authenticated user, else the client address.
+func admissionUser(c echo.Context) string {
+	if u := auth.GetUser(c); u != nil {
+		return u.ID
+	}
+	return c.RealIP()
+}
+
+func admissionMessage(cfg *config.ModelConfig, err error, retryAfter time.Duration) string {
+	switch {
+	case errors.Is(err, admission.ErrQueueTimeout):
+		return fmt.Sprintf("model %q is at capacity (max_concurrent=%d) and the request waited too long in the queue; retry after %s", cfg.Name, cfg.Limits.MaxConcurrent, retryAfter)
+	case errors.Is(err, admission.ErrEvicted):
+		return fmt.Sprintf("model %q is at capacity (max_concurrent=%d) and the request was displaced from the queue by a higher priority one; retry after %s", cfg.Name, cfg.Limits.MaxConcurrent, retryAfter)
+	default:
+		return fmt.Sprintf("model %q is at capacity (max_concurrent=%d); retry after %s", cfg.Name, cfg.Limits.MaxConcurrent, retryAfter)
+	}
+}
+
 // admissionEventSeq scopes IDs across the process so rapid
 // rejections under load get unique row IDs without coordinating
 // with the rest of the event-store ID schemes.
@@ -67,6 +119,7 @@ func recordAdmissionRejection(events pii.EventStore, modelName string, retryAfte
 	_ = events.Record(context.Background(), pii.PIIEvent{
 		ID:         id,
 		Kind:       pii.KindAdmission,
+		Model:      modelName,
 		Host:       modelName,
 		StatusCode: statusCode,
 		DurationMS: durMS,
@@ -115,4 +115,43 @@ var _ = Describe("Admission", func() {
 			Expect(rec.Code).To(Equal(http.StatusOK))
 		}
 	})
+
+	It("queues when the model has a queue", func() {
+		// The slot is held outside the middleware and freed while the
+		// request waits, so it runs instead of getting a 503.
+		lim := admission.New()
+		release, ok := lim.Acquire("queued", 1)
+		Expect(ok).To(BeTrue())
+
+		cfg := &config.ModelConfig{Limits: config.LimitsConfig{MaxConcurrent: 1, QueueSize: 4}}
+		cfg.Name = "queued"
+		go func() {
+			defer GinkgoRecover()
+			Eventually(func() int { return lim.Queued("queued") }).Should(Equal(1))
+			release()
+		}()
+		rec, err := runAdmission(lim, &recordingStore{}, cfg, func(c echo.Context) error {
+			return c.String(http.StatusOK, "ok")
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(rec.Code).To(Equal(http.StatusOK))
+	})
+
+	It("rejects when the queue deadline is reached", func() {
+		lim := admission.New()
+		release, ok := lim.Acquire("slow", 1)
+		Expect(ok).To(BeTrue())
+		defer release()
+
+		cfg := &config.ModelConfig{Limits: config.LimitsConfig{MaxConcurrent: 1, QueueSize: 4, QueueTimeoutSeconds: 1}}
+		cfg.Name = "slow"
+		store := &recordingStore{}
+		rec, err := runAdmission(lim, store, cfg, func(c echo.Context) error {
+			return c.String(http.StatusOK, "ok")
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(rec.Code).To(Equal(http.StatusServiceUnavailable))
+		Expect(rec.Body.String()).To(ContainSubstring("waited too long"))
+		Expect(store.events).To(HaveLen(1))
+	})
 })
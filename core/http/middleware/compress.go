@@ -39,6 +39,8 @@ var streamingPathPrefixes = []string{
 	"/audio/transcriptions",
 	"/api/chat",
 	"/api/generate",
+	"/api/pull",
+	"/api/create",
 	"/api/agent/jobs",
 	"/api/backend-logs",
 	"/api/node-backend-logs",
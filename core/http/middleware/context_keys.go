@@ -47,4 +47,18 @@ const (
 	// router nor the body-parse path has produced one. Distinct from
 	// ContextKeyServedModel, which is the router's resolved choice.
 	ContextKeyResponseModel = "routing.response_model"
+
+	// ContextKeyCachedTokens / ContextKeyAudioSeconds / ContextKeyImages
+	// carry the non-token units a model's price table can charge for:
+	// prompt tokens served from the prompt cache, seconds of transcribed
+	// or generated audio, and generated images. UsageMiddleware copies
+	// them into the UsageRecord so the billing recorder can price them.
+	ContextKeyCachedTokens = "routing.cached_tokens"
+	ContextKeyAudioSeconds = "routing.audio_seconds"
+	ContextKeyImages       = "routing.images"
+
+	// ContextKeyFallbackAttempts is set by ModelFallback to the
+	// []FallbackAttempt of a request that was retried on a fallback
+	// model. TraceMiddleware copies it into the API trace.
+	ContextKeyFallbackAttempts = "routing.fallback_attempts"
 )
@@ -0,0 +1,300 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/pkg/tracing"
+	"github.com/mudler/xlog"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ServedModelHeader names the model that answered a request after its
+// own model failed and ModelFallback retried it on a fallback. It is
+// absent when the requested model answered.
+const ServedModelHeader = "X-LocalAI-Served-Model"
+
+// contextKeyFallbackState carries the *fallbackState of the running
+// attempt to the handler, for WillFallBack.
+const contextKeyFallbackState = "routing.fallback_state"
+
+// FallbackAttempt is one model a request was tried on: the failed ones
+// carry the error that made ModelFallback move on.
+type FallbackAttempt struct {
+	Model string `json:"model"`
+	Error string `json:"error,omitempty"`
+}
+
+// fallbackErrorMarkers are the failures, spotted in the error text, that
+// another model may not share. Backend errors reach the handlers
+// flattened into strings more often than not (fmt.Errorf with %s, the
+// gRPC status rendered by the backend), so matching on the text is the
+// only check that holds across backends.
+var fallbackErrorMarkers = []string{
+	// pkg/model: the model or its backend could not be started.
+	"could not load model",
+	"failed to load model",
+	"grpc service not ready",
+	"backend not found",
+	"no grpc backend found",
+	// The backend process went away mid-request.
+	"code = unavailable",
+	"connection refused",
+	// Timeouts.
+	"code = deadlineexceeded",
+	"context deadline exceeded",
+	// Context overflow: llama.cpp, the compression middleware and
+	// OpenAI-compatible upstreams behind cloud-proxy.
+	"exceeds the available context size",
+	"exceeds context size",
+	"maximum context length",
+}
+
+// IsFallbackError reports whether err is a failure of the model rather
+// than of the request: the model failed to load, its backend is
+// unavailable, it timed out or the prompt overflowed its context. A
+// request that failed that way may succeed on another model; a bad
+// request or a cancelled one would fail on any.
+func IsFallbackError(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var he *echo.HTTPError
+	if errors.As(err, &he) {
+		switch {
+		case he.Code == http.StatusRequestEntityTooLarge:
+			// The compression middleware's context overflow.
+			return true
+		case he.Code < http.StatusInternalServerError:
+			return false
+		}
+	}
+	if st, ok := status.FromError(err); ok {
+		switch st.Code() {
+		case codes.Unavailable, codes.DeadlineExceeded:
+			return true
+		}
+	}
+	msg := strings.ToLower(err.Error())
+	for _, marker := range fallbackErrorMarkers {
+		if strings.Contains(msg, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// fallbackWriter holds the status line of an attempt back until its
+// first body byte. The handlers write nothing before the backend
+// produced a token, so an attempt that fails earlier leaves nothing on
+// the wire and the next model starts the response from scratch.
+type fallbackWriter struct {
+	http.ResponseWriter
+	status  int
+	started bool
+}
+
+func (w *fallbackWriter) start() {
+	if w.started {
+		return
+	}
+	w.started = true
+	if w.status != 0 {
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+}
+
+func (w *fallbackWriter) WriteHeader(code int) {
+	if w.started {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	w.status = code
+}
+
+func (w *fallbackWriter) Write(b []byte) (int, error) {
+	w.start()
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush is a no-op until the attempt has written: flushing would send
+// the held status line.
+func (w *fallbackWriter) Flush() {
+	if !w.started {
+		return
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *fallbackWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// fallbackState is what the running attempt needs to decide whether a
+// failure ends the request or moves it to the next model.
+type fallbackState struct {
+	writer *fallbackWriter
+	next   *config.ModelConfig
+}
+
+func (s *fallbackState) retries(c echo.Context, err error) bool {
+	return s.next != nil && !s.writer.started &&
+		c.Request().Context().Err() == nil && IsFallbackError(err)
+}
+
+// WillFallBack reports whether ModelFallback retries the request on
+// the next model of its chain after err. Streaming handlers ask before
+// writing an in-band error event: when it does, they return err
+// instead, and the client never sees the failed attempt.
+func WillFallBack(c echo.Context, err error) bool {
+	s, ok := c.Get(contextKeyFallbackState).(*fallbackState)
+	return ok && s.retries(c, err)
+}
+
+// ModelFallback retries chat and completion requests on the models
+// listed in the fallbacks of the requested model, in order, when an
+// attempt fails with an IsFallbackError error before writing anything.
+// A streamed request therefore only falls back before its first token.
+//
+// Each retry starts from the request as it reached this middleware:
+// the handler extends the request it is given (tools, injected
+// prompts, grammars), so the original is restored, pointed at the
+// fallback model and merged into the fallback's config, as
+// SetOpenAIRequest does for the requested model. The response carries
+// the fallback's name in its model field and in ServedModelHeader, and
+// RequestedModel/ServedModel are stamped for the usage log.
+//
+// Every attempt is traced as a model.attempt span, and the list of
+// attempts goes into the API trace.
+//
+// It runs innermost in the chain, after PII redaction and the response
+// cache: the retries see the request the handler saw, and the writer
+// wrappers of the outer middlewares only ever see the answering attempt.
+func ModelFallback(loader *config.ModelConfigLoader, appConfig *config.ApplicationConfig) echo.MiddlewareFunc {
+	// nextFallback returns the config of the first loadable model of
+	// chain, and the rest of the chain after it. A fallback naming an
+	// alias gets the config of its target, as a request would.
+	nextFallback := func(chain []string) (*config.ModelConfig, []string) {
+		for i, name := range chain {
+			if _, exists := loader.GetModelConfig(name); !exists {
+				xlog.Warn("model fallback: unknown fallback model, skipping", "model", name)
+				continue
+			}
+			cfg, err := loader.LoadResolvedModelConfig(name, appConfig.SystemState.Model.ModelsPath, appConfig.ToConfigLoaderOptions()...)
+			if err != nil {
+				xlog.Warn("model fallback: cannot load fallback config, skipping", "model", name, "error", err)
+				continue
+			}
+			return cfg, chain[i+1:]
+		}
+		return nil, nil
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			cfg, ok := c.Get(CONTEXT_LOCALS_KEY_MODEL_CONFIG).(*config.ModelConfig)
+			if !ok || cfg == nil || len(cfg.Fallbacks) == 0 {
+				return next(c)
+			}
+			input, ok := c.Get(CONTEXT_LOCALS_KEY_LOCALAI_REQUEST).(*schema.OpenAIRequest)
+			if !ok || input == nil {
+				return next(c)
+			}
+
+			original := cloneOpenAIRequest(input)
+			origWriter := c.Response().Writer
+			defer func() {
+				c.Response().Writer = origWriter
+			}()
+
+			state := &fallbackState{}
+			c.Set(contextKeyFallbackState, state)
+			defer c.Set(contextKeyFallbackState, nil)
+
+			model := cfg.Name
+			var attempts []FallbackAttempt
+			var remaining []string
+			state.next, remaining = nextFallback(cfg.Fallbacks)
+			for {
+				w := &fallbackWriter{ResponseWriter: origWriter}
+				c.Response().Writer = w
+				state.writer = w
+
+				_, span := tracing.Start(c.Request().Context(), "model.attempt", trace.WithAttributes(
+					attribute.String("localai.model", model),
+					attribute.Int("localai.attempt", len(attempts)+1),
+				))
+				err := next(c)
+				retry := state.retries(c, err)
+				span.SetAttributes(attribute.Bool("localai.fallback", retry))
+				tracing.End(span, err)
+
+				if !retry {
+					if err == nil {
+						w.start()
+					}
+					if len(attempts) > 0 {
+						last := FallbackAttempt{Model: model}
+						if err != nil {
+							last.Error = err.Error()
+						}
+						c.Set(ContextKeyFallbackAttempts, append(attempts, last))
+					}
+					return err
+				}
+
+				fallback := state.next
+				xlog.Warn("model fallback: attempt failed, retrying on the next model",
+					"model", model, "fallback", fallback.Name, "error", err)
+				attempts = append(attempts, FallbackAttempt{Model: model, Error: err.Error()})
+				model = fallback.Name
+
+				*input = cloneOpenAIRequest(&original)
+				input.ModelName(&model)
+				if err := mergeOpenAIRequestAndModelConfig(fallback, input); err != nil {
+					return err
+				}
+				if fallback.Model == "" {
+					fallback.Model = model
+				}
+				c.Set(CONTEXT_LOCALS_KEY_MODEL_CONFIG, fallback)
+				if c.Get(ContextKeyRequestedModel) == nil {
+					c.Set(ContextKeyRequestedModel, original.Model)
+				}
+				c.Set(ContextKeyServedModel, model)
+				c.Response().Header().Set(ServedModelHeader, model)
+				// The failed attempt may have set the status on echo's
+				// Response; the held writer kept it off the wire.
+				c.Response().Committed = false
+				c.Response().Status = http.StatusOK
+				c.Response().Size = 0
+
+				state.next, remaining = nextFallback(remaining)
+			}
+		}
+	}
+}
+
+// cloneOpenAIRequest copies r deep enough that the handler's appends
+// and element rewrites on the copy do not reach r.
+func cloneOpenAIRequest(r *schema.OpenAIRequest) schema.OpenAIRequest {
+	out := *r
+	out.Messages = slices.Clone(r.Messages)
+	out.Tools = slices.Clone(r.Tools)
+	out.Functions = slices.Clone(r.Functions)
+	return out
+}
@@ -0,0 +1,186 @@
+package middleware_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/mudler/LocalAI/core/config"
+	. "github.com/mudler/LocalAI/core/http/middleware"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/pkg/system"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ModelFallback", func() {
+	var (
+		modelDir  string
+		loader    *config.ModelConfigLoader
+		appConfig *config.ApplicationConfig
+		failures  map[string]error
+		tried     []string
+		seen      []int
+	)
+
+	// handler answers like the chat endpoint: it extends the request it
+	// is given, and a streamed attempt only writes once it has a token.
+	handler := func(c echo.Context) error {
+		cfg := c.Get(CONTEXT_LOCALS_KEY_MODEL_CONFIG).(*config.ModelConfig)
+		input := c.Get(CONTEXT_LOCALS_KEY_LOCALAI_REQUEST).(*schema.OpenAIRequest)
+		tried = append(tried, cfg.Name)
+		seen = append(seen, len(input.Messages))
+		input.Messages = append(input.Messages, schema.Message{Role: "system", Content: "injected"})
+
+		err := failures[cfg.Name]
+		if !input.Stream {
+			if err != nil {
+				return err
+			}
+			return c.JSON(http.StatusOK, map[string]any{"model": input.Model})
+		}
+		c.Response().Header().Set("Content-Type", "text/event-stream")
+		if errors.Is(err, errMidStream) {
+			fmt.Fprintf(c.Response().Writer, "data: {\"model\":%q}\n\n", input.Model)
+			c.Response().Flush()
+		}
+		if err != nil {
+			if WillFallBack(c, err) {
+				return err
+			}
+			fmt.Fprintf(c.Response().Writer, "data: {\"error\":{\"message\":%q}}\n\n", err.Error())
+			fmt.Fprint(c.Response().Writer, "data: [DONE]\n\n")
+			return nil
+		}
+		fmt.Fprintf(c.Response().Writer, "data: {\"model\":%q}\n\n", input.Model)
+		fmt.Fprint(c.Response().Writer, "data: [DONE]\n\n")
+		c.Response().Flush()
+		return nil
+	}
+
+	run := func(stream bool) (*httptest.ResponseRecorder, echo.Context, error) {
+		req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader("{}"))
+		rec := httptest.NewRecorder()
+		c := echo.New().NewContext(req, rec)
+		cfg, err := loader.LoadModelConfigFileByNameDefaultOptions("primary", appConfig)
+		Expect(err).ToNot(HaveOccurred())
+		input := &schema.OpenAIRequest{Messages: []schema.Message{{Role: "user", Content: "hi"}}, Stream: stream}
+		input.Model = "primary"
+		c.Set(CONTEXT_LOCALS_KEY_MODEL_CONFIG, cfg)
+		c.Set(CONTEXT_LOCALS_KEY_LOCALAI_REQUEST, input)
+		err = ModelFallback(loader, appConfig)(handler)(c)
+		return rec, c, err
+	}
+
+	BeforeEach(func() {
+		var err error
+		modelDir, err = os.MkdirTemp("", "localai-fallback-*")
+		Expect(err).ToNot(HaveOccurred())
+		for name, body := range map[string]string{
+			"primary":  "name: primary\nbackend: llama-cpp\nfallbacks: [missing, backup-a, backup-b]\n",
+			"backup-a": "name: backup-a\nbackend: llama-cpp\n",
+			"backup-b": "name: backup-b\nbackend: llama-cpp\n",
+		} {
+			Expect(os.WriteFile(filepath.Join(modelDir, name+".yaml"), []byte(body), 0644)).To(Succeed())
+		}
+		appConfig = config.NewApplicationConfig()
+		appConfig.SystemState = &system.SystemState{Model: system.Model{ModelsPath: modelDir}}
+		loader = config.NewModelConfigLoader(modelDir)
+		Expect(loader.LoadModelConfigsFromPath(modelDir)).To(Succeed())
+		failures = map[string]error{}
+		tried, seen = nil, nil
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(modelDir)
+	})
+
+	It("retries a failed request on the next known fallback", func() {
+		failures["primary"] = errors.New("could not load model: rpc error: code = Unavailable desc = connection refused")
+		rec, c, err := run(false)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(tried).To(Equal([]string{"primary", "backup-a"}))
+		Expect(seen).To(Equal([]int{1, 1}), "the retry starts from the original request")
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		Expect(rec.Header().Get(ServedModelHeader)).To(Equal("backup-a"))
+		Expect(rec.Body.String()).To(ContainSubstring(`"model":"backup-a"`))
+		Expect(c.Get(ContextKeyRequestedModel)).To(Equal("primary"))
+		Expect(c.Get(ContextKeyServedModel)).To(Equal("backup-a"))
+		Expect(c.Get(ContextKeyFallbackAttempts)).To(Equal([]FallbackAttempt{
+			{Model: "primary", Error: failures["primary"].Error()},
+			{Model: "backup-a"},
+		}))
+	})
+
+	It("falls back on the target of an aliased fallback", func() {
+		Expect(os.WriteFile(filepath.Join(modelDir, "primary.yaml"), []byte("name: primary\nbackend: llama-cpp\nfallbacks: [backup]\n"), 0644)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(modelDir, "backup.yaml"), []byte("name: backup\nalias: backup-b\n"), 0644)).To(Succeed())
+		Expect(loader.LoadModelConfigsFromPath(modelDir)).To(Succeed())
+
+		failures["primary"] = errors.New("grpc service not ready")
+		rec, c, err := run(false)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(tried).To(Equal([]string{"primary", "backup-b"}))
+		Expect(c.Get(CONTEXT_LOCALS_KEY_MODEL_CONFIG).(*config.ModelConfig).Backend).To(Equal("llama-cpp"))
+		Expect(rec.Header().Get(ServedModelHeader)).To(Equal("backup-b"))
+	})
+
+	It("returns the last error when every model fails", func() {
+		failures["primary"] = context.DeadlineExceeded
+		failures["backup-a"] = status.Error(codes.Unavailable, "backend gone")
+		failures["backup-b"] = errors.New("the request exceeds the available context size")
+		_, _, err := run(false)
+		Expect(err).To(MatchError(failures["backup-b"]))
+		Expect(tried).To(Equal([]string{"primary", "backup-a", "backup-b"}))
+	})
+
+	It("does not retry errors of the request", func() {
+		failures["primary"] = echo.NewHTTPError(http.StatusBadRequest, "invalid tool_choice")
+		_, _, err := run(false)
+		Expect(err).To(HaveOccurred())
+		Expect(tried).To(Equal([]string{"primary"}))
+	})
+
+	It("falls back on a stream that failed before its first token", func() {
+		failures["primary"] = errors.New("grpc service not ready")
+		rec, _, err := run(true)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(tried).To(Equal([]string{"primary", "backup-a"}))
+		Expect(rec.Header().Get(ServedModelHeader)).To(Equal("backup-a"))
+		Expect(rec.Body.String()).To(Equal("data: {\"model\":\"backup-a\"}\n\ndata: [DONE]\n\n"))
+	})
+
+	It("does not fall back once a token was streamed", func() {
+		failures["primary"] = errMidStream
+		rec, _, err := run(true)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(tried).To(Equal([]string{"primary"}))
+		Expect(rec.Header().Get(ServedModelHeader)).To(BeEmpty())
+		Expect(rec.Body.String()).To(ContainSubstring(`"error"`))
+	})
+
+	DescribeTable("IsFallbackError",
+		func(err error, want bool) {
+			Expect(IsFallbackError(err)).To(Equal(want))
+		},
+		Entry("load failure", errors.New("could not load model - all backends returned error"), true),
+		Entry("gRPC unavailable", status.Error(codes.Unavailable, "eof"), true),
+		Entry("flattened gRPC deadline", errors.New("rpc error: code = DeadlineExceeded desc = timeout"), true),
+		Entry("compression overflow", echo.NewHTTPError(http.StatusRequestEntityTooLarge, "too long"), true),
+		Entry("client cancellation", fmt.Errorf("predict: %w", context.Canceled), false),
+		Entry("bad request", echo.NewHTTPError(http.StatusBadRequest, "could not load model"), false),
+		Entry("other server error", errors.New("template rendering failed"), false),
+	)
+})
+
+// errMidStream fails a streamed attempt after its first token.
+var errMidStream = errors.New("could not load model: backend crashed mid-stream")
@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/labstack/echo/v4"
+	"github.com/mudler/LocalAI/pkg/tracing"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var _ = Describe("OTelTracing", func() {
+	var (
+		e        *echo.Echo
+		recorder *tracetest.SpanRecorder
+		seen     trace.SpanContext
+	)
+
+	BeforeEach(func() {
+		_, err := tracing.Setup(context.Background(), tracing.Config{})
+		Expect(err).ToNot(HaveOccurred())
+		recorder = tracetest.NewSpanRecorder()
+		prev := otel.GetTracerProvider()
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+		DeferCleanup(func() { otel.SetTracerProvider(prev) })
+
+		e = echo.New()
+		e.Use(OTelTracing(func(c echo.Context) bool { return c.Path() == "/healthz" }))
+		handler := func(c echo.Context) error {
+			seen = trace.SpanContextFromContext(c.Request().Context())
+			return c.String(http.StatusOK, "ok")
+		}
+		e.GET("/v1/models/:id", handler)
+		e.GET("/healthz", handler)
+		e.GET("/boom", func(c echo.Context) error {
+			return echo.NewHTTPError(http.StatusBadGateway, "upstream down")
+		})
+	})
+
+	It("continues the caller's trace and names the span after the route", func() {
+		req := httptest.NewRequest(http.MethodGet, "/v1/models/llama", nil)
+		req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+		e.ServeHTTP(httptest.NewRecorder(), req)
+
+		spans := recorder.Ended()
+		Expect(spans).To(HaveLen(1))
+		span := spans[0]
+		Expect(span.Name()).To(Equal("GET /v1/models/:id"))
+		Expect(span.SpanKind()).To(Equal(trace.SpanKindServer))
+		Expect(span.SpanContext().TraceID().String()).To(Equal("4bf92f3577b34da6a3ce929d0e0e4736"))
+		Expect(span.Parent().SpanID().String()).To(Equal("00f067aa0ba902b7"))
+		Expect(span.Attributes()).To(ContainElement(attribute.Int("http.response.status_code", http.StatusOK)))
+		Expect(seen.SpanID()).To(Equal(span.SpanContext().SpanID()))
+	})
+
+	It("marks server errors with the status echo will send", func() {
+		e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/boom", nil))
+
+		spans := recorder.Ended()
+		Expect(spans).To(HaveLen(1))
+		Expect(spans[0].Attributes()).To(ContainElement(attribute.Int("http.response.status_code", http.StatusBadGateway)))
+		Expect(spans[0].Status().Code).To(Equal(codes.Error))
+	})
+
+	It("leaves skipped paths untraced", func() {
+		e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/healthz", nil))
+		Expect(recorder.Ended()).To(BeEmpty())
+		Expect(seen.IsValid()).To(BeFalse())
+	})
+})
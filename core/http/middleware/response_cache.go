@@ -0,0 +1,225 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/http/auth"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/core/services/routing/responsecache"
+	"github.com/mudler/xlog"
+)
+
+// ResponseCacheHeader reports how the response cache handled a request:
+// hit, semantic_hit, miss or bypass.
+const ResponseCacheHeader = "X-LocalAI-Cache"
+
+// maxCachedResponseBytes bounds the responses the cache stores. Larger
+// ones are served but not cached.
+const maxCachedResponseBytes = 1 << 20
+
+// ResponseCache serves chat and completion requests to models with a
+// response_cache block from the cache, and caches the responses of the
+// requests it misses.
+//
+// It runs last in the chain, after PII redaction: the cache key is
+// derived from the request the handler would see, and a pseudonymized
+// response is restored by the PII writer on every replay the same way
+// it was the first time.
+//
+// Entries are scoped to the user (the fallback user when auth is off)
+// unless the model sets shared. Clients bypass the cache with the
+// Cache-Control request header: no-cache skips the lookup but caches
+// the fresh response, no-store skips both.
+//
+// A hit is recorded with the usage of the original request, its prompt
+// tokens counted as cached tokens.
+func ResponseCache(cache *responsecache.Cache, embedders EmbedderFactory, stores VectorStoreFactory, fallbackUser *auth.User) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if cache == nil {
+				return next(c)
+			}
+			cfg, ok := c.Get(CONTEXT_LOCALS_KEY_MODEL_CONFIG).(*config.ModelConfig)
+			if !ok || cfg == nil || !cfg.ResponseCache.Enabled {
+				return next(c)
+			}
+			input, ok := c.Get(CONTEXT_LOCALS_KEY_LOCALAI_REQUEST).(*schema.OpenAIRequest)
+			if !ok || input == nil {
+				return next(c)
+			}
+			rc := cfg.ResponseCache
+
+			noCache, noStore := cacheControlDirectives(c.Request().Header.Get("Cache-Control"))
+			if noStore {
+				responsecache.RecordRequest(cfg.Name, responsecache.ResultBypass)
+				c.Response().Header().Set(ResponseCacheHeader, responsecache.ResultBypass)
+				return next(c)
+			}
+
+			scope := ""
+			if !rc.Shared {
+				scope = responseCacheUser(c, fallbackUser)
+			}
+			keys, err := responsecache.NewKeys(scope, input)
+			if err != nil {
+				xlog.Debug("response cache: cannot key request", "model", cfg.Name, "error", err)
+				return next(c)
+			}
+			ix := semanticIndex(cfg, keys, embedders, stores)
+			ctx := c.Request().Context()
+
+			var vec []float32
+			result := responsecache.ResultBypass
+			if !noCache {
+				if e, ok := cache.Get(cfg.Name, keys.Exact); ok {
+					responsecache.RecordRequest(cfg.Name, responsecache.ResultHit)
+					return serveCachedResponse(c, e, responsecache.ResultHit)
+				}
+				if ix != nil {
+					if vec, err = ix.Embed(ctx, keys); err != nil {
+						xlog.Debug("response cache: embedding failed", "model", cfg.Name, "error", err)
+						ix = nil
+					} else if key, sim, found, err := ix.Lookup(ctx, vec, keys); err != nil {
+						xlog.Debug("response cache: vector search failed", "model", cfg.Name, "error", err)
+					} else if found {
+						if e, ok := cache.Get(cfg.Name, key); ok {
+							xlog.Debug("response cache: semantic hit", "model", cfg.Name, "similarity", sim)
+							responsecache.RecordRequest(cfg.Name, responsecache.ResultSemanticHit)
+							return serveCachedResponse(c, e, responsecache.ResultSemanticHit)
+						}
+					}
+				}
+				result = responsecache.ResultMiss
+			}
+			responsecache.RecordRequest(cfg.Name, result)
+			c.Response().Header().Set(ResponseCacheHeader, result)
+
+			resBody := new(bytes.Buffer)
+			origWriter := c.Response().Writer
+			bw := &bodyWriter{ResponseWriter: origWriter, body: resBody, maxBytes: maxCachedResponseBytes}
+			c.Response().Writer = bw
+			handlerErr := next(c)
+			c.Response().Writer = origWriter
+
+			// A fallback model's answer is not cached under the model
+			// that failed to give it.
+			if handlerErr != nil || c.Response().Status != http.StatusOK || bw.truncated ||
+				c.Response().Header().Get(ServedModelHeader) != "" {
+				return handlerErr
+			}
+			entry, ok := responsecache.NewResponse(c.Response().Header().Get(echo.HeaderContentType), resBody.Bytes(), input.Stream)
+			if !ok {
+				return nil
+			}
+			model, prompt, completion, _, _ := tokensFromContext(c)
+			if model == "" {
+				model = input.Model
+			}
+			entry.Model = model
+			entry.PromptTokens, entry.CompletionTokens = int(prompt), int(completion)
+			cache.Put(cfg.Name, keys.Exact, entry, time.Duration(rc.TTLSeconds)*time.Second, rc.MaxEntries)
+
+			if ix != nil {
+				// The response is already written: index it off the
+				// request path.
+				go func(vec []float32) {
+					ctx := context.Background()
+					if vec == nil {
+						var err error
+						if vec, err = ix.Embed(ctx, keys); err != nil {
+							xlog.Debug("response cache: embedding failed", "model", cfg.Name, "error", err)
+							return
+						}
+					}
+					if err := ix.Insert(ctx, vec, keys, entry); err != nil {
+						xlog.Debug("response cache: vector insert failed", "model", cfg.Name, "error", err)
+					}
+				}(vec)
+			}
+			return nil
+		}
+	}
+}
+
+// serveCachedResponse replays e, flushing a streamed response event by
+// event like the handler it was recorded from.
+func serveCachedResponse(c echo.Context, e *responsecache.Response, result string) error {
+	h := c.Response().Header()
+	h.Set(ResponseCacheHeader, result)
+	h.Set("Age", strconv.Itoa(int(time.Since(e.StoredAt).Seconds())))
+	h.Set(echo.HeaderContentType, e.ContentType)
+	if e.Stream {
+		h.Set("Cache-Control", "no-cache")
+		h.Set("Connection", "keep-alive")
+	}
+	StampUsage(c, e.Model, e.PromptTokens, e.CompletionTokens)
+	StampCachedTokens(c, e.PromptTokens)
+
+	c.Response().WriteHeader(http.StatusOK)
+	for _, chunk := range e.Replay(uuid.New().String(), time.Now().Unix()) {
+		if _, err := c.Response().Write(chunk); err != nil {
+			return err
+		}
+		if e.Stream {
+			c.Response().Flush()
+		}
+	}
+	return nil
+}
+
+// semanticIndex returns the similarity index of the model's cache, or
+// nil when the model has no semantic block, the request can't be
+// embedded, or the embedding model or store can't be loaded.
+func semanticIndex(cfg *config.ModelConfig, keys responsecache.Keys, embedders EmbedderFactory, stores VectorStoreFactory) *responsecache.Index {
+	sc := cfg.ResponseCache.Semantic
+	if sc == nil || keys.Text == "" || embedders == nil || stores == nil {
+		return nil
+	}
+	embedder := embedders(sc.EmbeddingModel)
+	if embedder == nil {
+		xlog.Debug("response cache: embedding model not loadable", "model", cfg.Name, "embedding_model", sc.EmbeddingModel)
+		return nil
+	}
+	storeName := sc.StoreName
+	if storeName == "" {
+		storeName = "response-cache-" + cfg.Name
+	}
+	store := stores(storeName)
+	if store == nil {
+		return nil
+	}
+	return responsecache.NewIndex(embedder, store, sc.SimilarityThreshold)
+}
+
+// responseCacheUser is the scope of the caller's cache entries.
+func responseCacheUser(c echo.Context, fallbackUser *auth.User) string {
+	if u := auth.GetUser(c); u != nil {
+		return u.ID
+	}
+	if fallbackUser != nil {
+		return fallbackUser.ID
+	}
+	return ""
+}
+
+// cacheControlDirectives reads the bypass directives of a Cache-Control
+// request header.
+func cacheControlDirectives(header string) (noCache, noStore bool) {
+	for _, d := range strings.Split(header, ",") {
+		switch strings.ToLower(strings.TrimSpace(d)) {
+		case "no-cache":
+			noCache = true
+		case "no-store":
+			noStore = true
+		}
+	}
+	return noCache, noStore
+}
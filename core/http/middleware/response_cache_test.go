@@ -0,0 +1,122 @@
+package middleware_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/mudler/LocalAI/core/config"
+	. "github.com/mudler/LocalAI/core/http/middleware"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/core/services/routing/responsecache"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ResponseCache", func() {
+	var (
+		cache *responsecache.Cache
+		cfg   *config.ModelConfig
+		calls int
+	)
+
+	// handler answers like the chat endpoint, with a new id per call.
+	handler := func(c echo.Context) error {
+		calls++
+		input := c.Get(CONTEXT_LOCALS_KEY_LOCALAI_REQUEST).(*schema.OpenAIRequest)
+		StampUsage(c, input.Model, 12, 3)
+		id := fmt.Sprintf("id-%d", calls)
+		if !input.Stream {
+			return c.JSON(http.StatusOK, map[string]any{
+				"id": id, "created": 100 + calls, "model": input.Model,
+				"choices": []any{map[string]any{"message": map[string]any{"role": "assistant", "content": "Hello"}}},
+			})
+		}
+		c.Response().Header().Set("Content-Type", "text/event-stream")
+		c.Response().WriteHeader(http.StatusOK)
+		for _, chunk := range []string{"Hel", "lo"} {
+			fmt.Fprintf(c.Response(), "data: {\"id\":%q,\"created\":%d,\"choices\":[{\"delta\":{\"content\":%q}}]}\n\n", id, 100+calls, chunk)
+			c.Response().Flush()
+		}
+		fmt.Fprint(c.Response(), "data: [DONE]\n\n")
+		return nil
+	}
+
+	run := func(content string, stream bool, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader("{}"))
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		c := echo.New().NewContext(req, rec)
+		input := &schema.OpenAIRequest{Messages: []schema.Message{{Role: "user", Content: content}}, Stream: stream}
+		input.Model = "m"
+		c.Set(CONTEXT_LOCALS_KEY_MODEL_CONFIG, cfg)
+		c.Set(CONTEXT_LOCALS_KEY_LOCALAI_REQUEST, input)
+		Expect(ResponseCache(cache, nil, nil, nil)(handler)(c)).To(Succeed())
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		return rec
+	}
+
+	BeforeEach(func() {
+		cache = responsecache.New()
+		cfg = &config.ModelConfig{ResponseCache: config.ResponseCacheConfig{Enabled: true}}
+		cfg.Name = "m"
+		calls = 0
+	})
+
+	It("serves a repeated request from the cache with a new id", func() {
+		first := run("hi", false, nil)
+		Expect(first.Header().Get(ResponseCacheHeader)).To(Equal("miss"))
+		Expect(first.Body.String()).To(ContainSubstring(`"id":"id-1"`))
+
+		second := run("  hi\n", false, nil)
+		Expect(calls).To(Equal(1))
+		Expect(second.Header().Get(ResponseCacheHeader)).To(Equal("hit"))
+		Expect(second.Header().Get("Content-Type")).To(ContainSubstring("application/json"))
+		Expect(second.Body.String()).To(ContainSubstring(`"content":"Hello"`))
+		Expect(second.Body.String()).ToNot(ContainSubstring(`"id":"id-1"`))
+
+		run("hello", false, nil)
+		Expect(calls).To(Equal(2))
+	})
+
+	It("replays streamed responses as a stream", func() {
+		run("hi", true, nil)
+		rec := run("hi", true, nil)
+		Expect(calls).To(Equal(1))
+		Expect(rec.Header().Get(ResponseCacheHeader)).To(Equal("hit"))
+		Expect(rec.Header().Get("Content-Type")).To(Equal("text/event-stream"))
+		Expect(strings.Count(rec.Body.String(), "data: ")).To(Equal(3))
+		Expect(rec.Body.String()).To(HaveSuffix("data: [DONE]\n\n"))
+
+		// A non-streamed request is not served the stream
+		run("hi", false, nil)
+		Expect(calls).To(Equal(2))
+	})
+
+	It("honours the Cache-Control bypass directives", func() {
+		run("hi", false, nil)
+
+		rec := run("hi", false, map[string]string{"Cache-Control": "no-cache"})
+		Expect(rec.Header().Get(ResponseCacheHeader)).To(Equal("bypass"))
+		Expect(calls).To(Equal(2))
+		Expect(run("hi", false, nil).Body.String()).To(ContainSubstring("Hello"))
+		Expect(calls).To(Equal(2))
+
+		run("other", false, map[string]string{"Cache-Control": "no-store"})
+		run("other", false, nil)
+		Expect(calls).To(Equal(4))
+	})
+
+	It("passes through models without a response cache", func() {
+		cfg.ResponseCache.Enabled = false
+		run("hi", false, nil)
+		rec := run("hi", false, nil)
+		Expect(calls).To(Equal(2))
+		Expect(rec.Header().Get(ResponseCacheHeader)).To(BeEmpty())
+	})
+})
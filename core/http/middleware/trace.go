@@ -10,6 +10,7 @@ import (
 	"path/filepath"
 	"slices"
 	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -57,6 +58,10 @@ type APIExchange struct {
 	// UI so an operator can tell who/what issued each request.
 	ClientIP  string `json:"client_ip,omitempty"`
 	UserAgent string `json:"user_agent,omitempty"`
+	// Fallbacks lists the models the request was tried on when its
+	// model failed and ModelFallback retried it, the answering one last.
+	// A pointer, like the bodies, to keep APIExchange comparable.
+	Fallbacks *[]FallbackAttempt `json:"fallbacks,omitempty"`
 }
 
 var traceBuffer *circularbuffer.Queue[APIExchange]
@@ -158,6 +163,9 @@ type bodyWriter struct {
 	maxBytes   int // 0 = unlimited capture
 	truncated  bool
 	totalBytes int // bytes the upstream handler wrote, even past the cap
+	// textOnly skips capture for responses that are neither JSON nor
+	// SSE (audio, images, archives): nothing downstream parses them.
+	textOnly bool
 }
 
 func (w *bodyWriter) Write(b []byte) (int, error) {
@@ -165,6 +173,9 @@ func (w *bodyWriter) Write(b []byte) (int, error) {
 	// so a chatty endpoint can't grow the buffer without bound. The full
 	// payload still flows through to the real client below.
 	w.totalBytes += len(b)
+	if w.textOnly && !isTextualContentType(w.Header().Get("Content-Type")) {
+		return w.ResponseWriter.Write(b)
+	}
 	if w.maxBytes <= 0 {
 		w.body.Write(b)
 	} else if remain := w.maxBytes - w.body.Len(); remain > 0 {
@@ -180,6 +191,13 @@ func (w *bodyWriter) Write(b []byte) (int, error) {
 	return w.ResponseWriter.Write(b)
 }
 
+// isTextualContentType reports whether a response of this type can carry
+// a usage block. An unset type counts, since the handler may not have
+// declared one yet.
+func isTextualContentType(ct string) bool {
+	return ct == "" || strings.Contains(ct, "json") || strings.HasPrefix(ct, "text/")
+}
+
 func (w *bodyWriter) Flush() {
 	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
 		flusher.Flush()
@@ -335,6 +353,9 @@ func TraceMiddleware(app *application.Application) echo.MiddlewareFunc {
 			if handlerErr != nil {
 				exchange.Error = handlerErr.Error()
 			}
+			if attempts, ok := c.Get(ContextKeyFallbackAttempts).([]FallbackAttempt); ok {
+				exchange.Fallbacks = &attempts
+			}
 
 			mu.Lock()
 			store := traceStore
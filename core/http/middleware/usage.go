@@ -18,9 +18,12 @@ import (
 type usageResponseBody struct {
 	Model string `json:"model"`
 	Usage *struct {
-		PromptTokens     int64 `json:"prompt_tokens"`
-		CompletionTokens int64 `json:"completion_tokens"`
-		TotalTokens      int64 `json:"total_tokens"`
+		PromptTokens        int64 `json:"prompt_tokens"`
+		CompletionTokens    int64 `json:"completion_tokens"`
+		TotalTokens         int64 `json:"total_tokens"`
+		PromptTokensDetails *struct {
+			CachedTokens int64 `json:"cached_tokens"`
+		} `json:"prompt_tokens_details"`
 	} `json:"usage"`
 }
 
@@ -28,8 +31,9 @@ type usageResponseBody struct {
 type anthropicResponseBody struct {
 	Model string `json:"model"`
 	Usage *struct {
-		InputTokens  int64 `json:"input_tokens"`
-		OutputTokens int64 `json:"output_tokens"`
+		InputTokens          int64 `json:"input_tokens"`
+		OutputTokens         int64 `json:"output_tokens"`
+		CacheReadInputTokens int64 `json:"cache_read_input_tokens"`
 	} `json:"usage"`
 }
 
@@ -45,6 +49,9 @@ type anthropicResponseBody struct {
 //     Anthropic-shaped usage block. Used by passthrough proxies and
 //     foreign endpoints.
 //
+// Responses outside 2xx are recorded only through the first path, when
+// the backend ran before the request failed.
+//
 // Recorder being nil (e.g., --disable-stats) makes the middleware a
 // transparent pass-through. fallbackUser is used when auth.GetUser(c)
 // returns nil; without it, an unauthenticated request would be dropped.
@@ -65,11 +72,14 @@ func UsageMiddleware(recorder *billing.Recorder, fallbackUser *auth.User) echo.M
 			// so the cost is the per-chunk Write going through one extra
 			// indirection — accepted overhead in exchange for one billing
 			// path that works for both stamping and body-parse callers.
+			// Binary bodies (speech audio, image files) are not buffered:
+			// those handlers stamp their usage instead.
 			resBody := new(bytes.Buffer)
 			origWriter := c.Response().Writer
 			mw := &bodyWriter{
 				ResponseWriter: origWriter,
 				body:           resBody,
+				textOnly:       true,
 			}
 			c.Response().Writer = mw
 
@@ -80,7 +90,11 @@ func UsageMiddleware(recorder *billing.Recorder, fallbackUser *auth.User) echo.M
 			endpoint := c.Request().URL.Path
 
 			if c.Response().Status < 200 || c.Response().Status >= 300 {
-				return handlerErr
+				// A failed response is only billed when its handler
+				// stamped the tokens the backend spent producing it.
+				if _, _, _, _, stamped := tokensFromContext(c); !stamped {
+					return handlerErr
+				}
 			}
 
 			user := auth.GetUser(c)
@@ -92,9 +106,19 @@ func UsageMiddleware(recorder *billing.Recorder, fallbackUser *auth.User) echo.M
 				return handlerErr
 			}
 
+			cached, audioSeconds, images := billableUnitsFromContext(c)
 			model, prompt, completion, total, ok := tokensFromContext(c)
+			if !ok && (audioSeconds > 0 || images > 0) {
+				// Media endpoints have no tokens; their stamp is the
+				// audio length or image count.
+				ok = true
+			}
 			if !ok {
-				model, prompt, completion, total, ok = tokensFromBody(resBody.Bytes(), c.Response().Header().Get("Content-Type"))
+				var bodyCached int64
+				model, prompt, completion, total, bodyCached, ok = tokensFromBody(resBody.Bytes(), c.Response().Header().Get("Content-Type"))
+				if cached == 0 {
+					cached = bodyCached
+				}
 			}
 			if !ok {
 				billing.CountUnrecorded(context.Background(), endpoint, "no_usage")
@@ -126,6 +150,9 @@ func UsageMiddleware(recorder *billing.Recorder, fallbackUser *auth.User) echo.M
 				ServedModel:            served,
 				PreFilterPromptTokens:  pre,
 				PostFilterPromptTokens: post,
+				CachedTokens:           cached,
+				AudioSeconds:           audioSeconds,
+				Images:                 images,
 				CorrelationID:          correlationIDFromContext(c),
 			}
 
@@ -175,10 +202,27 @@ func tokensFromContext(c echo.Context) (model string, prompt, completion, total
 	return
 }
 
+// billableUnitsFromContext returns the cache and media units stamped via
+// StampCachedTokens / StampMediaUsage; all zero when nothing was stamped.
+func billableUnitsFromContext(c echo.Context) (cached int64, audioSeconds float64, images int64) {
+	if v, ok := c.Get(ContextKeyCachedTokens).(int64); ok {
+		cached = v
+	}
+	if v, ok := c.Get(ContextKeyAudioSeconds).(float64); ok {
+		audioSeconds = v
+	}
+	if v, ok := c.Get(ContextKeyImages).(int64); ok {
+		images = v
+	}
+	return
+}
+
 // tokensFromBody covers the passthrough-proxy / foreign-endpoint case
-// where no handler stamps the context. Returns ok=false on any parse
-// failure or missing-usage; the caller increments the unrecorded counter.
-func tokensFromBody(responseBytes []byte, contentType string) (model string, prompt, completion, total int64, ok bool) {
+// where no handler stamps the context. cached is the provider-reported
+// prompt-cache hit count, when the usage block carries one. Returns
+// ok=false on any parse failure or missing-usage; the caller increments
+// the unrecorded counter.
+func tokensFromBody(responseBytes []byte, contentType string) (model string, prompt, completion, total, cached int64, ok bool) {
 	if len(responseBytes) == 0 {
 		return
 	}
@@ -216,6 +260,9 @@ func tokensFromBody(responseBytes []byte, contentType string) (model string, pro
 			if total == 0 {
 				total = prompt + completion
 			}
+			if openAI.Usage.PromptTokensDetails != nil {
+				cached = openAI.Usage.PromptTokensDetails.CachedTokens
+			}
 			ok = true
 			return
 		}
@@ -226,7 +273,10 @@ func tokensFromBody(responseBytes []byte, contentType string) (model string, pro
 	if err := json.Unmarshal(payload, &ant); err == nil && ant.Usage != nil {
 		if ant.Usage.InputTokens != 0 || ant.Usage.OutputTokens != 0 {
 			model = ant.Model
-			prompt = ant.Usage.InputTokens
+			// Anthropic counts cache reads outside input_tokens; fold them
+			// in so cached stays a subset of prompt, as in OpenAI's shape.
+			cached = ant.Usage.CacheReadInputTokens
+			prompt = ant.Usage.InputTokens + cached
 			completion = ant.Usage.OutputTokens
 			total = prompt + completion
 			ok = true
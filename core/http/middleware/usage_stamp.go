@@ -31,3 +31,32 @@ func StampUsage(c echo.Context, model string, prompt, completion int) {
 	c.Set(ContextKeyCompletionTokens, cp)
 	c.Set(ContextKeyTotalTokens, p+cp)
 }
+
+// StampMediaUsage records the audio seconds and image count a media
+// handler (transcription, speech, image generation) produced, so the
+// request is billed under the model's per-second or per-image rates.
+// These endpoints have no token counts; a stamp here is enough for
+// UsageMiddleware to record the request.
+func StampMediaUsage(c echo.Context, model string, audioSeconds float64, images int) {
+	if c == nil {
+		return
+	}
+	if model != "" {
+		c.Set(ContextKeyResponseModel, model)
+	}
+	if audioSeconds > 0 {
+		c.Set(ContextKeyAudioSeconds, audioSeconds)
+	}
+	if images > 0 {
+		c.Set(ContextKeyImages, int64(images))
+	}
+}
+
+// StampCachedTokens records how many of the prompt tokens were served
+// from the prompt cache, for models whose price table discounts them.
+func StampCachedTokens(c echo.Context, cached int) {
+	if c == nil || cached <= 0 {
+		return
+	}
+	c.Set(ContextKeyCachedTokens, int64(cached))
+}
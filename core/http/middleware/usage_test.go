@@ -119,6 +119,28 @@ var _ = Describe("UsageMiddleware", func() {
 		Expect(cap.records).To(BeEmpty())
 	})
 
+	It("records 5xx responses whose handler stamped the tokens it spent", func() {
+		cap := &captureBackend{}
+		rec := billing.NewRecorder(cap)
+		fallback := &auth.User{ID: "local-uuid", Name: "local"}
+
+		e := echo.New()
+		e.POST("/v1/chat/completions",
+			func(c echo.Context) error {
+				httpMiddleware.StampUsage(c, "qwen-7b", 30, 12)
+				return c.String(http.StatusInternalServerError, `{"error":"boom"}`)
+			},
+			httpMiddleware.UsageMiddleware(rec, fallback),
+		)
+		req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(`{}`))
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, req)
+		Expect(w.Code).To(Equal(http.StatusInternalServerError))
+		Expect(cap.records).To(HaveLen(1))
+		Expect(cap.records[0].PromptTokens).To(Equal(int64(30)))
+		Expect(cap.records[0].CompletionTokens).To(Equal(int64(12)))
+	})
+
 	It("records via context-stamped tokens when handler called StampUsage (streaming-safe path)", func() {
 		cap := &captureBackend{}
 		rec := billing.NewRecorder(cap)
@@ -320,4 +342,56 @@ var _ = Describe("UsageMiddleware", func() {
 		Expect(cap.records).To(HaveLen(1))
 		Expect(cap.records[0].Source).To(Equal(auth.UsageSourceWeb))
 	})
+
+	It("records media usage stamped by the handler without parsing the body", func() {
+		cap := &captureBackend{}
+		rec := billing.NewRecorder(cap)
+		fallback := &auth.User{ID: "local-uuid", Name: "local"}
+
+		speech := func(c echo.Context) error {
+			httpMiddleware.StampMediaUsage(c, "piper", 2.5, 0)
+			return c.Blob(http.StatusOK, "audio/wav", make([]byte, 1024))
+		}
+
+		e := echo.New()
+		e.POST("/v1/audio/speech", speech, httpMiddleware.UsageMiddleware(rec, fallback))
+
+		req := httptest.NewRequest(http.MethodPost, "/v1/audio/speech", strings.NewReader(`{}`))
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, req)
+
+		Expect(w.Code).To(Equal(http.StatusOK))
+		Expect(w.Body.Len()).To(Equal(1024))
+		Expect(cap.records).To(HaveLen(1))
+		Expect(cap.records[0].Model).To(Equal("piper"))
+		Expect(cap.records[0].AudioSeconds).To(Equal(2.5))
+		Expect(cap.records[0].TotalTokens).To(BeZero())
+	})
+
+	It("parses cached prompt tokens from the OpenAI and Anthropic body shapes", func() {
+		cap := &captureBackend{}
+		rec := billing.NewRecorder(cap)
+		fallback := &auth.User{ID: "local-uuid", Name: "local"}
+
+		e := echo.New()
+		e.POST("/v1/chat/completions",
+			mockChat(`{"prompt_tokens":12,"completion_tokens":8,"total_tokens":20,"prompt_tokens_details":{"cached_tokens":10}}`),
+			httpMiddleware.UsageMiddleware(rec, fallback),
+		)
+		e.POST("/v1/messages", func(c echo.Context) error {
+			c.Response().Header().Set("Content-Type", "application/json")
+			return c.String(http.StatusOK, `{"model":"claude-sonnet","usage":{"input_tokens":5,"cache_read_input_tokens":20,"output_tokens":7}}`)
+		}, httpMiddleware.UsageMiddleware(rec, fallback))
+
+		for _, path := range []string{"/v1/chat/completions", "/v1/messages"} {
+			req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{}`))
+			req.Header.Set("Content-Type", "application/json")
+			e.ServeHTTP(httptest.NewRecorder(), req)
+		}
+
+		Expect(cap.records).To(HaveLen(2))
+		Expect(cap.records[0].CachedTokens).To(Equal(int64(10)))
+		Expect(cap.records[1].PromptTokens).To(Equal(int64(25)))
+		Expect(cap.records[1].CachedTokens).To(Equal(int64(20)))
+	})
 })
@@ -381,8 +381,8 @@ export default function ConfigFieldRenderer({ field, value, onChange, onRemove,
     )
   }
 
-  // PII detectors — a capability-filtered multi-select of token_classify
-  // models (the consuming model's pii.detectors list).
+  // Model lists — a capability-filtered multi-select of models (the
+  // consuming model's pii.detectors, or its fallbacks chain).
   if (component === 'model-multi-select') {
     const cap = PROVIDER_TO_CAPABILITY[field.autocomplete_provider] || undefined
     return (
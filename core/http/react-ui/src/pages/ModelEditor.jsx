@@ -19,7 +19,8 @@ const SECTION_ICONS = {
   templates: 'fa-file-code', functions: 'fa-wrench', reasoning: 'fa-brain',
   diffusers: 'fa-image', tts: 'fa-volume-up', pipeline: 'fa-code-branch',
   grpc: 'fa-server', agent: 'fa-robot', mcp: 'fa-plug', router: 'fa-route', proxy: 'fa-cloud',
-  mitm: 'fa-user-secret', pii: 'fa-user-shield', other: 'fa-ellipsis-h',
+  mitm: 'fa-user-secret', pii: 'fa-user-shield', limits: 'fa-gauge-high', pricing: 'fa-coins',
+  response_cache: 'fa-database', other: 'fa-ellipsis-h',
 }
 
 const SECTION_COLORS = {
@@ -28,7 +29,9 @@ const SECTION_COLORS = {
   reasoning: 'var(--color-accent)', diffusers: 'var(--color-warning)', tts: 'var(--color-success)',
   pipeline: 'var(--color-accent)', grpc: 'var(--color-text-muted)', agent: 'var(--color-primary)',
   mcp: 'var(--color-accent)', router: 'var(--color-accent)', proxy: 'var(--color-info, var(--color-primary))',
-  mitm: 'var(--color-warning)', pii: 'var(--color-error)', other: 'var(--color-text-muted)',
+  mitm: 'var(--color-warning)', pii: 'var(--color-error)', limits: 'var(--color-warning)',
+  pricing: 'var(--color-success)', response_cache: 'var(--color-info, var(--color-primary))',
+  other: 'var(--color-text-muted)',
 }
 
 // flattenConfig turns a parsed YAML config into a flat { 'a.b.c': value }
@@ -317,6 +317,7 @@ function ApiTraceDetail({ trace }) {
     ['User', user],
     ['Client IP', trace.client_ip],
     ['User Agent', trace.user_agent],
+    ['Fallbacks', trace.fallbacks?.map(a => a.error ? `${a.model} (${a.error})` : a.model).join(' → ')],
   ].filter(([, v]) => v)
   return (
     <div className="tr-panel">
@@ -272,10 +272,12 @@ function computeQuotaExhaustion(quotas, timeSeries, period) {
 
   const totalTokens = timeSeries.reduce((s, b) => s + b.total_tokens, 0)
   const totalRequests = timeSeries.reduce((s, b) => s + b.request_count, 0)
+  const totalCost = timeSeries.reduce((s, b) => s + (b.cost || 0), 0)
   const bucketCount = timeSeries.length
   const hpb = HOURS_PER_BUCKET[period] || 24
   const tokensPerHour = bucketCount > 0 ? (totalTokens / bucketCount) / hpb : 0
   const requestsPerHour = bucketCount > 0 ? (totalRequests / bucketCount) / hpb : 0
+  const costPerHour = bucketCount > 0 ? (totalCost / bucketCount) / hpb : 0
 
   const results = []
   for (const q of quotas) {
@@ -309,6 +311,20 @@ function computeQuotaExhaustion(quotas, timeSeries, period) {
       })
     }
 
+    if (q.max_cost != null) {
+      const remaining = q.remaining_cost ?? Math.max(0, q.max_cost - (q.current_cost || 0))
+      const hoursLeft = costPerHour > 0 ? remaining / costPerHour : Infinity
+      const resetsAt = q.resets_at ? new Date(q.resets_at) : null
+      const hoursUntilReset = resetsAt ? Math.max(0, (resetsAt - Date.now()) / 3600000) : Infinity
+      items.push({
+        label: 'Spend',
+        current: Math.round((q.current_cost || 0) * 100) / 100,
+        max: q.max_cost,
+        hoursLeft: Math.min(hoursLeft, hoursUntilReset),
+        withinLimits: hoursLeft >= hoursUntilReset,
+      })
+    }
+
     if (items.length > 0) {
       results.push({ model: q.model || 'All models', window: q.window, items })
     }
@@ -134,8 +134,8 @@ function PermissionsModal({ user, featureMeta, availableModels, onClose, onSave,
 
   const addQuota = () => {
     setQuotas(prev => [...prev, {
-      id: null, model: '', max_requests: null, max_total_tokens: null, window: '1h',
-      current_requests: 0, current_total_tokens: 0, _dirty: true, _new: true,
+      id: null, model: '', max_requests: null, max_total_tokens: null, max_cost: null, window: '1h',
+      current_requests: 0, current_total_tokens: 0, current_cost: 0, _dirty: true, _new: true,
     }])
   }
 
@@ -166,8 +166,10 @@ function PermissionsModal({ user, featureMeta, availableModels, onClose, onSave,
         if (q._dirty || q._new) {
           await adminUsersApi.setQuota(user.id, {
             model: q.model,
+            api_key_id: q.api_key_id || '',
             max_requests: q.max_requests || null,
             max_total_tokens: q.max_total_tokens || null,
+            max_cost: q.max_cost || null,
             window: q.window,
           })
         }
@@ -356,6 +358,7 @@ function PermissionsModal({ user, featureMeta, availableModels, onClose, onSave,
               {quotas.map((q, idx) => {
                 const reqPct = (q.max_requests && !q._new) ? Math.min(100, Math.round(((q.current_requests ?? 0) / q.max_requests) * 100)) : null
                 const tokPct = (q.max_total_tokens && !q._new) ? Math.min(100, Math.round(((q.current_total_tokens ?? 0) / q.max_total_tokens) * 100)) : null
+                const costPct = (q.max_cost && !q._new) ? Math.min(100, Math.round(((q.current_cost ?? 0) / q.max_cost) * 100)) : null
                 return (
                   <div key={q.id || `new-${idx}`} className="quota-card">
                     <div className="quota-card-header">
@@ -378,6 +381,11 @@ function PermissionsModal({ user, featureMeta, availableModels, onClose, onSave,
                           <option key={w.value} value={w.value}>per {w.label}</option>
                         ))}
                       </select>
+                      {q.api_key_id && (
+                        <span className="quota-usage-label" title={q.api_key_id}>
+                          <i className="fas fa-key" /> {q.api_key_name || 'API key'}
+                        </span>
+                      )}
                       <button
                         className="btn btn-sm btn-danger quota-remove-btn"
                         onClick={() => removeQuota(idx)}
@@ -432,6 +440,29 @@ function PermissionsModal({ user, featureMeta, availableModels, onClose, onSave,
                           </div>
                         )}
                       </div>
+                      <div className="quota-field">
+                        <label className="quota-field-label">Max spend</label>
+                        <input
+                          type="number"
+                          className="quota-input"
+                          placeholder="Unlimited"
+                          value={q.max_cost ?? ''}
+                          onChange={e => updateQuota(idx, 'max_cost', e.target.value ? parseFloat(e.target.value) : null)}
+                          min="0"
+                          step="0.01"
+                        />
+                        {costPct !== null && (
+                          <div className="quota-usage">
+                            <div className="quota-progress">
+                              <div
+                                className={`quota-progress-fill${costPct >= 90 ? ' quota-progress-fill--danger' : costPct >= 70 ? ' quota-progress-fill--warning' : ''}`}
+                                style={{ width: `${costPct}%` }}
+                              />
+                            </div>
+                            <span className="quota-usage-label">{(q.current_cost ?? 0).toFixed(2)} / {q.max_cost.toFixed(2)}</span>
+                          </div>
+                        )}
+                      </div>
                     </div>
                   </div>
                 )
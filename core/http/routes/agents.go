@@ -42,6 +42,7 @@ func RegisterAgentPoolRoutes(e *echo.Echo, app *application.Application,
 	ag.GET("/:name/observables", localai.GetAgentObservablesEndpoint(app))
 	ag.DELETE("/:name/observables", localai.ClearAgentObservablesEndpoint(app))
 	ag.POST("/:name/chat", localai.ChatWithAgentEndpoint(app))
+	ag.POST("/:name/trigger", localai.TriggerAgentEndpoint(app))
 	ag.GET("/:name/sse", localai.AgentSSEEndpoint(app))
 	ag.GET("/:name/export", localai.ExportAgentEndpoint(app))
 	ag.GET("/:name/files", localai.AgentFileEndpoint(app))
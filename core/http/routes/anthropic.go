@@ -66,6 +66,34 @@ func RegisterAnthropicRoutes(app *echo.Echo,
 
 	// Also support without version prefix for compatibility
 	app.POST("/messages", messagesHandler, messagesMiddleware...)
+
+	// Token counting renders the prompt like /v1/messages but runs no
+	// inference, so it skips usage recording, routing and admission.
+	countTokensHandler := anthropic.CountTokensEndpoint(
+		application.ModelLoader(),
+		application.TemplatesEvaluator(),
+		application.ApplicationConfig(),
+	)
+	countTokensMiddleware := []echo.MiddlewareFunc{
+		middleware.TraceMiddleware(application),
+		re.BuildFilteredFirstAvailableDefaultModel(config.BuildUsecaseFilterFn(config.FLAG_CHAT)),
+		re.SetModelAndConfig(func() schema.LocalAIRequest { return new(schema.AnthropicRequest) }),
+	}
+	app.POST("/v1/messages/count_tokens", countTokensHandler, countTokensMiddleware...)
+	app.POST("/messages/count_tokens", countTokensHandler, countTokensMiddleware...)
+}
+
+// anthropicOr serves Anthropic clients, recognised by the anthropic-version
+// header every Anthropic SDK sends, with anthropicHandler and everyone else
+// with fallback. It lets endpoints shared by both APIs, such as /v1/models,
+// answer in the caller's format.
+func anthropicOr(anthropicHandler, fallback echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if c.Request().Header.Get("anthropic-version") != "" {
+			return anthropicHandler(c)
+		}
+		return fallback(c)
+	}
 }
 
 // setAnthropicRequestContext sets up the context and cancel function for Anthropic requests
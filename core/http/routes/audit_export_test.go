@@ -0,0 +1,70 @@
+package routes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/mudler/LocalAI/core/services/routing/router"
+	"github.com/onsi/gomega"
+)
+
+func auditContext(target string) (echo.Context, *httptest.ResponseRecorder) {
+	w := httptest.NewRecorder()
+	return echo.New().NewContext(httptest.NewRequest(http.MethodGet, target, nil), w), w
+}
+
+func TestRouterDecisionExportCSV(t *testing.T) {
+	g := gomega.NewWithT(t)
+	c, w := auditContext("/api/router/decisions/export?format=csv")
+	format, err := auditExportFormat(c)
+	g.Expect(err).ToNot(gomega.HaveOccurred())
+
+	decisions := []router.DecisionRecord{{
+		ID: "d1", CreatedAt: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC),
+		RouterModel: "smart", ServedModel: "small", Label: "code, review", Score: 0.5, Cached: true,
+	}}
+	g.Expect(writeAuditExport(c, format, "router-decisions", decisions, routerDecisionCSVHeader, routerDecisionCSVRow)).To(gomega.Succeed())
+
+	g.Expect(w.Header().Get(echo.HeaderContentType)).To(gomega.HavePrefix("text/csv"))
+	g.Expect(w.Header().Get("Content-Disposition")).To(gomega.MatchRegexp(`attachment; filename="router-decisions-\d{8}T\d{6}Z\.csv"`))
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	g.Expect(lines).To(gomega.HaveLen(2))
+	g.Expect(lines[0]).To(gomega.HavePrefix("id,created_at,correlation_id"))
+	g.Expect(lines[1]).To(gomega.Equal(`d1,2026-03-01T12:00:00Z,,,smart,,small,,"code, review",0.5,0,true,chat`))
+}
+
+func TestAuditExportDefaultsToJSONL(t *testing.T) {
+	g := gomega.NewWithT(t)
+	c, w := auditContext("/api/router/decisions/export")
+	format, err := auditExportFormat(c)
+	g.Expect(err).ToNot(gomega.HaveOccurred())
+	g.Expect(format).To(gomega.Equal("jsonl"))
+
+	decisions := []router.DecisionRecord{{ID: "d1"}, {ID: "d2"}}
+	g.Expect(writeAuditExport(c, format, "router-decisions", decisions, routerDecisionCSVHeader, routerDecisionCSVRow)).To(gomega.Succeed())
+	g.Expect(w.Header().Get(echo.HeaderContentType)).To(gomega.Equal("application/x-ndjson"))
+	g.Expect(strings.Count(w.Body.String(), "\n")).To(gomega.Equal(2))
+	g.Expect(w.Body.String()).To(gomega.HavePrefix(`{"id":"d1",`))
+}
+
+func TestAuditQueryRejectsBadInput(t *testing.T) {
+	g := gomega.NewWithT(t)
+	c, _ := auditContext("/api/pii/events/export?format=xml")
+	_, err := auditExportFormat(c)
+	g.Expect(err).To(gomega.HaveOccurred())
+
+	c, _ = auditContext("/api/pii/events?since=yesterday")
+	_, err = piiEventQuery(c, 100)
+	g.Expect(err).To(gomega.MatchError(gomega.ContainSubstring("invalid since")))
+
+	c, _ = auditContext("/api/pii/events?since=2026-03-01T00:00:00Z&until=2026-03-02T00:00:00Z&model=m&limit=5")
+	q, err := piiEventQuery(c, 100)
+	g.Expect(err).ToNot(gomega.HaveOccurred())
+	g.Expect(q.Model).To(gomega.Equal("m"))
+	g.Expect(q.Limit).To(gomega.Equal(5))
+	g.Expect(q.Until.Sub(q.Since)).To(gomega.Equal(24 * time.Hour))
+}
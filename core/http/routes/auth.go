@@ -1,6 +1,7 @@
 package routes
 
 import (
+	"cmp"
 	"crypto/rand"
 	"crypto/subtle"
 	"encoding/hex"
@@ -663,10 +664,17 @@ func RegisterAuthRoutes(e *echo.Echo, app *application.Application) {
 			Name      string `json:"name"`
 			ExpiresIn string `json:"expiresIn"` // duration like "30d", "90d", "1y"
 			ExpiresAt string `json:"expiresAt"` // ISO timestamp
+			Priority  string `json:"priority"`  // admission priority class, defaults to the role's
 		}
 		if err := c.Bind(&body); err != nil || body.Name == "" {
 			return c.JSON(http.StatusBadRequest, map[string]string{"error": "name is required"})
 		}
+		if body.Priority != "" && !auth.ValidPriority(body.Priority) {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid priority, use low, normal or high"})
+		}
+		if body.Priority != "" && !auth.PriorityAllowed(user.Role, body.Priority) {
+			return c.JSON(http.StatusForbidden, map[string]string{"error": "priority is above your role's"})
+		}
 
 		// Determine expiration
 		var expiresAt *time.Time
@@ -695,6 +703,12 @@ func RegisterAuthRoutes(e *echo.Echo, app *application.Application) {
 		if err != nil {
 			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "failed to create API key"})
 		}
+		if body.Priority != "" {
+			if err := auth.SetAPIKeyPriority(db, record.ID, user.ID, body.Priority); err != nil {
+				return c.JSON(http.StatusInternalServerError, map[string]string{"error": "failed to create API key"})
+			}
+			record.Priority = body.Priority
+		}
 
 		resp := map[string]any{
 			"key":       plaintext, // shown once
@@ -702,6 +716,7 @@ func RegisterAuthRoutes(e *echo.Echo, app *application.Application) {
 			"name":      record.Name,
 			"keyPrefix": record.KeyPrefix,
 			"role":      record.Role,
+			"priority":  cmp.Or(record.Priority, auth.RolePriority(record.Role)),
 			"createdAt": record.CreatedAt,
 		}
 		if record.ExpiresAt != nil {
@@ -730,6 +745,7 @@ func RegisterAuthRoutes(e *echo.Echo, app *application.Application) {
 				"name":      k.Name,
 				"keyPrefix": k.KeyPrefix,
 				"role":      k.Role,
+				"priority":  cmp.Or(k.Priority, auth.RolePriority(k.Role)),
 				"createdAt": k.CreatedAt,
 				"lastUsed":  k.LastUsed,
 			}
@@ -780,6 +796,7 @@ func RegisterAuthRoutes(e *echo.Echo, app *application.Application) {
 			totals.PromptTokens += b.PromptTokens
 			totals.CompletionTokens += b.CompletionTokens
 			totals.TotalTokens += b.TotalTokens
+			totals.Cost += b.Cost
 			totals.RequestCount += b.RequestCount
 		}
 
@@ -1054,7 +1071,7 @@ func RegisterAuthRoutes(e *echo.Echo, app *application.Application) {
 		return c.JSON(http.StatusOK, map[string]any{"quotas": quotas})
 	}, adminMw)
 
-	// PUT /api/auth/admin/users/:id/quotas - upsert quota rule (by user+model)
+	// PUT /api/auth/admin/users/:id/quotas - upsert quota rule (by user+key+model)
 	e.PUT("/api/auth/admin/users/:id/quotas", func(c echo.Context) error {
 		targetID := c.Param("id")
 		var target auth.User
@@ -1063,10 +1080,12 @@ func RegisterAuthRoutes(e *echo.Echo, app *application.Application) {
 		}
 
 		var body struct {
-			Model          string `json:"model"`
-			MaxRequests    *int64 `json:"max_requests"`
-			MaxTotalTokens *int64 `json:"max_total_tokens"`
-			Window         string `json:"window"`
+			Model          string   `json:"model"`
+			APIKeyID       string   `json:"api_key_id"`
+			MaxRequests    *int64   `json:"max_requests"`
+			MaxTotalTokens *int64   `json:"max_total_tokens"`
+			MaxCost        *float64 `json:"max_cost"`
+			Window         string   `json:"window"`
 		}
 		if err := c.Bind(&body); err != nil {
 			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request body"})
@@ -1074,13 +1093,30 @@ func RegisterAuthRoutes(e *echo.Echo, app *application.Application) {
 		if body.Window == "" {
 			return c.JSON(http.StatusBadRequest, map[string]string{"error": "window is required"})
 		}
+		if body.MaxCost != nil && *body.MaxCost < 0 {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "max_cost cannot be negative"})
+		}
+		if body.APIKeyID != "" {
+			var key auth.UserAPIKey
+			if err := db.First(&key, "id = ? AND user_id = ?", body.APIKeyID, targetID).Error; err != nil {
+				return c.JSON(http.StatusBadRequest, map[string]string{"error": "api_key_id does not belong to this user"})
+			}
+		}
 
 		windowSecs, err := auth.ParseWindowDuration(body.Window)
 		if err != nil {
 			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
 		}
 
-		rule, err := auth.CreateOrUpdateQuotaRule(db, targetID, body.Model, body.MaxRequests, body.MaxTotalTokens, windowSecs)
+		rule, err := auth.UpsertQuotaRule(db, auth.QuotaRule{
+			UserID:         targetID,
+			APIKeyID:       body.APIKeyID,
+			Model:          body.Model,
+			MaxRequests:    body.MaxRequests,
+			MaxTotalTokens: body.MaxTotalTokens,
+			MaxCost:        body.MaxCost,
+			WindowSeconds:  windowSecs,
+		})
 		if err != nil {
 			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "failed to save quota rule"})
 		}
@@ -1119,6 +1155,7 @@ func RegisterAuthRoutes(e *echo.Echo, app *application.Application) {
 			totals.PromptTokens += b.PromptTokens
 			totals.CompletionTokens += b.CompletionTokens
 			totals.TotalTokens += b.TotalTokens
+			totals.Cost += b.Cost
 			totals.RequestCount += b.RequestCount
 		}
 
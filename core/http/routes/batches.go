@@ -0,0 +1,142 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	echoMiddleware "github.com/labstack/echo/v4/middleware"
+	"github.com/mudler/LocalAI/core/application"
+	"github.com/mudler/LocalAI/core/http/auth"
+	"github.com/mudler/LocalAI/core/http/endpoints/anthropic"
+	"github.com/mudler/LocalAI/core/http/endpoints/openai"
+	"github.com/mudler/LocalAI/core/http/middleware"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/core/services/batches"
+	"gorm.io/gorm"
+)
+
+// RegisterBatchRoutes registers the OpenAI Files and Batch APIs and the
+// Anthropic Message Batches API, and starts the batch runner. bService is
+// nil when no database is configured, in which case the routes answer 503.
+func RegisterBatchRoutes(e *echo.Echo, bService *batches.Service, re *middleware.RequestExtractor, application *application.Application) {
+	// Service readiness middleware
+	readyMw := func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if bService == nil {
+				return c.JSON(http.StatusServiceUnavailable, schema.ErrorResponse{
+					Error: &schema.APIError{
+						Message: "batch API requires a database, enable authentication to use it",
+						Code:    http.StatusServiceUnavailable,
+						Type:    "server_error",
+					},
+				})
+			}
+			return next(c)
+		}
+	}
+
+	for _, prefix := range []string{"/v1", ""} {
+		e.POST(prefix+"/files", openai.UploadFileEndpoint(bService), readyMw)
+		e.GET(prefix+"/files", openai.ListFilesEndpoint(bService), readyMw)
+		e.GET(prefix+"/files/:file_id", openai.GetFileEndpoint(bService), readyMw)
+		e.GET(prefix+"/files/:file_id/content", openai.GetFileContentEndpoint(bService), readyMw)
+		e.DELETE(prefix+"/files/:file_id", openai.DeleteFileEndpoint(bService), readyMw)
+
+		e.POST(prefix+"/batches", openai.CreateBatchEndpoint(bService), readyMw)
+		e.GET(prefix+"/batches", openai.ListBatchesEndpoint(bService), readyMw)
+		e.GET(prefix+"/batches/:batch_id", openai.GetBatchEndpoint(bService), readyMw)
+		e.POST(prefix+"/batches/:batch_id/cancel", openai.CancelBatchEndpoint(bService), readyMw)
+
+		// Anthropic Message Batches run on the same service.
+		e.POST(prefix+"/messages/batches", anthropic.CreateMessageBatchEndpoint(bService), readyMw)
+		e.GET(prefix+"/messages/batches", anthropic.ListMessageBatchesEndpoint(bService), readyMw)
+		e.GET(prefix+"/messages/batches/:message_batch_id", anthropic.GetMessageBatchEndpoint(bService), readyMw)
+		e.DELETE(prefix+"/messages/batches/:message_batch_id", anthropic.DeleteMessageBatchEndpoint(bService), readyMw)
+		e.POST(prefix+"/messages/batches/:message_batch_id/cancel", anthropic.CancelMessageBatchEndpoint(bService), readyMw)
+		e.GET(prefix+"/messages/batches/:message_batch_id/results", anthropic.MessageBatchResultsEndpoint(bService), readyMw)
+	}
+
+	if bService != nil {
+		bService.Start(application.ApplicationConfig().Context, newBatchDispatcher(re, application))
+	}
+}
+
+// newBatchDispatcher builds the in-process handler batch lines are replayed
+// against, authenticating each line as the batch owner: a permission
+// revoked after submission stops the lines that have not run yet.
+func newBatchDispatcher(re *middleware.RequestExtractor, application *application.Application) http.Handler {
+	return newInProcessDispatcher(re, application, batchOwnerMiddleware(application.AuthDB()))
+}
+
+// newInProcessDispatcher builds a handler serving the same OpenAI,
+// Anthropic and Jina routes as the public server, so middleware, usage
+// recording and tracing behave exactly like a synchronous request. owner
+// authenticates the replayed request; the feature, model-access and quota
+// checks are then re-applied as that user.
+func newInProcessDispatcher(re *middleware.RequestExtractor, application *application.Application, owner echo.MiddlewareFunc) http.Handler {
+	db := application.AuthDB()
+	internal := echo.New()
+	internal.HideBanner = true
+	internal.HTTPErrorHandler = func(err error, c echo.Context) {
+		code := http.StatusInternalServerError
+		var he *echo.HTTPError
+		if errors.As(err, &he) {
+			code = he.Code
+		}
+		c.JSON(code, schema.ErrorResponse{
+			Error: &schema.APIError{Message: err.Error(), Code: code},
+		})
+	}
+	internal.Use(echoMiddleware.Recover())
+	internal.Use(owner)
+	internal.Use(auth.RequireRouteFeature(db))
+	internal.Use(auth.RequireModelAccess(db))
+	internal.Use(auth.RequireQuota(db))
+	RegisterOpenAIRoutes(internal, re, application)
+	RegisterAnthropicRoutes(internal, re, application)
+	RegisterJINARoutes(internal, re, application.ModelConfigLoader(), application.ModelLoader(), application.ApplicationConfig())
+	return internal
+}
+
+// batchOwnerMiddleware authenticates a replayed line as the user who
+// created the batch.
+func batchOwnerMiddleware(db *gorm.DB) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			userID, ok := batches.OwnerFromContext(c.Request().Context())
+			if !ok {
+				return c.JSON(http.StatusUnauthorized, schema.ErrorResponse{
+					Error: &schema.APIError{Message: "batch owner is missing", Code: http.StatusUnauthorized, Type: "authentication_error"},
+				})
+			}
+			if userID == "" || db == nil {
+				return next(c)
+			}
+			return runAsUser(c, next, db, userID, "batch owner")
+		}
+	}
+}
+
+// runAsUser authenticates a dispatched request as the user userID, who
+// must still exist and be active, and serves it. who names that user in
+// the error responses.
+func runAsUser(c echo.Context, next echo.HandlerFunc, db *gorm.DB, userID, who string) error {
+	var user auth.User
+	if err := db.First(&user, "id = ?", userID).Error; err != nil {
+		if userID == "legacy-api-key" {
+			user = auth.User{ID: userID, Name: "API Key User", Role: auth.RoleAdmin}
+		} else {
+			return c.JSON(http.StatusUnauthorized, schema.ErrorResponse{
+				Error: &schema.APIError{Message: who + " no longer exists", Code: http.StatusUnauthorized, Type: "authentication_error"},
+			})
+		}
+	}
+	if user.Status != "" && user.Status != "active" {
+		return c.JSON(http.StatusForbidden, schema.ErrorResponse{
+			Error: &schema.APIError{Message: who + " is not active", Code: http.StatusForbidden, Type: "authorization_error"},
+		})
+	}
+	auth.SetUser(c, &user)
+	return next(c)
+}
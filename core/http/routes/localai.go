@@ -5,12 +5,14 @@ import (
 	echomiddleware "github.com/labstack/echo/v4/middleware"
 	"github.com/mudler/LocalAI/core/application"
 	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/http/auth"
 	"github.com/mudler/LocalAI/core/http/endpoints/localai"
 	mcpTools "github.com/mudler/LocalAI/core/http/endpoints/mcp"
 	"github.com/mudler/LocalAI/core/http/middleware"
 	"github.com/mudler/LocalAI/core/schema"
 	compressionservice "github.com/mudler/LocalAI/core/services/compression"
 	"github.com/mudler/LocalAI/core/services/galleryop"
+	"github.com/mudler/LocalAI/core/services/messaging"
 	"github.com/mudler/LocalAI/core/services/monitoring"
 	"github.com/mudler/LocalAI/core/services/nodes"
 	"github.com/mudler/LocalAI/core/services/routing/pii"
@@ -19,6 +21,7 @@ import (
 	"github.com/mudler/LocalAI/internal"
 	"github.com/mudler/LocalAI/pkg/model"
 	"github.com/mudler/LocalAI/pkg/tokens"
+	"github.com/mudler/xlog"
 	echoswagger "github.com/swaggo/echo-swagger"
 )
 
@@ -343,9 +346,12 @@ func RegisterLocalAIRoutes(router *echo.Echo,
 					"completions":          "/v1/completions",
 					"embeddings":           "/v1/embeddings",
 					"transcription":        "/v1/audio/transcriptions",
+					"translation":          "/v1/audio/translations",
 					"diarization":          "/v1/audio/diarization",
 					"sound_classification": "/v1/audio/classification",
 					"image_generation":     "/v1/images/generations",
+					"image_edits":          "/v1/images/edits",
+					"image_variations":     "/v1/images/variations",
 				},
 				"config_management": map[string]string{
 					"config_metadata": "/api/models/config-metadata",
@@ -456,8 +462,26 @@ func RegisterLocalAIRoutes(router *echo.Echo,
 			compressionservice.NewInferenceSummarizer(cl, ml, appConfig),
 		)
 		var mcpNATS mcpTools.MCPNATSClient
+		var mcpBus messaging.MessagingClient
 		if d := app.Distributed(); d != nil {
 			mcpNATS = d.Nats
+			mcpBus = d.Nats
+			// Deliver elicitation answers for requests streamed by another
+			// replica or relayed by an agent worker.
+			if err := mcpTools.EnableDistributed(d.Nats); err != nil {
+				xlog.Error("Failed to enable distributed MCP client capabilities", "error", err)
+			}
+		}
+		// OAuth credentials of MCP servers are kept encrypted in the auth
+		// DB, shared by the replicas.
+		agentWorkerMw := auth.NoopMiddleware()
+		if db := app.AuthDB(); db != nil {
+			agentWorkerMw = auth.RequireAgentWorker()
+			if store, err := mcpTools.NewGormOAuthStore(db, appConfig.Auth.APIKeyHMACSecret); err != nil {
+				xlog.Error("Failed to initialize the MCP OAuth store", "error", err)
+			} else {
+				mcpTools.SetOAuthStore(store)
+			}
 		}
 		mcpStreamHandler := localai.MCPEndpoint(cl, ml, evaluator, appConfig, mcpNATS, chatCompressor)
 		mcpStreamMiddleware := []echo.MiddlewareFunc{
@@ -488,6 +512,16 @@ func RegisterLocalAIRoutes(router *echo.Echo,
 		router.GET("/v1/mcp/resources/:model", localai.MCPResourcesEndpoint(cl, appConfig), mcpMw)
 		router.POST("/v1/mcp/resources/:model/read", localai.MCPReadResourceEndpoint(cl, appConfig), mcpMw)
 
+		// MCP client capabilities: answers to elicitation requests streamed
+		// as "mcp_elicitation" events, the authorization of OAuth-protected
+		// MCP servers, which only administrators grant, and the tokens of
+		// those servers for agent workers.
+		router.POST("/v1/mcp/elicitations/:id", localai.MCPElicitationAnswerEndpoint(mcpBus), mcpMw)
+		router.GET("/api/mcp/oauth/authorizations", localai.MCPOAuthAuthorizationsEndpoint(), adminMiddleware)
+		router.GET("/api/mcp/oauth/callback", localai.MCPOAuthCallbackEndpoint(), adminMiddleware)
+		router.GET("/api/mcp/oauth/token", localai.MCPOAuthTokenEndpoint(cl), agentWorkerMw)
+		router.POST("/api/mcp/oauth/authorize", localai.MCPOAuthAuthorizeEndpoint(cl), agentWorkerMw)
+
 		// CORS proxy for client-side MCP connections
 		router.GET("/api/cors-proxy", localai.CORSProxyEndpoint(appConfig), mcpMw)
 		router.POST("/api/cors-proxy", localai.CORSProxyEndpoint(appConfig), mcpMw)
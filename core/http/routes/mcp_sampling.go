@@ -0,0 +1,71 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/mudler/LocalAI/core/application"
+	"github.com/mudler/LocalAI/core/http/auth"
+	mcpTools "github.com/mudler/LocalAI/core/http/endpoints/mcp"
+	"github.com/mudler/LocalAI/core/http/middleware"
+	"github.com/mudler/LocalAI/core/schema"
+	"gorm.io/gorm"
+)
+
+// RegisterMCPSampler serves the sampling requests of the MCP servers this
+// instance connects to as chat completions on an in-process dispatcher, run
+// as the user whose tool call triggered them: they count against that
+// user's quota and usage and go through admission control and PII
+// redaction like the user's own completions. Agent workers send the
+// sampling requests of their servers to the same dispatcher through
+// POST /api/mcp/sampling/chat/completions, naming the user with ?user_id=.
+func RegisterMCPSampler(e *echo.Echo, re *middleware.RequestExtractor, application *application.Application) {
+	db := application.AuthDB()
+	dispatcher := newInProcessDispatcher(re, application, mcpSamplingUserMiddleware(db))
+	mcpTools.SetSampler(mcpTools.NewDispatchSampler(dispatcher))
+
+	agentWorkerMw := auth.NoopMiddleware()
+	if db != nil {
+		agentWorkerMw = auth.RequireAgentWorker()
+	}
+	e.POST("/api/mcp/sampling/chat/completions", mcpWorkerSamplingEndpoint(dispatcher), agentWorkerMw)
+}
+
+// mcpWorkerSamplingEndpoint serves a sampling request relayed by an agent
+// worker as the user of the tool call it was sent during. Only agent
+// workers and administrators may name that user; without one the request
+// is refused like any unattributed sampling request.
+func mcpWorkerSamplingEndpoint(dispatcher http.Handler) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		ctx := c.Request().Context()
+		if userID := c.QueryParam("user_id"); userID != "" {
+			ctx = mcpTools.WithCallerID(ctx, userID)
+		}
+		req := c.Request().Clone(ctx)
+		req.URL.Path = "/v1/chat/completions"
+		req.URL.RawQuery = ""
+		dispatcher.ServeHTTP(c.Response(), req)
+		return nil
+	}
+}
+
+// mcpSamplingUserMiddleware authenticates a sampling request as the user
+// of the tool call it was sent during. Requests that can't be attributed
+// (no tool call running, or calls of several users on the session) are
+// refused when authentication is enabled.
+func mcpSamplingUserMiddleware(db *gorm.DB) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if db == nil {
+				return next(c)
+			}
+			userID, ok := mcpTools.CallerIDFromContext(c.Request().Context())
+			if !ok || userID == "" {
+				return c.JSON(http.StatusUnauthorized, schema.ErrorResponse{
+					Error: &schema.APIError{Message: "sampling request cannot be attributed to a user", Code: http.StatusUnauthorized, Type: "authentication_error"},
+				})
+			}
+			return runAsUser(c, next, db, userID, "sampling user")
+		}
+	}
+}
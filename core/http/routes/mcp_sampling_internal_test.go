@@ -0,0 +1,35 @@
+package routes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	mcpTools "github.com/mudler/LocalAI/core/http/endpoints/mcp"
+	"github.com/onsi/gomega"
+)
+
+// Sampling requests relayed by agent workers reach the in-process
+// dispatcher as a chat completion of the user the worker names, so
+// mcpSamplingUserMiddleware runs them as that user and not as the worker.
+func TestWorkerSamplingRunsAsNamedUser(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	var path, caller string
+	dispatcher := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		caller, _ = mcpTools.CallerIDFromContext(r.Context())
+		w.WriteHeader(http.StatusOK)
+	})
+
+	e := echo.New()
+	e.POST("/api/mcp/sampling/chat/completions", mcpWorkerSamplingEndpoint(dispatcher))
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/mcp/sampling/chat/completions?user_id=alice", strings.NewReader(`{}`)))
+
+	g.Expect(rec.Code).To(gomega.Equal(http.StatusOK))
+	g.Expect(path).To(gomega.Equal("/v1/chat/completions"))
+	g.Expect(caller).To(gomega.Equal("alice"))
+}
@@ -0,0 +1,52 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/mudler/LocalAI/core/application"
+	"github.com/mudler/LocalAI/core/http/auth"
+	"github.com/mudler/LocalAI/core/http/endpoints/mcpserver"
+	"github.com/mudler/LocalAI/core/http/middleware"
+	"github.com/mudler/LocalAI/core/schema"
+)
+
+// RegisterMCPServerRoutes serves the installed models as an MCP server
+// over streamable HTTP on /mcp. Tool calls run on an in-process dispatcher
+// as the MCP caller, like batch lines do as the batch owner.
+func RegisterMCPServerRoutes(e *echo.Echo, re *middleware.RequestExtractor, application *application.Application) {
+	appConfig := application.ApplicationConfig()
+	endpoint := mcpserver.Endpoint(
+		application.ModelConfigLoader(),
+		application.AuthDB(),
+		newInProcessDispatcher(re, application, mcpCallerMiddleware()),
+		mcpserver.Options{
+			// Every request is authenticated then, so the DNS rebinding
+			// protection would only break reverse proxies on the same host.
+			DisableLocalhostProtection: application.AuthDB() != nil || len(appConfig.ApiKeys) > 0,
+		},
+	)
+	e.Match([]string{http.MethodPost, http.MethodGet, http.MethodDelete}, "/mcp", endpoint)
+}
+
+// mcpCallerMiddleware authenticates a dispatched tool call as the MCP
+// caller.
+func mcpCallerMiddleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			caller, ok := mcpserver.CallerFromContext(c.Request().Context())
+			if !ok {
+				return c.JSON(http.StatusUnauthorized, schema.ErrorResponse{
+					Error: &schema.APIError{Message: "MCP caller is missing", Code: http.StatusUnauthorized, Type: "authentication_error"},
+				})
+			}
+			if caller.User != nil {
+				auth.SetUser(c, caller.User)
+			}
+			if caller.Source != "" {
+				auth.SetSource(c, caller.Source, caller.APIKey)
+			}
+			return next(c)
+		}
+	}
+}
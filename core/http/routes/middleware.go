@@ -75,24 +75,38 @@ func RegisterMiddlewareRoutes(e *echo.Echo, app *application.Application) {
 			return c.JSON(http.StatusOK, map[string]any{"decisions": []any{}})
 		}
 
-		limit := 100
-		if v := c.QueryParam("limit"); v != "" {
-			if n, err := strconv.Atoi(v); err == nil && n > 0 {
-				limit = n
-			}
+		q, err := routerDecisionQuery(c, 100)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
 		}
-		decisions, err := store.List(c.Request().Context(), router.DecisionListQuery{
-			CorrelationID: c.QueryParam("correlation_id"),
-			UserID:        c.QueryParam("user_id"),
-			RouterModel:   c.QueryParam("router_model"),
-			Limit:         limit,
-		})
+		decisions, err := store.List(c.Request().Context(), q)
 		if err != nil {
 			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "failed to list decisions"})
 		}
 		return c.JSON(http.StatusOK, map[string]any{"decisions": decisions})
 	}))
 
+	// GET /api/router/decisions/export?format=csv|jsonl — the decision
+	// log as a download for compliance archiving. Same filters as the
+	// list endpoint, capped at 100000 rows unless limit says otherwise.
+	e.GET("/api/router/decisions/export", adminOnly(app, func(c echo.Context) error {
+		format, err := auditExportFormat(c)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		q, err := routerDecisionQuery(c, auditExportLimit)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		var decisions []router.DecisionRecord
+		if store := app.RouterDecisions(); store != nil {
+			if decisions, err = store.List(c.Request().Context(), q); err != nil {
+				return c.JSON(http.StatusInternalServerError, map[string]string{"error": "failed to list decisions"})
+			}
+		}
+		return writeAuditExport(c, format, "router-decisions", decisions, routerDecisionCSVHeader, routerDecisionCSVRow)
+	}))
+
 	// GET /api/router/cache/stats — embedding-cache counters per
 	// router model. Read-only; same auth gating as /api/router/status
 	// (any authenticated user can see configuration). Omitted entries
@@ -154,6 +168,42 @@ func adminOnly(app *application.Application, h echo.HandlerFunc) echo.HandlerFun
 	}
 }
 
+// routerDecisionQuery builds the decision-log filter shared by the list
+// and export endpoints from the query string.
+func routerDecisionQuery(c echo.Context, defaultLimit int) (router.DecisionListQuery, error) {
+	since, until, err := auditTimeWindow(c)
+	if err != nil {
+		return router.DecisionListQuery{}, err
+	}
+	return router.DecisionListQuery{
+		CorrelationID: c.QueryParam("correlation_id"),
+		UserID:        c.QueryParam("user_id"),
+		RouterModel:   c.QueryParam("router_model"),
+		Model:         c.QueryParam("model"),
+		Source:        c.QueryParam("source"),
+		Since:         since,
+		Until:         until,
+		Limit:         auditQueryLimit(c, defaultLimit),
+	}, nil
+}
+
+var routerDecisionCSVHeader = []string{
+	"id", "created_at", "correlation_id", "user_id", "router_model", "requested_model",
+	"served_model", "classifier", "label", "score", "latency_ms", "cached", "source",
+}
+
+func routerDecisionCSVRow(r router.DecisionRecord) []string {
+	source := r.Source
+	if source == "" {
+		source = router.SourceChat
+	}
+	return []string{
+		r.ID, csvTime(r.CreatedAt), r.CorrelationID, r.UserID, r.RouterModel, r.RequestedModel,
+		r.ServedModel, r.Classifier, r.Label, strconv.FormatFloat(r.Score, 'f', -1, 64),
+		strconv.FormatInt(r.LatencyMs, 10), strconv.FormatBool(r.Cached), source,
+	}
+}
+
 // buildRouterStatus inventories every model that declares a Router
 // block and reports their classifiers + candidate tables. Reads from
 // the same loader the RouteModel middleware uses so the admin page
@@ -290,8 +340,8 @@ func buildMITMStatus(app *application.Application) map[string]any {
 	return out
 }
 
-// buildAdmissionStatus reports each model's MaxConcurrent ceiling
-// and current in-flight count. Models with no limit set are
+// buildAdmissionStatus reports each model's MaxConcurrent ceiling,
+// queue size and current in-flight and queued counts. Models with no limit set are
 // omitted — the dashboard view is "what's gated", not "every
 // model in the loader".
 func buildAdmissionStatus(app *application.Application) map[string]any {
@@ -309,6 +359,8 @@ func buildAdmissionStatus(app *application.Application) map[string]any {
 			"max_concurrent":      cfg.Limits.MaxConcurrent,
 			"retry_after_seconds": cfg.Limits.RetryAfterSeconds,
 			"in_flight":           limiter.InFlight(cfg.Name),
+			"queue_size":          cfg.Limits.QueueSize,
+			"queued":              limiter.Queued(cfg.Name),
 		})
 	}
 	return map[string]any{"models": models}
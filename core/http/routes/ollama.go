@@ -18,6 +18,7 @@ import (
 func RegisterOllamaRoutes(app *echo.Echo,
 	re *middleware.RequestExtractor,
 	application *application.Application,
+	adminMiddleware echo.MiddlewareFunc,
 ) {
 	traceMiddleware := middleware.TraceMiddleware(application)
 	usageMiddleware := middleware.UsageMiddleware(application.StatsRecorder(), application.FallbackUser())
@@ -83,6 +84,16 @@ func RegisterOllamaRoutes(app *echo.Echo,
 	app.GET("/api/ps", ollama.ListRunningEndpoint(application.ModelConfigLoader(), application.ModelLoader()))
 	app.GET("/api/version", ollama.VersionEndpoint())
 	app.HEAD("/api/version", ollama.VersionEndpoint())
+
+	// Endpoints that install or modify models share the gallery's gate
+	if !application.ApplicationConfig().DisableGalleryEndpoint {
+		cl, gs, appConfig := application.ModelConfigLoader(), application.GalleryService(), application.ApplicationConfig()
+		app.POST("/api/pull", ollama.PullModelEndpoint(cl, gs, appConfig), adminMiddleware)
+		app.POST("/api/push", ollama.PushModelEndpoint(), adminMiddleware)
+		app.POST("/api/create", ollama.CreateModelEndpoint(cl, gs, appConfig), adminMiddleware)
+		app.POST("/api/copy", ollama.CopyModelEndpoint(cl, gs, appConfig), adminMiddleware)
+		app.DELETE("/api/delete", ollama.DeleteModelEndpoint(cl, gs), adminMiddleware)
+	}
 }
 
 // RegisterOllamaRootEndpoint registers the Ollama "/" health check.
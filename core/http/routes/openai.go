@@ -4,6 +4,7 @@ import (
 	"github.com/labstack/echo/v4"
 	"github.com/mudler/LocalAI/core/application"
 	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/http/endpoints/anthropic"
 	"github.com/mudler/LocalAI/core/http/endpoints/localai"
 	mcpTools "github.com/mudler/LocalAI/core/http/endpoints/mcp"
 	"github.com/mudler/LocalAI/core/http/endpoints/openai"
@@ -30,6 +31,12 @@ func RegisterOpenAIRoutes(app *echo.Echo,
 	// image generation/inpainting) so distributed-mode operators can observe
 	// which worker served each request.
 	nodeHeaderMiddleware := middleware.ExposeNodeHeader(application.ApplicationConfig())
+	// Response cache for models with a response_cache block; a no-op for
+	// the others. Shared by chat and completions.
+	responseCacheMiddleware := middleware.ResponseCache(application.ResponseCache(), application.Embedder, application.VectorStore, application.FallbackUser())
+	// Retries chat and completion requests on the model's fallbacks
+	// when it fails before answering; a no-op for models without any.
+	fallbackMiddleware := middleware.ModelFallback(application.ModelConfigLoader(), application.ApplicationConfig())
 
 	// realtime
 	// TODO: Modify/disable the API key middleware for this endpoint to allow ephemeral keys created by sessions
@@ -90,6 +97,12 @@ func RegisterOpenAIRoutes(app *echo.Echo,
 		// claude-strict; that model's pii block applies, not the router
 		// model's), and prevents the compressor from seeing unredacted input.
 		pii.RequestMiddleware(application.PIIRedactor(), application.PIIEvents(), piiadapter.OpenAI(), application.FallbackUser(), pii.WithNERResolver(application.PIINERResolver()), pii.WithPolicyResolver(application.PIIPolicyResolver())),
+		// The response cache runs after PII so it keys on the redacted
+		// request the handler sees.
+		responseCacheMiddleware,
+		// Fallback runs last: a retry replays the request the handler
+		// saw, and only the answering attempt reaches the outer writers.
+		fallbackMiddleware,
 	}
 	app.POST("/v1/chat/completions", chatHandler, chatMiddleware...)
 	app.POST("/chat/completions", chatHandler, chatMiddleware...)
@@ -133,6 +146,8 @@ func RegisterOpenAIRoutes(app *echo.Echo,
 			}
 		},
 		pii.RequestMiddleware(application.PIIRedactor(), application.PIIEvents(), piiadapter.OpenAICompletion(), application.FallbackUser(), pii.WithNERResolver(application.PIINERResolver()), pii.WithPolicyResolver(application.PIIPolicyResolver())),
+		responseCacheMiddleware,
+		fallbackMiddleware,
 	}
 	app.POST("/v1/completions", completionHandler, completionMiddleware...)
 	app.POST("/completions", completionHandler, completionMiddleware...)
@@ -176,8 +191,10 @@ func RegisterOpenAIRoutes(app *echo.Echo,
 	app.POST("/v1/engines/:model/embeddings", embeddingHandler, embeddingMiddleware...)
 
 	audioHandler := openai.TranscriptEndpoint(application.ModelConfigLoader(), application.ModelLoader(), application.ApplicationConfig())
+	translationHandler := openai.TranslationEndpoint(application.ModelConfigLoader(), application.ModelLoader(), application.ApplicationConfig())
 	audioMiddleware := []echo.MiddlewareFunc{
 		nodeHeaderMiddleware,
+		usageMiddleware,
 		traceMiddleware,
 		re.BuildFilteredFirstAvailableDefaultModel(config.BuildUsecaseFilterFn(config.FLAG_TRANSCRIPT)),
 		re.SetModelAndConfig(func() schema.LocalAIRequest { return new(schema.OpenAIRequest) }),
@@ -186,13 +203,19 @@ func RegisterOpenAIRoutes(app *echo.Echo,
 				if err := re.SetOpenAIRequest(c); err != nil {
 					return err
 				}
+				openai.BindAudioPrompt(c)
 				return next(c)
 			}
 		},
+		// The only text of an audio request is its prompt, scanned like
+		// a completion prompt.
+		pii.RequestMiddleware(application.PIIRedactor(), application.PIIEvents(), piiadapter.OpenAICompletion(), application.FallbackUser(), pii.WithNERResolver(application.PIINERResolver()), pii.WithPolicyResolver(application.PIIPolicyResolver())),
 	}
 	// audio
 	app.POST("/v1/audio/transcriptions", audioHandler, audioMiddleware...)
 	app.POST("/audio/transcriptions", audioHandler, audioMiddleware...)
+	app.POST("/v1/audio/translations", translationHandler, audioMiddleware...)
+	app.POST("/audio/translations", translationHandler, audioMiddleware...)
 
 	diarizationHandler := openai.DiarizationEndpoint(application.ModelConfigLoader(), application.ModelLoader(), application.ApplicationConfig())
 	diarizationMiddleware := []echo.MiddlewareFunc{
@@ -231,6 +254,7 @@ func RegisterOpenAIRoutes(app *echo.Echo,
 	audioSpeechHandler := localai.TTSEndpoint(application.ModelConfigLoader(), application.ModelLoader(), application.ApplicationConfig(), application.VoiceProfileStore())
 	audioSpeechMiddleware := []echo.MiddlewareFunc{
 		nodeHeaderMiddleware,
+		usageMiddleware,
 		traceMiddleware,
 		re.BuildFilteredFirstAvailableDefaultModel(config.BuildUsecaseFilterFn(config.FLAG_TTS)),
 		re.SetModelAndConfig(func() schema.LocalAIRequest { return new(schema.TTSRequest) }),
@@ -243,6 +267,7 @@ func RegisterOpenAIRoutes(app *echo.Echo,
 	imageHandler := openai.ImageEndpoint(application.ModelConfigLoader(), application.ModelLoader(), application.ApplicationConfig())
 	imageMiddleware := []echo.MiddlewareFunc{
 		nodeHeaderMiddleware,
+		usageMiddleware,
 		traceMiddleware,
 		// Default: use the first available image generation model
 		re.BuildFilteredFirstAvailableDefaultModel(config.BuildUsecaseFilterFn(config.FLAG_IMAGE)),
@@ -265,14 +290,34 @@ func RegisterOpenAIRoutes(app *echo.Echo,
 	app.POST("/v1/images/inpainting", inpaintingHandler, imageMiddleware...)
 	app.POST("/images/inpainting", inpaintingHandler, imageMiddleware...)
 
+	// OpenAI image edits (image + optional mask + prompt) and variations,
+	// served by the inpainting and img2img paths
+	imageEditHandler := openai.ImageEditEndpoint(application.ModelConfigLoader(), application.ModelLoader(), application.ApplicationConfig())
+	app.POST("/v1/images/edits", imageEditHandler, imageMiddleware...)
+	app.POST("/images/edits", imageEditHandler, imageMiddleware...)
+	imageVariationHandler := openai.ImageVariationEndpoint(application.ModelConfigLoader(), application.ModelLoader(), application.ApplicationConfig())
+	app.POST("/v1/images/variations", imageVariationHandler, imageMiddleware...)
+	app.POST("/images/variations", imageVariationHandler, imageMiddleware...)
+
 	// upscale endpoint - reuse same middleware config as images
 	upscaleHandler := openai.UpscaleEndpoint(application.ModelConfigLoader(), application.ModelLoader(), application.ApplicationConfig())
 	app.POST("/v1/images/upscale", upscaleHandler, imageMiddleware...)
 	app.POST("/images/upscale", upscaleHandler, imageMiddleware...)
 
-	// List models
-	app.GET("/v1/models", openai.ListModelsEndpoint(application.ModelConfigLoader(), application.ModelLoader(), application.ApplicationConfig(), application.AuthDB()))
-	app.GET("/models", openai.ListModelsEndpoint(application.ModelConfigLoader(), application.ModelLoader(), application.ApplicationConfig(), application.AuthDB()))
+	// List models. Anthropic clients get the Anthropic Models API shape.
+	listModelsHandler := anthropicOr(
+		anthropic.ListModelsEndpoint(application.ModelConfigLoader(), application.ModelLoader(), application.AuthDB()),
+		openai.ListModelsEndpoint(application.ModelConfigLoader(), application.ModelLoader(), application.ApplicationConfig(), application.AuthDB()),
+	)
+	app.GET("/v1/models", listModelsHandler)
+	app.GET("/models", listModelsHandler)
+	getModelHandler := anthropicOr(
+		anthropic.GetModelEndpoint(application.ModelConfigLoader(), application.ModelLoader(), application.AuthDB()),
+		openai.GetModelEndpoint(application.ModelConfigLoader(), application.ModelLoader(), application.AuthDB()),
+	)
+	// Unprefixed /models/* is LocalAI's model management namespace, so the
+	// lookup is only served under /v1.
+	app.GET("/v1/models/:model_id", getModelHandler)
 
 	// List models enriched with capabilities + input/output modalities
 	// (LocalAI-specific, additive superset of /v1/models).
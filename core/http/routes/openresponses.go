@@ -9,12 +9,14 @@ import (
 	"github.com/mudler/LocalAI/core/http/endpoints/openresponses"
 	"github.com/mudler/LocalAI/core/http/middleware"
 	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/core/services/vectorstores"
 	"github.com/mudler/xlog"
 )
 
 func RegisterOpenResponsesRoutes(app *echo.Echo,
 	re *middleware.RequestExtractor,
-	application *application.Application) {
+	application *application.Application,
+	vsService *vectorstores.Service) {
 
 	// NATS client for distributed MCP tool routing (nil when not in distributed mode)
 	var natsClient mcpTools.MCPNATSClient
@@ -32,6 +34,27 @@ func RegisterOpenResponsesRoutes(app *echo.Echo,
 		}
 	}
 
+	// Persist responses to the auth database so previous_response_id chains,
+	// polling and stream resume survive restarts. Interrupted generations are
+	// only reaped in standalone mode: in distributed mode a peer may still be
+	// running them.
+	if appConfig := application.ApplicationConfig(); appConfig.OpenResponsesPersist {
+		if db := application.AuthDB(); db != nil {
+			if err := openresponses.GetGlobalStore().EnablePersistence(appConfig.Context, db,
+				appConfig.OpenResponsesStoreRetention, application.Distributed() == nil); err != nil {
+				xlog.Error("Failed to enable Open Responses persistence", "error", err)
+			}
+		} else {
+			xlog.Warn("Open Responses persistence requires authentication to be enabled; keeping responses in memory only")
+		}
+	}
+
+	// file_search tools need the vector stores API (nil without a database)
+	var fileSearcher openresponses.FileSearcher
+	if vsService != nil {
+		fileSearcher = vsService
+	}
+
 	// Open Responses API endpoint
 	responsesHandler := openresponses.ResponsesEndpoint(
 		application.ModelConfigLoader(),
@@ -39,6 +62,7 @@ func RegisterOpenResponsesRoutes(app *echo.Echo,
 		application.TemplatesEvaluator(),
 		application.ApplicationConfig(),
 		natsClient,
+		fileSearcher,
 	)
 
 	responsesMiddleware := []echo.MiddlewareFunc{
@@ -72,6 +96,20 @@ func RegisterOpenResponsesRoutes(app *echo.Echo,
 	cancelResponseHandler := openresponses.CancelResponseEndpoint()
 	app.POST("/v1/responses/:id/cancel", cancelResponseHandler, middleware.TraceMiddleware(application))
 	app.POST("/responses/:id/cancel", cancelResponseHandler, middleware.TraceMiddleware(application))
+
+	// GET /responses/:id/input_items - List the input items of a response
+	inputItemsHandler := openresponses.ListInputItemsEndpoint()
+	app.GET("/v1/responses/:id/input_items", inputItemsHandler, middleware.TraceMiddleware(application))
+	app.GET("/responses/:id/input_items", inputItemsHandler, middleware.TraceMiddleware(application))
+
+	// Conversations group response items across turns
+	for _, prefix := range []string{"/v1", ""} {
+		app.POST(prefix+"/conversations", openresponses.CreateConversationEndpoint(), middleware.TraceMiddleware(application))
+		app.GET(prefix+"/conversations/:id", openresponses.GetConversationEndpoint(), middleware.TraceMiddleware(application))
+		app.DELETE(prefix+"/conversations/:id", openresponses.DeleteConversationEndpoint(), middleware.TraceMiddleware(application))
+		app.GET(prefix+"/conversations/:id/items", openresponses.ListConversationItemsEndpoint(), middleware.TraceMiddleware(application))
+		app.POST(prefix+"/conversations/:id/items", openresponses.CreateConversationItemsEndpoint(), middleware.TraceMiddleware(application))
+	}
 }
 
 // setOpenResponsesRequestContext sets up the context and cancel function for Open Responses requests
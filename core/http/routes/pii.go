@@ -6,15 +6,15 @@ import (
 
 	"github.com/labstack/echo/v4"
 	"github.com/mudler/LocalAI/core/application"
-	"github.com/mudler/LocalAI/core/http/auth"
 	"github.com/mudler/LocalAI/core/http/endpoints/localai"
 	"github.com/mudler/LocalAI/core/services/routing/pii"
 )
 
-// RegisterPIIRoutes wires the read-only PII audit endpoint. The
-// detection itself runs request-side from the chat middleware
-// (routes/openai.go) and the MITM input path, driven by per-model NER
-// detectors; this endpoint is observation-side only.
+// RegisterPIIRoutes wires the read-only PII audit endpoints (the event
+// log and its CSV/JSONL export). The detection itself runs request-side
+// from the chat middleware (routes/openai.go) and the MITM input path,
+// driven by per-model NER detectors; these endpoints are
+// observation-side only.
 //
 // The legacy regex tier (pattern catalogue + per-pattern action editor
 // + dry-run/decide oracles) was removed — policy now lives on each
@@ -22,11 +22,13 @@ import (
 // list or mutate here.
 func RegisterPIIRoutes(e *echo.Echo, app *application.Application) {
 	if app.PIIEvents() == nil {
-		e.GET("/api/pii/events", func(c echo.Context) error {
+		unavailable := func(c echo.Context) error {
 			return c.JSON(http.StatusServiceUnavailable, map[string]string{
 				"error": "PII subsystem unavailable",
 			})
-		})
+		}
+		e.GET("/api/pii/events", unavailable)
+		e.GET("/api/pii/events/export", unavailable)
 		return
 	}
 
@@ -40,38 +42,51 @@ func RegisterPIIRoutes(e *echo.Echo, app *application.Application) {
 	// @Param pattern_id query string false "Detector group id (e.g. ner:EMAIL, pattern:ANTHROPIC_KEY)"
 	// @Param kind query string false "Event kind: pii | proxy_connect | proxy_traffic"
 	// @Param origin query string false "Redaction origin: middleware | proxy | pii_analyze | pii_redact"
+	// @Param model query string false "Model the request addressed"
+	// @Param since query string false "Oldest event time, inclusive (RFC 3339)"
+	// @Param until query string false "Newest event time, exclusive (RFC 3339)"
 	// @Param limit query int false "Max events" default(100)
 	// @Success 200 {object} map[string]interface{}
 	// @Router /api/pii/events [get]
-	e.GET("/api/pii/events", func(c echo.Context) error {
-		viewer := resolveUsageUser(c, app)
-		if viewer == nil {
-			return c.JSON(http.StatusUnauthorized, map[string]string{"error": "not authenticated"})
+	// Admin-only when auth is enabled. Local user has Role: admin.
+	e.GET("/api/pii/events", adminOnly(app, func(c echo.Context) error {
+		q, err := piiEventQuery(c, 100)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
 		}
-		// Admin-only when auth is enabled. Local user has Role: admin.
-		if viewer.Role != auth.RoleAdmin {
-			return c.JSON(http.StatusForbidden, map[string]string{"error": "admin access required"})
+		events, err := app.PIIEvents().List(c.Request().Context(), q)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "failed to list events"})
 		}
+		return c.JSON(http.StatusOK, map[string]any{"events": events})
+	}))
 
-		limit := 100
-		if v := c.QueryParam("limit"); v != "" {
-			if n, err := strconv.Atoi(v); err == nil && n > 0 {
-				limit = n
-			}
+	// ExportPIIEventsEndpoint godoc
+	// @Summary Export middleware events
+	// @Description Downloads the event log as CSV or JSONL for compliance archiving. Takes the same filters as /api/pii/events; the default cap is 100000 rows. Admin-only when auth is on.
+	// @Tags pii
+	// @Produce text/csv,application/x-ndjson
+	// @Param format query string false "csv | jsonl" default(jsonl)
+	// @Param since query string false "Oldest event time, inclusive (RFC 3339)"
+	// @Param until query string false "Newest event time, exclusive (RFC 3339)"
+	// @Param limit query int false "Max events" default(100000)
+	// @Success 200 {file} file
+	// @Router /api/pii/events/export [get]
+	e.GET("/api/pii/events/export", adminOnly(app, func(c echo.Context) error {
+		format, err := auditExportFormat(c)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		q, err := piiEventQuery(c, auditExportLimit)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
 		}
-		events, err := app.PIIEvents().List(c.Request().Context(), pii.ListQuery{
-			CorrelationID: c.QueryParam("correlation_id"),
-			UserID:        c.QueryParam("user_id"),
-			PatternID:     c.QueryParam("pattern_id"),
-			Kind:          pii.EventKind(c.QueryParam("kind")),
-			Origin:        c.QueryParam("origin"),
-			Limit:         limit,
-		})
+		events, err := app.PIIEvents().List(c.Request().Context(), q)
 		if err != nil {
 			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "failed to list events"})
 		}
-		return c.JSON(http.StatusOK, map[string]any{"events": events})
-	})
+		return writeAuditExport(c, format, "pii-events", events, piiEventCSVHeader, piiEventCSVRow)
+	}))
 
 	// Synchronous redaction service: scan a string and either report the
 	// detected entities (analyze) or apply the policy (redact). Unlike the
@@ -81,3 +96,39 @@ func RegisterPIIRoutes(e *echo.Echo, app *application.Application) {
 	e.POST("/api/pii/analyze", localai.PIIAnalyzeEndpoint(app))
 	e.POST("/api/pii/redact", localai.PIIRedactEndpoint(app))
 }
+
+// piiEventQuery builds the event-log filter shared by the list and
+// export endpoints from the query string.
+func piiEventQuery(c echo.Context, defaultLimit int) (pii.ListQuery, error) {
+	since, until, err := auditTimeWindow(c)
+	if err != nil {
+		return pii.ListQuery{}, err
+	}
+	return pii.ListQuery{
+		CorrelationID: c.QueryParam("correlation_id"),
+		UserID:        c.QueryParam("user_id"),
+		PatternID:     c.QueryParam("pattern_id"),
+		Kind:          pii.EventKind(c.QueryParam("kind")),
+		Origin:        c.QueryParam("origin"),
+		Model:         c.QueryParam("model"),
+		Since:         since,
+		Until:         until,
+		Limit:         auditQueryLimit(c, defaultLimit),
+	}, nil
+}
+
+var piiEventCSVHeader = []string{
+	"id", "created_at", "kind", "origin", "correlation_id", "user_id", "model",
+	"direction", "pattern_id", "byte_offset", "length", "hash_prefix", "action", "score",
+	"host", "status_code", "duration_ms", "bytes_sent", "bytes_received",
+}
+
+func piiEventCSVRow(e pii.PIIEvent) []string {
+	return []string{
+		e.ID, csvTime(e.CreatedAt), string(e.ResolvedKind()), e.Origin, e.CorrelationID, e.UserID, e.Model,
+		string(e.Direction), e.PatternID, strconv.Itoa(e.ByteOffset), strconv.Itoa(e.Length), e.HashPrefix,
+		string(e.Action), strconv.FormatFloat(float64(e.Score), 'f', -1, 32),
+		e.Host, strconv.Itoa(e.StatusCode), strconv.FormatInt(e.DurationMS, 10),
+		strconv.FormatInt(e.BytesSent, 10), strconv.FormatInt(e.BytesReceived, 10),
+	}
+}
@@ -139,6 +139,7 @@ func usageResponse(buckets []auth.UsageBucket, viewer *auth.User) map[string]any
 		totals.PromptTokens += b.PromptTokens
 		totals.CompletionTokens += b.CompletionTokens
 		totals.TotalTokens += b.TotalTokens
+		totals.Cost += b.Cost
 		totals.RequestCount += b.RequestCount
 	}
 	resp := map[string]any{
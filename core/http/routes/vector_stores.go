@@ -0,0 +1,50 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/mudler/LocalAI/core/application"
+	"github.com/mudler/LocalAI/core/http/endpoints/openai"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/core/services/vectorstores"
+)
+
+// RegisterVectorStoreRoutes registers the OpenAI Vector Stores API and
+// starts the file ingestion runner. vsService is nil when no database is
+// configured, in which case the routes answer 503.
+func RegisterVectorStoreRoutes(e *echo.Echo, vsService *vectorstores.Service, application *application.Application) {
+	// Service readiness middleware
+	readyMw := func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if vsService == nil {
+				return c.JSON(http.StatusServiceUnavailable, schema.ErrorResponse{
+					Error: &schema.APIError{
+						Message: "vector stores API requires a database, enable authentication to use it",
+						Code:    http.StatusServiceUnavailable,
+						Type:    "server_error",
+					},
+				})
+			}
+			return next(c)
+		}
+	}
+
+	for _, prefix := range []string{"/v1", ""} {
+		e.POST(prefix+"/vector_stores", openai.CreateVectorStoreEndpoint(vsService), readyMw)
+		e.GET(prefix+"/vector_stores", openai.ListVectorStoresEndpoint(vsService), readyMw)
+		e.GET(prefix+"/vector_stores/:vector_store_id", openai.GetVectorStoreEndpoint(vsService), readyMw)
+		e.DELETE(prefix+"/vector_stores/:vector_store_id", openai.DeleteVectorStoreEndpoint(vsService), readyMw)
+
+		e.POST(prefix+"/vector_stores/:vector_store_id/files", openai.CreateVectorStoreFileEndpoint(vsService), readyMw)
+		e.GET(prefix+"/vector_stores/:vector_store_id/files", openai.ListVectorStoreFilesEndpoint(vsService), readyMw)
+		e.GET(prefix+"/vector_stores/:vector_store_id/files/:file_id", openai.GetVectorStoreFileEndpoint(vsService), readyMw)
+		e.DELETE(prefix+"/vector_stores/:vector_store_id/files/:file_id", openai.DeleteVectorStoreFileEndpoint(vsService), readyMw)
+
+		e.POST(prefix+"/vector_stores/:vector_store_id/search", openai.SearchVectorStoreEndpoint(vsService), readyMw)
+	}
+
+	if vsService != nil {
+		vsService.Start(application.ApplicationConfig().Context)
+	}
+}
@@ -3,6 +3,7 @@ package p2p
 import (
 	"fmt"
 	"math/rand/v2"
+	"net/http"
 	"sync"
 
 	"github.com/mudler/xlog"
@@ -23,9 +24,21 @@ type FederatedServer struct {
 	requestTable                  map[string]int
 	loadBalanced                  bool
 	workerTarget                  string
+
+	// modelAware switches from proxying raw TCP connections to routing each
+	// HTTP request to a node serving its model (see federated_http.go).
+	modelAware bool
+	inFlight   map[string]int
+	transports map[string]*http.Transport
+	// maxBodyBytes bounds the request bodies routed in model-aware mode,
+	// which are held in memory to be replayed on another node.
+	maxBodyBytes int64
 }
 
-func NewFederatedServer(listenAddr, service, p2pToken string, loadBalanced bool, workerTarget string) *FederatedServer {
+// defaultMaxBodyBytes is the maxBodyBytes of a FederatedServer.
+const defaultMaxBodyBytes = 64 << 20
+
+func NewFederatedServer(listenAddr, service, p2pToken string, loadBalanced bool, workerTarget string, modelAware bool) *FederatedServer {
 	return &FederatedServer{
 		listenAddr:   listenAddr,
 		service:      service,
@@ -33,6 +46,10 @@ func NewFederatedServer(listenAddr, service, p2pToken string, loadBalanced bool,
 		requestTable: map[string]int{},
 		loadBalanced: loadBalanced,
 		workerTarget: workerTarget,
+		modelAware:   modelAware,
+		inFlight:     map[string]int{},
+		transports:   map[string]*http.Transport{},
+		maxBodyBytes: defaultMaxBodyBytes,
 	}
 }
 
@@ -72,6 +89,12 @@ func (fs *FederatedServer) syncTableStatus() {
 			delete(fs.requestTable, t)
 		}
 	}
+	for t, transport := range fs.transports {
+		if _, ok := currentTunnels[t]; !ok {
+			transport.CloseIdleConnections()
+			delete(fs.transports, t)
+		}
+	}
 }
 
 func (fs *FederatedServer) SelectLeastUsedServer() string {
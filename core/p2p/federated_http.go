@@ -0,0 +1,263 @@
+package p2p
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/edgevpn/pkg/node"
+	"github.com/mudler/xlog"
+)
+
+// federatedHost is the host of the requests forwarded to a node. Connections
+// are dialed over p2p streams, so it is never resolved.
+const federatedHost = "localai"
+
+// serveHTTP is the model-aware counterpart of proxy: instead of forwarding
+// raw connections, it reads each request's model and routes it to a node
+// that serves it, failing over to the next candidate on errors.
+func (fs *FederatedServer) serveHTTP(ctx context.Context, n *node.Node) error {
+	xlog.Info("Allocating service", "service", fs.service, "address", fs.listenAddr, "mode", "http")
+	l, err := net.Listen("tcp", fs.listenAddr)
+	if err != nil {
+		xlog.Error("Error listening", "error", err)
+		return err
+	}
+
+	nodeAnnounce(ctx, n)
+
+	srv := &http.Server{
+		Handler:           fs.routeRequest(n),
+		ReadHeaderTimeout: 30 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		srv.Close()
+	}()
+
+	if err := srv.Serve(l); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return errors.New("context canceled")
+}
+
+func (fs *FederatedServer) routeRequest(n *node.Node) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// The body is buffered so that it can be replayed on another node.
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, fs.maxBodyBytes))
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				writeHTMLMessage(w, http.StatusRequestEntityTooLarge, "The request is too large")
+				return
+			}
+			writeHTMLMessage(w, http.StatusBadRequest, "Could not read the request")
+			return
+		}
+		model := requestModel(r, body)
+
+		nodes := fs.candidates(model)
+		if len(nodes) == 0 {
+			xlog.Error("No available nodes yet")
+			writeHTMLMessage(w, http.StatusServiceUnavailable, "Sorry, waiting for nodes to connect")
+			return
+		}
+
+		for i, nd := range nodes {
+			last := i == len(nodes)-1
+
+			var proxyErr error
+			proxy := &httputil.ReverseProxy{
+				Rewrite: func(pr *httputil.ProxyRequest) {
+					pr.Out.URL.Scheme = "http"
+					pr.Out.URL.Host = federatedHost
+					pr.Out.Host = pr.In.Host
+					pr.SetXForwarded()
+				},
+				Transport: fs.transport(n, nd),
+				// Flush every write, so SSE events reach the client as the
+				// node produces them.
+				FlushInterval: -1,
+				// A 5xx is only worth retrying while nothing was sent to the
+				// client, which is still the case when headers come back.
+				ModifyResponse: func(resp *http.Response) error {
+					if !last && resp.StatusCode >= http.StatusInternalServerError {
+						return fmt.Errorf("node answered %s", resp.Status)
+					}
+					return nil
+				},
+				ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+					proxyErr = err
+					if last {
+						writeHTMLMessage(w, http.StatusBadGateway, "No node could serve the request")
+					}
+				},
+			}
+
+			req := r.Clone(r.Context())
+			req.Body = io.NopCloser(bytes.NewReader(body))
+			req.ContentLength = int64(len(body))
+
+			xlog.Debug("Selected node", "node", nd.ID, "model", model)
+			done := fs.trackRequest(nd.ID)
+			proxy.ServeHTTP(w, req)
+			done()
+
+			if proxyErr == nil {
+				if fs.loadBalanced {
+					fs.RecordRequest(nd.ID)
+				}
+				return
+			}
+			if r.Context().Err() != nil {
+				return
+			}
+			xlog.Warn("Node failed to serve the request", "node", nd.ID, "model", model, "error", proxyErr, "last", last)
+		}
+	}
+}
+
+// candidates returns the online nodes a request for model can be sent to, in
+// the order they should be tried: nodes with the model loaded, then nodes
+// that have it configured, then nodes that don't advertise their models.
+// Within each group the least busy nodes come first. Nodes advertising only
+// other models are used only when no node claims the model.
+func (fs *FederatedServer) candidates(model string) []schema.NodeData {
+	fs.syncTableStatus()
+
+	var nodes []schema.NodeData
+	for _, nd := range GetAvailableNodes(fs.service) {
+		if !nd.IsOnline() || (fs.workerTarget != "" && nd.ID != fs.workerTarget) {
+			continue
+		}
+		nodes = append(nodes, nd)
+	}
+	// Shuffle first, so that the stable sort below spreads ties across nodes.
+	rand.Shuffle(len(nodes), func(i, j int) { nodes[i], nodes[j] = nodes[j], nodes[i] })
+
+	rank := func(nd schema.NodeData) int {
+		switch {
+		case model == "" || slices.Contains(nd.LoadedModels, model):
+			return 0
+		case nd.Serves(model):
+			return 1
+		case len(nd.Models) == 0 && len(nd.LoadedModels) == 0:
+			return 2
+		default:
+			return 3
+		}
+	}
+
+	fs.Lock()
+	load := map[string]int{}
+	for _, nd := range nodes {
+		load[nd.ID] = nd.Busy + fs.inFlight[nd.ID]
+	}
+	fs.Unlock()
+
+	slices.SortStableFunc(nodes, func(a, b schema.NodeData) int {
+		if c := cmp.Compare(rank(a), rank(b)); c != 0 || !fs.loadBalanced {
+			return c
+		}
+		return cmp.Compare(load[a.ID], load[b.ID])
+	})
+
+	if len(nodes) > 0 && rank(nodes[0]) < 2 {
+		nodes = slices.DeleteFunc(nodes, func(nd schema.NodeData) bool { return rank(nd) == 3 })
+	}
+	return nodes
+}
+
+// trackRequest counts a request in flight on nodeID until the returned
+// function is called.
+func (fs *FederatedServer) trackRequest(nodeID string) func() {
+	fs.Lock()
+	fs.inFlight[nodeID]++
+	fs.Unlock()
+	return func() {
+		fs.Lock()
+		defer fs.Unlock()
+		if fs.inFlight[nodeID]--; fs.inFlight[nodeID] <= 0 {
+			delete(fs.inFlight, nodeID)
+		}
+	}
+}
+
+// transport returns the HTTP transport of a node, which keeps its p2p
+// connections alive across requests.
+func (fs *FederatedServer) transport(n *node.Node, nd schema.NodeData) *http.Transport {
+	fs.Lock()
+	defer fs.Unlock()
+	if t, ok := fs.transports[nd.ID]; ok {
+		return t
+	}
+	serviceID := nd.ServiceID
+	t := &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return dialService(ctx, n, serviceID)
+		},
+		MaxIdleConnsPerHost: 16,
+		IdleConnTimeout:     90 * time.Second,
+	}
+	fs.transports[nd.ID] = t
+	return t
+}
+
+// requestModel extracts the requested model from the query string or the
+// body (JSON, multipart or URL-encoded form). It returns "" for requests
+// that don't name one, e.g. listing models.
+func requestModel(r *http.Request, body []byte) string {
+	if m := r.URL.Query().Get("model"); m != "" {
+		return m
+	}
+	if len(body) == 0 {
+		return ""
+	}
+
+	mediaType, params, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case "multipart/form-data":
+		mr := multipart.NewReader(bytes.NewReader(body), params["boundary"])
+		for {
+			part, err := mr.NextPart()
+			if err != nil {
+				return ""
+			}
+			if part.FormName() == "model" {
+				m, _ := io.ReadAll(io.LimitReader(part, 1024))
+				return string(m)
+			}
+		}
+	case "application/x-www-form-urlencoded":
+		values, _ := url.ParseQuery(string(body))
+		return values.Get("model")
+	default:
+		var req struct {
+			Model string `json:"model"`
+		}
+		if json.Unmarshal(body, &req) != nil {
+			return ""
+		}
+		return req.Model
+	}
+}
+
+func writeHTMLMessage(w http.ResponseWriter, statusCode int, message string) {
+	w.Header().Set("Content-Type", "text/html")
+	w.WriteHeader(statusCode)
+	io.WriteString(w, htmlMessage(message))
+}
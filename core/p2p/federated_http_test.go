@@ -0,0 +1,224 @@
+package p2p
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/mudler/LocalAI/core/schema"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Model-aware federated routing", func() {
+	Describe("requestModel", func() {
+		It("reads the model from JSON bodies", func() {
+			body := []byte(`{"model": "qwen", "messages": []}`)
+			req := httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			Expect(requestModel(req, body)).To(Equal("qwen"))
+		})
+
+		It("reads the model from multipart forms", func() {
+			var buf bytes.Buffer
+			mw := multipart.NewWriter(&buf)
+			fw, _ := mw.CreateFormFile("file", "audio.wav")
+			fw.Write([]byte("RIFF"))
+			mw.WriteField("model", "whisper")
+			mw.Close()
+
+			req := httptest.NewRequest("POST", "/v1/audio/transcriptions", &buf)
+			req.Header.Set("Content-Type", mw.FormDataContentType())
+			Expect(requestModel(req, buf.Bytes())).To(Equal("whisper"))
+		})
+
+		It("reads the model from URL-encoded forms and the query string", func() {
+			body := []byte("model=bert&input=hi")
+			req := httptest.NewRequest("POST", "/embeddings", strings.NewReader(string(body)))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			Expect(requestModel(req, body)).To(Equal("bert"))
+
+			req = httptest.NewRequest("GET", "/v1/models?model=tts", nil)
+			Expect(requestModel(req, nil)).To(Equal("tts"))
+		})
+
+		It("returns nothing for requests without a model", func() {
+			req := httptest.NewRequest("GET", "/v1/models", nil)
+			Expect(requestModel(req, nil)).To(BeEmpty())
+		})
+	})
+
+	Describe("candidates", func() {
+		var fs *FederatedServer
+
+		BeforeEach(func() {
+			service := "federated-test-" + CurrentSpecReport().LeafNodeText
+			fs = NewFederatedServer(":0", service, "", true, "", true)
+			now := time.Now()
+			for _, nd := range []schema.NodeData{
+				{ID: "cold", LastSeen: now, Models: []string{"qwen", "bert"}},
+				{ID: "warm", LastSeen: now, Models: []string{"qwen"}, LoadedModels: []string{"qwen"}, Busy: 1},
+				{ID: "warm-idle", LastSeen: now, Models: []string{"qwen"}, LoadedModels: []string{"qwen"}},
+				{ID: "legacy", LastSeen: now},
+				{ID: "other", LastSeen: now, Models: []string{"whisper"}},
+				{ID: "offline", LastSeen: now.Add(-time.Hour), Models: []string{"qwen"}, LoadedModels: []string{"qwen"}},
+			} {
+				AddNode(service, nd)
+			}
+		})
+
+		ids := func(nodes []schema.NodeData) []string {
+			var out []string
+			for _, nd := range nodes {
+				out = append(out, nd.ID)
+			}
+			return out
+		}
+
+		It("prefers nodes with the model loaded, then the least busy", func() {
+			Expect(ids(fs.candidates("qwen"))).To(Equal([]string{"warm-idle", "warm", "cold", "legacy"}))
+		})
+
+		It("counts the requests it has in flight towards the load", func() {
+			done := fs.trackRequest("warm-idle")
+			done2 := fs.trackRequest("warm-idle")
+			Expect(ids(fs.candidates("qwen"))[:2]).To(Equal([]string{"warm", "warm-idle"}))
+			done()
+			done2()
+			Expect(fs.inFlight).To(BeEmpty())
+		})
+
+		It("falls back to every online node when nobody advertises the model", func() {
+			nodes := ids(fs.candidates("unknown"))
+			Expect(nodes).To(ConsistOf("cold", "warm", "warm-idle", "legacy", "other"))
+			Expect(nodes[0]).To(Equal("legacy"))
+		})
+
+		It("restricts routing to the target worker", func() {
+			fs.workerTarget = "cold"
+			Expect(ids(fs.candidates("qwen"))).To(Equal([]string{"cold"}))
+		})
+	})
+
+	Describe("routeRequest", func() {
+		var (
+			fs    *FederatedServer
+			proxy *httptest.Server
+		)
+
+		// nodeServing routes the requests of node id to handler.
+		nodeServing := func(id string, handler http.HandlerFunc) {
+			node := httptest.NewServer(handler)
+			DeferCleanup(node.Close)
+			fs.transports[id] = &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					return (&net.Dialer{}).DialContext(ctx, "tcp", node.Listener.Addr().String())
+				},
+			}
+		}
+
+		post := func(body string) (*http.Response, string) {
+			resp, err := http.Post(proxy.URL+"/v1/chat/completions", "application/json", strings.NewReader(body))
+			Expect(err).ToNot(HaveOccurred())
+			defer resp.Body.Close()
+			out, err := io.ReadAll(resp.Body)
+			Expect(err).ToNot(HaveOccurred())
+			return resp, string(out)
+		}
+
+		BeforeEach(func() {
+			service := "federated-route-" + CurrentSpecReport().LeafNodeText
+			fs = NewFederatedServer(":0", service, "", true, "", true)
+			now := time.Now()
+			AddNode(service, schema.NodeData{ID: "first", LastSeen: now, LoadedModels: []string{"qwen"}})
+			AddNode(service, schema.NodeData{ID: "second", LastSeen: now, Models: []string{"qwen"}})
+			proxy = httptest.NewServer(fs.routeRequest(nil))
+			DeferCleanup(proxy.Close)
+		})
+
+		answering := func(status int, text string, hits *[]string) http.HandlerFunc {
+			return func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				*hits = append(*hits, string(body))
+				w.WriteHeader(status)
+				io.WriteString(w, text)
+			}
+		}
+
+		It("fails over to the next node when a node answers 5xx", func() {
+			var first, second []string
+			nodeServing("first", answering(http.StatusInternalServerError, "broken", &first))
+			nodeServing("second", answering(http.StatusOK, "served", &second))
+
+			resp, body := post(`{"model":"qwen"}`)
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			Expect(body).To(Equal("served"))
+			Expect(first).To(Equal([]string{`{"model":"qwen"}`}))
+			Expect(second).To(Equal([]string{`{"model":"qwen"}`}), "the body is replayed")
+		})
+
+		It("fails over to the next node when a node cannot be dialed", func() {
+			var second []string
+			fs.transports["first"] = &http.Transport{
+				DialContext: func(context.Context, string, string) (net.Conn, error) {
+					return nil, errors.New("stream reset")
+				},
+			}
+			nodeServing("second", answering(http.StatusOK, "served", &second))
+
+			resp, body := post(`{"model":"qwen"}`)
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			Expect(body).To(Equal("served"))
+			Expect(second).To(HaveLen(1))
+		})
+
+		It("passes the last node's 5xx through", func() {
+			var first, second []string
+			nodeServing("first", answering(http.StatusInternalServerError, "broken", &first))
+			nodeServing("second", answering(http.StatusServiceUnavailable, "busy", &second))
+
+			resp, body := post(`{"model":"qwen"}`)
+			Expect(resp.StatusCode).To(Equal(http.StatusServiceUnavailable))
+			Expect(body).To(Equal("busy"))
+		})
+
+		It("streams server-sent events as the node writes them", func() {
+			release := make(chan struct{})
+			nodeServing("first", func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/event-stream")
+				io.WriteString(w, "data: one\n\n")
+				w.(http.Flusher).Flush()
+				<-release
+				io.WriteString(w, "data: [DONE]\n\n")
+			})
+			defer close(release)
+
+			resp, err := http.Post(proxy.URL+"/v1/chat/completions", "application/json", strings.NewReader(`{"model":"qwen","stream":true}`))
+			Expect(err).ToNot(HaveOccurred())
+			defer resp.Body.Close()
+			Expect(resp.Header.Get("Content-Type")).To(Equal("text/event-stream"))
+			line, err := bufio.NewReader(resp.Body).ReadString('\n')
+			Expect(err).ToNot(HaveOccurred())
+			Expect(line).To(Equal("data: one\n"))
+		})
+
+		It("refuses bodies over the limit", func() {
+			var first []string
+			nodeServing("first", answering(http.StatusOK, "served", &first))
+			fs.maxBodyBytes = 8
+
+			resp, _ := post(`{"model":"qwen"}`)
+			Expect(resp.StatusCode).To(Equal(http.StatusRequestEntityTooLarge))
+			Expect(first).To(BeEmpty())
+		})
+	})
+})
@@ -28,6 +28,9 @@ func (f *FederatedServer) Start(ctx context.Context) error {
 		return err
 	}
 
+	if f.modelAware {
+		return f.serveHTTP(ctx, n)
+	}
 	return f.proxy(ctx, n)
 }
 
@@ -108,7 +111,7 @@ func (fs *FederatedServer) sendHTMLResponse(conn net.Conn, statusCode int, messa
 	defer conn.Close()
 
 	// Define the HTML content separately for easier maintenance.
-	htmlContent := fmt.Sprintf("<html><body><h1>%s</h1></body></html>\r\n", message)
+	htmlContent := htmlMessage(message)
 
 	// Create the HTTP response with dynamic status code and content.
 	response := fmt.Sprintf(
@@ -127,6 +130,12 @@ func (fs *FederatedServer) sendHTMLResponse(conn net.Conn, statusCode int, messa
 	}
 }
 
+// htmlMessage is the page served when the federated server cannot forward a
+// request itself.
+func htmlMessage(message string) string {
+	return fmt.Sprintf("<html><body><h1>%s</h1></body></html>\r\n", message)
+}
+
 // getHTTPStatusText returns a textual representation of HTTP status codes.
 func getHTTPStatusText(statusCode int) string {
 	switch statusCode {
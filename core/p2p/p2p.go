@@ -13,6 +13,7 @@ import (
 
 	"github.com/ipfs/go-log"
 	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/p2p/net/gostream"
 	"github.com/mudler/LocalAI/core/schema"
 	"github.com/mudler/LocalAI/pkg/utils"
 	"github.com/mudler/edgevpn/pkg/config"
@@ -86,27 +87,39 @@ func nodeAnnounce(ctx context.Context, node *node.Node) {
 	)
 }
 
-func proxyP2PConnection(ctx context.Context, node *node.Node, serviceID string, conn net.Conn) {
+// servicePeer looks up the peer exposing serviceID in the ledger.
+func servicePeer(node *node.Node, serviceID string) (peer.ID, error) {
 	ledger, _ := node.Ledger()
 	// Retrieve current ID for ip in the blockchain
 	existingValue, found := ledger.GetKey(protocol.ServicesLedgerKey, serviceID)
-	service := &types.Service{}
-	existingValue.Unmarshal(service)
-	// If mismatch, update the blockchain
 	if !found {
-		zlog.Error("Service not found on blockchain")
-		conn.Close()
-		//	ll.Debugf("service '%s' not found on blockchain", serviceID)
-		return
+		return "", fmt.Errorf("service %q not found on blockchain", serviceID)
 	}
+	service := &types.Service{}
+	existingValue.Unmarshal(service)
 
 	// Decode the Peer
 	d, err := peer.Decode(service.PeerID)
 	if err != nil {
-		zlog.Error("cannot decode peer")
+		return "", fmt.Errorf("cannot decode peer %q: %w", service.PeerID, err)
+	}
+	return d, nil
+}
 
+// dialService opens a stream to the peer exposing serviceID, as a net.Conn.
+func dialService(ctx context.Context, node *node.Node, serviceID string) (net.Conn, error) {
+	d, err := servicePeer(node, serviceID)
+	if err != nil {
+		return nil, err
+	}
+	return gostream.Dial(ctx, node.Host(), d, protocol.ServiceProtocol.ID())
+}
+
+func proxyP2PConnection(ctx context.Context, node *node.Node, serviceID string, conn net.Conn) {
+	d, err := servicePeer(node, serviceID)
+	if err != nil {
+		zlog.Error("cannot find service peer", "error", err)
 		conn.Close()
-		//	ll.Debugf("could not decode peer '%s'", service.PeerID)
 		return
 	}
 
@@ -311,7 +324,9 @@ func ensureService(ctx context.Context, n *node.Node, nd *schema.NodeData, sserv
 }
 
 // This is the P2P worker main
-func ExposeService(ctx context.Context, host, port, token, servicesID string) (*node.Node, error) {
+// Each announce passes the node data through the annotate functions, which
+// let the exposing instance advertise its current state (e.g. its models).
+func ExposeService(ctx context.Context, host, port, token, servicesID string, annotate ...func(*schema.NodeData)) (*node.Node, error) {
 	if servicesID == "" {
 		servicesID = defaultServicesID
 	}
@@ -346,12 +361,16 @@ func ExposeService(ctx context.Context, host, port, token, servicesID string) (*
 		ctx,
 		20*time.Second,
 		func() {
-			updatedMap := map[string]any{}
-			updatedMap[name] = &schema.NodeData{
+			nd := &schema.NodeData{
 				Name:     name,
 				LastSeen: time.Now(),
 				ID:       nodeID(name),
 			}
+			for _, a := range annotate {
+				a(nd)
+			}
+			updatedMap := map[string]any{}
+			updatedMap[name] = nd
 			ledger.Add(servicesID, updatedMap)
 		},
 	)
@@ -218,3 +218,105 @@ func (m *AnthropicMessage) GetContentBlocks() []AnthropicContentBlock {
 	}
 	return nil
 }
+
+// AnthropicCountTokensResponse is the response of POST /v1/messages/count_tokens.
+type AnthropicCountTokensResponse struct {
+	InputTokens int `json:"input_tokens"`
+}
+
+// AnthropicModel is the model object returned by the Anthropic Models API.
+type AnthropicModel struct {
+	Type        string `json:"type"` // always "model"
+	ID          string `json:"id"`
+	DisplayName string `json:"display_name"`
+	CreatedAt   string `json:"created_at"`
+}
+
+// AnthropicModelList is the response of GET /v1/models when called by an
+// Anthropic client.
+type AnthropicModelList struct {
+	Data    []AnthropicModel `json:"data"`
+	HasMore bool             `json:"has_more"`
+	FirstID *string          `json:"first_id"`
+	LastID  *string          `json:"last_id"`
+}
+
+// Anthropic Message Batch processing states. They collapse the OpenAI batch
+// states: everything before a terminal state is in_progress.
+const (
+	AnthropicBatchInProgress = "in_progress"
+	AnthropicBatchCanceling  = "canceling"
+	AnthropicBatchEnded      = "ended"
+)
+
+// AnthropicMessageBatchRequest is one request of a Message Batch. Params
+// is kept raw so every Messages API field reaches the replayed request.
+type AnthropicMessageBatchRequest struct {
+	CustomID string          `json:"custom_id"`
+	Params   json.RawMessage `json:"params"`
+}
+
+// AnthropicMessageBatchCreateRequest is the body of POST /v1/messages/batches.
+type AnthropicMessageBatchCreateRequest struct {
+	Requests []AnthropicMessageBatchRequest `json:"requests"`
+}
+
+// AnthropicMessageBatchRequestCounts tracks per-request progress of a
+// Message Batch.
+type AnthropicMessageBatchRequestCounts struct {
+	Processing int `json:"processing"`
+	Succeeded  int `json:"succeeded"`
+	Errored    int `json:"errored"`
+	Canceled   int `json:"canceled"`
+	Expired    int `json:"expired"`
+}
+
+// AnthropicMessageBatch is the batch object returned by /v1/messages/batches.
+type AnthropicMessageBatch struct {
+	ID                string                             `json:"id"`
+	Type              string                             `json:"type"` // always "message_batch"
+	ProcessingStatus  string                             `json:"processing_status"`
+	RequestCounts     AnthropicMessageBatchRequestCounts `json:"request_counts"`
+	CreatedAt         string                             `json:"created_at"`
+	ExpiresAt         string                             `json:"expires_at"`
+	EndedAt           *string                            `json:"ended_at"`
+	CancelInitiatedAt *string                            `json:"cancel_initiated_at"`
+	ArchivedAt        *string                            `json:"archived_at"`
+	ResultsURL        *string                            `json:"results_url"`
+}
+
+// AnthropicMessageBatchList is the response of GET /v1/messages/batches.
+type AnthropicMessageBatchList struct {
+	Data    []AnthropicMessageBatch `json:"data"`
+	HasMore bool                    `json:"has_more"`
+	FirstID *string                 `json:"first_id"`
+	LastID  *string                 `json:"last_id"`
+}
+
+// AnthropicDeletedMessageBatch is returned when deleting a Message Batch.
+type AnthropicDeletedMessageBatch struct {
+	ID   string `json:"id"`
+	Type string `json:"type"` // always "message_batch_deleted"
+}
+
+// Anthropic Message Batch result types.
+const (
+	AnthropicBatchResultSucceeded = "succeeded"
+	AnthropicBatchResultErrored   = "errored"
+	AnthropicBatchResultCanceled  = "canceled"
+	AnthropicBatchResultExpired   = "expired"
+)
+
+// AnthropicMessageBatchResult is the outcome of one batch request.
+type AnthropicMessageBatchResult struct {
+	Type    string                  `json:"type"`
+	Message json.RawMessage         `json:"message,omitempty"`
+	Error   *AnthropicErrorResponse `json:"error,omitempty"`
+}
+
+// AnthropicMessageBatchResultLine is one line of the JSONL results of a
+// Message Batch.
+type AnthropicMessageBatchResultLine struct {
+	CustomID string                      `json:"custom_id"`
+	Result   AnthropicMessageBatchResult `json:"result"`
+}
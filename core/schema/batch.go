@@ -0,0 +1,139 @@
+package schema
+
+import "encoding/json"
+
+// OpenAI Batch API states. A batch moves validating -> in_progress ->
+// finalizing -> completed, or branches to failed (input rejected),
+// expired (completion window elapsed) or cancelling -> cancelled.
+const (
+	BatchStatusValidating = "validating"
+	BatchStatusFailed     = "failed"
+	BatchStatusInProgress = "in_progress"
+	BatchStatusFinalizing = "finalizing"
+	BatchStatusCompleted  = "completed"
+	BatchStatusExpired    = "expired"
+	BatchStatusCancelling = "cancelling"
+	BatchStatusCancelled  = "cancelled"
+)
+
+// File purposes understood by /v1/files. "batch" and "assistants" (see
+// FilePurposeAssistants) are accepted on upload; "batch_output" is assigned
+// to the output and error files a batch produces.
+const (
+	FilePurposeBatch       = "batch"
+	FilePurposeBatchOutput = "batch_output"
+)
+
+// OpenAIFile is the file object returned by /v1/files.
+type OpenAIFile struct {
+	ID        string `json:"id"`
+	Object    string `json:"object"` // always "file"
+	Bytes     int64  `json:"bytes"`
+	CreatedAt int64  `json:"created_at"`
+	Filename  string `json:"filename"`
+	Purpose   string `json:"purpose"`
+}
+
+// OpenAIFileList is the response of GET /v1/files.
+type OpenAIFileList struct {
+	Object string       `json:"object"` // always "list"
+	Data   []OpenAIFile `json:"data"`
+}
+
+// OpenAIDeleteResponse is returned when deleting a file.
+type OpenAIDeleteResponse struct {
+	ID      string `json:"id"`
+	Object  string `json:"object"`
+	Deleted bool   `json:"deleted"`
+}
+
+// BatchCreateRequest is the body of POST /v1/batches.
+type BatchCreateRequest struct {
+	InputFileID      string            `json:"input_file_id"`
+	Endpoint         string            `json:"endpoint"`
+	CompletionWindow string            `json:"completion_window"`
+	Metadata         map[string]string `json:"metadata,omitempty"`
+}
+
+// BatchRequestCounts tracks per-line progress of a batch.
+type BatchRequestCounts struct {
+	Total     int `json:"total"`
+	Completed int `json:"completed"`
+	Failed    int `json:"failed"`
+}
+
+// BatchError describes a validation error for a single input line.
+type BatchError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Param   string `json:"param,omitempty"`
+	Line    *int   `json:"line,omitempty"`
+}
+
+// BatchErrors is the errors envelope of a failed batch.
+type BatchErrors struct {
+	Object string       `json:"object"` // always "list"
+	Data   []BatchError `json:"data"`
+}
+
+// Batch is the batch object returned by /v1/batches.
+type Batch struct {
+	ID               string             `json:"id"`
+	Object           string             `json:"object"` // always "batch"
+	Endpoint         string             `json:"endpoint"`
+	Errors           *BatchErrors       `json:"errors,omitempty"`
+	InputFileID      string             `json:"input_file_id"`
+	CompletionWindow string             `json:"completion_window"`
+	Status           string             `json:"status"`
+	OutputFileID     *string            `json:"output_file_id"`
+	ErrorFileID      *string            `json:"error_file_id"`
+	CreatedAt        int64              `json:"created_at"`
+	InProgressAt     *int64             `json:"in_progress_at"`
+	ExpiresAt        *int64             `json:"expires_at"`
+	FinalizingAt     *int64             `json:"finalizing_at"`
+	CompletedAt      *int64             `json:"completed_at"`
+	FailedAt         *int64             `json:"failed_at"`
+	ExpiredAt        *int64             `json:"expired_at"`
+	CancellingAt     *int64             `json:"cancelling_at"`
+	CancelledAt      *int64             `json:"cancelled_at"`
+	RequestCounts    BatchRequestCounts `json:"request_counts"`
+	Metadata         map[string]string  `json:"metadata,omitempty"`
+}
+
+// BatchList is the response of GET /v1/batches.
+type BatchList struct {
+	Object  string  `json:"object"` // always "list"
+	Data    []Batch `json:"data"`
+	FirstID string  `json:"first_id,omitempty"`
+	LastID  string  `json:"last_id,omitempty"`
+	HasMore bool    `json:"has_more"`
+}
+
+// BatchInputLine is one line of a batch input JSONL file.
+type BatchInputLine struct {
+	CustomID string          `json:"custom_id"`
+	Method   string          `json:"method"`
+	URL      string          `json:"url"`
+	Body     json.RawMessage `json:"body"`
+}
+
+// BatchResponseBody is the per-line response recorded in the output file.
+type BatchResponseBody struct {
+	StatusCode int             `json:"status_code"`
+	RequestID  string          `json:"request_id"`
+	Body       json.RawMessage `json:"body"`
+}
+
+// BatchLineError is the per-line error recorded in the error file.
+type BatchLineError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// BatchOutputLine is one line of a batch output or error JSONL file.
+type BatchOutputLine struct {
+	ID       string             `json:"id"`
+	CustomID string             `json:"custom_id"`
+	Response *BatchResponseBody `json:"response"`
+	Error    *BatchLineError    `json:"error"`
+}
@@ -2,8 +2,10 @@ package schema
 
 import (
 	"encoding/json"
+	"slices"
 	"time"
 
+	"github.com/mudler/LocalAI/pkg/store"
 	gopsutil "github.com/shirou/gopsutil/v3/process"
 )
 
@@ -136,12 +138,17 @@ type VADResponse struct {
 
 type StoreCommon struct {
 	Backend string `json:"backend,omitempty" yaml:"backend,omitempty"`
+	// Namespace selects a logical collection within the store; empty is the
+	// default one.
+	Namespace string `json:"namespace,omitempty" yaml:"namespace,omitempty"`
 }
 type StoresSet struct {
 	Store string `json:"store,omitempty" yaml:"store,omitempty"`
 
 	Keys   [][]float32 `json:"keys" yaml:"keys"`
 	Values []string    `json:"values" yaml:"values"`
+	// Metadata is optional; when set it is aligned with Keys.
+	Metadata []store.Metadata `json:"metadata,omitempty" yaml:"metadata,omitempty"`
 	StoreCommon
 }
 
@@ -160,8 +167,9 @@ type StoresGet struct {
 }
 
 type StoresGetResponse struct {
-	Keys   [][]float32 `json:"keys" yaml:"keys"`
-	Values []string    `json:"values" yaml:"values"`
+	Keys     [][]float32      `json:"keys" yaml:"keys"`
+	Values   []string         `json:"values" yaml:"values"`
+	Metadata []store.Metadata `json:"metadata,omitempty" yaml:"metadata,omitempty"`
 }
 
 type StoresFind struct {
@@ -169,13 +177,17 @@ type StoresFind struct {
 
 	Key  []float32 `json:"key" yaml:"key"`
 	Topk int       `json:"topk" yaml:"topk"`
+	// Filters restrict the result to entries whose metadata matches all of
+	// them.
+	Filters []store.Filter `json:"filters,omitempty" yaml:"filters,omitempty"`
 	StoreCommon
 }
 
 type StoresFindResponse struct {
-	Keys         [][]float32 `json:"keys" yaml:"keys"`
-	Values       []string    `json:"values" yaml:"values"`
-	Similarities []float32   `json:"similarities" yaml:"similarities"`
+	Keys         [][]float32      `json:"keys" yaml:"keys"`
+	Values       []string         `json:"values" yaml:"values"`
+	Similarities []float32        `json:"similarities" yaml:"similarities"`
+	Metadata     []store.Metadata `json:"metadata,omitempty" yaml:"metadata,omitempty"`
 }
 
 type NodeData struct {
@@ -184,6 +196,17 @@ type NodeData struct {
 	TunnelAddress string
 	ServiceID     string
 	LastSeen      time.Time
+	// Models, LoadedModels and Busy are advertised by federated instances so
+	// the federated server can route a request to a node that already serves
+	// its model. Busy is the number of loaded models running a request.
+	Models       []string `json:",omitempty"`
+	LoadedModels []string `json:",omitempty"`
+	Busy         int      `json:",omitempty"`
+}
+
+// Serves reports whether the node advertises the model, loaded or not.
+func (d NodeData) Serves(model string) bool {
+	return slices.Contains(d.Models, model) || slices.Contains(d.LoadedModels, model)
 }
 
 func (d NodeData) IsOnline() bool {
@@ -333,17 +333,64 @@ type OllamaVersionResponse struct {
 
 // OllamaPullRequest represents a request to pull a model
 type OllamaPullRequest struct {
+	Model    string `json:"model"`
+	Name     string `json:"name"` // deprecated alias of Model
+	Insecure bool   `json:"insecure,omitempty"`
+	Stream   *bool  `json:"stream,omitempty"`
+}
+
+// IsStream returns whether streaming is enabled (defaults to true for Ollama)
+func (r *OllamaPullRequest) IsStream() bool {
+	return r.Stream == nil || *r.Stream
+}
+
+// OllamaPushRequest represents a request to push a model to a registry
+type OllamaPushRequest struct {
+	Model    string `json:"model"`
 	Name     string `json:"name"`
 	Insecure bool   `json:"insecure,omitempty"`
 	Stream   *bool  `json:"stream,omitempty"`
 }
 
+// OllamaProgressResponse is one line of the NDJSON stream returned by the
+// pull, push and create APIs
+type OllamaProgressResponse struct {
+	Status    string `json:"status"`
+	Digest    string `json:"digest,omitempty"`
+	Total     int64  `json:"total,omitempty"`
+	Completed int64  `json:"completed,omitempty"`
+}
+
 // OllamaDeleteRequest represents a request to delete a model
 type OllamaDeleteRequest struct {
 	Name  string `json:"name"`
 	Model string `json:"model"`
 }
 
+// OllamaCreateRequest represents a request to create a model. Current
+// Ollama clients send the Modelfile already parsed into From, Template,
+// System and Parameters; older ones send it verbatim in Modelfile.
+type OllamaCreateRequest struct {
+	Model      string            `json:"model"`
+	Name       string            `json:"name"` // deprecated alias of Model
+	Modelfile  string            `json:"modelfile,omitempty"`
+	From       string            `json:"from,omitempty"`
+	Template   string            `json:"template,omitempty"`
+	System     string            `json:"system,omitempty"`
+	Parameters map[string]any    `json:"parameters,omitempty"`
+	Adapters   map[string]string `json:"adapters,omitempty"`
+	Files      map[string]string `json:"files,omitempty"`
+	License    any               `json:"license,omitempty"`
+	Messages   []OllamaMessage   `json:"messages,omitempty"`
+	Quantize   string            `json:"quantize,omitempty"`
+	Stream     *bool             `json:"stream,omitempty"`
+}
+
+// IsStream returns whether streaming is enabled (defaults to true for Ollama)
+func (r *OllamaCreateRequest) IsStream() bool {
+	return r.Stream == nil || *r.Stream
+}
+
 // OllamaCopyRequest represents a request to copy a model
 type OllamaCopyRequest struct {
 	Source      string `json:"source"`
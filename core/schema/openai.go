@@ -182,7 +182,7 @@ type JsonSchemaRequest struct {
 type JsonSchema struct {
 	Name   string         `json:"name"`
 	Strict bool           `json:"strict"`
-	Schema functions.Item `json:"schema"`
+	Schema map[string]any `json:"schema"`
 }
 
 type OpenAIRequest struct {
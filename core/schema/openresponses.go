@@ -38,6 +38,7 @@ type OpenResponsesRequest struct {
 	Reasoning          *ORReasoningParam `json:"reasoning,omitempty"`
 	Metadata           map[string]string `json:"metadata,omitempty"`
 	PreviousResponseID string            `json:"previous_response_id,omitempty"`
+	Conversation       any               `json:"conversation,omitempty"` // conversation ID or {"id": "..."}
 
 	// Additional parameters from spec
 	TextFormat        any      `json:"text_format,omitempty"`         // TextResponseFormat or JsonSchemaResponseFormatParam
@@ -68,13 +69,31 @@ func (r *OpenResponsesRequest) ModelName(s *string) string {
 	return r.Model
 }
 
-// ORFunctionTool represents a function tool definition
+// ConversationID returns the ID of the conversation this request belongs to,
+// accepting both the string and the object form of the parameter.
+func (r *OpenResponsesRequest) ConversationID() string {
+	switch v := r.Conversation.(type) {
+	case string:
+		return v
+	case map[string]any:
+		id, _ := v["id"].(string)
+		return id
+	}
+	return ""
+}
+
+// ORFunctionTool represents a function tool definition, or a file_search
+// tool searching vector stores
 type ORFunctionTool struct {
-	Type        string         `json:"type"` // always "function"
+	Type        string         `json:"type"` // "function" or "file_search"
 	Name        string         `json:"name"`
 	Description string         `json:"description,omitempty"`
 	Parameters  map[string]any `json:"parameters,omitempty"`
 	Strict      bool           `json:"strict"` // Always include in response
+
+	// file_search tools only
+	VectorStoreIDs []string `json:"vector_store_ids,omitempty"`
+	MaxNumResults  *int     `json:"max_num_results,omitempty"`
 }
 
 // ORReasoningParam represents reasoning configuration
@@ -143,6 +162,7 @@ type ORResponseResource struct {
 	IncompleteDetails  *ORIncompleteDetails `json:"incomplete_details"` // Always present, null if complete
 	PreviousResponseID *string              `json:"previous_response_id"`
 	Instructions       *string              `json:"instructions"`
+	Conversation       *ORConversationRef   `json:"conversation,omitempty"` // set when the response belongs to a conversation
 
 	// Tool-related fields
 	Tools             []ORFunctionTool `json:"tools"` // Always present, empty array if no tools
@@ -317,3 +337,44 @@ func ORContentPartWithLogprobs(text string, logprobs *Logprobs) ORContentPart {
 		Logprobs:    orLogprobs,       // REQUIRED - must always be present as array (empty if none)
 	}
 }
+
+// ORConversationRef identifies the conversation a response belongs to
+type ORConversationRef struct {
+	ID string `json:"id"`
+}
+
+// ORConversation represents a conversation object (/v1/conversations)
+type ORConversation struct {
+	ID        string            `json:"id"`
+	Object    string            `json:"object"` // always "conversation"
+	CreatedAt int64             `json:"created_at"`
+	Metadata  map[string]string `json:"metadata"`
+}
+
+// ORConversationRequest is the body of POST /v1/conversations
+type ORConversationRequest struct {
+	Items    []ORItemParam     `json:"items,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// ORConversationItemsRequest is the body of POST /v1/conversations/{id}/items
+type ORConversationItemsRequest struct {
+	Items []ORItemParam `json:"items"`
+}
+
+// ORConversationDeleted is returned by DELETE /v1/conversations/{id}
+type ORConversationDeleted struct {
+	ID      string `json:"id"`
+	Object  string `json:"object"` // always "conversation.deleted"
+	Deleted bool   `json:"deleted"`
+}
+
+// ORItemList is a cursor-paginated page of items, as returned by
+// /v1/responses/{id}/input_items and /v1/conversations/{id}/items
+type ORItemList struct {
+	Object  string        `json:"object"` // always "list"
+	Data    []ORItemField `json:"data"`
+	FirstID *string       `json:"first_id"`
+	LastID  *string       `json:"last_id"`
+	HasMore bool          `json:"has_more"`
+}
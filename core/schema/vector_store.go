@@ -0,0 +1,171 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// OpenAI Vector Stores states. A vector store is in_progress while any of
+// its files is being ingested and completed otherwise; a file moves from
+// in_progress to completed or failed.
+const (
+	VectorStoreStatusInProgress = "in_progress"
+	VectorStoreStatusCompleted  = "completed"
+	VectorStoreStatusFailed     = "failed"
+)
+
+// FilePurposeAssistants is the purpose of files uploaded to be attached to
+// a vector store.
+const FilePurposeAssistants = "assistants"
+
+// VectorStoreFileCounts counts the files of a vector store by status.
+type VectorStoreFileCounts struct {
+	InProgress int `json:"in_progress"`
+	Completed  int `json:"completed"`
+	Failed     int `json:"failed"`
+	Cancelled  int `json:"cancelled"`
+	Total      int `json:"total"`
+}
+
+// VectorStore is the vector store object returned by /v1/vector_stores.
+type VectorStore struct {
+	ID           string                `json:"id"`
+	Object       string                `json:"object"` // always "vector_store"
+	CreatedAt    int64                 `json:"created_at"`
+	Name         string                `json:"name"`
+	UsageBytes   int64                 `json:"usage_bytes"`
+	FileCounts   VectorStoreFileCounts `json:"file_counts"`
+	Status       string                `json:"status"`
+	LastActiveAt *int64                `json:"last_active_at"`
+	ExpiresAt    *int64                `json:"expires_at"`
+	Metadata     map[string]string     `json:"metadata,omitempty"`
+	// EmbeddingModel is a LocalAI extension: the model the store's chunks
+	// are embedded with.
+	EmbeddingModel string `json:"embedding_model,omitempty"`
+}
+
+// VectorStoreList is the response of GET /v1/vector_stores.
+type VectorStoreList struct {
+	Object  string        `json:"object"` // always "list"
+	Data    []VectorStore `json:"data"`
+	FirstID string        `json:"first_id,omitempty"`
+	LastID  string        `json:"last_id,omitempty"`
+	HasMore bool          `json:"has_more"`
+}
+
+// StaticChunkingStrategy sizes the chunks a file is split into. Sizes are
+// counted in whitespace-separated words, which approximates tokens without
+// depending on the embedding model's tokenizer.
+type StaticChunkingStrategy struct {
+	MaxChunkSizeTokens int `json:"max_chunk_size_tokens"`
+	ChunkOverlapTokens int `json:"chunk_overlap_tokens"`
+}
+
+// ChunkingStrategy is the chunking_strategy of a vector store file. "auto"
+// uses the default static sizes.
+type ChunkingStrategy struct {
+	Type   string                  `json:"type"` // "auto" or "static"
+	Static *StaticChunkingStrategy `json:"static,omitempty"`
+}
+
+// VectorStoreCreateRequest is the body of POST /v1/vector_stores.
+type VectorStoreCreateRequest struct {
+	Name             string            `json:"name"`
+	FileIDs          []string          `json:"file_ids,omitempty"`
+	ChunkingStrategy *ChunkingStrategy `json:"chunking_strategy,omitempty"`
+	Metadata         map[string]string `json:"metadata,omitempty"`
+	// EmbeddingModel overrides the configured default embedding model
+	// (LocalAI extension).
+	EmbeddingModel string `json:"embedding_model,omitempty"`
+}
+
+// VectorStoreFileError is the last_error of a failed vector store file.
+type VectorStoreFileError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// VectorStoreFile is a file attached to a vector store.
+type VectorStoreFile struct {
+	ID               string                `json:"id"`
+	Object           string                `json:"object"` // always "vector_store.file"
+	CreatedAt        int64                 `json:"created_at"`
+	VectorStoreID    string                `json:"vector_store_id"`
+	Status           string                `json:"status"`
+	UsageBytes       int64                 `json:"usage_bytes"`
+	LastError        *VectorStoreFileError `json:"last_error"`
+	ChunkingStrategy *ChunkingStrategy     `json:"chunking_strategy,omitempty"`
+	Attributes       map[string]any        `json:"attributes,omitempty"`
+}
+
+// VectorStoreFileCreateRequest is the body of POST /v1/vector_stores/{id}/files.
+type VectorStoreFileCreateRequest struct {
+	FileID           string            `json:"file_id"`
+	ChunkingStrategy *ChunkingStrategy `json:"chunking_strategy,omitempty"`
+	Attributes       map[string]any    `json:"attributes,omitempty"`
+}
+
+// VectorStoreFileList is the response of GET /v1/vector_stores/{id}/files.
+type VectorStoreFileList struct {
+	Object  string            `json:"object"` // always "list"
+	Data    []VectorStoreFile `json:"data"`
+	FirstID string            `json:"first_id,omitempty"`
+	LastID  string            `json:"last_id,omitempty"`
+	HasMore bool              `json:"has_more"`
+}
+
+// VectorStoreRankingOptions tunes the results of a vector store search.
+type VectorStoreRankingOptions struct {
+	Ranker         string   `json:"ranker,omitempty"`
+	ScoreThreshold *float64 `json:"score_threshold,omitempty"`
+}
+
+// VectorStoreSearchQuery is the query of a vector store search: a single
+// string or a list of strings, whose results are merged.
+type VectorStoreSearchQuery []string
+
+// UnmarshalJSON accepts both a string and an array of strings.
+func (q *VectorStoreSearchQuery) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*q = VectorStoreSearchQuery{single}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return fmt.Errorf("query must be a string or an array of strings")
+	}
+	*q = many
+	return nil
+}
+
+// VectorStoreSearchRequest is the body of POST /v1/vector_stores/{id}/search.
+type VectorStoreSearchRequest struct {
+	Query          VectorStoreSearchQuery     `json:"query"`
+	MaxNumResults  *int                       `json:"max_num_results,omitempty"`
+	RankingOptions *VectorStoreRankingOptions `json:"ranking_options,omitempty"`
+}
+
+// VectorStoreSearchContent is one piece of content of a search result.
+type VectorStoreSearchContent struct {
+	Type string `json:"type"` // always "text"
+	Text string `json:"text"`
+}
+
+// VectorStoreSearchResult is a chunk matching a vector store search.
+type VectorStoreSearchResult struct {
+	FileID     string                     `json:"file_id"`
+	Filename   string                     `json:"filename"`
+	Score      float64                    `json:"score"`
+	Attributes map[string]any             `json:"attributes,omitempty"`
+	Content    []VectorStoreSearchContent `json:"content"`
+}
+
+// VectorStoreSearchResponse is the response of POST /v1/vector_stores/{id}/search.
+type VectorStoreSearchResponse struct {
+	Object      string                    `json:"object"` // always "vector_store.search_results.page"
+	SearchQuery []string                  `json:"search_query"`
+	Data        []VectorStoreSearchResult `json:"data"`
+	HasMore     bool                      `json:"has_more"`
+	NextPage    *string                   `json:"next_page"`
+}
@@ -49,6 +49,7 @@ type distributedBridge struct {
 	eventBridge AgentEventBridge        // Event bridge for SSE + persistence
 	skillStore  *distributed.SkillStore // PostgreSQL skill metadata (distributed mode)
 	dispatcher  agents.Dispatcher       // Native dispatcher (distributed or local)
+	scheduler   *agents.AgentScheduler  // Cron schedules and event triggers
 }
 
 // userManager handles per-user services, storage, and auth.
@@ -220,7 +221,7 @@ func (s *AgentPoolService) startDistributed(ctx context.Context, apiURL, apiKey
 	// It needs DB access to list configs and update LastRunAt — the worker doesn't have DB.
 	// The advisory lock ensures only one frontend instance runs the scheduler.
 	if s.users.authDB != nil && s.distributed.natsClient != nil && s.distributed.agentStore != nil {
-		var schedulerOpts []agents.AgentSchedulerOpt
+		schedulerOpts := []agents.AgentSchedulerOpt{agents.WithSchedulerTriggerStore(s.distributed.agentStore)}
 		if s.distributed.skillStore != nil {
 			schedulerOpts = append(schedulerOpts, agents.WithSchedulerSkillProvider(s.buildSkillProvider()))
 		}
@@ -231,6 +232,7 @@ func (s *AgentPoolService) startDistributed(ctx context.Context, apiURL, apiKey
 			messaging.SubjectAgentExecute,
 			schedulerOpts...,
 		)
+		s.distributed.scheduler = scheduler
 		go scheduler.Start(ctx)
 	}
 
@@ -1050,7 +1052,71 @@ func (s *AgentPoolService) ImportAgentForUser(userID string, data []byte) error
 		cfg.APIKey = plaintext
 	}
 
-	return s.configBackend.ImportConfig(userID, &cfg)
+	var sched agents.AgentScheduling
+	if err := json.Unmarshal(data, &sched); err != nil {
+		return fmt.Errorf("invalid agent config: %w", err)
+	}
+	if err := s.ValidateAgentScheduling(sched); err != nil {
+		return err
+	}
+
+	if err := s.configBackend.ImportConfig(userID, &cfg); err != nil {
+		return err
+	}
+	return s.SetAgentSchedulingForUser(userID, cfg.Name, sched)
+}
+
+// --- ForUser Scheduling ---
+
+// ValidateAgentScheduling checks the cron schedule and triggers of an agent
+// before it is saved. They are run by the agent scheduler, which only exists
+// in distributed mode.
+func (s *AgentPoolService) ValidateAgentScheduling(sched agents.AgentScheduling) error {
+	if sched.IsZero() {
+		return nil
+	}
+	if s.distributed.scheduler == nil {
+		return ErrSchedulingUnavailable
+	}
+	return agents.ValidateScheduling(&sched)
+}
+
+// GetAgentSchedulingForUser returns the cron schedule and triggers of a
+// user's agent. They are always empty outside distributed mode.
+func (s *AgentPoolService) GetAgentSchedulingForUser(userID, name string) agents.AgentScheduling {
+	if s.distributed.scheduler == nil {
+		return agents.AgentScheduling{}
+	}
+	rec, err := s.distributed.agentStore.GetConfig(userID, name)
+	if err != nil {
+		return agents.AgentScheduling{}
+	}
+	var cfg agents.AgentConfig
+	if err := agents.ParseConfigJSON(rec.ConfigJSON, &cfg); err != nil {
+		return agents.AgentScheduling{}
+	}
+	return cfg.AgentScheduling
+}
+
+// SetAgentSchedulingForUser replaces the cron schedule and triggers of a
+// user's agent.
+func (s *AgentPoolService) SetAgentSchedulingForUser(userID, name string, sched agents.AgentScheduling) error {
+	if s.distributed.scheduler == nil {
+		if sched.IsZero() {
+			return nil
+		}
+		return ErrSchedulingUnavailable
+	}
+	return s.distributed.agentStore.UpdateScheduling(userID, name, sched)
+}
+
+// TriggerAgentsForUser fires evt on the agents of its user with a matching
+// trigger and returns how many runs were queued.
+func (s *AgentPoolService) TriggerAgentsForUser(ctx context.Context, evt agents.TriggerEvent) (int, error) {
+	if s.distributed.scheduler == nil {
+		return 0, ErrSchedulingUnavailable
+	}
+	return s.distributed.scheduler.Trigger(ctx, evt)
 }
 
 // --- ForUser Collections ---
@@ -10,4 +10,7 @@ var (
 	ErrSkillsUnavailable = errors.New("skills service not available")
 	ErrTaskDisabled      = errors.New("task is disabled")
 	ErrJobQueueFull      = errors.New("job queue is full")
+	// ErrSchedulingUnavailable is returned for cron schedules and event
+	// triggers outside distributed mode, where no agent scheduler runs.
+	ErrSchedulingUnavailable = errors.New("agent schedules and triggers require distributed mode")
 )
@@ -56,6 +56,7 @@ type AgentConfig struct {
 	LastMessageDuration   string `json:"last_message_duration"`
 	PeriodicRuns          string `json:"periodic_runs"`
 	SchedulerPollInterval string `json:"scheduler_poll_interval"`
+	AgentScheduling
 
 	// Behavior
 	StandaloneJob          bool   `json:"standalone_job"`
@@ -130,6 +130,15 @@ func defaultFields() []ConfigField {
 		{Name: "can_stop_itself", Label: "Can Stop Itself", Type: FieldCheckbox, DefaultValue: false, Tags: ConfigFieldTags{Section: "AdvancedSettings"}},
 		{Name: "periodic_runs", Label: "Periodic Runs", Type: FieldText, Placeholder: "10m", HelpText: "Duration between periodic agent runs", Tags: ConfigFieldTags{Section: "AdvancedSettings"}},
 		{Name: "scheduler_poll_interval", Label: "Scheduler Poll Interval", Type: FieldText, DefaultValue: "30s", Tags: ConfigFieldTags{Section: "AdvancedSettings"}},
+		{Name: "schedule", Label: "Schedule", Type: FieldText, Placeholder: "0 9 * * MON-FRI", HelpText: "Cron expression for standalone job runs; replaces Periodic Runs", Tags: ConfigFieldTags{Section: "AdvancedSettings"}},
+		{Name: "schedule_timezone", Label: "Schedule Time Zone", Type: FieldText, Placeholder: "UTC", HelpText: "IANA time zone the schedule is evaluated in, e.g. Europe/Rome", Tags: ConfigFieldTags{Section: "AdvancedSettings"}},
+		{Name: "schedule_jitter", Label: "Schedule Jitter", Type: FieldText, Placeholder: "0s", HelpText: "Delay each scheduled run by a random offset up to this duration", Tags: ConfigFieldTags{Section: "AdvancedSettings"}},
+		{Name: "missed_runs", Label: "Missed Runs", Type: FieldSelect, DefaultValue: MissedRunsSkip,
+			Options: []ConfigFieldOption{
+				{Value: MissedRunsSkip, Label: "Skip"},
+				{Value: MissedRunsCatchUp, Label: "Catch up"},
+			},
+			HelpText: "What to do with scheduled runs missed while LocalAI was down", Tags: ConfigFieldTags{Section: "AdvancedSettings"}},
 		{Name: "enable_reasoning", Label: "Enable Reasoning", Type: FieldCheckbox, DefaultValue: false, Tags: ConfigFieldTags{Section: "AdvancedSettings"}},
 		{Name: "enable_reasoning_tool", Label: "Enable Reasoning for Tools", Type: FieldCheckbox, DefaultValue: true, Tags: ConfigFieldTags{Section: "AdvancedSettings"}},
 		{Name: "enable_reasoning_for_instruct", Label: "Enable Reasoning for Instruct Models", Type: FieldCheckbox, DefaultValue: false, HelpText: "Force structured reasoning before tool selection (recommended for instruct-tuned models)", Tags: ConfigFieldTags{Section: "AdvancedSettings"}},
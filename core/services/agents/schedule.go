@@ -0,0 +1,226 @@
+package agents
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// Missed-run policy constants for AgentScheduling.MissedRuns.
+const (
+	MissedRunsSkip    = "skip"     // default: runs missed while no scheduler was up are dropped
+	MissedRunsCatchUp = "catch_up" // missed runs are replayed, oldest first, one per poll
+)
+
+// AgentScheduling holds the cron schedule and event triggers of an agent.
+// These settings are LocalAI-only: LocalAGI's config has no equivalent and
+// drops them, so the config endpoints carry them alongside it and the store
+// merges them into the saved config JSON.
+type AgentScheduling struct {
+	// Schedule is a cron expression (5 fields or a descriptor such as
+	// @hourly). When set it replaces periodic_runs for standalone jobs.
+	Schedule string `json:"schedule,omitempty"`
+	// ScheduleTimezone is the IANA time zone the schedule is evaluated in.
+	// Defaults to UTC.
+	ScheduleTimezone string `json:"schedule_timezone,omitempty"`
+	// ScheduleJitter delays every run by a stable pseudo-random offset
+	// below this duration, so agents sharing a schedule don't all fire at once.
+	ScheduleJitter string `json:"schedule_jitter,omitempty"`
+	// MissedRuns is the policy for runs missed while no scheduler was up:
+	// "skip" (default) or "catch_up".
+	MissedRuns string `json:"missed_runs,omitempty"`
+	// Triggers run the agent when an event happens.
+	Triggers []AgentTrigger `json:"triggers,omitempty"`
+}
+
+// IsZero reports whether no scheduling setting is set.
+func (s AgentScheduling) IsZero() bool {
+	return s.Schedule == "" && s.ScheduleTimezone == "" && s.ScheduleJitter == "" && s.MissedRuns == "" && len(s.Triggers) == 0
+}
+
+// schedulingKeys are the JSON keys of AgentScheduling.
+var schedulingKeys = []string{"schedule", "schedule_timezone", "schedule_jitter", "missed_runs", "triggers"}
+
+// MergeSchedulingJSON replaces the scheduling settings in an agent config
+// JSON object with sched.
+func MergeSchedulingJSON(configJSON []byte, sched AgentScheduling) ([]byte, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(configJSON, &fields); err != nil {
+		return nil, fmt.Errorf("invalid agent config: %w", err)
+	}
+	if fields == nil {
+		fields = map[string]json.RawMessage{}
+	}
+	for _, k := range schedulingKeys {
+		delete(fields, k)
+	}
+	dat, err := json.Marshal(sched)
+	if err != nil {
+		return nil, err
+	}
+	var schedFields map[string]json.RawMessage
+	if err := json.Unmarshal(dat, &schedFields); err != nil {
+		return nil, err
+	}
+	for k, v := range schedFields {
+		fields[k] = v
+	}
+	return json.Marshal(fields)
+}
+
+// ApplySchedulingJSON returns sched with the scheduling settings present in
+// body, an agent config JSON object, replaced. The settings body leaves
+// out keep their value in sched.
+func ApplySchedulingJSON(sched AgentScheduling, body []byte) (AgentScheduling, error) {
+	var update map[string]json.RawMessage
+	if err := json.Unmarshal(body, &update); err != nil {
+		return sched, err
+	}
+	dat, err := json.Marshal(sched)
+	if err != nil {
+		return sched, err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(dat, &fields); err != nil {
+		return sched, err
+	}
+	for _, k := range schedulingKeys {
+		if v, ok := update[k]; ok {
+			fields[k] = v
+		}
+	}
+	dat, err = json.Marshal(fields)
+	if err != nil {
+		return sched, err
+	}
+	var out AgentScheduling
+	if err := json.Unmarshal(dat, &out); err != nil {
+		return sched, err
+	}
+	return out, nil
+}
+
+// ValidateScheduling reports the first invalid setting in sched.
+func ValidateScheduling(sched *AgentScheduling) error {
+	if _, err := parseSchedule(sched); err != nil {
+		return err
+	}
+	for i, t := range sched.Triggers {
+		if err := t.validate(); err != nil {
+			return fmt.Errorf("trigger %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// scheduleParser accepts the same expressions as the cron field of tasks.
+var scheduleParser = cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+// missedRunGrace is how late a tick may be noticed and still run under the
+// skip policy. It spans a few scheduler polls, so a slow poll or a leader
+// handover doesn't drop a run.
+const missedRunGrace = time.Minute
+
+// maxCatchUpRuns bounds the runs replayed by the catch_up policy: after a
+// long outage only the most recent missed ticks are run.
+const maxCatchUpRuns = 10
+
+// maxScheduleScan bounds the ticks walked in one evaluation, so a
+// per-minute schedule missed for months doesn't stall the scheduler.
+const maxScheduleScan = 100000
+
+// agentSchedule is the parsed cron schedule of an agent.
+type agentSchedule struct {
+	cron     cron.Schedule
+	location *time.Location
+	jitter   time.Duration
+	catchUp  bool
+}
+
+// parseSchedule parses the cron settings of sched. It returns nil when the
+// agent has no cron schedule.
+func parseSchedule(sched *AgentScheduling) (*agentSchedule, error) {
+	s := &agentSchedule{location: time.UTC}
+	var err error
+	if sched.ScheduleTimezone != "" {
+		if s.location, err = time.LoadLocation(sched.ScheduleTimezone); err != nil {
+			return nil, fmt.Errorf("invalid schedule_timezone %q: %w", sched.ScheduleTimezone, err)
+		}
+	}
+	if sched.ScheduleJitter != "" {
+		if s.jitter, err = time.ParseDuration(sched.ScheduleJitter); err != nil || s.jitter < 0 {
+			return nil, fmt.Errorf("invalid schedule_jitter %q", sched.ScheduleJitter)
+		}
+	}
+	switch sched.MissedRuns {
+	case "", MissedRunsSkip:
+	case MissedRunsCatchUp:
+		s.catchUp = true
+	default:
+		return nil, fmt.Errorf("invalid missed_runs %q: must be %s or %s", sched.MissedRuns, MissedRunsSkip, MissedRunsCatchUp)
+	}
+	if sched.Schedule == "" {
+		return nil, nil
+	}
+	if s.cron, err = scheduleParser.Parse(sched.Schedule); err != nil {
+		return nil, fmt.Errorf("invalid schedule %q: %w", sched.Schedule, err)
+	}
+	return s, nil
+}
+
+// fireAt returns when the tick runs: the tick delayed by the jitter offset
+// of the agent. The offset is derived from key and the tick, so every
+// replica computes the same one.
+func (s *agentSchedule) fireAt(key string, tick time.Time) time.Time {
+	if s.jitter <= 0 {
+		return tick
+	}
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	binary.Write(h, binary.LittleEndian, tick.Unix())
+	return tick.Add(time.Duration(h.Sum64() % uint64(s.jitter)))
+}
+
+// next returns the tick to handle after last, the previous tick handled.
+// A zero tick means nothing is due. Otherwise the caller records the tick
+// as handled, and runs the agent when run is true; when run is false, the
+// ticks up to this one were missed and are skipped.
+func (s *agentSchedule) next(key string, last, now time.Time) (tick time.Time, run bool) {
+	from := last.In(s.location)
+	if !s.catchUp {
+		// Ticks firing before the grace window are skipped anyway: start
+		// the walk there, remembering whether any was skipped.
+		cutoff := now.Add(-missedRunGrace - s.jitter).In(s.location)
+		if from.Before(cutoff) {
+			if n := s.cron.Next(from); !n.IsZero() && !n.After(cutoff) {
+				tick = cutoff
+			}
+			from = cutoff
+		}
+	}
+
+	var missed []time.Time
+	for range maxScheduleScan {
+		n := s.cron.Next(from)
+		if n.IsZero() || s.fireAt(key, n).After(now) {
+			break
+		}
+		if s.catchUp && len(missed) == maxCatchUpRuns {
+			missed = missed[1:]
+		}
+		missed = append(missed, n)
+		from = n
+	}
+	if len(missed) == 0 {
+		return tick, false
+	}
+	if s.catchUp {
+		return missed[0], true
+	}
+	latest := missed[len(missed)-1]
+	return latest, now.Sub(s.fireAt(key, latest)) <= missedRunGrace
+}
@@ -0,0 +1,194 @@
+package agents
+
+import (
+	"encoding/json"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func at(hour, minute, second int) time.Time {
+	return time.Date(2026, 3, 10, hour, minute, second, 0, time.UTC)
+}
+
+func mustParseSchedule(sched AgentScheduling) *agentSchedule {
+	s, err := parseSchedule(&sched)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(s).ToNot(BeNil())
+	return s
+}
+
+var _ = Describe("Agent cron schedules", func() {
+	Describe("ValidateScheduling", func() {
+		It("accepts an empty configuration", func() {
+			Expect(ValidateScheduling(&AgentScheduling{})).To(Succeed())
+		})
+
+		It("accepts cron expressions, descriptors and time zones", func() {
+			Expect(ValidateScheduling(&AgentScheduling{Schedule: "*/5 9-17 * * MON-FRI", ScheduleTimezone: "Europe/Rome"})).To(Succeed())
+			Expect(ValidateScheduling(&AgentScheduling{Schedule: "@daily", ScheduleJitter: "10m", MissedRuns: MissedRunsCatchUp})).To(Succeed())
+		})
+
+		It("rejects invalid settings", func() {
+			Expect(ValidateScheduling(&AgentScheduling{Schedule: "every day"})).To(MatchError(ContainSubstring("invalid schedule")))
+			Expect(ValidateScheduling(&AgentScheduling{Schedule: "@hourly", ScheduleTimezone: "Mars/Olympus"})).To(MatchError(ContainSubstring("schedule_timezone")))
+			Expect(ValidateScheduling(&AgentScheduling{Schedule: "@hourly", ScheduleJitter: "-1m"})).To(MatchError(ContainSubstring("schedule_jitter")))
+			Expect(ValidateScheduling(&AgentScheduling{Schedule: "@hourly", MissedRuns: "all"})).To(MatchError(ContainSubstring("missed_runs")))
+			Expect(ValidateScheduling(&AgentScheduling{Triggers: []AgentTrigger{{Type: "email"}}})).To(MatchError(ContainSubstring("unknown trigger type")))
+			Expect(ValidateScheduling(&AgentScheduling{Triggers: []AgentTrigger{{Type: TriggerJobCompleted, Statuses: []string{"running"}}}})).To(MatchError(ContainSubstring("invalid job status")))
+		})
+	})
+
+	Describe("next", func() {
+		It("runs the tick once it is due", func() {
+			s := mustParseSchedule(AgentScheduling{Schedule: "0 * * * *"})
+
+			tick, _ := s.next("agent", at(10, 0, 0), at(10, 30, 0))
+			Expect(tick.IsZero()).To(BeTrue())
+
+			tick, run := s.next("agent", at(10, 0, 0), at(11, 0, 20))
+			Expect(tick).To(Equal(at(11, 0, 0)))
+			Expect(run).To(BeTrue())
+		})
+
+		It("skips the runs missed during an outage by default", func() {
+			s := mustParseSchedule(AgentScheduling{Schedule: "0 * * * *"})
+
+			tick, run := s.next("agent", at(8, 0, 0), at(11, 20, 0))
+			Expect(run).To(BeFalse())
+			Expect(tick).To(Equal(at(11, 19, 0)))
+
+			tick, _ = s.next("agent", tick, at(11, 21, 0))
+			Expect(tick.IsZero()).To(BeTrue())
+
+			tick, run = s.next("agent", at(11, 19, 0), at(12, 0, 30))
+			Expect(tick).To(Equal(at(12, 0, 0)))
+			Expect(run).To(BeTrue())
+		})
+
+		It("skips a tick noticed after the grace period", func() {
+			s := mustParseSchedule(AgentScheduling{Schedule: "0 * * * *"})
+
+			_, run := s.next("agent", at(10, 0, 0), at(11, 5, 0))
+			Expect(run).To(BeFalse())
+		})
+
+		It("replays missed runs oldest first with catch_up", func() {
+			s := mustParseSchedule(AgentScheduling{Schedule: "0 * * * *", MissedRuns: MissedRunsCatchUp})
+
+			var ran []time.Time
+			last := at(8, 0, 0)
+			for {
+				tick, run := s.next("agent", last, at(11, 20, 0))
+				if tick.IsZero() {
+					break
+				}
+				Expect(run).To(BeTrue())
+				ran = append(ran, tick)
+				last = tick
+			}
+			Expect(ran).To(Equal([]time.Time{at(9, 0, 0), at(10, 0, 0), at(11, 0, 0)}))
+		})
+
+		It("bounds the runs replayed with catch_up", func() {
+			s := mustParseSchedule(AgentScheduling{Schedule: "0 * * * *", MissedRuns: MissedRunsCatchUp})
+
+			tick, run := s.next("agent", at(0, 0, 0).AddDate(0, 0, -2), at(20, 30, 0))
+			Expect(run).To(BeTrue())
+			Expect(tick).To(Equal(at(11, 0, 0)))
+		})
+
+		It("evaluates the expression in the configured time zone", func() {
+			s := mustParseSchedule(AgentScheduling{Schedule: "0 9 * * *", ScheduleTimezone: "Europe/Rome"})
+
+			// 09:00 in Rome is 08:00 UTC in winter
+			tick, run := s.next("agent", at(7, 0, 0), at(8, 0, 30))
+			Expect(run).To(BeTrue())
+			Expect(tick.Equal(at(8, 0, 0))).To(BeTrue())
+		})
+
+		It("delays runs by a stable jitter offset", func() {
+			s := mustParseSchedule(AgentScheduling{Schedule: "0 * * * *", ScheduleJitter: "10m"})
+
+			fire := s.fireAt("agent", at(11, 0, 0))
+			Expect(fire).To(BeTemporally(">=", at(11, 0, 0)))
+			Expect(fire).To(BeTemporally("<", at(11, 10, 0)))
+			Expect(s.fireAt("agent", at(11, 0, 0))).To(Equal(fire))
+
+			tick, _ := s.next("agent", at(10, 0, 0), fire.Add(-time.Second))
+			Expect(tick.IsZero()).To(BeTrue())
+			tick, run := s.next("agent", at(10, 0, 0), fire)
+			Expect(tick).To(Equal(at(11, 0, 0)))
+			Expect(run).To(BeTrue())
+		})
+	})
+
+	Describe("runDueAgents with a schedule", func() {
+		var (
+			pub    *mockPublisher
+			mStore *mockSchedulerStore
+			sched  *AgentScheduler
+			now    time.Time
+		)
+
+		BeforeEach(func() {
+			pub = &mockPublisher{}
+			mStore = &mockSchedulerStore{}
+			sched = NewAgentScheduler(nil, pub, mStore, "agent.execute")
+			sched.now = func() time.Time { return now }
+		})
+
+		record := func(lastRun *time.Time) AgentConfigRecord {
+			cfg := AgentConfig{StandaloneJob: true, PeriodicRuns: "1m"}
+			cfg.Schedule = "0 * * * *"
+			cfgJSON, err := json.Marshal(cfg)
+			Expect(err).ToNot(HaveOccurred())
+			return AgentConfigRecord{
+				ID:         "rec-cron",
+				UserID:     "user-1",
+				Name:       "cron-agent",
+				ConfigJSON: string(cfgJSON),
+				Status:     StatusActive,
+				LastRunAt:  lastRun,
+				CreatedAt:  at(9, 30, 0),
+			}
+		}
+
+		It("ignores periodic_runs and records the tick it ran for", func() {
+			last := at(10, 0, 0)
+			mStore.configs = []AgentConfigRecord{record(&last)}
+
+			now = at(10, 30, 0)
+			sched.runDueAgents()
+			Expect(pub.calls).To(BeEmpty())
+
+			now = at(11, 0, 10)
+			sched.runDueAgents()
+			Expect(pub.calls).To(HaveLen(1))
+			Expect(pub.calls[0].data.(AgentChatEvent).Role).To(Equal(RoleSystem))
+			Expect(mStore.updated).To(HaveLen(1))
+			Expect(mStore.updated[0].at).To(Equal(at(11, 0, 0)))
+		})
+
+		It("counts from the creation of an agent that never ran", func() {
+			mStore.configs = []AgentConfigRecord{record(nil)}
+
+			now = at(10, 0, 10)
+			sched.runDueAgents()
+			Expect(pub.calls).To(HaveLen(1))
+			Expect(mStore.updated[0].at).To(Equal(at(10, 0, 0)))
+		})
+
+		It("records skipped runs without publishing", func() {
+			last := at(6, 0, 0)
+			mStore.configs = []AgentConfigRecord{record(&last)}
+
+			now = at(10, 30, 0)
+			sched.runDueAgents()
+			Expect(pub.calls).To(BeEmpty())
+			Expect(mStore.updated).To(HaveLen(1))
+			Expect(mStore.updated[0].at).To(Equal(at(10, 29, 0)))
+		})
+	})
+})
@@ -3,6 +3,7 @@ package agents
 import (
 	"context"
 	"fmt"
+	"slices"
 	"time"
 
 	"github.com/mudler/LocalAI/core/services/advisorylock"
@@ -15,19 +16,31 @@ import (
 type SchedulerStore interface {
 	ListConfigs(userID string) ([]AgentConfigRecord, error)
 	UpdateLastRun(userID, name string) error
+	UpdateLastRunAt(userID, name string, at time.Time) error
 }
 
+// triggerBatchSize is the number of trigger events fired per poll.
+const triggerBatchSize = 100
+
+// triggerRetention is how long fired trigger events are kept, which is
+// also how long their dedup keys hold.
+const triggerRetention = 24 * time.Hour
+
 // AgentScheduler periodically checks for agents with standalone_job=true
 // and publishes background run events to the NATS agent execution queue.
+// It also fires the event triggers queued by Trigger.
 // Uses a PostgreSQL advisory lock so only one instance fires the cron.
 // Same pattern as notetaker's runAgentScheduler and LocalAI's cronLeaderLoop.
 type AgentScheduler struct {
 	db            *gorm.DB
 	nats          messaging.Publisher
 	store         SchedulerStore
+	triggers      TriggerStore         // optional: queue of event triggers
 	skillProvider SkillContentProvider // optional: loads full skill info for enriching events
 	subject       string               // NATS subject for agent execution
 	pollInterval  time.Duration        // how often to check for due agents
+	lastPrune     time.Time
+	now           func() time.Time
 }
 
 // AgentSchedulerOpt is a functional option for AgentScheduler.
@@ -40,6 +53,13 @@ func WithSchedulerSkillProvider(provider SkillContentProvider) AgentSchedulerOpt
 	}
 }
 
+// WithSchedulerTriggerStore enables event triggers, queued in store.
+func WithSchedulerTriggerStore(store TriggerStore) AgentSchedulerOpt {
+	return func(s *AgentScheduler) {
+		s.triggers = store
+	}
+}
+
 // NewAgentScheduler creates a new background agent scheduler.
 func NewAgentScheduler(db *gorm.DB, nats messaging.Publisher, store SchedulerStore, subject string, opts ...AgentSchedulerOpt) *AgentScheduler {
 	s := &AgentScheduler{
@@ -48,6 +68,7 @@ func NewAgentScheduler(db *gorm.DB, nats messaging.Publisher, store SchedulerSto
 		store:        store,
 		subject:      subject,
 		pollInterval: 15 * time.Second,
+		now:          time.Now,
 	}
 	for _, opt := range opts {
 		opt(s)
@@ -58,7 +79,10 @@ func NewAgentScheduler(db *gorm.DB, nats messaging.Publisher, store SchedulerSto
 // Start begins the scheduler loop. Blocks until ctx is cancelled.
 func (s *AgentScheduler) Start(ctx context.Context) {
 	xlog.Info("Agent scheduler started", "pollInterval", s.pollInterval, "subject", s.subject)
-	advisorylock.RunLeaderLoop(ctx, s.db, advisorylock.KeyAgentScheduler, s.pollInterval, s.runDueAgents)
+	advisorylock.RunLeaderLoop(ctx, s.db, advisorylock.KeyAgentScheduler, s.pollInterval, func() {
+		s.runDueAgents()
+		s.runTriggerEvents()
+	})
 	xlog.Info("Agent scheduler stopped")
 }
 
@@ -85,6 +109,17 @@ func (s *AgentScheduler) runDueAgents() {
 			continue
 		}
 
+		// A cron schedule replaces the periodic run interval
+		sched, err := parseSchedule(&cfg.AgentScheduling)
+		if err != nil {
+			xlog.Warn("Agent scheduler: invalid schedule", "agent", rec.Name, "user", rec.UserID, "error", err)
+			continue
+		}
+		if sched != nil {
+			s.runScheduled(rec, &cfg, sched)
+			continue
+		}
+
 		// Parse the periodic run interval
 		interval := parseInterval(cfg.PeriodicRuns)
 
@@ -95,31 +130,184 @@ func (s *AgentScheduler) runDueAgents() {
 
 		xlog.Info("Scheduling background agent run", "agent", rec.Name, "user", rec.UserID, "interval", interval)
 
-		// Enrich the event with config and skills so the worker needs no DB access
-		var skills []SkillInfo
-		if cfg.EnableSkills && s.skillProvider != nil {
-			if loaded, err := s.skillProvider(rec.UserID); err == nil {
-				skills = loaded
+		if !s.publishRun(rec, &cfg, fmt.Sprintf("bg-%d", time.Now().UnixNano()), RoleSystem, "") {
+			continue
+		}
+
+		// Update last run timestamp
+		if err := s.store.UpdateLastRun(rec.UserID, rec.Name); err != nil {
+			xlog.Warn("Agent scheduler: failed to update last run", "agent", rec.Name, "error", err)
+		}
+	}
+}
+
+// runScheduled runs an agent with a cron schedule when a tick is due.
+// LastRunAt holds the last tick handled; an agent that never ran counts
+// from its creation, so it doesn't fire for ticks that predate it.
+func (s *AgentScheduler) runScheduled(rec AgentConfigRecord, cfg *AgentConfig, sched *agentSchedule) {
+	now := s.now()
+	last := rec.CreatedAt
+	if rec.LastRunAt != nil {
+		last = *rec.LastRunAt
+	}
+	if last.IsZero() {
+		if err := s.store.UpdateLastRunAt(rec.UserID, rec.Name, now); err != nil {
+			xlog.Warn("Agent scheduler: failed to update last run", "agent", rec.Name, "error", err)
+		}
+		return
+	}
+
+	tick, run := sched.next(rec.ID, last, now)
+	if tick.IsZero() {
+		return
+	}
+	if run {
+		xlog.Info("Scheduling background agent run", "agent", rec.Name, "user", rec.UserID, "schedule", cfg.Schedule, "tick", tick)
+		if !s.publishRun(rec, cfg, fmt.Sprintf("bg-%d", tick.Unix()), RoleSystem, "") {
+			return
+		}
+	} else {
+		xlog.Info("Skipping missed agent runs", "agent", rec.Name, "user", rec.UserID, "schedule", cfg.Schedule, "until", tick)
+	}
+	if err := s.store.UpdateLastRunAt(rec.UserID, rec.Name, tick); err != nil {
+		xlog.Warn("Agent scheduler: failed to update last run", "agent", rec.Name, "error", err)
+	}
+}
+
+// publishRun publishes an execution event for the agent. It reports
+// whether the event was published.
+func (s *AgentScheduler) publishRun(rec AgentConfigRecord, cfg *AgentConfig, messageID, role, message string) bool {
+	// Enrich the event with config and skills so the worker needs no DB access
+	var skills []SkillInfo
+	if cfg.EnableSkills && s.skillProvider != nil {
+		if loaded, err := s.skillProvider(rec.UserID); err == nil {
+			skills = loaded
+		}
+	}
+
+	evt := AgentChatEvent{
+		AgentName: rec.Name,
+		UserID:    rec.UserID,
+		Message:   message,
+		MessageID: messageID,
+		Role:      role,
+		Config:    cfg,
+		Skills:    skills,
+	}
+	if err := s.nats.Publish(s.subject, evt); err != nil {
+		xlog.Error("Agent scheduler: failed to publish event", "agent", rec.Name, "error", err)
+		return false
+	}
+	return true
+}
+
+// Trigger queues evt for the active agents of its user with a matching
+// trigger and returns how many were newly queued. Events already queued by
+// another replica are not queued again. When no other instance holds the
+// scheduler lock the events are fired right away; otherwise the leader
+// fires them on its next poll.
+func (s *AgentScheduler) Trigger(ctx context.Context, evt TriggerEvent) (int, error) {
+	if s.triggers == nil {
+		return 0, fmt.Errorf("agent triggers are not enabled")
+	}
+	configs, err := s.store.ListConfigs(evt.UserID)
+	if err != nil {
+		return 0, err
+	}
+
+	queued := 0
+	for _, rec := range configs {
+		if rec.Status != StatusActive || rec.UserID != evt.UserID {
+			continue
+		}
+		if evt.Type == TriggerWebhook && rec.Name != evt.Agent {
+			continue
+		}
+		var cfg AgentConfig
+		if err := ParseConfigJSON(rec.ConfigJSON, &cfg); err != nil {
+			continue
+		}
+		if !slices.ContainsFunc(cfg.Triggers, func(t AgentTrigger) bool { return t.matches(rec.Name, evt) }) {
+			if evt.Type == TriggerWebhook {
+				return 0, ErrNoMatchingTrigger
 			}
+			continue
 		}
 
-		// Publish background run event
-		evt := AgentChatEvent{
-			AgentName: rec.Name,
+		ok, err := s.triggers.RecordTriggerEvent(&AgentTriggerEventRecord{
+			DedupKey:  triggerDedupKey(evt, rec.Name),
 			UserID:    rec.UserID,
-			MessageID: fmt.Sprintf("bg-%d", time.Now().UnixNano()),
-			Role:      RoleSystem,
-			Config:    &cfg,
-			Skills:    skills,
+			AgentName: rec.Name,
+			Type:      evt.Type,
+			Message:   evt.Message,
+			CreatedAt: s.now(),
+		})
+		if err != nil {
+			return queued, err
 		}
-		if err := s.nats.Publish(s.subject, evt); err != nil {
-			xlog.Error("Agent scheduler: failed to publish event", "agent", rec.Name, "error", err)
-			continue
+		if ok {
+			queued++
 		}
+	}
 
-		// Update last run timestamp
-		if err := s.store.UpdateLastRun(rec.UserID, rec.Name); err != nil {
-			xlog.Warn("Agent scheduler: failed to update last run", "agent", rec.Name, "error", err)
+	if queued > 0 {
+		if _, err := advisorylock.TryWithLockCtx(ctx, s.db, advisorylock.KeyAgentScheduler, func() error {
+			s.runTriggerEvents()
+			return nil
+		}); err != nil {
+			xlog.Warn("Agent scheduler: failed to fire trigger events", "error", err)
+		}
+	}
+	return queued, nil
+}
+
+// runTriggerEvents fires the queued trigger events, oldest first. Must run
+// under the scheduler lock. Events of agents that were deleted or paused
+// since are dropped.
+func (s *AgentScheduler) runTriggerEvents() {
+	if s.triggers == nil {
+		return
+	}
+	events, err := s.triggers.PendingTriggerEvents(triggerBatchSize)
+	if err != nil {
+		xlog.Error("Agent scheduler: failed to list trigger events", "error", err)
+		return
+	}
+
+	configs := map[string][]AgentConfigRecord{}
+	for _, ev := range events {
+		recs, ok := configs[ev.UserID]
+		if !ok {
+			if recs, err = s.store.ListConfigs(ev.UserID); err != nil {
+				xlog.Error("Agent scheduler: failed to list configs", "user", ev.UserID, "error", err)
+				return
+			}
+			configs[ev.UserID] = recs
+		}
+
+		i := slices.IndexFunc(recs, func(r AgentConfigRecord) bool { return r.Name == ev.AgentName && r.UserID == ev.UserID })
+		var cfg AgentConfig
+		switch {
+		case i < 0 || recs[i].Status != StatusActive:
+			xlog.Info("Dropping trigger event of inactive agent", "agent", ev.AgentName, "user", ev.UserID, "type", ev.Type)
+		case ParseConfigJSON(recs[i].ConfigJSON, &cfg) != nil:
+			xlog.Warn("Dropping trigger event of agent with invalid config", "agent", ev.AgentName, "user", ev.UserID)
+		default:
+			xlog.Info("Firing agent trigger", "agent", ev.AgentName, "user", ev.UserID, "type", ev.Type)
+			if !s.publishRun(recs[i], &cfg, "trigger-"+ev.ID, RoleUser, ev.Message) {
+				// Left pending: retried on the next poll
+				continue
+			}
+		}
+		if err := s.triggers.MarkTriggerEventFired(ev.ID); err != nil {
+			xlog.Warn("Agent scheduler: failed to mark trigger event fired", "id", ev.ID, "error", err)
+		}
+	}
+
+	if now := s.now(); now.Sub(s.lastPrune) >= time.Hour {
+		s.lastPrune = now
+		if err := s.triggers.PruneTriggerEvents(now.Add(-triggerRetention)); err != nil {
+			xlog.Warn("Agent scheduler: failed to prune trigger events", "error", err)
 		}
 	}
 }
@@ -35,6 +35,7 @@ type mockSchedulerStore struct {
 type lastRunUpdate struct {
 	userID string
 	name   string
+	at     time.Time
 }
 
 func (m *mockSchedulerStore) ListConfigs(userID string) ([]AgentConfigRecord, error) {
@@ -51,7 +52,11 @@ func (m *mockSchedulerStore) ListConfigs(userID string) ([]AgentConfigRecord, er
 }
 
 func (m *mockSchedulerStore) UpdateLastRun(userID, name string) error {
-	m.updated = append(m.updated, lastRunUpdate{userID: userID, name: name})
+	return m.UpdateLastRunAt(userID, name, time.Now())
+}
+
+func (m *mockSchedulerStore) UpdateLastRunAt(userID, name string, at time.Time) error {
+	m.updated = append(m.updated, lastRunUpdate{userID: userID, name: name, at: at})
 	return m.lastRunErr
 }
 
@@ -53,7 +53,7 @@ type AgentStore struct {
 // when multiple instances (frontend + workers) start at the same time.
 func NewAgentStore(db *gorm.DB) (*AgentStore, error) {
 	if err := advisorylock.WithLockCtx(context.Background(), db, advisorylock.KeySchemaMigrate, func() error {
-		return db.AutoMigrate(&AgentConfigRecord{}, &AgentObservableRecord{})
+		return db.AutoMigrate(&AgentConfigRecord{}, &AgentObservableRecord{}, &AgentTriggerEventRecord{})
 	}); err != nil {
 		return nil, fmt.Errorf("migrating agent tables: %w", err)
 	}
@@ -146,10 +146,31 @@ func (s *AgentStore) UpdateStatus(userID, name, status string) error {
 
 // UpdateLastRun updates the last autonomous run timestamp.
 func (s *AgentStore) UpdateLastRun(userID, name string) error {
-	now := time.Now()
+	return s.UpdateLastRunAt(userID, name, time.Now())
+}
+
+// UpdateLastRunAt sets the last autonomous run timestamp. Cron schedules
+// record the tick they ran for rather than the current time.
+func (s *AgentStore) UpdateLastRunAt(userID, name string, at time.Time) error {
 	return s.db.Model(&AgentConfigRecord{}).
 		Where("user_id = ? AND name = ?", userID, name).
-		Update("last_run_at", &now).Error
+		Update("last_run_at", &at).Error
+}
+
+// UpdateScheduling replaces the cron schedule and triggers in the config of
+// an agent.
+func (s *AgentStore) UpdateScheduling(userID, name string, sched AgentScheduling) error {
+	rec, err := s.GetConfig(userID, name)
+	if err != nil {
+		return err
+	}
+	configJSON, err := MergeSchedulingJSON([]byte(rec.ConfigJSON), sched)
+	if err != nil {
+		return err
+	}
+	return s.db.Model(&AgentConfigRecord{}).
+		Where("id = ?", rec.ID).
+		Updates(map[string]any{"config": string(configJSON), "updated_at": time.Now()}).Error
 }
 
 // --- Observables ---
@@ -0,0 +1,219 @@
+package agents
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm/clause"
+)
+
+// Trigger type constants for AgentTrigger.Type.
+const (
+	TriggerWebhook      = "webhook"       // POST /api/agents/:name/trigger
+	TriggerKnowledge    = "knowledge"     // a file is uploaded to a knowledge collection
+	TriggerJobCompleted = "job_completed" // an agent job reaches a final status
+)
+
+// AgentTrigger runs the agent when an event happens. Only the events of the
+// agent's owner are matched.
+type AgentTrigger struct {
+	Type string `json:"type"`
+	// Collection limits knowledge triggers to one collection.
+	// Empty matches every collection.
+	Collection string `json:"collection,omitempty"`
+	// TaskID limits job_completed triggers to the jobs of one task.
+	// Empty matches every task.
+	TaskID string `json:"task_id,omitempty"`
+	// Statuses limits job_completed triggers to these final statuses
+	// (completed, failed, cancelled). Defaults to completed.
+	Statuses []string `json:"statuses,omitempty"`
+}
+
+func (t AgentTrigger) validate() error {
+	switch t.Type {
+	case TriggerWebhook, TriggerKnowledge:
+	case TriggerJobCompleted:
+		for _, st := range t.Statuses {
+			switch st {
+			case "completed", "failed", "cancelled":
+			default:
+				return fmt.Errorf("invalid job status %q", st)
+			}
+		}
+	default:
+		return fmt.Errorf("unknown trigger type %q", t.Type)
+	}
+	return nil
+}
+
+// matches reports whether evt fires the trigger of agent.
+func (t AgentTrigger) matches(agent string, evt TriggerEvent) bool {
+	if t.Type != evt.Type {
+		return false
+	}
+	switch t.Type {
+	case TriggerWebhook:
+		return evt.Agent == agent
+	case TriggerKnowledge:
+		return t.Collection == "" || t.Collection == evt.Collection
+	case TriggerJobCompleted:
+		if t.TaskID != "" && t.TaskID != evt.TaskID {
+			return false
+		}
+		if len(t.Statuses) == 0 {
+			return evt.Status == "completed"
+		}
+		return slices.Contains(t.Statuses, evt.Status)
+	}
+	return false
+}
+
+// TriggerEvent is an event that may run agents.
+type TriggerEvent struct {
+	Type   string
+	UserID string
+	// Agent is the target of a webhook.
+	Agent string
+	// Collection is the knowledge collection a file was added to.
+	Collection string
+	// TaskID and Status describe a finished job.
+	TaskID string
+	Status string
+	// Key identifies the event, so replicas observing the same event fire
+	// it once. Events without a key are never deduplicated.
+	Key string
+	// Message is sent to the agent.
+	Message string
+}
+
+// maxTriggerPayload bounds the event payload quoted in trigger messages.
+const maxTriggerPayload = 16 << 10
+
+// WebhookTriggerEvent builds the event of a webhook call on an agent. key is
+// the caller's idempotency key, if any.
+func WebhookTriggerEvent(userID, agent, key string, payload []byte) TriggerEvent {
+	msg := "A webhook was received."
+	if len(payload) > 0 {
+		msg += "\n\nPayload:\n" + truncatePayload(string(payload))
+	}
+	if key != "" {
+		key = "webhook:" + key
+	}
+	return TriggerEvent{Type: TriggerWebhook, UserID: userID, Agent: agent, Key: key, Message: msg}
+}
+
+// KnowledgeTriggerEvent builds the event of a file added to a collection.
+// Uploads are handled by a single replica, so the event has no key.
+func KnowledgeTriggerEvent(userID, collection, entry string) TriggerEvent {
+	return TriggerEvent{
+		Type:       TriggerKnowledge,
+		UserID:     userID,
+		Collection: collection,
+		Message:    fmt.Sprintf("The file %q was added to the knowledge collection %q.", entry, collection),
+	}
+}
+
+// JobTriggerEvent builds the event of a job reaching a final status.
+func JobTriggerEvent(userID, taskID, jobID, status, result, errMsg string) TriggerEvent {
+	msg := fmt.Sprintf("Job %s of task %s finished with status %s.", jobID, taskID, status)
+	if result != "" {
+		msg += "\n\nResult:\n" + truncatePayload(result)
+	}
+	if errMsg != "" {
+		msg += "\n\nError:\n" + truncatePayload(errMsg)
+	}
+	return TriggerEvent{
+		Type:    TriggerJobCompleted,
+		UserID:  userID,
+		TaskID:  taskID,
+		Status:  status,
+		Key:     "job:" + jobID,
+		Message: msg,
+	}
+}
+
+func truncatePayload(s string) string {
+	if len(s) <= maxTriggerPayload {
+		return s
+	}
+	return s[:maxTriggerPayload] + "\n[truncated]"
+}
+
+// ErrNoMatchingTrigger is returned by AgentScheduler.Trigger for a webhook
+// on an agent without a webhook trigger.
+var ErrNoMatchingTrigger = errors.New("agent has no matching trigger")
+
+// AgentTriggerEventRecord is a trigger event queued for one agent until the
+// scheduler leader fires it.
+type AgentTriggerEventRecord struct {
+	ID string `gorm:"primaryKey;size:36" json:"id"`
+	// DedupKey is unique: a replica recording an event already recorded by
+	// another one is a no-op.
+	DedupKey  string     `gorm:"uniqueIndex;size:64" json:"-"`
+	UserID    string     `gorm:"index;size:36" json:"user_id"`
+	AgentName string     `gorm:"size:255" json:"agent_name"`
+	Type      string     `gorm:"size:32" json:"type"`
+	Message   string     `gorm:"type:text" json:"message"`
+	FiredAt   *time.Time `gorm:"index" json:"fired_at,omitempty"`
+	CreatedAt time.Time  `gorm:"index" json:"created_at"`
+}
+
+func (AgentTriggerEventRecord) TableName() string { return "agent_trigger_events" }
+
+// triggerDedupKey derives the dedup key of evt for one agent. Keyless events
+// get a random key.
+func triggerDedupKey(evt TriggerEvent, agent string) string {
+	if evt.Key == "" {
+		return uuid.New().String()
+	}
+	sum := sha256.Sum256([]byte(evt.UserID + "\x00" + agent + "\x00" + evt.Key))
+	return hex.EncodeToString(sum[:])
+}
+
+// TriggerStore is the interface for the trigger queue of the scheduler.
+type TriggerStore interface {
+	// RecordTriggerEvent queues ev. It returns false when an event with
+	// the same dedup key was already queued.
+	RecordTriggerEvent(ev *AgentTriggerEventRecord) (bool, error)
+	PendingTriggerEvents(limit int) ([]AgentTriggerEventRecord, error)
+	MarkTriggerEventFired(id string) error
+	PruneTriggerEvents(before time.Time) error
+}
+
+// --- Trigger events ---
+
+// RecordTriggerEvent queues a trigger event, ignoring duplicates.
+func (s *AgentStore) RecordTriggerEvent(ev *AgentTriggerEventRecord) (bool, error) {
+	if ev.ID == "" {
+		ev.ID = uuid.New().String()
+	}
+	res := s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "dedup_key"}},
+		DoNothing: true,
+	}).Create(ev)
+	return res.RowsAffected > 0, res.Error
+}
+
+// PendingTriggerEvents returns the oldest events not fired yet.
+func (s *AgentStore) PendingTriggerEvents(limit int) ([]AgentTriggerEventRecord, error) {
+	var events []AgentTriggerEventRecord
+	err := s.db.Where("fired_at IS NULL").Order("created_at").Limit(limit).Find(&events).Error
+	return events, err
+}
+
+// MarkTriggerEventFired records that an event was fired.
+func (s *AgentStore) MarkTriggerEventFired(id string) error {
+	now := time.Now()
+	return s.db.Model(&AgentTriggerEventRecord{}).Where("id = ?", id).Update("fired_at", &now).Error
+}
+
+// PruneTriggerEvents deletes the events fired before the given time. Their
+// dedup keys are released: an event replayed after that fires again.
+func (s *AgentStore) PruneTriggerEvents(before time.Time) error {
+	return s.db.Where("fired_at IS NOT NULL AND fired_at < ?", before).Delete(&AgentTriggerEventRecord{}).Error
+}
@@ -0,0 +1,179 @@
+package agents
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/mudler/LocalAI/core/services/advisorylock"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+var _ = Describe("Agent triggers", func() {
+	Describe("MergeSchedulingJSON", func() {
+		It("replaces the scheduling settings and keeps the rest", func() {
+			in := `{"name":"a","model":"m","schedule":"@daily","triggers":[{"type":"webhook"}]}`
+			out, err := MergeSchedulingJSON([]byte(in), AgentScheduling{Schedule: "@hourly", ScheduleTimezone: "UTC"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(out).To(MatchJSON(`{"name":"a","model":"m","schedule":"@hourly","schedule_timezone":"UTC"}`))
+
+			var cfg AgentConfig
+			Expect(ParseConfigJSON(string(out), &cfg)).To(Succeed())
+			Expect(cfg.Name).To(Equal("a"))
+			Expect(cfg.Schedule).To(Equal("@hourly"))
+			Expect(cfg.Triggers).To(BeEmpty())
+		})
+	})
+
+	Describe("ApplySchedulingJSON", func() {
+		current := AgentScheduling{Schedule: "@daily", ScheduleTimezone: "Europe/Rome", Triggers: []AgentTrigger{{Type: TriggerWebhook}}}
+
+		It("keeps the settings the body leaves out", func() {
+			got, err := ApplySchedulingJSON(current, []byte(`{"name":"a","model":"m"}`))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(got).To(Equal(current))
+		})
+
+		It("replaces the settings the body carries", func() {
+			got, err := ApplySchedulingJSON(current, []byte(`{"name":"a","schedule":"@hourly","triggers":[]}`))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(got.Schedule).To(Equal("@hourly"))
+			Expect(got.ScheduleTimezone).To(Equal("Europe/Rome"))
+			Expect(got.Triggers).To(BeEmpty())
+
+			got, err = ApplySchedulingJSON(current, []byte(`{"schedule":null}`))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(got.Schedule).To(BeEmpty())
+			Expect(got.Triggers).To(HaveLen(1))
+		})
+	})
+
+	Describe("AgentTrigger.matches", func() {
+		It("matches webhooks on the target agent only", func() {
+			t := AgentTrigger{Type: TriggerWebhook}
+			Expect(t.matches("a", TriggerEvent{Type: TriggerWebhook, Agent: "a"})).To(BeTrue())
+			Expect(t.matches("a", TriggerEvent{Type: TriggerWebhook, Agent: "b"})).To(BeFalse())
+			Expect(t.matches("a", TriggerEvent{Type: TriggerKnowledge})).To(BeFalse())
+		})
+
+		It("filters knowledge events by collection", func() {
+			evt := KnowledgeTriggerEvent("u", "docs", "report.pdf")
+			Expect(AgentTrigger{Type: TriggerKnowledge}.matches("a", evt)).To(BeTrue())
+			Expect(AgentTrigger{Type: TriggerKnowledge, Collection: "docs"}.matches("a", evt)).To(BeTrue())
+			Expect(AgentTrigger{Type: TriggerKnowledge, Collection: "other"}.matches("a", evt)).To(BeFalse())
+		})
+
+		It("filters job events by task and status", func() {
+			done := JobTriggerEvent("u", "task-1", "job-1", "completed", "ok", "")
+			failed := JobTriggerEvent("u", "task-1", "job-2", "failed", "", "boom")
+			Expect(AgentTrigger{Type: TriggerJobCompleted}.matches("a", done)).To(BeTrue())
+			Expect(AgentTrigger{Type: TriggerJobCompleted}.matches("a", failed)).To(BeFalse())
+			Expect(AgentTrigger{Type: TriggerJobCompleted, Statuses: []string{"failed"}}.matches("a", failed)).To(BeTrue())
+			Expect(AgentTrigger{Type: TriggerJobCompleted, TaskID: "task-2"}.matches("a", done)).To(BeFalse())
+		})
+	})
+
+	// Runs against an in-memory SQLite DB, so it does not require Docker.
+	Describe("AgentScheduler.Trigger", func() {
+		var (
+			store *AgentStore
+			pub   *mockPublisher
+			sched *AgentScheduler
+		)
+
+		saveAgent := func(userID, name, status string, triggers ...AgentTrigger) {
+			cfg := AgentConfig{Name: name, Model: "m"}
+			cfg.Triggers = triggers
+			cfgJSON, err := json.Marshal(cfg)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(store.SaveConfig(&AgentConfigRecord{UserID: userID, Name: name, ConfigJSON: string(cfgJSON), Status: status})).To(Succeed())
+		}
+
+		BeforeEach(func() {
+			dsn := fmt.Sprintf("file:triggers_%d?mode=memory&cache=shared", time.Now().UnixNano())
+			db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+			Expect(err).ToNot(HaveOccurred())
+			store, err = NewAgentStore(db)
+			Expect(err).ToNot(HaveOccurred())
+			pub = &mockPublisher{}
+			sched = NewAgentScheduler(db, pub, store, "agent.execute", WithSchedulerTriggerStore(store))
+		})
+
+		It("fires the matching active agents of the user", func() {
+			saveAgent("u1", "on-done", StatusActive, AgentTrigger{Type: TriggerJobCompleted})
+			saveAgent("u1", "on-failure", StatusActive, AgentTrigger{Type: TriggerJobCompleted, Statuses: []string{"failed"}})
+			saveAgent("u1", "paused", StatusPaused, AgentTrigger{Type: TriggerJobCompleted})
+			saveAgent("u2", "other-user", StatusActive, AgentTrigger{Type: TriggerJobCompleted})
+
+			n, err := sched.Trigger(context.Background(), JobTriggerEvent("u1", "task-1", "job-1", "completed", "all good", ""))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(n).To(Equal(1))
+
+			Expect(pub.calls).To(HaveLen(1))
+			evt := pub.calls[0].data.(AgentChatEvent)
+			Expect(evt.AgentName).To(Equal("on-done"))
+			Expect(evt.UserID).To(Equal("u1"))
+			Expect(evt.Role).To(Equal(RoleUser))
+			Expect(evt.Message).To(ContainSubstring("all good"))
+			Expect(evt.MessageID).To(HavePrefix("trigger-"))
+
+			pending, err := store.PendingTriggerEvents(10)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(pending).To(BeEmpty())
+		})
+
+		It("fires an event observed twice once", func() {
+			saveAgent("u1", "on-done", StatusActive, AgentTrigger{Type: TriggerJobCompleted})
+			evt := JobTriggerEvent("u1", "task-1", "job-1", "completed", "", "")
+
+			n, err := sched.Trigger(context.Background(), evt)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(n).To(Equal(1))
+			n, err = sched.Trigger(context.Background(), evt)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(n).To(Equal(0))
+			Expect(pub.calls).To(HaveLen(1))
+		})
+
+		It("rejects webhooks on agents without a webhook trigger", func() {
+			saveAgent("u1", "no-hook", StatusActive, AgentTrigger{Type: TriggerKnowledge})
+
+			_, err := sched.Trigger(context.Background(), WebhookTriggerEvent("u1", "no-hook", "", nil))
+			Expect(err).To(MatchError(ErrNoMatchingTrigger))
+			Expect(pub.calls).To(BeEmpty())
+		})
+
+		It("leaves events pending while another instance holds the scheduler lock", func() {
+			saveAgent("u1", "hook", StatusActive, AgentTrigger{Type: TriggerWebhook})
+
+			locked := make(chan struct{})
+			release := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				_, err := advisorylock.TryWithLockCtx(context.Background(), store.DB(), advisorylock.KeyAgentScheduler, func() error {
+					close(locked)
+					<-release
+					return nil
+				})
+				Expect(err).ToNot(HaveOccurred())
+			}()
+			<-locked
+
+			n, err := sched.Trigger(context.Background(), WebhookTriggerEvent("u1", "hook", "delivery-1", []byte(`{"x":1}`)))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(n).To(Equal(1))
+			Expect(pub.calls).To(BeEmpty())
+			close(release)
+
+			// The leader fires it on its next poll
+			sched.runTriggerEvents()
+			Expect(pub.calls).To(HaveLen(1))
+			Expect(pub.calls[0].data.(AgentChatEvent).Message).To(ContainSubstring(`{"x":1}`))
+		})
+	})
+})
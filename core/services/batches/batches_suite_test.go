@@ -0,0 +1,13 @@
+package batches
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestBatches(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Batches test suite")
+}
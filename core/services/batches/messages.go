@@ -0,0 +1,350 @@
+package batches
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mudler/LocalAI/core/schema"
+)
+
+// MessageBatchEndpoint is the endpoint every request of an Anthropic Message
+// Batch is replayed against. Batches targeting it are only reachable through
+// the Anthropic API, and the OpenAI batch API does not accept it.
+const MessageBatchEndpoint = "/v1/messages"
+
+// messageBatchWindow is the fixed processing window of a Message Batch.
+const messageBatchWindow = 24 * time.Hour
+
+var customIDPattern = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+// CreateMessageBatch stores the requests of an Anthropic Message Batch as a
+// batch input file and registers a batch over it. Requests are validated up
+// front, so a malformed one rejects the whole batch instead of failing it
+// later.
+func (s *Service) CreateMessageBatch(ctx context.Context, userID string, req schema.AnthropicMessageBatchCreateRequest) (*BatchRecord, error) {
+	if len(req.Requests) == 0 {
+		return nil, fmt.Errorf("%w: requests must not be empty", ErrInvalidRequest)
+	}
+	if len(req.Requests) > MaxBatchLines {
+		return nil, fmt.Errorf("%w: a batch holds at most %d requests, got %d", ErrInvalidRequest, MaxBatchLines, len(req.Requests))
+	}
+
+	var input bytes.Buffer
+	seen := map[string]struct{}{}
+	for i, r := range req.Requests {
+		if err := validateMessageRequest(r, seen); err != nil {
+			return nil, fmt.Errorf("%w: requests[%d]: %s", ErrInvalidRequest, i, err)
+		}
+		if err := writeJSONLine(&input, schema.BatchInputLine{
+			CustomID: r.CustomID,
+			Method:   http.MethodPost,
+			URL:      MessageBatchEndpoint,
+			Body:     r.Params,
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	id := "msgbatch_" + uuid.New().String()
+	in := &FileRecord{UserID: userID, Purpose: schema.FilePurposeBatch, Filename: id + "_requests.jsonl"}
+	if err := s.storeFile(ctx, in, &input); err != nil {
+		return nil, err
+	}
+	b := &BatchRecord{
+		ID:               id,
+		UserID:           userID,
+		Endpoint:         MessageBatchEndpoint,
+		InputFileID:      in.ID,
+		CompletionWindow: messageBatchWindow.String(),
+		Status:           schema.BatchStatusValidating,
+		Total:            len(req.Requests),
+		ExpiresAt:        time.Now().Add(messageBatchWindow),
+	}
+	if err := s.store.CreateBatch(b); err != nil {
+		_ = s.DeleteFile(ctx, userID, in.ID)
+		return nil, err
+	}
+	s.Wake()
+	return b, nil
+}
+
+// validateMessageRequest checks one Message Batch request the way the
+// Messages API would reject it.
+func validateMessageRequest(r schema.AnthropicMessageBatchRequest, seen map[string]struct{}) error {
+	if !customIDPattern.MatchString(r.CustomID) {
+		return fmt.Errorf("custom_id %q must be 1 to 64 letters, digits, underscores or hyphens", r.CustomID)
+	}
+	if _, dup := seen[r.CustomID]; dup {
+		return fmt.Errorf("custom_id %q is not unique", r.CustomID)
+	}
+	seen[r.CustomID] = struct{}{}
+
+	var params schema.AnthropicRequest
+	if err := json.Unmarshal(r.Params, &params); err != nil {
+		return fmt.Errorf("params must be a Messages API request: %v", err)
+	}
+	switch {
+	case params.Model == "":
+		return fmt.Errorf("params.model is required")
+	case params.MaxTokens <= 0:
+		return fmt.Errorf("params.max_tokens is required and must be greater than 0")
+	case len(params.Messages) == 0:
+		return fmt.Errorf("params.messages must not be empty")
+	case params.Stream:
+		return fmt.Errorf("streaming is not supported in batches")
+	}
+	return nil
+}
+
+// GetMessageBatch returns a Message Batch owned by userID.
+func (s *Service) GetMessageBatch(userID, id string) (*BatchRecord, error) {
+	return s.getBatch(userID, id, true)
+}
+
+// ListMessageBatches returns one page of the Message Batches owned by userID
+// and whether more follow.
+func (s *Service) ListMessageBatches(userID, after string, limit int) ([]BatchRecord, bool, error) {
+	return s.listBatches(userID, []string{MessageBatchEndpoint}, after, limit)
+}
+
+// CancelMessageBatch asks the runner to stop a Message Batch. Requests that
+// have not run yet are reported as canceled.
+func (s *Service) CancelMessageBatch(userID, id string) (*BatchRecord, error) {
+	return s.cancelBatch(userID, id, true)
+}
+
+// DeleteMessageBatch removes a Message Batch that has ended, together with
+// its input and result files.
+func (s *Service) DeleteMessageBatch(ctx context.Context, userID, id string) error {
+	b, err := s.GetMessageBatch(userID, id)
+	if err != nil {
+		return err
+	}
+	if slices.Contains(unfinishedStatuses, b.Status) {
+		return fmt.Errorf("%w: batch %q is still processing, cancel it before deleting it", ErrInvalidRequest, id)
+	}
+	for _, fileID := range []string{b.InputFileID, b.OutputFileID, b.ErrorFileID} {
+		if fileID == "" {
+			continue
+		}
+		if err := s.DeleteFile(ctx, b.UserID, fileID); err != nil && !errors.Is(err, ErrNotFound) {
+			return err
+		}
+	}
+	return s.store.DeleteBatch(b.ID)
+}
+
+// WriteMessageBatchResults writes the results of an ended Message Batch to
+// w as JSONL, one line per request in submission order. The output and error
+// files hold their lines in input order too, so the three files are merged
+// in a single pass.
+func (s *Service) WriteMessageBatchResults(ctx context.Context, userID, id string, w io.Writer) error {
+	b, err := s.GetMessageBatch(userID, id)
+	if err != nil {
+		return err
+	}
+	if slices.Contains(unfinishedStatuses, b.Status) {
+		return fmt.Errorf("%w: batch %q is still processing, results are available once it has ended", ErrInvalidRequest, id)
+	}
+
+	in, err := s.openBatchFile(ctx, b.InputFileID)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	outputs, err := s.openBatchFile(ctx, b.OutputFileID)
+	if err != nil {
+		return err
+	}
+	defer outputs.Close()
+	errs, err := s.openBatchFile(ctx, b.ErrorFileID)
+	if err != nil {
+		return err
+	}
+	defer errs.Close()
+
+	// Lines absent from both files never ran: the batch was cancelled
+	// first, or its input was rejected as a whole.
+	missing := schema.AnthropicMessageBatchResult{Type: schema.AnthropicBatchResultCanceled}
+	if b.Status == schema.BatchStatusFailed {
+		missing = schema.AnthropicMessageBatchResult{
+			Type:  schema.AnthropicBatchResultErrored,
+			Error: anthropicError(http.StatusBadRequest, batchFailureMessage(b)),
+		}
+	}
+
+	outCur, errCur := newOutputCursor(outputs), newOutputCursor(errs)
+	return scanLines(in, func(_ int, line []byte) error {
+		var req schema.BatchInputLine
+		if err := json.Unmarshal(line, &req); err != nil {
+			return err
+		}
+		res := schema.AnthropicMessageBatchResultLine{CustomID: req.CustomID, Result: missing}
+		if out := outCur.take(req.CustomID); out != nil {
+			res.Result = schema.AnthropicMessageBatchResult{Type: schema.AnthropicBatchResultSucceeded, Message: out.Response.Body}
+		} else if out := errCur.take(req.CustomID); out != nil {
+			res.Result = messageBatchErrorResult(out)
+		}
+		return writeJSONLine(w, res)
+	})
+}
+
+// openBatchFile opens a batch file by ID; an empty ID reads as an empty file.
+func (s *Service) openBatchFile(ctx context.Context, id string) (io.ReadCloser, error) {
+	if id == "" {
+		return io.NopCloser(bytes.NewReader(nil)), nil
+	}
+	path, err := s.localFile(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(path)
+}
+
+// outputCursor walks an output or error file one line at a time.
+type outputCursor struct {
+	sc   *bufio.Scanner
+	next *schema.BatchOutputLine
+}
+
+func newOutputCursor(r io.Reader) *outputCursor {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), maxLineBytes)
+	return &outputCursor{sc: sc}
+}
+
+// take returns the next line if it belongs to customID and advances past it.
+func (c *outputCursor) take(customID string) *schema.BatchOutputLine {
+	for c.next == nil && c.sc.Scan() {
+		line := bytes.TrimSpace(c.sc.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var out schema.BatchOutputLine
+		if json.Unmarshal(line, &out) == nil {
+			c.next = &out
+		}
+	}
+	if c.next == nil || c.next.CustomID != customID {
+		return nil
+	}
+	out := c.next
+	c.next = nil
+	return out
+}
+
+func messageBatchErrorResult(out *schema.BatchOutputLine) schema.AnthropicMessageBatchResult {
+	switch {
+	case out.Error != nil && out.Error.Code == expiredLineCode:
+		return schema.AnthropicMessageBatchResult{Type: schema.AnthropicBatchResultExpired}
+	case out.Error != nil:
+		return schema.AnthropicMessageBatchResult{Type: schema.AnthropicBatchResultErrored, Error: anthropicError(http.StatusInternalServerError, out.Error.Message)}
+	case out.Response == nil:
+		return schema.AnthropicMessageBatchResult{Type: schema.AnthropicBatchResultErrored, Error: anthropicError(http.StatusInternalServerError, "the request produced no response")}
+	}
+	res := schema.AnthropicMessageBatchResult{Type: schema.AnthropicBatchResultErrored}
+	// The Messages endpoint answers with an Anthropic error already; errors
+	// raised by the shared middleware use the OpenAI shape and are rewrapped.
+	var ae schema.AnthropicErrorResponse
+	if json.Unmarshal(out.Response.Body, &ae) == nil && ae.Type == "error" && ae.Error.Type != "" {
+		res.Error = &ae
+		return res
+	}
+	msg := string(out.Response.Body)
+	var oe schema.ErrorResponse
+	if json.Unmarshal(out.Response.Body, &oe) == nil && oe.Error != nil && oe.Error.Message != "" {
+		msg = oe.Error.Message
+	}
+	res.Error = anthropicError(out.Response.StatusCode, msg)
+	return res
+}
+
+// anthropicError builds an Anthropic error object for an HTTP status.
+func anthropicError(status int, message string) *schema.AnthropicErrorResponse {
+	typ := "api_error"
+	switch status {
+	case http.StatusBadRequest:
+		typ = "invalid_request_error"
+	case http.StatusUnauthorized:
+		typ = "authentication_error"
+	case http.StatusForbidden:
+		typ = "permission_error"
+	case http.StatusNotFound:
+		typ = "not_found_error"
+	case http.StatusRequestEntityTooLarge:
+		typ = "request_too_large"
+	case http.StatusTooManyRequests:
+		typ = "rate_limit_error"
+	case http.StatusServiceUnavailable:
+		typ = "overloaded_error"
+	}
+	return &schema.AnthropicErrorResponse{Type: "error", Error: schema.AnthropicError{Type: typ, Message: message}}
+}
+
+func batchFailureMessage(b *BatchRecord) string {
+	var errs schema.BatchErrors
+	if json.Unmarshal([]byte(b.ErrorsJSON), &errs) == nil && len(errs.Data) > 0 {
+		return errs.Data[0].Message
+	}
+	return "the batch could not be processed"
+}
+
+// MessageBatchToSchema converts a BatchRecord into the Anthropic Message
+// Batch object. ResultsURL is left for the caller, which knows the host the
+// batch was requested through.
+func MessageBatchToSchema(b BatchRecord) schema.AnthropicMessageBatch {
+	out := schema.AnthropicMessageBatch{
+		ID:                b.ID,
+		Type:              "message_batch",
+		ProcessingStatus:  schema.AnthropicBatchInProgress,
+		CreatedAt:         b.CreatedAt.UTC().Format(time.RFC3339),
+		ExpiresAt:         b.ExpiresAt.UTC().Format(time.RFC3339),
+		CancelInitiatedAt: rfc3339Ptr(b.CancellingAt),
+		RequestCounts: schema.AnthropicMessageBatchRequestCounts{
+			Succeeded: b.Completed,
+			Errored:   b.Failed - b.Expired,
+			Expired:   b.Expired,
+		},
+	}
+	remaining := max(b.Total-b.Completed-b.Failed, 0)
+	switch b.Status {
+	case schema.BatchStatusCancelling:
+		out.ProcessingStatus = schema.AnthropicBatchCanceling
+		out.RequestCounts.Processing = remaining
+	case schema.BatchStatusCompleted, schema.BatchStatusCancelled, schema.BatchStatusExpired, schema.BatchStatusFailed:
+		out.ProcessingStatus = schema.AnthropicBatchEnded
+		if b.Status == schema.BatchStatusFailed {
+			out.RequestCounts.Errored += remaining
+		} else {
+			out.RequestCounts.Canceled = remaining
+		}
+		for _, t := range []*time.Time{b.CompletedAt, b.CancelledAt, b.FailedAt, b.ExpiredAt} {
+			if t != nil {
+				out.EndedAt = rfc3339Ptr(t)
+				break
+			}
+		}
+	default:
+		out.RequestCounts.Processing = remaining
+	}
+	return out
+}
+
+func rfc3339Ptr(t *time.Time) *string {
+	if t == nil || t.IsZero() {
+		return nil
+	}
+	v := t.UTC().Format(time.RFC3339)
+	return &v
+}
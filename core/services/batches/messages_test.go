@@ -0,0 +1,160 @@
+//go:build auth
+
+package batches
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/mudler/LocalAI/core/http/auth"
+	"github.com/mudler/LocalAI/core/schema"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func messageRequest(customID, model string) schema.AnthropicMessageBatchRequest {
+	return schema.AnthropicMessageBatchRequest{
+		CustomID: customID,
+		Params:   json.RawMessage(`{"model":"` + model + `","max_tokens":16,"messages":[{"role":"user","content":"hi"}]}`),
+	}
+}
+
+func readResults(svc *Service, userID, id string) []schema.AnthropicMessageBatchResultLine {
+	var buf bytes.Buffer
+	Expect(svc.WriteMessageBatchResults(context.Background(), userID, id, &buf)).To(Succeed())
+	var out []schema.AnthropicMessageBatchResultLine
+	Expect(scanLines(&buf, func(_ int, line []byte) error {
+		var l schema.AnthropicMessageBatchResultLine
+		Expect(json.Unmarshal(line, &l)).To(Succeed())
+		out = append(out, l)
+		return nil
+	})).To(Succeed())
+	return out
+}
+
+var _ = Describe("Message Batches", func() {
+	var (
+		svc     *Service
+		handler *echoHandler
+		ctx     context.Context
+	)
+
+	BeforeEach(func() {
+		db, err := auth.InitDB(":memory:")
+		Expect(err).ToNot(HaveOccurred())
+		store, err := NewStore(db)
+		Expect(err).ToNot(HaveOccurred())
+		svc, err = NewService(store, GinkgoT().TempDir(), nil, 2)
+		Expect(err).ToNot(HaveOccurred())
+		handler = &echoHandler{}
+		ctx = context.Background()
+	})
+
+	create := func(reqs ...schema.AnthropicMessageBatchRequest) *BatchRecord {
+		b, err := svc.CreateMessageBatch(ctx, "alice", schema.AnthropicMessageBatchCreateRequest{Requests: reqs})
+		Expect(err).ToNot(HaveOccurred())
+		return b
+	}
+
+	It("rejects malformed requests before creating anything", func() {
+		for _, reqs := range [][]schema.AnthropicMessageBatchRequest{
+			nil,
+			{messageRequest("has space", "m")},
+			{messageRequest("a", "m"), messageRequest("a", "m")},
+			{{CustomID: "a", Params: json.RawMessage(`{"model":"m","messages":[{"role":"user","content":"hi"}]}`)}},
+			{{CustomID: "a", Params: json.RawMessage(`{"model":"m","max_tokens":1,"stream":true,"messages":[{"role":"user","content":"hi"}]}`)}},
+		} {
+			_, err := svc.CreateMessageBatch(ctx, "alice", schema.AnthropicMessageBatchCreateRequest{Requests: reqs})
+			Expect(err).To(MatchError(ErrInvalidRequest))
+		}
+		files, err := svc.ListFiles("alice", "")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(files).To(BeEmpty())
+	})
+
+	It("runs the requests against /v1/messages and merges the results in order", func() {
+		b := create(messageRequest("a", "m"), messageRequest("b", "fail"), messageRequest("c", "m"))
+		Expect(b.ID).To(HavePrefix("msgbatch_"))
+		api := MessageBatchToSchema(*b)
+		Expect(api.ProcessingStatus).To(Equal(schema.AnthropicBatchInProgress))
+		Expect(api.RequestCounts.Processing).To(Equal(3))
+
+		Expect(svc.process(ctx, handler, b.ID)).To(Succeed())
+
+		b, err := svc.GetMessageBatch("alice", b.ID)
+		Expect(err).ToNot(HaveOccurred())
+		api = MessageBatchToSchema(*b)
+		Expect(api.ProcessingStatus).To(Equal(schema.AnthropicBatchEnded))
+		Expect(api.EndedAt).ToNot(BeNil())
+		Expect(api.RequestCounts).To(Equal(schema.AnthropicMessageBatchRequestCounts{Succeeded: 2, Errored: 1}))
+
+		results := readResults(svc, "alice", b.ID)
+		Expect(results).To(HaveLen(3))
+		Expect([]string{results[0].CustomID, results[1].CustomID, results[2].CustomID}).To(Equal([]string{"a", "b", "c"}))
+		Expect(results[0].Result.Type).To(Equal(schema.AnthropicBatchResultSucceeded))
+		Expect(string(results[0].Result.Message)).To(ContainSubstring(`"max_tokens":16`))
+		Expect(results[1].Result.Type).To(Equal(schema.AnthropicBatchResultErrored))
+		Expect(results[1].Result.Error.Error).To(Equal(schema.AnthropicError{Type: "invalid_request_error", Message: "bad"}))
+	})
+
+	It("keeps Message Batches and OpenAI batches apart", func() {
+		b := create(messageRequest("a", "m"))
+
+		_, err := svc.GetBatch("alice", b.ID)
+		Expect(err).To(MatchError(ErrNotFound))
+		_, err = svc.CancelBatch("alice", b.ID)
+		Expect(err).To(MatchError(ErrNotFound))
+		list, _, err := svc.ListBatches("alice", "", 10)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(list).To(BeEmpty())
+
+		list, _, err = svc.ListMessageBatches("alice", "", 10)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(list).To(HaveLen(1))
+		_, err = svc.GetMessageBatch("bob", b.ID)
+		Expect(err).To(MatchError(ErrNotFound))
+	})
+
+	It("reports requests that never ran as canceled and deletes the batch once ended", func() {
+		b := create(messageRequest("a", "m"), messageRequest("b", "m"))
+		Expect(svc.DeleteMessageBatch(ctx, "alice", b.ID)).To(MatchError(ErrInvalidRequest))
+
+		b, err := svc.CancelMessageBatch("alice", b.ID)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(MessageBatchToSchema(*b).ProcessingStatus).To(Equal(schema.AnthropicBatchCanceling))
+		Expect(svc.process(ctx, handler, b.ID)).To(Succeed())
+
+		b, err = svc.GetMessageBatch("alice", b.ID)
+		Expect(err).ToNot(HaveOccurred())
+		api := MessageBatchToSchema(*b)
+		Expect(api.CancelInitiatedAt).ToNot(BeNil())
+		Expect(api.RequestCounts).To(Equal(schema.AnthropicMessageBatchRequestCounts{Canceled: 2}))
+		for _, r := range readResults(svc, "alice", b.ID) {
+			Expect(r.Result.Type).To(Equal(schema.AnthropicBatchResultCanceled))
+		}
+
+		Expect(svc.DeleteMessageBatch(ctx, "alice", b.ID)).To(Succeed())
+		_, err = svc.GetMessageBatch("alice", b.ID)
+		Expect(err).To(MatchError(ErrNotFound))
+		_, err = svc.GetFile("alice", b.InputFileID)
+		Expect(err).To(MatchError(ErrNotFound))
+	})
+
+	It("counts expired requests apart from errored ones", func() {
+		b := create(messageRequest("a", "m"), messageRequest("b", "m"))
+		b.ExpiresAt = time.Now().Add(-time.Minute)
+		Expect(svc.store.SaveBatch(b)).To(Succeed())
+
+		Expect(svc.process(ctx, handler, b.ID)).To(Succeed())
+
+		b, err := svc.GetMessageBatch("alice", b.ID)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(MessageBatchToSchema(*b).RequestCounts).To(Equal(schema.AnthropicMessageBatchRequestCounts{Expired: 2}))
+		for _, r := range readResults(svc, "alice", b.ID) {
+			Expect(r.Result.Type).To(Equal(schema.AnthropicBatchResultExpired))
+		}
+	})
+})
@@ -0,0 +1,612 @@
+package batches
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/core/services/advisorylock"
+	"github.com/mudler/xlog"
+)
+
+const (
+	outputWorkFile = "output.jsonl"
+	errorWorkFile  = "errors.jsonl"
+
+	// expiredLineCode marks the error lines of requests that never ran
+	// because the completion window elapsed.
+	expiredLineCode = "batch_expired"
+)
+
+// Start runs the batch runner until ctx is cancelled. Every line is executed
+// by handler, which must serve the endpoints in SupportedEndpoints and
+// MessageBatchEndpoint and resolve the owner set with WithOwner.
+//
+// Each batch is processed under a per-batch advisory lock, so with several
+// frontend replicas exactly one of them works on a batch at a time; the
+// others pick it up after a restart or crash.
+func (s *Service) Start(ctx context.Context, handler http.Handler) {
+	go func() {
+		ticker := time.NewTicker(s.pollInterval)
+		defer ticker.Stop()
+		for {
+			s.runPending(ctx, handler)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			case <-s.wake:
+			}
+		}
+	}()
+}
+
+func (s *Service) runPending(ctx context.Context, handler http.Handler) {
+	pending, err := s.store.ListUnfinishedBatches()
+	if err != nil {
+		xlog.Error("Failed to list pending batches", "error", err)
+		return
+	}
+	for _, b := range pending {
+		s.mu.Lock()
+		_, busy := s.running[b.ID]
+		if !busy {
+			s.running[b.ID] = struct{}{}
+		}
+		s.mu.Unlock()
+		if busy {
+			continue
+		}
+		go func(id string) {
+			defer func() {
+				s.mu.Lock()
+				delete(s.running, id)
+				s.mu.Unlock()
+			}()
+			acquired, err := advisorylock.TryWithLockCtx(ctx, s.store.DB(), advisorylock.KeyFromString("batch:"+id), func() error {
+				return s.process(ctx, handler, id)
+			})
+			if err != nil && ctx.Err() == nil {
+				xlog.Error("Batch processing failed", "batch", id, "error", err)
+			} else if !acquired {
+				xlog.Debug("Batch is being processed by another instance", "batch", id)
+			}
+		}(b.ID)
+	}
+}
+
+// process drives a batch from its current state to a terminal one. It is
+// safe to call again after an interruption: every step checkpoints its
+// progress in the database and the working files.
+func (s *Service) process(ctx context.Context, handler http.Handler, id string) error {
+	b, err := s.store.GetBatch("", id)
+	if err != nil {
+		return err
+	}
+	switch b.Status {
+	case schema.BatchStatusValidating:
+		ok, err := s.validate(ctx, b)
+		if err != nil || !ok {
+			return err
+		}
+		return s.execute(ctx, handler, b)
+	case schema.BatchStatusInProgress:
+		return s.execute(ctx, handler, b)
+	case schema.BatchStatusCancelling, schema.BatchStatusFinalizing:
+		return s.finalize(ctx, b)
+	}
+	return nil
+}
+
+// validate checks the whole input file before any line runs. A rejected file
+// fails the batch with one error per offending line.
+func (s *Service) validate(ctx context.Context, b *BatchRecord) (bool, error) {
+	path, err := s.localFile(ctx, b.InputFileID)
+	if err != nil {
+		return false, s.fail(b, schema.BatchError{Code: "invalid_file", Message: "input file is not available: " + err.Error()})
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return false, s.fail(b, schema.BatchError{Code: "invalid_file", Message: "input file is not available: " + err.Error()})
+	}
+	defer f.Close()
+
+	var errs []schema.BatchError
+	seen := map[string]struct{}{}
+	total := 0
+	err = scanLines(f, func(n int, line []byte) error {
+		total = n
+		if _, lineErr := validateLine(b.Endpoint, line, seen); lineErr != nil && len(errs) < maxValidationErrors {
+			lineErr.Line = &n
+			errs = append(errs, *lineErr)
+		}
+		return nil
+	})
+	switch {
+	case err != nil:
+		errs = append(errs, schema.BatchError{Code: "invalid_file", Message: "reading input file: " + err.Error()})
+	case total == 0:
+		errs = append(errs, schema.BatchError{Code: "empty_file", Message: "input file contains no requests"})
+	case total > MaxBatchLines:
+		errs = append(errs, schema.BatchError{Code: "too_many_requests", Message: fmt.Sprintf("input file contains %d requests, the maximum is %d", total, MaxBatchLines)})
+	}
+	if len(errs) > 0 {
+		return false, s.fail(b, errs...)
+	}
+
+	// Conditional transition: a cancel may have landed while the file was
+	// being read, in which case the batch is finalized as cancelled.
+	now := time.Now()
+	ok, err := s.store.Transition(b.ID, schema.BatchStatusValidating, map[string]any{
+		"status":         schema.BatchStatusInProgress,
+		"total":          total,
+		"in_progress_at": &now,
+	})
+	if err != nil {
+		return false, err
+	}
+	fresh, err := s.store.GetBatch("", b.ID)
+	if err != nil {
+		return false, err
+	}
+	*b = *fresh
+	if !ok {
+		return false, s.process(ctx, nil, b.ID)
+	}
+	return true, nil
+}
+
+func (s *Service) fail(b *BatchRecord, errs ...schema.BatchError) error {
+	payload, err := json.Marshal(schema.BatchErrors{Object: "list", Data: errs})
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	b.Status = schema.BatchStatusFailed
+	b.ErrorsJSON = string(payload)
+	b.FailedAt = &now
+	return s.store.SaveBatch(b)
+}
+
+// execute runs the remaining input lines in chunks of s.concurrency and
+// appends their results, in input order, to the working output and error
+// files.
+func (s *Service) execute(ctx context.Context, handler http.Handler, b *BatchRecord) error {
+	work := filepath.Join(workDir(s.dir), b.ID)
+	if err := os.MkdirAll(work, 0750); err != nil {
+		return err
+	}
+	if err := s.restoreCheckpoint(ctx, b, work); err != nil {
+		return err
+	}
+
+	// The working files are the source of truth for progress: the cursor
+	// in the database may lag behind them by one chunk after a crash.
+	outPath, errPath := filepath.Join(work, outputWorkFile), filepath.Join(work, errorWorkFile)
+	completed, err := repairLines(outPath)
+	if err != nil {
+		return err
+	}
+	failed, err := repairLines(errPath)
+	if err != nil {
+		return err
+	}
+	b.Completed, b.Failed, b.Cursor = completed, failed, completed+failed
+
+	outFile, err := os.OpenFile(outPath, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0640)
+	if err != nil {
+		return err
+	}
+	defer outFile.Close()
+	errFile, err := os.OpenFile(errPath, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0640)
+	if err != nil {
+		return err
+	}
+	defer errFile.Close()
+
+	inPath, err := s.localFile(ctx, b.InputFileID)
+	if err != nil {
+		return err
+	}
+	in, err := os.Open(inPath)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	lines := make(chan []byte)
+	scanErr := make(chan error, 1)
+	scanCtx, stopScan := context.WithCancel(ctx)
+	defer stopScan()
+	go func() {
+		defer close(lines)
+		scanErr <- scanLines(in, func(n int, line []byte) error {
+			if n <= b.Cursor {
+				return nil
+			}
+			select {
+			case lines <- append([]byte(nil), line...):
+				return nil
+			case <-scanCtx.Done():
+				return scanCtx.Err()
+			}
+		})
+	}()
+
+	lastCheckpoint := time.Now()
+	expired := false
+	for {
+		if err := ctx.Err(); err != nil {
+			// Shutting down: leave the batch in_progress so it resumes.
+			return err
+		}
+		status, err := s.store.GetBatchStatus(b.ID)
+		if err != nil {
+			return err
+		}
+		if status == schema.BatchStatusCancelling {
+			break
+		}
+
+		chunk := make([][]byte, 0, s.concurrency)
+		for len(chunk) < s.concurrency {
+			line, ok := <-lines
+			if !ok {
+				break
+			}
+			chunk = append(chunk, line)
+		}
+		if len(chunk) == 0 {
+			break
+		}
+
+		expired = expired || time.Now().After(b.ExpiresAt)
+		results := make([]schema.BatchOutputLine, len(chunk))
+		succeeded := make([]bool, len(chunk))
+		if expired {
+			for i, line := range chunk {
+				results[i] = expiredLine(line)
+			}
+		} else {
+			var wg sync.WaitGroup
+			for i, line := range chunk {
+				wg.Add(1)
+				go func(i int, line []byte) {
+					defer wg.Done()
+					results[i], succeeded[i] = s.executeLine(ctx, handler, b.UserID, line)
+				}(i, line)
+			}
+			wg.Wait()
+			if err := ctx.Err(); err != nil {
+				// Results produced while shutting down are unreliable;
+				// the chunk is replayed on resume.
+				return err
+			}
+		}
+
+		for i := range chunk {
+			target := errFile
+			if succeeded[i] {
+				target = outFile
+				b.Completed++
+			} else {
+				b.Failed++
+			}
+			if err := writeJSONLine(target, results[i]); err != nil {
+				return err
+			}
+		}
+		b.Cursor += len(chunk)
+		if err := s.store.UpdateProgress(b.ID, b.Cursor, b.Completed, b.Failed); err != nil {
+			return err
+		}
+		if s.distributed() && time.Since(lastCheckpoint) > s.checkpointInterval {
+			s.saveCheckpoint(ctx, b.ID, work)
+			lastCheckpoint = time.Now()
+		}
+	}
+	stopScan()
+	if err := <-scanErr; err != nil && !errors.Is(err, context.Canceled) {
+		return fmt.Errorf("reading input file: %w", err)
+	}
+
+	now := time.Now()
+	updates := map[string]any{
+		"status":        schema.BatchStatusFinalizing,
+		"finalizing_at": &now,
+	}
+	if expired {
+		// Recorded before finalizing so a resumed finalize still knows
+		// the batch ran out of time.
+		updates["expired_at"] = &now
+	}
+	if _, err := s.store.Transition(b.ID, schema.BatchStatusInProgress, updates); err != nil {
+		return err
+	}
+	fresh, err := s.store.GetBatch("", b.ID)
+	if err != nil {
+		return err
+	}
+	return s.finalize(ctx, fresh)
+}
+
+// executeLine replays one input line against handler on behalf of userID.
+// It reports whether the line belongs in the output file (2xx response) or
+// in the error file.
+func (s *Service) executeLine(ctx context.Context, handler http.Handler, userID string, line []byte) (schema.BatchOutputLine, bool) {
+	var in schema.BatchInputLine
+	_ = json.Unmarshal(line, &in) // validated before execution started
+	out := schema.BatchOutputLine{ID: "batch_req_" + uuid.New().String(), CustomID: in.CustomID}
+
+	req, err := http.NewRequestWithContext(WithOwner(ctx, userID), http.MethodPost, in.URL, bytes.NewReader(in.Body))
+	if err != nil {
+		out.Error = &schema.BatchLineError{Code: "internal_error", Message: err.Error()}
+		return out, false
+	}
+	requestID := "req_" + uuid.New().String()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Request-Id", requestID)
+
+	rec := httptest.NewRecorder()
+	if err := serveRecovered(handler, rec, req); err != nil {
+		out.Error = &schema.BatchLineError{Code: "internal_error", Message: err.Error()}
+		return out, false
+	}
+	body := bytes.TrimSpace(rec.Body.Bytes())
+	if !json.Valid(body) {
+		body, _ = json.Marshal(string(body))
+	}
+	out.Response = &schema.BatchResponseBody{StatusCode: rec.Code, RequestID: requestID, Body: body}
+	return out, rec.Code >= 200 && rec.Code < 300
+}
+
+func serveRecovered(handler http.Handler, w http.ResponseWriter, req *http.Request) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("handler panicked: %v", r)
+		}
+	}()
+	handler.ServeHTTP(w, req)
+	return nil
+}
+
+func expiredLine(line []byte) schema.BatchOutputLine {
+	var in schema.BatchInputLine
+	_ = json.Unmarshal(line, &in)
+	return schema.BatchOutputLine{
+		ID:       "batch_req_" + uuid.New().String(),
+		CustomID: in.CustomID,
+		Error:    &schema.BatchLineError{Code: expiredLineCode, Message: "This request could not be executed before the completion window expired."},
+	}
+}
+
+// finalize publishes the working files as batch_output files and moves the
+// batch to its terminal state. A batch resumed in the finalizing state
+// reuses the file IDs already recorded, so the step is idempotent.
+func (s *Service) finalize(ctx context.Context, b *BatchRecord) error {
+	now := time.Now()
+	if b.FinalizingAt == nil {
+		b.FinalizingAt = &now
+	}
+	work := filepath.Join(workDir(s.dir), b.ID)
+	if err := s.restoreCheckpoint(ctx, b, work); err != nil {
+		return err
+	}
+	if b.ExpiredAt != nil && b.Expired == 0 {
+		n, err := countExpiredLines(filepath.Join(work, errorWorkFile))
+		if err != nil {
+			return err
+		}
+		b.Expired = n
+	}
+	for _, wf := range []struct {
+		name string
+		id   *string
+		kind string
+	}{
+		{outputWorkFile, &b.OutputFileID, "output"},
+		{errorWorkFile, &b.ErrorFileID, "errors"},
+	} {
+		if *wf.id != "" {
+			continue
+		}
+		id, err := s.publishWorkFile(ctx, b, filepath.Join(work, wf.name), wf.kind)
+		if err != nil {
+			return err
+		}
+		if id != "" {
+			*wf.id = id
+			if err := s.store.SaveBatch(b); err != nil {
+				return err
+			}
+		}
+	}
+
+	switch {
+	case b.CancellingAt != nil:
+		b.Status = schema.BatchStatusCancelled
+		b.CancelledAt = &now
+	case b.ExpiredAt != nil:
+		b.Status = schema.BatchStatusExpired
+	default:
+		b.Status = schema.BatchStatusCompleted
+		b.CompletedAt = &now
+	}
+	if err := s.store.SaveBatch(b); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(work); err != nil {
+		xlog.Warn("Failed to remove batch working directory", "batch", b.ID, "error", err)
+	}
+	if s.distributed() {
+		for _, name := range []string{outputWorkFile, errorWorkFile} {
+			_ = s.fileMgr.Delete(ctx, checkpointKey(b.ID, name))
+		}
+	}
+	xlog.Info("Batch finished", "batch", b.ID, "status", b.Status, "completed", b.Completed, "failed", b.Failed)
+	return nil
+}
+
+// countExpiredLines returns the number of expired requests recorded in an
+// error working file.
+func countExpiredLines(path string) (int, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	n := 0
+	err = scanLines(f, func(_ int, line []byte) error {
+		var out schema.BatchOutputLine
+		if json.Unmarshal(line, &out) == nil && out.Error != nil && out.Error.Code == expiredLineCode {
+			n++
+		}
+		return nil
+	})
+	return n, err
+}
+
+// publishWorkFile turns a non-empty working file into a batch_output file
+// owned by the batch owner. It returns an empty ID for a missing or empty
+// file.
+func (s *Service) publishWorkFile(ctx context.Context, b *BatchRecord, path, kind string) (string, error) {
+	fi, err := os.Stat(path)
+	if os.IsNotExist(err) || err == nil && fi.Size() == 0 {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	rec := &FileRecord{
+		UserID:   b.UserID,
+		Purpose:  schema.FilePurposeBatchOutput,
+		Filename: fmt.Sprintf("%s_%s.jsonl", b.ID, kind),
+	}
+	if err := s.storeFile(ctx, rec, f); err != nil {
+		return "", err
+	}
+	return rec.ID, nil
+}
+
+// --- Checkpoints (distributed mode) ---
+
+func checkpointKey(batchID, name string) string {
+	return "batches/work/" + batchID + "/" + name
+}
+
+// saveCheckpoint mirrors the working files to object storage so another
+// replica can resume the batch. Failures only cost re-running lines.
+func (s *Service) saveCheckpoint(ctx context.Context, batchID, work string) {
+	for _, name := range []string{outputWorkFile, errorWorkFile} {
+		path := filepath.Join(work, name)
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+		if err := s.fileMgr.Upload(ctx, checkpointKey(batchID, name), path); err != nil {
+			xlog.Warn("Failed to checkpoint batch", "batch", batchID, "file", name, "error", err)
+		}
+	}
+}
+
+// restoreCheckpoint fetches the working files from object storage when this
+// replica has none, e.g. because the batch started on another instance.
+func (s *Service) restoreCheckpoint(ctx context.Context, b *BatchRecord, work string) error {
+	if !s.distributed() {
+		return nil
+	}
+	if err := os.MkdirAll(work, 0750); err != nil {
+		return err
+	}
+	for _, name := range []string{outputWorkFile, errorWorkFile} {
+		path := filepath.Join(work, name)
+		if _, err := os.Stat(path); err == nil {
+			continue
+		}
+		key := checkpointKey(b.ID, name)
+		if ok, _ := s.fileMgr.Exists(ctx, key); !ok {
+			continue
+		}
+		_ = s.fileMgr.EvictCache(key) // checkpoints change, never trust a cached copy
+		cached, err := s.fileMgr.Download(ctx, key)
+		if err != nil {
+			return err
+		}
+		if err := copyFile(cached, path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// --- Helpers ---
+
+// repairLines drops a trailing partial line left by a crash mid-write and
+// returns the number of complete lines in the file.
+func repairLines(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	if n := len(data); n > 0 && data[n-1] != '\n' {
+		keep := bytes.LastIndexByte(data, '\n') + 1
+		if err := os.Truncate(path, int64(keep)); err != nil {
+			return 0, err
+		}
+		data = data[:keep]
+	}
+	return bytes.Count(data, []byte{'\n'}), nil
+}
+
+func writeJSONLine(w io.Writer, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(out)
+	if _, err := io.Copy(w, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
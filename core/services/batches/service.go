@@ -0,0 +1,466 @@
+package batches
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/core/services/storage"
+	"github.com/mudler/xlog"
+	"gorm.io/gorm"
+)
+
+// SupportedEndpoints lists the endpoints a batch can target. Every line of
+// the input file is replayed against the same route the synchronous API
+// serves, so only request/response endpoints are accepted.
+var SupportedEndpoints = []string{
+	"/v1/chat/completions",
+	"/v1/completions",
+	"/v1/embeddings",
+	"/v1/moderations",
+}
+
+const (
+	// MaxBatchLines caps the number of requests a single input file may hold.
+	MaxBatchLines = 50000
+	// maxLineBytes bounds a single JSONL line (large chat histories with
+	// inline images can be several MB).
+	maxLineBytes = 64 << 20
+	// maxValidationErrors caps the errors reported for a rejected input file.
+	maxValidationErrors = 100
+	// defaultConcurrency is used when the configured concurrency is <= 0.
+	defaultConcurrency = 4
+)
+
+// unfinishedStatuses are the states a runner still has to act on.
+var unfinishedStatuses = []string{
+	schema.BatchStatusValidating,
+	schema.BatchStatusInProgress,
+	schema.BatchStatusFinalizing,
+	schema.BatchStatusCancelling,
+}
+
+var (
+	// ErrNotFound is returned when a file or batch does not exist or belongs
+	// to another user.
+	ErrNotFound = errors.New("not found")
+	// ErrInvalidRequest wraps every user-facing validation error.
+	ErrInvalidRequest = errors.New("invalid request")
+)
+
+type ownerKey struct{}
+
+// WithOwner returns a context carrying the ID of the user a batch line is
+// executed for. The HTTP layer resolves it back into an authenticated user
+// so feature, model-access and quota checks apply to every line.
+func WithOwner(ctx context.Context, userID string) context.Context {
+	return context.WithValue(ctx, ownerKey{}, userID)
+}
+
+// OwnerFromContext returns the user ID set by WithOwner.
+func OwnerFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(ownerKey{}).(string)
+	return id, ok
+}
+
+// Service implements the OpenAI Files and Batch APIs. File contents are
+// kept under dir and mirrored to object storage when one is configured, so
+// another replica can pick up a batch after a restart.
+type Service struct {
+	store       *Store
+	dir         string
+	fileMgr     *storage.FileManager
+	concurrency int
+
+	pollInterval       time.Duration
+	checkpointInterval time.Duration
+	wake               chan struct{}
+
+	mu      sync.Mutex
+	running map[string]struct{}
+}
+
+// NewService creates a batch service storing file contents under dir, which
+// is created on first use. fileMgr may be nil outside of distributed mode.
+func NewService(store *Store, dir string, fileMgr *storage.FileManager, concurrency int) (*Service, error) {
+	if concurrency <= 0 {
+		concurrency = defaultConcurrency
+	}
+	return &Service{
+		store:              store,
+		dir:                dir,
+		fileMgr:            fileMgr,
+		concurrency:        concurrency,
+		pollInterval:       5 * time.Second,
+		checkpointInterval: 30 * time.Second,
+		wake:               make(chan struct{}, 1),
+		running:            map[string]struct{}{},
+	}, nil
+}
+
+// Store returns the underlying persistence layer.
+func (s *Service) Store() *Store { return s.store }
+
+func filesDir(dir string) string { return filepath.Join(dir, "files") }
+func workDir(dir string) string  { return filepath.Join(dir, "work") }
+
+func (s *Service) filePath(id string) string {
+	return filepath.Join(filesDir(s.dir), id+".jsonl")
+}
+
+func (s *Service) distributed() bool {
+	return s.fileMgr != nil && s.fileMgr.IsConfigured()
+}
+
+// --- Files ---
+
+// UploadFile stores a new file for userID. The "batch" and "assistants"
+// purposes are accepted; the content is validated when a batch is created
+// from it or when it is added to a vector store.
+func (s *Service) UploadFile(ctx context.Context, userID, filename, purpose string, r io.Reader) (*FileRecord, error) {
+	if purpose != schema.FilePurposeBatch && purpose != schema.FilePurposeAssistants {
+		return nil, fmt.Errorf("%w: unsupported purpose %q, supported purposes are %q and %q", ErrInvalidRequest, purpose, schema.FilePurposeBatch, schema.FilePurposeAssistants)
+	}
+	rec := &FileRecord{UserID: userID, Purpose: purpose, Filename: filepath.Base(filename)}
+	return rec, s.storeFile(ctx, rec, r)
+}
+
+// storeFile writes r to the file store and creates rec once the content is
+// durable, so a row never points at a missing file.
+func (s *Service) storeFile(ctx context.Context, rec *FileRecord, r io.Reader) error {
+	if rec.ID == "" {
+		rec.ID = newFileID()
+	}
+	if err := os.MkdirAll(filesDir(s.dir), 0750); err != nil {
+		return fmt.Errorf("creating batch file directory: %w", err)
+	}
+	path := s.filePath(rec.ID)
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("creating file: %w", err)
+	}
+	n, err := io.Copy(f, r)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(path)
+		return fmt.Errorf("writing file: %w", err)
+	}
+	rec.Bytes = n
+	if s.distributed() {
+		if err := s.fileMgr.Upload(ctx, storage.BatchFileKey(rec.ID), path); err != nil {
+			os.Remove(path)
+			return err
+		}
+	}
+	if err := s.store.CreateFile(rec); err != nil {
+		os.Remove(path)
+		return err
+	}
+	return nil
+}
+
+// GetFile returns the metadata of a file owned by userID.
+func (s *Service) GetFile(userID, id string) (*FileRecord, error) {
+	f, err := s.store.GetFile(userID, id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	return f, err
+}
+
+// ListFiles returns the files owned by userID, optionally filtered by purpose.
+func (s *Service) ListFiles(userID, purpose string) ([]FileRecord, error) {
+	return s.store.ListFiles(userID, purpose)
+}
+
+// OpenFile returns a reader over the content of a file owned by userID,
+// fetching it from object storage when this replica has no local copy.
+func (s *Service) OpenFile(ctx context.Context, userID, id string) (*FileRecord, io.ReadCloser, error) {
+	rec, err := s.GetFile(userID, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	path, err := s.localFile(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return rec, f, nil
+}
+
+func (s *Service) localFile(ctx context.Context, id string) (string, error) {
+	path := s.filePath(id)
+	if _, err := os.Stat(path); err == nil || !s.distributed() {
+		return path, nil
+	}
+	return s.fileMgr.Download(ctx, storage.BatchFileKey(id))
+}
+
+// DeleteFile removes a file owned by userID.
+func (s *Service) DeleteFile(ctx context.Context, userID, id string) error {
+	if _, err := s.GetFile(userID, id); err != nil {
+		return err
+	}
+	if err := s.store.DeleteFile(id); err != nil {
+		return err
+	}
+	if err := os.Remove(s.filePath(id)); err != nil && !os.IsNotExist(err) {
+		xlog.Warn("Failed to remove batch file", "id", id, "error", err)
+	}
+	if s.distributed() {
+		if err := s.fileMgr.Delete(ctx, storage.BatchFileKey(id)); err != nil {
+			xlog.Warn("Failed to remove batch file from object storage", "id", id, "error", err)
+		}
+	}
+	return nil
+}
+
+// --- Batches ---
+
+// CreateBatch registers a batch in the validating state and wakes the runner.
+func (s *Service) CreateBatch(userID string, req schema.BatchCreateRequest) (*BatchRecord, error) {
+	if !isSupportedEndpoint(req.Endpoint) {
+		return nil, fmt.Errorf("%w: unsupported endpoint %q, supported endpoints are %s", ErrInvalidRequest, req.Endpoint, strings.Join(SupportedEndpoints, ", "))
+	}
+	window, err := time.ParseDuration(req.CompletionWindow)
+	if err != nil || window <= 0 {
+		return nil, fmt.Errorf("%w: invalid completion_window %q, expected a duration such as \"24h\"", ErrInvalidRequest, req.CompletionWindow)
+	}
+	in, err := s.GetFile(userID, req.InputFileID)
+	if errors.Is(err, ErrNotFound) {
+		return nil, fmt.Errorf("%w: input file %q not found", ErrInvalidRequest, req.InputFileID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if in.Purpose != schema.FilePurposeBatch {
+		return nil, fmt.Errorf("%w: input file %q must have purpose %q", ErrInvalidRequest, in.ID, schema.FilePurposeBatch)
+	}
+
+	var metadata string
+	if len(req.Metadata) > 0 {
+		b, err := json.Marshal(req.Metadata)
+		if err != nil {
+			return nil, err
+		}
+		metadata = string(b)
+	}
+	b := &BatchRecord{
+		UserID:           userID,
+		Endpoint:         req.Endpoint,
+		InputFileID:      in.ID,
+		CompletionWindow: req.CompletionWindow,
+		Status:           schema.BatchStatusValidating,
+		MetadataJSON:     metadata,
+		ExpiresAt:        time.Now().Add(window),
+	}
+	if err := s.store.CreateBatch(b); err != nil {
+		return nil, err
+	}
+	s.Wake()
+	return b, nil
+}
+
+// GetBatch returns a batch owned by userID. Message Batches are only visible
+// through the Anthropic API.
+func (s *Service) GetBatch(userID, id string) (*BatchRecord, error) {
+	return s.getBatch(userID, id, false)
+}
+
+func (s *Service) getBatch(userID, id string, messages bool) (*BatchRecord, error) {
+	b, err := s.store.GetBatch(userID, id)
+	if errors.Is(err, gorm.ErrRecordNotFound) || err == nil && (b.Endpoint == MessageBatchEndpoint) != messages {
+		return nil, ErrNotFound
+	}
+	return b, err
+}
+
+// ListBatches returns one page of the batches owned by userID and whether
+// more follow.
+func (s *Service) ListBatches(userID, after string, limit int) ([]BatchRecord, bool, error) {
+	return s.listBatches(userID, SupportedEndpoints, after, limit)
+}
+
+func (s *Service) listBatches(userID string, endpoints []string, after string, limit int) ([]BatchRecord, bool, error) {
+	batches, err := s.store.ListBatches(userID, endpoints, after, limit+1)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, false, ErrNotFound
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if len(batches) > limit {
+		return batches[:limit], true, nil
+	}
+	return batches, false, nil
+}
+
+// CancelBatch asks the runner to stop a batch. Lines already executed are
+// kept and exposed through the output and error files.
+func (s *Service) CancelBatch(userID, id string) (*BatchRecord, error) {
+	return s.cancelBatch(userID, id, false)
+}
+
+func (s *Service) cancelBatch(userID, id string, messages bool) (*BatchRecord, error) {
+	b, err := s.getBatch(userID, id, messages)
+	if err != nil {
+		return nil, err
+	}
+	ok, err := s.store.RequestCancel(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if !ok && b.Status != schema.BatchStatusCancelling && b.Status != schema.BatchStatusCancelled {
+		return nil, fmt.Errorf("%w: cannot cancel a batch in status %q", ErrInvalidRequest, b.Status)
+	}
+	s.Wake()
+	return s.getBatch(userID, id, messages)
+}
+
+// Wake makes the runner look for pending work without waiting for the next
+// poll.
+func (s *Service) Wake() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func isSupportedEndpoint(endpoint string) bool {
+	for _, e := range SupportedEndpoints {
+		if e == endpoint {
+			return true
+		}
+	}
+	return false
+}
+
+// --- Schema conversion ---
+
+// FileToSchema converts a FileRecord into the OpenAI file object.
+func FileToSchema(f FileRecord) schema.OpenAIFile {
+	return schema.OpenAIFile{
+		ID:        f.ID,
+		Object:    "file",
+		Bytes:     f.Bytes,
+		CreatedAt: f.CreatedAt.Unix(),
+		Filename:  f.Filename,
+		Purpose:   f.Purpose,
+	}
+}
+
+// BatchToSchema converts a BatchRecord into the OpenAI batch object.
+func BatchToSchema(b BatchRecord) schema.Batch {
+	out := schema.Batch{
+		ID:               b.ID,
+		Object:           "batch",
+		Endpoint:         b.Endpoint,
+		InputFileID:      b.InputFileID,
+		CompletionWindow: b.CompletionWindow,
+		Status:           b.Status,
+		CreatedAt:        b.CreatedAt.Unix(),
+		ExpiresAt:        unixPtr(&b.ExpiresAt),
+		InProgressAt:     unixPtr(b.InProgressAt),
+		FinalizingAt:     unixPtr(b.FinalizingAt),
+		CompletedAt:      unixPtr(b.CompletedAt),
+		FailedAt:         unixPtr(b.FailedAt),
+		ExpiredAt:        unixPtr(b.ExpiredAt),
+		CancellingAt:     unixPtr(b.CancellingAt),
+		CancelledAt:      unixPtr(b.CancelledAt),
+		RequestCounts: schema.BatchRequestCounts{
+			Total:     b.Total,
+			Completed: b.Completed,
+			Failed:    b.Failed,
+		},
+	}
+	if b.OutputFileID != "" {
+		out.OutputFileID = &b.OutputFileID
+	}
+	if b.ErrorFileID != "" {
+		out.ErrorFileID = &b.ErrorFileID
+	}
+	if b.ErrorsJSON != "" {
+		var errs schema.BatchErrors
+		if json.Unmarshal([]byte(b.ErrorsJSON), &errs) == nil {
+			out.Errors = &errs
+		}
+	}
+	if b.MetadataJSON != "" {
+		_ = json.Unmarshal([]byte(b.MetadataJSON), &out.Metadata)
+	}
+	return out
+}
+
+func unixPtr(t *time.Time) *int64 {
+	if t == nil || t.IsZero() {
+		return nil
+	}
+	v := t.Unix()
+	return &v
+}
+
+// --- Input parsing ---
+
+// scanLines calls fn for every non-blank line of r with its 1-based line
+// number. Blank lines are skipped so validation and execution agree on the
+// request numbering.
+func scanLines(r io.Reader, fn func(n int, line []byte) error) error {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), maxLineBytes)
+	n := 0
+	for sc.Scan() {
+		line := bytes.TrimSpace(sc.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		n++
+		if err := fn(n, line); err != nil {
+			return err
+		}
+	}
+	return sc.Err()
+}
+
+// validateLine checks a single input line against the batch endpoint.
+func validateLine(endpoint string, line []byte, seen map[string]struct{}) (*schema.BatchInputLine, *schema.BatchError) {
+	var in schema.BatchInputLine
+	if err := json.Unmarshal(line, &in); err != nil {
+		return nil, &schema.BatchError{Code: "invalid_json_line", Message: "line is not valid JSON: " + err.Error()}
+	}
+	if in.CustomID == "" {
+		return nil, &schema.BatchError{Code: "missing_required_parameter", Message: "custom_id is required", Param: "custom_id"}
+	}
+	if _, dup := seen[in.CustomID]; dup {
+		return nil, &schema.BatchError{Code: "duplicate_custom_id", Message: fmt.Sprintf("custom_id %q is not unique", in.CustomID), Param: "custom_id"}
+	}
+	seen[in.CustomID] = struct{}{}
+	if !strings.EqualFold(in.Method, "POST") {
+		return nil, &schema.BatchError{Code: "invalid_method", Message: "method must be POST", Param: "method"}
+	}
+	if in.URL != endpoint {
+		return nil, &schema.BatchError{Code: "mismatched_endpoint", Message: fmt.Sprintf("url %q does not match the batch endpoint %q", in.URL, endpoint), Param: "url"}
+	}
+	var body map[string]json.RawMessage
+	if err := json.Unmarshal(in.Body, &body); err != nil || body == nil {
+		return nil, &schema.BatchError{Code: "invalid_request", Message: "body must be a JSON object", Param: "body"}
+	}
+	if stream, ok := body["stream"]; ok && string(bytes.TrimSpace(stream)) == "true" {
+		return nil, &schema.BatchError{Code: "invalid_request", Message: "streaming is not supported in batches", Param: "body.stream"}
+	}
+	return &in, nil
+}
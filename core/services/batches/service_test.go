@@ -0,0 +1,280 @@
+//go:build auth
+
+package batches
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/mudler/LocalAI/core/http/auth"
+	"github.com/mudler/LocalAI/core/schema"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// echoHandler answers every request with its body, or with a 400 when the
+// body asks for it, and records the batch owner it saw.
+type echoHandler struct {
+	calls atomic.Int32
+	owner atomic.Value
+}
+
+func (h *echoHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.calls.Add(1)
+	if owner, ok := OwnerFromContext(r.Context()); ok {
+		h.owner.Store(owner)
+	}
+	body, _ := io.ReadAll(r.Body)
+	w.Header().Set("Content-Type", "application/json")
+	if strings.Contains(string(body), `"fail"`) {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"error":{"message":"bad"}}`))
+		return
+	}
+	_, _ = w.Write(body)
+}
+
+func batchLine(customID, url, body string) string {
+	return `{"custom_id":"` + customID + `","method":"POST","url":"` + url + `","body":` + body + "}\n"
+}
+
+func readOutputLines(svc *Service, userID, fileID string) []schema.BatchOutputLine {
+	_, r, err := svc.OpenFile(context.Background(), userID, fileID)
+	Expect(err).ToNot(HaveOccurred())
+	defer r.Close()
+	var out []schema.BatchOutputLine
+	Expect(scanLines(r, func(_ int, line []byte) error {
+		var l schema.BatchOutputLine
+		Expect(json.Unmarshal(line, &l)).To(Succeed())
+		out = append(out, l)
+		return nil
+	})).To(Succeed())
+	return out
+}
+
+var _ = Describe("Batch service", func() {
+	var (
+		svc     *Service
+		handler *echoHandler
+		ctx     context.Context
+	)
+
+	BeforeEach(func() {
+		db, err := auth.InitDB(":memory:")
+		Expect(err).ToNot(HaveOccurred())
+		store, err := NewStore(db)
+		Expect(err).ToNot(HaveOccurred())
+		svc, err = NewService(store, GinkgoT().TempDir(), nil, 2)
+		Expect(err).ToNot(HaveOccurred())
+		handler = &echoHandler{}
+		ctx = context.Background()
+	})
+
+	upload := func(content string) *FileRecord {
+		f, err := svc.UploadFile(ctx, "alice", "input.jsonl", schema.FilePurposeBatch, strings.NewReader(content))
+		Expect(err).ToNot(HaveOccurred())
+		return f
+	}
+
+	create := func(fileID string) *BatchRecord {
+		b, err := svc.CreateBatch("alice", schema.BatchCreateRequest{
+			InputFileID:      fileID,
+			Endpoint:         "/v1/chat/completions",
+			CompletionWindow: "24h",
+			Metadata:         map[string]string{"project": "test"},
+		})
+		Expect(err).ToNot(HaveOccurred())
+		return b
+	}
+
+	It("rejects unsupported purposes, endpoints and completion windows", func() {
+		_, err := svc.UploadFile(ctx, "alice", "x.jsonl", "fine-tune", strings.NewReader("{}"))
+		Expect(err).To(MatchError(ErrInvalidRequest))
+
+		f := upload(batchLine("a", "/v1/chat/completions", `{"model":"m"}`))
+		_, err = svc.CreateBatch("alice", schema.BatchCreateRequest{InputFileID: f.ID, Endpoint: "/v1/audio/speech", CompletionWindow: "24h"})
+		Expect(err).To(MatchError(ErrInvalidRequest))
+		_, err = svc.CreateBatch("alice", schema.BatchCreateRequest{InputFileID: f.ID, Endpoint: "/v1/chat/completions", CompletionWindow: "soon"})
+		Expect(err).To(MatchError(ErrInvalidRequest))
+		_, err = svc.CreateBatch("bob", schema.BatchCreateRequest{InputFileID: f.ID, Endpoint: "/v1/chat/completions", CompletionWindow: "24h"})
+		Expect(err).To(MatchError(ErrInvalidRequest), "files are scoped to their owner")
+	})
+
+	It("runs every line and splits results into output and error files", func() {
+		f := upload(batchLine("a", "/v1/chat/completions", `{"model":"m","n":1}`) +
+			"\n" +
+			batchLine("b", "/v1/chat/completions", `{"model":"fail"}`) +
+			batchLine("c", "/v1/chat/completions", `{"model":"m","n":3}`))
+		b := create(f.ID)
+		Expect(b.Status).To(Equal(schema.BatchStatusValidating))
+
+		Expect(svc.process(ctx, handler, b.ID)).To(Succeed())
+
+		b, err := svc.GetBatch("alice", b.ID)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(b.Status).To(Equal(schema.BatchStatusCompleted))
+		Expect(b.Total).To(Equal(3))
+		Expect(b.Completed).To(Equal(2))
+		Expect(b.Failed).To(Equal(1))
+		Expect(handler.calls.Load()).To(Equal(int32(3)))
+		Expect(handler.owner.Load()).To(Equal("alice"))
+
+		out := readOutputLines(svc, "alice", b.OutputFileID)
+		Expect(out).To(HaveLen(2))
+		Expect(out[0].CustomID).To(Equal("a"))
+		Expect(out[1].CustomID).To(Equal("c"))
+		Expect(out[0].Response.StatusCode).To(Equal(http.StatusOK))
+		Expect(string(out[0].Response.Body)).To(MatchJSON(`{"model":"m","n":1}`))
+
+		errs := readOutputLines(svc, "alice", b.ErrorFileID)
+		Expect(errs).To(HaveLen(1))
+		Expect(errs[0].CustomID).To(Equal("b"))
+		Expect(errs[0].Response.StatusCode).To(Equal(http.StatusBadRequest))
+
+		api := BatchToSchema(*b)
+		Expect(*api.OutputFileID).To(Equal(b.OutputFileID))
+		Expect(api.Metadata).To(HaveKeyWithValue("project", "test"))
+		Expect(api.CompletedAt).ToNot(BeNil())
+
+		outFile, err := svc.GetFile("alice", b.OutputFileID)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(outFile.Purpose).To(Equal(schema.FilePurposeBatchOutput))
+	})
+
+	It("fails the batch with per-line errors on invalid input", func() {
+		f := upload(batchLine("a", "/v1/chat/completions", `{"model":"m"}`) +
+			batchLine("a", "/v1/chat/completions", `{"model":"m"}`) +
+			batchLine("b", "/v1/embeddings", `{"model":"m"}`) +
+			batchLine("c", "/v1/chat/completions", `{"model":"m","stream":true}`) +
+			"not json\n")
+		b := create(f.ID)
+
+		Expect(svc.process(ctx, handler, b.ID)).To(Succeed())
+
+		b, err := svc.GetBatch("alice", b.ID)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(b.Status).To(Equal(schema.BatchStatusFailed))
+		api := BatchToSchema(*b)
+		Expect(api.Errors).ToNot(BeNil())
+		codes := []string{}
+		for _, e := range api.Errors.Data {
+			codes = append(codes, e.Code)
+		}
+		Expect(codes).To(Equal([]string{"duplicate_custom_id", "mismatched_endpoint", "invalid_request", "invalid_json_line"}))
+		Expect(*api.Errors.Data[0].Line).To(Equal(2))
+		Expect(handler.calls.Load()).To(BeZero())
+	})
+
+	It("cancels a batch before it runs", func() {
+		f := upload(batchLine("a", "/v1/chat/completions", `{"model":"m"}`))
+		b := create(f.ID)
+
+		b, err := svc.CancelBatch("alice", b.ID)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(b.Status).To(Equal(schema.BatchStatusCancelling))
+
+		Expect(svc.process(ctx, handler, b.ID)).To(Succeed())
+		b, err = svc.GetBatch("alice", b.ID)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(b.Status).To(Equal(schema.BatchStatusCancelled))
+		Expect(b.CancelledAt).ToNot(BeNil())
+		Expect(handler.calls.Load()).To(BeZero())
+
+		_, err = svc.CancelBatch("alice", b.ID)
+		Expect(err).ToNot(HaveOccurred(), "cancelling twice is idempotent")
+	})
+
+	It("resumes an interrupted batch from its working files", func() {
+		f := upload(batchLine("a", "/v1/chat/completions", `{"model":"m"}`) +
+			batchLine("b", "/v1/chat/completions", `{"model":"m"}`) +
+			batchLine("c", "/v1/chat/completions", `{"model":"m"}`))
+		b := create(f.ID)
+		ok, err := svc.validate(ctx, b)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(ok).To(BeTrue())
+
+		// Simulate a crash after the first line was written and while the
+		// second one was half-flushed.
+		work := filepath.Join(workDir(svc.dir), b.ID)
+		Expect(os.MkdirAll(work, 0750)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(work, outputWorkFile),
+			[]byte(`{"id":"batch_req_1","custom_id":"a","response":{"status_code":200,"request_id":"r","body":{}},"error":null}`+"\n"+`{"id":"batch_req_2","cus`), 0640)).To(Succeed())
+
+		Expect(svc.process(ctx, handler, b.ID)).To(Succeed())
+
+		b, err = svc.GetBatch("alice", b.ID)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(b.Status).To(Equal(schema.BatchStatusCompleted))
+		Expect(handler.calls.Load()).To(Equal(int32(2)))
+		out := readOutputLines(svc, "alice", b.OutputFileID)
+		Expect(out).To(HaveLen(3))
+		Expect([]string{out[0].CustomID, out[1].CustomID, out[2].CustomID}).To(Equal([]string{"a", "b", "c"}))
+		_, err = os.Stat(work)
+		Expect(os.IsNotExist(err)).To(BeTrue())
+	})
+
+	It("expires lines that did not run within the completion window", func() {
+		f := upload(batchLine("a", "/v1/chat/completions", `{"model":"m"}`) +
+			batchLine("b", "/v1/chat/completions", `{"model":"m"}`))
+		b := create(f.ID)
+		b.ExpiresAt = time.Now().Add(-time.Minute)
+		Expect(svc.store.SaveBatch(b)).To(Succeed())
+
+		Expect(svc.process(ctx, handler, b.ID)).To(Succeed())
+
+		b, err := svc.GetBatch("alice", b.ID)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(b.Status).To(Equal(schema.BatchStatusExpired))
+		Expect(b.OutputFileID).To(BeEmpty())
+		errs := readOutputLines(svc, "alice", b.ErrorFileID)
+		Expect(errs).To(HaveLen(2))
+		Expect(errs[0].Error.Code).To(Equal("batch_expired"))
+		Expect(handler.calls.Load()).To(BeZero())
+	})
+
+	It("paginates batches newest first", func() {
+		f := upload(batchLine("a", "/v1/chat/completions", `{"model":"m"}`))
+		var ids []string
+		for range 3 {
+			ids = append(ids, create(f.ID).ID)
+			time.Sleep(5 * time.Millisecond)
+		}
+		page, more, err := svc.ListBatches("alice", "", 2)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(more).To(BeTrue())
+		Expect([]string{page[0].ID, page[1].ID}).To(Equal([]string{ids[2], ids[1]}))
+
+		page, more, err = svc.ListBatches("alice", page[1].ID, 2)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(more).To(BeFalse())
+		Expect(page).To(HaveLen(1))
+		Expect(page[0].ID).To(Equal(ids[0]))
+
+		page, _, err = svc.ListBatches("bob", "", 10)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(page).To(BeEmpty())
+	})
+
+	It("picks up pending batches from the runner loop", func() {
+		svc.pollInterval = 20 * time.Millisecond
+		runCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		svc.Start(runCtx, handler)
+
+		f := upload(batchLine("a", "/v1/chat/completions", `{"model":"m"}`))
+		b := create(f.ID)
+		Eventually(func() string {
+			got, err := svc.GetBatch("alice", b.ID)
+			Expect(err).ToNot(HaveOccurred())
+			return got.Status
+		}, "5s", "20ms").Should(Equal(schema.BatchStatusCompleted))
+	})
+})
@@ -0,0 +1,254 @@
+package batches
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/core/services/advisorylock"
+	"gorm.io/gorm"
+)
+
+// FileRecord is the GORM model for files uploaded through /v1/files and for
+// the output/error files produced by batches. The content lives on disk (and
+// in object storage in distributed mode); the row only carries metadata.
+type FileRecord struct {
+	ID        string    `gorm:"primaryKey;size:64" json:"id"`
+	UserID    string    `gorm:"index;size:36" json:"user_id"`
+	Purpose   string    `gorm:"index;size:32" json:"purpose"`
+	Filename  string    `gorm:"size:255" json:"filename"`
+	Bytes     int64     `json:"bytes"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (FileRecord) TableName() string { return "batch_files" }
+
+// BatchRecord is the GORM model for a batch run. Cursor is the number of
+// input lines already processed and is what lets a restarted instance resume
+// the batch instead of starting over. Expired is the part of Failed that
+// never ran because the completion window elapsed.
+type BatchRecord struct {
+	ID               string     `gorm:"primaryKey;size:64" json:"id"`
+	UserID           string     `gorm:"index;size:36" json:"user_id"`
+	Endpoint         string     `gorm:"size:64" json:"endpoint"`
+	InputFileID      string     `gorm:"size:64" json:"input_file_id"`
+	CompletionWindow string     `gorm:"size:16" json:"completion_window"`
+	Status           string     `gorm:"index;size:32" json:"status"`
+	OutputFileID     string     `gorm:"size:64" json:"output_file_id,omitempty"`
+	ErrorFileID      string     `gorm:"size:64" json:"error_file_id,omitempty"`
+	ErrorsJSON       string     `gorm:"column:errors;type:text" json:"-"`
+	MetadataJSON     string     `gorm:"column:metadata;type:text" json:"-"`
+	Total            int        `json:"total"`
+	Completed        int        `json:"completed"`
+	Failed           int        `json:"failed"`
+	Expired          int        `json:"expired"`
+	Cursor           int        `json:"cursor"`
+	ExpiresAt        time.Time  `gorm:"index" json:"expires_at"`
+	InProgressAt     *time.Time `json:"in_progress_at,omitempty"`
+	FinalizingAt     *time.Time `json:"finalizing_at,omitempty"`
+	CompletedAt      *time.Time `json:"completed_at,omitempty"`
+	FailedAt         *time.Time `json:"failed_at,omitempty"`
+	ExpiredAt        *time.Time `json:"expired_at,omitempty"`
+	CancellingAt     *time.Time `json:"cancelling_at,omitempty"`
+	CancelledAt      *time.Time `json:"cancelled_at,omitempty"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+}
+
+func (BatchRecord) TableName() string { return "batches" }
+
+// Store provides database persistence for batch files and batch runs.
+type Store struct {
+	db *gorm.DB
+}
+
+// NewStore creates a new Store and auto-migrates the schema.
+// Uses an advisory lock to prevent concurrent migration races when
+// multiple frontend replicas start at the same time.
+func NewStore(db *gorm.DB) (*Store, error) {
+	if err := advisorylock.WithLockCtx(context.Background(), db, advisorylock.KeySchemaMigrate, func() error {
+		return db.AutoMigrate(&FileRecord{}, &BatchRecord{})
+	}); err != nil {
+		return nil, fmt.Errorf("migrating batch tables: %w", err)
+	}
+	return &Store{db: db}, nil
+}
+
+// DB returns the underlying database handle.
+func (s *Store) DB() *gorm.DB { return s.db }
+
+// --- Files ---
+
+func newFileID() string { return "file-" + uuid.New().String() }
+
+// CreateFile stores a new file record.
+func (s *Store) CreateFile(f *FileRecord) error {
+	if f.ID == "" {
+		f.ID = newFileID()
+	}
+	if f.CreatedAt.IsZero() {
+		f.CreatedAt = time.Now()
+	}
+	return s.db.Create(f).Error
+}
+
+// GetFile retrieves a file by ID. When userID is non-empty the file must
+// belong to that user.
+func (s *Store) GetFile(userID, id string) (*FileRecord, error) {
+	var f FileRecord
+	q := s.db.Where("id = ?", id)
+	if userID != "" {
+		q = q.Where("user_id = ?", userID)
+	}
+	if err := q.First(&f).Error; err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+// ListFiles returns the files of a user, newest first, optionally filtered by purpose.
+func (s *Store) ListFiles(userID, purpose string) ([]FileRecord, error) {
+	var files []FileRecord
+	q := s.db.Order("created_at DESC")
+	if userID != "" {
+		q = q.Where("user_id = ?", userID)
+	}
+	if purpose != "" {
+		q = q.Where("purpose = ?", purpose)
+	}
+	if err := q.Find(&files).Error; err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// DeleteFile removes a file record.
+func (s *Store) DeleteFile(id string) error {
+	return s.db.Where("id = ?", id).Delete(&FileRecord{}).Error
+}
+
+// --- Batches ---
+
+// CreateBatch stores a new batch.
+func (s *Store) CreateBatch(b *BatchRecord) error {
+	if b.ID == "" {
+		b.ID = "batch_" + uuid.New().String()
+	}
+	b.CreatedAt = time.Now()
+	b.UpdatedAt = b.CreatedAt
+	return s.db.Create(b).Error
+}
+
+// GetBatch retrieves a batch by ID. When userID is non-empty the batch must
+// belong to that user.
+func (s *Store) GetBatch(userID, id string) (*BatchRecord, error) {
+	var b BatchRecord
+	q := s.db.Where("id = ?", id)
+	if userID != "" {
+		q = q.Where("user_id = ?", userID)
+	}
+	if err := q.First(&b).Error; err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+// ListBatches returns batches of a user targeting one of endpoints, newest
+// first. after is the ID of the last batch of the previous page (cursor
+// pagination); limit <= 0 means no limit.
+func (s *Store) ListBatches(userID string, endpoints []string, after string, limit int) ([]BatchRecord, error) {
+	var batches []BatchRecord
+	q := s.db.Order("created_at DESC").Order("id DESC").Where("endpoint IN ?", endpoints)
+	if userID != "" {
+		q = q.Where("user_id = ?", userID)
+	}
+	if after != "" {
+		cursor, err := s.GetBatch(userID, after)
+		if err != nil {
+			return nil, err
+		}
+		q = q.Where("created_at < ? OR (created_at = ? AND id < ?)", cursor.CreatedAt, cursor.CreatedAt, cursor.ID)
+	}
+	if limit > 0 {
+		q = q.Limit(limit)
+	}
+	if err := q.Find(&batches).Error; err != nil {
+		return nil, err
+	}
+	return batches, nil
+}
+
+// DeleteBatch removes a batch record.
+func (s *Store) DeleteBatch(id string) error {
+	return s.db.Where("id = ?", id).Delete(&BatchRecord{}).Error
+}
+
+// ListUnfinishedBatches returns every batch that still needs work from a
+// runner: validating, in_progress, finalizing and cancelling batches.
+func (s *Store) ListUnfinishedBatches() ([]BatchRecord, error) {
+	var batches []BatchRecord
+	if err := s.db.Where("status IN ?", unfinishedStatuses).
+		Order("created_at ASC").Find(&batches).Error; err != nil {
+		return nil, err
+	}
+	return batches, nil
+}
+
+// SaveBatch writes every column of the batch.
+func (s *Store) SaveBatch(b *BatchRecord) error {
+	b.UpdatedAt = time.Now()
+	return s.db.Save(b).Error
+}
+
+// UpdateProgress persists the line cursor and request counters after a line
+// has been written to the output or error file.
+func (s *Store) UpdateProgress(id string, cursor, completed, failed int) error {
+	return s.db.Model(&BatchRecord{}).Where("id = ?", id).Updates(map[string]any{
+		"cursor":     cursor,
+		"completed":  completed,
+		"failed":     failed,
+		"updated_at": time.Now(),
+	}).Error
+}
+
+// Transition applies updates only while the batch is still in status from.
+// It returns false when the batch moved on concurrently (e.g. a cancel).
+func (s *Store) Transition(id, from string, updates map[string]any) (bool, error) {
+	updates["updated_at"] = time.Now()
+	res := s.db.Model(&BatchRecord{}).Where("id = ? AND status = ?", id, from).Updates(updates)
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected > 0, nil
+}
+
+// RequestCancel moves a batch that has not finished yet into the cancelling
+// state. It returns false when the batch was already in a terminal state.
+func (s *Store) RequestCancel(userID, id string) (bool, error) {
+	now := time.Now()
+	q := s.db.Model(&BatchRecord{}).Where("id = ? AND status IN ?", id, []string{schema.BatchStatusValidating, schema.BatchStatusInProgress})
+	if userID != "" {
+		q = q.Where("user_id = ?", userID)
+	}
+	res := q.Updates(map[string]any{
+		"status":        schema.BatchStatusCancelling,
+		"cancelling_at": &now,
+		"updated_at":    now,
+	})
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected > 0, nil
+}
+
+// GetBatchStatus returns only the status column, used by the runner to
+// notice cancellations requested on another replica.
+func (s *Store) GetBatchStatus(id string) (string, error) {
+	var b BatchRecord
+	if err := s.db.Select("status").Where("id = ?", id).First(&b).Error; err != nil {
+		return "", err
+	}
+	return b.Status, nil
+}
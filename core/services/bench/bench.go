@@ -0,0 +1,210 @@
+// Package bench replays a workload against a model at a fixed concurrency
+// and summarizes latency, time-to-first-token and throughput, so that runs
+// on different backends, quantizations or machines can be compared.
+package bench
+
+import (
+	"context"
+	"math"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Options controls how a workload is replayed.
+type Options struct {
+	// Requests is the number of measured requests.
+	Requests int `json:"requests"`
+	// Concurrency is the number of requests in flight at any time.
+	Concurrency int `json:"concurrency"`
+	// Warmup requests run before the measured ones and are not counted.
+	Warmup int `json:"warmup"`
+}
+
+// Sample is the measurement of a single request. Latency is filled in by
+// Run; tasks report the rest when it applies to the workload.
+type Sample struct {
+	Latency      time.Duration
+	TTFT         time.Duration
+	InputTokens  int
+	OutputTokens int
+}
+
+// Task runs the i-th request of a workload.
+type Task func(ctx context.Context, i int) (Sample, error)
+
+// Distribution summarizes a set of measurements. Percentiles are
+// nearest-rank, so they are always one of the measured values.
+type Distribution struct {
+	Mean float64 `json:"mean"`
+	Min  float64 `json:"min"`
+	P50  float64 `json:"p50"`
+	P95  float64 `json:"p95"`
+	P99  float64 `json:"p99"`
+	Max  float64 `json:"max"`
+}
+
+// Result is the summary of a run. Latencies are in milliseconds.
+type Result struct {
+	Requests          int     `json:"requests"`
+	Errors            int     `json:"errors"`
+	FirstError        string  `json:"first_error,omitempty"`
+	WallSeconds       float64 `json:"wall_seconds"`
+	RequestsPerSecond float64 `json:"requests_per_second"`
+	InputTokens       int     `json:"input_tokens,omitempty"`
+	OutputTokens      int     `json:"output_tokens,omitempty"`
+	// TokensPerSecond is the aggregate generation throughput: output tokens
+	// of all requests over the wall time of the run.
+	TokensPerSecond float64       `json:"tokens_per_second,omitempty"`
+	Latency         *Distribution `json:"latency_ms,omitempty"`
+	TTFT            *Distribution `json:"ttft_ms,omitempty"`
+	// RequestTokensPerSecond is the decode speed seen by each request, from
+	// its first token to its last.
+	RequestTokensPerSecond *Distribution `json:"request_tokens_per_second,omitempty"`
+}
+
+// Settings records how a workload was built and replayed.
+type Settings struct {
+	Options
+	Prompts int `json:"prompts"`
+	// InputTokens is the approximate length of synthetic prompts.
+	InputTokens  int `json:"input_tokens,omitempty"`
+	OutputTokens int `json:"output_tokens,omitempty"`
+}
+
+// Report is the outcome of a benchmark, meant to be stored as JSON and
+// compared with the reports of other runs.
+type Report struct {
+	Version     string    `json:"version"`
+	Model       string    `json:"model"`
+	Backend     string    `json:"backend"`
+	Mode        string    `json:"mode"`
+	StartedAt   time.Time `json:"started_at"`
+	Settings    Settings  `json:"settings"`
+	LoadSeconds float64   `json:"load_seconds"`
+	PeakMemory  Memory    `json:"peak_memory"`
+	Result      Result    `json:"result"`
+}
+
+// Run replays task: Warmup requests one at a time, then Requests requests
+// with up to Concurrency of them in flight. Failed requests are counted but
+// left out of the distributions. Run stops early when ctx is done.
+func Run(ctx context.Context, opts Options, task Task) Result {
+	for i := range opts.Warmup {
+		if ctx.Err() != nil {
+			break
+		}
+		task(ctx, i)
+	}
+
+	concurrency := max(1, min(opts.Concurrency, opts.Requests))
+	next := make(chan int)
+	go func() {
+		defer close(next)
+		for i := range opts.Requests {
+			select {
+			case next <- opts.Warmup + i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var (
+		mu      sync.Mutex
+		samples []Sample
+		errs    []error
+	)
+	start := time.Now()
+	var wg sync.WaitGroup
+	for range concurrency {
+		wg.Go(func() {
+			for i := range next {
+				t := time.Now()
+				s, err := task(ctx, i)
+				s.Latency = time.Since(t)
+
+				mu.Lock()
+				if err != nil {
+					errs = append(errs, err)
+				} else {
+					samples = append(samples, s)
+				}
+				mu.Unlock()
+			}
+		})
+	}
+	wg.Wait()
+
+	return summarize(samples, errs, time.Since(start))
+}
+
+func summarize(samples []Sample, errs []error, wall time.Duration) Result {
+	r := Result{
+		Requests:    len(samples) + len(errs),
+		Errors:      len(errs),
+		WallSeconds: round(wall.Seconds()),
+	}
+	if len(errs) > 0 {
+		r.FirstError = errs[0].Error()
+	}
+	if wall > 0 {
+		r.RequestsPerSecond = round(float64(len(samples)) / wall.Seconds())
+	}
+
+	var latencies, ttfts, speeds []float64
+	for _, s := range samples {
+		r.InputTokens += s.InputTokens
+		r.OutputTokens += s.OutputTokens
+		latencies = append(latencies, milliseconds(s.Latency))
+		if s.TTFT > 0 {
+			ttfts = append(ttfts, milliseconds(s.TTFT))
+		}
+		// The first token is excluded: its time is the TTFT.
+		if decode := s.Latency - s.TTFT; s.OutputTokens > 1 && decode > 0 {
+			speeds = append(speeds, float64(s.OutputTokens-1)/decode.Seconds())
+		}
+	}
+	if wall > 0 {
+		r.TokensPerSecond = round(float64(r.OutputTokens) / wall.Seconds())
+	}
+	r.Latency = distribution(latencies)
+	r.TTFT = distribution(ttfts)
+	r.RequestTokensPerSecond = distribution(speeds)
+	return r
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+func distribution(values []float64) *Distribution {
+	if len(values) == 0 {
+		return nil
+	}
+	slices.Sort(values)
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	return &Distribution{
+		Mean: round(sum / float64(len(values))),
+		Min:  round(values[0]),
+		P50:  round(percentile(values, 0.50)),
+		P95:  round(percentile(values, 0.95)),
+		P99:  round(percentile(values, 0.99)),
+		Max:  round(values[len(values)-1]),
+	}
+}
+
+// percentile returns the nearest-rank pth percentile of sorted values.
+func percentile(sorted []float64, p float64) float64 {
+	rank := int(math.Ceil(p*float64(len(sorted)))) - 1
+	return sorted[max(0, min(rank, len(sorted)-1))]
+}
+
+// round keeps results readable and diffable: sub-microsecond noise in the
+// JSON would make every run look different.
+func round(v float64) float64 {
+	return math.Round(v*1000) / 1000
+}
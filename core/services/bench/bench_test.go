@@ -0,0 +1,150 @@
+package bench_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mudler/LocalAI/core/services/bench"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Run", func() {
+	It("runs the warmup requests first and leaves them out of the results", func() {
+		var mu sync.Mutex
+		var seen []int
+		res := bench.Run(context.Background(), bench.Options{Requests: 4, Concurrency: 1, Warmup: 2}, func(_ context.Context, i int) (bench.Sample, error) {
+			mu.Lock()
+			seen = append(seen, i)
+			mu.Unlock()
+			return bench.Sample{}, nil
+		})
+		Expect(seen).To(Equal([]int{0, 1, 2, 3, 4, 5}))
+		Expect(res.Requests).To(Equal(4))
+		Expect(res.Errors).To(BeZero())
+	})
+
+	It("keeps at most Concurrency requests in flight", func() {
+		var inFlight, peak atomic.Int32
+		res := bench.Run(context.Background(), bench.Options{Requests: 12, Concurrency: 3}, func(context.Context, int) (bench.Sample, error) {
+			n := inFlight.Add(1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+			inFlight.Add(-1)
+			return bench.Sample{}, nil
+		})
+		Expect(res.Requests).To(Equal(12))
+		Expect(peak.Load()).To(BeEquivalentTo(3))
+	})
+
+	It("counts failures without measuring them", func() {
+		res := bench.Run(context.Background(), bench.Options{Requests: 4, Concurrency: 2}, func(_ context.Context, i int) (bench.Sample, error) {
+			if i%2 == 1 {
+				return bench.Sample{}, errors.New("backend went away")
+			}
+			return bench.Sample{OutputTokens: 10}, nil
+		})
+		Expect(res.Requests).To(Equal(4))
+		Expect(res.Errors).To(Equal(2))
+		Expect(res.FirstError).To(Equal("backend went away"))
+		Expect(res.OutputTokens).To(Equal(20))
+	})
+
+	It("summarizes latency, TTFT and token throughput", func() {
+		res := bench.Run(context.Background(), bench.Options{Requests: 10, Concurrency: 1}, func(_ context.Context, i int) (bench.Sample, error) {
+			time.Sleep(time.Duration(i+1) * 5 * time.Millisecond)
+			return bench.Sample{TTFT: time.Millisecond, InputTokens: 3, OutputTokens: 5}, nil
+		})
+		Expect(res.InputTokens).To(Equal(30))
+		Expect(res.OutputTokens).To(Equal(50))
+		Expect(res.TokensPerSecond).To(BeNumerically(">", 0))
+
+		Expect(res.Latency).ToNot(BeNil())
+		Expect(res.Latency.Min).To(BeNumerically(">=", 5))
+		Expect(res.Latency.P50).To(BeNumerically(">=", 25))
+		Expect(res.Latency.P50).To(BeNumerically("<", res.Latency.P95))
+		Expect(res.Latency.P99).To(Equal(res.Latency.Max))
+
+		Expect(res.TTFT).ToNot(BeNil())
+		Expect(res.TTFT.P50).To(BeNumerically("==", 1))
+		Expect(res.RequestTokensPerSecond).ToNot(BeNil())
+		Expect(res.RequestTokensPerSecond.Max).To(BeNumerically("<", 4/0.004))
+	})
+
+	It("leaves out the metrics the workload doesn't produce", func() {
+		res := bench.Run(context.Background(), bench.Options{Requests: 2, Concurrency: 2}, func(context.Context, int) (bench.Sample, error) {
+			return bench.Sample{}, nil
+		})
+		Expect(res.Latency).ToNot(BeNil())
+		Expect(res.TTFT).To(BeNil())
+		Expect(res.RequestTokensPerSecond).To(BeNil())
+		Expect(res.TokensPerSecond).To(BeZero())
+	})
+
+	It("stops when the context is canceled", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		var calls atomic.Int32
+		res := bench.Run(ctx, bench.Options{Requests: 100, Concurrency: 1}, func(context.Context, int) (bench.Sample, error) {
+			if calls.Add(1) == 3 {
+				cancel()
+			}
+			return bench.Sample{}, nil
+		})
+		Expect(res.Requests).To(BeNumerically("<", 100))
+	})
+})
+
+var _ = Describe("MemorySampler", func() {
+	It("records the peak of each reading", func() {
+		readings := []bench.Memory{{RSS: 10, VRAM: 50}, {RSS: 30, VRAM: 20}, {RSS: 20, VRAM: 40}}
+		var mu sync.Mutex
+		n := 0
+		s := bench.StartMemorySampler(context.Background(), time.Millisecond, func() bench.Memory {
+			mu.Lock()
+			defer mu.Unlock()
+			m := readings[min(n, len(readings)-1)]
+			n++
+			return m
+		})
+		Eventually(func() int {
+			mu.Lock()
+			defer mu.Unlock()
+			return n
+		}).Should(BeNumerically(">=", len(readings)))
+		Expect(s.Stop()).To(Equal(bench.Memory{RSS: 30, VRAM: 50}))
+	})
+})
+
+var _ = Describe("Prompts", func() {
+	It("generates distinct prompts of the requested length", func() {
+		prompts := bench.SyntheticPrompts(3, 64)
+		Expect(prompts).To(HaveLen(3))
+		Expect(prompts[0]).ToNot(Equal(prompts[1]))
+		for _, p := range prompts {
+			Expect(strings.Fields(p)).To(HaveLen(65))
+		}
+	})
+
+	It("reads one prompt per non-empty line", func() {
+		path := filepath.Join(GinkgoT().TempDir(), "prompts.txt")
+		Expect(os.WriteFile(path, []byte("Hello there\n\n  What is LocalAI?  \n"), 0644)).To(Succeed())
+		Expect(bench.ReadPrompts(path)).To(Equal([]string{"Hello there", "What is LocalAI?"}))
+
+		empty := filepath.Join(GinkgoT().TempDir(), "empty.txt")
+		Expect(os.WriteFile(empty, []byte("\n \n"), 0644)).To(Succeed())
+		_, err := bench.ReadPrompts(empty)
+		Expect(err).To(HaveOccurred())
+	})
+})
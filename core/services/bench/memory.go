@@ -0,0 +1,97 @@
+package bench
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/mudler/LocalAI/pkg/xsysinfo"
+	gopsutil "github.com/shirou/gopsutil/v3/process"
+)
+
+// Memory is a memory usage reading, in bytes.
+type Memory struct {
+	// RSS is the resident memory of the backend process and its children.
+	RSS uint64 `json:"rss_bytes"`
+	// VRAM is the memory used on all GPUs, including by other processes.
+	VRAM uint64 `json:"vram_bytes"`
+}
+
+// MemorySampler records the peak memory usage while a run is in progress.
+type MemorySampler struct {
+	read   func() Memory
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu   sync.Mutex
+	peak Memory
+}
+
+// StartMemorySampler reads the memory usage every interval until Stop is
+// called. Each field peaks independently.
+func StartMemorySampler(ctx context.Context, interval time.Duration, read func() Memory) *MemorySampler {
+	ctx, cancel := context.WithCancel(ctx)
+	s := &MemorySampler{read: read, cancel: cancel, done: make(chan struct{})}
+	s.sample()
+	go func() {
+		defer close(s.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.sample()
+			}
+		}
+	}()
+	return s
+}
+
+func (s *MemorySampler) sample() {
+	m := s.read()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.peak.RSS = max(s.peak.RSS, m.RSS)
+	s.peak.VRAM = max(s.peak.VRAM, m.VRAM)
+}
+
+// Stop takes a last reading and returns the peak usage.
+func (s *MemorySampler) Stop() Memory {
+	s.cancel()
+	<-s.done
+	s.sample()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.peak
+}
+
+// ProcessMemory returns a reader of the memory used by the process pid (0
+// when it is unknown, e.g. for remote backends) and by the GPUs.
+func ProcessMemory(pid int) func() Memory {
+	return func() Memory {
+		m := Memory{VRAM: xsysinfo.GetGPUAggregateInfo().UsedVRAM}
+		if pid <= 0 {
+			return m
+		}
+		if p, err := gopsutil.NewProcess(int32(pid)); err == nil {
+			m.RSS = processTreeRSS(p)
+		}
+		return m
+	}
+}
+
+// processTreeRSS sums the RSS of p and its descendants: Python backends
+// often do the actual work in child processes.
+func processTreeRSS(p *gopsutil.Process) uint64 {
+	var rss uint64
+	if info, err := p.MemoryInfo(); err == nil {
+		rss = info.RSS
+	}
+	children, _ := p.Children()
+	for _, c := range children {
+		rss += processTreeRSS(c)
+	}
+	return rss
+}
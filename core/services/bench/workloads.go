@@ -0,0 +1,182 @@
+package bench
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mudler/LocalAI/core/backend"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/core/templates"
+	"github.com/mudler/LocalAI/pkg/model"
+)
+
+// Workloads a model can be benchmarked with.
+const (
+	ModeLLM           = "llm"
+	ModeEmbeddings    = "embeddings"
+	ModeTTS           = "tts"
+	ModeTranscription = "transcription"
+)
+
+// DetectMode returns the workload matching the usecases of cfg, defaulting
+// to text generation.
+func DetectMode(cfg *config.ModelConfig) string {
+	switch {
+	case cfg.HasUsecases(config.FLAG_EMBEDDINGS):
+		return ModeEmbeddings
+	case cfg.HasUsecases(config.FLAG_TRANSCRIPT):
+		return ModeTranscription
+	case cfg.HasUsecases(config.FLAG_TTS):
+		return ModeTTS
+	default:
+		return ModeLLM
+	}
+}
+
+// Runtime is what the tasks need to reach the backends.
+type Runtime struct {
+	Loader  *model.ModelLoader
+	Configs *config.ModelConfigLoader
+	App     *config.ApplicationConfig
+}
+
+// Load loads the model of cfg, so that the measured requests don't pay for
+// it, and returns the process ID of its backend (0 if it has none, e.g. a
+// remote one).
+func (rt Runtime) Load(ctx context.Context, cfg config.ModelConfig) (int, error) {
+	m, err := rt.Loader.Load(backend.ModelOptions(cfg, rt.App, model.WithContext(ctx))...)
+	if err != nil {
+		return 0, err
+	}
+	if m == nil {
+		return 0, fmt.Errorf("could not load model %q", cfg.Name)
+	}
+	pid, err := rt.Loader.GetGRPCPID(cfg.ModelID())
+	if err != nil {
+		return 0, nil
+	}
+	return pid, nil
+}
+
+// LLMTask generates a completion for each prompt, streaming it to measure the
+// time to the first token. When outputTokens is set, generation ignores the
+// end of stream token so that every request produces the same length.
+func LLMTask(rt Runtime, cfg config.ModelConfig, prompts []string, outputTokens int) Task {
+	if outputTokens > 0 {
+		cfg.Maxtokens = &outputTokens
+		cfg.IgnoreEOS = true
+	}
+	evaluator := templates.NewEvaluator(rt.App.SystemState.Model.ModelsPath)
+
+	return func(ctx context.Context, i int) (Sample, error) {
+		prompt := prompts[i%len(prompts)]
+		messages := []schema.Message{{Role: "user", Content: prompt, StringContent: prompt}}
+		predInput := evaluator.TemplateMessages(schema.OpenAIRequest{}, messages, &cfg, nil, false)
+
+		start := time.Now()
+		var ttft time.Duration
+		var chunks int
+		fn, err := backend.ModelInference(ctx, predInput, messages, nil, nil, nil, rt.Loader, &cfg, rt.Configs, rt.App,
+			func(token string, _ backend.TokenUsage) bool {
+				if token == "" {
+					return true
+				}
+				if chunks == 0 {
+					ttft = time.Since(start)
+				}
+				chunks++
+				return true
+			}, "", "", nil, nil, nil, nil)
+		if err != nil {
+			return Sample{}, err
+		}
+		resp, err := fn()
+		if err != nil {
+			return Sample{}, err
+		}
+
+		s := Sample{TTFT: ttft, InputTokens: resp.Usage.Prompt, OutputTokens: resp.Usage.Completion}
+		// Not every backend reports usage; streamed chunks are the best
+		// approximation then.
+		if s.OutputTokens == 0 {
+			s.OutputTokens = chunks
+		}
+		return s, nil
+	}
+}
+
+// EmbeddingsTask computes the embedding of each prompt.
+func EmbeddingsTask(rt Runtime, cfg config.ModelConfig, prompts []string) Task {
+	return func(ctx context.Context, i int) (Sample, error) {
+		fn, err := backend.ModelEmbedding(ctx, prompts[i%len(prompts)], nil, rt.Loader, cfg, rt.App)
+		if err != nil {
+			return Sample{}, err
+		}
+		if _, err := fn(); err != nil {
+			return Sample{}, err
+		}
+		return Sample{}, nil
+	}
+}
+
+// TTSTask synthesizes each text. The generated audio is discarded.
+func TTSTask(rt Runtime, cfg config.ModelConfig, texts []string, voice, language string) Task {
+	return func(ctx context.Context, i int) (Sample, error) {
+		path, _, err := backend.ModelTTS(ctx, texts[i%len(texts)], voice, language, "", nil, rt.Loader, rt.App, cfg)
+		if err != nil {
+			return Sample{}, err
+		}
+		os.Remove(path)
+		return Sample{}, nil
+	}
+}
+
+// TranscriptionTask transcribes each audio file.
+func TranscriptionTask(rt Runtime, cfg config.ModelConfig, files []string, language string) Task {
+	return func(ctx context.Context, i int) (Sample, error) {
+		_, err := backend.ModelTranscription(ctx, files[i%len(files)], language, false, false, "", rt.Loader, cfg, rt.App)
+		return Sample{}, err
+	}
+}
+
+// ReadPrompts reads a prompt set: one prompt per non-empty line.
+func ReadPrompts(path string) ([]string, error) {
+	dat, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var prompts []string
+	for line := range strings.Lines(string(dat)) {
+		if line = strings.TrimSpace(line); line != "" {
+			prompts = append(prompts, line)
+		}
+	}
+	if len(prompts) == 0 {
+		return nil, errors.New("no prompts in " + path)
+	}
+	return prompts, nil
+}
+
+// fillerWords are short, common words that most tokenizers encode as a
+// single token each.
+var fillerWords = strings.Fields("the quick brown fox jumps over a lazy dog while it is raining in the old town and we are all")
+
+// SyntheticPrompts returns n distinct prompts of approximately tokens tokens
+// each. They differ from their first word, so that prompt caches can't make
+// later requests cheaper than the first.
+func SyntheticPrompts(n, tokens int) []string {
+	prompts := make([]string, n)
+	for i := range prompts {
+		words := []string{fmt.Sprintf("Request %d:", i)}
+		for j := len(words); j < tokens; j++ {
+			words = append(words, fillerWords[(i+j)%len(fillerWords)])
+		}
+		prompts[i] = strings.Join(words, " ")
+	}
+	return prompts
+}
@@ -20,7 +20,8 @@ import (
 // ForwardViaBackend loads the cloud-proxy gRPC backend, ships the
 // request via the Forward RPC, and pumps the response back to the
 // client. PII redaction runs request-side (the NER middleware + MITM
-// input path); the response is forwarded unmodified.
+// input path); the response is forwarded as-is, and the NER middleware
+// scans it on the way out for models with pii.scan_responses on.
 func ForwardViaBackend(
 	c echo.Context,
 	cfg *config.ModelConfig,
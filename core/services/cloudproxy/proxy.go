@@ -3,7 +3,9 @@
 // itself lives inside the cloud-proxy backend binary
 // (backend/go/cloud-proxy), not here — this package is the core-side
 // glue. PII redaction runs request-side (the NER middleware + MITM
-// input path); response/output is forwarded unmodified.
+// input path); responses are relayed as-is here, and output scanning,
+// when a model opts in, happens in the NER middleware wrapping the
+// handler.
 package cloudproxy
 
 import (
@@ -60,9 +62,9 @@ func forwardBuffered(c echo.Context, statusCode int, contentType string, body io
 }
 
 // forwardStream relays the upstream SSE response to the client,
-// flushing per read so events arrive in real time. Response/output PII
-// redaction is out of scope for now, so the stream is forwarded
-// unmodified.
+// flushing per read so events arrive in real time. The stream is
+// relayed as-is: output PII scanning, when the model opts in, happens
+// in the NER middleware, which reassembles events across reads.
 func forwardStream(c echo.Context, body io.Reader) error {
 	c.Response().Header().Set("Content-Type", "text/event-stream")
 	c.Response().Header().Set("Cache-Control", "no-cache")
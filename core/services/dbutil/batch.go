@@ -0,0 +1,181 @@
+package dbutil
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/mudler/LocalAI/core/services/advisorylock"
+	"github.com/mudler/xlog"
+	"gorm.io/gorm"
+)
+
+// BatchConfig describes how a BatchWriter buffers and expires its rows.
+type BatchConfig[T any] struct {
+	// Component and Noun name the writer in logs and errors, e.g. "pii"
+	// and "event".
+	Component string
+	Noun      string
+	// ID identifies a row in the log line of a row the database rejects.
+	ID func(*T) string
+
+	// FlushInterval is how long rows wait in memory before they are
+	// inserted as one batch.
+	FlushInterval time.Duration
+	// MaxPending caps the buffer while the database is failing.
+	MaxPending int
+
+	// Retention is how long rows are kept, by their created_at column;
+	// 0 keeps them forever. Expired rows are deleted every PruneInterval
+	// by the replica holding PruneLockKey.
+	Retention     time.Duration
+	PruneInterval time.Duration
+	PruneLockKey  int64
+}
+
+// BatchWriter inserts rows of the GORM model T in batches off the
+// request path, for stores that record from every request (PII events,
+// router decisions) and would otherwise pay one insert each time.
+// Readers call Flush first, so the buffer is never visible to them.
+type BatchWriter[T any] struct {
+	db  *gorm.DB
+	cfg BatchConfig[T]
+
+	mu      sync.Mutex
+	pending []T
+	// flushMu serializes flushes so a reader's flush and the ticker's
+	// don't insert the same batch twice.
+	flushMu sync.Mutex
+
+	cancel    context.CancelFunc
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewBatchWriter starts the flush loop and, with a retention, the prune
+// loop. Both stop with ctx or Close; Close also flushes what is still
+// buffered.
+func NewBatchWriter[T any](ctx context.Context, db *gorm.DB, cfg BatchConfig[T]) *BatchWriter[T] {
+	ctx, cancel := context.WithCancel(ctx)
+	w := &BatchWriter[T]{db: db, cfg: cfg, cancel: cancel, done: make(chan struct{})}
+	go w.flushLoop(ctx)
+	if cfg.Retention > 0 {
+		go advisorylock.RunLeaderLoop(ctx, db, cfg.PruneLockKey, cfg.PruneInterval, func() {
+			if n, err := w.Prune(time.Now()); err != nil {
+				xlog.Warn(cfg.Component+": failed to prune expired "+cfg.Noun+"s", "error", err)
+			} else if n > 0 {
+				xlog.Debug(cfg.Component+": pruned expired "+cfg.Noun+"s", "count", n)
+			}
+		})
+	}
+	return w
+}
+
+// Add buffers row for the next flush. It fails when MaxPending rows are
+// already waiting.
+func (w *BatchWriter[T]) Add(row T) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.pending) >= w.cfg.MaxPending {
+		return fmt.Errorf("%s: %s buffer full (%d pending)", w.cfg.Component, w.cfg.Noun, len(w.pending))
+	}
+	w.pending = append(w.pending, row)
+	return nil
+}
+
+// Close stops the background loops after a last flush.
+func (w *BatchWriter[T]) Close() {
+	w.closeOnce.Do(func() {
+		w.cancel()
+		<-w.done
+	})
+}
+
+func (w *BatchWriter[T]) flushLoop(ctx context.Context) {
+	defer close(w.done)
+	ticker := time.NewTicker(w.cfg.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			w.Flush()
+			return
+		case <-ticker.C:
+			w.Flush()
+		}
+	}
+}
+
+// Flush inserts every buffered row. When the batch fails it is retried
+// row by row, so a row the database rejects doesn't hold back the
+// others; see insertEach.
+func (w *BatchWriter[T]) Flush() {
+	w.flushMu.Lock()
+	defer w.flushMu.Unlock()
+
+	w.mu.Lock()
+	batch := w.pending
+	w.pending = nil
+	w.mu.Unlock()
+	if len(batch) == 0 {
+		return
+	}
+	if err := w.db.CreateInBatches(batch, 500).Error; err != nil {
+		xlog.Error(w.cfg.Component+": failed to flush "+w.cfg.Noun+" batch", "count", len(batch), "error", err)
+		rest := w.insertEach(batch)
+		if len(rest) == 0 {
+			return
+		}
+		w.mu.Lock()
+		if len(w.pending)+len(rest) <= w.cfg.MaxPending {
+			w.pending = append(rest, w.pending...)
+		}
+		w.mu.Unlock()
+	}
+}
+
+// insertEach inserts the rows one at a time. A row that fails while the
+// database still answers is dropped, as retrying it would fail again. If
+// the database is unreachable the rows not yet inserted are returned, to
+// go back to the front of the buffer up to MaxPending.
+func (w *BatchWriter[T]) insertEach(rows []T) []T {
+	for i := range rows {
+		err := w.db.Create(&rows[i]).Error
+		if err == nil {
+			continue
+		}
+		if pingErr := w.ping(); pingErr != nil {
+			return rows[i:]
+		}
+		xlog.Warn(w.cfg.Component+": dropping "+w.cfg.Noun+" the database rejected", "id", w.cfg.ID(&rows[i]), "error", err)
+	}
+	return nil
+}
+
+func (w *BatchWriter[T]) ping() error {
+	sqlDB, err := w.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Ping()
+}
+
+// Prune deletes the rows created before the retention window.
+func (w *BatchWriter[T]) Prune(now time.Time) (int64, error) {
+	res := w.db.Where("created_at < ?", now.Add(-w.cfg.Retention)).Delete(new(T))
+	return res.RowsAffected, res.Error
+}
+
+// TruncateColumn cuts s to at most n bytes without splitting a rune, so
+// a value taken from the request fits its column.
+func TruncateColumn(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
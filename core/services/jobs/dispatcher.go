@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/mudler/LocalAI/core/config"
@@ -84,6 +85,10 @@ type Dispatcher struct {
 	// Concurrency limiter; nil = unlimited
 	sem chan struct{}
 
+	// Hooks called with each job that reached a final status
+	resultHooksMu sync.RWMutex
+	resultHooks   []ResultHook
+
 	// Lifecycle
 	ctx    context.Context
 	cancel context.CancelFunc
@@ -131,6 +136,37 @@ func (d *Dispatcher) SetModelConfigLoader(cl ModelConfigLoader) {
 	d.configLoader = cl
 }
 
+// ResultHook is called with a job once its final status was persisted.
+// Result events are broadcast, so on a multi-frontend deployment the hook
+// runs on every frontend: hooks with side effects must deduplicate.
+type ResultHook func(job *JobRecord)
+
+// AddResultHook registers fn to be called with the jobs reaching a final
+// status. Hooks run on the result subscription: they must not block.
+func (d *Dispatcher) AddResultHook(fn ResultHook) {
+	d.resultHooksMu.Lock()
+	defer d.resultHooksMu.Unlock()
+	d.resultHooks = append(d.resultHooks, fn)
+}
+
+// runResultHooks calls the result hooks with the job of a result event.
+func (d *Dispatcher) runResultHooks(evt JobResultEvent) {
+	d.resultHooksMu.RLock()
+	hooks := d.resultHooks
+	d.resultHooksMu.RUnlock()
+	if len(hooks) == 0 {
+		return
+	}
+	job, err := d.store.GetJob(evt.JobID)
+	if err != nil {
+		xlog.Warn("Failed to load job for result hooks", "job_id", evt.JobID, "error", err)
+		return
+	}
+	for _, fn := range hooks {
+		fn(job)
+	}
+}
+
 // Start begins listening for jobs via NATS and starts the cron leader loop.
 func (d *Dispatcher) Start(ctx context.Context) error {
 	d.ctx, d.cancel = context.WithCancel(ctx)
@@ -174,6 +210,7 @@ func (d *Dispatcher) Start(ctx context.Context) error {
 	if d.store != nil {
 		d.resultSub, err = messaging.SubscribeJSON(d.nats, messaging.SubjectJobResultWildcard, func(evt JobResultEvent) {
 			d.store.UpdateJobStatus(evt.JobID, evt.Status, evt.Result, evt.Error)
+			d.runResultHooks(evt)
 		})
 		if err != nil {
 			return fmt.Errorf("subscribing to result events: %w", err)
@@ -14,6 +14,32 @@ type MCPToolRequest struct {
 	Arguments     map[string]any                                   `json:"arguments,omitempty"`
 	RemoteServers config.MCPGenericConfig[config.MCPRemoteServers] `json:"remote_servers"`
 	StdioServers  config.MCPGenericConfig[config.MCPSTDIOServers]  `json:"stdio_servers"`
+	// ElicitationSubject, when set, is where the worker sends the
+	// elicitation requests of the tool's server (MCPElicitationRequest);
+	// the frontend relays them to the API caller.
+	ElicitationSubject string `json:"elicitation_subject,omitempty"`
+	// CallerID is the user the tool call runs for: the worker serves the
+	// sampling requests of the tool's server as that user.
+	CallerID string `json:"caller_id,omitempty"`
+}
+
+// MCPElicitationRequest is the NATS request-reply payload a worker sends
+// to ElicitationSubject when an MCP server asks for user input during a
+// forwarded tool call.
+type MCPElicitationRequest struct {
+	ServerName      string `json:"server_name"`
+	Message         string `json:"message"`
+	Mode            string `json:"mode,omitempty"`
+	URL             string `json:"url,omitempty"`
+	RequestedSchema any    `json:"requested_schema,omitempty"`
+}
+
+// MCPElicitationResponse is the frontend's reply: the caller's action
+// (accept, decline or cancel) and, on accept, the submitted content.
+type MCPElicitationResponse struct {
+	Action  string         `json:"action"`
+	Content map[string]any `json:"content,omitempty"`
+	Error   string         `json:"error,omitempty"`
 }
 
 // MCPToolResponse is the NATS reply for an MCP tool execution.
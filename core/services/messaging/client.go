@@ -1,6 +1,7 @@
 package messaging
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -293,19 +294,7 @@ func QueueSubscribeJSON[T any](c MessagingClient, subject, queue string, handler
 // unmarshaling the reply. This eliminates the repeated marshal/request/unmarshal
 // boilerplate across all NATS request-reply call sites.
 func RequestJSON[Req, Reply any](c MessagingClient, subject string, req Req, timeout time.Duration) (*Reply, error) {
-	data, err := json.Marshal(req)
-	if err != nil {
-		return nil, fmt.Errorf("marshaling request: %w", err)
-	}
-	replyData, err := c.Request(subject, data, timeout)
-	if err != nil {
-		return nil, fmt.Errorf("NATS request to %s: %w", subject, err)
-	}
-	var reply Reply
-	if err := json.Unmarshal(replyData, &reply); err != nil {
-		return nil, fmt.Errorf("unmarshaling reply from %s: %w", subject, err)
-	}
-	return &reply, nil
+	return RequestJSONContext[Req, Reply](context.Background(), c, subject, req, timeout)
 }
 
 // Conn returns the underlying NATS connection for advanced usage.
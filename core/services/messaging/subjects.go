@@ -54,6 +54,21 @@ const (
 	QueueAgentWorkers     = "agent-workers"
 )
 
+// MCP client capabilities. Elicitation requests of a forwarded tool call go
+// back to the frontend on a per-call subject (request-reply); answers that
+// land on the wrong process are broadcast (Pub/Sub) to every frontend and
+// agent worker.
+const (
+	subjectMCPElicitationPrefix  = "mcp.elicitation."
+	SubjectMCPElicitationAnswers = "mcp.elicitation.answers"
+)
+
+// SubjectMCPElicitationRequest returns the NATS subject an agent worker
+// sends the elicitation requests of one forwarded tool call to.
+func SubjectMCPElicitationRequest(callID string) string {
+	return subjectMCPElicitationPrefix + sanitizeSubjectToken(callID) + ".request"
+}
+
 // SubjectFineTuneProgress returns the NATS subject for fine-tune progress.
 func SubjectFineTuneProgress(jobID string) string {
 	return subjectFineTunePrefix + sanitizeSubjectToken(jobID) + ".progress"
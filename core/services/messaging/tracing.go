@@ -0,0 +1,126 @@
+package messaging
+
+// Trace-context propagation over NATS. The span context of the caller
+// travels in the message headers (W3C traceparent/tracestate), so a worker
+// handling backend.install continues the trace the frontend request
+// started.
+//
+// The context-aware variants are extra methods on *Client consumed through
+// optional interfaces, like OnReconnect: MessagingClient stays minimal, and
+// test doubles without them fall back to the plain methods with an empty
+// context.
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/mudler/LocalAI/pkg/tracing"
+	"github.com/mudler/xlog"
+	"github.com/nats-io/nats.go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// headerCarrier adapts NATS headers, which share http.Header's layout, to
+// propagation.TextMapCarrier.
+func headerCarrier(h nats.Header) propagation.HeaderCarrier {
+	return propagation.HeaderCarrier(http.Header(h))
+}
+
+// RequestContext is Request with ctx's span context carried in the message
+// headers. Outside a trace it behaves exactly like Request. The timeout,
+// not ctx, still bounds the wait, as for Request.
+func (c *Client) RequestContext(ctx context.Context, subject string, data []byte, timeout time.Duration) ([]byte, error) {
+	msg := nats.NewMsg(subject)
+	msg.Data = data
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return c.requestMsg(msg, timeout)
+	}
+	ctx, span := tracing.Start(ctx, "nats.request", trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "nats"),
+			attribute.String("messaging.destination.name", subject),
+		))
+	otel.GetTextMapPropagator().Inject(ctx, headerCarrier(msg.Header))
+	reply, err := c.requestMsg(msg, timeout)
+	tracing.End(span, err)
+	return reply, err
+}
+
+func (c *Client) requestMsg(msg *nats.Msg, timeout time.Duration) ([]byte, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	reply, err := c.conn.RequestMsg(msg, timeout)
+	if err != nil {
+		return nil, fmt.Errorf("request to %s: %w", msg.Subject, err)
+	}
+	return reply.Data, nil
+}
+
+// SubscribeReplyContext is SubscribeReply with the handler receiving a
+// context that carries the span context found in the request headers.
+func (c *Client) SubscribeReplyContext(subject string, handler func(ctx context.Context, data []byte, reply func([]byte))) (Subscription, error) {
+	return c.confirmSubscription(subject, func(conn *nats.Conn) (*nats.Subscription, error) {
+		return conn.Subscribe(subject, func(msg *nats.Msg) {
+			ctx := context.Background()
+			if len(msg.Header) > 0 {
+				ctx = otel.GetTextMapPropagator().Extract(ctx, headerCarrier(msg.Header))
+			}
+			handler(ctx, msg.Data, func(replyData []byte) {
+				if msg.Reply != "" {
+					if err := msg.Respond(replyData); err != nil {
+						xlog.Warn("Failed to send NATS reply", "subject", subject, "error", err)
+					}
+				}
+			})
+		})
+	})
+}
+
+type contextRequester interface {
+	RequestContext(ctx context.Context, subject string, data []byte, timeout time.Duration) ([]byte, error)
+}
+
+type contextReplySubscriber interface {
+	SubscribeReplyContext(subject string, handler func(ctx context.Context, data []byte, reply func([]byte))) (Subscription, error)
+}
+
+// SubscribeReplyContext subscribes handler through c's SubscribeReplyContext
+// when it has one, and through SubscribeReply with a background context
+// otherwise.
+func SubscribeReplyContext(c MessagingClient, subject string, handler func(ctx context.Context, data []byte, reply func([]byte))) (Subscription, error) {
+	if cs, ok := c.(contextReplySubscriber); ok {
+		return cs.SubscribeReplyContext(subject, handler)
+	}
+	return c.SubscribeReply(subject, func(data []byte, reply func([]byte)) {
+		handler(context.Background(), data, reply)
+	})
+}
+
+// RequestJSONContext is RequestJSON propagating ctx's span context to the
+// replier when c supports it.
+func RequestJSONContext[Req, Reply any](ctx context.Context, c MessagingClient, subject string, req Req, timeout time.Duration) (*Reply, error) {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling request: %w", err)
+	}
+	var replyData []byte
+	if cr, ok := c.(contextRequester); ok {
+		replyData, err = cr.RequestContext(ctx, subject, data, timeout)
+	} else {
+		replyData, err = c.Request(subject, data, timeout)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("NATS request to %s: %w", subject, err)
+	}
+	var reply Reply
+	if err := json.Unmarshal(replyData, &reply); err != nil {
+		return nil, fmt.Errorf("unmarshaling reply from %s: %w", subject, err)
+	}
+	return &reply, nil
+}
@@ -0,0 +1,50 @@
+package messaging_test
+
+import (
+	"context"
+	"time"
+
+	"github.com/mudler/LocalAI/core/services/messaging"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// plainClient implements only MessagingClient, without the context-aware
+// extensions of *messaging.Client.
+type plainClient struct {
+	messaging.MessagingClient
+	subject string
+	reply   func([]byte, func([]byte))
+}
+
+func (p *plainClient) Request(subject string, data []byte, _ time.Duration) ([]byte, error) {
+	p.subject = subject
+	var out []byte
+	p.reply(data, func(b []byte) { out = b })
+	return out, nil
+}
+
+func (p *plainClient) SubscribeReply(subject string, handler func([]byte, func([]byte))) (messaging.Subscription, error) {
+	p.subject = subject
+	p.reply = handler
+	return nil, nil
+}
+
+var _ = Describe("context-aware helpers", func() {
+	It("fall back to the plain methods for clients without context support", func() {
+		c := &plainClient{}
+		var gotCtx context.Context
+		_, err := messaging.SubscribeReplyContext(c, "echo", func(ctx context.Context, data []byte, reply func([]byte)) {
+			gotCtx = ctx
+			reply(data)
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		type msg struct{ Value string }
+		out, err := messaging.RequestJSONContext[msg, msg](context.Background(), c, "echo", msg{Value: "hi"}, time.Second)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(out.Value).To(Equal("hi"))
+		Expect(c.subject).To(Equal("echo"))
+		Expect(gotCtx).ToNot(BeNil())
+	})
+})
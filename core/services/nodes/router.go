@@ -19,8 +19,11 @@ import (
 	grpc "github.com/mudler/LocalAI/pkg/grpc"
 	pb "github.com/mudler/LocalAI/pkg/grpc/proto"
 	"github.com/mudler/LocalAI/pkg/model"
+	"github.com/mudler/LocalAI/pkg/tracing"
 	"github.com/mudler/LocalAI/pkg/vram"
 	"github.com/mudler/xlog"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/sync/singleflight"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
@@ -611,6 +614,19 @@ func (r *SmartRouter) Route(ctx context.Context, modelID, modelName, backendType
 	if trackingKey == "" {
 		trackingKey = modelName
 	}
+	ctx, span := tracing.Start(ctx, "nodes.route", trace.WithAttributes(
+		attribute.String("localai.model", trackingKey),
+		attribute.String("localai.backend", backendType),
+	))
+	result, err := r.route(ctx, trackingKey, modelName, backendType, configRevision, modelOpts, parallel)
+	if err == nil && result.Node != nil {
+		span.SetAttributes(attribute.String("localai.node", result.Node.Name))
+	}
+	tracing.End(span, err)
+	return result, err
+}
+
+func (r *SmartRouter) route(ctx context.Context, trackingKey, modelName, backendType, configRevision string, modelOpts *pb.ModelOptions, parallel bool) (*RouteResult, error) {
 	if configRevision != "" {
 		if err := r.registry.EstablishModelConfigRevision(ctx, trackingKey, configRevision); err != nil {
 			return nil, fmt.Errorf("establishing config revision for %s: %w", trackingKey, err)
@@ -758,10 +774,20 @@ func (r *SmartRouter) tryWarmPath(ctx context.Context, att *routeAttempt) *Route
 // the replica it landed on. initialInFlight reserves the slot for the calling
 // request; the job runner passes 0 because it is loading on nobody's behalf.
 func (r *SmartRouter) coldLoad(ctx context.Context, att *routeAttempt, initialInFlight int) (*RouteResult, error) {
+	ctx, span := tracing.Start(ctx, "model.load", trace.WithAttributes(
+		attribute.String("localai.model", att.trackingKey),
+		attribute.String("localai.backend", att.backendType),
+	))
 	result, err := r.scheduleAndLoad(ctx, att.backendType, att.trackingKey, att.modelName, att.configRevision, att.modelOpts, att.parallel, initialInFlight)
 	if err != nil {
+		tracing.End(span, err)
 		return nil, err
 	}
+	span.SetAttributes(
+		attribute.String("localai.node", result.Node.Name),
+		attribute.Int("localai.replica", result.ReplicaIndex),
+	)
+	tracing.End(span, nil)
 
 	// Cold load landed on result.Node replica result.ReplicaIndex: record the
 	// assignment so subsequent requests with the same prefix prefer it.
@@ -1304,7 +1330,7 @@ func (r *SmartRouter) installBackendOnNode(ctx context.Context, node *BackendNod
 	// frees the caller promptly. The shared install keeps running in the
 	// background and still coalesces other callers via singleflight.
 	resCh := r.installFlight.DoChan(key, func() (any, error) {
-		reply, err := r.unloader.InstallBackend(node.ID, backendType, modelID, r.galleriesJSON, "", "", "", replicaIndex, "", nil)
+		reply, err := r.unloader.InstallBackend(ctx, node.ID, backendType, modelID, r.galleriesJSON, "", "", "", replicaIndex, "", nil)
 		if err != nil {
 			return "", err
 		}
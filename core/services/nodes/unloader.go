@@ -30,7 +30,7 @@ import (
 // nats.ErrNoResponders for old workers that don't subscribe to the new
 // backend.upgrade subject.
 type NodeCommandSender interface {
-	InstallBackend(nodeID, backendType, modelID, galleriesJSON, uri, name, alias string, replicaIndex int, opID string, onProgress func(messaging.BackendInstallProgressEvent)) (*messaging.BackendInstallReply, error)
+	InstallBackend(ctx context.Context, nodeID, backendType, modelID, galleriesJSON, uri, name, alias string, replicaIndex int, opID string, onProgress func(messaging.BackendInstallProgressEvent)) (*messaging.BackendInstallReply, error)
 	UpgradeBackend(nodeID, backendType, galleriesJSON, uri, name, alias string, replicaIndex int, opID string, onProgress func(messaging.BackendInstallProgressEvent)) (*messaging.BackendUpgradeReply, error)
 	DeleteBackend(nodeID, backendName string) (*messaging.BackendDeleteReply, error)
 	ListBackends(nodeID string) (*messaging.BackendListReply, error)
@@ -201,6 +201,7 @@ func (a *RemoteUnloaderAdapter) UnloadRemoteModelContext(ctx context.Context, mo
 // it lives on a different NATS subject so it cannot head-of-line-block
 // routine load traffic on the same worker.
 func (a *RemoteUnloaderAdapter) InstallBackend(
+	ctx context.Context,
 	nodeID, backendType, modelID, galleriesJSON, uri, name, alias string,
 	replicaIndex int,
 	opID string,
@@ -213,7 +214,7 @@ func (a *RemoteUnloaderAdapter) InstallBackend(
 	// request so we don't miss early events.
 	sub := a.subscribeProgress(nodeID, opID, onProgress)
 
-	reply, err := messaging.RequestJSON[messaging.BackendInstallRequest, messaging.BackendInstallReply](a.nats, subject, messaging.BackendInstallRequest{
+	reply, err := messaging.RequestJSONContext[messaging.BackendInstallRequest, messaging.BackendInstallReply](ctx, a.nats, subject, messaging.BackendInstallRequest{
 		Backend:          backendType,
 		ModelID:          modelID,
 		BackendGalleries: galleriesJSON,
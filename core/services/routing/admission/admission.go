@@ -5,66 +5,249 @@
 // row goes into the shared event store alongside PII and proxy
 // rows so admins see a single timeline of routing pressure.
 //
-// Concurrency model: one buffered channel per model name (kept in
-// a sync.Map). Acquire is a non-blocking send; full = reject. No
-// queueing in the MVP — adding queue depth + timeout is a small
-// follow-up if/when telemetry shows admins want it.
+// Concurrency model: one slot counter per model name, guarded by
+// the Limiter mutex. Acquire never blocks: full = reject. Wait
+// additionally lets a bounded number of requests queue for a slot
+// when the model opts in (limits.queue_size). Freed slots go to the
+// highest priority class first and, within a class, to the user
+// holding the fewest slots, so one batch script can't starve the
+// other users of the same class. Waiters give up at their queue
+// deadline.
 package admission
 
 import (
+	"context"
+	"errors"
 	"sync"
 	"time"
 )
 
-// Limiter holds the per-model semaphores. Safe for concurrent use.
+// Priority is the class a request waits in. Higher classes are
+// served first.
+type Priority int
+
+const (
+	PriorityLow Priority = iota
+	PriorityNormal
+	PriorityHigh
+)
+
+// ParsePriority maps a priority name (low, normal, high) to its
+// class. Unknown names map to PriorityNormal.
+func ParsePriority(s string) Priority {
+	switch s {
+	case "low":
+		return PriorityLow
+	case "high":
+		return PriorityHigh
+	default:
+		return PriorityNormal
+	}
+}
+
+func (p Priority) String() string {
+	switch p {
+	case PriorityLow:
+		return "low"
+	case PriorityHigh:
+		return "high"
+	default:
+		return "normal"
+	}
+}
+
+var (
+	// ErrQueueFull is returned by Wait when every slot is busy and
+	// the queue has no room (or the model has no queue).
+	ErrQueueFull = errors.New("admission queue is full")
+	// ErrQueueTimeout is returned by Wait when the request reached
+	// its queue deadline without getting a slot.
+	ErrQueueTimeout = errors.New("timed out waiting in the admission queue")
+	// ErrEvicted is returned by Wait when a higher-priority request
+	// took the queue position of the waiting one.
+	ErrEvicted = errors.New("evicted from the admission queue by a higher priority request")
+)
+
+// Limiter holds the per-model slots and queues. Safe for concurrent
+// use.
 //
 // Each model's slot count is fixed at first Acquire — a config
 // edit that reduces MaxConcurrent only takes effect on the NEXT
 // process start (or after the limiter is rebuilt). The alternative
-// (dynamic resize on every call) would require swapping the channel
-// out from under in-flight Acquires; the simplicity tradeoff favors
-// "restart to apply" since admins editing limits do so rarely.
+// (dynamic resize on every call) would have to decide what happens
+// to the slots in flight above the new cap; the simplicity tradeoff
+// favors "restart to apply" since admins editing limits do so rarely.
+// Queue size and deadline are read on every Wait, so they apply
+// right away.
 type Limiter struct {
-	mu    sync.Mutex
-	slots map[string]chan struct{}
+	mu     sync.Mutex
+	models map[string]*modelSlots
+	seq    uint64
+}
+
+type modelSlots struct {
+	capacity int
+	inFlight int
+	byUser   map[string]int
+	waiters  []*waiter
+}
+
+type waiter struct {
+	priority Priority
+	user     string
+	seq      uint64
+	// ready is closed once the waiter left the queue: granted tells
+	// whether it got a slot or was evicted.
+	ready   chan struct{}
+	granted bool
+}
+
+// Request describes a caller waiting for a slot.
+type Request struct {
+	Model         string
+	MaxConcurrent int
+	// QueueSize is the number of requests allowed to wait for the
+	// model. 0 means no queue: Wait behaves like Acquire.
+	QueueSize int
+	// Timeout is the queue deadline. 0 waits until ctx is done.
+	Timeout  time.Duration
+	Priority Priority
+	// User identifies the caller for fair sharing within a class.
+	User string
 }
 
 // New returns an empty Limiter.
 func New() *Limiter {
-	return &Limiter{slots: make(map[string]chan struct{})}
+	return &Limiter{models: make(map[string]*modelSlots)}
 }
 
 // Acquire takes a slot for the named model. maxConcurrent <= 0
 // means unlimited — Acquire returns immediately with a no-op
-// release. When all slots are busy, returns ok=false. Callers
-// MUST call the returned release when done (typically via defer);
-// missing a release leaks one slot for the lifetime of the
-// process.
+// release. When all slots are busy, or requests are queued for
+// them, returns ok=false. Callers MUST call the returned release
+// when done (typically via defer); missing a release leaks one
+// slot for the lifetime of the process.
 func (l *Limiter) Acquire(modelName string, maxConcurrent int) (release func(), ok bool) {
 	if maxConcurrent <= 0 {
 		return func() {}, true
 	}
-	ch := l.slot(modelName, maxConcurrent)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	m := l.slot(modelName, maxConcurrent)
+	if m.inFlight >= m.capacity || len(m.waiters) > 0 {
+		return nil, false
+	}
+	return l.grant(modelName, m, ""), true
+}
+
+// Wait takes a slot for req.Model, queueing for one if the model has
+// a queue and it has room. A full queue still admits a request that
+// outranks its lowest-priority waiter, which is evicted. Wait returns
+// ErrQueueFull, ErrQueueTimeout, ErrEvicted or the ctx error when no
+// slot was taken; otherwise the same release contract as Acquire
+// applies.
+func (l *Limiter) Wait(ctx context.Context, req Request) (release func(), err error) {
+	if req.MaxConcurrent <= 0 {
+		return func() {}, nil
+	}
+	l.mu.Lock()
+	m := l.slot(req.Model, req.MaxConcurrent)
+	if m.inFlight < m.capacity && len(m.waiters) == 0 {
+		release := l.grant(req.Model, m, req.User)
+		l.mu.Unlock()
+		return release, nil
+	}
+	if req.QueueSize <= 0 {
+		l.mu.Unlock()
+		recordRejection(req.Model, "full")
+		return nil, ErrQueueFull
+	}
+	if len(m.waiters) >= req.QueueSize {
+		victim := m.lowestWaiter()
+		if victim == nil || victim.priority >= req.Priority {
+			l.mu.Unlock()
+			recordRejection(req.Model, "full")
+			return nil, ErrQueueFull
+		}
+		m.remove(victim)
+		close(victim.ready)
+	}
+	l.seq++
+	w := &waiter{priority: req.Priority, user: req.User, seq: l.seq, ready: make(chan struct{})}
+	m.waiters = append(m.waiters, w)
+	l.mu.Unlock()
+	queueDepthChanged(req.Model, 1)
+
+	start := time.Now()
+	var deadline <-chan time.Time
+	if req.Timeout > 0 {
+		timer := time.NewTimer(req.Timeout)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+
+	select {
+	case <-w.ready:
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-deadline:
+		err = ErrQueueTimeout
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
 	select {
-	case ch <- struct{}{}:
-		return func() { <-ch }, true
+	case <-w.ready:
 	default:
-		return nil, false
+		// Still queued: leave. A slot granted in the meantime would
+		// have closed ready, so nothing is held here.
+		m.remove(w)
+	}
+	queueDepthChanged(req.Model, -1)
+
+	switch {
+	case w.granted && err == nil:
+		recordWait(req.Model, req.Priority, "admitted", time.Since(start))
+		return l.releaseFunc(req.Model, m, req.User), nil
+	case w.granted:
+		// Granted while giving up: hand the slot to the next waiter.
+		l.free(req.Model, m, req.User)
+	case err == nil:
+		err = ErrEvicted
 	}
+	outcome := "canceled"
+	switch err {
+	case ErrQueueTimeout:
+		outcome = "timeout"
+		recordRejection(req.Model, "timeout")
+	case ErrEvicted:
+		outcome = "evicted"
+		recordRejection(req.Model, "evicted")
+	}
+	recordWait(req.Model, req.Priority, outcome, time.Since(start))
+	return nil, err
 }
 
 // InFlight reports the number of currently-held slots for the
-// named model. Used by the admin status surface — read-only and
-// approximate (ch length is racy with concurrent Acquire/release
-// but that's fine for a dashboard).
+// named model. Used by the admin status surface.
 func (l *Limiter) InFlight(modelName string) int {
 	l.mu.Lock()
-	ch, ok := l.slots[modelName]
-	l.mu.Unlock()
-	if !ok {
-		return 0
+	defer l.mu.Unlock()
+	if m, ok := l.models[modelName]; ok {
+		return m.inFlight
 	}
-	return len(ch)
+	return 0
+}
+
+// Queued reports the number of requests waiting for a slot of the
+// named model. Same dashboard-only purpose as InFlight.
+func (l *Limiter) Queued(modelName string) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if m, ok := l.models[modelName]; ok {
+		return len(m.waiters)
+	}
+	return 0
 }
 
 // Capacity reports the limiter's configured slot count for the
@@ -72,24 +255,100 @@ func (l *Limiter) InFlight(modelName string) int {
 // against it. Same dashboard-only purpose as InFlight.
 func (l *Limiter) Capacity(modelName string) int {
 	l.mu.Lock()
-	ch, ok := l.slots[modelName]
-	l.mu.Unlock()
-	if !ok {
-		return 0
+	defer l.mu.Unlock()
+	if m, ok := l.models[modelName]; ok {
+		return m.capacity
 	}
-	return cap(ch)
+	return 0
 }
 
-// slot returns the per-model channel, creating it on first use.
-func (l *Limiter) slot(modelName string, capacity int) chan struct{} {
-	l.mu.Lock()
-	defer l.mu.Unlock()
-	if ch, ok := l.slots[modelName]; ok {
-		return ch
+// slot returns the per-model slots, creating them on first use.
+// Called with l.mu held.
+func (l *Limiter) slot(modelName string, capacity int) *modelSlots {
+	if m, ok := l.models[modelName]; ok {
+		return m
+	}
+	m := &modelSlots{capacity: capacity, byUser: make(map[string]int)}
+	l.models[modelName] = m
+	return m
+}
+
+// grant takes a slot for user. Called with l.mu held.
+func (l *Limiter) grant(modelName string, m *modelSlots, user string) func() {
+	m.inFlight++
+	m.byUser[user]++
+	return l.releaseFunc(modelName, m, user)
+}
+
+func (l *Limiter) releaseFunc(modelName string, m *modelSlots, user string) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			l.free(modelName, m, user)
+		})
+	}
+}
+
+// free returns a slot of user and hands it to the next waiter.
+// Called with l.mu held.
+func (l *Limiter) free(modelName string, m *modelSlots, user string) {
+	m.inFlight--
+	if m.byUser[user]--; m.byUser[user] <= 0 {
+		delete(m.byUser, user)
+	}
+	for m.inFlight < m.capacity {
+		w := m.nextWaiter()
+		if w == nil {
+			return
+		}
+		m.remove(w)
+		m.inFlight++
+		m.byUser[w.user]++
+		w.granted = true
+		close(w.ready)
+	}
+}
+
+// nextWaiter picks the waiter to serve: highest priority, then the
+// user holding the fewest slots, then the oldest.
+func (m *modelSlots) nextWaiter() *waiter {
+	var best *waiter
+	for _, w := range m.waiters {
+		if best == nil || w.priority > best.priority {
+			best = w
+			continue
+		}
+		if w.priority < best.priority {
+			continue
+		}
+		if hw, hb := m.byUser[w.user], m.byUser[best.user]; hw < hb || (hw == hb && w.seq < best.seq) {
+			best = w
+		}
+	}
+	return best
+}
+
+// lowestWaiter returns the waiter to evict: lowest priority, then
+// the most recent.
+func (m *modelSlots) lowestWaiter() *waiter {
+	var worst *waiter
+	for _, w := range m.waiters {
+		if worst == nil || w.priority < worst.priority || (w.priority == worst.priority && w.seq > worst.seq) {
+			worst = w
+		}
+	}
+	return worst
+}
+
+func (m *modelSlots) remove(w *waiter) {
+	for i, x := range m.waiters {
+		if x == w {
+			m.waiters = append(m.waiters[:i], m.waiters[i+1:]...)
+			return
+		}
 	}
-	ch := make(chan struct{}, capacity)
-	l.slots[modelName] = ch
-	return ch
 }
 
 // RetryAfter returns the Retry-After header value for a rejected
@@ -103,3 +362,14 @@ func RetryAfter(configured int) time.Duration {
 	}
 	return time.Second
 }
+
+// QueueTimeout returns the queue deadline for the configured
+// limits.queue_timeout_seconds, defaulting to 30s: long enough for
+// a few generations to finish, short enough that clients behind a
+// proxy with its own timeout still get a clean 503.
+func QueueTimeout(configured int) time.Duration {
+	if configured > 0 {
+		return time.Duration(configured) * time.Second
+	}
+	return 30 * time.Second
+}
@@ -1,6 +1,7 @@
 package admission
 
 import (
+	"context"
 	"sync"
 	"time"
 
@@ -95,9 +96,166 @@ var _ = Describe("Limiter", func() {
 	})
 })
 
+var _ = Describe("Limiter queue", func() {
+	// waitAsync queues a request and reports its outcome on the
+	// returned channel once Wait returns.
+	waitAsync := func(l *Limiter, req Request) chan error {
+		done := make(chan error, 1)
+		go func() {
+			release, err := l.Wait(context.Background(), req)
+			if err == nil {
+				defer release()
+			}
+			done <- err
+		}()
+		return done
+	}
+	queued := func(l *Limiter, n int) {
+		Eventually(func() int { return l.Queued("m") }).Should(Equal(n))
+	}
+
+	It("rejects at once without a queue", func() {
+		l := New()
+		r1, err := l.Wait(context.Background(), Request{Model: "m", MaxConcurrent: 1})
+		Expect(err).NotTo(HaveOccurred())
+		defer r1()
+		_, err = l.Wait(context.Background(), Request{Model: "m", MaxConcurrent: 1})
+		Expect(err).To(MatchError(ErrQueueFull))
+	})
+
+	It("hands a freed slot to the queued request", func() {
+		l := New()
+		r1, _ := l.Acquire("m", 1)
+		done := waitAsync(l, Request{Model: "m", MaxConcurrent: 1, QueueSize: 1})
+		queued(l, 1)
+
+		_, ok := l.Acquire("m", 1)
+		Expect(ok).To(BeFalse(), "Acquire must not jump the queue")
+
+		r1()
+		Eventually(done).Should(Receive(BeNil()))
+		Expect(l.InFlight("m")).To(Equal(0))
+	})
+
+	It("serves higher priority classes first", func() {
+		l := New()
+		r1, _ := l.Acquire("m", 1)
+		order := make(chan Priority, 3)
+		var wg sync.WaitGroup
+		for _, p := range []Priority{PriorityLow, PriorityNormal, PriorityHigh} {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				release, err := l.Wait(context.Background(), Request{Model: "m", MaxConcurrent: 1, QueueSize: 3, Priority: p})
+				Expect(err).NotTo(HaveOccurred())
+				order <- p
+				release()
+			}()
+			queued(l, int(p)+1)
+		}
+		r1()
+		wg.Wait()
+		close(order)
+		var got []Priority
+		for p := range order {
+			got = append(got, p)
+		}
+		Expect(got).To(Equal([]Priority{PriorityHigh, PriorityNormal, PriorityLow}))
+	})
+
+	It("shares slots fairly across users of the same class", func() {
+		l := New()
+		// "batch" holds one of the two slots; "alice" queued after
+		// "batch" asked for a second one, but gets the freed slot.
+		batch, err := l.Wait(context.Background(), Request{Model: "m", MaxConcurrent: 2, User: "batch"})
+		Expect(err).NotTo(HaveOccurred())
+		defer batch()
+		other, err := l.Wait(context.Background(), Request{Model: "m", MaxConcurrent: 2, User: "bob"})
+		Expect(err).NotTo(HaveOccurred())
+
+		order := make(chan string, 2)
+		var wg sync.WaitGroup
+		for i, user := range []string{"batch", "alice"} {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				release, err := l.Wait(context.Background(), Request{Model: "m", MaxConcurrent: 2, QueueSize: 2, User: user})
+				Expect(err).NotTo(HaveOccurred())
+				order <- user
+				release()
+			}()
+			queued(l, i+1)
+		}
+
+		other()
+		wg.Wait()
+		Expect(<-order).To(Equal("alice"))
+		Expect(<-order).To(Equal("batch"))
+	})
+
+	It("gives up at the queue deadline", func() {
+		l := New()
+		r1, _ := l.Acquire("m", 1)
+		defer r1()
+		start := time.Now()
+		_, err := l.Wait(context.Background(), Request{Model: "m", MaxConcurrent: 1, QueueSize: 1, Timeout: 50 * time.Millisecond})
+		Expect(err).To(MatchError(ErrQueueTimeout))
+		Expect(time.Since(start)).To(BeNumerically(">=", 50*time.Millisecond))
+		Expect(l.Queued("m")).To(Equal(0))
+	})
+
+	It("leaves the queue when the context is canceled", func() {
+		l := New()
+		r1, _ := l.Acquire("m", 1)
+		defer r1()
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error, 1)
+		go func() {
+			_, err := l.Wait(ctx, Request{Model: "m", MaxConcurrent: 1, QueueSize: 1})
+			done <- err
+		}()
+		queued(l, 1)
+		cancel()
+		Eventually(done).Should(Receive(MatchError(context.Canceled)))
+		Expect(l.Queued("m")).To(Equal(0))
+	})
+
+	It("evicts a lower priority waiter from a full queue", func() {
+		l := New()
+		r1, _ := l.Acquire("m", 1)
+		low := waitAsync(l, Request{Model: "m", MaxConcurrent: 1, QueueSize: 1, Priority: PriorityLow})
+		queued(l, 1)
+
+		_, err := l.Wait(context.Background(), Request{Model: "m", MaxConcurrent: 1, QueueSize: 1, Priority: PriorityLow, Timeout: time.Millisecond})
+		Expect(err).To(MatchError(ErrQueueFull), "same class must not evict")
+
+		high := waitAsync(l, Request{Model: "m", MaxConcurrent: 1, QueueSize: 1, Priority: PriorityHigh})
+		Eventually(low).Should(Receive(MatchError(ErrEvicted)))
+		r1()
+		Eventually(high).Should(Receive(BeNil()))
+	})
+})
+
+var _ = Describe("ParsePriority", func() {
+	It("round-trips the class names and defaults to normal", func() {
+		for _, p := range []Priority{PriorityLow, PriorityNormal, PriorityHigh} {
+			Expect(ParsePriority(p.String())).To(Equal(p))
+		}
+		Expect(ParsePriority("")).To(Equal(PriorityNormal))
+		Expect(ParsePriority("urgent")).To(Equal(PriorityNormal))
+	})
+})
+
 var _ = Describe("RetryAfter", func() {
 	It("defaults to one second", func() {
 		Expect(RetryAfter(0)).To(Equal(time.Second))
 		Expect(RetryAfter(5)).To(Equal(5 * time.Second))
 	})
 })
+
+var _ = Describe("QueueTimeout", func() {
+	It("defaults to thirty seconds", func() {
+		Expect(QueueTimeout(0)).To(Equal(30 * time.Second))
+		Expect(QueueTimeout(5)).To(Equal(5 * time.Second))
+	})
+})
@@ -0,0 +1,102 @@
+package admission
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/mudler/xlog"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+var (
+	metricsOnce       sync.Once
+	queueDepthCounter metric.Int64UpDownCounter
+	queueWaitHist     metric.Float64Histogram
+	rejectedCounter   metric.Int64Counter
+
+	// configuredMeter mirrors billing.SetMeter: the queue metrics
+	// must land on the MeterProvider that exports /metrics.
+	configuredMeterMu sync.Mutex
+	configuredMeter   metric.Meter
+)
+
+// SetMeter wires the meter from monitoring.LocalAIMetricsService
+// before the first request is admitted.
+func SetMeter(m metric.Meter) {
+	configuredMeterMu.Lock()
+	defer configuredMeterMu.Unlock()
+	configuredMeter = m
+}
+
+func resolveMeter() metric.Meter {
+	configuredMeterMu.Lock()
+	m := configuredMeter
+	configuredMeterMu.Unlock()
+	if m != nil {
+		return m
+	}
+	return otel.Meter("github.com/mudler/LocalAI/core/services/routing/admission")
+}
+
+func initMetrics() {
+	metricsOnce.Do(func() {
+		meter := resolveMeter()
+		var err error
+		queueDepthCounter, err = meter.Int64UpDownCounter(
+			"localai_admission_queue_depth",
+			metric.WithDescription("Requests waiting for an admission slot, labeled by model"),
+		)
+		if err != nil {
+			xlog.Error("admission: failed to create queue depth gauge", "error", err)
+		}
+		queueWaitHist, err = meter.Float64Histogram(
+			"localai_admission_queue_wait_seconds",
+			metric.WithDescription("Time spent waiting for an admission slot, labeled by model, priority and outcome (admitted, timeout, evicted, canceled)"),
+			metric.WithUnit("s"),
+		)
+		if err != nil {
+			xlog.Error("admission: failed to create queue wait histogram", "error", err)
+		}
+		rejectedCounter, err = meter.Int64Counter(
+			"localai_admission_rejected_total",
+			metric.WithDescription("Requests rejected by admission control, labeled by model and reason (full, timeout, evicted)"),
+		)
+		if err != nil {
+			xlog.Error("admission: failed to create rejections counter", "error", err)
+		}
+	})
+}
+
+func queueDepthChanged(model string, delta int64) {
+	initMetrics()
+	if queueDepthCounter == nil {
+		return
+	}
+	queueDepthCounter.Add(context.Background(), delta, metric.WithAttributes(attribute.String("model", model)))
+}
+
+func recordWait(model string, priority Priority, outcome string, d time.Duration) {
+	initMetrics()
+	if queueWaitHist == nil {
+		return
+	}
+	queueWaitHist.Record(context.Background(), d.Seconds(), metric.WithAttributes(
+		attribute.String("model", model),
+		attribute.String("priority", priority.String()),
+		attribute.String("outcome", outcome),
+	))
+}
+
+func recordRejection(model, reason string) {
+	initMetrics()
+	if rejectedCounter == nil {
+		return
+	}
+	rejectedCounter.Add(context.Background(), 1, metric.WithAttributes(
+		attribute.String("model", model),
+		attribute.String("reason", reason),
+	))
+}
@@ -95,6 +95,7 @@ func (b *memoryBackend) Aggregate(_ context.Context, q AggregateQuery) ([]auth.U
 		entry.PromptTokens += r.PromptTokens
 		entry.CompletionTokens += r.CompletionTokens
 		entry.TotalTokens += r.TotalTokens
+		entry.Cost += r.CostUSD
 		entry.RequestCount++
 	}
 
@@ -0,0 +1,68 @@
+package billing
+
+import (
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/http/auth"
+)
+
+// PriceSource resolves the price table of a served model. ok is false
+// when the model is unknown or has no rates; the record is then stored
+// unpriced (CostUSD 0, empty PricingVersionID) rather than as free.
+type PriceSource interface {
+	Pricing(model string) (config.PricingConfig, bool)
+}
+
+// ModelConfigPrices reads price tables from the `pricing:` block of the
+// loaded model configs. Lookups go through the loader on every record,
+// so an edited config takes effect for the next request without a
+// restart. An alias without its own table is priced as its target.
+func ModelConfigPrices(cl *config.ModelConfigLoader) PriceSource {
+	return modelConfigPrices{cl: cl}
+}
+
+type modelConfigPrices struct {
+	cl *config.ModelConfigLoader
+}
+
+func (p modelConfigPrices) Pricing(model string) (config.PricingConfig, bool) {
+	cfg, ok := p.cl.GetModelConfig(model)
+	if !ok {
+		return config.PricingConfig{}, false
+	}
+	if !cfg.Pricing.IsSet() && cfg.IsAlias() {
+		if cfg, ok = p.cl.GetModelConfig(cfg.Alias); !ok {
+			return config.PricingConfig{}, false
+		}
+	}
+	return cfg.Pricing, cfg.Pricing.IsSet()
+}
+
+// RecorderOption configures a Recorder.
+type RecorderOption func(*Recorder)
+
+// WithPrices makes the Recorder price every record it has no pricing
+// version for, using the served model's table from src.
+func WithPrices(src PriceSource) RecorderOption {
+	return func(rec *Recorder) { rec.prices = src }
+}
+
+// applyPricing fills CostUSD and PricingVersionID from the served
+// model's price table. A record that already carries a pricing version
+// was priced upstream and is left alone.
+func (rec *Recorder) applyPricing(r *auth.UsageRecord) {
+	if rec.prices == nil || r.PricingVersionID != "" {
+		return
+	}
+	p, ok := rec.prices.Pricing(servedModelOf(r))
+	if !ok {
+		return
+	}
+	r.CostUSD = p.Cost(config.BillableUsage{
+		PromptTokens:     r.PromptTokens,
+		CompletionTokens: r.CompletionTokens,
+		CachedTokens:     r.CachedTokens,
+		AudioSeconds:     r.AudioSeconds,
+		Images:           r.Images,
+	})
+	r.PricingVersionID = p.Version()
+}
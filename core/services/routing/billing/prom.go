@@ -25,6 +25,7 @@ import (
 // extra defense in depth.
 type Recorder struct {
 	backend StatsBackend
+	prices  PriceSource
 
 	tokensCounter metric.Int64Counter
 	costCounter   metric.Float64Counter
@@ -32,11 +33,11 @@ type Recorder struct {
 }
 
 var (
-	metricsOnce              sync.Once
-	sharedTokensCounter      metric.Int64Counter
-	sharedCostCounter        metric.Float64Counter
-	sharedRequestsCount      metric.Int64Counter
-	sharedUnrecordedCounter  metric.Int64Counter
+	metricsOnce             sync.Once
+	sharedTokensCounter     metric.Int64Counter
+	sharedCostCounter       metric.Float64Counter
+	sharedRequestsCount     metric.Int64Counter
+	sharedUnrecordedCounter metric.Int64Counter
 
 	// configuredMeter is the meter handed in by the caller (typically
 	// monitoring.LocalAIMetricsService). Setting it before initMetrics
@@ -124,20 +125,26 @@ func CountUnrecorded(ctx context.Context, endpoint, reason string) {
 // and to Prometheus. The Prom counters are package-singletons so that
 // multiple Recorders (e.g., reusing the same metrics across rebuilds)
 // don't double-register identical metric names.
-func NewRecorder(backend StatsBackend) *Recorder {
+func NewRecorder(backend StatsBackend, opts ...RecorderOption) *Recorder {
 	initMetrics()
-	return &Recorder{
+	rec := &Recorder{
 		backend:       backend,
 		tokensCounter: sharedTokensCounter,
 		costCounter:   sharedCostCounter,
 		requestsCount: sharedRequestsCount,
 	}
+	for _, opt := range opts {
+		opt(rec)
+	}
+	return rec
 }
 
-// Record asserts billing invariants, persists the record, and emits the
-// matching Prom counters. r must not be mutated by the caller after
-// this call; the backend takes ownership.
+// Record prices the record when a PriceSource is configured, asserts
+// billing invariants, persists the record, and emits the matching Prom
+// counters. r must not be mutated by the caller after this call; the
+// backend takes ownership.
 func (rec *Recorder) Record(ctx context.Context, r *auth.UsageRecord) error {
+	rec.applyPricing(r)
 	rec.assertInvariants(r)
 
 	if err := rec.backend.Record(ctx, r); err != nil {
@@ -4,6 +4,7 @@ import (
 	"context"
 	"sync"
 
+	"github.com/mudler/LocalAI/core/config"
 	"github.com/mudler/LocalAI/core/http/auth"
 
 	. "github.com/onsi/ginkgo/v2"
@@ -29,6 +30,14 @@ func (f *fakeBackend) Aggregate(_ context.Context, _ AggregateQuery) ([]auth.Usa
 }
 func (f *fakeBackend) Close() error { return nil }
 
+// staticPrices is a PriceSource backed by a fixed map.
+type staticPrices map[string]config.PricingConfig
+
+func (s staticPrices) Pricing(model string) (config.PricingConfig, bool) {
+	p, ok := s[model]
+	return p, ok
+}
+
 var _ = Describe("Recorder", func() {
 	It("forwards to backend", func() {
 		fb := &fakeBackend{}
@@ -79,4 +88,30 @@ var _ = Describe("Recorder", func() {
 		})
 		Expect(err).NotTo(HaveOccurred(), "non-strict build must not error on invariant violation")
 	})
+
+	It("prices records by the served model", func() {
+		prices := staticPrices{"qwen-7b": {InputPerMillion: 1, OutputPerMillion: 4, PerAudioSecond: 0.01}}
+		rec := NewRecorder(&fakeBackend{}, WithPrices(prices))
+
+		r := &auth.UsageRecord{
+			UserID: "u-1", Model: "fast", ServedModel: "qwen-7b",
+			PromptTokens: 2_000_000, CompletionTokens: 500_000, AudioSeconds: 10,
+		}
+		Expect(rec.Record(context.Background(), r)).To(Succeed())
+		Expect(r.CostUSD).To(BeNumerically("~", 2+2+0.1, 1e-9))
+		Expect(r.PricingVersionID).To(Equal(prices["qwen-7b"].Version()))
+	})
+
+	It("leaves unpriced models and upstream-priced records alone", func() {
+		rec := NewRecorder(&fakeBackend{}, WithPrices(staticPrices{"qwen-7b": {InputPerMillion: 1}}))
+
+		unknown := &auth.UsageRecord{UserID: "u-1", Model: "other", PromptTokens: 1_000_000}
+		Expect(rec.Record(context.Background(), unknown)).To(Succeed())
+		Expect(unknown.CostUSD).To(BeZero())
+		Expect(unknown.PricingVersionID).To(BeEmpty())
+
+		priced := &auth.UsageRecord{UserID: "u-1", Model: "qwen-7b", PromptTokens: 1_000_000, CostUSD: 0.5, PricingVersionID: "upstream"}
+		Expect(rec.Record(context.Background(), priced)).To(Succeed())
+		Expect(priced.CostUSD).To(Equal(0.5))
+	})
 })
@@ -43,6 +43,12 @@ type ModelPIIConfig interface {
 	PIIDetectors() []string
 }
 
+// modelIDConfig names the model of the per-model configuration, for
+// the Model of the recorded events. *config.ModelConfig satisfies it.
+type modelIDConfig interface {
+	ModelID() string
+}
+
 // NERDetectorResolver resolves a detector model name to a ready-to-use
 // NERConfig — the detector plus the policy (min score, entity→action
 // map, default action) read from that model's own pii_detection block.
@@ -104,9 +110,21 @@ type ScannedText struct {
 // every span the redactor returned. updates are guaranteed to share
 // indices the adapter previously returned from Scan; the adapter
 // must not assume input order matches scan order.
+//
+// The response-side fields serve models with pii.scan_responses on.
+// ScanResponse pulls the generated text out of a decoded response
+// document — a whole body or one streamed event — and ApplyResponse
+// writes masked text back into it. ResponseError renders the event that
+// ends a stream blocked mid-way; nil sends an OpenAI-style error event
+// (SSE) or an {"error": ...} line (NDJSON). An adapter without
+// ScanResponse leaves responses unscanned.
 type Adapter struct {
 	Scan  func(parsed any) []ScannedText
 	Apply func(parsed any, updates []ScannedText)
+
+	ScanResponse  func(doc map[string]any) []ScannedText
+	ApplyResponse func(doc map[string]any, updates []ScannedText)
+	ResponseError func(errType, message string) []byte
 }
 
 // RequestMiddleware applies the regex PII tier to incoming chat
@@ -122,6 +140,13 @@ type Adapter struct {
 //   - On match with action=allow: the original text is left intact; a
 //     PIIEvent is still recorded so the detection is auditable.
 //
+// Models with pii.scan_responses on also have the generated response
+// scanned with the same detectors (see responseScanner), with events
+// tagged DirectionOut. A block there ends the response: a buffered body
+// is replaced by the 400, a stream is cut off with an error event.
+// The scan sees the response before reversible pseudonyms are restored,
+// so the values the client sent itself are never flagged on the way back.
+//
 // recorder is the Recorder on which to record events; nil disables
 // recording (the redaction still happens). fallbackUser supplies the
 // no-auth identity. The middleware writes ctxKeyPIIEventID on the echo
@@ -176,6 +201,10 @@ func RequestMiddleware(redactor *Redactor, store EventStore, adapter Adapter, fa
 				userID = user.ID
 			}
 			correlationID, _ := c.Get(ctxKeyCorrelationID).(string)
+			var model string
+			if cfg, ok := rawCfg.(modelIDConfig); ok {
+				model = cfg.ModelID()
+			}
 
 			// Resolve each named detector to its NERConfig (detector +
 			// the policy from that model's own pii_detection block). A
@@ -187,7 +216,7 @@ func RequestMiddleware(redactor *Redactor, store EventStore, adapter Adapter, fa
 				nc, ok := o.nerResolver(name)
 				if !ok {
 					xlog.Error("pii: configured detector model could not be resolved; blocking request (fail-closed)", "detector", name)
-					return blockNERUnavailable(c, store, correlationID, userID)
+					return blockNERUnavailable(c, store, correlationID, userID, model)
 				}
 				cfgs = append(cfgs, nc)
 			}
@@ -222,7 +251,7 @@ func RequestMiddleware(redactor *Redactor, store EventStore, adapter Adapter, fa
 			segResults, nerErr := RedactNERSegments(c.Request().Context(), segTexts, cfgs)
 			if nerErr != nil {
 				xlog.Error("pii: NER detector failed; blocking request (fail-closed)", "error", nerErr)
-				return blockNERUnavailable(c, store, correlationID, userID)
+				return blockNERUnavailable(c, store, correlationID, userID, model)
 			}
 
 			for i, res := range segResults {
@@ -241,6 +270,7 @@ func RequestMiddleware(redactor *Redactor, store EventStore, adapter Adapter, fa
 						Origin:        OriginMiddleware,
 						CorrelationID: correlationID,
 						UserID:        userID,
+						Model:         model,
 						Direction:     DirectionIn,
 						PatternID:     span.Pattern,
 						ByteOffset:    span.Start,
@@ -293,14 +323,35 @@ func RequestMiddleware(redactor *Redactor, store EventStore, adapter Adapter, fa
 			if firstEventID != "" {
 				c.Set(ctxKeyPIIEventID, firstEventID)
 			}
-			if len(pseudonyms.original) == 0 {
+			var restoring *restoringWriter
+			if len(pseudonyms.original) > 0 {
+				restoring = newRestoringWriter(c.Response().Writer, pseudonyms.original)
+				c.Response().Writer = restoring
+			}
+			var scanner *responseScanner
+			if cfg, ok := rawCfg.(responseScanConfig); ok && cfg.PIIScanResponses() && adapter.ScanResponse != nil && adapter.ApplyResponse != nil {
+				scanner = newResponseScanner(c.Request().Context(), c.Response().Writer, adapter, cfgs, store, correlationID, userID, model)
+				c.Response().Writer = scanner
+			}
+			if restoring == nil && scanner == nil {
 				return next(c)
 			}
-			writer := newRestoringWriter(c.Response().Writer, pseudonyms.original)
-			c.Response().Writer = writer
 			err := next(c)
-			if finishErr := writer.Finish(); err == nil {
-				err = finishErr
+			if scanner != nil {
+				if finishErr := scanner.Finish(); err == nil {
+					err = finishErr
+				}
+				if scanner.blockedStatus != 0 {
+					c.Response().Status = scanner.blockedStatus
+				}
+				if firstEventID == "" && scanner.firstEventID != "" {
+					c.Set(ctxKeyPIIEventID, scanner.firstEventID)
+				}
+			}
+			if restoring != nil {
+				if finishErr := restoring.Finish(); err == nil {
+					err = finishErr
+				}
 			}
 			return err
 		}
@@ -322,13 +373,14 @@ const nerUnavailablePattern = "__ner_unavailable__"
 // request is safer than serving it with only the cheap regex tier. The
 // 503 (vs the 400 used for a content block) tells clients and operators
 // this was a dependency outage, not sensitive data in the request.
-func blockNERUnavailable(c echo.Context, store EventStore, correlationID, userID string) error {
+func blockNERUnavailable(c echo.Context, store EventStore, correlationID, userID, model string) error {
 	ev := PIIEvent{
 		ID:            newEventID(),
 		Kind:          KindPII,
 		Origin:        OriginMiddleware,
 		CorrelationID: correlationID,
 		UserID:        userID,
+		Model:         model,
 		Direction:     DirectionIn,
 		PatternID:     nerUnavailablePattern,
 		Action:        ActionBlock,
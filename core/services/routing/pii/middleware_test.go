@@ -63,6 +63,7 @@ type fakeModelPIIConfig struct {
 	reverse   bool
 	prefix    string
 	suffix    string
+	scan      bool
 }
 
 func (f fakeModelPIIConfig) PIIIsEnabled() bool               { return f.enabled }
@@ -70,6 +71,7 @@ func (f fakeModelPIIConfig) PIIDetectors() []string           { return f.detecto
 func (f fakeModelPIIConfig) PIIReversibleRedactions() bool    { return f.reverse }
 func (f fakeModelPIIConfig) PIIReversibleTokenPrefix() string { return f.prefix }
 func (f fakeModelPIIConfig) PIIReversibleTokenSuffix() string { return f.suffix }
+func (f fakeModelPIIConfig) PIIScanResponses() bool           { return f.scan }
 
 func withModelConfig(cfg fakeModelPIIConfig) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
@@ -0,0 +1,603 @@
+package pii
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/mudler/xlog"
+)
+
+// responseScanConfig is the duck-typed view of the per-model opt-in for
+// output-direction scanning. *config.ModelConfig satisfies it via
+// PIIScanResponses.
+type responseScanConfig interface {
+	PIIScanResponses() bool
+}
+
+// responseLookBehind is how many bytes of generated text a streamed
+// response holds back before releasing it to the client. A span the
+// detector finds within the held tail may still grow with the next
+// tokens, so only spans starting before it are acted on; the tail is
+// rescanned with what follows. Entities longer than this can still be
+// split across two scans — 256 bytes covers every PII shape the
+// detectors report (emails, card numbers, keys) with room to spare.
+const responseLookBehind = 256
+
+type responseMode int
+
+const (
+	responseUndecided responseMode = iota
+	// responsePassthrough: error statuses, encoded bodies and content
+	// types the adapters don't parse go out untouched.
+	responsePassthrough
+	// responseWhole: a JSON body, buffered and scanned once complete.
+	responseWhole
+	// responseSSE / responseNDJSON: streamed events, scanned through the
+	// look-behind window.
+	responseSSE
+	responseNDJSON
+)
+
+// responseEvent is one streamed event: an SSE event (head carries the
+// lines before the data payload, e.g. "event: ...\ndata: ") or an NDJSON
+// line. texts are the adapter's generated-text fields of the decoded
+// payload, rewritten in place when a span is masked.
+type responseEvent struct {
+	head, payload, tail []byte
+	doc                 map[string]any
+	texts               []ScannedText
+	changed             bool
+}
+
+func (e *responseEvent) textLen() int {
+	n := 0
+	for _, t := range e.texts {
+		n += len(t.Text)
+	}
+	return n
+}
+
+func (e *responseEvent) bytes(adapter Adapter) []byte {
+	payload := e.payload
+	if e.changed {
+		adapter.ApplyResponse(e.doc, e.texts)
+		if encoded, err := encodeResponseDoc(e.doc); err == nil {
+			payload = encoded
+		} else {
+			xlog.Error("pii: failed to re-encode scanned response event", "error", err)
+		}
+	}
+	out := make([]byte, 0, len(e.head)+len(payload)+len(e.tail))
+	out = append(out, e.head...)
+	out = append(out, payload...)
+	return append(out, e.tail...)
+}
+
+// responseScanner scans what the handler writes for PII before it
+// reaches the client. The mode is picked from the Content-Type when the
+// handler starts writing: JSON bodies are buffered whole, SSE and NDJSON
+// streams are split into events and scanned through a look-behind window
+// so a span crossing two chunks is still caught.
+type responseScanner struct {
+	http.ResponseWriter
+	ctx     context.Context
+	adapter Adapter
+	cfgs    []NERConfig
+	store   EventStore
+
+	correlationID string
+	userID        string
+	model         string
+	// firstEventID is the first PIIEvent recorded on the response, and
+	// blockedStatus the status that replaced the handler's when a whole
+	// body was blocked; the middleware copies both onto the echo context.
+	firstEventID  string
+	blockedStatus int
+
+	mode    responseMode
+	status  int
+	buf     []byte
+	pending []*responseEvent
+	// pendingLen is the generated text held in pending, scanAt the
+	// length at which it is scanned next, and offset the text already
+	// released (the stream offset recorded on events).
+	pendingLen int
+	scanAt     int
+	offset     int
+	blocked    bool
+}
+
+func newResponseScanner(ctx context.Context, w http.ResponseWriter, adapter Adapter, cfgs []NERConfig, store EventStore, correlationID, userID, model string) *responseScanner {
+	return &responseScanner{
+		ResponseWriter: w,
+		ctx:            ctx,
+		adapter:        adapter,
+		cfgs:           cfgs,
+		store:          store,
+		correlationID:  correlationID,
+		userID:         userID,
+		model:          model,
+		scanAt:         2 * responseLookBehind,
+	}
+}
+
+func (w *responseScanner) decide() {
+	if w.mode != responseUndecided {
+		return
+	}
+	h := w.Header()
+	ct := strings.ToLower(h.Get("Content-Type"))
+	encoding := h.Get("Content-Encoding")
+	switch {
+	case w.status >= http.StatusMultipleChoices, encoding != "" && encoding != "identity":
+		w.mode = responsePassthrough
+	case strings.HasPrefix(ct, "text/event-stream"):
+		w.mode = responseSSE
+	case strings.HasPrefix(ct, "application/x-ndjson"):
+		w.mode = responseNDJSON
+	case ct == "" || strings.Contains(ct, "json"):
+		w.mode = responseWhole
+	default:
+		w.mode = responsePassthrough
+	}
+	if w.mode != responseWhole && w.status != 0 {
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+}
+
+func (w *responseScanner) WriteHeader(code int) {
+	if w.mode != responseUndecided {
+		if w.mode != responseWhole {
+			w.ResponseWriter.WriteHeader(code)
+		} else {
+			w.status = code
+		}
+		return
+	}
+	w.status = code
+	w.decide()
+}
+
+func (w *responseScanner) Write(data []byte) (int, error) {
+	w.decide()
+	if w.mode == responsePassthrough {
+		return w.ResponseWriter.Write(data)
+	}
+	if w.blocked {
+		// The stream was cut off: the rest of it is dropped.
+		return len(data), nil
+	}
+	w.buf = append(w.buf, data...)
+	if w.mode == responseWhole {
+		return len(data), nil
+	}
+	sep := []byte("\n\n")
+	if w.mode == responseNDJSON {
+		sep = []byte("\n")
+	}
+	for !w.blocked {
+		i := bytes.Index(w.buf, sep)
+		if i < 0 {
+			break
+		}
+		raw := bytes.Clone(w.buf[:i+len(sep)])
+		w.buf = w.buf[i+len(sep):]
+		if err := w.push(w.parseEvent(raw)); err != nil {
+			return 0, err
+		}
+	}
+	return len(data), nil
+}
+
+// Flush forwards for streams only: a buffered body has not been written
+// yet, and flushing would send its status line.
+func (w *responseScanner) Flush() {
+	w.decide()
+	if w.mode == responseWhole {
+		return
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *responseScanner) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Finish scans and releases whatever the handler left buffered: the
+// whole body, or the stream's held tail. Anything written afterwards
+// (echo's error handler) passes through.
+func (w *responseScanner) Finish() error {
+	defer func() { w.mode = responsePassthrough }()
+	switch w.mode {
+	case responseWhole:
+		return w.finishWhole()
+	case responseSSE, responseNDJSON:
+		if w.blocked {
+			return nil
+		}
+		if len(w.buf) > 0 {
+			raw := w.buf
+			w.buf = nil
+			if err := w.push(w.parseEvent(raw)); err != nil {
+				return err
+			}
+		}
+		if w.blocked {
+			return nil
+		}
+		return w.scan(true)
+	}
+	return nil
+}
+
+// parseEvent splits raw into the event's framing and its JSON payload,
+// and collects the payload's generated text. Events without a JSON
+// object payload ("data: [DONE]", comments) carry no text.
+func (w *responseScanner) parseEvent(raw []byte) *responseEvent {
+	ev := &responseEvent{payload: raw}
+	if w.mode == responseSSE {
+		start := -1
+		if bytes.HasPrefix(raw, []byte("data:")) {
+			start = 0
+		} else if i := bytes.Index(raw, []byte("\ndata:")); i >= 0 {
+			start = i + 1
+		}
+		if start < 0 {
+			return ev
+		}
+		start += len("data:")
+		if start < len(raw) && raw[start] == ' ' {
+			start++
+		}
+		end := start + bytes.IndexByte(raw[start:], '\n')
+		if end < start {
+			end = len(raw)
+		}
+		ev.head, ev.payload, ev.tail = raw[:start], raw[start:end], raw[end:]
+	} else {
+		trimmed := bytes.TrimRight(raw, "\r\n")
+		ev.payload, ev.tail = trimmed, raw[len(trimmed):]
+	}
+	if len(ev.payload) == 0 || ev.payload[0] != '{' {
+		return ev
+	}
+	doc, err := decodeResponseDoc(ev.payload)
+	if err != nil {
+		return ev
+	}
+	ev.doc = doc
+	for _, t := range w.adapter.ScanResponse(doc) {
+		if t.Text != "" {
+			ev.texts = append(ev.texts, t)
+		}
+	}
+	return ev
+}
+
+func (w *responseScanner) push(ev *responseEvent) error {
+	w.pending = append(w.pending, ev)
+	w.pendingLen += ev.textLen()
+	if w.pendingLen == 0 {
+		// Nothing held back: events without text go straight out.
+		return w.release(len(w.pending))
+	}
+	if w.pendingLen >= w.scanAt {
+		return w.scan(false)
+	}
+	return nil
+}
+
+// scan runs the detectors over the text held in pending, as one
+// document so a span split across events is seen whole. Unless final,
+// the last responseLookBehind bytes are held back: spans starting in
+// them are left for the next scan, and only the events wholly before
+// them are released.
+func (w *responseScanner) scan(final bool) error {
+	type segment struct {
+		ev    *responseEvent
+		i     int
+		start int
+	}
+	var segs []segment
+	var joined strings.Builder
+	for _, ev := range w.pending {
+		for i, t := range ev.texts {
+			segs = append(segs, segment{ev: ev, i: i, start: joined.Len()})
+			joined.WriteString(t.Text)
+		}
+	}
+	text := joined.String()
+	boundary := len(text)
+	if !final {
+		boundary -= responseLookBehind
+	}
+
+	res, err := RedactNER(w.ctx, text, w.cfgs)
+	if err != nil {
+		xlog.Error("pii: NER detector failed on a response; blocking it (fail-closed)", "error", err)
+		w.recordUnavailable()
+		return w.blockStream("response blocked: PII NER check is configured but unavailable", "pii_ner_unavailable")
+	}
+	var spans []Span
+	for _, span := range res.Spans {
+		if span.Start >= boundary {
+			break
+		}
+		spans = append(spans, span)
+	}
+	var blocked bool
+	for _, span := range spans {
+		w.record(span, w.offset+span.Start)
+		if span.Action == ActionBlock {
+			blocked = true
+		}
+	}
+	if blocked {
+		return w.blockStream("response blocked by content policy (sensitive data detected)", "pii_blocked")
+	}
+
+	// Rewrite the masked spans: the placeholder goes where the span
+	// starts, and the rest of it is cut from the segments it runs into.
+	for _, seg := range segs {
+		t := seg.ev.texts[seg.i].Text
+		end := seg.start + len(t)
+		var b strings.Builder
+		cursor := seg.start
+		changed := false
+		for _, span := range spans {
+			if span.Action != ActionMask || span.End <= seg.start || span.Start >= end {
+				continue
+			}
+			changed = true
+			b.WriteString(text[cursor:max(span.Start, seg.start)])
+			if span.Start >= seg.start {
+				b.WriteString(maskFor(span.Pattern))
+			}
+			cursor = min(span.End, end)
+		}
+		if changed {
+			b.WriteString(text[cursor:end])
+			seg.ev.texts[seg.i].Text = b.String()
+			seg.ev.changed = true
+		}
+	}
+
+	// Release the events that end before the boundary; a masked span
+	// reaching past it has already been cut from the later segments.
+	n := 0
+	cursor := 0
+	for _, ev := range w.pending {
+		for _, t := range ev.texts {
+			cursor += len(t.Text)
+		}
+		if !final && cursor > boundary {
+			break
+		}
+		n++
+	}
+	if err := w.release(n); err != nil {
+		return err
+	}
+	w.scanAt = w.pendingLen + responseLookBehind
+	if w.scanAt < 2*responseLookBehind {
+		w.scanAt = 2 * responseLookBehind
+	}
+	return nil
+}
+
+// release writes the first n pending events to the client.
+func (w *responseScanner) release(n int) error {
+	if n == 0 {
+		return nil
+	}
+	for _, ev := range w.pending[:n] {
+		w.offset += ev.textLen()
+		if _, err := w.ResponseWriter.Write(ev.bytes(w.adapter)); err != nil {
+			return err
+		}
+	}
+	w.pending = append(w.pending[:0:0], w.pending[n:]...)
+	w.pendingLen = 0
+	for _, ev := range w.pending {
+		w.pendingLen += ev.textLen()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+	return nil
+}
+
+// blockStream ends a stream whose headers are already out: the held
+// events are dropped, the adapter's error event is sent in their place
+// and the rest of the stream is swallowed.
+func (w *responseScanner) blockStream(message, errType string) error {
+	w.blocked = true
+	w.pending, w.buf, w.pendingLen = nil, nil, 0
+	var event []byte
+	switch {
+	case w.adapter.ResponseError != nil:
+		event = w.adapter.ResponseError(errType, message)
+	case w.mode == responseNDJSON:
+		encoded, _ := json.Marshal(map[string]string{"error": message})
+		event = append(encoded, '\n')
+	default:
+		encoded, _ := json.Marshal(map[string]any{
+			"error": map[string]string{"message": message, "type": errType},
+		})
+		event = []byte("data: " + string(encoded) + "\n\ndata: [DONE]\n\n")
+	}
+	if _, err := w.ResponseWriter.Write(event); err != nil {
+		return err
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+	return nil
+}
+
+func (w *responseScanner) finishWhole() error {
+	body := w.buf
+	w.buf = nil
+	status := w.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	passthrough := func() error {
+		w.ResponseWriter.WriteHeader(status)
+		if len(body) == 0 {
+			return nil
+		}
+		_, err := w.ResponseWriter.Write(body)
+		return err
+	}
+	if len(body) == 0 {
+		return passthrough()
+	}
+	doc, err := decodeResponseDoc(body)
+	if err != nil {
+		return passthrough()
+	}
+	var texts []ScannedText
+	for _, t := range w.adapter.ScanResponse(doc) {
+		if t.Text != "" {
+			texts = append(texts, t)
+		}
+	}
+	if len(texts) == 0 {
+		return passthrough()
+	}
+
+	segTexts := make([]string, len(texts))
+	for i, t := range texts {
+		segTexts[i] = t.Text
+	}
+	results, err := RedactNERSegments(w.ctx, segTexts, w.cfgs)
+	if err != nil {
+		xlog.Error("pii: NER detector failed on a response; blocking it (fail-closed)", "error", err)
+		w.recordUnavailable()
+		return w.blockWhole(http.StatusServiceUnavailable, "response blocked: PII NER check is configured but unavailable", "pii_ner_unavailable")
+	}
+	var updates []ScannedText
+	var blocked bool
+	for i, res := range results {
+		for _, span := range res.Spans {
+			w.record(span, span.Start)
+		}
+		blocked = blocked || res.Blocked
+		if res.Masked {
+			updates = append(updates, ScannedText{Index: texts[i].Index, Text: res.Redacted})
+		}
+	}
+	if blocked {
+		return w.blockWhole(http.StatusBadRequest, "response blocked by content policy (sensitive data detected)", "pii_blocked")
+	}
+	if len(updates) == 0 {
+		return passthrough()
+	}
+	w.adapter.ApplyResponse(doc, updates)
+	encoded, err := encodeResponseDoc(doc)
+	if err != nil {
+		return err
+	}
+	if bytes.HasSuffix(body, []byte("\n")) {
+		encoded = append(encoded, '\n')
+	}
+	body = encoded
+	w.Header().Del("Content-Length")
+	return passthrough()
+}
+
+// blockWhole replaces a buffered body with the same error document the
+// request side answers a blocked request with.
+func (w *responseScanner) blockWhole(status int, message, errType string) error {
+	w.blockedStatus = status
+	h := w.Header()
+	h.Del("Content-Length")
+	h.Set("Content-Type", "application/json; charset=UTF-8")
+	body, err := json.Marshal(map[string]any{
+		"error":          map[string]string{"message": message, "type": errType},
+		"correlation_id": w.correlationID,
+		"pii_event_id":   w.firstEventID,
+	})
+	if err != nil {
+		return err
+	}
+	w.ResponseWriter.WriteHeader(status)
+	_, err = w.ResponseWriter.Write(body)
+	return err
+}
+
+// record persists one output-direction event; offset is relative to the
+// generated text (the stream so far, or the scanned field).
+func (w *responseScanner) record(span Span, offset int) {
+	w.persist(PIIEvent{
+		ID:            newEventID(),
+		Origin:        OriginMiddleware,
+		CorrelationID: w.correlationID,
+		UserID:        w.userID,
+		Model:         w.model,
+		Direction:     DirectionOut,
+		PatternID:     span.Pattern,
+		ByteOffset:    offset,
+		Length:        span.End - span.Start,
+		HashPrefix:    span.HashPrefix,
+		Action:        span.Action,
+		Score:         span.Score,
+		CreatedAt:     time.Now().UTC(),
+	})
+}
+
+func (w *responseScanner) recordUnavailable() {
+	w.persist(PIIEvent{
+		ID:            newEventID(),
+		Kind:          KindPII,
+		Origin:        OriginMiddleware,
+		CorrelationID: w.correlationID,
+		UserID:        w.userID,
+		Model:         w.model,
+		Direction:     DirectionOut,
+		PatternID:     nerUnavailablePattern,
+		Action:        ActionBlock,
+		CreatedAt:     time.Now().UTC(),
+	})
+}
+
+func (w *responseScanner) persist(ev PIIEvent) {
+	if w.firstEventID == "" {
+		w.firstEventID = ev.ID
+	}
+	if w.store == nil {
+		return
+	}
+	if err := w.store.Record(context.Background(), ev); err != nil {
+		xlog.Error("pii: failed to record response event", "error", err, "pattern", ev.PatternID)
+	}
+}
+
+// decodeResponseDoc keeps numbers as json.Number so re-encoding an
+// event leaves ids, token counts and timestamps byte-identical.
+func decodeResponseDoc(data []byte) (map[string]any, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var doc map[string]any
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+func encodeResponseDoc(doc map[string]any) ([]byte, error) {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(doc); err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(b.Bytes(), "\n"), nil
+}
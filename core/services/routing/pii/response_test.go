@@ -0,0 +1,214 @@
+package pii
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// fakeResponseAdapter scans the "text" field of a response document,
+// whole or streamed.
+func fakeResponseAdapter() Adapter {
+	a := fakeAdapter()
+	a.ScanResponse = func(doc map[string]any) []ScannedText {
+		if t, ok := doc["text"].(string); ok {
+			return []ScannedText{{Index: 0, Text: t}}
+		}
+		return nil
+	}
+	a.ApplyResponse = func(doc map[string]any, updates []ScannedText) {
+		for _, u := range updates {
+			doc["text"] = u.Text
+		}
+	}
+	return a
+}
+
+// valueDetector reports every occurrence of value as group, the way an
+// encoder would find the same email wherever it appears.
+func valueDetector(group, value string) NERDetector {
+	return &funcNERDetector{fn: func(text string) ([]NEREntity, error) {
+		var out []NEREntity
+		for from := 0; ; {
+			i := strings.Index(text[from:], value)
+			if i < 0 {
+				return out, nil
+			}
+			start := from + i
+			out = append(out, NEREntity{Group: group, Start: start, End: start + len(value), Score: 0.9})
+			from = start + len(value)
+		}
+	}}
+}
+
+var _ = Describe("RequestMiddleware (responses)", func() {
+	var st EventStore
+
+	// run answers the request with handler behind the middleware, with
+	// a detector finding "alice@example.com" under action.
+	run := func(action Action, scan bool, handler echo.HandlerFunc) *httptest.ResponseRecorder {
+		cfg := NERConfig{
+			Detector:      valueDetector("EMAIL", "alice@example.com"),
+			EntityActions: map[string]Action{"EMAIL": action},
+		}
+		mw := RequestMiddleware(&Redactor{}, st, fakeResponseAdapter(), nil,
+			WithNERResolver(resolverFor(map[string]NERConfig{"pf": cfg})))
+		e := echo.New()
+		e.POST("/chat", handler,
+			setRequestOnContext(&fakeRequest{Messages: []string{"who wrote this?"}}),
+			withModelConfig(fakeModelPIIConfig{enabled: true, detectors: []string{"pf"}, scan: scan}), mw)
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/chat", strings.NewReader(`{}`)))
+		return w
+	}
+
+	jsonAnswer := func(text string) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			return c.JSON(http.StatusOK, map[string]any{"id": "r1", "tokens": 12, "text": text})
+		}
+	}
+
+	// sseAnswer streams chunks as one event each, then [DONE].
+	sseAnswer := func(chunks ...string) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.Response().Header().Set("Content-Type", "text/event-stream")
+			c.Response().WriteHeader(http.StatusOK)
+			for _, chunk := range chunks {
+				fmt.Fprintf(c.Response(), "data: {\"text\":%q}\n\n", chunk)
+				c.Response().Flush()
+			}
+			fmt.Fprint(c.Response(), "data: [DONE]\n\n")
+			return nil
+		}
+	}
+
+	events := func() []PIIEvent {
+		evs, err := st.List(context.Background(), ListQuery{Limit: 100})
+		Expect(err).ToNot(HaveOccurred())
+		return evs
+	}
+
+	BeforeEach(func() {
+		st = NewMemoryEventStore(0)
+	})
+
+	It("masks PII in a whole response and records an out event", func() {
+		w := run(ActionMask, true, jsonAnswer("Contact alice@example.com for access"))
+
+		Expect(w.Code).To(Equal(http.StatusOK))
+		Expect(w.Body.String()).To(Equal(`{"id":"r1","text":"Contact [REDACTED:ner:EMAIL] for access","tokens":12}` + "\n"))
+		evs := events()
+		Expect(evs).To(HaveLen(1))
+		Expect(evs[0].Direction).To(Equal(DirectionOut))
+		Expect(evs[0].Action).To(Equal(ActionMask))
+		Expect(evs[0].ByteOffset).To(Equal(8))
+	})
+
+	It("leaves responses alone unless scan_responses is on", func() {
+		w := run(ActionMask, false, jsonAnswer("Contact alice@example.com"))
+
+		Expect(w.Body.String()).To(ContainSubstring("alice@example.com"))
+		Expect(events()).To(BeEmpty())
+	})
+
+	It("catches a span split across stream chunks", func() {
+		filler := strings.Repeat("lorem ipsum ", 60)
+		w := run(ActionMask, true, sseAnswer(filler, "write to ali", "ce@exam", "ple.com", " today", filler))
+
+		Expect(w.Code).To(Equal(http.StatusOK))
+		body := w.Body.String()
+		Expect(body).ToNot(ContainSubstring("ali"))
+		Expect(body).To(ContainSubstring(`{"text":"write to [REDACTED:ner:EMAIL]"}`))
+		Expect(body).To(ContainSubstring(`{"text":""}`), "the rest of the span is cut from the next chunks")
+		Expect(body).To(HaveSuffix("data: [DONE]\n\n"))
+		Expect(strings.Count(body, "data: ")).To(Equal(7), "every event is released once")
+		evs := events()
+		Expect(evs).To(HaveLen(1))
+		Expect(evs[0].Direction).To(Equal(DirectionOut))
+		Expect(evs[0].ByteOffset).To(Equal(len(filler) + len("write to ")))
+	})
+
+	It("cuts off a stream when a span is blocked", func() {
+		w := run(ActionBlock, true, sseAnswer("my email is ", "alice@example.com", " and more"))
+
+		body := w.Body.String()
+		Expect(body).ToNot(ContainSubstring("alice"))
+		Expect(body).ToNot(ContainSubstring("and more"))
+		Expect(body).To(ContainSubstring(`"type":"pii_blocked"`))
+		Expect(body).To(HaveSuffix("data: [DONE]\n\n"))
+		Expect(events()[0].Action).To(Equal(ActionBlock))
+	})
+
+	It("replaces a blocked whole response with a 400", func() {
+		w := run(ActionBlock, true, jsonAnswer("alice@example.com"))
+
+		Expect(w.Code).To(Equal(http.StatusBadRequest))
+		Expect(w.Body.String()).ToNot(ContainSubstring("alice"))
+		Expect(w.Body.String()).To(ContainSubstring(`"pii_blocked"`))
+		Expect(w.Body.String()).To(ContainSubstring(events()[0].ID))
+	})
+
+	It("allow passes the response through but records the event", func() {
+		w := run(ActionAllow, true, sseAnswer("alice@example.com"))
+
+		Expect(w.Body.String()).To(Equal("data: {\"text\":\"alice@example.com\"}\n\ndata: [DONE]\n\n"))
+		Expect(events()).To(HaveLen(1))
+		Expect(events()[0].Action).To(Equal(ActionAllow))
+	})
+
+	It("fails closed when the detector errors on the response", func() {
+		cfg := NERConfig{
+			Detector: &funcNERDetector{fn: func(text string) ([]NEREntity, error) {
+				if strings.Contains(text, "answer") {
+					return nil, errors.New("backend down")
+				}
+				return nil, nil
+			}},
+			DefaultAction: ActionMask,
+		}
+		mw := RequestMiddleware(&Redactor{}, st, fakeResponseAdapter(), nil,
+			WithNERResolver(resolverFor(map[string]NERConfig{"pf": cfg})))
+		w := serveResponse(mw, jsonAnswer("the answer"))
+
+		Expect(w.Code).To(Equal(http.StatusServiceUnavailable))
+		Expect(w.Body.String()).To(ContainSubstring("pii_ner_unavailable"))
+		Expect(events()[0].Direction).To(Equal(DirectionOut))
+	})
+
+	It("does not flag the client's own pseudonymized values on the way back", func() {
+		cfg := NERConfig{Detector: valueDetector("EMAIL", "alice@example.com"), DefaultAction: ActionMask}
+		mw := RequestMiddleware(&Redactor{}, st, fakeResponseAdapter(), nil,
+			WithNERResolver(resolverFor(map[string]NERConfig{"pf": cfg})))
+		e := echo.New()
+		e.POST("/chat", jsonAnswer("Sure, I emailed [REDACTED:EMAIL_001]"),
+			setRequestOnContext(&fakeRequest{Messages: []string{"email alice@example.com"}}),
+			withModelConfig(fakeModelPIIConfig{enabled: true, detectors: []string{"pf"}, reverse: true, scan: true}), mw)
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/chat", strings.NewReader(`{}`)))
+
+		Expect(w.Body.String()).To(ContainSubstring("Sure, I emailed alice@example.com"))
+		for _, ev := range events() {
+			Expect(ev.Direction).To(Equal(DirectionIn))
+		}
+	})
+})
+
+// serveResponse runs handler behind mw for a scanning model whose
+// request carries no PII.
+func serveResponse(mw echo.MiddlewareFunc, handler echo.HandlerFunc) *httptest.ResponseRecorder {
+	e := echo.New()
+	e.POST("/chat", handler,
+		setRequestOnContext(&fakeRequest{Messages: []string{"hello"}}),
+		withModelConfig(fakeModelPIIConfig{enabled: true, detectors: []string{"pf"}, scan: true}), mw)
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/chat", strings.NewReader(`{}`)))
+	return w
+}
@@ -3,12 +3,13 @@ package pii
 import (
 	"context"
 	"sync"
+	"time"
 )
 
 // EventStore persists PIIEvent records. Mirrors the StatsBackend
 // abstraction in the billing package: in-process by default so a
-// no-auth box still gets an event log; a future GORM-backed impl
-// (when --auth is on) will reuse the auth DB.
+// no-auth box still gets an event log; when --auth is on the
+// GORM-backed impl (NewGormEventStore) reuses the auth DB.
 type EventStore interface {
 	Record(ctx context.Context, e PIIEvent) error
 	List(ctx context.Context, q ListQuery) ([]PIIEvent, error)
@@ -30,7 +31,13 @@ type ListQuery struct {
 	// Origin scopes the search to redaction events from one surface
 	// (middleware | proxy | pii_analyze | pii_redact); empty matches any.
 	Origin Origin
-	Limit  int
+	// Model scopes the search to events recorded for one model.
+	Model string
+	// Since and Until bound CreatedAt to [Since, Until); zero values
+	// leave that side open.
+	Since time.Time
+	Until time.Time
+	Limit int
 }
 
 // NewMemoryEventStore returns an in-memory ring-buffer event store.
@@ -38,7 +45,7 @@ type ListQuery struct {
 //
 // Why a ring: PII events are noisy; a chatty deployment can produce
 // thousands per minute. A bounded buffer keeps memory predictable,
-// and the GORM impl (when added) handles long-term retention.
+// and the GORM impl handles long-term retention.
 func NewMemoryEventStore(capacity int) EventStore {
 	if capacity <= 0 {
 		capacity = 10_000
@@ -98,6 +105,15 @@ func (s *memoryEventStore) List(_ context.Context, q ListQuery) ([]PIIEvent, err
 		if q.Origin != "" && e.Origin != q.Origin {
 			return false
 		}
+		if q.Model != "" && e.Model != q.Model {
+			return false
+		}
+		if !q.Since.IsZero() && e.CreatedAt.Before(q.Since) {
+			return false
+		}
+		if !q.Until.IsZero() && !e.CreatedAt.Before(q.Until) {
+			return false
+		}
 		out = append(out, e)
 		return len(out) >= limit
 	}
@@ -0,0 +1,205 @@
+package pii
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mudler/LocalAI/core/services/advisorylock"
+	"github.com/mudler/LocalAI/core/services/dbutil"
+	"gorm.io/gorm"
+)
+
+// eventRow is the GORM model of a PIIEvent. The columns mirror the
+// JSON shape one-to-one; the matched value is never among them.
+type eventRow struct {
+	ID            string `gorm:"primaryKey;size:64"`
+	Kind          string `gorm:"index;size:32"`
+	Origin        string `gorm:"index;size:32"`
+	CorrelationID string `gorm:"index;size:128"`
+	UserID        string `gorm:"index;size:64"`
+	Model         string `gorm:"index;size:255"`
+	Direction     string `gorm:"size:8"`
+	PatternID     string `gorm:"index;size:128"`
+	ByteOffset    int
+	Length        int
+	HashPrefix    string `gorm:"size:16"`
+	Action        string `gorm:"size:16"`
+	Score         float32
+	CreatedAt     time.Time `gorm:"index"`
+
+	Host          string `gorm:"size:255"`
+	Intercepted   *bool
+	BytesSent     int64
+	BytesReceived int64
+	StatusCode    int
+	DurationMS    int64
+}
+
+func (eventRow) TableName() string { return "pii_events" }
+
+// toEventRow converts e, cutting the strings that come from the request
+// (the correlation ID is the client's X-Correlation-ID header) down to
+// their column sizes so one long value can't fail a batch.
+func toEventRow(e PIIEvent) eventRow {
+	return eventRow{
+		ID:            e.ID,
+		Kind:          string(e.Kind),
+		Origin:        dbutil.TruncateColumn(e.Origin, 32),
+		CorrelationID: dbutil.TruncateColumn(e.CorrelationID, 128),
+		UserID:        dbutil.TruncateColumn(e.UserID, 64),
+		Model:         dbutil.TruncateColumn(e.Model, 255),
+		Direction:     string(e.Direction),
+		PatternID:     dbutil.TruncateColumn(e.PatternID, 128),
+		ByteOffset:    e.ByteOffset,
+		Length:        e.Length,
+		HashPrefix:    e.HashPrefix,
+		Action:        string(e.Action),
+		Score:         e.Score,
+		CreatedAt:     e.CreatedAt,
+		Host:          dbutil.TruncateColumn(e.Host, 255),
+		Intercepted:   e.Intercepted,
+		BytesSent:     e.BytesSent,
+		BytesReceived: e.BytesReceived,
+		StatusCode:    e.StatusCode,
+		DurationMS:    e.DurationMS,
+	}
+}
+
+func (r eventRow) event() PIIEvent {
+	return PIIEvent{
+		ID:            r.ID,
+		Kind:          EventKind(r.Kind),
+		Origin:        r.Origin,
+		CorrelationID: r.CorrelationID,
+		UserID:        r.UserID,
+		Model:         r.Model,
+		Direction:     Direction(r.Direction),
+		PatternID:     r.PatternID,
+		ByteOffset:    r.ByteOffset,
+		Length:        r.Length,
+		HashPrefix:    r.HashPrefix,
+		Action:        Action(r.Action),
+		Score:         r.Score,
+		CreatedAt:     r.CreatedAt,
+		Host:          r.Host,
+		Intercepted:   r.Intercepted,
+		BytesSent:     r.BytesSent,
+		BytesReceived: r.BytesReceived,
+		StatusCode:    r.StatusCode,
+		DurationMS:    r.DurationMS,
+	}
+}
+
+const (
+	// eventFlushInterval is how long recorded events wait in memory
+	// before they are inserted as one batch. List and Count flush first,
+	// so the buffer is never visible to readers.
+	eventFlushInterval = 2 * time.Second
+	// maxPendingEvents caps the buffer while the database is failing.
+	maxPendingEvents = 10_000
+	// eventPruneInterval is how often events past the retention are
+	// deleted.
+	eventPruneInterval = time.Hour
+)
+
+// NewGormEventStore returns an EventStore persisting to db (the auth
+// DB), so the audit trail survives restarts and can be queried beyond
+// the ring buffer's window. Events are inserted in batches every
+// couple of seconds, as the billing backend does with usage records:
+// the PII middleware records from the request path, and a chatty
+// deployment produces thousands of events per minute.
+//
+// Events older than retention are pruned hourly by one replica (0
+// keeps them forever). The background loops stop with ctx or Close;
+// Close also flushes what is still buffered. See dbutil.BatchWriter.
+func NewGormEventStore(ctx context.Context, db *gorm.DB, retention time.Duration) (EventStore, error) {
+	if err := advisorylock.WithLockCtx(ctx, db, advisorylock.KeySchemaMigrate, func() error {
+		return db.AutoMigrate(&eventRow{})
+	}); err != nil {
+		return nil, fmt.Errorf("migrating pii event table: %w", err)
+	}
+	s := &gormEventStore{db: db}
+	s.rows = dbutil.NewBatchWriter(ctx, db, dbutil.BatchConfig[eventRow]{
+		Component:     "pii",
+		Noun:          "event",
+		ID:            func(r *eventRow) string { return r.ID },
+		FlushInterval: eventFlushInterval,
+		MaxPending:    maxPendingEvents,
+		Retention:     retention,
+		PruneInterval: eventPruneInterval,
+		PruneLockKey:  advisorylock.KeyPIIEventsPrune,
+	})
+	return s, nil
+}
+
+type gormEventStore struct {
+	db   *gorm.DB
+	rows *dbutil.BatchWriter[eventRow]
+}
+
+func (s *gormEventStore) Record(_ context.Context, e PIIEvent) error {
+	recordEventMetric(e)
+	return s.rows.Add(toEventRow(e))
+}
+
+func (s *gormEventStore) List(ctx context.Context, q ListQuery) ([]PIIEvent, error) {
+	s.rows.Flush()
+	limit := q.Limit
+	if limit <= 0 {
+		limit = 1000
+	}
+	tx := s.db.WithContext(ctx).Model(&eventRow{})
+	if q.CorrelationID != "" {
+		tx = tx.Where("correlation_id = ?", dbutil.TruncateColumn(q.CorrelationID, 128))
+	}
+	if q.UserID != "" {
+		tx = tx.Where("user_id = ?", q.UserID)
+	}
+	if q.PatternID != "" {
+		tx = tx.Where("pattern_id = ?", q.PatternID)
+	}
+	switch q.Kind {
+	case "":
+	case KindPII:
+		// Rows written before Kind existed count as PII events.
+		tx = tx.Where("kind = ? OR kind = ''", q.Kind)
+	default:
+		tx = tx.Where("kind = ?", q.Kind)
+	}
+	if q.Origin != "" {
+		tx = tx.Where("origin = ?", q.Origin)
+	}
+	if q.Model != "" {
+		tx = tx.Where("model = ?", q.Model)
+	}
+	if !q.Since.IsZero() {
+		tx = tx.Where("created_at >= ?", q.Since)
+	}
+	if !q.Until.IsZero() {
+		tx = tx.Where("created_at < ?", q.Until)
+	}
+	var rows []eventRow
+	if err := tx.Order("created_at DESC").Order("id DESC").Limit(limit).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	out := make([]PIIEvent, len(rows))
+	for i, r := range rows {
+		out[i] = r.event()
+	}
+	return out, nil
+}
+
+func (s *gormEventStore) Count(ctx context.Context) (int, error) {
+	s.rows.Flush()
+	var n int64
+	if err := s.db.WithContext(ctx).Model(&eventRow{}).Count(&n).Error; err != nil {
+		return 0, err
+	}
+	return int(n), nil
+}
+
+func (s *gormEventStore) Close() error {
+	s.rows.Close()
+	return nil
+}
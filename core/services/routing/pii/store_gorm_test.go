@@ -0,0 +1,122 @@
+package pii
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+var _ = Describe("GormEventStore", func() {
+	var (
+		store EventStore
+		ctx   context.Context
+		base  time.Time
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		dsn := fmt.Sprintf("file:pii_events_%d?mode=memory&cache=shared", time.Now().UnixNano())
+		db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+		Expect(err).ToNot(HaveOccurred())
+		store, err = NewGormEventStore(ctx, db, 24*time.Hour)
+		Expect(err).ToNot(HaveOccurred())
+		DeferCleanup(store.Close)
+
+		base = time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+		for i, e := range []PIIEvent{
+			{UserID: "alice", Model: "gpt-a", Kind: KindPII, PatternID: "ner:EMAIL"},
+			{UserID: "bob", Model: "gpt-b", Kind: KindPII, PatternID: "ner:PHONE"},
+			{UserID: "alice", Model: "gpt-b", Kind: KindAdmission},
+			// Written before Kind existed.
+			{UserID: "carol", Model: "gpt-a", PatternID: "ner:EMAIL"},
+		} {
+			e.ID = NewEventID()
+			e.CreatedAt = base.Add(time.Duration(i) * time.Hour)
+			Expect(store.Record(ctx, e)).To(Succeed())
+		}
+	})
+
+	It("reads back buffered events newest first", func() {
+		got, err := store.List(ctx, ListQuery{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(got).To(HaveLen(4))
+		Expect(got[0].UserID).To(Equal("carol"))
+		Expect(got[3].PatternID).To(Equal("ner:EMAIL"))
+		Expect(store.Count(ctx)).To(Equal(4))
+	})
+
+	It("filters by user, model and kind", func() {
+		got, err := store.List(ctx, ListQuery{UserID: "alice", Model: "gpt-b"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(got).To(HaveLen(1))
+		Expect(got[0].Kind).To(Equal(KindAdmission))
+
+		got, err = store.List(ctx, ListQuery{Kind: KindPII})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(got).To(HaveLen(3), "rows without a kind count as PII")
+	})
+
+	It("bounds the time window to [Since, Until)", func() {
+		got, err := store.List(ctx, ListQuery{Since: base.Add(time.Hour), Until: base.Add(3 * time.Hour)})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(got).To(HaveLen(2))
+		Expect(got[0].Kind).To(Equal(KindAdmission))
+		Expect(got[1].UserID).To(Equal("bob"))
+	})
+
+	It("prunes events past the retention", func() {
+		Expect(store.Count(ctx)).To(Equal(4))
+		n, err := store.(*gormEventStore).rows.Prune(base.Add(26*time.Hour + 30*time.Minute))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(n).To(BeEquivalentTo(3))
+		got, err := store.List(ctx, ListQuery{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(got).To(HaveLen(1))
+		Expect(got[0].UserID).To(Equal("carol"))
+	})
+
+	It("cuts a long correlation ID down to its column", func() {
+		id := strings.Repeat("c", 300)
+		Expect(store.Record(ctx, PIIEvent{ID: NewEventID(), CorrelationID: id, CreatedAt: base})).To(Succeed())
+		got, err := store.List(ctx, ListQuery{CorrelationID: id})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(got).To(HaveLen(1))
+		Expect(got[0].CorrelationID).To(HaveLen(128))
+	})
+
+	It("drops a rejected row without losing the rest of the batch", func() {
+		Expect(store.Count(ctx)).To(Equal(4))
+		dup := NewEventID()
+		for _, id := range []string{dup, dup, NewEventID()} {
+			Expect(store.Record(ctx, PIIEvent{ID: id, CreatedAt: base})).To(Succeed())
+		}
+		Expect(store.Count(ctx)).To(Equal(6))
+		Expect(store.Count(ctx)).To(Equal(6), "the rejected row is not retried")
+	})
+})
+
+var _ = Describe("MemoryEventStore window and model filters", func() {
+	It("applies Since, Until and Model like the database store", func() {
+		ctx := context.Background()
+		store := NewMemoryEventStore(0)
+		base := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+		for i, m := range []string{"a", "b", "a"} {
+			Expect(store.Record(ctx, PIIEvent{ID: NewEventID(), Model: m, CreatedAt: base.Add(time.Duration(i) * time.Hour)})).To(Succeed())
+		}
+
+		got, err := store.List(ctx, ListQuery{Model: "a", Since: base.Add(time.Minute)})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(got).To(HaveLen(1))
+		Expect(got[0].CreatedAt).To(Equal(base.Add(2 * time.Hour)))
+
+		got, err = store.List(ctx, ListQuery{Until: base.Add(time.Hour)})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(got).To(HaveLen(1))
+	})
+})
@@ -141,12 +141,15 @@ type PIIEvent struct {
 	Origin        Origin    `json:"origin,omitempty"`
 	CorrelationID string    `json:"correlation_id,omitempty"`
 	UserID        string    `json:"user_id,omitempty"`
-	Direction     Direction `json:"direction,omitempty"`
-	PatternID     string    `json:"pattern_id,omitempty"`
-	ByteOffset    int       `json:"byte_offset,omitempty"`
-	Length        int       `json:"length,omitempty"`
-	HashPrefix    string    `json:"hash_prefix,omitempty"`
-	Action        Action    `json:"action,omitempty"`
+	// Model is the model the request addressed, when the event came
+	// from a model-scoped surface (the chat middleware, admission).
+	Model      string    `json:"model,omitempty"`
+	Direction  Direction `json:"direction,omitempty"`
+	PatternID  string    `json:"pattern_id,omitempty"`
+	ByteOffset int       `json:"byte_offset,omitempty"`
+	Length     int       `json:"length,omitempty"`
+	HashPrefix string    `json:"hash_prefix,omitempty"`
+	Action     Action    `json:"action,omitempty"`
 	// Score is the detector confidence (0..1) for an NER PII hit. Metadata
 	// only — never the matched value. Lets admins see how sure the model was
 	// about a (possibly false-positive) detection without re-running it.
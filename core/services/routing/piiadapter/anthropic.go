@@ -77,5 +77,8 @@ func Anthropic() pii.Adapter {
 				}
 			}
 		},
+		ScanResponse:  anthropicResponseFields.scan,
+		ApplyResponse: anthropicResponseFields.apply,
+		ResponseError: anthropicResponseError,
 	}
 }
@@ -34,6 +34,8 @@ func OllamaChat() pii.Adapter {
 				}
 			}
 		},
+		ScanResponse:  ollamaChatResponseFields.scan,
+		ApplyResponse: ollamaChatResponseFields.apply,
 	}
 }
 
@@ -75,6 +77,8 @@ func OllamaGenerate() pii.Adapter {
 				}
 			}
 		},
+		ScanResponse:  ollamaGenerateResponseFields.scan,
+		ApplyResponse: ollamaGenerateResponseFields.apply,
 	}
 }
 
@@ -107,6 +107,8 @@ func OpenAI() pii.Adapter {
 				blockMap["text"] = u.Text
 			}
 		},
+		ScanResponse:  openAIResponseFields.scan,
+		ApplyResponse: openAIResponseFields.apply,
 	}
 }
 
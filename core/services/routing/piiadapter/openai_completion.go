@@ -87,5 +87,7 @@ func OpenAICompletion() pii.Adapter {
 				}
 			}
 		},
+		ScanResponse:  openAIResponseFields.scan,
+		ApplyResponse: openAIResponseFields.apply,
 	}
 }
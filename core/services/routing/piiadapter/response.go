@@ -0,0 +1,127 @@
+package piiadapter
+
+import (
+	"encoding/json"
+
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/core/services/routing/pii"
+)
+
+// responseFields are the generated-text fields of a response shape,
+// as paths of object keys, with "*" standing for every element of an
+// array. Paths that don't resolve in a given document (a delta in a
+// whole body, a tool_use block) are skipped. The Index of a ScannedText
+// is the position of its string in the walk, which apply repeats: the
+// document's shape does not change between the two calls, only its
+// strings do.
+type responseFields [][]string
+
+func (f responseFields) scan(doc map[string]any) []pii.ScannedText {
+	var out []pii.ScannedText
+	n := 0
+	for _, path := range f {
+		walkText(doc, path, func(s string, _ func(string)) {
+			out = append(out, pii.ScannedText{Index: n, Text: s})
+			n++
+		})
+	}
+	return out
+}
+
+func (f responseFields) apply(doc map[string]any, updates []pii.ScannedText) {
+	byIndex := make(map[int]string, len(updates))
+	for _, u := range updates {
+		byIndex[u.Index] = u.Text
+	}
+	n := 0
+	for _, path := range f {
+		walkText(doc, path, func(_ string, set func(string)) {
+			if text, ok := byIndex[n]; ok {
+				set(text)
+			}
+			n++
+		})
+	}
+}
+
+// walkText calls visit for every string found at path under v, in
+// document order, with a setter that writes a replacement in place.
+func walkText(v any, path []string, visit func(s string, set func(string))) {
+	if len(path) == 0 {
+		return
+	}
+	key, rest := path[0], path[1:]
+	if key == "*" {
+		arr, ok := v.([]any)
+		if !ok {
+			return
+		}
+		for i := range arr {
+			if len(rest) == 0 {
+				if s, ok := arr[i].(string); ok {
+					visit(s, func(text string) { arr[i] = text })
+				}
+				continue
+			}
+			walkText(arr[i], rest, visit)
+		}
+		return
+	}
+	obj, ok := v.(map[string]any)
+	if !ok {
+		return
+	}
+	if len(rest) == 0 {
+		if s, ok := obj[key].(string); ok {
+			visit(s, func(text string) { obj[key] = text })
+		}
+		return
+	}
+	walkText(obj[key], rest, visit)
+}
+
+// openAIResponseFields cover both OpenAI response shapes: chat
+// completions (message, or delta when streamed) and legacy completions
+// (text), whole or streamed. Besides the content, a chat message carries
+// generated text in its reasoning (under either name backends use) and
+// in the arguments of its tool calls.
+var openAIResponseFields = responseFields{
+	{"choices", "*", "message", "content"},
+	{"choices", "*", "message", "reasoning"},
+	{"choices", "*", "message", "reasoning_content"},
+	{"choices", "*", "message", "tool_calls", "*", "function", "arguments"},
+	{"choices", "*", "delta", "content"},
+	{"choices", "*", "delta", "reasoning"},
+	{"choices", "*", "delta", "reasoning_content"},
+	{"choices", "*", "delta", "tool_calls", "*", "function", "arguments"},
+	{"choices", "*", "text"},
+}
+
+// anthropicResponseFields are the text and thinking blocks of a message
+// and the text_delta and thinking_delta of a streamed
+// content_block_delta. A redacted thinking block no longer matches its
+// signature, which Anthropic checks if the block is sent back. Tool input
+// is left alone, as on the request side.
+var anthropicResponseFields = responseFields{
+	{"content", "*", "text"},
+	{"content", "*", "thinking"},
+	{"delta", "text"},
+	{"delta", "thinking"},
+}
+
+// Ollama answers the same shape whole or streamed, one NDJSON line per
+// chunk.
+var (
+	ollamaChatResponseFields     = responseFields{{"message", "content"}}
+	ollamaGenerateResponseFields = responseFields{{"response"}}
+)
+
+// anthropicResponseError ends a blocked Anthropic stream with the error
+// event the messages endpoint sends on an inference failure.
+func anthropicResponseError(errType, message string) []byte {
+	data, _ := json.Marshal(schema.AnthropicStreamEvent{
+		Type:  "error",
+		Error: &schema.AnthropicError{Type: errType, Message: message},
+	})
+	return []byte("event: error\ndata: " + string(data) + "\n\n")
+}
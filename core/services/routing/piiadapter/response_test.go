@@ -0,0 +1,87 @@
+package piiadapter
+
+import (
+	"encoding/json"
+
+	"github.com/mudler/LocalAI/core/services/routing/pii"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// responseDoc decodes a response body the way the pii middleware does.
+func responseDoc(body string) map[string]any {
+	var doc map[string]any
+	Expect(json.Unmarshal([]byte(body), &doc)).To(Succeed())
+	return doc
+}
+
+// applyAllResponse rewrites every scanned response field through fn.
+func applyAllResponse(a pii.Adapter, doc map[string]any, fn func(string) string) {
+	texts := a.ScanResponse(doc)
+	for i := range texts {
+		texts[i].Text = fn(texts[i].Text)
+	}
+	a.ApplyResponse(doc, texts)
+}
+
+func bracket(s string) string { return "<" + s + ">" }
+
+var _ = Describe("Response scanning", func() {
+	It("covers OpenAI chat messages and stream deltas", func() {
+		doc := responseDoc(`{"choices":[{"message":{"role":"assistant","content":"one"}},{"message":{"content":"two","tool_calls":[]}}]}`)
+		Expect(OpenAI().ScanResponse(doc)).To(HaveLen(2))
+		applyAllResponse(OpenAI(), doc, bracket)
+		Expect(doc["choices"].([]any)[1].(map[string]any)["message"].(map[string]any)["content"]).To(Equal("<two>"))
+
+		delta := responseDoc(`{"choices":[{"delta":{"content":"tok"}}]}`)
+		applyAllResponse(OpenAI(), delta, bracket)
+		Expect(delta["choices"].([]any)[0].(map[string]any)["delta"].(map[string]any)["content"]).To(Equal("<tok>"))
+	})
+
+	It("covers OpenAI reasoning and tool call arguments", func() {
+		doc := responseDoc(`{"choices":[{"message":{"content":null,"reasoning":"r","reasoning_content":"rc","tool_calls":[{"type":"function","function":{"name":"f","arguments":"{\"to\":\"a\"}"}}]}}]}`)
+		applyAllResponse(OpenAI(), doc, bracket)
+		msg := doc["choices"].([]any)[0].(map[string]any)["message"].(map[string]any)
+		Expect(msg["reasoning"]).To(Equal("<r>"))
+		Expect(msg["reasoning_content"]).To(Equal("<rc>"))
+		fn := msg["tool_calls"].([]any)[0].(map[string]any)["function"].(map[string]any)
+		Expect(fn["arguments"]).To(Equal(`<{"to":"a"}>`))
+		Expect(fn["name"]).To(Equal("f"))
+
+		delta := responseDoc(`{"choices":[{"delta":{"reasoning":"r","tool_calls":[{"index":0,"function":{"arguments":"{\"to"}}]}}]}`)
+		Expect(OpenAI().ScanResponse(delta)).To(HaveLen(2))
+	})
+
+	It("covers OpenAI completion text and skips null content", func() {
+		doc := responseDoc(`{"choices":[{"text":"done"},{"message":{"content":null}}]}`)
+		got := OpenAICompletion().ScanResponse(doc)
+		Expect(got).To(HaveLen(1))
+		Expect(got[0].Text).To(Equal("done"))
+	})
+
+	It("covers Anthropic text and thinking blocks and their deltas only", func() {
+		doc := responseDoc(`{"content":[{"type":"thinking","thinking":"hmm","signature":"s"},{"type":"text","text":"hi"},{"type":"tool_use","input":{"q":"x"}},{"type":"text","text":"bye"}]}`)
+		applyAllResponse(Anthropic(), doc, bracket)
+		blocks := doc["content"].([]any)
+		Expect(blocks[0].(map[string]any)["thinking"]).To(Equal("<hmm>"))
+		Expect(blocks[0].(map[string]any)["signature"]).To(Equal("s"))
+		Expect(blocks[1].(map[string]any)["text"]).To(Equal("<hi>"))
+		Expect(blocks[2].(map[string]any)).ToNot(HaveKey("text"))
+		Expect(blocks[3].(map[string]any)["text"]).To(Equal("<bye>"))
+
+		Expect(Anthropic().ScanResponse(responseDoc(`{"type":"content_block_delta","delta":{"type":"input_json_delta","partial_json":"{"}}`))).To(BeEmpty())
+		Expect(Anthropic().ScanResponse(responseDoc(`{"type":"content_block_delta","delta":{"type":"text_delta","text":"tok"}}`))).To(HaveLen(1))
+		Expect(Anthropic().ScanResponse(responseDoc(`{"type":"content_block_delta","delta":{"type":"thinking_delta","thinking":"tok"}}`))).To(HaveLen(1))
+		Expect(string(Anthropic().ResponseError("pii_blocked", "blocked"))).To(HavePrefix("event: error\ndata: {\"type\":\"error\""))
+	})
+
+	It("covers Ollama chat and generate chunks", func() {
+		chat := responseDoc(`{"model":"m","message":{"role":"assistant","content":"hi"},"done":false}`)
+		applyAllResponse(OllamaChat(), chat, bracket)
+		Expect(chat["message"].(map[string]any)["content"]).To(Equal("<hi>"))
+
+		gen := responseDoc(`{"model":"m","response":"hi","done":true}`)
+		applyAllResponse(OllamaGenerate(), gen, bracket)
+		Expect(gen["response"]).To(Equal("<hi>"))
+	})
+})
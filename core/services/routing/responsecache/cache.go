@@ -0,0 +1,161 @@
+// Package responsecache caches chat and completion responses so a
+// repeated request is answered without running a generation. Entries
+// are looked up by an exact key derived from the normalized request
+// (see NewKeys) and, optionally, through an embedding-similarity Index
+// that maps a paraphrase to the exact key of a cached response.
+//
+// The cache is process-local: every replica keeps its own entries.
+package responsecache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// Defaults applied when the model's response_cache block leaves the
+// field at 0.
+const (
+	DefaultTTL        = time.Hour
+	DefaultMaxEntries = 1000
+)
+
+// Response is a cached response, as written by the handler.
+type Response struct {
+	// Model is the model name written in the response.
+	Model       string
+	ContentType string
+	// Body is the response body: a JSON document, or the SSE events of
+	// a streamed response.
+	Body   []byte
+	Stream bool
+	// ID and Created are the response id and timestamp in Body; replays
+	// restamp them so a client never sees the same id twice.
+	ID      string
+	Created int64
+	// PromptTokens and CompletionTokens are the usage the handler
+	// stamped for the original request.
+	PromptTokens     int
+	CompletionTokens int
+	StoredAt         time.Time
+
+	expires time.Time
+
+	// mu guards the link to the semantic index: unindex drops the entry
+	// from it, released is set once the entry left the cache.
+	mu       sync.Mutex
+	released bool
+	unindex  func()
+}
+
+// release is called once e left the cache (evicted, expired or
+// replaced): it drops e from the semantic index, and keeps an index
+// insert still in flight from adding it.
+func (e *Response) release() {
+	e.mu.Lock()
+	e.released = true
+	unindex := e.unindex
+	e.unindex = nil
+	e.mu.Unlock()
+	if unindex != nil {
+		unindex()
+	}
+}
+
+// Cache is an in-memory LRU of responses with a TTL, partitioned by
+// model so each model's max_entries bound applies to its own entries.
+// Safe for concurrent use.
+type Cache struct {
+	mu     sync.Mutex
+	models map[string]*modelCache
+	now    func() time.Time
+}
+
+type modelCache struct {
+	order *list.List // of *cacheItem, most recently used first
+	items map[string]*list.Element
+}
+
+type cacheItem struct {
+	key   string
+	entry *Response
+}
+
+// New returns an empty cache.
+func New() *Cache {
+	return &Cache{models: map[string]*modelCache{}, now: time.Now}
+}
+
+// Get returns the live entry cached under key for model.
+func (c *Cache) Get(model, key string) (*Response, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	mc := c.models[model]
+	if mc == nil {
+		return nil, false
+	}
+	el, ok := mc.items[key]
+	if !ok {
+		return nil, false
+	}
+	item := el.Value.(*cacheItem)
+	if !c.now().Before(item.entry.expires) {
+		go mc.remove(el).release()
+		return nil, false
+	}
+	mc.order.MoveToFront(el)
+	return item.entry, true
+}
+
+// Put caches e under key for model for ttl, evicting the least
+// recently used entries past maxEntries. Zero ttl and maxEntries pick
+// DefaultTTL and DefaultMaxEntries. The entries that leave the cache,
+// the one e replaces included, are dropped from the semantic index.
+func (c *Cache) Put(model, key string, e *Response, ttl time.Duration, maxEntries int) {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	if maxEntries <= 0 {
+		maxEntries = DefaultMaxEntries
+	}
+	now := c.now()
+	e.StoredAt = now
+	e.expires = now.Add(ttl)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	mc := c.models[model]
+	if mc == nil {
+		mc = &modelCache{order: list.New(), items: map[string]*list.Element{}}
+		c.models[model] = mc
+	}
+	if el, ok := mc.items[key]; ok {
+		item := el.Value.(*cacheItem)
+		go item.entry.release()
+		item.entry = e
+		mc.order.MoveToFront(el)
+	} else {
+		mc.items[key] = mc.order.PushFront(&cacheItem{key: key, entry: e})
+	}
+	for mc.order.Len() > maxEntries {
+		go mc.remove(mc.order.Back()).release()
+	}
+}
+
+// Len returns the number of entries held for model, expired ones
+// included until they are looked up or evicted.
+func (c *Cache) Len(model string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if mc := c.models[model]; mc != nil {
+		return mc.order.Len()
+	}
+	return 0
+}
+
+func (mc *modelCache) remove(el *list.Element) *Response {
+	item := el.Value.(*cacheItem)
+	mc.order.Remove(el)
+	delete(mc.items, item.key)
+	return item.entry
+}
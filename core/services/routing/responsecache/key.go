@@ -0,0 +1,134 @@
+package responsecache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+
+	"github.com/mudler/LocalAI/core/schema"
+)
+
+// Keys identifies a request in the cache.
+type Keys struct {
+	// Exact is the cache key: a hash of the scope, the parameters and
+	// the normalized conversation.
+	Exact string
+	// Scope is the user the entry belongs to, empty when shared.
+	Scope string
+	// Params is a hash of everything but the conversation: model,
+	// sampling parameters, tools, response format and stream flag. A
+	// semantic match must agree on it exactly.
+	Params string
+	// Text is the conversation as embedded by the semantic Index.
+	// Empty when the request carries non-text content, which the
+	// embedding would not capture.
+	Text string
+}
+
+// NewKeys derives the cache keys of req for scope. Streamed and
+// non-streamed requests get different keys: each is replayed the way
+// it was generated.
+func NewKeys(scope string, req *schema.OpenAIRequest) (Keys, error) {
+	params := *req
+	params.Messages = nil
+	params.Prompt = nil
+	params.StreamOptions = nil
+	paramsJSON, err := json.Marshal(&params)
+	if err != nil {
+		return Keys{}, err
+	}
+
+	messages := make([]schema.Message, len(req.Messages))
+	for i, m := range req.Messages {
+		if s, ok := m.Content.(string); ok {
+			m.Content = normalizeText(s)
+		}
+		messages[i] = m
+	}
+	var prompt any
+	switch p := req.Prompt.(type) {
+	case string:
+		prompt = normalizeText(p)
+	default:
+		prompt = p
+	}
+	convJSON, err := json.Marshal(map[string]any{"messages": messages, "prompt": prompt})
+	if err != nil {
+		return Keys{}, err
+	}
+
+	k := Keys{Scope: scope, Params: hashOf(paramsJSON)}
+	k.Exact = hashOf([]byte(scope), []byte(k.Params), convJSON)
+	k.Text = conversationText(messages, prompt)
+	return k, nil
+}
+
+// normalizeText drops the differences a client introduces without
+// changing the request: line endings and surrounding whitespace.
+func normalizeText(s string) string {
+	return strings.TrimSpace(strings.ReplaceAll(s, "\r\n", "\n"))
+}
+
+// conversationText renders the messages, or the prompt, as the text the
+// semantic index embeds. It returns "" when any message carries an
+// image, audio or other non-text part.
+func conversationText(messages []schema.Message, prompt any) string {
+	var b strings.Builder
+	if len(messages) == 0 {
+		switch p := prompt.(type) {
+		case string:
+			return p
+		case []any:
+			if len(p) == 1 {
+				if s, ok := p[0].(string); ok {
+					return normalizeText(s)
+				}
+			}
+		}
+		return ""
+	}
+	for _, m := range messages {
+		text, ok := messageText(m.Content)
+		if !ok || len(m.ToolCalls) > 0 || m.FunctionCall != nil {
+			return ""
+		}
+		b.WriteString(m.Role)
+		b.WriteString(": ")
+		b.WriteString(text)
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// messageText returns the text of a message content: a string, or an
+// array of text parts.
+func messageText(content any) (string, bool) {
+	switch c := content.(type) {
+	case nil:
+		return "", true
+	case string:
+		return c, true
+	case []any:
+		var parts []string
+		for _, p := range c {
+			part, ok := p.(map[string]any)
+			if !ok || part["type"] != "text" {
+				return "", false
+			}
+			text, _ := part["text"].(string)
+			parts = append(parts, normalizeText(text))
+		}
+		return strings.Join(parts, "\n"), true
+	}
+	return "", false
+}
+
+func hashOf(parts ...[]byte) string {
+	h := sha256.New()
+	for _, p := range parts {
+		h.Write(p)
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,73 @@
+package responsecache
+
+import (
+	"context"
+	"sync"
+
+	"github.com/mudler/xlog"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// Lookup results, the result label of localai_response_cache_requests_total
+// and the value of the X-LocalAI-Cache response header.
+const (
+	ResultHit         = "hit"
+	ResultSemanticHit = "semantic_hit"
+	ResultMiss        = "miss"
+	ResultBypass      = "bypass"
+)
+
+var (
+	metricsOnce     sync.Once
+	requestsCounter metric.Int64Counter
+
+	// configuredMeter mirrors billing.SetMeter: the cache metrics
+	// must land on the MeterProvider that exports /metrics.
+	configuredMeterMu sync.Mutex
+	configuredMeter   metric.Meter
+)
+
+// SetMeter wires the meter from monitoring.LocalAIMetricsService
+// before the first request is served.
+func SetMeter(m metric.Meter) {
+	configuredMeterMu.Lock()
+	defer configuredMeterMu.Unlock()
+	configuredMeter = m
+}
+
+func resolveMeter() metric.Meter {
+	configuredMeterMu.Lock()
+	m := configuredMeter
+	configuredMeterMu.Unlock()
+	if m != nil {
+		return m
+	}
+	return otel.Meter("github.com/mudler/LocalAI/core/services/routing/responsecache")
+}
+
+func initMetrics() {
+	metricsOnce.Do(func() {
+		var err error
+		requestsCounter, err = resolveMeter().Int64Counter(
+			"localai_response_cache_requests_total",
+			metric.WithDescription("Requests to models with a response cache, labeled by model and result (hit, semantic_hit, miss, bypass)"),
+		)
+		if err != nil {
+			xlog.Error("responsecache: failed to create requests counter", "error", err)
+		}
+	})
+}
+
+// RecordRequest counts a request to a model with a response cache.
+func RecordRequest(model, result string) {
+	initMetrics()
+	if requestsCounter == nil {
+		return
+	}
+	requestsCounter.Add(context.Background(), 1, metric.WithAttributes(
+		attribute.String("model", model),
+		attribute.String("result", result),
+	))
+}
@@ -0,0 +1,89 @@
+package responsecache
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+)
+
+// responseMeta is the part of a response (or stream chunk) the cache
+// reads: the id and timestamp it restamps, and what tells a result
+// apart from an error.
+type responseMeta struct {
+	ID      string          `json:"id"`
+	Created int64           `json:"created"`
+	Choices json.RawMessage `json:"choices"`
+	Error   json.RawMessage `json:"error"`
+}
+
+// NewResponse builds the cached response of a successful body. It returns
+// false when the body is not a complete result: an error payload, or a
+// stream that failed or stopped before its [DONE] event.
+func NewResponse(contentType string, body []byte, stream bool) (*Response, bool) {
+	e := &Response{ContentType: contentType, Body: bytes.Clone(body), Stream: stream}
+	if !stream {
+		var meta responseMeta
+		if json.Unmarshal(body, &meta) != nil || len(meta.Error) > 0 || len(meta.Choices) == 0 {
+			return nil, false
+		}
+		e.ID, e.Created = meta.ID, meta.Created
+		return e, true
+	}
+
+	done := false
+	for _, ev := range splitEvents(body) {
+		data, ok := bytes.CutPrefix(ev, []byte("data: "))
+		if !ok {
+			continue
+		}
+		if string(bytes.TrimSpace(data)) == "[DONE]" {
+			done = true
+			continue
+		}
+		var meta responseMeta
+		if json.Unmarshal(data, &meta) != nil || len(meta.Error) > 0 {
+			return nil, false
+		}
+		if e.ID == "" {
+			e.ID, e.Created = meta.ID, meta.Created
+		}
+	}
+	return e, done
+}
+
+// Replay returns the body of e restamped with a new id and timestamp.
+// A streamed response is returned as its list of SSE events, to be written
+// and flushed one by one.
+func (e *Response) Replay(id string, created int64) [][]byte {
+	body := e.Body
+	if e.ID != "" {
+		oldID, _ := json.Marshal(e.ID)
+		newID, _ := json.Marshal(id)
+		body = bytes.ReplaceAll(body, append([]byte(`"id":`), oldID...), append([]byte(`"id":`), newID...))
+	}
+	if e.Created != 0 {
+		body = bytes.ReplaceAll(body,
+			[]byte(`"created":`+strconv.FormatInt(e.Created, 10)),
+			[]byte(`"created":`+strconv.FormatInt(created, 10)))
+	}
+	if !e.Stream {
+		return [][]byte{body}
+	}
+	events := splitEvents(body)
+	for i, ev := range events {
+		events[i] = append(ev, '\n', '\n')
+	}
+	return events
+}
+
+// splitEvents splits an SSE body into its events, without the blank
+// line that terminates each.
+func splitEvents(body []byte) [][]byte {
+	var events [][]byte
+	for _, ev := range bytes.Split(body, []byte("\n\n")) {
+		if len(bytes.TrimSpace(ev)) > 0 {
+			events = append(events, bytes.Clone(ev))
+		}
+	}
+	return events
+}
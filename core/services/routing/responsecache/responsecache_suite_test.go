@@ -0,0 +1,13 @@
+package responsecache
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestResponseCache(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Response cache test suite")
+}
@@ -0,0 +1,317 @@
+package responsecache
+
+import (
+	"context"
+	"math"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/mudler/LocalAI/core/backend"
+	"github.com/mudler/LocalAI/core/schema"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// tableEmbedder embeds the texts it was given a vector for.
+type tableEmbedder map[string][]float32
+
+func (e tableEmbedder) Embed(_ context.Context, text string) ([]float32, error) {
+	return e[text], nil
+}
+
+// cosineStore is an in-memory vector store ranking by cosine similarity.
+type cosineStore struct {
+	mu       sync.Mutex
+	vecs     [][]float32
+	payloads [][]byte
+}
+
+func (s *cosineStore) Search(ctx context.Context, vec []float32) (float64, []byte, bool, error) {
+	n, err := s.SearchK(ctx, vec, 1)
+	if err != nil || len(n) == 0 {
+		return 0, nil, false, err
+	}
+	return n[0].Similarity, n[0].Payload, true, nil
+}
+
+func (s *cosineStore) SearchK(_ context.Context, vec []float32, k int) ([]backend.Neighbor, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []backend.Neighbor
+	for i, v := range s.vecs {
+		out = append(out, backend.Neighbor{Similarity: cosine(v, vec), Payload: s.payloads[i]})
+	}
+	if len(out) > k {
+		out = out[:k]
+	}
+	return out, nil
+}
+
+// Insert replaces the payload of a vector already stored, as the
+// local-store does.
+func (s *cosineStore) Insert(_ context.Context, vec []float32, payload []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, v := range s.vecs {
+		if slices.Equal(v, vec) {
+			s.payloads[i] = payload
+			return nil
+		}
+	}
+	s.vecs = append(s.vecs, vec)
+	s.payloads = append(s.payloads, payload)
+	return nil
+}
+
+func (s *cosineStore) Delete(_ context.Context, vecs [][]float32) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, vec := range vecs {
+		for i, v := range s.vecs {
+			if slices.Equal(v, vec) {
+				s.vecs = slices.Delete(s.vecs, i, i+1)
+				s.payloads = slices.Delete(s.payloads, i, i+1)
+				break
+			}
+		}
+	}
+	return nil
+}
+
+func (s *cosineStore) len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.vecs)
+}
+
+func cosine(a, b []float32) float64 {
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i] * b[i])
+		na += float64(a[i] * a[i])
+		nb += float64(b[i] * b[i])
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
+
+func chatRequest(content string) *schema.OpenAIRequest {
+	temp := 0.0
+	req := &schema.OpenAIRequest{Messages: []schema.Message{
+		{Role: "system", Content: "You are helpful."},
+		{Role: "user", Content: content},
+	}}
+	req.Model = "m"
+	req.Temperature = &temp
+	return req
+}
+
+var _ = Describe("Response cache", func() {
+	Describe("Cache", func() {
+		var (
+			cache *Cache
+			now   time.Time
+		)
+
+		BeforeEach(func() {
+			now = time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+			cache = New()
+			cache.now = func() time.Time { return now }
+		})
+
+		It("expires entries after their TTL", func() {
+			cache.Put("m", "k", &Response{ID: "a"}, time.Minute, 0)
+			e, ok := cache.Get("m", "k")
+			Expect(ok).To(BeTrue())
+			Expect(e.ID).To(Equal("a"))
+			Expect(e.StoredAt).To(Equal(now))
+
+			now = now.Add(time.Minute)
+			_, ok = cache.Get("m", "k")
+			Expect(ok).To(BeFalse())
+			Expect(cache.Len("m")).To(Equal(0))
+		})
+
+		It("evicts the least recently used entry of the model", func() {
+			cache.Put("m", "a", &Response{}, 0, 2)
+			cache.Put("m", "b", &Response{}, 0, 2)
+			cache.Put("other", "x", &Response{}, 0, 2)
+			_, ok := cache.Get("m", "a")
+			Expect(ok).To(BeTrue())
+
+			cache.Put("m", "c", &Response{}, 0, 2)
+			_, ok = cache.Get("m", "b")
+			Expect(ok).To(BeFalse())
+			_, ok = cache.Get("m", "a")
+			Expect(ok).To(BeTrue())
+			Expect(cache.Len("other")).To(Equal(1))
+		})
+	})
+
+	Describe("NewKeys", func() {
+		It("ignores whitespace and stream options", func() {
+			a, err := NewKeys("u1", chatRequest("What is LocalAI?"))
+			Expect(err).ToNot(HaveOccurred())
+			req := chatRequest("  What is LocalAI?\r\n")
+			req.StreamOptions = &schema.StreamOptions{IncludeUsage: true}
+			b, err := NewKeys("u1", req)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(b.Exact).To(Equal(a.Exact))
+			Expect(a.Text).To(Equal("system: You are helpful.\nuser: What is LocalAI?\n"))
+		})
+
+		It("separates users, sampling parameters and streaming", func() {
+			base, _ := NewKeys("u1", chatRequest("hi"))
+
+			other, _ := NewKeys("u2", chatRequest("hi"))
+			Expect(other.Exact).ToNot(Equal(base.Exact))
+
+			hot := chatRequest("hi")
+			temp := 0.9
+			hot.Temperature = &temp
+			k, _ := NewKeys("u1", hot)
+			Expect(k.Exact).ToNot(Equal(base.Exact))
+			Expect(k.Params).ToNot(Equal(base.Params))
+
+			streamed := chatRequest("hi")
+			streamed.Stream = true
+			k, _ = NewKeys("u1", streamed)
+			Expect(k.Exact).ToNot(Equal(base.Exact))
+		})
+
+		It("does not embed conversations with images", func() {
+			req := chatRequest("")
+			req.Messages[1].Content = []any{
+				map[string]any{"type": "text", "text": "what is this?"},
+				map[string]any{"type": "image_url", "image_url": map[string]any{"url": "data:image/png;base64,AAAA"}},
+			}
+			k, err := NewKeys("u1", req)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(k.Exact).ToNot(BeEmpty())
+			Expect(k.Text).To(BeEmpty())
+		})
+	})
+
+	Describe("Index", func() {
+		It("matches paraphrases with the same scope and parameters", func() {
+			orig, _ := NewKeys("u1", chatRequest("How do I reset my password?"))
+			para, _ := NewKeys("u1", chatRequest("How can I reset my password?"))
+			otherUser, _ := NewKeys("u2", chatRequest("How can I reset my password?"))
+			unrelated, _ := NewKeys("u1", chatRequest("What's the weather?"))
+			emb := tableEmbedder{
+				orig.Text:      {1, 0, 0},
+				para.Text:      {0.99, 0.05, 0},
+				otherUser.Text: {0.99, 0.05, 0},
+				unrelated.Text: {0, 1, 0},
+			}
+			ix := NewIndex(emb, &cosineStore{}, 0)
+			ctx := context.Background()
+
+			vec, err := ix.Embed(ctx, orig)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ix.Insert(ctx, vec, orig, &Response{})).To(Succeed())
+
+			for _, tc := range []struct {
+				keys Keys
+				hit  bool
+			}{{para, true}, {otherUser, false}, {unrelated, false}} {
+				vec, err := ix.Embed(ctx, tc.keys)
+				Expect(err).ToNot(HaveOccurred())
+				key, _, ok, err := ix.Lookup(ctx, vec, tc.keys)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(ok).To(Equal(tc.hit))
+				if tc.hit {
+					Expect(key).To(Equal(orig.Exact))
+				}
+			}
+		})
+
+		lookup := func(ix *Index, vec []float32, k Keys) string {
+			key, _, ok, err := ix.Lookup(context.Background(), vec, k)
+			Expect(err).ToNot(HaveOccurred())
+			if !ok {
+				return ""
+			}
+			return key
+		}
+
+		It("keeps the responses of every scope sharing a vector", func(ctx SpecContext) {
+			store := &cosineStore{}
+			ix := NewIndex(tableEmbedder{}, store, 0)
+			alice, _ := NewKeys("alice", chatRequest("hi"))
+			bob, _ := NewKeys("bob", chatRequest("hi"))
+			vec := []float32{1, 0, 0}
+
+			Expect(ix.Insert(ctx, vec, alice, &Response{})).To(Succeed())
+			Expect(ix.Insert(ctx, vec, bob, &Response{})).To(Succeed())
+			Expect(store.len()).To(Equal(1))
+			Expect(lookup(ix, vec, alice)).To(Equal(alice.Exact))
+			Expect(lookup(ix, vec, bob)).To(Equal(bob.Exact))
+		})
+
+		It("drops the vectors of responses that leave the cache", func(ctx SpecContext) {
+			store := &cosineStore{}
+			ix := NewIndex(tableEmbedder{}, store, 0)
+			cache := New()
+			now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+			cache.now = func() time.Time { return now }
+
+			// Evicted by the LRU bound.
+			a, _ := NewKeys("u1", chatRequest("a"))
+			b, _ := NewKeys("u1", chatRequest("b"))
+			ea, eb := &Response{}, &Response{}
+			cache.Put("m", a.Exact, ea, time.Minute, 1)
+			Expect(ix.Insert(ctx, []float32{1, 0, 0}, a, ea)).To(Succeed())
+			cache.Put("m", b.Exact, eb, time.Minute, 1)
+			Expect(ix.Insert(ctx, []float32{0, 1, 0}, b, eb)).To(Succeed())
+			Eventually(store.len).Should(Equal(1))
+			Expect(lookup(ix, []float32{1, 0, 0}, a)).To(BeEmpty())
+
+			// Expired.
+			now = now.Add(time.Minute)
+			_, ok := cache.Get("m", b.Exact)
+			Expect(ok).To(BeFalse())
+			Eventually(store.len).Should(Equal(0))
+
+			// Gone before it was indexed.
+			c, _ := NewKeys("u1", chatRequest("c"))
+			ec := &Response{}
+			cache.Put("m", c.Exact, ec, time.Minute, 1)
+			cache.Put("m", c.Exact, &Response{}, time.Minute, 1)
+			Eventually(func() bool {
+				ec.mu.Lock()
+				defer ec.mu.Unlock()
+				return ec.released
+			}).Should(BeTrue())
+			Expect(ix.Insert(ctx, []float32{0, 0, 1}, c, ec)).To(Succeed())
+			Expect(store.len()).To(Equal(0))
+		})
+	})
+
+	Describe("Response", func() {
+		It("caches complete results only", func() {
+			_, ok := NewResponse("application/json", []byte(`{"error":{"message":"boom"}}`), false)
+			Expect(ok).To(BeFalse())
+			_, ok = NewResponse("text/event-stream", []byte("data: {\"id\":\"a\",\"choices\":[]}\n\n"), true)
+			Expect(ok).To(BeFalse(), "a stream without [DONE] is incomplete")
+			_, ok = NewResponse("text/event-stream", []byte("data: {\"id\":\"a\",\"choices\":[]}\n\ndata: {\"error\":{\"message\":\"boom\"}}\n\ndata: [DONE]\n\n"), true)
+			Expect(ok).To(BeFalse())
+		})
+
+		It("restamps the id and timestamp on replay", func() {
+			body := "data: {\"id\":\"old\",\"created\":100,\"choices\":[{\"delta\":{\"content\":\"Hel\"}}]}\n\n" +
+				"data: {\"id\":\"old\",\"created\":100,\"choices\":[{\"delta\":{\"content\":\"lo\"}}]}\n\n" +
+				"data: [DONE]\n\n"
+			e, ok := NewResponse("text/event-stream", []byte(body), true)
+			Expect(ok).To(BeTrue())
+			Expect(e.ID).To(Equal("old"))
+
+			events := e.Replay("new", 200)
+			Expect(events).To(HaveLen(3))
+			Expect(string(events[0])).To(Equal("data: {\"id\":\"new\",\"created\":200,\"choices\":[{\"delta\":{\"content\":\"Hel\"}}]}\n\n"))
+			Expect(string(events[2])).To(Equal("data: [DONE]\n\n"))
+			Expect(string(e.Body)).To(Equal(body), "the cached body is not modified")
+		})
+	})
+})
@@ -0,0 +1,153 @@
+package responsecache
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"sync"
+
+	"github.com/mudler/LocalAI/core/backend"
+	"github.com/mudler/xlog"
+)
+
+// DefaultSimilarityThreshold is the cosine-similarity floor of a
+// semantic match when the config leaves it at 0. It is much stricter
+// than the router cache's: a routing decision is right for a whole
+// topic, a response only for a near-paraphrase of its request.
+const DefaultSimilarityThreshold = 0.95
+
+// semanticCandidates is how many neighbours a lookup inspects. The
+// nearest vectors may belong to other users or to requests with other
+// parameters, which the payload filter then skips.
+const semanticCandidates = 8
+
+// identicalSimilarity is the similarity from which the nearest
+// neighbour of a vector is the vector itself.
+const identicalSimilarity = 1 - 1e-6
+
+// semanticPayload points a vector at a cached response: its exact key,
+// what a match must agree on, and when it was stored, which tells the
+// entry apart from a later one under the same key.
+type semanticPayload struct {
+	Key    string `json:"key"`
+	Scope  string `json:"scope"`
+	Params string `json:"params"`
+	Stored int64  `json:"stored"`
+}
+
+// deleter is the optional capability of the local-store to remove
+// vectors. Without it, a vector whose entries all left the cache stays
+// in the store with an empty payload.
+type deleter interface {
+	Delete(ctx context.Context, vecs [][]float32) error
+}
+
+// indexMu serialises the read-modify-write of the payload of a vector.
+var indexMu sync.Mutex
+
+// Index maps request embeddings to the exact keys of cached responses.
+// The store holds one entry per vector, and the requests of different
+// users or parameters with the same conversation embed alike, so the
+// payload of a vector lists every response it leads to. Responses are
+// dropped from it when they leave the cache.
+type Index struct {
+	embedder  backend.Embedder
+	store     backend.VectorStore
+	threshold float64
+}
+
+// NewIndex binds an embedder and a vector store. Zero threshold picks
+// DefaultSimilarityThreshold.
+func NewIndex(embedder backend.Embedder, store backend.VectorStore, threshold float64) *Index {
+	if threshold <= 0 {
+		threshold = DefaultSimilarityThreshold
+	}
+	return &Index{embedder: embedder, store: store, threshold: threshold}
+}
+
+// Embed returns the vector of the conversation of k.
+func (ix *Index) Embed(ctx context.Context, k Keys) ([]float32, error) {
+	return ix.embedder.Embed(ctx, k.Text)
+}
+
+// Lookup returns the exact key of the most similar cached request with
+// the scope and parameters of k, if one clears the threshold.
+func (ix *Index) Lookup(ctx context.Context, vec []float32, k Keys) (key string, similarity float64, ok bool, err error) {
+	neighbors, err := ix.store.SearchK(ctx, vec, semanticCandidates)
+	if err != nil {
+		return "", 0, false, err
+	}
+	for _, n := range neighbors {
+		if n.Similarity < ix.threshold || (ok && n.Similarity <= similarity) {
+			continue
+		}
+		var payloads []semanticPayload
+		if json.Unmarshal(n.Payload, &payloads) != nil {
+			continue
+		}
+		for _, p := range payloads {
+			if p.Scope == k.Scope && p.Params == k.Params {
+				key, similarity, ok = p.Key, n.Similarity, true
+				break
+			}
+		}
+	}
+	return key, similarity, ok, nil
+}
+
+// Insert records vec as the embedding of e, the response cached under
+// k. Once e leaves the cache it is removed again; a response that left
+// the cache before it was indexed is not added.
+func (ix *Index) Insert(ctx context.Context, vec []float32, k Keys, e *Response) error {
+	p := semanticPayload{Key: k.Exact, Scope: k.Scope, Params: k.Params, Stored: e.StoredAt.UnixNano()}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.released {
+		return nil
+	}
+	err := ix.update(ctx, vec, func(payloads []semanticPayload) []semanticPayload {
+		payloads = slices.DeleteFunc(payloads, func(o semanticPayload) bool { return o.Key == p.Key })
+		return append(payloads, p)
+	})
+	if err != nil {
+		return err
+	}
+	e.unindex = func() {
+		err := ix.update(context.Background(), vec, func(payloads []semanticPayload) []semanticPayload {
+			return slices.DeleteFunc(payloads, func(o semanticPayload) bool { return o == p })
+		})
+		if err != nil {
+			xlog.Debug("response cache: vector removal failed", "error", err)
+		}
+	}
+	return nil
+}
+
+// update rewrites the payload list of vec with fn, removing the vector
+// once the list is empty.
+func (ix *Index) update(ctx context.Context, vec []float32, fn func([]semanticPayload) []semanticPayload) error {
+	indexMu.Lock()
+	defer indexMu.Unlock()
+
+	neighbors, err := ix.store.SearchK(ctx, vec, 1)
+	if err != nil {
+		return err
+	}
+	var payloads []semanticPayload
+	if len(neighbors) > 0 && neighbors[0].Similarity >= identicalSimilarity {
+		// An unreadable payload is replaced.
+		_ = json.Unmarshal(neighbors[0].Payload, &payloads)
+	}
+	payloads = fn(payloads)
+	if len(payloads) == 0 {
+		if d, ok := ix.store.(deleter); ok {
+			return d.Delete(ctx, [][]float32{vec})
+		}
+	}
+	data, err := json.Marshal(payloads)
+	if err != nil {
+		return err
+	}
+	return ix.store.Insert(ctx, vec, data)
+}
@@ -52,7 +52,8 @@ const (
 
 // DecisionStore persists routing decisions for the admin page and
 // future drift checks. In-process by default so a no-auth box still
-// gets a decision log; a future GORM impl can reuse the auth DB.
+// gets a decision log; when --auth is on the GORM impl
+// (NewGormDecisionStore) reuses the auth DB.
 type DecisionStore interface {
 	Record(ctx context.Context, r DecisionRecord) error
 	List(ctx context.Context, q DecisionListQuery) ([]DecisionRecord, error)
@@ -66,8 +67,15 @@ type DecisionListQuery struct {
 	CorrelationID string
 	UserID        string
 	RouterModel   string
-	Source        string
-	Limit         int
+	// Model matches decisions that routed through or to a model: the
+	// router model or the served candidate.
+	Model  string
+	Source string
+	// Since and Until bound CreatedAt to [Since, Until); zero values
+	// leave that side open.
+	Since time.Time
+	Until time.Time
+	Limit int
 }
 
 // NewMemoryDecisionStore returns a ring-buffer DecisionStore. capacity
@@ -125,6 +133,15 @@ func (s *memoryDecisionStore) List(_ context.Context, q DecisionListQuery) ([]De
 		if q.RouterModel != "" && r.RouterModel != q.RouterModel {
 			return false
 		}
+		if q.Model != "" && r.RouterModel != q.Model && r.ServedModel != q.Model {
+			return false
+		}
+		if !q.Since.IsZero() && r.CreatedAt.Before(q.Since) {
+			return false
+		}
+		if !q.Until.IsZero() && !r.CreatedAt.Before(q.Until) {
+			return false
+		}
 		if q.Source != "" {
 			// Empty source on the row is treated as SourceChat for back-
 			// compat with rows written before the field existed.
@@ -0,0 +1,205 @@
+package router
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/mudler/LocalAI/core/services/advisorylock"
+	"github.com/mudler/LocalAI/core/services/dbutil"
+	"gorm.io/gorm"
+)
+
+// decisionRow is the GORM model of a DecisionRecord. The per-label
+// scores and KNN neighbors are kept as JSON text: they are only ever
+// read back whole, never filtered on.
+type decisionRow struct {
+	ID                  string `gorm:"primaryKey;size:64"`
+	CorrelationID       string `gorm:"index;size:128"`
+	UserID              string `gorm:"index;size:64"`
+	RouterModel         string `gorm:"index;size:255"`
+	RequestedModel      string `gorm:"size:255"`
+	ServedModel         string `gorm:"index;size:255"`
+	Classifier          string `gorm:"size:64"`
+	Label               string `gorm:"size:255"`
+	Score               float64
+	LatencyMs           int64
+	Cached              bool
+	CacheSimilarity     float64
+	NearestSimilarity   float64
+	LabelScoresJSON     string `gorm:"column:label_scores;type:text"`
+	ActivationThreshold float64
+	NeighborsJSON       string    `gorm:"column:neighbors;type:text"`
+	Source              string    `gorm:"index;size:32"`
+	CreatedAt           time.Time `gorm:"index"`
+}
+
+func (decisionRow) TableName() string { return "router_decisions" }
+
+// toDecisionRow converts r, cutting the strings that come from the
+// request (the correlation ID is the client's X-Correlation-ID header)
+// down to their column sizes so one long value can't fail a batch.
+func toDecisionRow(r DecisionRecord) decisionRow {
+	row := decisionRow{
+		ID:                  r.ID,
+		CorrelationID:       dbutil.TruncateColumn(r.CorrelationID, 128),
+		UserID:              dbutil.TruncateColumn(r.UserID, 64),
+		RouterModel:         dbutil.TruncateColumn(r.RouterModel, 255),
+		RequestedModel:      dbutil.TruncateColumn(r.RequestedModel, 255),
+		ServedModel:         dbutil.TruncateColumn(r.ServedModel, 255),
+		Classifier:          dbutil.TruncateColumn(r.Classifier, 64),
+		Label:               dbutil.TruncateColumn(r.Label, 255),
+		Score:               r.Score,
+		LatencyMs:           r.LatencyMs,
+		Cached:              r.Cached,
+		CacheSimilarity:     r.CacheSimilarity,
+		NearestSimilarity:   r.NearestSimilarity,
+		ActivationThreshold: r.ActivationThreshold,
+		Source:              dbutil.TruncateColumn(r.Source, 32),
+		CreatedAt:           r.CreatedAt,
+	}
+	if len(r.LabelScores) > 0 {
+		if data, err := json.Marshal(r.LabelScores); err == nil {
+			row.LabelScoresJSON = string(data)
+		}
+	}
+	if len(r.Neighbors) > 0 {
+		if data, err := json.Marshal(r.Neighbors); err == nil {
+			row.NeighborsJSON = string(data)
+		}
+	}
+	return row
+}
+
+func (r decisionRow) record() DecisionRecord {
+	rec := DecisionRecord{
+		ID:                  r.ID,
+		CorrelationID:       r.CorrelationID,
+		UserID:              r.UserID,
+		RouterModel:         r.RouterModel,
+		RequestedModel:      r.RequestedModel,
+		ServedModel:         r.ServedModel,
+		Classifier:          r.Classifier,
+		Label:               r.Label,
+		Score:               r.Score,
+		LatencyMs:           r.LatencyMs,
+		Cached:              r.Cached,
+		CacheSimilarity:     r.CacheSimilarity,
+		NearestSimilarity:   r.NearestSimilarity,
+		ActivationThreshold: r.ActivationThreshold,
+		Source:              r.Source,
+		CreatedAt:           r.CreatedAt,
+	}
+	if r.LabelScoresJSON != "" {
+		_ = json.Unmarshal([]byte(r.LabelScoresJSON), &rec.LabelScores)
+	}
+	if r.NeighborsJSON != "" {
+		_ = json.Unmarshal([]byte(r.NeighborsJSON), &rec.Neighbors)
+	}
+	return rec
+}
+
+const (
+	// decisionFlushInterval is how long recorded decisions wait in
+	// memory before they are inserted as one batch. List and Count flush
+	// first, so the buffer is never visible to readers.
+	decisionFlushInterval = 2 * time.Second
+	// maxPendingDecisions caps the buffer while the database is failing.
+	maxPendingDecisions = 10_000
+	// decisionPruneInterval is how often decisions past the retention
+	// are deleted.
+	decisionPruneInterval = time.Hour
+)
+
+// NewGormDecisionStore returns a DecisionStore persisting to db (the
+// auth DB), batching inserts off the request path with a
+// dbutil.BatchWriter like the PII event store. Decisions older than retention are pruned hourly by
+// one replica (0 keeps them forever). The background loops stop with
+// ctx or Close; Close also flushes what is still buffered.
+func NewGormDecisionStore(ctx context.Context, db *gorm.DB, retention time.Duration) (DecisionStore, error) {
+	if err := advisorylock.WithLockCtx(ctx, db, advisorylock.KeySchemaMigrate, func() error {
+		return db.AutoMigrate(&decisionRow{})
+	}); err != nil {
+		return nil, fmt.Errorf("migrating router decision table: %w", err)
+	}
+	s := &gormDecisionStore{db: db}
+	s.rows = dbutil.NewBatchWriter(ctx, db, dbutil.BatchConfig[decisionRow]{
+		Component:     "router",
+		Noun:          "decision",
+		ID:            func(r *decisionRow) string { return r.ID },
+		FlushInterval: decisionFlushInterval,
+		MaxPending:    maxPendingDecisions,
+		Retention:     retention,
+		PruneInterval: decisionPruneInterval,
+		PruneLockKey:  advisorylock.KeyRouterDecisionsPrune,
+	})
+	return s, nil
+}
+
+type gormDecisionStore struct {
+	db   *gorm.DB
+	rows *dbutil.BatchWriter[decisionRow]
+}
+
+func (s *gormDecisionStore) Record(_ context.Context, r DecisionRecord) error {
+	return s.rows.Add(toDecisionRow(r))
+}
+
+func (s *gormDecisionStore) List(ctx context.Context, q DecisionListQuery) ([]DecisionRecord, error) {
+	s.rows.Flush()
+	limit := q.Limit
+	if limit <= 0 {
+		limit = 1000
+	}
+	tx := s.db.WithContext(ctx).Model(&decisionRow{})
+	if q.CorrelationID != "" {
+		tx = tx.Where("correlation_id = ?", dbutil.TruncateColumn(q.CorrelationID, 128))
+	}
+	if q.UserID != "" {
+		tx = tx.Where("user_id = ?", q.UserID)
+	}
+	if q.RouterModel != "" {
+		tx = tx.Where("router_model = ?", q.RouterModel)
+	}
+	if q.Model != "" {
+		tx = tx.Where("router_model = ? OR served_model = ?", q.Model, q.Model)
+	}
+	switch q.Source {
+	case "":
+	case SourceChat:
+		// Rows written before Source existed count as chat decisions.
+		tx = tx.Where("source = ? OR source = ''", q.Source)
+	default:
+		tx = tx.Where("source = ?", q.Source)
+	}
+	if !q.Since.IsZero() {
+		tx = tx.Where("created_at >= ?", q.Since)
+	}
+	if !q.Until.IsZero() {
+		tx = tx.Where("created_at < ?", q.Until)
+	}
+	var rows []decisionRow
+	if err := tx.Order("created_at DESC").Order("id DESC").Limit(limit).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	out := make([]DecisionRecord, len(rows))
+	for i, r := range rows {
+		out[i] = r.record()
+	}
+	return out, nil
+}
+
+func (s *gormDecisionStore) Count(ctx context.Context) (int, error) {
+	s.rows.Flush()
+	var n int64
+	if err := s.db.WithContext(ctx).Model(&decisionRow{}).Count(&n).Error; err != nil {
+		return 0, err
+	}
+	return int(n), nil
+}
+
+func (s *gormDecisionStore) Close() error {
+	s.rows.Close()
+	return nil
+}
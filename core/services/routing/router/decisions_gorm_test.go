@@ -0,0 +1,97 @@
+package router_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/mudler/LocalAI/core/services/routing/router"
+)
+
+var _ = Describe("GormDecisionStore", func() {
+	var (
+		store router.DecisionStore
+		ctx   context.Context
+		base  time.Time
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		dsn := fmt.Sprintf("file:router_decisions_%d?mode=memory&cache=shared", time.Now().UnixNano())
+		db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+		Expect(err).ToNot(HaveOccurred())
+		store, err = router.NewGormDecisionStore(ctx, db, 0)
+		Expect(err).ToNot(HaveOccurred())
+		DeferCleanup(store.Close)
+
+		base = time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+		for i, r := range []router.DecisionRecord{
+			{UserID: "alice", RouterModel: "smart", ServedModel: "small", Source: router.SourceChat,
+				LabelScores: []router.LabelScore{{Label: "code", Score: 0.8}},
+				Neighbors:   []router.NeighborRef{{ID: "n1", Similarity: 0.9, Labels: []string{"code"}}}},
+			{UserID: "bob", RouterModel: "smart", ServedModel: "large", Source: router.SourceAnthropic},
+			// Written before Source existed.
+			{UserID: "alice", RouterModel: "other", ServedModel: "large"},
+		} {
+			r.ID = fmt.Sprintf("d%d", i)
+			r.CreatedAt = base.Add(time.Duration(i) * time.Hour)
+			Expect(store.Record(ctx, r)).To(Succeed())
+		}
+	})
+
+	It("reads back buffered decisions newest first with their score breakdown", func() {
+		got, err := store.List(ctx, router.DecisionListQuery{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(got).To(HaveLen(3))
+		Expect(got[0].ID).To(Equal("d2"))
+		Expect(got[2].LabelScores).To(Equal([]router.LabelScore{{Label: "code", Score: 0.8}}))
+		Expect(got[2].Neighbors[0].Labels).To(ConsistOf("code"))
+		Expect(store.Count(ctx)).To(Equal(3))
+	})
+
+	It("matches Model against the router and the served model", func() {
+		got, err := store.List(ctx, router.DecisionListQuery{Model: "large"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(got).To(HaveLen(2))
+
+		got, err = store.List(ctx, router.DecisionListQuery{Model: "smart", UserID: "alice"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(got).To(HaveLen(1))
+		Expect(got[0].ID).To(Equal("d0"))
+	})
+
+	It("treats rows without a source as chat", func() {
+		got, err := store.List(ctx, router.DecisionListQuery{Source: router.SourceChat})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(got).To(HaveLen(2))
+	})
+
+	It("bounds the time window to [Since, Until)", func() {
+		got, err := store.List(ctx, router.DecisionListQuery{Since: base.Add(time.Hour), Until: base.Add(2 * time.Hour)})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(got).To(HaveLen(1))
+		Expect(got[0].ID).To(Equal("d1"))
+	})
+
+	It("cuts a long correlation ID down to its column", func() {
+		id := strings.Repeat("c", 300)
+		Expect(store.Record(ctx, router.DecisionRecord{ID: "d3", CorrelationID: id, CreatedAt: base})).To(Succeed())
+		got, err := store.List(ctx, router.DecisionListQuery{CorrelationID: id})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(got).To(HaveLen(1))
+		Expect(got[0].CorrelationID).To(HaveLen(128))
+	})
+
+	It("drops a rejected row without losing the rest of the batch", func() {
+		Expect(store.Record(ctx, router.DecisionRecord{ID: "d1", CreatedAt: base})).To(Succeed())
+		Expect(store.Record(ctx, router.DecisionRecord{ID: "d3", CreatedAt: base})).To(Succeed())
+		Expect(store.Count(ctx)).To(Equal(4))
+		Expect(store.Count(ctx)).To(Equal(4), "the rejected row is not retried")
+	})
+})
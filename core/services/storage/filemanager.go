@@ -293,3 +293,9 @@ func SkillKey(userID, skillName, filename string) string {
 	}
 	return "skills/global/" + skillName + "/" + filename
 }
+
+// BatchFileKey returns the object storage key for a /v1/files file
+// (batch input, output or error JSONL).
+func BatchFileKey(fileID string) string {
+	return "batches/files/" + fileID
+}
@@ -0,0 +1,53 @@
+package vectorstores
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mudler/LocalAI/core/schema"
+)
+
+// Chunk sizes of the "auto" strategy and the bounds OpenAI enforces on the
+// "static" one. Sizes are counted in whitespace-separated words.
+const (
+	defaultMaxChunkSize = 800
+	defaultChunkOverlap = 400
+	minMaxChunkSize     = 100
+	maxMaxChunkSize     = 4096
+)
+
+// chunkSizes validates a chunking strategy and returns the chunk size and
+// overlap it asks for.
+func chunkSizes(strategy *schema.ChunkingStrategy) (size, overlap int, err error) {
+	if strategy == nil || strategy.Type == "" || strategy.Type == "auto" {
+		return defaultMaxChunkSize, defaultChunkOverlap, nil
+	}
+	if strategy.Type != "static" || strategy.Static == nil {
+		return 0, 0, fmt.Errorf("%w: chunking_strategy must be \"auto\" or \"static\" with a static object", ErrInvalidRequest)
+	}
+	size, overlap = strategy.Static.MaxChunkSizeTokens, strategy.Static.ChunkOverlapTokens
+	if size < minMaxChunkSize || size > maxMaxChunkSize {
+		return 0, 0, fmt.Errorf("%w: max_chunk_size_tokens must be between %d and %d", ErrInvalidRequest, minMaxChunkSize, maxMaxChunkSize)
+	}
+	if overlap < 0 || overlap > size/2 {
+		return 0, 0, fmt.Errorf("%w: chunk_overlap_tokens must be between 0 and half of max_chunk_size_tokens", ErrInvalidRequest)
+	}
+	return size, overlap, nil
+}
+
+// chunkText splits text into chunks of at most size words, each repeating
+// the last overlap words of the previous one.
+func chunkText(text string, size, overlap int) []string {
+	words := strings.Fields(text)
+	if len(words) == 0 {
+		return nil
+	}
+	var chunks []string
+	for start := 0; ; start += size - overlap {
+		end := min(start+size, len(words))
+		chunks = append(chunks, strings.Join(words[start:end], " "))
+		if end == len(words) {
+			return chunks
+		}
+	}
+}
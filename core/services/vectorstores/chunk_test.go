@@ -0,0 +1,31 @@
+package vectorstores
+
+import (
+	"github.com/mudler/LocalAI/core/schema"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Chunking", func() {
+	It("overlaps consecutive chunks", func() {
+		Expect(chunkText("a b c d e f g", 4, 2)).To(Equal([]string{"a b c d", "c d e f", "e f g"}))
+		Expect(chunkText("a b", 4, 2)).To(Equal([]string{"a b"}))
+		Expect(chunkText(" \n ", 4, 2)).To(BeEmpty())
+	})
+
+	It("validates the chunking strategy", func() {
+		size, overlap, err := chunkSizes(nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect([]int{size, overlap}).To(Equal([]int{defaultMaxChunkSize, defaultChunkOverlap}))
+
+		size, overlap, err = chunkSizes(&schema.ChunkingStrategy{Type: "static", Static: &schema.StaticChunkingStrategy{MaxChunkSizeTokens: 200, ChunkOverlapTokens: 50}})
+		Expect(err).ToNot(HaveOccurred())
+		Expect([]int{size, overlap}).To(Equal([]int{200, 50}))
+
+		_, _, err = chunkSizes(&schema.ChunkingStrategy{Type: "static", Static: &schema.StaticChunkingStrategy{MaxChunkSizeTokens: 200, ChunkOverlapTokens: 150}})
+		Expect(err).To(MatchError(ErrInvalidRequest))
+		_, _, err = chunkSizes(&schema.ChunkingStrategy{Type: "semantic"})
+		Expect(err).To(MatchError(ErrInvalidRequest))
+	})
+})
@@ -0,0 +1,141 @@
+package vectorstores
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/mudler/xlog"
+)
+
+// batchIndex and deleter are optional fast paths the local-store
+// implementation provides; the Service degrades to per-chunk Insert (and to
+// "chunks persist in the index until restart" on delete) when a store
+// doesn't. Search drops stale hits either way.
+type batchIndex interface {
+	InsertBatch(ctx context.Context, vecs [][]float32, payloads [][]byte) error
+}
+
+type deleter interface {
+	Delete(ctx context.Context, vecs [][]float32) error
+}
+
+// indexName is the local-store namespace holding a vector store's
+// embeddings.
+func indexName(vectorStoreID string) string {
+	return "vector-store-" + vectorStoreID
+}
+
+// syncIndex inserts into this process's index the chunks of a vector store
+// it does not have yet: every chunk after a restart, the chunks ingested
+// since the last sync otherwise (including those ingested by another
+// replica). Chunk IDs only grow, so the highest indexed ID is the cursor.
+//
+// The index is keyed by embedding, so chunks with identical text share an
+// entry whose payload lists all their IDs; a new chunk rewrites the entry of
+// its embedding with the chunks already holding it.
+func (s *Service) syncIndex(ctx context.Context, vectorStoreID string) error {
+	s.indexMu.Lock()
+	defer s.indexMu.Unlock()
+
+	chunks, err := s.store.ChunksAfter(vectorStoreID, s.indexed[vectorStoreID])
+	if err != nil || len(chunks) == 0 {
+		return err
+	}
+	if err := s.writeIndex(ctx, vectorStoreID, chunks); err != nil {
+		return err
+	}
+	s.indexed[vectorStoreID] = chunks[len(chunks)-1].ID
+	return nil
+}
+
+// removeFromIndex drops deleted chunks from this process's index. An
+// embedding another chunk still holds is rewritten with the remaining IDs
+// rather than deleted. Other replicas keep the deleted IDs until they
+// restart; their searches skip them because the rows are gone.
+func (s *Service) removeFromIndex(ctx context.Context, vectorStoreID string, chunks []ChunkRecord) {
+	if len(chunks) == 0 {
+		return
+	}
+	s.indexMu.Lock()
+	defer s.indexMu.Unlock()
+	if err := s.writeIndex(ctx, vectorStoreID, chunks); err != nil {
+		xlog.Warn("Failed to remove chunks from the vector store index", "vector_store", vectorStoreID, "error", err)
+	}
+}
+
+// writeIndex brings the index entries of the embeddings of chunks in line
+// with the database: each is set to the IDs of the chunks holding it, or
+// deleted when none does. The caller holds indexMu.
+func (s *Service) writeIndex(ctx context.Context, vectorStoreID string, chunks []ChunkRecord) error {
+	var keys [][]byte
+	seen := map[string]bool{}
+	for _, c := range chunks {
+		if !seen[string(c.Vector)] {
+			seen[string(c.Vector)] = true
+			keys = append(keys, c.Vector)
+		}
+	}
+	ids, err := s.store.ChunkIDsByVector(vectorStoreID, keys)
+	if err != nil {
+		return err
+	}
+
+	var vecs, gone [][]float32
+	var payloads [][]byte
+	for _, k := range keys {
+		if len(ids[string(k)]) == 0 {
+			gone = append(gone, decodeVector(k))
+			continue
+		}
+		vecs = append(vecs, decodeVector(k))
+		payloads = append(payloads, encodePayload(ids[string(k)]))
+	}
+
+	index := s.index(indexName(vectorStoreID))
+	if len(gone) > 0 {
+		if d, ok := index.(deleter); ok {
+			if err := d.Delete(ctx, gone); err != nil {
+				return err
+			}
+		}
+	}
+	if len(vecs) == 0 {
+		return nil
+	}
+	if b, ok := index.(batchIndex); ok {
+		return b.InsertBatch(ctx, vecs, payloads)
+	}
+	for i := range vecs {
+		if err := index.Insert(ctx, vecs[i], payloads[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encodePayload serialises the IDs of the chunks sharing an embedding as a
+// comma-separated list.
+func encodePayload(ids []uint) []byte {
+	var out []byte
+	for i, id := range ids {
+		if i > 0 {
+			out = append(out, ',')
+		}
+		out = strconv.AppendUint(out, uint64(id), 10)
+	}
+	return out
+}
+
+// decodePayload is the inverse of encodePayload. Malformed IDs are skipped.
+func decodePayload(payload []byte) []uint {
+	var out []uint
+	for f := range strings.SplitSeq(string(payload), ",") {
+		id, err := strconv.ParseUint(f, 10, 64)
+		if err != nil {
+			continue
+		}
+		out = append(out, uint(id))
+	}
+	return out
+}
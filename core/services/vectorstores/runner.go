@@ -0,0 +1,183 @@
+package vectorstores
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+	"unicode/utf8"
+
+	"github.com/mudler/LocalAI/core/services/advisorylock"
+	"github.com/mudler/xlog"
+	"gorm.io/gorm"
+)
+
+// maxFileBytes bounds the content of a file added to a vector store.
+const maxFileBytes = 64 << 20
+
+// Error codes of a failed vector store file, as defined by OpenAI.
+const (
+	errCodeServer      = "server_error"
+	errCodeUnsupported = "unsupported_file"
+	errCodeInvalid     = "invalid_file"
+)
+
+// fileError is an ingestion failure that marks the file failed instead of
+// being retried.
+type fileError struct {
+	code    string
+	message string
+}
+
+func (e *fileError) Error() string { return e.message }
+
+// Start launches the ingestion runner: files added to a vector store are
+// processed in the background, and files left in_progress by a stopped
+// instance are picked up again.
+func (s *Service) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(s.pollInterval)
+		defer ticker.Stop()
+		for {
+			s.runPending(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			case <-s.wake:
+			}
+		}
+	}()
+}
+
+// Wake makes the runner look for pending files without waiting for the
+// next poll.
+func (s *Service) Wake() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *Service) runPending(ctx context.Context) {
+	pending, err := s.store.ListFilesInProgress()
+	if err != nil {
+		xlog.Error("Failed to list pending vector store files", "error", err)
+		return
+	}
+	for _, f := range pending {
+		s.mu.Lock()
+		_, busy := s.running[f.ID]
+		if !busy {
+			s.running[f.ID] = struct{}{}
+		}
+		s.mu.Unlock()
+		if busy {
+			continue
+		}
+		go func(f VectorStoreFileRecord) {
+			defer func() {
+				s.mu.Lock()
+				delete(s.running, f.ID)
+				s.mu.Unlock()
+			}()
+			acquired, err := advisorylock.TryWithLockCtx(ctx, s.store.DB(), advisorylock.KeyFromString("vector_store_file:"+f.ID), func() error {
+				return s.ingest(ctx, f)
+			})
+			if err != nil && ctx.Err() == nil {
+				xlog.Error("Vector store file ingestion failed", "vector_store", f.VectorStoreID, "file", f.FileID, "error", err)
+			} else if !acquired {
+				xlog.Debug("Vector store file is being ingested by another instance", "vector_store", f.VectorStoreID, "file", f.FileID)
+			}
+		}(f)
+	}
+}
+
+// ingest chunks and embeds a file, then stores the chunks and indexes them.
+// Errors caused by the file itself mark it failed; other errors leave it
+// in_progress so the next poll retries it.
+func (s *Service) ingest(ctx context.Context, f VectorStoreFileRecord) error {
+	// Another instance may have finished the file between the listing and
+	// the lock.
+	current, err := s.store.GetFile(f.VectorStoreID, f.FileID)
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && current.Status != f.Status) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	chunks, err := s.embedFile(ctx, f)
+	var ferr *fileError
+	if errors.As(err, &ferr) {
+		xlog.Warn("Vector store file failed", "vector_store", f.VectorStoreID, "file", f.FileID, "error", ferr.message)
+		return s.store.FailFile(f.ID, ferr.code, ferr.message)
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, c := range chunks {
+		f.UsageBytes += int64(len(c.Text))
+	}
+	if err := s.store.CompleteFile(&f, chunks); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	if err := s.syncIndex(ctx, f.VectorStoreID); err != nil {
+		// Searches retry the sync, so the file stays searchable.
+		xlog.Warn("Failed to index vector store file", "vector_store", f.VectorStoreID, "file", f.FileID, "error", err)
+	}
+	return nil
+}
+
+// embedFile reads a file and returns its embedded chunks.
+func (s *Service) embedFile(ctx context.Context, f VectorStoreFileRecord) ([]ChunkRecord, error) {
+	vs, err := s.store.GetVectorStore("", f.VectorStoreID)
+	if err != nil {
+		return nil, err
+	}
+	embedder := s.embedder(vs.EmbeddingModel)
+	if embedder == nil {
+		return nil, &fileError{code: errCodeServer, message: fmt.Sprintf("embedding model %q is not available", vs.EmbeddingModel)}
+	}
+
+	_, r, err := s.files.OpenFile(ctx, vs.UserID, f.FileID)
+	if err != nil {
+		return nil, &fileError{code: errCodeInvalid, message: fmt.Sprintf("reading file: %v", err)}
+	}
+	content, err := io.ReadAll(io.LimitReader(r, maxFileBytes+1))
+	r.Close()
+	if err != nil {
+		return nil, err
+	}
+	if len(content) > maxFileBytes {
+		return nil, &fileError{code: errCodeInvalid, message: fmt.Sprintf("file exceeds %d bytes", maxFileBytes)}
+	}
+	if !utf8.Valid(content) {
+		return nil, &fileError{code: errCodeUnsupported, message: "only UTF-8 text files can be added to a vector store"}
+	}
+
+	texts := chunkText(string(content), f.MaxChunkSize, f.ChunkOverlap)
+	chunks := make([]ChunkRecord, 0, len(texts))
+	for i, text := range texts {
+		vec, err := embedder.Embed(ctx, text)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			return nil, &fileError{code: errCodeServer, message: fmt.Sprintf("embedding chunk %d: %v", i, err)}
+		}
+		chunks = append(chunks, ChunkRecord{
+			VectorStoreID: f.VectorStoreID,
+			FileID:        f.FileID,
+			Index:         i,
+			Text:          text,
+			Vector:        encodeVector(vec),
+		})
+	}
+	return chunks, nil
+}
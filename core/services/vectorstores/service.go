@@ -0,0 +1,472 @@
+package vectorstores
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/mudler/LocalAI/core/backend"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/core/services/batches"
+	"github.com/mudler/xlog"
+	"gorm.io/gorm"
+)
+
+const (
+	// defaultMaxNumResults and maxMaxNumResults bound max_num_results of a
+	// search, like OpenAI.
+	defaultMaxNumResults = 10
+	maxMaxNumResults     = 50
+	// searchOverfetch widens the neighbour query so chunks deleted by another
+	// replica, which linger in this replica's index, do not shrink the page.
+	searchOverfetch = 2
+)
+
+var (
+	// ErrNotFound is returned when a vector store or one of its files does
+	// not exist or belongs to another user.
+	ErrNotFound = errors.New("not found")
+	// ErrInvalidRequest wraps every user-facing validation error.
+	ErrInvalidRequest = errors.New("invalid request")
+)
+
+// FileSource gives access to files uploaded through /v1/files. It is
+// implemented by the batches service, which owns the Files API.
+type FileSource interface {
+	GetFile(userID, id string) (*batches.FileRecord, error)
+	OpenFile(ctx context.Context, userID, id string) (*batches.FileRecord, io.ReadCloser, error)
+}
+
+// Service implements the OpenAI Vector Stores API. Files are split into
+// chunks, embedded with the vector store's embedding model and kept in the
+// database; the embeddings are served from a local-store vector index per
+// vector store, which is (re)built from the database on demand.
+type Service struct {
+	store        *Store
+	files        FileSource
+	embedder     func(modelName string) backend.Embedder
+	index        func(storeName string) backend.VectorStore
+	defaultModel string
+
+	pollInterval time.Duration
+	wake         chan struct{}
+
+	mu      sync.Mutex
+	running map[string]struct{}
+
+	// indexMu serialises index syncs; indexed records, per vector store, the
+	// highest chunk ID already inserted into this process's index.
+	indexMu sync.Mutex
+	indexed map[string]uint
+}
+
+// NewService creates a vector store service. embedder resolves an embedding
+// model by name (nil when it does not exist) and index returns the vector
+// index for a store name. defaultModel is the embedding model of vector
+// stores created without one.
+func NewService(store *Store, files FileSource, embedder func(string) backend.Embedder, index func(string) backend.VectorStore, defaultModel string) *Service {
+	return &Service{
+		store:        store,
+		files:        files,
+		embedder:     embedder,
+		index:        index,
+		defaultModel: defaultModel,
+		pollInterval: 30 * time.Second,
+		wake:         make(chan struct{}, 1),
+		running:      map[string]struct{}{},
+		indexed:      map[string]uint{},
+	}
+}
+
+// Store returns the underlying persistence layer.
+func (s *Service) Store() *Store { return s.store }
+
+// --- Vector stores ---
+
+// CreateVectorStore creates a vector store for userID and attaches the
+// files listed in the request.
+func (s *Service) CreateVectorStore(userID string, req schema.VectorStoreCreateRequest) (*schema.VectorStore, error) {
+	model := cmp.Or(req.EmbeddingModel, s.defaultModel)
+	if model == "" {
+		return nil, fmt.Errorf("%w: no embedding model configured, set embedding_model or LOCALAI_VECTOR_STORE_EMBEDDING_MODEL", ErrInvalidRequest)
+	}
+	if s.embedder(model) == nil {
+		return nil, fmt.Errorf("%w: embedding model %q not found", ErrInvalidRequest, model)
+	}
+	size, overlap, err := chunkSizes(req.ChunkingStrategy)
+	if err != nil {
+		return nil, err
+	}
+	for _, fileID := range req.FileIDs {
+		if _, err := s.sourceFile(userID, fileID); err != nil {
+			return nil, err
+		}
+	}
+
+	var metadata string
+	if len(req.Metadata) > 0 {
+		b, err := json.Marshal(req.Metadata)
+		if err != nil {
+			return nil, err
+		}
+		metadata = string(b)
+	}
+	vs := &VectorStoreRecord{UserID: userID, Name: req.Name, EmbeddingModel: model, MetadataJSON: metadata}
+	if err := s.store.CreateVectorStore(vs); err != nil {
+		return nil, err
+	}
+	for _, fileID := range req.FileIDs {
+		if _, err := s.attachFile(vs.ID, fileID, size, overlap, nil); err != nil {
+			return nil, err
+		}
+	}
+	s.Wake()
+	return s.GetVectorStore(userID, vs.ID)
+}
+
+// GetVectorStore returns a vector store owned by userID.
+func (s *Service) GetVectorStore(userID, id string) (*schema.VectorStore, error) {
+	vs, err := s.vectorStore(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	out, err := s.toSchema([]VectorStoreRecord{*vs})
+	if err != nil {
+		return nil, err
+	}
+	return &out[0], nil
+}
+
+// ListVectorStores returns one page of the vector stores owned by userID
+// and whether more follow.
+func (s *Service) ListVectorStores(userID, after string, limit int) ([]schema.VectorStore, bool, error) {
+	stores, err := s.store.ListVectorStores(userID, after, limit+1)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, false, ErrNotFound
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	hasMore := len(stores) > limit
+	if hasMore {
+		stores = stores[:limit]
+	}
+	out, err := s.toSchema(stores)
+	return out, hasMore, err
+}
+
+// DeleteVectorStore removes a vector store owned by userID with its files
+// and drops its embeddings from the index.
+func (s *Service) DeleteVectorStore(ctx context.Context, userID, id string) error {
+	if _, err := s.vectorStore(userID, id); err != nil {
+		return err
+	}
+	chunks, err := s.store.ChunksAfter(id, 0)
+	if err != nil {
+		return err
+	}
+	if err := s.store.DeleteVectorStore(id); err != nil {
+		return err
+	}
+	s.removeFromIndex(ctx, id, chunks)
+	s.indexMu.Lock()
+	delete(s.indexed, id)
+	s.indexMu.Unlock()
+	return nil
+}
+
+func (s *Service) vectorStore(userID, id string) (*VectorStoreRecord, error) {
+	vs, err := s.store.GetVectorStore(userID, id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	return vs, err
+}
+
+// --- Files ---
+
+// AddFile attaches a file owned by userID to a vector store. The file is
+// ingested in the background; the returned file is in_progress until then.
+func (s *Service) AddFile(userID, vectorStoreID string, req schema.VectorStoreFileCreateRequest) (*schema.VectorStoreFile, error) {
+	if _, err := s.vectorStore(userID, vectorStoreID); err != nil {
+		return nil, err
+	}
+	size, overlap, err := chunkSizes(req.ChunkingStrategy)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.sourceFile(userID, req.FileID); err != nil {
+		return nil, err
+	}
+	f, err := s.attachFile(vectorStoreID, req.FileID, size, overlap, req.Attributes)
+	if err != nil {
+		return nil, err
+	}
+	s.Wake()
+	out := FileToSchema(*f)
+	return &out, nil
+}
+
+func (s *Service) attachFile(vectorStoreID, fileID string, size, overlap int, attributes map[string]any) (*VectorStoreFileRecord, error) {
+	f := &VectorStoreFileRecord{
+		FileID:        fileID,
+		VectorStoreID: vectorStoreID,
+		Status:        schema.VectorStoreStatusInProgress,
+		MaxChunkSize:  size,
+		ChunkOverlap:  overlap,
+	}
+	if len(attributes) > 0 {
+		b, err := json.Marshal(attributes)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid attributes: %v", ErrInvalidRequest, err)
+		}
+		f.AttributesJSON = string(b)
+	}
+	if _, err := s.store.CreateFile(f); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// sourceFile checks that fileID names a file uploaded by userID.
+func (s *Service) sourceFile(userID, fileID string) (*batches.FileRecord, error) {
+	f, err := s.files.GetFile(userID, fileID)
+	if errors.Is(err, batches.ErrNotFound) {
+		return nil, fmt.Errorf("%w: file %q not found", ErrInvalidRequest, fileID)
+	}
+	return f, err
+}
+
+// GetFile returns a file of a vector store owned by userID.
+func (s *Service) GetFile(userID, vectorStoreID, fileID string) (*schema.VectorStoreFile, error) {
+	if _, err := s.vectorStore(userID, vectorStoreID); err != nil {
+		return nil, err
+	}
+	f, err := s.store.GetFile(vectorStoreID, fileID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	out := FileToSchema(*f)
+	return &out, nil
+}
+
+// ListFiles returns one page of the files of a vector store owned by
+// userID, optionally filtered by status, and whether more follow.
+func (s *Service) ListFiles(userID, vectorStoreID, status, after string, limit int) ([]schema.VectorStoreFile, bool, error) {
+	if _, err := s.vectorStore(userID, vectorStoreID); err != nil {
+		return nil, false, err
+	}
+	files, err := s.store.ListFiles(vectorStoreID, status, after, limit+1)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, false, ErrNotFound
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	hasMore := len(files) > limit
+	if hasMore {
+		files = files[:limit]
+	}
+	out := make([]schema.VectorStoreFile, 0, len(files))
+	for _, f := range files {
+		out = append(out, FileToSchema(f))
+	}
+	return out, hasMore, nil
+}
+
+// DeleteFile detaches a file from a vector store owned by userID and drops
+// its embeddings from the index. The uploaded file itself is kept.
+func (s *Service) DeleteFile(ctx context.Context, userID, vectorStoreID, fileID string) error {
+	if _, err := s.GetFile(userID, vectorStoreID, fileID); err != nil {
+		return err
+	}
+	chunks, err := s.store.FileChunks(vectorStoreID, fileID)
+	if err != nil {
+		return err
+	}
+	if err := s.store.DeleteFile(vectorStoreID, fileID); err != nil {
+		return err
+	}
+	s.removeFromIndex(ctx, vectorStoreID, chunks)
+	return nil
+}
+
+// --- Search ---
+
+// Search returns the chunks of a vector store owned by userID closest to
+// the query, best first. With several queries the results are merged,
+// keeping the best score of each chunk.
+func (s *Service) Search(ctx context.Context, userID, vectorStoreID string, req schema.VectorStoreSearchRequest) (*schema.VectorStoreSearchResponse, error) {
+	if len(req.Query) == 0 {
+		return nil, fmt.Errorf("%w: query is required", ErrInvalidRequest)
+	}
+	limit := defaultMaxNumResults
+	if req.MaxNumResults != nil {
+		limit = *req.MaxNumResults
+		if limit < 1 || limit > maxMaxNumResults {
+			return nil, fmt.Errorf("%w: max_num_results must be between 1 and %d", ErrInvalidRequest, maxMaxNumResults)
+		}
+	}
+	vs, err := s.vectorStore(userID, vectorStoreID)
+	if err != nil {
+		return nil, err
+	}
+	embedder := s.embedder(vs.EmbeddingModel)
+	if embedder == nil {
+		return nil, fmt.Errorf("embedding model %q of vector store %s is not available", vs.EmbeddingModel, vs.ID)
+	}
+	if err := s.syncIndex(ctx, vs.ID); err != nil {
+		return nil, err
+	}
+	if err := s.store.TouchVectorStore(vs.ID); err != nil {
+		xlog.Warn("Failed to update vector store activity", "vector_store", vs.ID, "error", err)
+	}
+
+	scores := map[uint]float64{}
+	for _, query := range req.Query {
+		vec, err := embedder.Embed(ctx, query)
+		if err != nil {
+			return nil, fmt.Errorf("embedding query: %w", err)
+		}
+		neighbors, err := s.index(indexName(vs.ID)).SearchK(ctx, vec, limit*searchOverfetch)
+		if err != nil {
+			return nil, err
+		}
+		for _, n := range neighbors {
+			for _, id := range decodePayload(n.Payload) {
+				if cur, ok := scores[id]; !ok || n.Similarity > cur {
+					scores[id] = n.Similarity
+				}
+			}
+		}
+	}
+
+	ids := make([]uint, 0, len(scores))
+	for id, score := range scores {
+		if req.RankingOptions != nil && req.RankingOptions.ScoreThreshold != nil && score < *req.RankingOptions.ScoreThreshold {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	chunks, err := s.store.ChunksByID(vs.ID, ids)
+	if err != nil {
+		return nil, err
+	}
+	ids = slices.DeleteFunc(ids, func(id uint) bool { _, ok := chunks[id]; return !ok })
+	slices.SortFunc(ids, func(a, b uint) int {
+		return cmp.Or(cmp.Compare(scores[b], scores[a]), cmp.Compare(a, b))
+	})
+	if len(ids) > limit {
+		ids = ids[:limit]
+	}
+
+	resp := &schema.VectorStoreSearchResponse{
+		Object:      "vector_store.search_results.page",
+		SearchQuery: req.Query,
+		Data:        make([]schema.VectorStoreSearchResult, 0, len(ids)),
+	}
+	files := map[string]*schema.VectorStoreSearchResult{}
+	for _, id := range ids {
+		chunk := chunks[id]
+		meta, ok := files[chunk.FileID]
+		if !ok {
+			meta = s.resultFile(vs, chunk.FileID)
+			files[chunk.FileID] = meta
+		}
+		resp.Data = append(resp.Data, schema.VectorStoreSearchResult{
+			FileID:     chunk.FileID,
+			Filename:   meta.Filename,
+			Score:      scores[id],
+			Attributes: meta.Attributes,
+			Content:    []schema.VectorStoreSearchContent{{Type: "text", Text: chunk.Text}},
+		})
+	}
+	return resp, nil
+}
+
+// resultFile returns the filename and attributes search results of a file
+// carry.
+func (s *Service) resultFile(vs *VectorStoreRecord, fileID string) *schema.VectorStoreSearchResult {
+	out := &schema.VectorStoreSearchResult{}
+	if f, err := s.files.GetFile(vs.UserID, fileID); err == nil {
+		out.Filename = f.Filename
+	}
+	if f, err := s.store.GetFile(vs.ID, fileID); err == nil && f.AttributesJSON != "" {
+		_ = json.Unmarshal([]byte(f.AttributesJSON), &out.Attributes)
+	}
+	return out
+}
+
+// --- Schema conversion ---
+
+// toSchema converts vector stores into the OpenAI vector store object,
+// deriving their status and usage from their files.
+func (s *Service) toSchema(stores []VectorStoreRecord) ([]schema.VectorStore, error) {
+	ids := make([]string, 0, len(stores))
+	for _, vs := range stores {
+		ids = append(ids, vs.ID)
+	}
+	stats, err := s.store.FileStats(ids)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]schema.VectorStore, 0, len(stores))
+	for _, vs := range stores {
+		st := stats[vs.ID]
+		v := schema.VectorStore{
+			ID:             vs.ID,
+			Object:         "vector_store",
+			CreatedAt:      vs.CreatedAt.Unix(),
+			Name:           vs.Name,
+			UsageBytes:     st.UsageBytes,
+			FileCounts:     st.Counts,
+			Status:         schema.VectorStoreStatusCompleted,
+			EmbeddingModel: vs.EmbeddingModel,
+		}
+		if st.Counts.InProgress > 0 {
+			v.Status = schema.VectorStoreStatusInProgress
+		}
+		if vs.LastActiveAt != nil {
+			t := vs.LastActiveAt.Unix()
+			v.LastActiveAt = &t
+		}
+		if vs.MetadataJSON != "" {
+			_ = json.Unmarshal([]byte(vs.MetadataJSON), &v.Metadata)
+		}
+		out = append(out, v)
+	}
+	return out, nil
+}
+
+// FileToSchema converts a VectorStoreFileRecord into the OpenAI vector
+// store file object.
+func FileToSchema(f VectorStoreFileRecord) schema.VectorStoreFile {
+	out := schema.VectorStoreFile{
+		ID:            f.FileID,
+		Object:        "vector_store.file",
+		CreatedAt:     f.CreatedAt.Unix(),
+		VectorStoreID: f.VectorStoreID,
+		Status:        f.Status,
+		UsageBytes:    f.UsageBytes,
+		ChunkingStrategy: &schema.ChunkingStrategy{
+			Type:   "static",
+			Static: &schema.StaticChunkingStrategy{MaxChunkSizeTokens: f.MaxChunkSize, ChunkOverlapTokens: f.ChunkOverlap},
+		},
+	}
+	if f.Status == schema.VectorStoreStatusFailed {
+		out.LastError = &schema.VectorStoreFileError{Code: f.LastErrorCode, Message: f.LastErrorMessage}
+	}
+	if f.AttributesJSON != "" {
+		_ = json.Unmarshal([]byte(f.AttributesJSON), &out.Attributes)
+	}
+	return out
+}
@@ -0,0 +1,283 @@
+//go:build auth
+
+package vectorstores
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mudler/LocalAI/core/backend"
+	"github.com/mudler/LocalAI/core/http/auth"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/core/services/batches"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// bagOfWords embeds text as normalised hashed word counts, so texts sharing
+// words are similar.
+type bagOfWords struct{}
+
+func (bagOfWords) Embed(_ context.Context, text string) ([]float32, error) {
+	vec := make([]float32, 64)
+	for _, w := range strings.Fields(strings.ToLower(text)) {
+		h := fnv.New32a()
+		h.Write([]byte(strings.Trim(w, ".,?!")))
+		vec[h.Sum32()%64]++
+	}
+	var norm float64
+	for _, v := range vec {
+		norm += float64(v * v)
+	}
+	for i := range vec {
+		vec[i] /= float32(math.Sqrt(norm))
+	}
+	return vec, nil
+}
+
+// memoryIndex is an in-memory stand-in for a local-store namespace.
+type memoryIndex struct {
+	mu      sync.Mutex
+	entries map[string][]byte
+	vecs    map[string][]float32
+}
+
+func newMemoryIndex() *memoryIndex {
+	return &memoryIndex{entries: map[string][]byte{}, vecs: map[string][]float32{}}
+}
+
+func vecKey(vec []float32) string { return fmt.Sprint(vec) }
+
+func (m *memoryIndex) Insert(_ context.Context, vec []float32, payload []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries[vecKey(vec)] = payload
+	m.vecs[vecKey(vec)] = vec
+	return nil
+}
+
+func (m *memoryIndex) Delete(_ context.Context, vecs [][]float32) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, v := range vecs {
+		delete(m.entries, vecKey(v))
+		delete(m.vecs, vecKey(v))
+	}
+	return nil
+}
+
+func (m *memoryIndex) SearchK(_ context.Context, query []float32, k int) ([]backend.Neighbor, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []backend.Neighbor
+	for key, vec := range m.vecs {
+		var dot float64
+		for i := range vec {
+			dot += float64(vec[i] * query[i])
+		}
+		out = append(out, backend.Neighbor{Similarity: dot, Payload: m.entries[key]})
+	}
+	slices.SortFunc(out, func(a, b backend.Neighbor) int {
+		if a.Similarity > b.Similarity {
+			return -1
+		}
+		return 1
+	})
+	if len(out) > k {
+		out = out[:k]
+	}
+	return out, nil
+}
+
+func (m *memoryIndex) Search(ctx context.Context, vec []float32) (float64, []byte, bool, error) {
+	n, err := m.SearchK(ctx, vec, 1)
+	if err != nil || len(n) == 0 {
+		return 0, nil, false, err
+	}
+	return n[0].Similarity, n[0].Payload, true, nil
+}
+
+var _ = Describe("Vector store service", func() {
+	var (
+		svc     *Service
+		store   *Store
+		files   *batches.Service
+		indexes map[string]*memoryIndex
+		ctx     context.Context
+		cancel  context.CancelFunc
+	)
+
+	newService := func() *Service {
+		indexes = map[string]*memoryIndex{}
+		var mu sync.Mutex
+		s := NewService(store, files,
+			func(model string) backend.Embedder {
+				if model != "embedder" {
+					return nil
+				}
+				return bagOfWords{}
+			},
+			func(name string) backend.VectorStore {
+				mu.Lock()
+				defer mu.Unlock()
+				if indexes[name] == nil {
+					indexes[name] = newMemoryIndex()
+				}
+				return indexes[name]
+			},
+			"embedder")
+		s.pollInterval = 20 * time.Millisecond
+		return s
+	}
+
+	upload := func(userID, name, content string) string {
+		f, err := files.UploadFile(ctx, userID, name, schema.FilePurposeAssistants, strings.NewReader(content))
+		Expect(err).ToNot(HaveOccurred())
+		return f.ID
+	}
+
+	waitIngested := func(userID, vsID string) *schema.VectorStore {
+		var vs *schema.VectorStore
+		Eventually(func() string {
+			var err error
+			vs, err = svc.GetVectorStore(userID, vsID)
+			Expect(err).ToNot(HaveOccurred())
+			return vs.Status
+		}, "5s", "20ms").Should(Equal(schema.VectorStoreStatusCompleted))
+		return vs
+	}
+
+	search := func(svc *Service, userID, vsID, query string) []schema.VectorStoreSearchResult {
+		resp, err := svc.Search(ctx, userID, vsID, schema.VectorStoreSearchRequest{Query: schema.VectorStoreSearchQuery{query}})
+		Expect(err).ToNot(HaveOccurred())
+		return resp.Data
+	}
+
+	BeforeEach(func() {
+		db, err := auth.InitDB(":memory:")
+		Expect(err).ToNot(HaveOccurred())
+		bStore, err := batches.NewStore(db)
+		Expect(err).ToNot(HaveOccurred())
+		files, err = batches.NewService(bStore, GinkgoT().TempDir(), nil, 1)
+		Expect(err).ToNot(HaveOccurred())
+		store, err = NewStore(db)
+		Expect(err).ToNot(HaveOccurred())
+		ctx, cancel = context.WithCancel(context.Background())
+		DeferCleanup(cancel)
+		svc = newService()
+		svc.Start(ctx)
+	})
+
+	It("ingests files and searches them", func() {
+		paris := upload("alice", "paris.txt", "The capital of France is Paris.")
+		bananas := upload("alice", "bananas.txt", "Bananas are a yellow fruit.")
+
+		vs, err := svc.CreateVectorStore("alice", schema.VectorStoreCreateRequest{Name: "docs", FileIDs: []string{paris}})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(vs.EmbeddingModel).To(Equal("embedder"))
+		_, err = svc.AddFile("alice", vs.ID, schema.VectorStoreFileCreateRequest{FileID: bananas, Attributes: map[string]any{"kind": "fruit"}})
+		Expect(err).ToNot(HaveOccurred())
+
+		vs = waitIngested("alice", vs.ID)
+		Expect(vs.FileCounts).To(Equal(schema.VectorStoreFileCounts{Completed: 2, Total: 2}))
+		Expect(vs.UsageBytes).To(BeNumerically(">", 0))
+
+		results := search(svc, "alice", vs.ID, "what is the capital of France?")
+		Expect(results).To(HaveLen(2))
+		Expect(results[0].FileID).To(Equal(paris))
+		Expect(results[0].Filename).To(Equal("paris.txt"))
+		Expect(results[0].Content[0].Text).To(Equal("The capital of France is Paris."))
+		Expect(results[1].Attributes).To(HaveKeyWithValue("kind", "fruit"))
+
+		threshold := results[0].Score - 0.01
+		resp, err := svc.Search(ctx, "alice", vs.ID, schema.VectorStoreSearchRequest{
+			Query:          schema.VectorStoreSearchQuery{"what is the capital of France?"},
+			RankingOptions: &schema.VectorStoreRankingOptions{ScoreThreshold: &threshold},
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(resp.Data).To(HaveLen(1))
+	})
+
+	It("fails files that are not UTF-8 text", func() {
+		bin := upload("alice", "blob.bin", "\xff\xfe\x00\x01")
+		vs, err := svc.CreateVectorStore("alice", schema.VectorStoreCreateRequest{FileIDs: []string{bin}})
+		Expect(err).ToNot(HaveOccurred())
+
+		vs = waitIngested("alice", vs.ID)
+		Expect(vs.FileCounts.Failed).To(Equal(1))
+		f, err := svc.GetFile("alice", vs.ID, bin)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(f.LastError).ToNot(BeNil())
+		Expect(f.LastError.Code).To(Equal(errCodeUnsupported))
+	})
+
+	It("scopes vector stores and files to their owner", func() {
+		vs, err := svc.CreateVectorStore("alice", schema.VectorStoreCreateRequest{})
+		Expect(err).ToNot(HaveOccurred())
+
+		_, err = svc.GetVectorStore("bob", vs.ID)
+		Expect(err).To(MatchError(ErrNotFound))
+		_, err = svc.AddFile("alice", vs.ID, schema.VectorStoreFileCreateRequest{FileID: upload("bob", "b.txt", "bob's notes")})
+		Expect(err).To(MatchError(ErrInvalidRequest))
+
+		_, err = svc.CreateVectorStore("alice", schema.VectorStoreCreateRequest{EmbeddingModel: "missing"})
+		Expect(err).To(MatchError(ErrInvalidRequest))
+	})
+
+	It("drops deleted files from search results", func() {
+		paris := upload("alice", "paris.txt", "The capital of France is Paris.")
+		vs, err := svc.CreateVectorStore("alice", schema.VectorStoreCreateRequest{FileIDs: []string{paris}})
+		Expect(err).ToNot(HaveOccurred())
+		waitIngested("alice", vs.ID)
+		Expect(search(svc, "alice", vs.ID, "France")).To(HaveLen(1))
+
+		Expect(svc.DeleteFile(ctx, "alice", vs.ID, paris)).To(Succeed())
+		Expect(search(svc, "alice", vs.ID, "France")).To(BeEmpty())
+		_, err = svc.GetFile("alice", vs.ID, paris)
+		Expect(err).To(MatchError(ErrNotFound))
+
+		Expect(svc.DeleteVectorStore(ctx, "alice", vs.ID)).To(Succeed())
+		_, err = svc.GetVectorStore("alice", vs.ID)
+		Expect(err).To(MatchError(ErrNotFound))
+	})
+
+	It("keeps a chunk another file shares when one file is deleted", func() {
+		first := upload("alice", "first.txt", "The capital of France is Paris.")
+		second := upload("alice", "second.txt", "The capital of France is Paris.")
+		vs, err := svc.CreateVectorStore("alice", schema.VectorStoreCreateRequest{FileIDs: []string{first, second}})
+		Expect(err).ToNot(HaveOccurred())
+		waitIngested("alice", vs.ID)
+
+		results := search(svc, "alice", vs.ID, "France")
+		Expect(results).To(HaveLen(2))
+		Expect([]string{results[0].FileID, results[1].FileID}).To(ConsistOf(first, second))
+
+		Expect(svc.DeleteFile(ctx, "alice", vs.ID, first)).To(Succeed())
+		results = search(svc, "alice", vs.ID, "France")
+		Expect(results).To(HaveLen(1))
+		Expect(results[0].FileID).To(Equal(second))
+
+		Expect(svc.DeleteFile(ctx, "alice", vs.ID, second)).To(Succeed())
+		Expect(search(svc, "alice", vs.ID, "France")).To(BeEmpty())
+		Expect(indexes[indexName(vs.ID)].entries).To(BeEmpty())
+	})
+
+	It("rebuilds the index from the database", func() {
+		paris := upload("alice", "paris.txt", "The capital of France is Paris.")
+		vs, err := svc.CreateVectorStore("alice", schema.VectorStoreCreateRequest{FileIDs: []string{paris}})
+		Expect(err).ToNot(HaveOccurred())
+		waitIngested("alice", vs.ID)
+
+		restarted := newService()
+		results := search(restarted, "alice", vs.ID, "France")
+		Expect(results).To(HaveLen(1))
+		Expect(results[0].FileID).To(Equal(paris))
+	})
+})
@@ -0,0 +1,372 @@
+package vectorstores
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/core/services/advisorylock"
+	"gorm.io/gorm"
+)
+
+// VectorStoreRecord is the GORM model for a vector store.
+type VectorStoreRecord struct {
+	ID             string     `gorm:"primaryKey;size:64" json:"id"`
+	UserID         string     `gorm:"index;size:36" json:"user_id"`
+	Name           string     `gorm:"size:255" json:"name"`
+	EmbeddingModel string     `gorm:"size:255" json:"embedding_model"`
+	MetadataJSON   string     `gorm:"column:metadata;type:text" json:"-"`
+	LastActiveAt   *time.Time `json:"last_active_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+func (VectorStoreRecord) TableName() string { return "vector_stores" }
+
+// VectorStoreFileRecord is the GORM model for a file attached to a vector
+// store. The file itself is a /v1/files upload; the row tracks its
+// ingestion.
+type VectorStoreFileRecord struct {
+	ID               string    `gorm:"primaryKey;size:140" json:"id"`
+	FileID           string    `gorm:"index;size:64" json:"file_id"`
+	VectorStoreID    string    `gorm:"index;size:64" json:"vector_store_id"`
+	Status           string    `gorm:"index;size:32" json:"status"`
+	LastErrorCode    string    `gorm:"size:64" json:"last_error_code,omitempty"`
+	LastErrorMessage string    `gorm:"type:text" json:"last_error_message,omitempty"`
+	UsageBytes       int64     `json:"usage_bytes"`
+	MaxChunkSize     int       `json:"max_chunk_size"`
+	ChunkOverlap     int       `json:"chunk_overlap"`
+	AttributesJSON   string    `gorm:"column:attributes;type:text" json:"-"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+func (VectorStoreFileRecord) TableName() string { return "vector_store_files" }
+
+// ChunkRecord is one embedded chunk of a vector store file. The database is
+// the source of truth: the vector index only holds the embeddings, each with
+// the IDs of the chunks sharing it, and is rebuilt from these rows when a
+// process starts.
+type ChunkRecord struct {
+	ID            uint   `gorm:"primaryKey;autoIncrement" json:"id"`
+	VectorStoreID string `gorm:"index;size:64" json:"vector_store_id"`
+	FileID        string `gorm:"index;size:64" json:"file_id"`
+	Index         int    `gorm:"column:chunk_index" json:"index"`
+	Text          string `gorm:"type:text" json:"text"`
+	Vector        []byte `json:"-"`
+}
+
+func (ChunkRecord) TableName() string { return "vector_store_chunks" }
+
+// Store provides database persistence for vector stores, their files and
+// chunks.
+type Store struct {
+	db *gorm.DB
+}
+
+// NewStore creates a new Store and auto-migrates the schema.
+// Uses an advisory lock to prevent concurrent migration races when
+// multiple frontend replicas start at the same time.
+func NewStore(db *gorm.DB) (*Store, error) {
+	if err := advisorylock.WithLockCtx(context.Background(), db, advisorylock.KeySchemaMigrate, func() error {
+		return db.AutoMigrate(&VectorStoreRecord{}, &VectorStoreFileRecord{}, &ChunkRecord{})
+	}); err != nil {
+		return nil, fmt.Errorf("migrating vector store tables: %w", err)
+	}
+	return &Store{db: db}, nil
+}
+
+// DB returns the underlying database handle.
+func (s *Store) DB() *gorm.DB { return s.db }
+
+// --- Vector stores ---
+
+// CreateVectorStore stores a new vector store.
+func (s *Store) CreateVectorStore(vs *VectorStoreRecord) error {
+	if vs.ID == "" {
+		vs.ID = "vs_" + uuid.New().String()
+	}
+	vs.CreatedAt = time.Now()
+	return s.db.Create(vs).Error
+}
+
+// GetVectorStore retrieves a vector store by ID. When userID is non-empty
+// the vector store must belong to that user.
+func (s *Store) GetVectorStore(userID, id string) (*VectorStoreRecord, error) {
+	var vs VectorStoreRecord
+	q := s.db.Where("id = ?", id)
+	if userID != "" {
+		q = q.Where("user_id = ?", userID)
+	}
+	if err := q.First(&vs).Error; err != nil {
+		return nil, err
+	}
+	return &vs, nil
+}
+
+// ListVectorStores returns vector stores of a user, newest first. after is
+// the ID of the last vector store of the previous page (cursor pagination);
+// limit <= 0 means no limit.
+func (s *Store) ListVectorStores(userID, after string, limit int) ([]VectorStoreRecord, error) {
+	var stores []VectorStoreRecord
+	q := s.db.Order("created_at DESC").Order("id DESC")
+	if userID != "" {
+		q = q.Where("user_id = ?", userID)
+	}
+	if after != "" {
+		cursor, err := s.GetVectorStore(userID, after)
+		if err != nil {
+			return nil, err
+		}
+		q = q.Where("created_at < ? OR (created_at = ? AND id < ?)", cursor.CreatedAt, cursor.CreatedAt, cursor.ID)
+	}
+	if limit > 0 {
+		q = q.Limit(limit)
+	}
+	if err := q.Find(&stores).Error; err != nil {
+		return nil, err
+	}
+	return stores, nil
+}
+
+// TouchVectorStore records that a vector store has just been used.
+func (s *Store) TouchVectorStore(id string) error {
+	return s.db.Model(&VectorStoreRecord{}).Where("id = ?", id).Update("last_active_at", time.Now()).Error
+}
+
+// DeleteVectorStore removes a vector store with its files and chunks.
+func (s *Store) DeleteVectorStore(id string) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("vector_store_id = ?", id).Delete(&ChunkRecord{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("vector_store_id = ?", id).Delete(&VectorStoreFileRecord{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("id = ?", id).Delete(&VectorStoreRecord{}).Error
+	})
+}
+
+// FileStats aggregates the files of a vector store.
+type FileStats struct {
+	Counts     schema.VectorStoreFileCounts
+	UsageBytes int64
+}
+
+// FileStats counts the files of each vector store in ids by status and sums
+// their usage.
+func (s *Store) FileStats(ids []string) (map[string]FileStats, error) {
+	var rows []struct {
+		VectorStoreID string
+		Status        string
+		Files         int
+		Bytes         int64
+	}
+	if err := s.db.Model(&VectorStoreFileRecord{}).
+		Select("vector_store_id, status, COUNT(*) AS files, COALESCE(SUM(usage_bytes), 0) AS bytes").
+		Where("vector_store_id IN ?", ids).
+		Group("vector_store_id, status").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	stats := make(map[string]FileStats, len(ids))
+	for _, r := range rows {
+		st := stats[r.VectorStoreID]
+		switch r.Status {
+		case schema.VectorStoreStatusInProgress:
+			st.Counts.InProgress += r.Files
+		case schema.VectorStoreStatusCompleted:
+			st.Counts.Completed += r.Files
+		case schema.VectorStoreStatusFailed:
+			st.Counts.Failed += r.Files
+		}
+		st.Counts.Total += r.Files
+		st.UsageBytes += r.Bytes
+		stats[r.VectorStoreID] = st
+	}
+	return stats, nil
+}
+
+// --- Files ---
+
+// vectorStoreFileID scopes a file ID to a vector store: OpenAI identifies a
+// vector store file by the ID of the file it was created from.
+func vectorStoreFileID(vectorStoreID, fileID string) string {
+	return vectorStoreID + "/" + fileID
+}
+
+// CreateFile stores a new vector store file. It reports false when the file
+// is already attached to the vector store.
+func (s *Store) CreateFile(f *VectorStoreFileRecord) (bool, error) {
+	f.ID = vectorStoreFileID(f.VectorStoreID, f.FileID)
+	f.CreatedAt = time.Now()
+	res := s.db.Where("id = ?", f.ID).FirstOrCreate(f)
+	return res.RowsAffected > 0, res.Error
+}
+
+// GetFile retrieves the file fileID of a vector store.
+func (s *Store) GetFile(vectorStoreID, fileID string) (*VectorStoreFileRecord, error) {
+	var f VectorStoreFileRecord
+	if err := s.db.Where("id = ?", vectorStoreFileID(vectorStoreID, fileID)).First(&f).Error; err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+// ListFiles returns the files of a vector store, newest first, optionally
+// filtered by status. after is the file ID of the last file of the previous
+// page; limit <= 0 means no limit.
+func (s *Store) ListFiles(vectorStoreID, status, after string, limit int) ([]VectorStoreFileRecord, error) {
+	var files []VectorStoreFileRecord
+	q := s.db.Where("vector_store_id = ?", vectorStoreID).Order("created_at DESC").Order("id DESC")
+	if status != "" {
+		q = q.Where("status = ?", status)
+	}
+	if after != "" {
+		cursor, err := s.GetFile(vectorStoreID, after)
+		if err != nil {
+			return nil, err
+		}
+		q = q.Where("created_at < ? OR (created_at = ? AND id < ?)", cursor.CreatedAt, cursor.CreatedAt, cursor.ID)
+	}
+	if limit > 0 {
+		q = q.Limit(limit)
+	}
+	if err := q.Find(&files).Error; err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// ListFilesInProgress returns every file still waiting for ingestion,
+// across all vector stores.
+func (s *Store) ListFilesInProgress() ([]VectorStoreFileRecord, error) {
+	var files []VectorStoreFileRecord
+	if err := s.db.Where("status = ?", schema.VectorStoreStatusInProgress).
+		Order("created_at ASC").Find(&files).Error; err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// CompleteFile stores the chunks of an ingested file and marks it completed
+// in one transaction, so a file is never completed with missing chunks.
+func (s *Store) CompleteFile(f *VectorStoreFileRecord, chunks []ChunkRecord) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("vector_store_id = ? AND file_id = ?", f.VectorStoreID, f.FileID).Delete(&ChunkRecord{}).Error; err != nil {
+			return err
+		}
+		if len(chunks) > 0 {
+			if err := tx.CreateInBatches(chunks, 100).Error; err != nil {
+				return err
+			}
+		}
+		res := tx.Model(&VectorStoreFileRecord{}).Where("id = ?", f.ID).Updates(map[string]any{
+			"status":      schema.VectorStoreStatusCompleted,
+			"usage_bytes": f.UsageBytes,
+		})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			// Detached while it was being ingested.
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+}
+
+// FailFile marks a file failed with the given error.
+func (s *Store) FailFile(id, code, message string) error {
+	return s.db.Model(&VectorStoreFileRecord{}).Where("id = ?", id).Updates(map[string]any{
+		"status":             schema.VectorStoreStatusFailed,
+		"last_error_code":    code,
+		"last_error_message": message,
+	}).Error
+}
+
+// DeleteFile detaches a file from a vector store and removes its chunks.
+func (s *Store) DeleteFile(vectorStoreID, fileID string) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("vector_store_id = ? AND file_id = ?", vectorStoreID, fileID).Delete(&ChunkRecord{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("id = ?", vectorStoreFileID(vectorStoreID, fileID)).Delete(&VectorStoreFileRecord{}).Error
+	})
+}
+
+// --- Chunks ---
+
+// ChunksAfter returns the chunks of a vector store with an ID greater than
+// afterID, in ID order.
+func (s *Store) ChunksAfter(vectorStoreID string, afterID uint) ([]ChunkRecord, error) {
+	var chunks []ChunkRecord
+	if err := s.db.Where("vector_store_id = ? AND id > ?", vectorStoreID, afterID).
+		Order("id ASC").Find(&chunks).Error; err != nil {
+		return nil, err
+	}
+	return chunks, nil
+}
+
+// ChunksByID returns the chunks of a vector store among ids, keyed by ID.
+// Chunks deleted since they were indexed are absent.
+func (s *Store) ChunksByID(vectorStoreID string, ids []uint) (map[uint]ChunkRecord, error) {
+	var chunks []ChunkRecord
+	if err := s.db.Where("vector_store_id = ? AND id IN ?", vectorStoreID, ids).Find(&chunks).Error; err != nil {
+		return nil, err
+	}
+	out := make(map[uint]ChunkRecord, len(chunks))
+	for _, c := range chunks {
+		out[c.ID] = c
+	}
+	return out, nil
+}
+
+// ChunkIDsByVector returns the IDs of the chunks of a vector store whose
+// encoded embedding is among vecs, in ID order, keyed by encoded embedding.
+// Chunks with identical text share an embedding, and so an index entry.
+func (s *Store) ChunkIDsByVector(vectorStoreID string, vecs [][]byte) (map[string][]uint, error) {
+	out := map[string][]uint{}
+	for batch := range slices.Chunk(vecs, 500) {
+		var chunks []ChunkRecord
+		if err := s.db.Select("id", "vector").
+			Where("vector_store_id = ? AND vector IN ?", vectorStoreID, batch).
+			Order("id ASC").Find(&chunks).Error; err != nil {
+			return nil, err
+		}
+		for _, c := range chunks {
+			out[string(c.Vector)] = append(out[string(c.Vector)], c.ID)
+		}
+	}
+	return out, nil
+}
+
+// FileChunks returns the chunks of a file of a vector store.
+func (s *Store) FileChunks(vectorStoreID, fileID string) ([]ChunkRecord, error) {
+	var chunks []ChunkRecord
+	if err := s.db.Where("vector_store_id = ? AND file_id = ?", vectorStoreID, fileID).Find(&chunks).Error; err != nil {
+		return nil, err
+	}
+	return chunks, nil
+}
+
+// encodeVector serialises an embedding as little-endian float32s.
+func encodeVector(vec []float32) []byte {
+	out := make([]byte, 4*len(vec))
+	for i, v := range vec {
+		binary.LittleEndian.PutUint32(out[4*i:], math.Float32bits(v))
+	}
+	return out
+}
+
+// decodeVector is the inverse of encodeVector.
+func decodeVector(b []byte) []float32 {
+	out := make([]float32, len(b)/4)
+	for i := range out {
+		out[i] = math.Float32frombits(binary.LittleEndian.Uint32(b[4*i:]))
+	}
+	return out
+}
@@ -0,0 +1,13 @@
+package vectorstores
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestVectorStores(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Vector stores test suite")
+}
@@ -1,5 +1,7 @@
 package worker
 
+import "github.com/mudler/LocalAI/pkg/tracing"
+
 // Config is the configuration for the distributed agent worker.
 //
 // Field tags are kong/kong-env metadata read by core/cli/worker.go's WorkerCMD,
@@ -88,6 +90,24 @@ type Config struct {
 	StorageRegion    string `env:"LOCALAI_STORAGE_REGION" help:"S3 region" group:"distributed"`
 	StorageAccessKey string `env:"LOCALAI_STORAGE_ACCESS_KEY" help:"S3 access key" group:"distributed"`
 	StorageSecretKey string `env:"LOCALAI_STORAGE_SECRET_KEY" help:"S3 secret key" group:"distributed"`
+
+	// OpenTelemetry trace export, same knobs as `local-ai run`. Backends the
+	// worker spawns inherit them.
+	OTelEndpoint    string  `name:"otel-endpoint" env:"LOCALAI_OTEL_ENDPOINT" help:"OTLP collector to export OpenTelemetry traces to, as host:port or URL. Empty falls back to OTEL_EXPORTER_OTLP_ENDPOINT; with neither set, no spans are exported." group:"observability"`
+	OTelProtocol    string  `name:"otel-protocol" env:"LOCALAI_OTEL_PROTOCOL" default:"grpc" enum:"grpc,http/protobuf" help:"OTLP transport: grpc or http/protobuf" group:"observability"`
+	OTelInsecure    bool    `name:"otel-insecure" env:"LOCALAI_OTEL_INSECURE" default:"false" help:"Connect to the OTLP collector without TLS" group:"observability"`
+	OTelSampleRatio float64 `name:"otel-sample-ratio" env:"LOCALAI_OTEL_SAMPLE_RATIO" default:"1" help:"Fraction of new traces to record, between 0 and 1. Requests arriving with a sampled traceparent are always recorded." group:"observability"`
+}
+
+// tracingConfig is the OpenTelemetry export configuration of this worker.
+func (c Config) tracingConfig() tracing.Config {
+	return tracing.Config{
+		Endpoint:    c.OTelEndpoint,
+		Protocol:    c.OTelProtocol,
+		Insecure:    c.OTelInsecure,
+		SampleRatio: c.OTelSampleRatio,
+		ServiceName: "local-ai-worker",
+	}
 }
 
 // NatsAuthRequired reports whether NATS JWT credentials must be present — the
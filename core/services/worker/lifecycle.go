@@ -12,7 +12,10 @@ import (
 	"github.com/mudler/LocalAI/core/gallery"
 	"github.com/mudler/LocalAI/core/services/messaging"
 	grpc "github.com/mudler/LocalAI/pkg/grpc"
+	"github.com/mudler/LocalAI/pkg/tracing"
 	"github.com/mudler/xlog"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // subscribeLifecycleEvents wires every NATS subject this worker accepts to its
@@ -21,7 +24,7 @@ import (
 // subject a 2-line patch (one line here, one new method) instead of grafting
 // onto a monolith.
 func (s *backendSupervisor) subscribeLifecycleEvents() error {
-	if _, err := s.nats.SubscribeReply(messaging.SubjectNodeBackendInstall(s.nodeID), s.handleBackendInstall); err != nil {
+	if _, err := messaging.SubscribeReplyContext(s.nats, messaging.SubjectNodeBackendInstall(s.nodeID), s.handleBackendInstall); err != nil {
 		return fmt.Errorf("subscribing to backend install events: %w", err)
 	}
 	if _, err := s.nats.SubscribeReply(messaging.SubjectNodeBackendUpgrade(s.nodeID), s.handleBackendUpgrade); err != nil {
@@ -65,14 +68,15 @@ func (s *backendSupervisor) handleModelStop(data []byte, reply func([]byte)) {
 
 // handleBackendInstall is the NATS callback for backend.install — install
 // backend (idempotent: skips download if binary exists on disk) + start gRPC
-// process (request-reply).
+// process (request-reply). ctx carries the requesting frontend's trace, so
+// the install shows up under the request that triggered the cold load.
 //
 // Each request runs in its own goroutine so that a slow install on one
 // backend does NOT head-of-line-block install requests for unrelated
 // backends arriving on the same subscription. Per-backend serialization
 // is provided by lockBackend so two requests targeting the same on-disk
 // artifact don't race the gallery directory.
-func (s *backendSupervisor) handleBackendInstall(data []byte, reply func([]byte)) {
+func (s *backendSupervisor) handleBackendInstall(ctx context.Context, data []byte, reply func([]byte)) {
 	go func() {
 		xlog.Info("Received NATS backend.install event")
 		var req messaging.BackendInstallRequest
@@ -82,6 +86,12 @@ func (s *backendSupervisor) handleBackendInstall(data []byte, reply func([]byte)
 			return
 		}
 
+		_, span := tracing.Start(ctx, "worker.backend_install", trace.WithAttributes(
+			attribute.String("localai.backend", req.Backend),
+			attribute.String("localai.model", req.ModelID),
+			attribute.Int("localai.replica", int(req.ReplicaIndex)),
+		))
+
 		release := s.lockBackend(req.Backend)
 		defer release()
 
@@ -90,6 +100,7 @@ func (s *backendSupervisor) handleBackendInstall(data []byte, reply func([]byte)
 		// update with new worker + old master keeps working; new masters
 		// send to backend.upgrade instead.
 		addr, err := s.installBackend(req, req.Force)
+		tracing.End(span, err)
 		if err != nil {
 			xlog.Error("Failed to install backend via NATS", "error", err)
 			resp := messaging.BackendInstallReply{Success: false, Error: err.Error()}
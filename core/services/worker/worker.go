@@ -22,6 +22,7 @@ import (
 	"github.com/mudler/LocalAI/pkg/model"
 	"github.com/mudler/LocalAI/pkg/sanitize"
 	"github.com/mudler/LocalAI/pkg/system"
+	"github.com/mudler/LocalAI/pkg/tracing"
 	"github.com/mudler/xlog"
 )
 
@@ -38,6 +39,18 @@ func Run(ctx *cliContext.Context, cfg *Config) error {
 		return fmt.Errorf("registration auth is required (LOCALAI_REGISTRATION_REQUIRE_AUTH or LOCALAI_DISTRIBUTED_REQUIRE_AUTH) but LOCALAI_REGISTRATION_TOKEN is empty — refusing to start an unauthenticated file-transfer server")
 	}
 
+	tracingShutdown, err := tracing.Setup(context.Background(), cfg.tracingConfig())
+	if err != nil {
+		return fmt.Errorf("setting up tracing: %w", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := tracingShutdown(ctx); err != nil {
+			xlog.Warn("Failed to flush OpenTelemetry spans", "error", err)
+		}
+	}()
+
 	systemState, err := system.GetSystemState(
 		system.WithModelPath(cfg.ModelsPath),
 		system.WithBackendPath(cfg.BackendsPath),
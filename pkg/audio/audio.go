@@ -79,16 +79,16 @@ const WAVHeaderSize = 44
 
 // wavDataChunk walks the RIFF sub-chunks of an in-memory WAV and returns the
 // `data` chunk payload (a sub-slice of data, not a copy) plus the sample rate
-// from `fmt `. ok is false when data isn't a RIFF/WAVE stream or carries no
+// and byte rate from `fmt `. ok is false when data isn't a RIFF/WAVE stream or carries no
 // data chunk — callers then fall back to treating the input as raw PCM.
 //
 // Walking the chunks rather than assuming the canonical 44-byte header is what
 // keeps an 18/40-byte extensible `fmt `, or JUNK/LIST/bext metadata before or
 // after `data` (e.g. ffmpeg's trailing "Lavf" tag), from being spliced into
 // the PCM as an audible click.
-func wavDataChunk(data []byte) (pcm []byte, sampleRate int, ok bool) {
+func wavDataChunk(data []byte) (pcm []byte, sampleRate, byteRate int, ok bool) {
 	if len(data) < 12 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WAVE" {
-		return nil, 0, false
+		return nil, 0, 0, false
 	}
 	for off := 12; off+8 <= len(data); {
 		id := string(data[off : off+4])
@@ -103,14 +103,15 @@ func wavDataChunk(data []byte) (pcm []byte, sampleRate int, ok bool) {
 		case "fmt ":
 			if size >= 16 {
 				sampleRate = int(binary.LittleEndian.Uint32(data[body+4 : body+8]))
+				byteRate = int(binary.LittleEndian.Uint32(data[body+8 : body+12]))
 			}
 		case "data":
-			return data[body : body+size], sampleRate, true
+			return data[body : body+size], sampleRate, byteRate, true
 		}
 		// Chunks are word-aligned: an odd size is followed by a pad byte.
 		off = body + size + (size & 1)
 	}
-	return nil, 0, false
+	return nil, 0, 0, false
 }
 
 // StripWAVHeader removes a WAV header from audio data, returning raw PCM. If
@@ -118,7 +119,7 @@ func wavDataChunk(data []byte) (pcm []byte, sampleRate int, ok bool) {
 // unchanged. Locates the `data` chunk by walking the RIFF structure rather
 // than assuming a fixed 44-byte header — see [wavDataChunk].
 func StripWAVHeader(data []byte) []byte {
-	if pcm, _, ok := wavDataChunk(data); ok {
+	if pcm, _, _, ok := wavDataChunk(data); ok {
 		return pcm
 	}
 	return data
@@ -128,8 +129,19 @@ func StripWAVHeader(data []byte) []byte {
 // rate from `fmt `. If the data isn't a recognisable WAV it is returned as-is
 // with sampleRate=0. Walks the RIFF structure — see [wavDataChunk].
 func ParseWAV(data []byte) (pcm []byte, sampleRate int) {
-	if pcm, sr, ok := wavDataChunk(data); ok {
+	if pcm, sr, _, ok := wavDataChunk(data); ok {
 		return pcm, sr
 	}
 	return data, 0
 }
+
+// WAVDuration returns the playback length of a WAV in seconds, from the size
+// of its `data` chunk and the byte rate declared in `fmt `. ok is false when
+// data isn't a recognisable WAV or declares no byte rate.
+func WAVDuration(data []byte) (seconds float64, ok bool) {
+	pcm, _, byteRate, ok := wavDataChunk(data)
+	if !ok || byteRate <= 0 {
+		return 0, false
+	}
+	return float64(len(pcm)) / float64(byteRate), true
+}
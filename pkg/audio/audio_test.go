@@ -97,6 +97,25 @@ var _ = Describe("WAV utilities", func() {
 		})
 	})
 
+	Describe("WAVDuration", func() {
+		It("derives the length from the data size and byte rate", func() {
+			pcm := make([]byte, 48000) // 1.5s of 16 kHz 16-bit mono
+			hdr := NewWAVHeader(uint32(len(pcm)))
+			var buf bytes.Buffer
+			hdr.Write(&buf)
+			buf.Write(pcm)
+
+			secs, ok := WAVDuration(buf.Bytes())
+			Expect(ok).To(BeTrue())
+			Expect(secs).To(BeNumerically("~", 1.5, 1e-9))
+		})
+
+		It("rejects non-WAV input", func() {
+			_, ok := WAVDuration([]byte("not a wav"))
+			Expect(ok).To(BeFalse())
+		})
+	})
+
 	Describe("non-canonical RIFF layouts", func() {
 		// chunk builds a word-aligned RIFF sub-chunk (id + size + body + pad).
 		chunk := func(id string, body []byte) []byte {
@@ -24,6 +24,28 @@ var (
 		"null": `"null" space`,
 	}
 
+	// HELPER_RULES are the building blocks of the rules generated for
+	// string, array and object keywords. They are added to the grammar on
+	// first use only.
+	HELPER_RULES = map[string]string{
+		"char":   `[^"\\] | "\\" (["\\/bfnrt] | "u" [0-9a-fA-F]{4})`,
+		"date":   `[0-9]{4} "-" ( "0" [1-9] | "1" [0-2] ) "-" ( "0" [1-9] | [1-2] [0-9] | "3" [0-1] )`,
+		"time":   `( [01] [0-9] | "2" [0-3] ) ":" [0-5] [0-9] ":" [0-5] [0-9] ( "." [0-9]+ )? ( "Z" | ( "+" | "-" ) ( [01] [0-9] | "2" [0-3] ) ":" [0-5] [0-9] )`,
+		"value":  `object | array | string | number | boolean | null`,
+		"object": `"{" space ( string ":" space value ("," space string ":" space value)* )? "}" space`,
+		"array":  `"[" space ( value ("," space value)* )? "]" space`,
+	}
+
+	// FORMAT_RULES constrain the strings of the JSON schema formats that can
+	// be expressed in a grammar. Other formats are generated as plain strings.
+	FORMAT_RULES = map[string]string{
+		"date":      `"\"" date "\"" space`,
+		"time":      `"\"" time "\"" space`,
+		"date-time": `"\"" date "T" time "\"" space`,
+		"uuid":      `"\"" [0-9a-fA-F]{8} "-" [0-9a-fA-F]{4} "-" [0-9a-fA-F]{4} "-" [0-9a-fA-F]{4} "-" [0-9a-fA-F]{12} "\"" space`,
+		"email":     `"\"" [a-zA-Z0-9!#$%&'*+/=?^_{|}~-]+ ( "." [a-zA-Z0-9!#$%&'*+/=?^_{|}~-]+ )* "@" [a-zA-Z0-9] ( [a-zA-Z0-9-]* [a-zA-Z0-9] )? ( "." [a-zA-Z0-9] ( [a-zA-Z0-9-]* [a-zA-Z0-9] )? )+ "\"" space`,
+	}
+
 	INVALID_RULE_CHARS_RE     = regexp.MustCompile(`[^a-zA-Z0-9-]+`)
 	GRAMMAR_LITERAL_ESCAPE_RE = regexp.MustCompile(`[\r\n"]`)
 	GRAMMAR_LITERAL_ESCAPES   = map[string]string{
@@ -136,6 +136,12 @@ func (sc *JSONSchemaConverter) visit(schema map[string]any, name string, rootSch
 
 		rule := strings.Join(alternatives, " | ")
 		return sc.addRule(ruleName, rule), nil
+	} else if allOf, exists := schema["allOf"].([]any); exists {
+		merged, err := sc.mergeAllOf(schema, allOf, rootSchema)
+		if err != nil {
+			return "", err
+		}
+		return sc.visit(merged, name, rootSchema)
 	} else if ref, exists := schema["$ref"].(string); exists {
 		// A client-supplied schema may contain a cyclic $ref (e.g. a $def that
 		// references itself directly or through a chain). Without this guard the
@@ -226,6 +232,14 @@ func (sc *JSONSchemaConverter) visit(schema map[string]any, name string, rootSch
 			rule.WriteString(fmt.Sprintf(` %s space ":" space %s`, lPropName, propRuleName))
 		}
 
+		kvRule, err := sc.additionalPropertiesRule(schema, ruleName, rootSchema)
+		if err != nil {
+			return "", err
+		}
+		if kvRule != "" {
+			rule.WriteString(fmt.Sprintf(` ("," space %s)*`, kvRule))
+		}
+
 		rule.WriteString(` "}" space`)
 		return sc.addRule(ruleName, rule.String()), nil
 	} else if items, exists := schema["items"].(map[string]any); schemaType == "array" && exists {
@@ -233,12 +247,28 @@ func (sc *JSONSchemaConverter) visit(schema map[string]any, name string, rootSch
 		if err != nil {
 			return "", err
 		}
-		rule := fmt.Sprintf(`"[" space (%s ("," space %s)*)? "]" space`, itemRuleName, itemRuleName)
+		items := buildRepetition(itemRuleName, intKeyword(schema, "minItems", 0), intKeyword(schema, "maxItems", -1), `"," space`)
+		rule := fmt.Sprintf(`"[" space %s "]" space`, items)
 		return sc.addRule(ruleName, rule), nil
 	} else if properties, _ := schema["properties"].(map[string]any); (schemaType == "object" || schemaType == "") && len(properties) == 0 {
-		// Handle empty object schema (no properties)
+		// Handle empty object schema (no properties), which may still allow
+		// additional properties
+		kvRule, err := sc.additionalPropertiesRule(schema, ruleName, rootSchema)
+		if err != nil {
+			return "", err
+		}
 		rule := `"{" space "}" space`
+		if kvRule != "" {
+			rule = fmt.Sprintf(`"{" space (%s ("," space %s)*)? "}" space`, kvRule, kvRule)
+		}
+		return sc.addRule(ruleName, rule), nil
+	} else if rule, ok := sc.stringRule(schema); ok {
 		return sc.addRule(ruleName, rule), nil
+	} else if minValue, maxValue := integerBounds(schema); schemaType == "integer" && len(schemaTypes) == 1 && (minValue != nil || maxValue != nil) {
+		if minValue != nil && maxValue != nil && *minValue > *maxValue {
+			return "", fmt.Errorf("integer schema has no valid value: minimum %d is above maximum %d", *minValue, *maxValue)
+		}
+		return sc.addRule(ruleName, integerRangeRule(minValue, maxValue)), nil
 	} else {
 		// Handle primitive types, including multi-type arrays like ["string", "null"]
 		if len(schemaTypes) > 1 {
@@ -266,6 +296,32 @@ func (sc *JSONSchemaConverter) visit(schema map[string]any, name string, rootSch
 		}
 	}
 }
+
+// additionalPropertiesRule returns the rule of one extra key/value pair of
+// an object, or "" when the object is closed. Objects are closed unless
+// additionalProperties is true or a schema.
+func (sc *JSONSchemaConverter) additionalPropertiesRule(schema map[string]any, ruleName string, rootSchema map[string]any) (string, error) {
+	var valueRule string
+	switch additional := schema["additionalProperties"].(type) {
+	case bool:
+		if !additional {
+			return "", nil
+		}
+		sc.addHelper("value")
+		valueRule = "value"
+	case map[string]any:
+		var err error
+		valueRule, err = sc.visit(additional, fmt.Sprintf("%s-additional-value", ruleName), rootSchema)
+		if err != nil {
+			return "", err
+		}
+	default:
+		return "", nil
+	}
+	sc.addHelper("string")
+	return sc.addRule(fmt.Sprintf("%s-additional-kv", ruleName), fmt.Sprintf(`string ":" space %s`, valueRule)), nil
+}
+
 func (sc *JSONSchemaConverter) resolveReference(ref string, rootSchema map[string]any) (map[string]any, error) {
 	if !strings.HasPrefix(ref, "#/$defs/") {
 		return nil, fmt.Errorf("invalid reference format: %s", ref)
@@ -0,0 +1,404 @@
+package grammars
+
+import (
+	"fmt"
+	"maps"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// helperDeps lists the rules each helper rule refers to.
+var helperDeps = map[string][]string{
+	"value":  {"object", "array", "string", "number", "boolean", "null"},
+	"object": {"value"},
+	"array":  {"value"},
+}
+
+// addHelper adds the named helper or primitive rules, along with the rules
+// they depend on, to the grammar.
+func (sc *JSONSchemaConverter) addHelper(names ...string) {
+	for _, name := range names {
+		if _, ok := sc.rules[name]; ok {
+			continue
+		}
+		rule, ok := HELPER_RULES[name]
+		if !ok {
+			rule = PRIMITIVE_RULES[name]
+		}
+		sc.rules[name] = rule
+		sc.addHelper(helperDeps[name]...)
+	}
+}
+
+// buildRepetition repeats itemRule between minItems and maxItems times, a
+// negative maxItems meaning unbounded, with separatorRule between items
+// when it is set. itemRule must be a rule name or a parenthesized group.
+func buildRepetition(itemRule string, minItems, maxItems int, separatorRule string) string {
+	if maxItems == 0 {
+		return ""
+	}
+	if minItems == 0 && maxItems == 1 {
+		return itemRule + "?"
+	}
+	if separatorRule == "" {
+		switch {
+		case minItems == 1 && maxItems < 0:
+			return itemRule + "+"
+		case minItems == 0 && maxItems < 0:
+			return itemRule + "*"
+		case maxItems < 0:
+			return fmt.Sprintf("%s{%d,}", itemRule, minItems)
+		case minItems == maxItems:
+			return fmt.Sprintf("%s{%d}", itemRule, minItems)
+		default:
+			return fmt.Sprintf("%s{%d,%d}", itemRule, minItems, maxItems)
+		}
+	}
+
+	rest := maxItems - 1
+	if maxItems < 0 {
+		rest = -1
+	}
+	result := itemRule
+	if more := buildRepetition(fmt.Sprintf("(%s %s)", separatorRule, itemRule), max(minItems-1, 0), rest, ""); more != "" {
+		result += " " + more
+	}
+	if minItems == 0 {
+		return "(" + result + ")?"
+	}
+	return result
+}
+
+// intKeyword reads an integer keyword such as minItems, returning def when
+// it is absent or not a number.
+func intKeyword(schema map[string]any, key string, def int) int {
+	if v, ok := schema[key].(float64); ok {
+		return int(v)
+	}
+	return def
+}
+
+// integerBounds returns the inclusive bounds of an integer schema, folding
+// exclusiveMinimum/exclusiveMaximum in both their draft 4 (boolean) and
+// later (numeric) forms.
+func integerBounds(schema map[string]any) (minValue, maxValue *int64) {
+	tighten := func(cur *int64, v int64, lower bool) *int64 {
+		if cur == nil || (lower && v > *cur) || (!lower && v < *cur) {
+			return &v
+		}
+		return cur
+	}
+	if v, ok := schema["minimum"].(float64); ok {
+		bound := int64(math.Ceil(v))
+		if exclusive, _ := schema["exclusiveMinimum"].(bool); exclusive && v == math.Ceil(v) {
+			bound++
+		}
+		minValue = tighten(minValue, bound, true)
+	}
+	if v, ok := schema["exclusiveMinimum"].(float64); ok {
+		minValue = tighten(minValue, int64(math.Floor(v))+1, true)
+	}
+	if v, ok := schema["maximum"].(float64); ok {
+		bound := int64(math.Floor(v))
+		if exclusive, _ := schema["exclusiveMaximum"].(bool); exclusive && v == math.Floor(v) {
+			bound--
+		}
+		maxValue = tighten(maxValue, bound, false)
+	}
+	if v, ok := schema["exclusiveMaximum"].(float64); ok {
+		maxValue = tighten(maxValue, int64(math.Ceil(v))-1, false)
+	}
+	return minValue, maxValue
+}
+
+// stringRule builds the rule of a string schema carrying a pattern, format
+// or length keyword. It returns false for other schemas, and when none of
+// the keywords can be expressed as a grammar, so that the caller falls back
+// to an unconstrained string.
+func (sc *JSONSchemaConverter) stringRule(schema map[string]any) (string, bool) {
+	if t, _ := schema["type"].(string); t != "string" {
+		return "", false
+	}
+	if pattern, ok := schema["pattern"].(string); ok {
+		if rule, err := sc.patternRule(pattern); err == nil {
+			return rule, true
+		}
+	}
+	if format, ok := schema["format"].(string); ok {
+		if rule, ok := FORMAT_RULES[format]; ok {
+			switch format {
+			case "date", "time", "date-time":
+				sc.addHelper("date", "time")
+			}
+			return rule, true
+		}
+	}
+	_, hasMin := schema["minLength"]
+	_, hasMax := schema["maxLength"]
+	if hasMin || hasMax {
+		sc.addHelper("char")
+		return fmt.Sprintf(`"\"" %s "\"" space`, buildRepetition("char", intKeyword(schema, "minLength", 0), intKeyword(schema, "maxLength", -1), "")), true
+	}
+	return "", false
+}
+
+// mergeAllOf folds the allOf components of a schema into a single schema.
+// The properties of all components are combined; for every other keyword
+// the first component setting it wins. Components may be $refs or carry an
+// allOf of their own.
+func (sc *JSONSchemaConverter) mergeAllOf(schema map[string]any, components []any, rootSchema map[string]any) (map[string]any, error) {
+	merged := make(map[string]any, len(schema))
+	properties := make(map[string]any)
+	for key, value := range schema {
+		if key == "allOf" {
+			continue
+		}
+		merged[key] = value
+	}
+	if p, ok := schema["properties"].(map[string]any); ok {
+		maps.Copy(properties, p)
+	}
+
+	for _, c := range components {
+		component, ok := c.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("invalid allOf component: %v", c)
+		}
+		if ref, ok := component["$ref"].(string); ok {
+			if sc.refsInProgress[ref] {
+				return nil, fmt.Errorf("cyclic $ref detected while building grammar: %s", ref)
+			}
+			resolved, err := sc.resolveReference(ref, rootSchema)
+			if err != nil {
+				return nil, err
+			}
+			sc.refsInProgress[ref] = true
+			if nested, ok := resolved["allOf"].([]any); ok {
+				resolved, err = sc.mergeAllOf(resolved, nested, rootSchema)
+			}
+			delete(sc.refsInProgress, ref)
+			if err != nil {
+				return nil, err
+			}
+			component = resolved
+		} else if nested, ok := component["allOf"].([]any); ok {
+			var err error
+			if component, err = sc.mergeAllOf(component, nested, rootSchema); err != nil {
+				return nil, err
+			}
+		}
+
+		for key, value := range component {
+			if key == "properties" {
+				if p, ok := value.(map[string]any); ok {
+					maps.Copy(properties, p)
+				}
+				continue
+			}
+			if _, set := merged[key]; !set {
+				merged[key] = value
+			}
+		}
+	}
+
+	if len(properties) > 0 {
+		merged["properties"] = properties
+		if _, ok := merged["type"]; !ok {
+			merged["type"] = "object"
+		}
+	}
+	return merged, nil
+}
+
+// integerRangeRule builds the rule of an integer between minValue and
+// maxValue, either of which may be nil for an open bound. This is a port of
+// _generate_min_max_int from llama.cpp's json_schema_to_grammar.py.
+func integerRangeRule(minValue, maxValue *int64) string {
+	var out strings.Builder
+	generateMinMaxInt(minValue, maxValue, &out, 16, true)
+	return "(" + out.String() + ") space"
+}
+
+func generateMinMaxInt(minValue, maxValue *int64, out *strings.Builder, decimalsLeft int, topLevel bool) {
+	digitRange := func(from, to byte) {
+		out.WriteString("[")
+		out.WriteByte(from)
+		if from != to {
+			out.WriteString("-")
+			out.WriteByte(to)
+		}
+		out.WriteString("]")
+	}
+	moreDigits := func(minDigits, maxDigits int) {
+		out.WriteString("[0-9]")
+		if minDigits == maxDigits && minDigits == 1 {
+			return
+		}
+		out.WriteString("{" + strconv.Itoa(minDigits))
+		if maxDigits != minDigits {
+			out.WriteString("," + strconv.Itoa(maxDigits))
+		}
+		out.WriteString("}")
+	}
+
+	// uniformRange matches the numbers from fromStr to toStr, which have
+	// the same number of digits.
+	var uniformRange func(fromStr, toStr string)
+	uniformRange = func(fromStr, toStr string) {
+		i := 0
+		for i < len(fromStr) && fromStr[i] == toStr[i] {
+			i++
+		}
+		if i > 0 {
+			out.WriteString(`"` + fromStr[:i] + `"`)
+		}
+		if i >= len(fromStr) {
+			return
+		}
+		if i > 0 {
+			out.WriteString(" ")
+		}
+		subLen := len(fromStr) - i - 1
+		if subLen == 0 {
+			digitRange(fromStr[i], toStr[i])
+			return
+		}
+
+		fromSub, toSub := fromStr[i+1:], toStr[i+1:]
+		subZeros, subNines := strings.Repeat("0", subLen), strings.Repeat("9", subLen)
+		toReached := false
+		out.WriteString("(")
+		if fromSub == subZeros {
+			digitRange(fromStr[i], toStr[i]-1)
+			out.WriteString(" ")
+			moreDigits(subLen, subLen)
+		} else {
+			out.WriteString("[" + string(fromStr[i]) + "] (")
+			uniformRange(fromSub, subNines)
+			out.WriteString(")")
+			if fromStr[i] < toStr[i]-1 {
+				out.WriteString(" | ")
+				if toSub == subNines {
+					digitRange(fromStr[i]+1, toStr[i])
+					toReached = true
+				} else {
+					digitRange(fromStr[i]+1, toStr[i]-1)
+				}
+				out.WriteString(" ")
+				moreDigits(subLen, subLen)
+			}
+		}
+		if !toReached {
+			out.WriteString(" | ")
+			digitRange(toStr[i], toStr[i])
+			out.WriteString(" ")
+			uniformRange(subZeros, toSub)
+		}
+		out.WriteString(")")
+	}
+
+	neg := func(v int64) *int64 { v = -v; return &v }
+
+	if minValue != nil && maxValue != nil {
+		lo, hi := *minValue, *maxValue
+		if lo < 0 && hi < 0 {
+			out.WriteString(`"-" (`)
+			generateMinMaxInt(neg(hi), neg(lo), out, decimalsLeft, true)
+			out.WriteString(")")
+			return
+		}
+		if lo < 0 {
+			out.WriteString(`"-" (`)
+			zero := int64(0)
+			generateMinMaxInt(&zero, neg(lo), out, decimalsLeft, true)
+			out.WriteString(") | ")
+			lo = 0
+		}
+
+		minS, maxS := strconv.FormatInt(lo, 10), strconv.FormatInt(hi, 10)
+		for digits := len(minS); digits < len(maxS); digits++ {
+			uniformRange(minS, strings.Repeat("9", digits))
+			minS = "1" + strings.Repeat("0", digits)
+			out.WriteString(" | ")
+		}
+		uniformRange(minS, maxS)
+		return
+	}
+
+	lessDecimals := max(decimalsLeft-1, 1)
+
+	if minValue != nil {
+		lo := *minValue
+		switch {
+		case lo < 0:
+			out.WriteString(`"-" (`)
+			generateMinMaxInt(nil, neg(lo), out, decimalsLeft, false)
+			out.WriteString(") | [0] | [1-9] ")
+			moreDigits(0, lessDecimals)
+		case lo == 0:
+			if topLevel {
+				out.WriteString("[0] | [1-9] ")
+				moreDigits(0, lessDecimals)
+			} else {
+				moreDigits(1, decimalsLeft)
+			}
+		case lo <= 9:
+			c := byte('0' + lo)
+			rangeStart := byte('1')
+			if !topLevel {
+				rangeStart = '0'
+			}
+			if c > rangeStart {
+				digitRange(rangeStart, c-1)
+				out.WriteString(" ")
+				moreDigits(1, lessDecimals)
+				out.WriteString(" | ")
+			}
+			digitRange(c, '9')
+			out.WriteString(" ")
+			moreDigits(0, lessDecimals)
+		default:
+			minS := strconv.FormatInt(lo, 10)
+			length := len(minS)
+			c := minS[0]
+			if c > '1' {
+				rangeStart := byte('1')
+				if !topLevel {
+					rangeStart = '0'
+				}
+				digitRange(rangeStart, c-1)
+				out.WriteString(" ")
+				moreDigits(length, lessDecimals)
+				out.WriteString(" | ")
+			}
+			digitRange(c, c)
+			out.WriteString(" (")
+			rest, _ := strconv.ParseInt(minS[1:], 10, 64)
+			generateMinMaxInt(&rest, nil, out, lessDecimals, false)
+			out.WriteString(")")
+			if c < '9' {
+				out.WriteString(" | ")
+				digitRange(c+1, '9')
+				out.WriteString(" ")
+				moreDigits(length-1, lessDecimals)
+			}
+		}
+		return
+	}
+
+	hi := *maxValue
+	if hi >= 0 {
+		if topLevel {
+			out.WriteString(`"-" [1-9] `)
+			moreDigits(0, lessDecimals)
+			out.WriteString(" | ")
+		}
+		zero := int64(0)
+		generateMinMaxInt(&zero, maxValue, out, decimalsLeft, true)
+	} else {
+		out.WriteString(`"-" (`)
+		generateMinMaxInt(neg(hi), nil, out, decimalsLeft, false)
+		out.WriteString(")")
+	}
+}
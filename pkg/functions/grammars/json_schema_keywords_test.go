@@ -0,0 +1,232 @@
+package grammars_test
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	. "github.com/mudler/LocalAI/pkg/functions/grammars"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var classEscapeRE = regexp.MustCompile(`\\[uU]([0-9A-F]+)`)
+
+// grammarRegexp turns a non-recursive grammar into an equivalent regular
+// expression anchored on its root rule, so that tests can check which
+// documents a generated grammar accepts.
+func grammarRegexp(grammar string) *regexp.Regexp {
+	rules := map[string]string{}
+	var last string
+	for _, line := range strings.Split(grammar, "\n") {
+		if name, body, ok := strings.Cut(line, " ::= "); ok {
+			rules[name] = body
+			last = name
+		} else {
+			rules[last] += "\n" + line
+		}
+	}
+
+	var translate func(body string, depth int) string
+	translate = func(body string, depth int) string {
+		Expect(depth).To(BeNumerically("<", 32), "grammar is recursive")
+		var out strings.Builder
+		for i := 0; i < len(body); {
+			switch c := body[i]; {
+			case c == '"':
+				j := i + 1
+				var lit strings.Builder
+				for body[j] != '"' {
+					if body[j] == '\\' {
+						j++
+					}
+					lit.WriteByte(body[j])
+					j++
+				}
+				out.WriteString("(?:" + regexp.QuoteMeta(lit.String()) + ")")
+				i = j + 1
+			case c == '[':
+				j := i + 1
+				for body[j] != ']' {
+					if body[j] == '\\' {
+						j++
+					}
+					j++
+				}
+				out.WriteString(classEscapeRE.ReplaceAllString(body[i:j+1], `\x{$1}`))
+				i = j + 1
+			case c == '(':
+				out.WriteString("(?:")
+				i++
+			case strings.IndexByte(")|?*+", c) >= 0:
+				out.WriteByte(c)
+				i++
+			case c == '{':
+				j := strings.IndexByte(body[i:], '}')
+				out.WriteString(body[i : i+j+1])
+				i += j + 1
+			case c == ' ' || c == '\t' || c == '\n':
+				i++
+			default:
+				j := i
+				for j < len(body) && (body[j] == '-' || body[j] >= '0' && body[j] <= '9' || body[j] >= 'a' && body[j] <= 'z' || body[j] >= 'A' && body[j] <= 'Z') {
+					j++
+				}
+				Expect(j).To(BeNumerically(">", i), "unexpected character %q in %s", c, body)
+				rule, ok := rules[body[i:j]]
+				Expect(ok).To(BeTrue(), "undefined rule %s", body[i:j])
+				out.WriteString("(?:" + translate(rule, depth+1) + ")")
+				i = j
+			}
+		}
+		return out.String()
+	}
+	return regexp.MustCompile("^(?:" + translate(rules["root"], 0) + ")$")
+}
+
+func schemaRegexp(schema string) *regexp.Regexp {
+	grammar, err := NewJSONSchemaConverter("").GrammarFromBytes([]byte(schema))
+	Expect(err).ToNot(HaveOccurred())
+	return grammarRegexp(grammar)
+}
+
+var _ = Describe("JSON schema keywords", func() {
+	It("bounds integers with minimum and maximum", func() {
+		bound := func(v *int) string {
+			if v == nil {
+				return ""
+			}
+			return strconv.Itoa(*v)
+		}
+		ptr := func(v int) *int { return &v }
+		for _, b := range []struct{ min, max *int }{
+			{ptr(0), ptr(100)}, {ptr(-50), ptr(50)}, {ptr(-300), ptr(-7)}, {ptr(5), ptr(1234)}, {ptr(19), ptr(19)},
+			{ptr(0), nil}, {ptr(7), nil}, {ptr(15), nil}, {ptr(123), nil}, {ptr(-42), nil},
+			{nil, ptr(0)}, {nil, ptr(99)}, {nil, ptr(-13)},
+		} {
+			keywords := []string{`"type": "integer"`}
+			if b.min != nil {
+				keywords = append(keywords, `"minimum": `+bound(b.min))
+			}
+			if b.max != nil {
+				keywords = append(keywords, `"maximum": `+bound(b.max))
+			}
+			re := schemaRegexp("{" + strings.Join(keywords, ", ") + "}")
+			for n := -2000; n <= 2000; n++ {
+				want := (b.min == nil || n >= *b.min) && (b.max == nil || n <= *b.max)
+				Expect(re.MatchString(strconv.Itoa(n))).To(Equal(want), "%d with minimum %s and maximum %s", n, bound(b.min), bound(b.max))
+			}
+			Expect(re.MatchString("007")).To(BeFalse())
+		}
+	})
+
+	It("folds exclusive bounds into the integer range", func() {
+		re := schemaRegexp(`{"type": "integer", "exclusiveMinimum": 1, "exclusiveMaximum": 4}`)
+		for n := -5; n <= 10; n++ {
+			Expect(re.MatchString(strconv.Itoa(n))).To(Equal(n == 2 || n == 3), "%d", n)
+		}
+	})
+
+	It("bounds string lengths", func() {
+		re := schemaRegexp(`{"type": "string", "minLength": 2, "maxLength": 3}`)
+		Expect(re.MatchString(`"a"`)).To(BeFalse())
+		Expect(re.MatchString(`"ab"`)).To(BeTrue())
+		Expect(re.MatchString(`"a\"c"`)).To(BeTrue())
+		Expect(re.MatchString(`"abcd"`)).To(BeFalse())
+	})
+
+	It("compiles patterns", func() {
+		re := schemaRegexp(`{"type": "string", "pattern": "^[A-Z]{2}-\\d+(\\.\\d{1,2})?$"}`)
+		Expect(re.MatchString(`"AB-12"`)).To(BeTrue())
+		Expect(re.MatchString(`"AB-12.5"`)).To(BeTrue())
+		Expect(re.MatchString(`"AB-12.555"`)).To(BeFalse())
+		Expect(re.MatchString(`"ab-12"`)).To(BeFalse())
+
+		re = schemaRegexp(`{"type": "string", "pattern": "(?i)^(yes|no)$"}`)
+		Expect(re.MatchString(`"YES"`)).To(BeTrue())
+		Expect(re.MatchString(`"nO"`)).To(BeTrue())
+		Expect(re.MatchString(`"maybe"`)).To(BeFalse())
+
+		re = schemaRegexp(`{"type": "string", "pattern": "^say \"[^\"]*\"$"}`)
+		Expect(re.MatchString(`"say \"hi\""`)).To(BeTrue())
+		Expect(re.MatchString(`"say hi"`)).To(BeFalse())
+	})
+
+	It("lets unanchored patterns match anywhere in the string", func() {
+		re := schemaRegexp(`{"type": "string", "pattern": "[0-9]{3}"}`)
+		Expect(re.MatchString(`"call 555 now"`)).To(BeTrue())
+		Expect(re.MatchString(`"call now"`)).To(BeFalse())
+	})
+
+	It("falls back to a plain string for patterns a grammar cannot express", func() {
+		re := schemaRegexp(`{"type": "string", "pattern": "^\\bword\\b$"}`)
+		Expect(re.MatchString(`"anything"`)).To(BeTrue())
+	})
+
+	DescribeTable("constrains string formats",
+		func(format, valid, invalid string) {
+			re := schemaRegexp(fmt.Sprintf(`{"type": "string", "format": %q}`, format))
+			Expect(re.MatchString(`"` + valid + `"`)).To(BeTrue())
+			Expect(re.MatchString(`"` + invalid + `"`)).To(BeFalse())
+		},
+		Entry("date", "date", "2024-02-29", "2024-13-01"),
+		Entry("time", "time", "23:59:59Z", "24:00:00Z"),
+		Entry("date-time", "date-time", "2024-02-29T12:30:00.123+02:00", "2024-02-29 12:30:00"),
+		Entry("uuid", "uuid", "123e4567-e89b-12d3-a456-426614174000", "123e4567e89b12d3a456426614174000"),
+		Entry("email", "email", "first.last+tag@mail.example.com", "first.last@localhost"),
+	)
+
+	It("bounds array lengths", func() {
+		re := schemaRegexp(`{"type": "array", "items": {"type": "boolean"}, "minItems": 1, "maxItems": 2}`)
+		Expect(re.MatchString(`[]`)).To(BeFalse())
+		Expect(re.MatchString(`[true]`)).To(BeTrue())
+		Expect(re.MatchString(`[true, false]`)).To(BeTrue())
+		Expect(re.MatchString(`[true, false, true]`)).To(BeFalse())
+	})
+
+	It("keeps the unbounded array rule unchanged", func() {
+		grammar, err := NewJSONSchemaConverter("").GrammarFromBytes([]byte(`{"type": "array", "items": {"type": "boolean"}}`))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(grammar).To(ContainSubstring(`root ::= "[" space (boolean ("," space boolean)*)? "]" space`))
+	})
+
+	It("merges allOf components, following $refs", func() {
+		re := schemaRegexp(`{
+			"$defs": {"Named": {"type": "object", "properties": {"name": {"type": "string", "maxLength": 3}}}},
+			"allOf": [
+				{"$ref": "#/$defs/Named"},
+				{"properties": {"age": {"type": "integer", "minimum": 0}}}
+			]
+		}`)
+		Expect(re.MatchString(`{"age": 3, "name": "bob"}`)).To(BeTrue())
+		Expect(re.MatchString(`{"age": -3, "name": "bob"}`)).To(BeFalse())
+		Expect(re.MatchString(`{"name": "bob"}`)).To(BeFalse())
+	})
+
+	It("closes objects unless additionalProperties allows more", func() {
+		re := schemaRegexp(`{"type": "object", "properties": {"a": {"type": "integer"}}, "additionalProperties": false}`)
+		Expect(re.MatchString(`{"a": 1}`)).To(BeTrue())
+		Expect(re.MatchString(`{"a": 1, "b": 2}`)).To(BeFalse())
+
+		re = schemaRegexp(`{"type": "object", "properties": {"a": {"type": "integer"}}, "additionalProperties": {"type": "boolean"}}`)
+		Expect(re.MatchString(`{"a": 1, "b": true, "c": false}`)).To(BeTrue())
+		Expect(re.MatchString(`{"a": 1, "b": 2}`)).To(BeFalse())
+
+		re = schemaRegexp(`{"type": "object", "additionalProperties": {"type": "integer"}}`)
+		Expect(re.MatchString(`{}`)).To(BeTrue())
+		Expect(re.MatchString(`{"x": 1, "y": 2}`)).To(BeTrue())
+	})
+
+	It("allows any value for additionalProperties: true", func() {
+		grammar, err := NewJSONSchemaConverter("").GrammarFromBytes([]byte(`{"type": "object", "additionalProperties": true}`))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(grammar).To(ContainSubstring(`root-additional-kv ::= string ":" space value`))
+		Expect(grammar).To(ContainSubstring(`value ::= object | array | string | number | boolean | null`))
+	})
+
+	It("rejects integer ranges without any valid value", func() {
+		_, err := NewJSONSchemaConverter("").GrammarFromBytes([]byte(`{"type": "integer", "minimum": 5, "maximum": 1}`))
+		Expect(err).To(HaveOccurred())
+	})
+})
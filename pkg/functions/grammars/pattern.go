@@ -0,0 +1,208 @@
+package grammars
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp/syntax"
+	"strings"
+	"unicode"
+)
+
+// patternRule translates the pattern of a string schema into a rule
+// matching the JSON-encoded string, quotes included. JSON schema patterns
+// are ECMA 262 regular expressions; the subset they share with RE2 is
+// supported. Unanchored patterns may match anywhere in the string, so
+// they are padded with free characters on the open side(s).
+//
+// Control characters other than tab and newlines are left out of character
+// classes, which keeps the grammar compact at the cost of being slightly
+// stricter than the pattern.
+func (sc *JSONSchemaConverter) patternRule(pattern string) (string, error) {
+	re, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return "", err
+	}
+	body, err := sc.regexpRule(re)
+	if err != nil {
+		return "", err
+	}
+
+	parts := []string{`"\""`}
+	if !strings.HasPrefix(pattern, "^") {
+		sc.addHelper("char")
+		parts = append(parts, "char*")
+	}
+	if body != "" {
+		parts = append(parts, body)
+	}
+	if !strings.HasSuffix(pattern, "$") || strings.HasSuffix(pattern, `\$`) {
+		sc.addHelper("char")
+		parts = append(parts, "char*")
+	}
+	parts = append(parts, `"\""`, "space")
+	return strings.Join(parts, " "), nil
+}
+
+func (sc *JSONSchemaConverter) regexpRule(re *syntax.Regexp) (string, error) {
+	switch re.Op {
+	case syntax.OpEmptyMatch, syntax.OpBeginLine, syntax.OpEndLine, syntax.OpBeginText, syntax.OpEndText:
+		return "", nil
+	case syntax.OpLiteral:
+		if re.Flags&syntax.FoldCase == 0 {
+			return jsonLiteral(string(re.Rune)), nil
+		}
+		parts := make([]string, 0, len(re.Rune))
+		for _, r := range re.Rune {
+			ranges := []rune{r, r}
+			for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+				ranges = append(ranges, f, f)
+			}
+			part, err := charClassRule(ranges)
+			if err != nil {
+				return "", err
+			}
+			parts = append(parts, part)
+		}
+		return strings.Join(parts, " "), nil
+	case syntax.OpCharClass:
+		return charClassRule(re.Rune)
+	case syntax.OpAnyChar, syntax.OpAnyCharNotNL:
+		sc.addHelper("char")
+		return "char", nil
+	case syntax.OpCapture:
+		sub, err := sc.regexpRule(re.Sub[0])
+		if err != nil || sub == "" {
+			return sub, err
+		}
+		return "(" + sub + ")", nil
+	case syntax.OpStar, syntax.OpPlus, syntax.OpQuest, syntax.OpRepeat:
+		sub, err := sc.regexpRule(re.Sub[0])
+		if err != nil || sub == "" {
+			return sub, err
+		}
+		switch re.Op {
+		case syntax.OpStar:
+			return buildRepetition("("+sub+")", 0, -1, ""), nil
+		case syntax.OpPlus:
+			return buildRepetition("("+sub+")", 1, -1, ""), nil
+		case syntax.OpQuest:
+			return buildRepetition("("+sub+")", 0, 1, ""), nil
+		}
+		return buildRepetition("("+sub+")", re.Min, re.Max, ""), nil
+	case syntax.OpConcat:
+		var parts []string
+		for _, s := range re.Sub {
+			part, err := sc.regexpRule(s)
+			if err != nil {
+				return "", err
+			}
+			if part != "" {
+				parts = append(parts, part)
+			}
+		}
+		return strings.Join(parts, " "), nil
+	case syntax.OpAlternate:
+		var parts []string
+		optional := false
+		for _, s := range re.Sub {
+			part, err := sc.regexpRule(s)
+			if err != nil {
+				return "", err
+			}
+			if part == "" {
+				optional = true
+				continue
+			}
+			parts = append(parts, part)
+		}
+		if len(parts) == 0 {
+			return "", nil
+		}
+		rule := "(" + strings.Join(parts, " | ") + ")"
+		if optional {
+			rule += "?"
+		}
+		return rule, nil
+	}
+	return "", fmt.Errorf("unsupported construct in pattern: %s", re)
+}
+
+// jsonLiteral is the grammar literal matching s once JSON-encoded.
+func jsonLiteral(s string) string {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	_ = enc.Encode(s)
+	encoded := strings.TrimSuffix(buf.String(), "\n")
+	encoded = encoded[1 : len(encoded)-1]
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(encoded) + `"`
+}
+
+// charClassRule builds the rule of a character class given as pairs of
+// inclusive rune ranges. The quote and backslash are only valid escaped in
+// a JSON string, so they and the whitespace escapes become alternatives to
+// the class. Other control characters are left out.
+func charClassRule(ranges []rune) (string, error) {
+	var class strings.Builder
+	var escaped []string
+	for i := 0; i+1 < len(ranges); i += 2 {
+		lo, hi := ranges[i], ranges[i+1]
+		for _, r := range []rune{'\t', '\n', '\r', '"', '\\'} {
+			if lo <= r && r <= hi {
+				escaped = append(escaped, jsonLiteral(string(r)))
+			}
+		}
+		// Split the range around the runes that must not appear raw.
+		for _, gap := range [][2]rune{{0, 0x1F}, {'"', '"'}, {'\\', '\\'}} {
+			if lo > hi {
+				break
+			}
+			switch {
+			case hi < gap[0] || lo > gap[1]:
+			case lo >= gap[0] && hi <= gap[1]:
+				lo = hi + 1
+			case lo < gap[0]:
+				writeClassRange(&class, lo, gap[0]-1)
+				lo = gap[1] + 1
+			default:
+				lo = gap[1] + 1
+			}
+		}
+		if lo <= hi {
+			writeClassRange(&class, lo, hi)
+		}
+	}
+
+	var alternatives []string
+	if class.Len() > 0 {
+		alternatives = append(alternatives, "["+class.String()+"]")
+	}
+	alternatives = append(alternatives, escaped...)
+	switch len(alternatives) {
+	case 0:
+		return "", fmt.Errorf("character class only matches control characters")
+	case 1:
+		return alternatives[0], nil
+	}
+	return "(" + strings.Join(alternatives, " | ") + ")", nil
+}
+
+func writeClassRange(class *strings.Builder, lo, hi rune) {
+	class.WriteString(classRune(lo))
+	if hi != lo {
+		class.WriteString("-" + classRune(hi))
+	}
+}
+
+func classRune(r rune) string {
+	switch {
+	case r < 0x80 && unicode.IsPrint(r) && !strings.ContainsRune(`\]-^[`, r):
+		return string(r)
+	case r <= 0xFF:
+		return fmt.Sprintf(`\x%02X`, r)
+	case r <= 0xFFFF:
+		return fmt.Sprintf(`\u%04X`, r)
+	}
+	return fmt.Sprintf(`\U%08X`, r)
+}
@@ -0,0 +1,51 @@
+package functions
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/google/jsonschema-go/jsonschema"
+	"github.com/mudler/LocalAI/pkg/functions/grammars"
+)
+
+// JSONSchemaGrammar builds the grammar constraining the output to a single
+// JSON schema, as requested through a json_schema response_format. The
+// schema is converted as a whole, so its $defs stay resolvable.
+func JSONSchemaGrammar(schema map[string]any, options ...func(*grammars.GrammarOption)) (string, error) {
+	grammarOpts := &grammars.GrammarOption{}
+	grammarOpts.Apply(options...)
+	return grammars.NewJSONSchemaConverter(grammarOpts.PropOrder).Grammar(schema, options...)
+}
+
+// SchemaValidator checks generated output against a JSON schema. The
+// grammar cannot express every keyword (e.g. uniqueItems or non-integer
+// bounds), and backends without grammar support ignore it altogether.
+type SchemaValidator struct {
+	resolved *jsonschema.Resolved
+}
+
+func NewSchemaValidator(schema map[string]any) (*SchemaValidator, error) {
+	dat, err := json.Marshal(schema)
+	if err != nil {
+		return nil, err
+	}
+	var s jsonschema.Schema
+	if err := json.Unmarshal(dat, &s); err != nil {
+		return nil, fmt.Errorf("invalid JSON schema: %w", err)
+	}
+	resolved, err := s.Resolve(nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JSON schema: %w", err)
+	}
+	return &SchemaValidator{resolved: resolved}, nil
+}
+
+// Validate parses output as JSON and checks it against the schema.
+func (v *SchemaValidator) Validate(output string) error {
+	var instance any
+	if err := json.Unmarshal([]byte(strings.TrimSpace(output)), &instance); err != nil {
+		return fmt.Errorf("output is not valid JSON: %w", err)
+	}
+	return v.resolved.Validate(instance)
+}
@@ -0,0 +1,46 @@
+package functions_test
+
+import (
+	. "github.com/mudler/LocalAI/pkg/functions"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("JSON schema response formats", func() {
+	schema := map[string]any{
+		"$defs": map[string]any{
+			"Code": map[string]any{"type": "string", "pattern": "^[A-Z]{3}$"},
+		},
+		"type": "object",
+		"properties": map[string]any{
+			"code":  map[string]any{"$ref": "#/$defs/Code"},
+			"count": map[string]any{"type": "integer", "minimum": 1},
+			"tags":  map[string]any{"type": "array", "items": map[string]any{"type": "string"}, "uniqueItems": true},
+		},
+		"required":             []any{"code", "count", "tags"},
+		"additionalProperties": false,
+	}
+
+	It("builds the grammar from the whole schema, $defs included", func() {
+		grammar, err := JSONSchemaGrammar(schema)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(grammar).To(ContainSubstring(`root-code ::= "\"" ([A-Z]){3} "\"" space`))
+	})
+
+	It("validates output against the schema", func() {
+		v, err := NewSchemaValidator(schema)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(v.Validate(` {"code": "ABC", "count": 2, "tags": ["a", "b"]} `)).To(Succeed())
+		Expect(v.Validate(`{"code": "ABC", "count": 0, "tags": []}`)).ToNot(Succeed())
+		Expect(v.Validate(`{"code": "abc", "count": 1, "tags": []}`)).ToNot(Succeed())
+		Expect(v.Validate(`{"code": "ABC", "count": 1, "tags": ["a", "a"]}`)).ToNot(Succeed())
+		Expect(v.Validate(`{"code": "ABC", "count": 1, "tags": [], "extra": true}`)).ToNot(Succeed())
+		Expect(v.Validate(`{"code": "ABC", "count": 1`)).To(MatchError(ContainSubstring("not valid JSON")))
+	})
+
+	It("rejects schemas it cannot resolve", func() {
+		_, err := NewSchemaValidator(map[string]any{"$ref": "#/$defs/Missing"})
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -49,6 +49,11 @@ type GrammarConfig struct {
 	// available : json, llama3.1
 	SchemaType string `yaml:"schema_type,omitempty" json:"schema_type,omitempty"`
 
+	// SchemaRetries is how many times an output failing validation against a
+	// strict json_schema response_format is regenerated before the failure
+	// is reported to the client. Streamed responses are never regenerated.
+	SchemaRetries int `yaml:"schema_retries,omitempty" json:"schema_retries,omitempty"`
+
 	GrammarTriggers []GrammarTrigger `yaml:"triggers,omitempty" json:"triggers,omitempty"`
 }
 
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	pb "github.com/mudler/LocalAI/pkg/grpc/proto"
+	"github.com/mudler/LocalAI/pkg/tracing"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
 )
@@ -68,7 +69,8 @@ func (c *Client) wdMark() func() {
 }
 
 // dial creates a gRPC client connection with common options.
-// If c.token is set, bearer token credentials are included.
+// If c.token is set, bearer token credentials are included. The tracing
+// interceptors carry the caller's trace context to the backend.
 func (c *Client) dial() (*grpc.ClientConn, error) {
 	opts := []grpc.DialOption{
 		grpc.WithTransportCredentials(insecure.NewCredentials()),
@@ -76,6 +78,8 @@ func (c *Client) dial() (*grpc.ClientConn, error) {
 			grpc.MaxCallRecvMsgSize(maxGRPCMessageSize),
 			grpc.MaxCallSendMsgSize(maxGRPCMessageSize),
 		),
+		grpc.WithUnaryInterceptor(tracing.UnaryClientInterceptor()),
+		grpc.WithStreamInterceptor(tracing.StreamClientInterceptor()),
 	}
 	if c.token != "" {
 		opts = append(opts, grpc.WithPerRPCCredentials(bearerToken{token: c.token}))
@@ -9,11 +9,13 @@ import (
 	"log"
 	"net"
 	"os"
+	"path/filepath"
 	"strings"
 	"sync"
 
 	"github.com/mudler/LocalAI/pkg/grpc/grpcerrors"
 	pb "github.com/mudler/LocalAI/pkg/grpc/proto"
+	"github.com/mudler/LocalAI/pkg/tracing"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
@@ -1073,21 +1075,33 @@ func tokenStreamInterceptor(token string) grpc.StreamServerInterceptor {
 	}
 }
 
-// serverOpts returns the common gRPC server options, including auth interceptors
-// when LOCALAI_GRPC_AUTH_TOKEN is set.
+// serverOpts returns the common gRPC server options: the tracing
+// interceptors, plus auth interceptors when LOCALAI_GRPC_AUTH_TOKEN is set.
 func serverOpts() []grpc.ServerOption {
-	opts := []grpc.ServerOption{
+	unary := []grpc.UnaryServerInterceptor{tracing.UnaryServerInterceptor()}
+	stream := []grpc.StreamServerInterceptor{tracing.StreamServerInterceptor()}
+	if token := os.Getenv(AuthTokenEnvVar); token != "" {
+		unary = append(unary, tokenUnaryInterceptor(token))
+		stream = append(stream, tokenStreamInterceptor(token))
+		log.Printf("gRPC auth enabled via %s", AuthTokenEnvVar)
+	}
+	return []grpc.ServerOption{
 		grpc.MaxRecvMsgSize(maxGRPCMessageSize),
 		grpc.MaxSendMsgSize(maxGRPCMessageSize),
+		grpc.ChainUnaryInterceptor(unary...),
+		grpc.ChainStreamInterceptor(stream...),
 	}
-	if token := os.Getenv(AuthTokenEnvVar); token != "" {
-		opts = append(opts,
-			grpc.UnaryInterceptor(tokenUnaryInterceptor(token)),
-			grpc.StreamInterceptor(tokenStreamInterceptor(token)),
-		)
-		log.Printf("gRPC auth enabled via %s", AuthTokenEnvVar)
+}
+
+// setupTracing starts span export in a backend process when the parent
+// LocalAI handed it an OTLP configuration (see tracing.Environ). The
+// exporter lives as long as the process; spans still buffered at exit are
+// lost, which is acceptable for a backend being torn down.
+func setupTracing() {
+	cfg := tracing.ConfigFromEnv("local-ai-backend-" + filepath.Base(os.Args[0]))
+	if _, err := tracing.Setup(context.Background(), cfg); err != nil {
+		log.Printf("tracing disabled: %v", err)
 	}
-	return opts
 }
 
 func StartServer(address string, model AIModel) error {
@@ -1095,6 +1109,7 @@ func StartServer(address string, model AIModel) error {
 	if err != nil {
 		return err
 	}
+	setupTracing()
 	s := grpc.NewServer(serverOpts()...)
 	pb.RegisterBackendServer(s, &server{llm: model})
 	log.Printf("gRPC Server listening at %v", lis.Addr())
@@ -1113,6 +1128,7 @@ func RunServer(address string, model AIModel) (func() error, error) {
 	if err != nil {
 		return nil, err
 	}
+	setupTracing()
 	s := grpc.NewServer(serverOpts()...)
 	pb.RegisterBackendServer(s, &server{llm: model})
 	log.Printf("gRPC Server listening at %v", lis.Addr())
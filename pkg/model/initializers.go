@@ -11,9 +11,12 @@ import (
 
 	grpc "github.com/mudler/LocalAI/pkg/grpc"
 	pb "github.com/mudler/LocalAI/pkg/grpc/proto"
+	"github.com/mudler/LocalAI/pkg/tracing"
 	processManager "github.com/mudler/go-processmanager"
 	"github.com/mudler/xlog"
 	"github.com/phayes/freeport"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/protobuf/proto"
 )
 
@@ -71,11 +74,21 @@ func (ml *ModelLoader) grpcModel(backend string, o *Options) func(string, string
 			return router(o.context, backend, modelID, modelName, modelFile, o.configRevision, o.gRPCOptions, o.parallelRequests)
 		}
 
+		// The span covers process spawn and the LoadModel RPC, which runs
+		// under o.context: swapping in the span's context makes the RPC its
+		// child.
+		ctx, span := tracing.Start(o.context, "model.load", trace.WithAttributes(
+			attribute.String("localai.model", modelID),
+			attribute.String("localai.backend", backend),
+		))
+		o.context = ctx
+
 		uri := ml.GetAllExternalBackends(o)[backend]
 		start := time.Now()
 		started := BackendLoadEvent{ModelID: modelID, ModelName: modelName, Backend: backend, BackendURI: uri}
 		finish, admissionErr := ml.notifyLoadStarted(started)
 		if admissionErr != nil {
+			tracing.End(span, admissionErr)
 			return nil, admissionErr
 		}
 		m, err := ml.spawnGRPCModel(backend, uri, o, modelID, modelName, modelFile)
@@ -91,6 +104,7 @@ func (ml *ModelLoader) grpcModel(backend string, o *Options) func(string, string
 		if finish != nil {
 			finish(completed)
 		}
+		tracing.End(span, err)
 		return m, err
 	}
 }
@@ -74,3 +74,12 @@ func (m *Model) GRPC(parallel bool, wd *WatchDog) grpc.Backend {
 	m.client = grpc.NewClient(m.address, parallel, wd, enableWD)
 	return m.client
 }
+
+// IsBusy reports whether the backend is running a request. A model whose
+// client was never created has served nothing yet.
+func (m *Model) IsBusy() bool {
+	m.Lock()
+	client := m.client
+	m.Unlock()
+	return client != nil && client.IsBusy()
+}
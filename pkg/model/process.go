@@ -13,6 +13,7 @@ import (
 	"github.com/hpcloud/tail"
 	"github.com/mudler/LocalAI/pkg/grpc/grpcerrors"
 	"github.com/mudler/LocalAI/pkg/signals"
+	"github.com/mudler/LocalAI/pkg/tracing"
 	process "github.com/mudler/go-processmanager"
 	"github.com/mudler/xlog"
 )
@@ -260,6 +261,9 @@ func (ml *ModelLoader) startProcess(grpcProcess, id string, serverAddress string
 	// SYCL/Level-Zero stack instead, so the default ICD search path is empty
 	// and the GPU would silently fall back to CPU). No-op for other backends.
 	env = append(env, vulkanICDEnv(workDir)...)
+	// Hand the OTLP export settings down so the backend's spans reach the
+	// same collector even when they were configured by flag.
+	env = append(env, tracing.Environ()...)
 
 	grpcControlProcess := process.New(
 		process.WithTemporaryStateDir(),
@@ -18,12 +18,58 @@ import (
 // the vector store, since store loads have no artefact to validate.
 const NamespacePrefix = "store://"
 
+// Option tunes a single store request.
+type Option func(*options)
+
+type options struct {
+	namespace string
+	metadata  []Metadata
+	filters   []Filter
+}
+
+func applyOptions(opts []Option) options {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithNamespace runs the request against a logical collection of the store
+// rather than its default one. Namespaces share the loaded store model but
+// not their entries.
+func WithNamespace(namespace string) Option {
+	return func(o *options) { o.namespace = namespace }
+}
+
+// WithMetadata attaches metadata to the entries of a Set; metadata[i]
+// belongs to keys[i].
+func WithMetadata(metadata []Metadata) Option {
+	return func(o *options) { o.metadata = metadata }
+}
+
+// WithFilters restricts a Find to entries whose metadata matches every
+// filter.
+func WithFilters(filters ...Filter) Option {
+	return func(o *options) { o.filters = append(o.filters, filters...) }
+}
+
 // SetCols sets multiple key-value pairs in the store
 // It's in columnar format so that keys[i] is associated with values[i]
-func SetCols(ctx context.Context, c grpc.Backend, keys [][]float32, values [][]byte) error {
+func SetCols(ctx context.Context, c grpc.Backend, keys [][]float32, values [][]byte, opts ...Option) error {
+	o := applyOptions(opts)
+	if o.metadata != nil && len(o.metadata) != len(keys) {
+		return fmt.Errorf("failed to set keys: %d metadata entries for %d keys", len(o.metadata), len(keys))
+	}
+	metadata, err := WrapMetadata(o.metadata)
+	if err != nil {
+		return err
+	}
 	res, err := c.StoresSet(ctx, &proto.StoresSetOptions{
-		Keys:   WrapKeys(keys),
-		Values: WrapValues(values),
+		Keys:      WrapKeys(keys),
+		Values:    WrapValues(values),
+		Metadata:  metadata,
+		Namespace: o.namespace,
 	})
 	if err != nil {
 		return err
@@ -38,14 +84,15 @@ func SetCols(ctx context.Context, c grpc.Backend, keys [][]float32, values [][]b
 
 // SetSingle sets a single key-value pair in the store
 // Don't call this in a tight loop, instead use SetCols
-func SetSingle(ctx context.Context, c grpc.Backend, key []float32, value []byte) error {
-	return SetCols(ctx, c, [][]float32{key}, [][]byte{value})
+func SetSingle(ctx context.Context, c grpc.Backend, key []float32, value []byte, opts ...Option) error {
+	return SetCols(ctx, c, [][]float32{key}, [][]byte{value}, opts...)
 }
 
 // DeleteCols deletes multiple key-value pairs from the store
 // It's in columnar format so that keys[i] is associated with values[i]
-func DeleteCols(ctx context.Context, c grpc.Backend, keys [][]float32) error {
-	res, err := c.StoresDelete(ctx, &proto.StoresDeleteOptions{Keys: WrapKeys(keys)})
+func DeleteCols(ctx context.Context, c grpc.Backend, keys [][]float32, opts ...Option) error {
+	o := applyOptions(opts)
+	res, err := c.StoresDelete(ctx, &proto.StoresDeleteOptions{Keys: WrapKeys(keys), Namespace: o.namespace})
 	if err != nil {
 		return err
 	}
@@ -59,26 +106,51 @@ func DeleteCols(ctx context.Context, c grpc.Backend, keys [][]float32) error {
 
 // DeleteSingle deletes a single key-value pair from the store
 // Don't call this in a tight loop, instead use DeleteCols
-func DeleteSingle(ctx context.Context, c grpc.Backend, key []float32) error {
-	return DeleteCols(ctx, c, [][]float32{key})
+func DeleteSingle(ctx context.Context, c grpc.Backend, key []float32, opts ...Option) error {
+	return DeleteCols(ctx, c, [][]float32{key}, opts...)
 }
 
 // GetCols gets multiple key-value pairs from the store
 // It's in columnar format so that keys[i] is associated with values[i]
 // Be warned the keys are sorted and will be returned in a different order than they were input
 // There is no guarantee as to how the keys are sorted
-func GetCols(ctx context.Context, c grpc.Backend, keys [][]float32) ([][]float32, [][]byte, error) {
-	res, err := c.StoresGet(ctx, &proto.StoresGetOptions{Keys: WrapKeys(keys)})
+func GetCols(ctx context.Context, c grpc.Backend, keys [][]float32, opts ...Option) ([][]float32, [][]byte, error) {
+	entries, err := GetEntries(ctx, c, keys, opts...)
 	if err != nil {
 		return nil, nil, err
 	}
-	return UnwrapKeys(res.Keys), UnwrapValues(res.Values), nil
+	outKeys := make([][]float32, len(entries))
+	outValues := make([][]byte, len(entries))
+	for i, e := range entries {
+		outKeys[i], outValues[i] = e.Key, e.Value
+	}
+	return outKeys, outValues, nil
+}
+
+// Entry is a stored key with its value and metadata. Similarity is only set
+// by FindEntries.
+type Entry struct {
+	Key        []float32
+	Value      []byte
+	Metadata   Metadata
+	Similarity float32
+}
+
+// GetEntries is GetCols returning the metadata of the entries too. Missing
+// keys are omitted.
+func GetEntries(ctx context.Context, c grpc.Backend, keys [][]float32, opts ...Option) ([]Entry, error) {
+	o := applyOptions(opts)
+	res, err := c.StoresGet(ctx, &proto.StoresGetOptions{Keys: WrapKeys(keys), Namespace: o.namespace})
+	if err != nil {
+		return nil, err
+	}
+	return entries(res.Keys, res.Values, res.Metadata, nil), nil
 }
 
 // GetSingle gets a single key-value pair from the store
 // Don't call this in a tight loop, instead use GetCols
-func GetSingle(ctx context.Context, c grpc.Backend, key []float32) ([]byte, error) {
-	_, values, err := GetCols(ctx, c, [][]float32{key})
+func GetSingle(ctx context.Context, c grpc.Backend, key []float32, opts ...Option) ([]byte, error) {
+	_, values, err := GetCols(ctx, c, [][]float32{key}, opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -91,13 +163,51 @@ func GetSingle(ctx context.Context, c grpc.Backend, key []float32) ([]byte, erro
 }
 
 // Find similar keys to the given key. Returns the keys, values, and similarities
-func Find(ctx context.Context, c grpc.Backend, key []float32, topk int) ([][]float32, [][]byte, []float32, error) {
-	res, err := c.StoresFind(ctx, &proto.StoresFindOptions{
-		Key:  &proto.StoresKey{Floats: key},
-		TopK: int32(topk),
-	})
+func Find(ctx context.Context, c grpc.Backend, key []float32, topk int, opts ...Option) ([][]float32, [][]byte, []float32, error) {
+	res, err := find(ctx, c, key, topk, opts)
 	if err != nil {
 		return nil, nil, nil, err
 	}
 	return UnwrapKeys(res.Keys), UnwrapValues(res.Values), res.Similarities, nil
 }
+
+// FindEntries is Find returning the metadata of the entries too, most
+// similar first.
+func FindEntries(ctx context.Context, c grpc.Backend, key []float32, topk int, opts ...Option) ([]Entry, error) {
+	res, err := find(ctx, c, key, topk, opts)
+	if err != nil {
+		return nil, err
+	}
+	return entries(res.Keys, res.Values, res.Metadata, res.Similarities), nil
+}
+
+func find(ctx context.Context, c grpc.Backend, key []float32, topk int, opts []Option) (*proto.StoresFindResult, error) {
+	o := applyOptions(opts)
+	filters, err := WrapFilters(o.filters)
+	if err != nil {
+		return nil, err
+	}
+	return c.StoresFind(ctx, &proto.StoresFindOptions{
+		Key:       &proto.StoresKey{Floats: key},
+		TopK:      int32(topk),
+		Filters:   filters,
+		Namespace: o.namespace,
+	})
+}
+
+// entries zips the columns of a Get/Find result. Backends that predate
+// metadata leave it empty, so it is only read when aligned.
+func entries(keys []*proto.StoresKey, values []*proto.StoresValue, metadata []*proto.StoresMetadata, sims []float32) []Entry {
+	md := UnwrapMetadata(metadata)
+	out := make([]Entry, len(keys))
+	for i := range keys {
+		out[i] = Entry{Key: keys[i].GetFloats(), Value: values[i].GetBytes()}
+		if len(md) == len(keys) {
+			out[i].Metadata = md[i]
+		}
+		if len(sims) == len(keys) {
+			out[i].Similarity = sims[i]
+		}
+	}
+	return out
+}
@@ -0,0 +1,259 @@
+package store
+
+// Structured entry metadata and the filters StoresFind evaluates on it,
+// with their pb⇄Go translation. Shared by the gRPC client and the store
+// backends (local-store matches filters in process with Filter.Match), so
+// the filter semantics are defined once.
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/mudler/LocalAI/pkg/grpc/proto"
+)
+
+// Metadata is the structured metadata of a store entry. Values are strings,
+// numbers or booleans; numbers are carried as float64.
+type Metadata map[string]any
+
+// Filter matches entries whose metadata field Field equals Eq, is one of In,
+// or is a number within Range. Exactly one of Eq, In and Range is set; a
+// Find with several filters returns entries matching all of them.
+type Filter struct {
+	Field string `json:"field" yaml:"field"`
+	Eq    any    `json:"eq,omitempty" yaml:"eq,omitempty"`
+	In    []any  `json:"in,omitempty" yaml:"in,omitempty"`
+	Range *Range `json:"range,omitempty" yaml:"range,omitempty"`
+}
+
+// Range is a numeric range. Unset bounds are open.
+type Range struct {
+	Gt  *float64 `json:"gt,omitempty" yaml:"gt,omitempty"`
+	Gte *float64 `json:"gte,omitempty" yaml:"gte,omitempty"`
+	Lt  *float64 `json:"lt,omitempty" yaml:"lt,omitempty"`
+	Lte *float64 `json:"lte,omitempty" yaml:"lte,omitempty"`
+}
+
+// Validate reports a filter without a field, with more or less than one
+// condition, or with values of an unsupported type.
+func (f Filter) Validate() error {
+	if f.Field == "" {
+		return fmt.Errorf("store: filter without a field")
+	}
+	conditions := 0
+	if f.Eq != nil {
+		conditions++
+		if _, err := normalizeValue(f.Eq); err != nil {
+			return fmt.Errorf("store: filter on %q: %w", f.Field, err)
+		}
+	}
+	if len(f.In) > 0 {
+		conditions++
+		for _, v := range f.In {
+			if _, err := normalizeValue(v); err != nil {
+				return fmt.Errorf("store: filter on %q: %w", f.Field, err)
+			}
+		}
+	}
+	if f.Range != nil {
+		conditions++
+		if f.Range.Gt == nil && f.Range.Gte == nil && f.Range.Lt == nil && f.Range.Lte == nil {
+			return fmt.Errorf("store: filter on %q: range without bounds", f.Field)
+		}
+	}
+	if conditions != 1 {
+		return fmt.Errorf("store: filter on %q must set exactly one of eq, in or range", f.Field)
+	}
+	return nil
+}
+
+// Match reports whether md satisfies the filter. A missing field, or a value
+// of another type than the filter's, never matches.
+func (f Filter) Match(md Metadata) bool {
+	v, ok := md[f.Field]
+	if !ok {
+		return false
+	}
+	switch {
+	case f.Eq != nil:
+		return valuesEqual(v, f.Eq)
+	case len(f.In) > 0:
+		for _, want := range f.In {
+			if valuesEqual(v, want) {
+				return true
+			}
+		}
+		return false
+	case f.Range != nil:
+		n, ok := v.(float64)
+		if !ok {
+			return false
+		}
+		r := f.Range
+		return (r.Gt == nil || n > *r.Gt) && (r.Gte == nil || n >= *r.Gte) &&
+			(r.Lt == nil || n < *r.Lt) && (r.Lte == nil || n <= *r.Lte)
+	}
+	return false
+}
+
+// MatchAll reports whether md satisfies every filter.
+func MatchAll(filters []Filter, md Metadata) bool {
+	for _, f := range filters {
+		if !f.Match(md) {
+			return false
+		}
+	}
+	return true
+}
+
+func valuesEqual(have, want any) bool {
+	w, err := normalizeValue(want)
+	if err != nil {
+		return false
+	}
+	return have == w
+}
+
+// normalizeValue maps a Go metadata value onto the three wire types, so
+// callers may use any integer or float type for numbers.
+func normalizeValue(v any) (any, error) {
+	switch x := v.(type) {
+	case string, bool:
+		return x, nil
+	case float64:
+		if math.IsNaN(x) {
+			return nil, fmt.Errorf("NaN is not a valid metadata value")
+		}
+		return x, nil
+	case float32:
+		return float64(x), nil
+	case int:
+		return float64(x), nil
+	case int32:
+		return float64(x), nil
+	case int64:
+		return float64(x), nil
+	case uint:
+		return float64(x), nil
+	case uint32:
+		return float64(x), nil
+	case uint64:
+		return float64(x), nil
+	}
+	return nil, fmt.Errorf("unsupported metadata value %v of type %T (want string, number or bool)", v, v)
+}
+
+// WrapMetadata converts per-entry metadata to its wire form. A nil slice
+// stays nil, so Set requests without metadata are unchanged on the wire.
+func WrapMetadata(in []Metadata) ([]*proto.StoresMetadata, error) {
+	if in == nil {
+		return nil, nil
+	}
+	out := make([]*proto.StoresMetadata, len(in))
+	for i, md := range in {
+		fields := make(map[string]*proto.StoresMetadataValue, len(md))
+		for k, v := range md {
+			pv, err := wrapValue(v)
+			if err != nil {
+				return nil, fmt.Errorf("store: metadata %d field %q: %w", i, k, err)
+			}
+			fields[k] = pv
+		}
+		out[i] = &proto.StoresMetadata{Fields: fields}
+	}
+	return out, nil
+}
+
+// UnwrapMetadata converts wire metadata back to Go values. Entries without
+// fields become nil.
+func UnwrapMetadata(in []*proto.StoresMetadata) []Metadata {
+	if in == nil {
+		return nil
+	}
+	out := make([]Metadata, len(in))
+	for i, md := range in {
+		if len(md.GetFields()) == 0 {
+			continue
+		}
+		out[i] = make(Metadata, len(md.GetFields()))
+		for k, v := range md.GetFields() {
+			out[i][k] = unwrapValue(v)
+		}
+	}
+	return out
+}
+
+// WrapFilters validates filters and converts them to their wire form.
+func WrapFilters(in []Filter) ([]*proto.StoresFilter, error) {
+	out := make([]*proto.StoresFilter, len(in))
+	for i, f := range in {
+		if err := f.Validate(); err != nil {
+			return nil, err
+		}
+		pf := &proto.StoresFilter{Field: f.Field}
+		if f.Eq != nil {
+			pf.Eq, _ = wrapValue(f.Eq)
+		}
+		for _, v := range f.In {
+			pv, _ := wrapValue(v)
+			pf.In = append(pf.In, pv)
+		}
+		if f.Range != nil {
+			pf.Range = &proto.StoresRange{Gt: f.Range.Gt, Gte: f.Range.Gte, Lt: f.Range.Lt, Lte: f.Range.Lte}
+		}
+		out[i] = pf
+	}
+	return out, nil
+}
+
+// UnwrapFilters converts wire filters back to Go, validating them so a
+// backend can reject a malformed request before searching.
+func UnwrapFilters(in []*proto.StoresFilter) ([]Filter, error) {
+	out := make([]Filter, len(in))
+	for i, pf := range in {
+		f := Filter{Field: pf.GetField()}
+		if pf.GetEq() != nil {
+			f.Eq = unwrapValue(pf.GetEq())
+		}
+		for _, v := range pf.GetIn() {
+			f.In = append(f.In, unwrapValue(v))
+		}
+		if r := pf.GetRange(); r != nil {
+			f.Range = &Range{Gt: r.Gt, Gte: r.Gte, Lt: r.Lt, Lte: r.Lte}
+		}
+		if err := f.Validate(); err != nil {
+			return nil, err
+		}
+		out[i] = f
+	}
+	return out, nil
+}
+
+func wrapValue(v any) (*proto.StoresMetadataValue, error) {
+	n, err := normalizeValue(v)
+	if err != nil {
+		return nil, err
+	}
+	switch x := n.(type) {
+	case string:
+		return &proto.StoresMetadataValue{Kind: &proto.StoresMetadataValue_StringValue{StringValue: x}}, nil
+	case bool:
+		return &proto.StoresMetadataValue{Kind: &proto.StoresMetadataValue_BoolValue{BoolValue: x}}, nil
+	default:
+		return &proto.StoresMetadataValue{Kind: &proto.StoresMetadataValue_NumberValue{NumberValue: x.(float64)}}, nil
+	}
+}
+
+// unwrapValue returns nil for a value without a kind, which Validate then
+// reports as a missing condition.
+func unwrapValue(v *proto.StoresMetadataValue) any {
+	switch k := v.GetKind().(type) {
+	case *proto.StoresMetadataValue_StringValue:
+		return k.StringValue
+	case *proto.StoresMetadataValue_NumberValue:
+		return k.NumberValue
+	case *proto.StoresMetadataValue_BoolValue:
+		return k.BoolValue
+	}
+	return nil
+}
@@ -0,0 +1,240 @@
+// Package tracing is LocalAI's OpenTelemetry trace pipeline: OTLP export
+// setup, the W3C trace-context propagator, and the small helpers the
+// instrumented packages share (span start/end, gRPC metadata propagation).
+//
+// Why in pkg and not next to the metrics service in core/services/monitoring:
+// the gRPC client and server in pkg/grpc and the model loader in pkg/model
+// have to inject and extract trace context too, and pkg must not import core.
+//
+// Instrumented code always goes through the global otel TracerProvider and
+// propagator. Until Setup installs an exporter the provider is the no-op
+// default, so spans cost next to nothing when tracing is off.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/mudler/LocalAI/internal"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName is the tracer name every LocalAI span is created under.
+const instrumentationName = "github.com/mudler/LocalAI"
+
+// Export protocols accepted by Config.Protocol.
+const (
+	ProtocolGRPC = "grpc"
+	ProtocolHTTP = "http/protobuf"
+)
+
+// Environment variables Environ hands to child processes and ConfigFromEnv
+// reads back. They mirror the --otel-* flags of `local-ai run` and `worker`.
+const (
+	EnvEndpoint    = "LOCALAI_OTEL_ENDPOINT"
+	EnvProtocol    = "LOCALAI_OTEL_PROTOCOL"
+	EnvInsecure    = "LOCALAI_OTEL_INSECURE"
+	EnvSampleRatio = "LOCALAI_OTEL_SAMPLE_RATIO"
+)
+
+// Config selects where and how spans are exported.
+type Config struct {
+	// Endpoint is the OTLP collector, either host:port or a full URL
+	// (http://collector:4318/v1/traces). Empty falls back to the standard
+	// OTEL_EXPORTER_OTLP_TRACES_ENDPOINT / OTEL_EXPORTER_OTLP_ENDPOINT
+	// variables; with none of them set, export is disabled.
+	Endpoint string
+	// Protocol is ProtocolGRPC (the default) or ProtocolHTTP.
+	Protocol string
+	// Insecure disables TLS towards the collector.
+	Insecure bool
+	// SampleRatio is the fraction of new traces that are recorded, in
+	// [0, 1]. Requests arriving with a sampled parent are always recorded,
+	// so a trace started upstream is never cut in half. 0 means 1 (the
+	// zero Config samples everything).
+	SampleRatio float64
+	// ServiceName is the service.name resource attribute, unless
+	// OTEL_SERVICE_NAME overrides it.
+	ServiceName string
+}
+
+// Enabled reports whether c, or the standard OTel environment, names a
+// collector to export to.
+func (c Config) Enabled() bool {
+	return c.Endpoint != "" ||
+		os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != "" ||
+		os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != ""
+}
+
+// Validate reports an unknown protocol or an out-of-range sample ratio.
+func (c Config) Validate() error {
+	switch c.Protocol {
+	case "", ProtocolGRPC, ProtocolHTTP:
+	default:
+		return fmt.Errorf("tracing: unknown OTLP protocol %q (want %q or %q)", c.Protocol, ProtocolGRPC, ProtocolHTTP)
+	}
+	if c.SampleRatio < 0 || c.SampleRatio > 1 {
+		return fmt.Errorf("tracing: sample ratio %v out of range [0, 1]", c.SampleRatio)
+	}
+	return nil
+}
+
+// ConfigFromEnv builds a Config from the LOCALAI_OTEL_* variables. Backend
+// processes use it to pick up the configuration the parent handed them
+// through Environ.
+func ConfigFromEnv(serviceName string) Config {
+	c := Config{
+		Endpoint:    os.Getenv(EnvEndpoint),
+		Protocol:    os.Getenv(EnvProtocol),
+		ServiceName: serviceName,
+	}
+	c.Insecure, _ = strconv.ParseBool(os.Getenv(EnvInsecure))
+	c.SampleRatio, _ = strconv.ParseFloat(os.Getenv(EnvSampleRatio), 64)
+	return c
+}
+
+var (
+	activeMu sync.RWMutex
+	active   *Config
+)
+
+// Environ returns the LOCALAI_OTEL_* assignments of the active export
+// configuration, so that spawned backend processes export to the same
+// collector even when it was configured by flag rather than environment.
+// It returns nil while export is disabled.
+func Environ() []string {
+	activeMu.RLock()
+	defer activeMu.RUnlock()
+	if active == nil || active.Endpoint == "" {
+		return nil
+	}
+	env := []string{
+		EnvEndpoint + "=" + active.Endpoint,
+		EnvInsecure + "=" + strconv.FormatBool(active.Insecure),
+	}
+	if active.Protocol != "" {
+		env = append(env, EnvProtocol+"="+active.Protocol)
+	}
+	if active.SampleRatio > 0 {
+		env = append(env, EnvSampleRatio+"="+strconv.FormatFloat(active.SampleRatio, 'g', -1, 64))
+	}
+	return env
+}
+
+// Setup installs the W3C trace-context and baggage propagator and, when c
+// is enabled, an OTLP exporter behind a batching TracerProvider. The
+// propagator is installed either way: a LocalAI instance that does not
+// export still forwards its callers' trace context to the backends.
+//
+// The returned shutdown flushes pending spans; it is never nil and is safe
+// to call when export is disabled.
+func Setup(ctx context.Context, c Config) (shutdown func(context.Context) error, err error) {
+	noop := func(context.Context) error { return nil }
+	if err := c.Validate(); err != nil {
+		return noop, err
+	}
+
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+	if !c.Enabled() {
+		return noop, nil
+	}
+
+	exporter, err := newExporter(ctx, c)
+	if err != nil {
+		return noop, fmt.Errorf("tracing: creating OTLP exporter: %w", err)
+	}
+
+	serviceName := c.ServiceName
+	if serviceName == "" {
+		serviceName = "local-ai"
+	}
+	attrs := []attribute.KeyValue{attribute.String("service.version", internal.PrintableVersion())}
+	if os.Getenv("OTEL_SERVICE_NAME") == "" {
+		attrs = append(attrs, attribute.String("service.name", serviceName))
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(attrs...))
+	if err != nil {
+		return noop, fmt.Errorf("tracing: building resource: %w", err)
+	}
+
+	ratio := c.SampleRatio
+	if ratio == 0 {
+		ratio = 1
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+	)
+	otel.SetTracerProvider(provider)
+
+	activeMu.Lock()
+	active = &c
+	activeMu.Unlock()
+
+	return func(ctx context.Context) error {
+		activeMu.Lock()
+		active = nil
+		activeMu.Unlock()
+		return provider.Shutdown(ctx)
+	}, nil
+}
+
+func newExporter(ctx context.Context, c Config) (*otlptrace.Exporter, error) {
+	isURL := strings.Contains(c.Endpoint, "://")
+	if c.Protocol == ProtocolHTTP {
+		var opts []otlptracehttp.Option
+		switch {
+		case isURL:
+			opts = append(opts, otlptracehttp.WithEndpointURL(c.Endpoint))
+		case c.Endpoint != "":
+			opts = append(opts, otlptracehttp.WithEndpoint(c.Endpoint))
+		}
+		if c.Insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		return otlptracehttp.New(ctx, opts...)
+	}
+	var opts []otlptracegrpc.Option
+	switch {
+	case isURL:
+		opts = append(opts, otlptracegrpc.WithEndpointURL(c.Endpoint))
+	case c.Endpoint != "":
+		opts = append(opts, otlptracegrpc.WithEndpoint(c.Endpoint))
+	}
+	if c.Insecure {
+		opts = append(opts, otlptracegrpc.WithInsecure())
+	}
+	return otlptracegrpc.New(ctx, opts...)
+}
+
+// Start starts a span under the LocalAI tracer. It is otel.Tracer(...).Start
+// spelled once, so instrumented packages do not each pick a tracer name.
+func Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name, opts...)
+}
+
+// End records err on span (when non-nil) and ends it.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
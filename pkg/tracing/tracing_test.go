@@ -0,0 +1,164 @@
+package tracing_test
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/mudler/LocalAI/pkg/tracing"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func TestTracing(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "tracing suite")
+}
+
+var _ = Describe("Config", func() {
+	It("accepts the zero config and both protocols", func() {
+		Expect(tracing.Config{}.Validate()).To(Succeed())
+		Expect(tracing.Config{Protocol: tracing.ProtocolGRPC}.Validate()).To(Succeed())
+		Expect(tracing.Config{Protocol: tracing.ProtocolHTTP, SampleRatio: 0.5}.Validate()).To(Succeed())
+	})
+
+	It("rejects an unknown protocol and an out-of-range ratio", func() {
+		Expect(tracing.Config{Protocol: "zipkin"}.Validate()).To(MatchError(ContainSubstring("unknown OTLP protocol")))
+		Expect(tracing.Config{SampleRatio: 1.5}.Validate()).To(MatchError(ContainSubstring("out of range")))
+	})
+
+	It("reads the LOCALAI_OTEL_* environment", func() {
+		GinkgoT().Setenv(tracing.EnvEndpoint, "collector:4317")
+		GinkgoT().Setenv(tracing.EnvProtocol, tracing.ProtocolHTTP)
+		GinkgoT().Setenv(tracing.EnvInsecure, "true")
+		GinkgoT().Setenv(tracing.EnvSampleRatio, "0.25")
+		Expect(tracing.ConfigFromEnv("svc")).To(Equal(tracing.Config{
+			Endpoint:    "collector:4317",
+			Protocol:    tracing.ProtocolHTTP,
+			Insecure:    true,
+			SampleRatio: 0.25,
+			ServiceName: "svc",
+		}))
+	})
+})
+
+var _ = Describe("Setup", func() {
+	BeforeEach(func() {
+		GinkgoT().Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "")
+		GinkgoT().Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "")
+	})
+
+	It("installs the propagator but exports nothing without an endpoint", func() {
+		shutdown, err := tracing.Setup(context.Background(), tracing.Config{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(shutdown(context.Background())).To(Succeed())
+		Expect(otel.GetTextMapPropagator().Fields()).To(ContainElements("traceparent", "baggage"))
+		Expect(tracing.Environ()).To(BeEmpty())
+	})
+
+	It("hands the active configuration to child processes until shutdown", func() {
+		prev := otel.GetTracerProvider()
+		DeferCleanup(func() { otel.SetTracerProvider(prev) })
+
+		shutdown, err := tracing.Setup(context.Background(), tracing.Config{
+			Endpoint:    "127.0.0.1:4317",
+			Insecure:    true,
+			SampleRatio: 0.5,
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(tracing.Environ()).To(ConsistOf(
+			tracing.EnvEndpoint+"=127.0.0.1:4317",
+			tracing.EnvInsecure+"=true",
+			tracing.EnvSampleRatio+"=0.5",
+		))
+		Expect(shutdown(context.Background())).To(Succeed())
+		Expect(tracing.Environ()).To(BeEmpty())
+	})
+
+	It("rejects an invalid configuration", func() {
+		shutdown, err := tracing.Setup(context.Background(), tracing.Config{Protocol: "udp"})
+		Expect(err).To(HaveOccurred())
+		Expect(shutdown(context.Background())).To(Succeed())
+	})
+})
+
+var _ = Describe("gRPC propagation", func() {
+	var (
+		recorder *tracetest.SpanRecorder
+		client   healthpb.HealthClient
+	)
+
+	BeforeEach(func() {
+		_, err := tracing.Setup(context.Background(), tracing.Config{})
+		Expect(err).ToNot(HaveOccurred())
+		recorder = tracetest.NewSpanRecorder()
+		prev := otel.GetTracerProvider()
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+		DeferCleanup(func() { otel.SetTracerProvider(prev) })
+
+		lis := bufconn.Listen(1 << 20)
+		srv := grpc.NewServer(
+			grpc.UnaryInterceptor(tracing.UnaryServerInterceptor()),
+			grpc.StreamInterceptor(tracing.StreamServerInterceptor()),
+		)
+		healthpb.RegisterHealthServer(srv, health.NewServer())
+		go func() { _ = srv.Serve(lis) }()
+		DeferCleanup(srv.Stop)
+
+		conn, err := grpc.NewClient("passthrough:///bufnet",
+			grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
+			grpc.WithUnaryInterceptor(tracing.UnaryClientInterceptor()),
+		)
+		Expect(err).ToNot(HaveOccurred())
+		DeferCleanup(conn.Close)
+		client = healthpb.NewHealthClient(conn)
+	})
+
+	It("continues the caller's trace on the server", func() {
+		ctx, parent := tracing.Start(context.Background(), "request")
+		_, err := client.Check(ctx, &healthpb.HealthCheckRequest{})
+		Expect(err).ToNot(HaveOccurred())
+		parent.End()
+
+		spans := map[string]sdktrace.ReadOnlySpan{}
+		for _, s := range recorder.Ended() {
+			spans[s.Name()] = s
+		}
+		Expect(spans).To(HaveKey("grpc.health.v1.Health/Check"))
+		Expect(spans).To(HaveKey("request"))
+
+		var clientSpan, serverSpan sdktrace.ReadOnlySpan
+		for _, s := range recorder.Ended() {
+			switch s.SpanKind() {
+			case trace.SpanKindClient:
+				clientSpan = s
+			case trace.SpanKindServer:
+				serverSpan = s
+			}
+		}
+		Expect(clientSpan).ToNot(BeNil())
+		Expect(serverSpan).ToNot(BeNil())
+		traceID := spans["request"].SpanContext().TraceID()
+		Expect(clientSpan.SpanContext().TraceID()).To(Equal(traceID))
+		Expect(clientSpan.Parent().SpanID()).To(Equal(spans["request"].SpanContext().SpanID()))
+		Expect(serverSpan.SpanContext().TraceID()).To(Equal(traceID))
+		Expect(serverSpan.Parent().SpanID()).To(Equal(clientSpan.SpanContext().SpanID()))
+		Expect(serverSpan.Parent().IsRemote()).To(BeTrue())
+	})
+
+	It("does not start traces for calls outside one", func() {
+		_, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(recorder.Ended()).To(BeEmpty())
+	})
+})